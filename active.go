@@ -0,0 +1,234 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/constant"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ActiveThreads is the number of threads per workgroup in the
+// generated active index kernel.
+const ActiveThreads = 256
+
+// ActiveSpec specifies a generated kernel that compacts the indexes of
+// the active elements of a struct type in a buffer (e.g., neurons that
+// are not lesioned) into a list, so that kernels can be dispatched over
+// only the active elements, parsed from the -active arg:
+// Type.FlagsField:Mask, where the elements with none of the Mask bits
+// set in the FlagsField are active, e.g., Neuron.Flags:NeuronOff.
+// The Mask is a |-separated list of integer constants or numbers.
+type ActiveSpec struct {
+
+	// name of the struct type, e.g., Neuron
+	Type string
+
+	// name of the integer flags field, e.g., Flags
+	Field string
+
+	// terms of the mask, e.g., NeuronOff
+	Terms []string
+
+	// value of the mask
+	Mask uint32
+
+	// HLSL definition of Type as a plain data struct, without methods,
+	// so the kernel does not depend on other generated code
+	TypeDef string
+}
+
+// ParseActiveSpec parses the -active arg: Type.FlagsField:Mask
+func ParseActiveSpec(spec string) (*ActiveSpec, error) {
+	tf, ms, ok := strings.Cut(spec, ":")
+	tp, fld, ok2 := strings.Cut(tf, ".")
+	if !ok || !ok2 || tp == "" || fld == "" || strings.TrimSpace(ms) == "" {
+		return nil, fmt.Errorf("gosl: -active must be of the form Type.FlagsField:Mask, e.g., Neuron.Flags:NeuronOff -- got: %q", spec)
+	}
+	as := &ActiveSpec{Type: tp, Field: fld}
+	for _, t := range strings.Split(ms, "|") {
+		if t = strings.TrimSpace(t); t != "" {
+			as.Terms = append(as.Terms, t)
+		}
+	}
+	return as, nil
+}
+
+// Name returns the kernel name, e.g., neuronactive
+func (as *ActiveSpec) Name() string {
+	return strings.ToLower(as.Type) + "active"
+}
+
+// Buffers returns the names of the buffers of the kernel: the elements,
+// the active indexes, and the number of active indexes, e.g.,
+// Neurons, NeuronActiveIndexes, NeuronActiveN
+func (as *ActiveSpec) Buffers() [3]string {
+	return [3]string{as.Type + "s", as.Type + "ActiveIndexes", as.Type + "ActiveN"}
+}
+
+// Check checks that the type, field and mask constants exist in the
+// given package with the right types, and sets the TypeDef and Mask.
+func (as *ActiveSpec) Check(pkg *packages.Package) error {
+	obj := pkg.Types.Scope().Lookup(as.Type)
+	if obj == nil {
+		return fmt.Errorf("gosl: -active type not found in gosl regions: %s", as.Type)
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return fmt.Errorf("gosl: -active type is not a struct: %s", as.Type)
+	}
+	def, fields, err := plainTypeDef(as.Type, st)
+	if err != nil {
+		return fmt.Errorf("gosl: -active %w", err)
+	}
+	as.TypeDef = def
+	ft, ok := fields[as.Field]
+	if bt, isBasic := ft.(*types.Basic); !ok || !isBasic || bt.Info()&types.IsInteger == 0 {
+		return fmt.Errorf("gosl: -active flags field must be an integer field of %s: %s", as.Type, as.Field)
+	}
+	as.Mask = 0
+	for _, t := range as.Terms {
+		if v, err := strconv.ParseUint(t, 0, 32); err == nil {
+			as.Mask |= uint32(v)
+			continue
+		}
+		c, ok := pkg.Types.Scope().Lookup(t).(*types.Const)
+		if !ok {
+			return fmt.Errorf("gosl: -active mask must be integer constants in gosl regions or numbers: %s", t)
+		}
+		v, exact := constant.Uint64Val(constant.ToInt(c.Val()))
+		if !exact || v > 0xFFFFFFFF {
+			return fmt.Errorf("gosl: -active mask constant is not a 32 bit unsigned integer: %s", t)
+		}
+		as.Mask |= uint32(v)
+	}
+	return nil
+}
+
+// GenActive generates the active index kernel in the output directory,
+// and the Go file with the CPU version in the current directory, for
+// the given -active spec, returning the kernel name.  The buffers are
+// bound as given by KernelBindings.
+func GenActive(pkg *packages.Package, spec string) (string, error) {
+	as, err := ParseActiveSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	if err := as.Check(pkg); err != nil {
+		return "", err
+	}
+	nm := as.Name()
+	bufs := as.Buffers()
+	binds := [3][2]int(KernelBindings(nm, bufs[:]))
+	if err := WriteGenerated(filepath.Join(GenDir(), nm+".hlsl"), nm, FormatShader("hlsl", as.HLSL(binds))); err != nil {
+		return "", err
+	}
+	gofn := nm + ".go"
+	pnm, _ := DocPackageName(gofn)
+	src, err := as.Go(pnm)
+	if err != nil {
+		return "", err
+	}
+	return nm, WriteGenerated(gofn, nm, src)
+}
+
+// KernelBindings returns the set and binding of each of the given
+// buffers of the generated kernel with given name: the same as in the
+// other Kernels that declare buffers with the same names, so that one
+// runtime configuration can be used for all of them, and otherwise in
+// new sets after those used by the other Kernels, in order.
+func KernelBindings(kernel string, bufs []string) [][2]int {
+	binds := make([][2]int, len(bufs))
+	next := 0
+	for _, k := range SortedKernels() {
+		for _, b := range k.Buffers {
+			if k.Name != kernel {
+				next = max(next, b.Set+1)
+			}
+		}
+	}
+	for i, bnm := range bufs {
+		binds[i] = [2]int{-1, 0}
+		for _, k := range SortedKernels() {
+			for _, b := range k.Buffers {
+				if b.Name == bnm && k.Name != kernel {
+					binds[i] = [2]int{b.Set, b.Binding}
+				}
+			}
+		}
+		if binds[i][0] < 0 {
+			binds[i][0] = next
+			next++
+		}
+	}
+	return binds
+}
+
+// HLSL returns the generated active index kernel source, with the
+// buffers at the given set and binding.  A single workgroup processes
+// ActiveThreads elements at a time, using a prefix sum (scan) of the
+// active flags in groupshared memory to compute the position of each
+// active index in the list, so that the indexes are in order.
+func (as *ActiveSpec) HLSL(binds [3][2]int) []byte {
+	var b bytes.Buffer
+	bufs := as.Buffers()
+	sc := as.Type + "ActiveScan"
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	b.WriteString(as.TypeDef + "\n")
+	b.WriteString("// note: binding is var, set\n")
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[0][1], binds[0][0], as.Type, bufs[0])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<uint> %s;\n", binds[1][1], binds[1][0], bufs[1])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<uint> %s;\n\n", binds[2][1], binds[2][0], bufs[2])
+	fmt.Fprintf(&b, "groupshared uint %s[%d];\n\n", sc, ActiveThreads)
+	fmt.Fprintf(&b, "// %sSum does an inclusive prefix sum of %s\n// across the threads of the workgroup.\n", sc, sc)
+	fmt.Fprintf(&b, "void %sSum(uint ti) {\n", sc)
+	fmt.Fprintf(&b, "\tfor (uint stride = 1; stride < %d; stride <<= 1) {\n", ActiveThreads)
+	fmt.Fprintf(&b, "\t\tuint v = 0;\n\t\tif (ti >= stride) {\n\t\t\tv = %s[ti - stride];\n\t\t}\n", sc)
+	fmt.Fprintf(&b, "\t\tGroupMemoryBarrierWithGroupSync();\n\t\t%s[ti] += v;\n\t\tGroupMemoryBarrierWithGroupSync();\n\t}\n}\n\n", sc)
+	fmt.Fprintf(&b, "// one workgroup writes the indexes of the %s with none of the\n", bufs[0])
+	fmt.Fprintf(&b, "// %s bits set in %s to %s, in order,\n", strings.Join(as.Terms, "|"), as.Field, bufs[1])
+	fmt.Fprintf(&b, "// and their number to %s[0].\n", bufs[2])
+	fmt.Fprintf(&b, "[numthreads(%d, 1, 1)]\n\n", ActiveThreads)
+	b.WriteString("void main(uint3 lid : SV_GroupThreadID) {\n")
+	b.WriteString("\tuint ti = lid.x;\n\tuint ns;\n\tuint st;\n")
+	fmt.Fprintf(&b, "\t%s.GetDimensions(ns, st);\n", bufs[0])
+	b.WriteString("\tuint base = 0;\n")
+	fmt.Fprintf(&b, "\tfor (uint i0 = 0; i0 < ns; i0 += %d) {\n", ActiveThreads)
+	b.WriteString("\t\tuint i = i0 + ti;\n\t\tuint act = 0;\n")
+	fmt.Fprintf(&b, "\t\tif (i < ns && (uint(%s[i].%s) & %du) == 0) {\n\t\t\tact = 1;\n\t\t}\n", bufs[0], as.Field, as.Mask)
+	fmt.Fprintf(&b, "\t\t%s[ti] = act;\n\t\tGroupMemoryBarrierWithGroupSync();\n\t\t%sSum(ti);\n", sc, sc)
+	fmt.Fprintf(&b, "\t\tif (act == 1) {\n\t\t\t%s[base + %s[ti] - 1] = i;\n\t\t}\n", bufs[1], sc)
+	fmt.Fprintf(&b, "\t\tbase += %s[%d];\n\t\tGroupMemoryBarrierWithGroupSync();\n\t}\n", sc, ActiveThreads-1)
+	fmt.Fprintf(&b, "\tif (ti == 0) {\n\t\t%s[0] = base;\n\t}\n}\n", bufs[2])
+	return b.Bytes()
+}
+
+// Go returns the generated Go source for the CPU version of the
+// kernel, in given package.
+func (as *ActiveSpec) Go(pkgName string) ([]byte, error) {
+	var b bytes.Buffer
+	bufs := as.Buffers()
+	mask := strings.Join(as.Terms, "|")
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	fmt.Fprintf(&b, "// %sActive returns whether the given %s is active: none of the\n", as.Type, as.Type)
+	fmt.Fprintf(&b, "// %s bits are set in its %s, as in the %s kernel.\n", mask, as.Field, as.Name())
+	fmt.Fprintf(&b, "func %sActive(v *%s) bool {\n\treturn v.%s&(%s) == 0\n}\n\n", as.Type, as.Type, as.Field, mask)
+	fmt.Fprintf(&b, "// %sCPU writes the indexes of the active %s to %s,\n", as.Type+"Active", bufs[0], bufs[1])
+	fmt.Fprintf(&b, "// in order, and their number to %s[0], as the %s kernel does\n", bufs[2], as.Name())
+	fmt.Fprintf(&b, "// on the GPU.  %s must have the same length as %s.\n", bufs[1], bufs[0])
+	b.WriteString("// Call it whenever the flags change, and upload both buffers,\n// or run the kernel instead.\n")
+	fmt.Fprintf(&b, "func %sActiveCPU(%s []%s, %s []uint32, %s []uint32) {\n", as.Type, bufs[0], as.Type, bufs[1], bufs[2])
+	fmt.Fprintf(&b, "\tn := uint32(0)\n\tfor i := range %s {\n\t\tif %sActive(&%s[i]) {\n", bufs[0], as.Type, bufs[0])
+	fmt.Fprintf(&b, "\t\t\t%s[n] = uint32(i)\n\t\t\tn++\n\t\t}\n\t}\n\t%s[0] = n\n}\n", bufs[1], bufs[2])
+	return format.Source(b.Bytes())
+}
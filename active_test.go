@@ -0,0 +1,40 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseActiveSpec(t *testing.T) {
+	as, err := ParseActiveSpec("Neuron.Flags:NeuronOff | 0x4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as.Type != "Neuron" || as.Field != "Flags" || strings.Join(as.Terms, ",") != "NeuronOff,0x4" {
+		t.Errorf("wrong parse: %+v", as)
+	}
+	if as.Name() != "neuronactive" || as.Buffers() != [3]string{"Neurons", "NeuronActiveIndexes", "NeuronActiveN"} {
+		t.Errorf("wrong names: %s %v", as.Name(), as.Buffers())
+	}
+	src, err := as.Go("axon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "v.Flags&(NeuronOff|0x4) == 0") {
+		t.Errorf("missing mask test in:\n%s", src)
+	}
+	as.Mask = 5
+	hlsl := string(as.HLSL([3][2]int{{2, 0}, {7, 0}, {8, 0}}))
+	if !strings.Contains(hlsl, "[[vk::binding(0, 8)]] RWStructuredBuffer<uint> NeuronActiveN;") || !strings.Contains(hlsl, "& 5u) == 0") {
+		t.Errorf("wrong bindings or mask in:\n%s", hlsl)
+	}
+	for _, bad := range []string{"Neuron", "Neuron.Flags", "Neuron.Flags:", ".Flags:NeuronOff", "Neuron:NeuronOff"} {
+		if _, err := ParseActiveSpec(bad); err == nil {
+			t.Errorf("expected error for: %q", bad)
+		}
+	}
+}
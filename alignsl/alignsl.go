@@ -9,8 +9,15 @@ and total size modulus checking of struct types to ensure HLSL
 
 Checks that struct sizes are an even multiple of 16 bytes
 (4 float32's), fields are 32 bit types: [U]Int32, Float32,
-and that fields that are other struct types are aligned
-at even 16 byte multiples.
+or arrays of them or of structs, and that fields that are
+other struct types, or arrays of them, are aligned at even
+16 byte multiples.  Vector types with 2-4 32 bit X, Y, Z, W
+fields (e.g., sltype.Float2) are aligned at 8 bytes for 2
+components, and 16 bytes for 3 or 4.
+
+Fields with a `gosl:"-"` struct tag are CPU-only fields that are
+excluded from the GPU struct, so only the other fields are checked,
+with the GPU sizes of any struct types that have excluded fields.
 */
 package alignsl
 
@@ -18,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"go/types"
+	"reflect"
 	"strings"
 
 	"golang.org/x/tools/go/packages"
@@ -29,10 +37,12 @@ type Context struct {
 	Structs map[*types.Struct]string // structs that have been processed already -- value is name
 	Stack   map[*types.Struct]string // structs to process in a second pass -- structs encountered during processing of other structs
 	Errs    []string                 // accumulating list of error strings -- empty if all good
+	WGSL    bool                     // check the WGSL (and GLSL std430) layout, with CheckStructWGSL
+	MSL     bool                     // with WGSL, check the MSL layout instead, where 3 component vectors are 16 bytes
 }
 
 func NewContext(sz types.Sizes) *Context {
-	cx := &Context{Sizes: sz}
+	cx := &Context{Sizes: GPUSizes{sz}}
 	cx.Structs = make(map[*types.Struct]string)
 	cx.Stack = make(map[*types.Struct]string)
 	return cx
@@ -62,22 +72,164 @@ func TypeName(tp types.Type) string {
 	return tp.String()
 }
 
+// VectorSize returns the number of components if the given struct type
+// is a vector, e.g., math32.Vector2 (sltype.Float2), with 2-4 32 bit
+// fields named X, Y, Z, W, which is an HLSL vector type (e.g., float2),
+// and otherwise returns 0.
+func VectorSize(st *types.Struct) int {
+	n := st.NumFields()
+	if n < 2 || n > 4 {
+		return 0
+	}
+	for i := 0; i < n; i++ {
+		fl := st.Field(i)
+		if fl.Name() != "XYZW"[i:i+1] {
+			return 0
+		}
+		bt, isBasic := fl.Type().Underlying().(*types.Basic)
+		if !isBasic || !(bt.Kind() == types.Uint32 || bt.Kind() == types.Int32 || bt.Kind() == types.Float32) {
+			return 0
+		}
+	}
+	return n
+}
+
+// VectorAlign returns the alignment in bytes of an HLSL vector
+// with given number of components in a Storage buffer: 8 for 2,
+// and 16 for 3 or 4.
+func VectorAlign(n int) int {
+	if n == 2 {
+		return 8
+	}
+	return 16
+}
+
+// BoolFields returns the number of the given fields of the slbool.Bool
+// type, which is an int32, so that each bool takes 4 bytes.  Up to four
+// of them can instead be packed into one slbool.Bool2 or Bool4 field,
+// which is a uint32 of 4 bytes, as a basic type.
+func BoolFields(flds []*types.Var) int {
+	n := 0
+	for _, fl := range flds {
+		nt, ok := fl.Type().(*types.Named)
+		if !ok || nt.Obj().Pkg() == nil {
+			continue
+		}
+		if nt.Obj().Name() == "Bool" && strings.HasSuffix(nt.Obj().Pkg().Path(), "/slbool") {
+			n++
+		}
+	}
+	return n
+}
+
+// Excluded returns true if the given struct tag excludes the field from
+// the GPU struct: `gosl:"-"`
+func Excluded(tag string) bool {
+	return reflect.StructTag(tag).Get("gosl") == "-"
+}
+
+// GPUFields returns the fields of the given struct that are not
+// excluded from the GPU struct with a `gosl:"-"` tag.
+func GPUFields(st *types.Struct) []*types.Var {
+	var flds []*types.Var
+	for i := 0; i < st.NumFields(); i++ {
+		if !Excluded(st.Tag(i)) {
+			flds = append(flds, st.Field(i))
+		}
+	}
+	return flds
+}
+
+// HasExcluded returns true if the given struct type has any fields
+// excluded from the GPU struct, including in nested struct types,
+// so that its GPU layout is different from the Go layout.
+func HasExcluded(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if Excluded(st.Tag(i)) {
+			return true
+		}
+		ut := st.Field(i).Type().Underlying()
+		for {
+			at, is := ut.(*types.Array)
+			if !is {
+				break
+			}
+			ut = at.Elem().Underlying()
+		}
+		if sst, is := ut.(*types.Struct); is && HasExcluded(sst) {
+			return true
+		}
+	}
+	return false
+}
+
+// GPUSizes are the sizes of types on the GPU, which are those of the
+// Go Sizes, except without the fields excluded from struct types
+// with a `gosl:"-"` tag.
+type GPUSizes struct {
+	types.Sizes
+}
+
+func (gs GPUSizes) Alignof(t types.Type) int64 {
+	switch ut := t.Underlying().(type) {
+	case *types.Array:
+		return gs.Alignof(ut.Elem())
+	case *types.Struct:
+		al := int64(1)
+		for _, f := range GPUFields(ut) {
+			al = max(al, gs.Alignof(f.Type()))
+		}
+		return al
+	}
+	return gs.Sizes.Alignof(t)
+}
+
+func (gs GPUSizes) Offsetsof(fields []*types.Var) []int64 {
+	offs := make([]int64, len(fields))
+	off := int64(0)
+	for i, f := range fields {
+		al := gs.Alignof(f.Type())
+		off = (off + al - 1) / al * al
+		offs[i] = off
+		off += gs.Sizeof(f.Type())
+	}
+	return offs
+}
+
+func (gs GPUSizes) Sizeof(t types.Type) int64 {
+	switch ut := t.Underlying().(type) {
+	case *types.Array:
+		return ut.Len() * gs.Sizeof(ut.Elem())
+	case *types.Struct:
+		flds := GPUFields(ut)
+		if len(flds) == 0 {
+			return 0
+		}
+		offs := gs.Offsetsof(flds)
+		al := gs.Alignof(t)
+		sz := offs[len(flds)-1] + gs.Sizeof(flds[len(flds)-1].Type())
+		return (sz + al - 1) / al * al
+	}
+	return gs.Sizes.Sizeof(t)
+}
+
 // CheckStruct is the primary checker -- returns hasErr = true if there
 // are any mis-aligned fields or total size of struct is not an
 // even multiple of 16 bytes -- adds details to Errs
 func CheckStruct(cx *Context, st *types.Struct, stName string) bool {
+	if cx.WGSL {
+		return CheckStructWGSL(cx, st, stName)
+	}
 	if !cx.IsNewStruct(st) {
 		return false
 	}
-	var flds []*types.Var
-	nf := st.NumFields()
+	flds := GPUFields(st)
+	nf := len(flds)
 	if nf == 0 {
 		return false
 	}
 	hasErr := false
-	for i := 0; i < nf; i++ {
-		fl := st.Field(i)
-		flds = append(flds, fl)
+	for _, fl := range flds {
 		ft := fl.Type()
 		ut := ft.Underlying()
 		if bt, isBasic := ut.(*types.Basic); isBasic {
@@ -87,7 +239,27 @@ func CheckStruct(cx *Context, st *types.Struct, stName string) bool {
 			}
 		} else {
 			if sst, is := ut.(*types.Struct); is {
-				cx.Stack[sst] = TypeName(ft)
+				if VectorSize(sst) == 0 {
+					cx.Stack[sst] = TypeName(ft)
+				}
+			} else if at, is := ut.(*types.Array); is {
+				et := at.Elem()
+				for {
+					eat, is := et.Underlying().(*types.Array)
+					if !is {
+						break
+					}
+					et = eat.Elem()
+				}
+				if sst, is := et.Underlying().(*types.Struct); is {
+					if n := VectorSize(sst); n == 3 {
+						hasErr = cx.AddError(fmt.Sprintf("    %s:  array of 3 component vectors: %s has a 16 byte stride in HLSL: use 4 component vectors", fl.Name(), TypeName(et)), hasErr, stName)
+					} else if n == 0 {
+						cx.Stack[sst] = TypeName(et)
+					}
+				} else if bt, is := et.Underlying().(*types.Basic); !is || !(bt.Kind() == types.Uint32 || bt.Kind() == types.Int32 || bt.Kind() == types.Float32) {
+					hasErr = cx.AddError(fmt.Sprintf("    %s:  array element type != [U]Int32, Float32 or struct: %s", fl.Name(), et.String()), hasErr, stName)
+				}
 			} else {
 				hasErr = cx.AddError(fmt.Sprintf("    %s:  unsupported type: %s", fl.Name(), ft.String()), hasErr, stName)
 			}
@@ -99,15 +271,32 @@ func CheckStruct(cx *Context, st *types.Struct, stName string) bool {
 	mod := totsz % 16
 	if mod != 0 {
 		needs := 4 - (mod / 4)
-		hasErr = cx.AddError(fmt.Sprintf("    total size: %d not even multiple of 16 -- needs %d extra 32bit padding fields", totsz, needs), hasErr, stName)
+		msg := fmt.Sprintf("    total size: %d not even multiple of 16 -- needs %d extra 32bit padding fields", totsz, needs)
+		if nb := BoolFields(flds); nb > 1 {
+			msg += fmt.Sprintf(", or pack its %d slbool.Bool fields into slbool.Bool2 or Bool4 fields of 4 bytes", nb)
+		}
+		hasErr = cx.AddError(msg, hasErr, stName)
 	}
 
 	// check that struct starts at mod 16 byte offset
 	for i, fl := range flds {
 		ft := fl.Type()
 		ut := ft.Underlying()
-		if _, is := ut.(*types.Struct); is {
+		for {
+			at, is := ut.(*types.Array)
+			if !is {
+				break
+			}
+			ut = at.Elem().Underlying()
+		}
+		if sst, is := ut.(*types.Struct); is {
 			off := offs[i]
+			if n := VectorSize(sst); n > 0 {
+				if al := VectorAlign(n); off%int64(al) != 0 {
+					hasErr = cx.AddError(fmt.Sprintf("    %s:  %d component vector type: %s is not at mod-%d byte offset: %d", fl.Name(), n, TypeName(ft), al, off), hasErr, stName)
+				}
+				continue
+			}
 			if off%16 != 0 {
 
 				hasErr = cx.AddError(fmt.Sprintf("    %s:  struct type: %s is not at mod-16 byte offset: %d", fl.Name(), TypeName(ft), off), hasErr, stName)
@@ -129,8 +318,9 @@ func CheckPackage(pkg *packages.Package) error {
 		str := `
 WARNING: in struct type alignment checking:
     Checks that struct sizes are an even multiple of 16 bytes (4 float32's),
-    and fields are 32 bit types: [U]Int32, Float32 or other struct,
-    and that fields that are other struct types are aligned at even 16 byte multiples.
+    and fields are 32 bit types: [U]Int32, Float32 or other struct, or arrays of them,
+    and that fields that are other struct types, or arrays of them, are aligned at even 16 byte multiples.
+    and that vectors (e.g., Float2) are aligned at 8 bytes for 2 components, and 16 for 3 or 4.
     List of errors found follow below, by struct type name:
 ` + strings.Join(cx.Errs, "\n")
 		return errors.New(str)
@@ -0,0 +1,225 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package alignsl
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// WGSLSizes are the sizes of types in a WGSL storage buffer: 32 bit
+// scalars are aligned at 4 bytes, 2 component vectors at 8 bytes, and
+// 3 and 4 component vectors at 16 bytes, with a size of 12 bytes for 3
+// components.  Structs are aligned at the largest alignment of their
+// fields, and their size is rounded up to it, and arrays have a stride
+// of the element size rounded up to its alignment.  Fields excluded with
+// a `gosl:"-"` tag are not included, as in GPUSizes.  This is also the
+// std430 layout of GLSL buffers.
+type WGSLSizes struct {
+	GPUSizes
+}
+
+func (ws WGSLSizes) Alignof(t types.Type) int64 {
+	return vectorSizes{ws.GPUSizes, 12}.Alignof(t)
+}
+
+func (ws WGSLSizes) Offsetsof(fields []*types.Var) []int64 {
+	return vectorSizes{ws.GPUSizes, 12}.Offsetsof(fields)
+}
+
+func (ws WGSLSizes) Sizeof(t types.Type) int64 {
+	return vectorSizes{ws.GPUSizes, 12}.Sizeof(t)
+}
+
+// MSLSizes are the sizes of types in an MSL device buffer, which are
+// the same as WGSLSizes, except that 3 component vectors (float3, etc)
+// have a size of 16 bytes, as their alignment.
+type MSLSizes struct {
+	GPUSizes
+}
+
+func (ms MSLSizes) Alignof(t types.Type) int64 {
+	return vectorSizes{ms.GPUSizes, 16}.Alignof(t)
+}
+
+func (ms MSLSizes) Offsetsof(fields []*types.Var) []int64 {
+	return vectorSizes{ms.GPUSizes, 16}.Offsetsof(fields)
+}
+
+func (ms MSLSizes) Sizeof(t types.Type) int64 {
+	return vectorSizes{ms.GPUSizes, 16}.Sizeof(t)
+}
+
+// vectorSizes are the sizes of WGSLSizes and MSLSizes, which only
+// differ in the size of 3 component vectors: vec3 bytes.
+type vectorSizes struct {
+	GPUSizes
+	vec3 int64
+}
+
+func (ws vectorSizes) Alignof(t types.Type) int64 {
+	switch ut := t.Underlying().(type) {
+	case *types.Array:
+		return ws.Alignof(ut.Elem())
+	case *types.Struct:
+		if n := VectorSize(ut); n > 0 {
+			return int64(VectorAlign(n))
+		}
+		al := int64(1)
+		for _, f := range GPUFields(ut) {
+			al = max(al, ws.Alignof(f.Type()))
+		}
+		return al
+	}
+	return ws.GPUSizes.Alignof(t)
+}
+
+func (ws vectorSizes) Offsetsof(fields []*types.Var) []int64 {
+	offs := make([]int64, len(fields))
+	off := int64(0)
+	for i, f := range fields {
+		al := ws.Alignof(f.Type())
+		off = (off + al - 1) / al * al
+		offs[i] = off
+		off += ws.Sizeof(f.Type())
+	}
+	return offs
+}
+
+func (ws vectorSizes) Sizeof(t types.Type) int64 {
+	switch ut := t.Underlying().(type) {
+	case *types.Array:
+		al := ws.Alignof(ut.Elem())
+		stride := (ws.Sizeof(ut.Elem()) + al - 1) / al * al
+		return ut.Len() * stride
+	case *types.Struct:
+		if n := VectorSize(ut); n == 3 {
+			return ws.vec3
+		} else if n > 0 {
+			return int64(4 * n)
+		}
+		flds := GPUFields(ut)
+		if len(flds) == 0 {
+			return 0
+		}
+		offs := ws.Offsetsof(flds)
+		al := ws.Alignof(t)
+		sz := offs[len(flds)-1] + ws.Sizeof(flds[len(flds)-1].Type())
+		return (sz + al - 1) / al * al
+	}
+	return ws.GPUSizes.Sizeof(t)
+}
+
+// CheckStructWGSL checks that the Go layout of the given struct is the
+// same as in a WGSL storage buffer (see WGSLSizes), or an MSL device
+// buffer if cx.MSL (see MSLSizes), returning hasErr = true
+// if any fields are at different offsets, with the padding they need,
+// or the total size differs, or there are fields that are not 32 bit
+// types, or vectors, or arrays or structs of them -- adds details to Errs.
+func CheckStructWGSL(cx *Context, st *types.Struct, stName string) bool {
+	if !cx.IsNewStruct(st) {
+		return false
+	}
+	flds := GPUFields(st)
+	nf := len(flds)
+	if nf == 0 {
+		return false
+	}
+	hasErr := false
+	for _, fl := range flds {
+		et := fl.Type()
+		for {
+			at, is := et.Underlying().(*types.Array)
+			if !is {
+				break
+			}
+			et = at.Elem()
+		}
+		switch ut := et.Underlying().(type) {
+		case *types.Basic:
+			if kind := ut.Kind(); !(kind == types.Uint32 || kind == types.Int32 || kind == types.Float32) {
+				hasErr = cx.AddError(fmt.Sprintf("    %s:  basic type != [U]Int32 or Float32: %s", fl.Name(), ut.String()), hasErr, stName)
+			}
+		case *types.Struct:
+			if VectorSize(ut) == 0 {
+				cx.Stack[ut] = TypeName(et)
+			}
+		default:
+			hasErr = cx.AddError(fmt.Sprintf("    %s:  unsupported type: %s", fl.Name(), fl.Type().String()), hasErr, stName)
+		}
+	}
+	var ws types.Sizes = WGSLSizes{GPUSizes{cx.Sizes}}
+	lang := "WGSL"
+	if cx.MSL {
+		ws = MSLSizes{GPUSizes{cx.Sizes}}
+		lang = "MSL"
+	}
+	goOffs := cx.Sizes.Offsetsof(flds)
+	wOffs := ws.Offsetsof(flds)
+	for i, fl := range flds {
+		if d := wOffs[i] - goOffs[i]; d != 0 {
+			hasErr = cx.AddError(fmt.Sprintf("    %s:  %s is at offset %d in %s, %d in Go -- needs %d bytes of padding before it", fl.Name(), TypeName(fl.Type()), wOffs[i], lang, goOffs[i], d), hasErr, stName)
+			break // later offsets follow from this one
+		}
+		if gsz, wsz := cx.Sizes.Sizeof(fl.Type()), ws.Sizeof(fl.Type()); gsz != wsz {
+			hasErr = cx.AddError(fmt.Sprintf("    %s:  %s has size %d in %s, %d in Go: use 4 component vectors in arrays, and pad struct types", fl.Name(), TypeName(fl.Type()), wsz, lang, gsz), hasErr, stName)
+			break
+		}
+	}
+	gsz, wsz := cx.Sizes.Sizeof(st), ws.Sizeof(st)
+	if !hasErr && gsz != wsz {
+		hasErr = cx.AddError(fmt.Sprintf("    total size: %d in %s, %d in Go -- needs %d extra 32bit padding fields", wsz, lang, gsz, (wsz-gsz)/4), hasErr, stName)
+	}
+	return hasErr
+}
+
+// CheckPackageWGSL is the entry point for checking the struct types of
+// a package for WGSL, as in CheckPackage, returning an error if the Go
+// layout of any of them differs from that in a WGSL storage buffer.
+func CheckPackageWGSL(pkg *packages.Package) error {
+	return checkPackageLayout(pkg, "WGSL storage buffer", false)
+}
+
+// CheckPackageStd430 is the entry point for checking the struct types of
+// a package for GLSL buffers with the std430 layout, which is the same
+// as that of WGSL storage buffers (see WGSLSizes), returning an error if
+// the Go layout of any of them differs from it.
+func CheckPackageStd430(pkg *packages.Package) error {
+	return checkPackageLayout(pkg, "GLSL std430 buffer", false)
+}
+
+// CheckPackageMSL is the entry point for checking the struct types of
+// a package for MSL device buffers (see MSLSizes), returning an error if
+// the Go layout of any of them differs from it.
+func CheckPackageMSL(pkg *packages.Package) error {
+	return checkPackageLayout(pkg, "MSL device buffer", true)
+}
+
+// checkPackageLayout checks the struct types of the given package for
+// the WGSLSizes layout, or MSLSizes if msl, in the given kind of buffer.
+func checkPackageLayout(pkg *packages.Package, kind string, msl bool) error {
+	cx := NewContext(pkg.TypesSizes)
+	cx.WGSL = true
+	cx.MSL = msl
+	sc := pkg.Types.Scope()
+	hasErr := CheckScope(cx, sc, 0)
+	er := CheckStack(cx)
+	if hasErr || er {
+		str := `
+WARNING: in struct type alignment checking for a ` + kind + `:
+    Checks that fields are 32 bit types: [U]Int32, Float32, vectors, or other struct, or arrays of them,
+    and that the Go layout of each struct is the same as in the buffer, where vectors
+    (e.g., Float2) are aligned at 8 bytes for 2 components, and 16 for 3 or 4, and structs at the
+    largest alignment of their fields, with their size rounded up to it.
+    List of errors found follow below, by struct type name:
+` + strings.Join(cx.Errs, "\n")
+		return errors.New(str)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emer/gosl/v2/sltune"
+)
+
+// AutotuneSizes returns the workgroup sizes from the -autotune flag.
+func AutotuneSizes() ([]int, error) {
+	var sizes []int
+	for _, s := range strings.Split(*autotune, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("gosl: -autotune: invalid workgroup size: %q", s)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// GenVariants writes a variant of the given kernel with the given source
+// for each of the given workgroup sizes, in the output directory, named
+// as in sltune.VariantName, and returns the names of the variants.
+// Only 1D kernels that do not use groupshared memory, which is typically
+// sized according to the number of threads, can have variants.
+func GenVariants(k *Kernel, src []byte, sizes []int) []string {
+	code := StripHLSLComments(src)
+	loc := numthreadsRe.FindIndex(src)
+	switch {
+	case loc == nil || !numthreadsRe.Match(code):
+		return nil
+	case k.Workgroup[1] != 1 || k.Workgroup[2] != 1:
+		fmt.Printf("gosl: -autotune: no variants for kernel %s: only 1D workgroups can be tuned, not: %v\n", k.Name, k.Workgroup)
+		return nil
+	case bytes.Contains(code, []byte("groupshared")):
+		fmt.Printf("gosl: -autotune: no variants for kernel %s: it uses groupshared memory\n", k.Name)
+		return nil
+	}
+	var nms []string
+	for _, th := range sizes {
+		nm := sltune.VariantName(k.Name, th)
+		var b bytes.Buffer
+		b.Write(src[:loc[0]])
+		fmt.Fprintf(&b, "[numthreads(%d, 1, 1)]", th)
+		b.Write(src[loc[1]:])
+		if err := os.WriteFile(filepath.Join(GenDir(), nm+".hlsl"), b.Bytes(), 0644); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		k.Variants = append(k.Variants, th)
+		nms = append(nms, nm)
+	}
+	return nms
+}
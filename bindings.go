@@ -0,0 +1,265 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BindingsFile is the name of the Go file generated in the package
+// directory for the GPU buffers declared by //gosl: buffer directives.
+const BindingsFile = "gosl_bindings.go"
+
+// BindingsType is the name of the Go type generated in the BindingsFile.
+const BindingsType = "GPUBuffers"
+
+// BindingBuffer is a GPU buffer declared by a directive in the doc comment
+// of a struct type, which is the element type of the buffer:
+//
+//	//gosl: buffer <set> <Name>
+//
+// The buffers of each set are bound in the order of their directives, at
+// bindings 0, 1, ..., and the sets must be numbered from 0 without gaps,
+// as the sets of vgpu are.  A type can have directives for multiple buffers.
+type BindingBuffer struct {
+
+	// name of the buffer, as in the shader code
+	Name string
+
+	// element type name of the buffer
+	Type string
+
+	// descriptor set of the buffer
+	Set int
+
+	// binding of the buffer in its set
+	Binding int
+
+	// position of the directive, for messages
+	Pos token.Position
+}
+
+// bufferDirectives returns the args of the buffer directives in given
+// doc comment, with the position of each.
+func bufferDirectives(doc *ast.CommentGroup) ([]string, []token.Pos) {
+	if doc == nil {
+		return nil, nil
+	}
+	var args []string
+	var poss []token.Pos
+	for _, c := range doc.List {
+		txt := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if a, ok := strings.CutPrefix(txt, "gosl: buffer "); ok {
+			args = append(args, strings.TrimSpace(a))
+			poss = append(poss, c.Pos())
+		}
+	}
+	return args, poss
+}
+
+// FindBindingBuffers returns the BindingBuffers declared in the given Go
+// files, with the name and directory of their package, in which the
+// BindingsFile is generated, and an error for each directive that is not
+// valid.  The buffers are sorted by set and binding.
+func FindBindingBuffers(files []string) (string, string, []*BindingBuffer, error) {
+	var bufs []*BindingBuffer
+	var errs []error
+	pkgName, dir := "", ""
+	names := map[string]*BindingBuffer{}
+	fset := token.NewFileSet()
+	for _, fn := range files {
+		if !strings.HasSuffix(fn, ".go") || filepath.Base(fn) == BindingsFile {
+			continue
+		}
+		f, err := parser.ParseFile(fset, fn, nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, s := range gd.Specs {
+				ts := s.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				args, poss := bufferDirectives(doc)
+				for i, a := range args {
+					pos := fset.Position(poss[i])
+					bb, err := newBindingBuffer(ts, a)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("%s: gosl: buffer: %w", pos, err))
+						continue
+					}
+					if pkgName == "" {
+						pkgName, dir = f.Name.Name, filepath.Dir(fn)
+					} else if f.Name.Name != pkgName || filepath.Dir(fn) != dir {
+						errs = append(errs, fmt.Errorf("%s: gosl: buffer: all buffers must be declared in one package, in %s", pos, dir))
+						continue
+					}
+					if ob := names[bb.Name]; ob != nil {
+						errs = append(errs, fmt.Errorf("%s: gosl: buffer: %s is already declared at %s", pos, bb.Name, ob.Pos))
+						continue
+					}
+					bb.Pos = pos
+					names[bb.Name] = bb
+					bufs = append(bufs, bb)
+				}
+			}
+		}
+	}
+	sort.SliceStable(bufs, func(i, j int) bool { return bufs[i].Set < bufs[j].Set })
+	set, binding := -1, 0
+	for _, bb := range bufs {
+		if bb.Set != set {
+			if bb.Set != set+1 {
+				errs = append(errs, fmt.Errorf("%s: gosl: buffer: set %d of %s: the sets must be numbered from 0 without gaps", bb.Pos, bb.Set, bb.Name))
+			}
+			set, binding = bb.Set, 0
+		}
+		bb.Binding = binding
+		binding++
+	}
+	return pkgName, dir, bufs, errors.Join(errs...)
+}
+
+// newBindingBuffer returns the BindingBuffer for the given struct type
+// with a buffer directive with the given args.
+func newBindingBuffer(ts *ast.TypeSpec, args string) (*BindingBuffer, error) {
+	if _, ok := ts.Type.(*ast.StructType); !ok || ts.TypeParams != nil {
+		return nil, fmt.Errorf("%s must be a struct type", ts.Name.Name)
+	}
+	fs := strings.Fields(args)
+	if len(fs) != 2 {
+		return nil, fmt.Errorf("the directive must be: //gosl: buffer <set> <Name>, not: %s", args)
+	}
+	set, err := strconv.Atoi(fs[0])
+	if err != nil || set < 0 {
+		return nil, fmt.Errorf("set must be a number >= 0, not: %s", fs[0])
+	}
+	if !token.IsExported(fs[1]) {
+		return nil, fmt.Errorf("buffer name must be an exported identifier, for the field of %s: %s", BindingsType, fs[1])
+	}
+	if fs[1] == "System" {
+		return nil, fmt.Errorf("buffer name %s is a field of %s: use another name", fs[1], BindingsType)
+	}
+	return &BindingBuffer{Name: fs[1], Type: ts.Name.Name, Set: set}, nil
+}
+
+// CheckBindings reports the buffers of the Kernels with the name of one
+// of the given buffers that have a different set, binding or element type.
+func CheckBindings(bufs []*BindingBuffer) {
+	for _, bb := range bufs {
+		for _, k := range SortedKernels() {
+			for _, b := range k.Buffers {
+				if b.Name != bb.Name {
+					continue
+				}
+				if b.Set != bb.Set || b.Binding != bb.Binding || b.Type != bb.Type {
+					fmt.Printf("%s: gosl: buffer: %s is %s at set %d, binding %d, but in kernel %s it is %s at set %d, binding %d\n", bb.Pos, bb.Name, bb.Type, bb.Set, bb.Binding, k.Name, b.Type, b.Set, b.Binding)
+				}
+			}
+		}
+	}
+}
+
+// BindingsGo returns the Go source of the BindingsFile for the given
+// buffers, in the given package, with a BindingsType type that has a
+// slice field for the CPU data of each buffer, and methods to configure
+// the vgpu vars of the buffers, and to copy the slices to and from the GPU.
+func BindingsGo(pkgName string, bufs []*BindingBuffer) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	b.WriteString("import (\n\t\"slices\"\n\t\"unsafe\"\n\n\t\"cogentcore.org/core/vgpu\"\n)\n\n")
+	fmt.Fprintf(&b, "// %s has the CPU data of the GPU buffers declared with\n// //gosl: buffer directives, which are configured as the vars of\n// a vgpu compute System with Config, and copied to and from the\n// GPU with SyncToGPU and SyncFromGPU.\n", BindingsType)
+	fmt.Fprintf(&b, "type %s struct {\n", BindingsType)
+	for _, bb := range bufs {
+		fmt.Fprintf(&b, "\n\t// %s buffer, at set %d, binding %d\n\t%s []%s\n", bb.Name, bb.Set, bb.Binding, bb.Name, bb.Type)
+	}
+	b.WriteString("\n\t// System is the vgpu compute system, set by Config\n\tSystem *vgpu.System\n")
+	b.WriteString("\n\t// values of the buffers, by name\n\tvalues map[string]*vgpu.Value\n}\n\n")
+
+	nSets := 0
+	if len(bufs) > 0 {
+		nSets = bufs[len(bufs)-1].Set + 1
+	}
+	b.WriteString("// Config adds the vars of the buffers to the given System, with one\n// value each, sized for the current lengths of the slices, which must\n// not be empty, configures the System, and binds the values.\n")
+	fmt.Fprintf(&b, "func (gb *%s) Config(sy *vgpu.System) error {\n", BindingsType)
+	b.WriteString("\tgb.System = sy\n\tvars := sy.Vars()\n")
+	fmt.Fprintf(&b, "\tsets := make([]*vgpu.VarSet, %d)\n", nSets)
+	b.WriteString("\tfor i := range sets {\n\t\tsets[i] = vars.AddSet()\n\t}\n")
+	b.WriteString("\tvrs := map[string]*vgpu.Var{}\n")
+	for _, bb := range bufs {
+		fmt.Fprintf(&b, "\tvrs[%q] = sets[%d].AddStruct(%q, int(unsafe.Sizeof(%s{})), len(gb.%s), vgpu.Storage, vgpu.ComputeShader)\n", bb.Name, bb.Set, bb.Name, bb.Type, bb.Name)
+	}
+	b.WriteString("\tfor _, st := range sets {\n\t\tst.ConfigValues(1)\n\t}\n\tsy.Config()\n")
+	b.WriteString("\tgb.values = map[string]*vgpu.Value{}\n")
+	for _, bb := range bufs {
+		fmt.Fprintf(&b, "\tif err := gb.bind(vrs, %d, %q); err != nil {\n\t\treturn err\n\t}\n", bb.Set, bb.Name)
+	}
+	b.WriteString("\treturn nil\n}\n\n")
+
+	b.WriteString("// bind binds the value of the var with given set and name.\n")
+	fmt.Fprintf(&b, "func (gb *%s) bind(vrs map[string]*vgpu.Var, set int, name string) error {\n", BindingsType)
+	b.WriteString("\tvl, err := vrs[name].Values.ValueByIndexTry(0)\n\tif err != nil {\n\t\treturn err\n\t}\n\tgb.values[name] = vl\n\treturn gb.System.Vars().BindDynamicValueIndex(set, name, 0)\n}\n\n")
+
+	b.WriteString("// SyncToGPU copies the slices of the given buffers, or all of them\n// if none, to their values, and syncs all of the values to the GPU.\n")
+	fmt.Fprintf(&b, "func (gb *%s) SyncToGPU(names ...string) {\n", BindingsType)
+	for _, bb := range bufs {
+		fmt.Fprintf(&b, "\tif len(gb.%s) > 0 && (len(names) == 0 || slices.Contains(names, %q)) {\n\t\tgb.values[%q].CopyFromBytes(unsafe.Pointer(&gb.%s[0]))\n\t}\n", bb.Name, bb.Name, bb.Name, bb.Name)
+	}
+	b.WriteString("\tgb.System.Mem.SyncToGPU()\n}\n\n")
+
+	b.WriteString("// SyncFromGPU syncs the values of the given buffers, or all of them\n// if none, from the GPU, and copies them to their slices.\n")
+	fmt.Fprintf(&b, "func (gb *%s) SyncFromGPU(names ...string) error {\n", BindingsType)
+	for _, bb := range bufs {
+		fmt.Fprintf(&b, "\tif len(gb.%s) > 0 && (len(names) == 0 || slices.Contains(names, %q)) {\n", bb.Name, bb.Name)
+		fmt.Fprintf(&b, "\t\tif err := gb.System.Mem.SyncValueIndexFromGPU(%d, %q, 0); err != nil {\n\t\t\treturn err\n\t\t}\n", bb.Set, bb.Name)
+		fmt.Fprintf(&b, "\t\tgb.values[%q].CopyToBytes(unsafe.Pointer(&gb.%s[0]))\n\t}\n", bb.Name, bb.Name)
+	}
+	b.WriteString("\treturn nil\n}\n")
+	return format.Source(b.Bytes())
+}
+
+// GenBindings generates the BindingsFile in the package directory for
+// the buffers declared by //gosl: buffer directives in the given files,
+// if any, for the vgpu API version of the -vgpu flag.  It is only
+// written if it changed.
+func GenBindings(files []string) error {
+	pkgName, dir, bufs, err := FindBindingBuffers(files)
+	if err != nil {
+		return err
+	}
+	if len(bufs) == 0 {
+		return nil
+	}
+	CheckBindings(bufs)
+	src, err := BindingsGo(pkgName, bufs)
+	if err != nil {
+		return err
+	}
+	src = VgpuTarget.Convert(src)
+	fn := filepath.Join(dir, BindingsFile)
+	RecordOutput(fn)
+	if cur, err := os.ReadFile(fn); err == nil && bytes.Equal(cur, src) {
+		return nil // unchanged, e.g., for -watch
+	}
+	return os.WriteFile(fn, src, 0644)
+}
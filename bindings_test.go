@@ -0,0 +1,73 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBindings(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "layer.go")
+	src := `package axon
+
+//gosl: buffer 0 Params
+type LayerParams struct {
+	Gain float32
+}
+
+type (
+	// Neuron has the neuron variables
+	//gosl: buffer 1 Neurons
+	//gosl: buffer 1 PrvNeurons
+	Neuron struct {
+		Act float32
+	}
+
+	//gosl: buffer 2 Synapses
+	Synapse struct {
+		Wt float32
+	}
+)
+`
+	os.WriteFile(fn, []byte(src), 0644)
+	pkgName, pdir, bufs, err := FindBindingBuffers([]string{fn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkgName != "axon" || pdir != dir || len(bufs) != 4 {
+		t.Fatalf("wrong package %s, dir %s, or buffers: %d", pkgName, pdir, len(bufs))
+	}
+	if pb := bufs[2]; pb.Name != "PrvNeurons" || pb.Type != "Neuron" || pb.Set != 1 || pb.Binding != 1 {
+		t.Errorf("wrong buffer: %+v", pb)
+	}
+	gsrc, err := BindingsGo(pkgName, bufs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type GPUBuffers struct {",
+		"\tNeurons []Neuron\n",
+		"sets := make([]*vgpu.VarSet, 3)",
+		`vrs["Synapses"] = sets[2].AddStruct("Synapses", int(unsafe.Sizeof(Synapse{})), len(gb.Synapses), vgpu.Storage, vgpu.ComputeShader)`,
+		`if err := gb.bind(vrs, 1, "PrvNeurons"); err != nil {`,
+		`gb.values["Params"].CopyFromBytes(unsafe.Pointer(&gb.Params[0]))`,
+		`if err := gb.System.Mem.SyncValueIndexFromGPU(1, "Neurons", 0); err != nil {`,
+	} {
+		if !strings.Contains(string(gsrc), want) {
+			t.Errorf("missing %q in:\n%s", want, gsrc)
+		}
+	}
+
+	src = strings.Replace(src, "buffer 2 Synapses", "buffer 3 Synapses", 1)
+	os.WriteFile(fn, []byte(src+"\n//gosl: buffer 0 params\ntype Pool struct{}\n"), 0644)
+	_, _, _, err = FindBindingBuffers([]string{fn})
+	if err == nil || !strings.Contains(err.Error(), "without gaps") || !strings.Contains(err.Error(), "exported") {
+		t.Errorf("expected errors for set gap and unexported name, got: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// BoundsBuffer returns the buffer whose number of elements bounds the
+// thread index of the given 1D kernel: the first buffer (in set, binding
+// order) with an element indexed by the x thread index, e.g., Data[idx.x],
+// in the given (comment-stripped) code, or nil if none.
+func BoundsBuffer(k *Kernel, code []byte) *Buffer {
+	ix := k.Index + ".x"
+	for _, b := range k.Buffers {
+		for _, u := range BufferUses(code, b.Name) {
+			if u.Index == ix {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// BoundsCheck returns the source of the given 1D kernel with an early
+// exit prologue at the start of the entry point function, so that the
+// number of elements does not need to be a multiple of the workgroup size:
+//
+//	uint gosl_n, gosl_stride;
+//	Data.GetDimensions(gosl_n, gosl_stride);
+//	if (idx.x >= gosl_n) {
+//		return;
+//	}
+//
+// where Data is the BoundsBuffer, which is set as the Bounds of the kernel.
+// Kernels that already compare the thread index against a count are not
+// changed, and false is returned, as it is for kernels with more than
+// one dimension, with barriers, or without a buffer indexed by the
+// thread index.
+func BoundsCheck(k *Kernel, src []byte) ([]byte, bool) {
+	if k.Index == "" || k.IndexDims != 1 {
+		return src, false
+	}
+	code := StripHLSLComments(src)
+	idx := regexp.QuoteMeta(k.Index)
+	if regexp.MustCompile(`\b` + idx + `\s*\.\s*x\s*(<|>)`).Match(code) {
+		return src, false
+	}
+	if bytes.Contains(code, []byte("WithGroupSync")) {
+		return src, false // all threads must reach the barriers
+	}
+	b := BoundsBuffer(k, code)
+	if b == nil {
+		if *debug {
+			fmt.Printf("kernel %s: no buffer indexed by %s.x to bound the thread index\n", k.Name, k.Index)
+		}
+		return src, false
+	}
+	loc := regexp.MustCompile(`\bvoid\s+` + regexp.QuoteMeta(k.Entry) + `\s*\([^)]*SV_DispatchThreadID[^)]*\)\s*\{`).FindIndex(src)
+	if loc == nil {
+		return src, false
+	}
+	pro := fmt.Sprintf("\n\tuint gosl_n, gosl_stride;\n\t%s.GetDimensions(gosl_n, gosl_stride);\n\tif (%s.x >= gosl_n) {\n\t\treturn;\n\t}\n", b.Name, k.Index)
+	k.Bounds = b.Name
+	out := make([]byte, 0, len(src)+len(pro))
+	out = append(out, src[:loc[1]]...)
+	out = append(out, pro...)
+	return append(out, src[loc[1]:]...), true
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBoundsCheck(t *testing.T) {
+	src := `[[vk::binding(0, 0)]] RWStructuredBuffer<ParamStruct> Params;
+[[vk::binding(0, 1)]] RWStructuredBuffer<DataStruct> Data;
+
+[numthreads(64, 1, 1)]
+void main(uint3 idx : SV_DispatchThreadID) {
+	Params[0].IntegFromRaw(Data[idx.x]);
+}
+`
+	k := ParseKernel("basic", []byte(src))
+	out, ok := BoundsCheck(k, []byte(src))
+	if !ok {
+		t.Fatal("BoundsCheck did not add prologue")
+	}
+	if k.Bounds != "Data" {
+		t.Errorf("Bounds: got %q, want Data", k.Bounds)
+	}
+	want := "void main(uint3 idx : SV_DispatchThreadID) {\n\tuint gosl_n, gosl_stride;\n\tData.GetDimensions(gosl_n, gosl_stride);\n\tif (idx.x >= gosl_n) {\n\t\treturn;\n\t}\n\n\tParams[0]"
+	if !strings.Contains(string(out), want) {
+		t.Errorf("prologue not found in:\n%s", out)
+	}
+
+	checked := strings.Replace(src, "\tParams[0].IntegFromRaw(Data[idx.x]);", "\tif (idx.x < Params[0].N) {\n\t\tParams[0].IntegFromRaw(Data[idx.x]);\n\t}", 1)
+	if _, ok := BoundsCheck(ParseKernel("basic", []byte(checked)), []byte(checked)); ok {
+		t.Error("BoundsCheck added prologue to kernel with its own check")
+	}
+	sync := strings.Replace(src, "\tParams[0]", "\tGroupMemoryBarrierWithGroupSync();\n\tParams[0]", 1)
+	if _, ok := BoundsCheck(ParseKernel("basic", []byte(sync)), []byte(sync)); ok {
+		t.Error("BoundsCheck added prologue to kernel with barriers")
+	}
+}
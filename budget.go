@@ -0,0 +1,178 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// hlslTypeSize returns the size in bytes of the given HLSL basic
+// scalar or vector type (e.g., float, uint2), or 0 if not a basic type.
+func hlslTypeSize(typ string) int64 {
+	for _, bt := range []string{"float", "uint", "int", "bool"} {
+		if typ == bt {
+			return 4
+		}
+		if len(typ) == len(bt)+1 && typ[:len(bt)] == bt && typ[len(bt)] >= '2' && typ[len(bt)] <= '4' {
+			return 4 * int64(typ[len(bt)]-'0')
+		}
+	}
+	return 0
+}
+
+// SetBufferSizes sets the ElemSize of the buffers of all Kernels,
+// from the HLSL basic types or the struct types in the given package.
+func SetBufferSizes(pkg *packages.Package) {
+	for _, k := range Kernels {
+		for _, b := range k.Buffers {
+			if b.ElemSize = hlslTypeSize(b.Type); b.ElemSize > 0 {
+				continue
+			}
+			obj := pkg.Types.Scope().Lookup(b.Type)
+			if obj == nil {
+				fmt.Printf("WARNING: -budget: element type %s of buffer %s in kernel %s not found\n", b.Type, b.Name, k.Name)
+				continue
+			}
+			b.ElemSize = pkg.TypesSizes.Sizeof(obj.Type())
+		}
+	}
+}
+
+// BudgetBuffers returns the unique buffers across all Kernels,
+// by name, sorted by set, binding and name.
+func BudgetBuffers() []*Buffer {
+	bm := map[string]*Buffer{}
+	for _, k := range SortedKernels() {
+		for _, b := range k.Buffers {
+			if _, has := bm[b.Name]; !has {
+				bm[b.Name] = b
+			}
+		}
+	}
+	bs := make([]*Buffer, 0, len(bm))
+	for _, b := range bm {
+		bs = append(bs, b)
+	}
+	sort.Slice(bs, func(i, j int) bool {
+		bi, bj := bs[i], bs[j]
+		if bi.Set != bj.Set {
+			return bi.Set < bj.Set
+		}
+		if bi.Binding != bj.Binding {
+			return bi.Binding < bj.Binding
+		}
+		return bi.Name < bj.Name
+	})
+	return bs
+}
+
+// GenMemoryBudget generates a Go file at given path with a MemoryBudget
+// function computing the GPU memory required for the buffers of all
+// Kernels, given the number of elements of each buffer, with a report
+// that can be checked against device limits before allocating.
+func GenMemoryBudget(path string) error {
+	pnm, _ := DocPackageName(path)
+	bs := BudgetBuffers()
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pnm)
+	b.WriteString("import (\n\t\"fmt\"\n\t\"strings\"\n)\n\n")
+	b.WriteString("// BufferNames are the names of the GPU buffers of all kernels,\n// in the order of the counts passed to MemoryBudget\nvar BufferNames = [...]string{")
+	for i, bf := range bs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(bf.Name))
+	}
+	b.WriteString("}\n\n// BufferTypes are the element types of the buffers\nvar BufferTypes = [...]string{")
+	for i, bf := range bs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(bf.Type))
+	}
+	b.WriteString("}\n\n// BufferElemSizes are the sizes in bytes of the elements of the buffers\nvar BufferElemSizes = [...]int{")
+	for i, bf := range bs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%d", bf.ElemSize)
+	}
+	b.WriteString("}\n")
+	b.WriteString(`
+// BufferUsage is the GPU memory used by one buffer
+type BufferUsage struct {
+	Name     string
+	Type     string
+	ElemSize int
+	N        int
+	Bytes    int
+}
+
+// BudgetReport is the GPU memory required for all of the buffers
+type BudgetReport struct {
+	Buffers []BufferUsage
+	Total   int
+}
+
+// MemoryBudget returns the GPU memory required for the buffers,
+// given the number of elements of each buffer, in the order of BufferNames.
+func MemoryBudget(n ...int) BudgetReport {
+	if len(n) != len(BufferNames) {
+		panic(fmt.Sprintf("MemoryBudget: %d counts given for %d buffers: %s", len(n), len(BufferNames), strings.Join(BufferNames[:], ", ")))
+	}
+	var br BudgetReport
+	for i, nm := range BufferNames {
+		bu := BufferUsage{Name: nm, Type: BufferTypes[i], ElemSize: BufferElemSizes[i], N: n[i]}
+		bu.Bytes = bu.ElemSize * bu.N
+		br.Buffers = append(br.Buffers, bu)
+		br.Total += bu.Bytes
+	}
+	return br
+}
+
+// String returns a readable breakdown of the memory for each buffer
+func (br *BudgetReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-20s %-20s %10s %12s %14s\n", "Buffer", "Type", "ElemSize", "N", "Bytes")
+	for _, bu := range br.Buffers {
+		fmt.Fprintf(&b, "%-20s %-20s %10d %12d %14d\n", bu.Name, bu.Type, bu.ElemSize, bu.N, bu.Bytes)
+	}
+	fmt.Fprintf(&b, "%-20s %-20s %10s %12s %14d\n", "Total", "", "", "", br.Total)
+	return b.String()
+}
+
+// Check returns an error with a readable breakdown if any buffer is
+// larger than maxBuffer bytes (e.g., the MaxStorageBufferRange limit of
+// the device), or the total is larger than maxTotal bytes (e.g., the size
+// of the device local memory heap).  Limits <= 0 are not checked.
+func (br *BudgetReport) Check(maxBuffer, maxTotal int) error {
+	var errs []string
+	for _, bu := range br.Buffers {
+		if maxBuffer > 0 && bu.Bytes > maxBuffer {
+			errs = append(errs, fmt.Sprintf("buffer %s needs %d bytes, more than the maximum buffer size of %d", bu.Name, bu.Bytes, maxBuffer))
+		}
+	}
+	if maxTotal > 0 && br.Total > maxTotal {
+		errs = append(errs, fmt.Sprintf("buffers need %d bytes in total, more than the available %d", br.Total, maxTotal))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("GPU memory budget exceeded:\n%s\n\n%s", strings.Join(errs, "\n"), br.String())
+}
+`)
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	return WriteGenerated(path, "budget", src)
+}
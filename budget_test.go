@@ -0,0 +1,15 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestHLSLTypeSize(t *testing.T) {
+	for typ, sz := range map[string]int64{"float": 4, "uint2": 8, "int3": 12, "float4": 16, "Neuron": 0, "float5": 0} {
+		if s := hlslTypeSize(typ); s != sz {
+			t.Errorf("%s: expected %d, got %d", typ, sz, s)
+		}
+	}
+}
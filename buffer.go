@@ -0,0 +1,136 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BufferDecl is the declaration of a storage buffer of Go values, as
+// specified by a directive within a gosl region:
+//
+//	//gosl: buffer <set> <binding> <Name> []<Type> [readonly]
+//
+// which is emitted as the buffer declaration of the -target, after the
+// element type, e.g., for //gosl: buffer 0 1 Data []DataStruct:
+//
+//	[[vk::binding(1, 0)]] RWStructuredBuffer<DataStruct> Data; // HLSL
+//	@group(0) @binding(1) var<storage, read_write> Data: array<DataStruct>; // WGSL
+//	layout(std430, set = 0, binding = 1) buffer DataBuffer { DataStruct Data[]; }; // GLSL
+//	#define GOSL_BUFFER_Data device DataStruct* Data [[buffer(1)]] // MSL
+//
+// so that the buffers are declared once for all targets, with the
+// layout checked for each target (see alignsl).  In MSL, buffers are
+// parameters of the kernel function, so the declaration is a macro
+// for the parameter, used as kernel void main0(GOSL_BUFFER_Data, ...),
+// in the device address space, or constant if readonly, and the set
+// must be 0, as the binding is the buffer index.
+type BufferDecl struct {
+
+	// descriptor set number
+	Set int
+
+	// binding number within the set
+	Binding int
+
+	// variable name of the buffer in the shader
+	Name string
+
+	// Go element type, e.g., DataStruct or float32
+	Type string
+
+	// the buffer is only read by the kernels
+	ReadOnly bool
+}
+
+// ParseBufferDirective parses the arguments of a buffer directive:
+// <set> <binding> <Name> []<Type> [readonly]
+func ParseBufferDirective(args string) (*BufferDecl, error) {
+	fs := strings.Fields(args)
+	if len(fs) < 4 || len(fs) > 5 || !strings.HasPrefix(fs[3], "[]") || (len(fs) == 5 && fs[4] != "readonly") {
+		return nil, fmt.Errorf("gosl: buffer directive must be of the form: //gosl: buffer <set> <binding> <Name> []<Type> [readonly] -- got: %q", args)
+	}
+	bd := &BufferDecl{Name: fs[2], Type: fs[3][2:], ReadOnly: len(fs) == 5}
+	var err error
+	if bd.Set, err = strconv.Atoi(fs[0]); err != nil || bd.Set < 0 {
+		return nil, fmt.Errorf("gosl: buffer %s: invalid set number: %s", bd.Name, fs[0])
+	}
+	if bd.Binding, err = strconv.Atoi(fs[1]); err != nil || bd.Binding < 0 {
+		return nil, fmt.Errorf("gosl: buffer %s: invalid binding number: %s", bd.Name, fs[1])
+	}
+	if bd.Type == "" {
+		return nil, fmt.Errorf("gosl: buffer %s: missing element type", bd.Name)
+	}
+	if *target == TargetMetal && bd.Set != 0 {
+		return nil, fmt.Errorf("gosl: buffer %s: the set must be 0 for -target %s, where the binding is the buffer index: %d", bd.Name, TargetMetal, bd.Set)
+	}
+	return bd, nil
+}
+
+// ElemType returns the name of the element type in the given target
+// language, translated by its replace table, e.g., float for float32.
+func (bd *BufferDecl) ElemType(target string) string {
+	typ := []byte(bd.Type)
+	rs := Replaces
+	switch target {
+	case TargetWGSL:
+		rs = WGSLReplaces
+	case TargetGLSL:
+		rs = GLSLReplaces
+	case TargetMetal:
+		rs = MetalReplaces
+	}
+	for _, r := range rs {
+		typ = bytes.ReplaceAll(typ, r.From, r.To)
+	}
+	return string(typ)
+}
+
+// Decl returns the declaration of the buffer in the given target language.
+func (bd *BufferDecl) Decl(target string) string {
+	et := bd.ElemType(target)
+	switch target {
+	case TargetWGSL:
+		access := "read_write"
+		if bd.ReadOnly {
+			access = "read"
+		}
+		return fmt.Sprintf("@group(%d) @binding(%d) var<storage, %s> %s: array<%s>;", bd.Set, bd.Binding, access, bd.Name, et)
+	case TargetGLSL:
+		ro := ""
+		if bd.ReadOnly {
+			ro = "readonly "
+		}
+		return fmt.Sprintf("layout(std430, set = %d, binding = %d) %sbuffer %sBuffer { %s %s[]; };", bd.Set, bd.Binding, ro, bd.Name, et, bd.Name)
+	case TargetMetal:
+		space := "device"
+		if bd.ReadOnly {
+			space = "constant"
+		}
+		return fmt.Sprintf("#define GOSL_BUFFER_%s %s %s* %s [[buffer(%d)]]", bd.Name, space, et, bd.Name, bd.Binding)
+	}
+	kind := "RWStructuredBuffer"
+	if bd.ReadOnly {
+		kind = "StructuredBuffer"
+	}
+	return fmt.Sprintf("[[vk::binding(%d, %d)]] %s<%s> %s;", bd.Binding, bd.Set, kind, et, bd.Name)
+}
+
+// Lines returns the declaration of the buffer for the -target, as a raw
+// code block of the given region, which is extracted with the others.
+// The block is separated by blank lines, so that it is not the doc
+// comment of a declaration, which goimports would reformat.
+func (bd *BufferDecl) Lines(region string) [][]byte {
+	return [][]byte{
+		nil,
+		[]byte("//gosl: " + *target + " " + region),
+		[]byte("// " + bd.Decl(*target)),
+		[]byte("//gosl: end " + region),
+		nil,
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CompareFile is the name of the Go file generated by -compare in the
+// directory of the CPU kernel functions.
+const CompareFile = "gosl_compare.go"
+
+// CompareElement is a CPU kernel function of a 1D kernel, which is
+// registered as an slcpu.Element by the CompareFile, for comparing the
+// kernel on the GPU with the function on the CPU.
+type CompareElement struct {
+
+	// CPU kernel function
+	Func *KernelFunc
+
+	// kernel of the function
+	Kernel *Kernel
+
+	// buffers that the kernel writes at the thread index,
+	// whose elements are compared
+	Compare []string
+}
+
+// CompareElements returns the CompareElements of the given CPU kernel
+// functions: those of the 1D kernels that match their kernel (see
+// KernelFunc.Check), and write to a buffer at the thread index, e.g.,
+// Neurons[idx.x], sorted by kernel name.  Other kernels of the functions
+// are reported, as they cannot be compared.
+func CompareElements(kfs []*KernelFunc) []*CompareElement {
+	var els []*CompareElement
+	for _, kf := range kfs {
+		k, ok := Kernels[kf.Kernel]
+		if !ok || len(kf.Check(k)) > 0 || slices.ContainsFunc(els, func(el *CompareElement) bool { return el.Kernel == k }) {
+			continue // reported by CheckKernelFuncs
+		}
+		if kf.Index != "uint32" || k.IndexDims != 1 {
+			fmt.Printf("gosl: -compare: CPU function %s is not compared with kernel %s: only 1D kernels are compared\n", kf.Name, k.Name)
+			continue
+		}
+		el := &CompareElement{Func: kf, Kernel: k}
+		for _, b := range k.Buffers {
+			if b.Indexed && b.Access&Write != 0 {
+				el.Compare = append(el.Compare, b.Name)
+			}
+		}
+		if len(el.Compare) == 0 {
+			fmt.Printf("gosl: -compare: CPU function %s is not compared with kernel %s: it does not write to a buffer at the thread index\n", kf.Name, k.Name)
+			continue
+		}
+		els = append(els, el)
+	}
+	sort.Slice(els, func(i, j int) bool { return els[i].Kernel.Name < els[j].Kernel.Name })
+	return els
+}
+
+// fileImports returns the import paths of the Go file with the given
+// name, by package name, for the qualified types of its functions.
+func fileImports(fn string) map[string]string {
+	f, err := parser.ParseFile(token.NewFileSet(), fn, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	imps := map[string]string{}
+	for _, is := range f.Imports {
+		p, _ := strconv.Unquote(is.Path.Value)
+		nm := path.Base(p)
+		if is.Name != nil {
+			nm = is.Name.Name
+		}
+		imps[nm] = p
+	}
+	return imps
+}
+
+// CompareGo returns the Go source of the CompareFile for the given
+// CompareElements, in the given package: a RegisterCompareElements
+// function that registers an slcpu.Element for each on a Comparer, which
+// calls the CPU function for one thread index on the buffers.
+func CompareGo(pkgName string, els []*CompareElement) ([]byte, error) {
+	imps := map[string]bool{"github.com/emer/gosl/v2/slgpu/slcpu": true}
+	var fb bytes.Buffer
+	for _, el := range els {
+		kf, k := el.Func, el.Kernel
+		fimps := fileImports(kf.Pos.Filename)
+		var bufs, args []string
+		for i, b := range k.Buffers {
+			bufs = append(bufs, strconv.Quote(b.Name))
+			args = append(args, kf.Params[i].Name)
+		}
+		var cmps []string
+		for _, nm := range el.Compare {
+			cmps = append(cmps, strconv.Quote(nm))
+		}
+		fmt.Fprintf(&fb, "\tc.RegisterElement(%q, &slcpu.Element{Threads: %d, Buffers: []string{%s}, Compare: []string{%s},\n", k.Name, k.Workgroup[0], strings.Join(bufs, ", "), strings.Join(cmps, ", "))
+		fb.WriteString("\t\tFunc: func(bufs *slcpu.Buffers, idx uint32) {\n")
+		for i, b := range k.Buffers {
+			par := kf.Params[i]
+			if pkg, _, ok := strings.Cut(par.Type, "."); ok {
+				if ip, ok := fimps[pkg]; ok {
+					imps[ip] = true
+				}
+			}
+			fmt.Fprintf(&fb, "\t\t\t%s := slcpu.Slice[%s](bufs, %q)\n", par.Name, par.Type, b.Name)
+		}
+		fmt.Fprintf(&fb, "\t\t\t%s(idx, %s)\n", kf.Name, strings.Join(args, ", "))
+		for i, b := range k.Buffers {
+			if b.Access&Write != 0 {
+				fmt.Fprintf(&fb, "\t\t\tslcpu.Store(bufs, %q, %s)\n", b.Name, kf.Params[i].Name)
+			}
+		}
+		fb.WriteString("\t\t}})\n")
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	ips := make([]string, 0, len(imps))
+	for ip := range imps {
+		ips = append(ips, strconv.Quote(ip))
+	}
+	sort.Strings(ips)
+	fmt.Fprintf(&b, "import (\n\t%s\n)\n\n", strings.Join(ips, "\n\t"))
+	b.WriteString("// RegisterCompareElements registers the CPU kernel functions of the\n// 1D kernels for one thread index on the given slcpu.Comparer, which\n// periodically compares the results of the kernels on the GPU with\n// those of the functions on the CPU.\n")
+	b.WriteString("func RegisterCompareElements(c *slcpu.Comparer) {\n")
+	b.Write(fb.Bytes())
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// GenCompare writes the CompareFile for the CPU kernel functions in the
+// given Go files, in their directory, if any can be compared (see
+// CompareElements).
+func GenCompare(files []string) error {
+	els := CompareElements(FindKernelFuncs(files))
+	if len(els) == 0 {
+		return nil
+	}
+	fn := filepath.Join(filepath.Dir(els[0].Func.Pos.Filename), CompareFile)
+	pnm, _ := DocPackageName(fn)
+	src, err := CompareGo(pnm, els)
+	if err != nil {
+		return err
+	}
+	return WriteGenerated(fn, "compare", src)
+}
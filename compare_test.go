@@ -0,0 +1,68 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	src := `[[vk::binding(0, 0)]] StructuredBuffer<Layer> Layers;
+[[vk::binding(1, 0)]] RWStructuredBuffer<Neuron> Neurons;
+[[vk::binding(2, 0)]] RWStructuredBuffer<uint2> Spikes;
+
+[numthreads(64, 1, 1)]
+void main(uint3 idx : SV_DispatchThreadID) {
+	Layers[Neurons[idx.x].LayIndex].CycleNeuron(Neurons[idx.x]);
+	Spikes[Neurons[idx.x].LayIndex].x += 1;
+}
+`
+	k := ParseKernel("cycle", []byte(src))
+	if !k.Buffers[1].Indexed || k.Buffers[0].Indexed || k.Buffers[2].Indexed {
+		t.Errorf("wrong indexed buffers: %v, %v, %v", k.Buffers[0].Indexed, k.Buffers[1].Indexed, k.Buffers[2].Indexed)
+	}
+	saved := Kernels
+	defer func() { Kernels = saved }()
+	Kernels = map[string]*Kernel{"cycle": k}
+
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "cpu.go")
+	os.WriteFile(fn, []byte(`package axon
+
+import "github.com/emer/gosl/v2/sltype"
+
+// CycleCPU is the CPU version of the cycle kernel
+//
+// gosl: cpukernel cycle
+func CycleCPU(idx uint32, Layers []Layer, Neurons []Neuron, Spikes []sltype.Uint2) {
+}
+`), 0644)
+	els := CompareElements(FindKernelFuncs([]string{fn}))
+	if len(els) != 1 || strings.Join(els[0].Compare, " ") != "Neurons" {
+		t.Fatalf("wrong compare elements: %+v", els)
+	}
+	gsrc, err := CompareGo("axon", els)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t\"github.com/emer/gosl/v2/slgpu/slcpu\"\n\t\"github.com/emer/gosl/v2/sltype\"\n",
+		"func RegisterCompareElements(c *slcpu.Comparer) {\n",
+		`c.RegisterElement("cycle", &slcpu.Element{Threads: 64, Buffers: []string{"Layers", "Neurons", "Spikes"}, Compare: []string{"Neurons"},`,
+		`Spikes := slcpu.Slice[sltype.Uint2](bufs, "Spikes")`,
+		"CycleCPU(idx, Layers, Neurons, Spikes)\n",
+		`slcpu.Store(bufs, "Neurons", Neurons)`,
+	} {
+		if !strings.Contains(string(gsrc), want) {
+			t.Errorf("missing %q in:\n%s", want, gsrc)
+		}
+	}
+	if strings.Contains(string(gsrc), `slcpu.Store(bufs, "Layers"`) {
+		t.Errorf("read-only buffer should not be stored:\n%s", gsrc)
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/emer/gosl/v2/alignsl"
+	"golang.org/x/tools/go/packages"
+)
+
+// InterfaceManifest records the interface between the CPU and the
+// generated GPU code: the layouts of the struct types in the buffers,
+// and the entry points and bindings of the kernels.  Any change in it
+// requires regenerating the .spv files and the buffer setup code, and
+// can break previously saved buffer contents (e.g., checkpoints), so
+// a stored manifest is compared with the current one by gosl compat.
+type InterfaceManifest struct {
+
+	// layouts of the struct types in the buffers, including
+	// nested struct types, sorted by name
+	Structs []*StructLayout
+
+	// the kernels, sorted by name
+	Kernels []*KernelInterface
+
+	// license of the generated files, from the config Header
+	License string `json:",omitempty"`
+}
+
+// StructLayout is the GPU layout of a struct type
+type StructLayout struct {
+	Name string
+
+	// size in bytes on the GPU
+	Size int64
+
+	// GPU fields, in order, excluding CPU-only fields
+	Fields []FieldLayout
+}
+
+// FieldLayout is the GPU layout of a struct field
+type FieldLayout struct {
+	Name   string
+	Type   string
+	Offset int64
+	Size   int64
+}
+
+// KernelInterface is the entry point and bindings of a kernel
+type KernelInterface struct {
+	Name      string
+	Entry     string
+	Workgroup [3]int
+	Bindings  []BindingInterface
+
+	// license of the kernel, if it differs from that of the manifest
+	License string `json:",omitempty"`
+
+	// status of the .spv file if it was not compiled because dxc
+	// was not found: stale (kept from a previous run) or missing
+	SPV string `json:",omitempty"`
+}
+
+// BindingInterface is a buffer binding of a kernel
+type BindingInterface struct {
+	Name    string
+	Set     int
+	Binding int
+	Kind    string
+	Type    string `json:",omitempty"`
+}
+
+// InterfaceStructs are the struct layouts of the buffer element types
+// of the Kernels in the current run, set by SetInterfaceStructs.
+var InterfaceStructs []*StructLayout
+
+// SetInterfaceStructs sets the InterfaceStructs from the buffer element
+// types of all Kernels that are struct types in the given package.
+func SetInterfaceStructs(pkg *packages.Package) {
+	sizes := alignsl.GPUSizes{Sizes: pkg.TypesSizes}
+	qual := types.RelativeTo(pkg.Types)
+	sm := map[string]*StructLayout{}
+	var add func(nm string, typ types.Type)
+	add = func(nm string, typ types.Type) {
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok || sm[nm] != nil || alignsl.VectorSize(st) > 0 {
+			return
+		}
+		sl := &StructLayout{Name: nm, Size: sizes.Sizeof(typ)}
+		sm[nm] = sl
+		flds := alignsl.GPUFields(st)
+		offs := sizes.Offsetsof(flds)
+		for i, f := range flds {
+			ft := f.Type()
+			sl.Fields = append(sl.Fields, FieldLayout{Name: f.Name(), Type: types.TypeString(ft, qual), Offset: offs[i], Size: sizes.Sizeof(ft)})
+			if at, ok := ft.(*types.Array); ok {
+				ft = at.Elem()
+			}
+			if nt, ok := ft.(*types.Named); ok {
+				add(types.TypeString(nt, qual), nt)
+			}
+		}
+	}
+	for _, k := range SortedKernels() {
+		for _, b := range k.Buffers {
+			if obj, ok := pkg.Types.Scope().Lookup(b.Type).(*types.TypeName); ok {
+				add(b.Type, obj.Type())
+			}
+		}
+	}
+	InterfaceStructs = make([]*StructLayout, 0, len(sm))
+	for _, sl := range sm {
+		InterfaceStructs = append(InterfaceStructs, sl)
+	}
+	sort.Slice(InterfaceStructs, func(i, j int) bool { return InterfaceStructs[i].Name < InterfaceStructs[j].Name })
+}
+
+// NewInterfaceManifest returns the InterfaceManifest of the Kernels
+// and InterfaceStructs in the current run.
+func NewInterfaceManifest() *InterfaceManifest {
+	im := &InterfaceManifest{Structs: InterfaceStructs, License: GoslConfig.Header.License}
+	for _, k := range SortedKernels() {
+		ki := &KernelInterface{Name: k.Name, Entry: k.Entry, Workgroup: k.Workgroup}
+		if lc := LicenseFor(k.Name); lc != im.License {
+			ki.License = lc
+		}
+		ki.SPV = SPVStatus[k.Name]
+		for _, b := range k.Buffers {
+			ki.Bindings = append(ki.Bindings, BindingInterface{Name: b.Name, Set: b.Set, Binding: b.Binding, Kind: b.Kind, Type: b.Type})
+		}
+		im.Kernels = append(im.Kernels, ki)
+	}
+	return im
+}
+
+// GenInterfaceManifest writes the InterfaceManifest of the current run
+// to the given JSON file.
+func GenInterfaceManifest(fn string) error {
+	b, err := json.MarshalIndent(NewInterfaceManifest(), "", "\t")
+	if err != nil {
+		return err
+	}
+	RecordOutput(fn)
+	return os.WriteFile(fn, append(b, '\n'), 0644)
+}
+
+// ReadInterfaceManifest reads an InterfaceManifest from the given JSON file
+func ReadInterfaceManifest(fn string) (*InterfaceManifest, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	im := &InterfaceManifest{}
+	if err := json.Unmarshal(b, im); err != nil {
+		return nil, fmt.Errorf("gosl compat: %s: %w", fn, err)
+	}
+	return im, nil
+}
+
+// CompatChange is a difference between two InterfaceManifests
+type CompatChange struct {
+
+	// kind of change: layout, binding or entry
+	Kind string
+
+	// whether the change breaks compatibility with the code and data
+	// of the previous version, or only adds to it
+	Breaking bool
+
+	// description of the change
+	Desc string
+}
+
+func (cc CompatChange) String() string {
+	if cc.Breaking {
+		return fmt.Sprintf("BREAKING %s: %s", cc.Kind, cc.Desc)
+	}
+	return fmt.Sprintf("%s: %s", cc.Kind, cc.Desc)
+}
+
+// CompareManifests returns the changes from the old to the new
+// InterfaceManifest.  Removing or changing a struct layout, kernel,
+// entry point, workgroup size or binding is breaking, while adding
+// a struct type or kernel is not.  Changes to padding (pad* fields)
+// are not breaking, so adding a field to a struct is only not breaking
+// if it replaces padding without changing the size or the other fields.
+func CompareManifests(old, cur *InterfaceManifest) []CompatChange {
+	var ccs []CompatChange
+	add := func(kind string, breaking bool, format string, a ...any) {
+		ccs = append(ccs, CompatChange{Kind: kind, Breaking: breaking, Desc: fmt.Sprintf(format, a...)})
+	}
+	cs := map[string]*StructLayout{}
+	for _, sl := range cur.Structs {
+		cs[sl.Name] = sl
+	}
+	olds := map[string]*StructLayout{}
+	for _, sl := range old.Structs {
+		olds[sl.Name] = sl
+		nl := cs[sl.Name]
+		if nl == nil {
+			add("layout", true, "struct %s removed", sl.Name)
+			continue
+		}
+		if nl.Size != sl.Size {
+			add("layout", true, "struct %s size changed from %d to %d bytes", sl.Name, sl.Size, nl.Size)
+		}
+		nf := map[string]FieldLayout{}
+		for _, f := range nl.Fields {
+			nf[f.Name] = f
+		}
+		of := map[string]bool{}
+		for _, f := range sl.Fields {
+			of[f.Name] = true
+			n, ok := nf[f.Name]
+			brk := !isPadField(f.Name)
+			switch {
+			case !ok:
+				add("layout", brk, "%s.%s removed", sl.Name, f.Name)
+			case n.Type != f.Type:
+				add("layout", brk, "%s.%s type changed from %s to %s", sl.Name, f.Name, f.Type, n.Type)
+			case n.Offset != f.Offset:
+				add("layout", brk, "%s.%s offset changed from %d to %d", sl.Name, f.Name, f.Offset, n.Offset)
+			}
+		}
+		for _, f := range nl.Fields {
+			if !of[f.Name] {
+				add("layout", nl.Size != sl.Size, "%s.%s %s added at offset %d", sl.Name, f.Name, f.Type, f.Offset)
+			}
+		}
+	}
+	for _, sl := range cur.Structs {
+		if olds[sl.Name] == nil {
+			add("layout", false, "struct %s added", sl.Name)
+		}
+	}
+
+	ck := map[string]*KernelInterface{}
+	for _, k := range cur.Kernels {
+		ck[k.Name] = k
+	}
+	oldk := map[string]bool{}
+	for _, k := range old.Kernels {
+		oldk[k.Name] = true
+		nk := ck[k.Name]
+		if nk == nil {
+			add("entry", true, "kernel %s removed", k.Name)
+			continue
+		}
+		if nk.Entry != k.Entry {
+			add("entry", true, "kernel %s entry point changed from %s to %s", k.Name, k.Entry, nk.Entry)
+		}
+		if nk.Workgroup != k.Workgroup {
+			add("entry", true, "kernel %s workgroup size changed from %v to %v", k.Name, k.Workgroup, nk.Workgroup)
+		}
+		nb := map[string]BindingInterface{}
+		for _, b := range nk.Bindings {
+			nb[b.Name] = b
+		}
+		ob := map[string]bool{}
+		for _, b := range k.Bindings {
+			ob[b.Name] = true
+			n, has := nb[b.Name]
+			switch {
+			case !has:
+				add("binding", true, "kernel %s buffer %s removed", k.Name, b.Name)
+			case n.Set != b.Set || n.Binding != b.Binding:
+				add("binding", true, "kernel %s buffer %s moved from set %d binding %d to set %d binding %d", k.Name, b.Name, b.Set, b.Binding, n.Set, n.Binding)
+			case n.Kind != b.Kind || n.Type != b.Type:
+				add("binding", true, "kernel %s buffer %s changed from %s<%s> to %s<%s>", k.Name, b.Name, b.Kind, b.Type, n.Kind, n.Type)
+			}
+		}
+		for _, b := range nk.Bindings {
+			if !ob[b.Name] {
+				add("binding", true, "kernel %s buffer %s added at set %d binding %d", k.Name, b.Name, b.Set, b.Binding)
+			}
+		}
+	}
+	for _, k := range cur.Kernels {
+		if !oldk[k.Name] {
+			add("entry", false, "kernel %s added", k.Name)
+		}
+	}
+	return ccs
+}
+
+// isPadField returns whether the given field name is for padding
+func isPadField(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), "pad")
+}
+
+func compatUsage() {
+	fmt.Fprintf(os.Stderr, "usage: gosl compat -against <manifest> [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+// compatMain runs the gosl compat command with the given arguments,
+// which generates the shaders for the paths in a temporary directory,
+// without compiling them, and compares their InterfaceManifest with
+// the one in the -against file, returning the exit code: 1 if there
+// are any breaking changes or errors.
+func compatMain(args []string) int {
+	against := flag.String("against", "", "InterfaceManifest JSON file to compare against, written by a previous run with -manifest")
+	flag.Usage = compatUsage
+	flag.CommandLine.Parse(args)
+	if *against == "" || flag.NArg() == 0 {
+		compatUsage()
+		return 2
+	}
+	old, err := ReadInterfaceManifest(*against)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	tmp, err := os.MkdirTemp(".", ".gosl-compat-")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer os.RemoveAll(tmp)
+	*outDir = tmp
+	*dxcPath = ToolNone
+	GoslArgs()
+	if err := ConfigArgs(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if err := Generate(flag.Args()); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	ccs := CompareManifests(old, NewInterfaceManifest())
+	nbreak := 0
+	fmt.Printf("\ngosl compat: changes from %s:\n", *against)
+	for _, cc := range ccs {
+		fmt.Printf("    %s\n", cc)
+		if cc.Breaking {
+			nbreak++
+		}
+	}
+	if len(ccs) == 0 {
+		fmt.Printf("    none\n")
+	}
+	if nbreak > 0 {
+		fmt.Printf("gosl compat: %d breaking changes: the .spv files and buffer setup code must be regenerated, and previously saved buffer contents may not load\n", nbreak)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompareManifests(t *testing.T) {
+	old := &InterfaceManifest{
+		Structs: []*StructLayout{{Name: "Params", Size: 16, Fields: []FieldLayout{{"Tau", "float32", 0, 4}, {"Dt", "float32", 4, 4}, {"pad", "float32", 8, 4}, {"pad1", "float32", 12, 4}}}},
+		Kernels: []*KernelInterface{{Name: "basic", Entry: "main", Workgroup: [3]int{64, 1, 1}, Bindings: []BindingInterface{{"Params", 0, 0, "RWStructuredBuffer", "Params"}, {"Data", 1, 0, "RWStructuredBuffer", "float"}}}},
+	}
+	cur := &InterfaceManifest{
+		Structs: []*StructLayout{{Name: "Params", Size: 16, Fields: []FieldLayout{{"Tau", "float32", 0, 4}, {"Dt", "float32", 4, 4}, {"Gain", "float32", 8, 4}, {"pad", "float32", 12, 4}}}},
+		Kernels: []*KernelInterface{
+			{Name: "basic", Entry: "main", Workgroup: [3]int{64, 1, 1}, Bindings: []BindingInterface{{"Params", 0, 0, "RWStructuredBuffer", "Params"}, {"Data", 2, 0, "RWStructuredBuffer", "float"}}},
+			{Name: "init", Entry: "main", Workgroup: [3]int{64, 1, 1}},
+		},
+	}
+	want := []string{
+		"layout: Params.pad offset changed from 8 to 12",
+		"layout: Params.pad1 removed",
+		"layout: Params.Gain float32 added at offset 8",
+		"BREAKING binding: kernel basic buffer Data moved from set 1 binding 0 to set 2 binding 0",
+		"entry: kernel init added",
+	}
+	ccs := CompareManifests(old, cur)
+	if len(ccs) != len(want) {
+		t.Fatalf("expected %d changes, got %d: %v", len(want), len(ccs), ccs)
+	}
+	for i, cc := range ccs {
+		if s := fmt.Sprint(cc); s != want[i] {
+			t.Errorf("change %d: expected %q, got %q", i, want[i], s)
+		}
+	}
+	if ccs := CompareManifests(cur, cur); len(ccs) != 0 {
+		t.Errorf("expected no changes, got %v", ccs)
+	}
+}
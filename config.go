@@ -0,0 +1,113 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// DefaultConfigFile is the config file used if -config is not set,
+// if it exists in the current directory.
+const DefaultConfigFile = "gosl.json"
+
+// Config is the per-project gosl config file, in JSON, e.g.:
+//
+//	{
+//		"Replace": {
+//			"Funcs": {"mymath.Exp": "exp", "github.com/me/mymath.Clamp": "clamp"},
+//			"Types": {"mymath.Vec4": "float4"}
+//		},
+//		"Names": {"Files": {"axon": "kernels/Axon"}}
+//	}
+type Config struct {
+
+	// replacement rules for the functions and types of other packages
+	// in the generated HLSL code
+	Replace ReplaceConfig
+
+	// commands run as custom passes at fixed points of the generation
+	Hooks HooksConfig
+
+	// license and attribution header of the generated files
+	Header HeaderConfig
+
+	// names of the output files of the regions and kernels, and of the
+	// entry points of the kernels
+	Names NamesConfig
+}
+
+// ReplaceConfig has replacement rules for the functions and types of
+// other packages, e.g., project-specific math wrapper packages, which
+// are otherwise printed as is in the HLSL code.  The keys are the
+// qualified names of the Go functions and types, as package name or
+// import path, dot, name, e.g., mymath.Exp or github.com/me/mymath.Exp,
+// and the values are the HLSL names that replace them, e.g., exp.
+// They are applied to the uses of these functions and types, as
+// resolved by the type checker, so that other uses of the same names
+// are not affected.
+type ReplaceConfig struct {
+
+	// functions, e.g., "mymath.Exp": "exp"
+	Funcs map[string]string
+
+	// types, e.g., "mymath.Vec4": "float4"
+	Types map[string]string
+}
+
+// GoslConfig is the config read by ConfigArgs
+var GoslConfig Config
+
+// ConfigArgs reads the -config file into GoslConfig, or the
+// DefaultConfigFile if it is not set and exists, and sets GoslHooks.
+func ConfigArgs() error {
+	fn := *configFile
+	if fn == "" {
+		fn = DefaultConfigFile
+	}
+	GoslConfig = Config{}
+	GoslHooks = Hooks{}
+	headerTemplate = nil
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		if *configFile == "" && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("gosl: -config: %w", err)
+	}
+	if err := ParseConfig(fn, b, &GoslConfig); err != nil {
+		return err
+	}
+	GoslHooks = GoslConfig.Hooks.Hooks()
+	headerTemplate, err = ParseHeaderTemplate(&GoslConfig.Header)
+	return err
+}
+
+// ParseConfig parses the given config file contents into the given Config,
+// and checks the replacement rules and names.
+func ParseConfig(fn string, b []byte, cfg *Config) error {
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("gosl: config file %s: %w", fn, err)
+	}
+	var errs []error
+	for kind, rs := range map[string]map[string]string{"Funcs": cfg.Replace.Funcs, "Types": cfg.Replace.Types} {
+		for from, to := range rs {
+			if i := strings.LastIndex(from, "."); i <= 0 || i == len(from)-1 || to == "" {
+				errs = append(errs, fmt.Errorf("gosl: config file %s: Replace.%s: %q: %q must be a package name or import path, dot, name, e.g., mymath.Exp, replaced with a non-empty name", fn, kind, from, to))
+			}
+		}
+	}
+	if _, err := ParseHeaderTemplate(&cfg.Header); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, CheckNames(fn, &cfg.Names)...)
+	return errors.Join(errs...)
+}
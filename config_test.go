@@ -0,0 +1,31 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	var cfg Config
+	err := ParseConfig("gosl.json", []byte(`{"Replace": {"Funcs": {"mymath.Exp": "exp", "github.com/me/mymath.Clamp": "clamp"}, "Types": {"mymath.Vec4": "float4"}}}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Replace.Funcs["github.com/me/mymath.Clamp"] != "clamp" || cfg.Replace.Types["mymath.Vec4"] != "float4" {
+		t.Errorf("wrong config: %+v", cfg)
+	}
+	for src, want := range map[string]string{
+		`{"Replace": {"Func": {"mymath.Exp": "exp"}}}`: "unknown field",
+		`{"Replace": {"Funcs": {"Exp": "exp"}}}`:       `"Exp"`,
+		`{"Replace": {"Types": {"mymath.Vec4": ""}}}`:  `"mymath.Vec4"`,
+	} {
+		err := ParseConfig("gosl.json", []byte(src), &Config{})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v does not contain %s", src, err, want)
+		}
+	}
+}
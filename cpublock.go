@@ -0,0 +1,171 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// CPUOnlyBlock is a block of statements within a function that is only
+// run on the CPU, e.g., diagnostics guarded by error returns, which is
+// removed from the GPU code:
+//
+//	//gosl: cpuonly
+//	if err := nt.Check(); err != nil {
+//		log.Println(err)
+//	}
+//	//gosl: end cpuonly
+type CPUOnlyBlock struct {
+
+	// line numbers of the start and end directives, starting at 1
+	Start, End int
+}
+
+// CheckCPUOnlyBlocks checks that the given CPUOnlyBlocks in the given
+// source file contain only complete statements within a function, and
+// cannot affect the GPU results: they must not assign to, increment or
+// take the address of any variables declared outside of the block, and
+// must not return or branch out of the block.  Calls are not checked,
+// so methods that modify their receivers must not be called on outside
+// variables.
+func CheckCPUOnlyBlocks(fn string, src []byte, blocks []CPUOnlyBlock) error {
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, fn, src, 0)
+	if err != nil {
+		return err
+	}
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+	var errs []error
+	for _, bl := range blocks {
+		bad := func(n ast.Node, format string, a ...any) {
+			errs = append(errs, fmt.Errorf("%s: //gosl: cpuonly block at line %d: %s", fset.Position(n.Pos()), bl.Start, fmt.Sprintf(format, a...)))
+		}
+		inside := func(n ast.Node) bool {
+			return line(n.Pos()) > bl.Start && line(n.End()) < bl.End
+		}
+		var fun *ast.FuncDecl
+		for _, d := range af.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil && line(fd.Body.Lbrace) < bl.Start && line(fd.Body.Rbrace) > bl.End {
+				fun = fd
+			}
+		}
+		if fun == nil {
+			errs = append(errs, fmt.Errorf("%s:%d: //gosl: cpuonly block must be within a function body", fn, bl.Start))
+			continue
+		}
+		var stmts []ast.Stmt
+		ast.Inspect(fun.Body, func(n ast.Node) bool {
+			st, ok := n.(ast.Stmt)
+			if !ok || n == fun.Body {
+				return true
+			}
+			if inside(st) {
+				stmts = append(stmts, st)
+				return false
+			}
+			if line(st.End()) < bl.Start || line(st.Pos()) > bl.End {
+				return false
+			}
+			if _, isBlock := st.(*ast.BlockStmt); !isBlock && (line(st.Pos()) >= bl.Start || line(st.End()) <= bl.End) {
+				bad(st, "must only contain complete statements")
+				return false
+			}
+			return true
+		})
+		outside := func(id *ast.Ident) bool {
+			if id.Name == "_" {
+				return false
+			}
+			if id.Obj == nil {
+				return true // package-level
+			}
+			dn, ok := id.Obj.Decl.(ast.Node)
+			return !ok || !inside(dn)
+		}
+		for _, st := range stmts {
+			var stack []ast.Node
+			ast.Inspect(st, func(n ast.Node) bool {
+				if n == nil {
+					stack = stack[:len(stack)-1]
+					return true
+				}
+				switch x := n.(type) {
+				case *ast.AssignStmt:
+					if x.Tok == token.DEFINE {
+						for _, lh := range x.Lhs {
+							if id, ok := lh.(*ast.Ident); ok && id.Obj != nil && outside(id) {
+								bad(id, "assigns to %s, which is declared outside of the block", id.Name)
+							}
+						}
+						break
+					}
+					for _, lh := range x.Lhs {
+						if id := rootIdent(lh); id != nil && outside(id) {
+							bad(id, "assigns to %s, which is declared outside of the block", id.Name)
+						}
+					}
+				case *ast.IncDecStmt:
+					if id := rootIdent(x.X); id != nil && outside(id) {
+						bad(id, "modifies %s, which is declared outside of the block", id.Name)
+					}
+				case *ast.UnaryExpr:
+					if id := rootIdent(x.X); x.Op == token.AND && id != nil && outside(id) {
+						bad(id, "takes the address of %s, which is declared outside of the block", id.Name)
+					}
+				case *ast.ReturnStmt:
+					bad(x, "must not return")
+				case *ast.BranchStmt:
+					if x.Label != nil || x.Tok == token.GOTO || x.Tok == token.FALLTHROUGH || !branchTarget(stack, x.Tok) {
+						bad(x, "must not %s out of the block", x.Tok)
+					}
+				}
+				stack = append(stack, n)
+				return true
+			})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rootIdent returns the variable at the root of the given expression,
+// e.g., nt for nt.Acts[i].Ge, or nil if none.
+func rootIdent(ex ast.Expr) *ast.Ident {
+	for {
+		switch x := ex.(type) {
+		case *ast.Ident:
+			return x
+		case *ast.SelectorExpr:
+			ex = x.X
+		case *ast.IndexExpr:
+			ex = x.X
+		case *ast.StarExpr:
+			ex = x.X
+		case *ast.ParenExpr:
+			ex = x.X
+		default:
+			return nil
+		}
+	}
+}
+
+// branchTarget returns whether the given stack of nodes has a target
+// for a break or continue statement.
+func branchTarget(stack []ast.Node, tok token.Token) bool {
+	for _, n := range stack {
+		switch n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			return true
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			if tok == token.BREAK {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckCPUOnlyBlocks(t *testing.T) {
+	src := `package test
+
+func F(x *float32, n int) float32 {
+	s := *x
+	//gosl: cpuonly
+	for i := range n {
+		if i > 2 {
+			break
+		}
+		s += 1
+	}
+	//gosl: end cpuonly
+	//gosl: cpuonly
+	if err := check(s); err != nil {
+		msg := err.Error()
+		_ = msg
+		return 0
+	}
+	//gosl: end cpuonly
+	if s > 0 {
+		//gosl: cpuonly
+		*x = 0
+		p := &n
+		_ = p
+	}
+	//gosl: end cpuonly
+	return s
+}
+`
+	err := CheckCPUOnlyBlocks("f.go", []byte(src), []CPUOnlyBlock{{5, 12}, {13, 19}, {21, 26}})
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	want := []string{
+		"f.go:10:3: //gosl: cpuonly block at line 5: assigns to s",
+		"f.go:17:3: //gosl: cpuonly block at line 13: must not return",
+		"f.go:20:2: //gosl: cpuonly block at line 21: must only contain complete statements",
+	}
+	errs := strings.Split(err.Error(), "\n")
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got:\n%s", len(want), err)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(errs[i], w) {
+			t.Errorf("error %d: expected %q, got %q", i, w, errs[i])
+		}
+	}
+}
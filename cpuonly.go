@@ -0,0 +1,176 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/emer/gosl/v2/alignsl"
+	"golang.org/x/tools/go/packages"
+)
+
+// GPUStruct is a struct type with CPU-only fields (GUI strings, maps,
+// book-keeping) that are excluded from the GPU struct with a
+// `gosl:"-"` struct tag, directly or in nested struct types:
+//
+//	type Layer struct {
+//		Gain float32
+//		Name string `gosl:"-"`
+//
+// The HLSL struct omits these fields, with a note in their place, so
+// the Go layout is different, and gosl generates a Go mirror type with
+// only the GPU fields, e.g., LayerGPU, with the same layout as the HLSL
+// struct, and Set and Get methods to convert from and to the Go type,
+// which is copied to the GPU buffers instead.
+type GPUStruct struct {
+
+	// name of the struct type, e.g., Layer
+	Type string
+
+	// struct type
+	Struct *types.Struct
+}
+
+// FindGPUStructs returns the struct types in the given package that
+// have fields excluded from the GPU struct, sorted by name.
+func FindGPUStructs(pkg *packages.Package) []*GPUStruct {
+	var gss []*GPUStruct
+	scope := pkg.Types.Scope()
+	for _, nm := range scope.Names() {
+		tn, ok := scope.Lookup(nm).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		st, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok || !alignsl.HasExcluded(st) {
+			continue
+		}
+		gss = append(gss, &GPUStruct{Type: nm, Struct: st})
+	}
+	sort.Slice(gss, func(i, j int) bool { return gss[i].Type < gss[j].Type })
+	return gss
+}
+
+// Name returns the base name of the generated file, e.g., layergpu
+func (gs *GPUStruct) Name() string {
+	return strings.ToLower(gs.Type) + "gpu"
+}
+
+// Mirror returns the name of the mirror type, e.g., LayerGPU
+func (gs *GPUStruct) Mirror() string {
+	return gs.Type + "GPU"
+}
+
+// gpuMirrorType returns the name of the mirror type of the given type
+// if it is a struct type with excluded fields, and whether it is.
+func gpuMirrorType(typ types.Type, qual types.Qualifier) (string, bool, error) {
+	st, ok := typ.Underlying().(*types.Struct)
+	if !ok || !alignsl.HasExcluded(st) {
+		return "", false, nil
+	}
+	nt, ok := typ.(*types.Named)
+	if !ok {
+		return "", true, fmt.Errorf("struct types with CPU-only fields must be named: %s", typ)
+	}
+	return types.TypeString(nt, qual) + "GPU", true, nil
+}
+
+// Go returns the Go source with the mirror type, in given package,
+// for the type in the given types package.
+func (gs *GPUStruct) Go(pkg *types.Package, pkgName string) ([]byte, error) {
+	imps := map[string]string{}
+	qual := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		imps[p.Path()] = p.Name()
+		return p.Name()
+	}
+	mnm := gs.Mirror()
+	var flds, set, get bytes.Buffer
+	st := gs.Struct
+	for i := range st.NumFields() {
+		f := st.Field(i)
+		if alignsl.Excluded(st.Tag(i)) {
+			fmt.Fprintf(&flds, "\t// %s: CPU-only, excluded by gosl:\"-\"\n", f.Name())
+			continue
+		}
+		ft := types.TypeString(f.Type(), qual)
+		if mt, is, err := gpuMirrorType(f.Type(), qual); err != nil {
+			return nil, fmt.Errorf("gosl: %s.%s: %w", gs.Type, f.Name(), err)
+		} else if is {
+			ft = mt
+			fmt.Fprintf(&set, "\tg.%s.Set(&v.%s)\n", f.Name(), f.Name())
+			fmt.Fprintf(&get, "\tg.%s.Get(&v.%s)\n", f.Name(), f.Name())
+		} else if at, ok := f.Type().Underlying().(*types.Array); ok {
+			mt, is, err := gpuMirrorType(at.Elem(), qual)
+			if err != nil {
+				return nil, fmt.Errorf("gosl: %s.%s: %w", gs.Type, f.Name(), err)
+			}
+			if is {
+				ft = fmt.Sprintf("[%d]%s", at.Len(), mt)
+				fmt.Fprintf(&set, "\tfor i := range v.%s {\n\t\tg.%s[i].Set(&v.%s[i])\n\t}\n", f.Name(), f.Name(), f.Name())
+				fmt.Fprintf(&get, "\tfor i := range v.%s {\n\t\tg.%s[i].Get(&v.%s[i])\n\t}\n", f.Name(), f.Name(), f.Name())
+			} else {
+				fmt.Fprintf(&set, "\tg.%s = v.%s\n", f.Name(), f.Name())
+				fmt.Fprintf(&get, "\tv.%s = g.%s\n", f.Name(), f.Name())
+			}
+		} else {
+			fmt.Fprintf(&set, "\tg.%s = v.%s\n", f.Name(), f.Name())
+			fmt.Fprintf(&get, "\tv.%s = g.%s\n", f.Name(), f.Name())
+		}
+		fmt.Fprintf(&flds, "\t%s %s\n", f.Name(), ft)
+	}
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	if len(imps) > 0 {
+		paths := make([]string, 0, len(imps))
+		for p := range imps {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		b.WriteString("import (\n")
+		for _, p := range paths {
+			fmt.Fprintf(&b, "\t%q\n", p)
+		}
+		b.WriteString(")\n\n")
+	}
+	fmt.Fprintf(&b, "// %s is %s without its CPU-only fields, with the layout\n// of the HLSL struct, to copy to the GPU buffers instead of %s\ntype %s struct {\n", mnm, gs.Type, gs.Type, mnm)
+	b.Write(flds.Bytes())
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "// Set sets the fields from the given %s\nfunc (g *%s) Set(v *%s) {\n", gs.Type, mnm, gs.Type)
+	b.Write(set.Bytes())
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "// Get sets the GPU fields of the given %s,\n// leaving its CPU-only fields as they are\nfunc (g *%s) Get(v *%s) {\n", gs.Type, mnm, gs.Type)
+	b.Write(get.Bytes())
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// GenGPUStructs generates the mirror types for all of the struct types
+// in the given package with CPU-only fields: <type>gpu.go in the current
+// directory, reporting the Go and GPU sizes, which differ intentionally.
+func GenGPUStructs(pkg *packages.Package) error {
+	sizes := alignsl.GPUSizes{Sizes: pkg.TypesSizes}
+	for _, gs := range FindGPUStructs(pkg) {
+		gofn := gs.Name() + ".go"
+		pnm, _ := DocPackageName(gofn)
+		src, err := gs.Go(pkg.Types, pnm)
+		if err != nil {
+			return err
+		}
+		if err := WriteGenerated(gofn, gs.Name(), src); err != nil {
+			return err
+		}
+		fmt.Printf("gosl: %s has CPU-only fields: Go size: %d, GPU size: %d bytes: use %s in GPU buffers\n", gs.Type, pkg.TypesSizes.Sizeof(gs.Struct), sizes.Sizeof(gs.Struct), gs.Mirror())
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/emer/gosl/v2/alignsl"
+)
+
+func TestGPUStructs(t *testing.T) {
+	src := "package main\n\ntype Pos struct {\n\tX, Y, Z, W float32\n\tLabel string `gosl:\"-\"`\n}\n\ntype Layer struct {\n\tGain float32\n\tName string `gosl:\"-\"`\n\tBias, Off, pad float32\n\tPs [2]Pos\n}\n\ntype Data struct {\n\tX float32\n}\n"
+	pkg := testPackage(t, "layer.go", src)
+	gss := FindGPUStructs(pkg)
+	if len(gss) != 2 || gss[0].Type != "Layer" || gss[1].Type != "Pos" {
+		t.Fatalf("wrong GPU structs: %+v", gss)
+	}
+	sizes := alignsl.GPUSizes{Sizes: types.SizesFor("gc", "amd64")}
+	if sz := sizes.Sizeof(gss[0].Struct); sz != 48 {
+		t.Errorf("GPU size of Layer: %d, want 48", sz)
+	}
+	gsrc, err := gss[0].Go(pkg.Types, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"type LayerGPU struct {\n\tGain float32\n\t// Name: CPU-only", "Ps   [2]PosGPU", "g.Ps[i].Set(&v.Ps[i])", "v.Bias = g.Bias"} {
+		if !strings.Contains(string(gsrc), want) {
+			t.Errorf("missing %q in:\n%s", want, gsrc)
+		}
+	}
+	if strings.Contains(string(gsrc), "Name string") {
+		t.Errorf("CPU-only field in:\n%s", gsrc)
+	}
+}
@@ -12,10 +12,14 @@
 gosl translates Go source code into HLSL compatible shader code.
 use //gosl: start <filename> and //gosl: end <filename> to
 bracket code that should be copied into shaders/<filename>.hlsl
-use //gosl: main <filename> instead of start for shader code that is
+use //gosl: hlsl <filename> instead of start for shader code that is
 commented out in the .go file, which will be copied into the filename
 and uncommented.
 
+gosl fix [path ...] rewrites the Go files in the paths from the
+conventions of gosl v1 to the current ones: directives, import paths,
+and the mat32 package, which is now math32.
+
 pass filenames or directory names for files to process.
 
 Usage:
@@ -25,6 +29,8 @@ Usage:
 The flags are:
 
 	-out string
-	  	output directory for shader code, relative to where gosl is invoked (default "shaders")
+	  	output directory for shader code, relative to the package directory: where gosl is run, e.g., by go generate, or -chdir (default "shaders")
+	-chdir string
+	  	if set, change to this package directory before doing anything else, as with go -C
 */
 package main
@@ -17,6 +17,7 @@ import (
 //  act.go contains the activation params and functions for axon
 
 //gosl: start axon
+//gosl: uses chans minmax
 
 //////////////////////////////////////////////////////////////////////////////////////
 //  SpikeParams
@@ -129,7 +129,7 @@ func (ap *AKParams) Gak(m, h float32) float32 {
 //////////////////////////////////////////////////////////////////////
 //  Simplified AK
 
-//gosl: start axon
+//gosl: start chans
 
 // AKsParams provides a highly simplified stateless A-type K channel
 // that only has the voltage-gated activation (M) dynamic with a cutoff
@@ -186,4 +186,4 @@ func (ap *AKsParams) Gak(v float32) float32 {
 	return ap.Gbar * ap.MFromVnorm(v)
 }
 
-//gosl: end axon
+//gosl: end chans
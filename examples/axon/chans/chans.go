@@ -10,7 +10,8 @@ Includes excitatory, leak, inhibition, and dynamic potassium channels.
 */
 package chans
 
-//gosl: start axon
+//gosl: start chans
+//gosl: uses fastexp
 
 // Chans are ion channels used in computing point-neuron activation function
 type Chans struct {
@@ -40,7 +41,7 @@ func VFromBio(vm float32) float32 {
 	return (vm + 100) / 100
 }
 
-//gosl: end axon
+//gosl: end chans
 
 // note: self type not avail in hlsl:
 
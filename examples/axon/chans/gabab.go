@@ -8,7 +8,7 @@ import (
 	"cogentcore.org/core/math32"
 )
 
-//gosl: start axon
+//gosl: start chans
 
 // GABABParams control the GABAB dynamics in PFC Maint neurons,
 // based on Brunel & Wang (2001) parameters.
@@ -101,4 +101,4 @@ func (gp *GABABParams) GgabaB(gabaB, vm float32) float32 {
 	return gp.Gbar * gp.GFromV(vm) * (gabaB + gp.Gbase)
 }
 
-//gosl: end axon
+//gosl: end chans
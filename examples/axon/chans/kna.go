@@ -6,7 +6,7 @@ package chans
 
 import "github.com/emer/gosl/v2/slbool"
 
-//gosl: start axon
+//gosl: start chans
 
 // KNaParams implements sodium (Na) gated potassium (K) currents
 // that drive adaptation (accommodation) in neural firing.
@@ -98,4 +98,4 @@ func (ka *KNaMedSlow) GcFromSpike(gKNaM, gKNaS *float32, spike bool) {
 	ka.Slow.GcFromSpike(gKNaS, spike)
 }
 
-//gosl: end axon
+//gosl: end chans
@@ -6,7 +6,7 @@ package chans
 
 import "cogentcore.org/core/math32"
 
-//gosl: start axon
+//gosl: start chans
 
 // MahpParams implements an M-type medium afterhyperpolarizing (mAHP) channel,
 // where m also stands for muscarinic due to the ACh inactivation of this channel.
@@ -96,4 +96,4 @@ func (mp *MahpParams) GmAHP(n float32) float32 {
 	return mp.Tadj * mp.Gbar * n
 }
 
-//gosl: end axon
+//gosl: end chans
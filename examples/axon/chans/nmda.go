@@ -6,7 +6,7 @@ package chans
 
 import "cogentcore.org/core/math32"
 
-//gosl: start axon
+//gosl: start chans
 
 // NMDAParams control the NMDA dynamics, based on Jahr & Stevens (1990) equations
 // which are widely used in models, from Brunel & Wang (2001) to Sanders et al. (2013).
@@ -115,4 +115,4 @@ func (np *NMDAParams) SnmdaFromSpike(spike float32, snmdaO, snmdaI *float32) {
 	}
 }
 
-//gosl: end axon
+//gosl: end chans
@@ -6,7 +6,7 @@ package chans
 
 import "cogentcore.org/core/math32"
 
-//gosl: start axon
+//gosl: start chans
 
 // SahpParams implements a slow afterhyperpolarizing (sAHP) channel,
 // It has a slowly accumulating calcium value, aggregated at the
@@ -99,4 +99,4 @@ func (mp *SahpParams) GsAHP(n float32) float32 {
 	return mp.Gbar * n
 }
 
-//gosl: end axon
+//gosl: end chans
@@ -8,7 +8,7 @@ import (
 	"cogentcore.org/core/math32"
 )
 
-//gosl: start axon
+//gosl: start chans
 
 // VGCCParams control the standard L-type Ca channel
 type VGCCParams struct {
@@ -87,4 +87,4 @@ func (np *VGCCParams) CaFromG(v, g, ca float32) float32 {
 	return -vbio * np.Ca * g
 }
 
-//gosl: end axon
+//gosl: end chans
@@ -0,0 +1,49 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/gosl/v2/slfixed"
+	"github.com/emer/gosl/v2/sltype"
+)
+
+// CPU versions of the kernels, which gosl checks against the buffers
+// of the kernels.  The time is incremented after all of the neurons
+// on the CPU, instead of by the first neuron.
+
+// CycleCPU is the CPU version of the cycle kernel
+//
+// gosl: cpukernel cycle
+func CycleCPU(idx uint32, Layers []Layer, time []Time, Neurons []Neuron, GeRaws []int32) {
+	nrn := &Neurons[idx]
+	nrn.GeRaw = slfixed.ToFloat(GeRaws[idx])
+	GeRaws[idx] = 0
+	Layers[nrn.LayIndex].CycleNeuron(int(idx), nrn, &time[0])
+}
+
+// SendSpikeCPU is the CPU version of the sendspike kernel,
+// which can be run in parallel, as the additions are atomic.
+//
+// gosl: cpukernel sendspike
+func SendSpikeCPU(idx uint32, Neurons []Neuron, Prjns []Prjn, SendSyns []sltype.Uint2, Synapses []Synapse, GeRaws []int32) {
+	if Neurons[idx].Spike == 0 {
+		return
+	}
+	sr := SendSyns[idx]
+	for si := sr.X; si < sr.X+sr.Y; si++ {
+		sy := &Synapses[si]
+		slfixed.AtomicAdd(&GeRaws[sy.RecvIndex], Prjns[sy.PrjnIndex].SpikeG(sy))
+	}
+}
+
+// SynLearnCPU is the CPU version of the synlearn kernel
+//
+// gosl: cpukernel synlearn
+func SynLearnCPU(idx uint32, Neurons []Neuron, Prjns []Prjn, Synapses []Synapse) {
+	sy := &Synapses[idx]
+	pj := &Prjns[sy.PrjnIndex]
+	pj.DWt(sy, &Neurons[sy.SendIndex], &Neurons[sy.RecvIndex])
+	pj.WtFromDWt(sy)
+}
@@ -4,7 +4,7 @@
 
 package kinase
 
-//gosl: start axon
+//gosl: start kinase
 
 // CaDtParams has rate constants for integrating Ca calcium
 // at different time scales, including final CaP = CaMKII and CaD = DAPK1
@@ -123,4 +123,4 @@ func (kp *CaParams) CurCa(ctime, utime int32, caM, caP, caD *float32) {
 	}
 }
 
-//gosl: end axon
+//gosl: end kinase
@@ -54,9 +54,10 @@ func (ly *Layer) GiInteg(ni int, nrn *Neuron, ctime *Time) {
 }
 
 // GFromSpikeRaw integrates G*Raw and G*Syn values for given neuron
-// from the Prjn-level GSyn integrated values.
+// from the GeRaw spike input received from the sending neurons in the
+// Prjns, which is set from the GeRaws accumulated by SendSpike.
 func (ly *Layer) GFromSpikeRaw(ni int, nrn *Neuron, ctime *Time) {
-	nrn.GeRaw = 0.4
+	nrn.GeRaw += nrn.GeBase
 	nrn.GiRaw = 0
 	nrn.GeSyn = nrn.GeBase
 	nrn.GiSyn = nrn.GiBase
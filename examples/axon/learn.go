@@ -16,6 +16,7 @@ import (
 //  learn.go contains the learning params and functions for axon
 
 //gosl: start axon
+//gosl: uses chans kinase minmax
 
 // CaLrnParams parameterizes the neuron-level calcium signals driving learning:
 // CaLrn = NMDA + VGCC Ca sources, where VGCC can be simulated from spiking or
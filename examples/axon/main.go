@@ -7,12 +7,12 @@ package main
 import (
 	"fmt"
 	"runtime"
-	"unsafe"
 
 	"log/slog"
 
 	"cogentcore.org/core/math32"
-	"cogentcore.org/core/vgpu"
+	"github.com/emer/gosl/v2/goslrun"
+	_ "github.com/emer/gosl/v2/slgpu/slvgpu"
 	"github.com/emer/gosl/v2/sltype"
 	"github.com/emer/gosl/v2/threading"
 	"github.com/emer/gosl/v2/timer"
@@ -23,7 +23,7 @@ const DiffTol = 1.0e-3
 
 // note: standard one to use is plain "gosl" which should be go install'd
 
-//go:generate ../../gosl -exclude=Update,UpdateParams,Defaults -keep cogentcore.org/core/math32/fastexp.go minmax chans/chans.go chans kinase time.go neuron.go act.go learn.go layer.go axon.hlsl
+//go:generate ../../gosl -exclude=Update,UpdateParams,Defaults -keep cogentcore.org/core/math32/fastexp.go minmax chans/chans.go chans kinase time.go neuron.go act.go learn.go layer.go prjn.go cpu.go cycle.hlsl sendspike.hlsl synlearn.hlsl
 
 func init() {
 	// must lock main thread for gpu!  this also means that vulkan must be used
@@ -32,16 +32,6 @@ func init() {
 }
 
 func main() {
-	if vgpu.InitNoDisplay() != nil {
-		return
-	}
-
-	gp := vgpu.NewComputeGPU()
-	// vgpu.Debug = true
-	gp.Config("axon")
-
-	// gp.PropsString(true) // print
-
 	// n := 64 // debugging
 	n := 100000 // 1,000,000 = 80x even with range checking
 	// 100,000 = ~60x "
@@ -50,10 +40,10 @@ func main() {
 	gpuThreads := 64
 	cpuThreads := 10
 	nInt := int(math32.IntMultiple(float32(n), float32(gpuThreads)))
-	n = nInt                  // enforce optimal n's -- otherwise requires range checking
-	nGps := nInt / gpuThreads // dispatch n
+	n = nInt // enforce optimal n's -- otherwise requires range checking
 
 	maxCycles := 200 // 70x speedup doing 20000
+	nSendSyns := 10  // synapses per sending neuron
 	// fmt.Printf("n: %d   cycles: %d\n", n, maxCycles)
 
 	nLays := 2
@@ -64,8 +54,8 @@ func main() {
 		ly.Defaults()
 	}
 
-	time := NewTime()
-	time.Defaults()
+	times := []Time{*NewTime()}
+	time := &times[0]
 
 	neur1 := make([]Neuron, n)
 	for i := range neur1 {
@@ -88,15 +78,13 @@ func main() {
 		nrn.GeBase = 0.4
 	}
 
-	// for testing alignment and buffer type isues
-	idxs := make([]sltype.Uint2, n)
-	for i := range idxs {
-		iv := &idxs[i]
-		iv.X = uint32(i)
-		iv.Y = uint32(i)
-		// iv.Z = uint32(i)
-		// iv.W = uint32(i)
-	}
+	prjns := []Prjn{{SendLay: 0, RecvLay: 1}}
+	prjns[0].Defaults()
+	sendSyns, syns1 := ConnectPrjn(0, nfirst+1, n, nSendSyns)
+	syns2 := make([]Synapse, len(syns1))
+	copy(syns2, syns1)
+	geRaws1 := make([]int32, n)
+	geRaws2 := make([]int32, n)
 
 	cpuTmr := timer.Time{}
 	cpuTmr.Start()
@@ -104,66 +92,54 @@ func main() {
 	for cy := 0; cy < maxCycles; cy++ {
 		threading.ParallelRun(func(st, ed int) {
 			for ni := st; ni < ed; ni++ {
-				nrn := &neur1[ni]
-				ly := &lays[nrn.LayIndex]
-				ly.CycleNeuron(ni, nrn, time)
+				SendSpikeCPU(uint32(ni), neur1, prjns, sendSyns, syns1, geRaws1)
+			}
+		}, len(neur1), cpuThreads)
+		threading.ParallelRun(func(st, ed int) {
+			for ni := st; ni < ed; ni++ {
+				CycleCPU(uint32(ni), lays, times, neur1, geRaws1)
 			}
 		}, len(neur1), cpuThreads)
 		ly := &lays[0]
 		ly.CycleTimeInc(time)
 		// fmt.Printf("%d\ttime.RandCtr: %v\n", cy, time.RandCtr.Uint2())
 	}
+	threading.ParallelRun(func(st, ed int) {
+		for si := st; si < ed; si++ {
+			SynLearnCPU(uint32(si), neur1, prjns, syns1)
+		}
+	}, len(syns1), cpuThreads)
 
 	// cpuTmr.Stop()
 
 	time.Reset()
 
-	sy := gp.NewComputeSystem("axon")
-	pl := sy.NewPipeline("axon")
-	pl.AddShaderFile("axon", vgpu.ComputeShader, "shaders/axon.spv")
-
-	vars := sy.Vars()
-	setl := vars.AddSet()
-	sett := vars.AddSet()
-	setn := vars.AddSet()
-	// seti := vars.AddSet()
+	run := goslrun.New("axon")
+	run.Threads = gpuThreads
 
 	// important: Uniform appears to have much higher alignment restrictions
 	// compared to Storage -- Layer works but Uint4 does not.
 	// Storage however *does* appear to work with only 32 or 16 byte values!
 	// all of this is on mac
-
-	layv := setl.AddStruct("Layers", int(unsafe.Sizeof(Layer{})), nLays, vgpu.Storage, vgpu.ComputeShader)
-	timev := sett.AddStruct("Time", int(unsafe.Sizeof(Time{})), 1, vgpu.Storage, vgpu.ComputeShader)
-	neurv := setn.AddStruct("Neurons", int(unsafe.Sizeof(Neuron{})), n, vgpu.Storage, vgpu.ComputeShader)
-	// var ui sltype.Uint2
-	// idxv := seti.AddStruct("Indexes", int(unsafe.Sizeof(ui)), n, vgpu.Storage, vgpu.ComputeShader)
-
-	setl.ConfigValues(1) // one val per var
-	sett.ConfigValues(1) // one val per var
-	setn.ConfigValues(1) // one val per var
-	// seti.ConfigValues(1) // one val per var
-	sy.Config() // configures vars, allocates vals, configs pipelines..
+	// use a //gosl: uniform directive on types used in Uniform buffers,
+	// and copy the generated padded Uniform mirror type instead.
+
+	run.Buffer("Layers", lays)
+	run.Buffer("Time", time)
+	run.Buffer("Neurons", neur2)
+	run.Buffer("Prjns", prjns)
+	run.Buffer("SendSyns", sendSyns)
+	run.Buffer("Synapses", syns2)
+	run.Buffer("GeRaws", geRaws2)
+	sendKern := run.Kernel("sendspike.spv")
+	cycleKern := run.Kernel("cycle.spv")
+	learnKern := run.Kernel("synlearn.spv")
 
 	// this copy is pretty fast -- most of time is below
-	lvl, _ := layv.Values.ValueByIndexTry(0)
-	lvl.CopyFromBytes(unsafe.Pointer(&lays[0]))
-	tvl, _ := timev.Values.ValueByIndexTry(0)
-	tvl.CopyFromBytes(unsafe.Pointer(time))
-	nvl, _ := neurv.Values.ValueByIndexTry(0)
-	nvl.CopyFromBytes(unsafe.Pointer(&neur2[0]))
-	// ivl, _ := idxv.Values.ValueByIndexTry(0)
-	// ivl.CopyFromBytes(unsafe.Pointer(&idxs[0]))
-
-	sy.Mem.SyncToGPU()
-
-	vars.BindDynamicValueIndex(0, "Layers", 0)
-	vars.BindDynamicValueIndex(1, "Time", 0)
-	vars.BindDynamicValueIndex(2, "Neurons", 0)
-	// vars.BindDynamicValueIndex(3, "Indexes", 0)
-
-	cmd := sy.ComputeCmdBuff()
-	sy.CmdResetBindVars(cmd, 0)
+	if err := run.Config(); err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	gpuFullTmr := timer.Time{}
 	gpuFullTmr.Start()
@@ -171,23 +147,28 @@ func main() {
 	gpuTmr := timer.Time{}
 	gpuTmr.Start()
 
-	// note: it is 2x faster to run the for loop within the shader entirely
-	pl.ComputeDispatch(cmd, nGps, 1, 1)
-	sy.ComputeCmdEnd(cmd)
-	sy.ComputeSubmitWait(cmd) // technically should wait, but results are same..
+	// each kernel must complete before the next one reads its results
+	for cy := 0; cy < maxCycles; cy++ {
+		sendKern.Dispatch(n)
+		run.Runtime.Barrier()
+		cycleKern.Dispatch(n)
+		run.Runtime.Barrier()
+	}
+	learnKern.Dispatch(len(syns2))
+	run.Runtime.Wait()
 
 	gpuTmr.Stop()
 
-	sy.Mem.SyncValueIndexFromGPU(2, "Neurons", 0) // this is about same as SyncToGPU
-	nvl.CopyToBytes(unsafe.Pointer(&neur2[0]))
+	run.Read("Neurons", neur2) // this is about same as the upload
+	run.Read("Synapses", syns2)
 
 	gpuFullTmr.Stop()
 
 	mx := min(n, 1)
 	_ = mx
 	anyDiff := false
-	// for i := n - 1; i < n; i++ {
-	for i := 0; i < 1; i++ {
+	// first sending and last receiving neuron
+	for _, i := range []int{0, n - 1} {
 		d1 := &neur1[i]
 		d2 := &neur2[i]
 		fmt.Printf("\n%14s\t   CPU\t   GPU\tDiff\n", "Var")
@@ -202,6 +183,16 @@ func main() {
 			fmt.Printf("%14s\t%6.4g\t%6.4g\t%s\n", vn, v1, v2, diff)
 		}
 	}
+	nWtDiff := 0
+	for si := range syns1 {
+		if math32.Abs(syns1[si].Wt-syns2[si].Wt) > DiffTol {
+			nWtDiff++
+		}
+	}
+	fmt.Printf("\nSynapses: %d\t Wt differences: %d\n", len(syns1), nWtDiff)
+	if nWtDiff > 0 {
+		anyDiff = true
+	}
 	fmt.Printf("\n")
 	if anyDiff {
 		slog.Error("Differences between CPU and GPU detected -- see stars above\n")
@@ -211,7 +202,25 @@ func main() {
 	gpu := gpuTmr.TotalSecs()
 	fmt.Printf("N: %d\t CPU: %6.4g\t GPU: %6.4g\t Full: %6.4g\t CPU/GPU: %6.4g\n", n, cpu, gpu, gpuFullTmr.TotalSecs(), cpu/gpu)
 
-	sy.Destroy()
-	gp.Destroy()
-	vgpu.Terminate()
+	run.Release()
+}
+
+// ConnectPrjn returns the synapses of the projection with the given index
+// from the sending neurons from 0 to rst, to the receiving neurons from rst
+// to ned, with nsyn synapses per sending neuron, sorted by sending neuron,
+// and the range of the synapses of each neuron (X = start, Y = number),
+// in the compressed sparse row (CSR) format of the SendSyns buffer.
+// The receiving neurons are spread evenly across the sending neurons.
+func ConnectPrjn(pi, rst, ned, nsyn int) ([]sltype.Uint2, []Synapse) {
+	sendSyns := make([]sltype.Uint2, ned)
+	syns := make([]Synapse, 0, rst*nsyn)
+	nrecv := ned - rst
+	for si := range rst {
+		sendSyns[si] = sltype.Uint2{X: uint32(len(syns)), Y: uint32(nsyn)}
+		for k := range nsyn {
+			ri := rst + (si*nsyn+k*(nrecv/nsyn+1))%nrecv
+			syns = append(syns, Synapse{SendIndex: uint32(si), RecvIndex: uint32(ri), PrjnIndex: uint32(pi), Wt: 0.5})
+		}
+	}
+	return sendSyns, syns
 }
@@ -4,11 +4,7 @@
 
 package minmax
 
-//gosl: hlsl axon
-// #include "fastexp.hlsl"
-//gosl: end axon
-
-//gosl: start axon
+//gosl: start minmax
 
 const (
 	MaxFloat32 float32 = 3.402823466e+38
@@ -128,7 +124,7 @@ func (mr *F32) Set(min, max float32) {
 	mr.Max = max
 }
 
-//gosl: end axon
+//gosl: end minmax
 
 // FitInRange adjusts our Min, Max to fit within those of other F32
 // returns true if we had to adjust to fit.
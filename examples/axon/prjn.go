@@ -0,0 +1,87 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "github.com/emer/gosl/v2/slfixed"
+
+//gosl: hlsl axon
+// #include "slfixed.hlsl"
+//gosl: end axon
+
+//gosl: start axon
+
+// axon.Synapse has the state of a synapse from a sending neuron
+// to a receiving neuron.
+type Synapse struct {
+
+	// index of the sending neuron in Neurons
+	SendIndex uint32
+
+	// index of the receiving neuron in Neurons
+	RecvIndex uint32
+
+	// index of the projection in Prjns
+	PrjnIndex uint32
+
+	// synaptic weight, in the 0-1 range
+	Wt float32
+
+	// change in synaptic weight, from learning
+	DWt float32
+
+	pad, pad1, pad2 float32
+}
+
+// axon.Prjn has the parameters for a projection of synapses from a
+// sending layer to a receiving layer.  The synapses of all projections
+// are stored in one Synapses buffer, sorted by sending neuron, with the
+// range of synapses of each sending neuron in the SendSyns buffer, in
+// compressed sparse row (CSR) format: X = start, Y = number.
+type Prjn struct {
+
+	// index of the sending layer
+	SendLay uint32
+
+	// index of the receiving layer
+	RecvLay uint32
+
+	// scaling factor for the excitatory conductance sent by a spike: GScale * Wt
+	GScale float32 `default:"0.1"`
+
+	// learning rate for the weight changes
+	LRate float32 `default:"0.04"`
+}
+
+func (pj *Prjn) Defaults() {
+	pj.GScale = 0.1
+	pj.LRate = 0.04
+}
+
+// SpikeG returns the excitatory conductance sent over the synapse by a
+// spike of the sending neuron, in fixed point, for deterministic
+// accumulation into the GeRaws of the receiving neuron with slfixed.AtomicAdd.
+func (pj *Prjn) SpikeG(sy *Synapse) int32 {
+	return slfixed.FromFloat(pj.GScale * sy.Wt)
+}
+
+// DWt computes the weight change for the synapse from the
+// sending and receiving neuron calcium: the difference between
+// the CaSpkP (plus phase) and CaSpkD (minus phase) coproducts.
+func (pj *Prjn) DWt(sy *Synapse, sn, rn *Neuron) {
+	sy.DWt += pj.LRate * (sn.CaSpkP*rn.CaSpkP - sn.CaSpkD*rn.CaSpkD)
+}
+
+// WtFromDWt updates the weight from the weight change, with soft bounding
+// to keep it in the 0-1 range, and resets the weight change.
+func (pj *Prjn) WtFromDWt(sy *Synapse) {
+	if sy.DWt > 0 {
+		sy.Wt += sy.DWt * (1 - sy.Wt)
+	} else {
+		sy.Wt += sy.DWt * sy.Wt
+	}
+	sy.DWt = 0
+}
+
+//gosl: end axon
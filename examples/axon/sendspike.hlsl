@@ -0,0 +1,25 @@
+
+#include "axon.hlsl"
+
+// note: binding is var, set, and must match the other kernels
+[[vk::binding(0, 2)]] RWStructuredBuffer<Neuron> Neurons;
+[[vk::binding(0, 3)]] RWStructuredBuffer<Prjn> Prjns;
+[[vk::binding(0, 4)]] RWStructuredBuffer<uint2> SendSyns;
+[[vk::binding(0, 5)]] RWStructuredBuffer<Synapse> Synapses;
+[[vk::binding(0, 6)]] RWStructuredBuffer<int> GeRaws;
+
+// one thread per sending neuron, which adds the conductance of each of
+// its synapses to the GeRaws of the receiving neuron if it spiked.
+// the atomic fixed point additions give the same result regardless of
+// the order of the threads, and on the CPU.
+[numthreads(64, 1, 1)]
+void main(uint3 idx : SV_DispatchThreadID) {
+	if (Neurons[idx.x].Spike == 0) {
+		return;
+	}
+	uint2 sr = SendSyns[idx.x];
+	for (uint si = sr.x; si < sr.x + sr.y; si++) {
+		Synapse sy = Synapses[si];
+		FixedAtomicAdd(GeRaws[sy.RecvIndex], Prjns[sy.PrjnIndex].SpikeG(sy));
+	}
+}
@@ -0,0 +1,21 @@
+
+#include "axon.hlsl"
+
+// note: binding is var, set, and must match the other kernels
+[[vk::binding(0, 2)]] RWStructuredBuffer<Neuron> Neurons;
+[[vk::binding(0, 3)]] RWStructuredBuffer<Prjn> Prjns;
+[[vk::binding(0, 5)]] RWStructuredBuffer<Synapse> Synapses;
+
+// one thread per synapse, which computes the weight change from the
+// sending and receiving neurons, and updates the weight.
+// the neurons are copied to local variables, because they are only read.
+[numthreads(64, 1, 1)]
+void main(uint3 idx : SV_DispatchThreadID) {
+	Synapse sy = Synapses[idx.x];
+	Neuron sn = Neurons[sy.SendIndex];
+	Neuron rn = Neurons[sy.RecvIndex];
+	Prjn pj = Prjns[sy.PrjnIndex];
+	pj.DWt(sy, sn, rn);
+	pj.WtFromDWt(sy);
+	Synapses[idx.x] = sy;
+}
@@ -59,6 +59,14 @@ func (ps *ParamStruct) Update() {
 	ps.Dt = 1.0 / ps.Tau
 }
 
+// BasicCPU is the CPU version of the basic kernel, for thread index idx,
+// which gosl verifies against the kernel buffers and thread index.
+//
+// gosl: cpukernel basic
+func BasicCPU(idx uint32, Params []ParamStruct, Data []DataStruct) {
+	Params[0].IntegFromRaw(&Data[idx])
+}
+
 //gosl: hlsl basic
 /*
 // // note: double-commented lines required here -- binding is var, set
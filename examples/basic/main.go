@@ -8,10 +8,9 @@ import (
 	"fmt"
 	"math/rand"
 	"runtime"
-	"unsafe"
 
-	"cogentcore.org/core/math32"
-	"cogentcore.org/core/vgpu"
+	"github.com/emer/gosl/v2/goslrun"
+	_ "github.com/emer/gosl/v2/slgpu/slvgpu"
 	"github.com/emer/gosl/v2/timer"
 )
 
@@ -26,21 +25,8 @@ func init() {
 }
 
 func main() {
-	if vgpu.InitNoDisplay() != nil {
-		return
-	}
-
-	gp := vgpu.NewComputeGPU()
-	// vgpu.Debug = true
-	gp.Config("basic")
-
-	// gp.PropsString(true) // print
-
 	n := 100000000 // get 80x with 100m, 50x with 10m
 	threads := 64
-	nInt := int(math32.IntMultiple(float32(n), float32(threads)))
-	n = nInt               // enforce optimal n's -- otherwise requires range checking
-	nGps := nInt / threads // dispatch n
 
 	pars := &ParamStruct{}
 	pars.Defaults()
@@ -52,63 +38,38 @@ func main() {
 		d.Integ = 0
 	}
 
+	params := []ParamStruct{*pars}
 	cpuTmr := timer.Time{}
 	cpuTmr.Start()
 	for i := range data {
-		d := &data[i]
-		pars.IntegFromRaw(d)
+		BasicCPU(uint32(i), params, data)
 	}
 	cpuTmr.Stop()
 
-	sy := gp.NewComputeSystem("basic")
-	pl := sy.NewPipeline("basic")
-	pl.AddShaderFile("basic", vgpu.ComputeShader, "shaders/basic.spv")
-
-	vars := sy.Vars()
-	setp := vars.AddSet()
-	setd := vars.AddSet()
-
-	parsv := setp.AddStruct("Params", int(unsafe.Sizeof(ParamStruct{})), 1, vgpu.Storage, vgpu.ComputeShader)
-	datav := setd.AddStruct("Data", int(unsafe.Sizeof(DataStruct{})), n, vgpu.Storage, vgpu.ComputeShader)
-
-	setp.ConfigValues(1) // one val per var
-	setd.ConfigValues(1) // one val per var
-	sy.Config()          // configures vars, allocates vals, configs pipelines..
+	run := goslrun.New("basic")
+	run.Threads = threads
+	run.Buffer("Params", params)
+	run.Buffer("Data", data)
+	kern := run.Kernel("basic.spv")
 
 	gpuFullTmr := timer.Time{}
 	gpuFullTmr.Start()
 
 	// this copy is pretty fast -- most of time is below
-	pvl, _ := parsv.Values.ValueByIndexTry(0)
-	pvl.CopyFromBytes(unsafe.Pointer(pars))
-	dvl, _ := datav.Values.ValueByIndexTry(0)
-	dvl.CopyFromBytes(unsafe.Pointer(&data[0]))
-
-	// gpuFullTmr := timer.Time{}
-	// gpuFullTmr.Start()
-
-	sy.Mem.SyncToGPU()
-
-	vars.BindDynamicValueIndex(0, "Params", 0)
-	vars.BindDynamicValueIndex(1, "Data", 0)
-
-	cmd := sy.ComputeCmdBuff()
-	sy.CmdResetBindVars(cmd, 0)
-
-	// gpuFullTmr := timer.Time{}
-	// gpuFullTmr.Start()
+	if err := run.Config(); err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	gpuTmr := timer.Time{}
 	gpuTmr.Start()
 
-	pl.ComputeDispatch(cmd, nGps, 1, 1)
-	sy.ComputeCmdEnd(cmd)
-	sy.ComputeSubmitWait(cmd)
+	kern.Dispatch(n) // kernel returns early for idx.x >= n
+	run.Runtime.Wait()
 
 	gpuTmr.Stop()
 
-	sy.Mem.SyncValueIndexFromGPU(1, "Data", 0) // this is about same as SyncToGPU
-	dvl.CopyToBytes(unsafe.Pointer(&data[0]))
+	run.Read("Data", data) // this is about same as the upload
 
 	gpuFullTmr.Stop()
 
@@ -123,7 +84,5 @@ func main() {
 	gpu := gpuTmr.TotalSecs()
 	fmt.Printf("N: %d\t CPU: %6.4g\t GPU: %6.4g\t Full: %6.4g\t CPU/GPU: %6.4g\n", n, cpu, gpu, gpuFullTmr.TotalSecs(), cpu/gpu)
 
-	sy.Destroy()
-	gp.Destroy()
-	vgpu.Terminate()
+	run.Release()
 }
@@ -0,0 +1,161 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"runtime"
+	"slices"
+	"unsafe"
+
+	"cogentcore.org/core/vgpu"
+	"github.com/emer/gosl/v2/slsync"
+	"github.com/emer/gosl/v2/timer"
+)
+
+// note: standard one to use is plain "gosl" which should be go install'd
+
+//go:generate ../../gosl -exclude=Defaults,PoolCPU,IsSame pool.go pool.hlsl
+
+func init() {
+	// must lock main thread for gpu!  this also means that vulkan must be used
+	// for gogi/oswin eventually if we want gui and compute
+	runtime.LockOSThread()
+}
+
+func main() {
+	if vgpu.InitNoDisplay() != nil {
+		return
+	}
+
+	gp := vgpu.NewComputeGPU()
+	// vgpu.Debug = true
+	gp.Config("pool")
+
+	nPools := 1000
+	nNeurons := 200 // per pool: need not be a multiple of PoolThreads
+	n := nPools * nNeurons
+
+	fb := &FFFB{}
+	fb.Defaults()
+	fb.NNeurons = uint32(nNeurons)
+
+	poolsC := make([]Pool, nPools)
+	poolsG := make([]Pool, nPools)
+	nrnsC := make([]Neuron, n)
+	nrnsG := make([]Neuron, n)
+	for i := range nrnsC {
+		nc := &nrnsC[i]
+		nc.Ge = rand.Float32()
+		nc.Act = 0.5 * rand.Float32()
+		nrnsG[i] = *nc
+	}
+
+	cpuTmr := timer.Time{}
+	cpuTmr.Start()
+	for pi := range nPools {
+		PoolCPU(fb, poolsC, nrnsC, uint32(pi))
+	}
+	cpuTmr.Stop()
+
+	// the GPU kernel written in Go, run with the workgroup semantics of the GPU
+	poolsK := make([]Pool, nPools)
+	nrnsK := slices.Clone(nrnsG)
+	slsync.RunGroups(nPools, PoolThreads, func(gid, lid uint32) {
+		PoolKernelCPU(fb, poolsK, nrnsK, gid, lid)
+	})
+	if !slices.Equal(nrnsK, nrnsC) || !slices.Equal(poolsK, poolsC) {
+		fmt.Println("ERROR: PoolKernelCPU run with slsync.RunGroups differs from PoolCPU")
+	}
+
+	sy := gp.NewComputeSystem("pool")
+	pl := sy.NewPipeline("pool")
+	pl.AddShaderFile("pool", vgpu.ComputeShader, "shaders/pool.spv")
+
+	vars := sy.Vars()
+	setp := vars.AddSet()
+	setl := vars.AddSet()
+	setn := vars.AddSet()
+
+	parsv := setp.AddStruct("Params", int(unsafe.Sizeof(FFFB{})), 1, vgpu.Storage, vgpu.ComputeShader)
+	poolv := setl.AddStruct("Pools", int(unsafe.Sizeof(Pool{})), nPools, vgpu.Storage, vgpu.ComputeShader)
+	neurv := setn.AddStruct("Neurons", int(unsafe.Sizeof(Neuron{})), n, vgpu.Storage, vgpu.ComputeShader)
+
+	setp.ConfigValues(1) // one val per var
+	setl.ConfigValues(1) // one val per var
+	setn.ConfigValues(1) // one val per var
+	sy.Config()          // configures vars, allocates vals, configs pipelines..
+
+	gpuFullTmr := timer.Time{}
+	gpuFullTmr.Start()
+
+	pvl, _ := parsv.Values.ValueByIndexTry(0)
+	pvl.CopyFromBytes(unsafe.Pointer(fb))
+	lvl, _ := poolv.Values.ValueByIndexTry(0)
+	lvl.CopyFromBytes(unsafe.Pointer(&poolsG[0]))
+	nvl, _ := neurv.Values.ValueByIndexTry(0)
+	nvl.CopyFromBytes(unsafe.Pointer(&nrnsG[0]))
+
+	sy.Mem.SyncToGPU()
+
+	vars.BindDynamicValueIndex(0, "Params", 0)
+	vars.BindDynamicValueIndex(1, "Pools", 0)
+	vars.BindDynamicValueIndex(2, "Neurons", 0)
+
+	cmd := sy.ComputeCmdBuff()
+	sy.CmdResetBindVars(cmd, 0)
+
+	gpuTmr := timer.Time{}
+	gpuTmr.Start()
+
+	pl.ComputeDispatch(cmd, nPools, 1, 1) // one workgroup per pool
+	sy.ComputeCmdEnd(cmd)
+	sy.ComputeSubmitWait(cmd)
+
+	gpuTmr.Stop()
+
+	sy.Mem.SyncValueIndexFromGPU(1, "Pools", 0)
+	lvl.CopyToBytes(unsafe.Pointer(&poolsG[0]))
+	sy.Mem.SyncValueIndexFromGPU(2, "Neurons", 0)
+	nvl.CopyToBytes(unsafe.Pointer(&nrnsG[0]))
+
+	gpuFullTmr.Stop()
+
+	mx := min(nPools, 5)
+	for pi := 0; pi < mx; pi++ {
+		pc := &poolsC[pi]
+		pg := &poolsG[pi]
+		fmt.Printf("Pool: %d\tGeAvg: %g\tGeMax: %g\tActAvg: %g\tGi: %g\n\t\tGeAvg: %g\tGeMax: %g\tActAvg: %g\tGi: %g\n", pi, pc.GeAvg, pc.GeMax, pc.ActAvg, pc.Gi, pg.GeAvg, pg.GeMax, pg.ActAvg, pg.Gi)
+	}
+	fmt.Printf("\n")
+
+	anyDiffEx := false
+	anyDiffTol := false
+	for i := range nrnsC {
+		smEx, smTol := nrnsC[i].IsSame(&nrnsG[i])
+		if !smEx {
+			anyDiffEx = true
+		}
+		if !smTol {
+			anyDiffTol = true
+		}
+	}
+	if anyDiffEx {
+		slog.Warn("Differences between CPU and GPU detected at Exact level")
+	}
+	if anyDiffTol {
+		slog.Error("Differences between CPU and GPU detected at Tolerance level", "tolerance", Tol)
+	}
+
+	cpu := cpuTmr.TotalSecs()
+	gpu := gpuTmr.TotalSecs()
+	fmt.Printf("N: %d\t CPU: %6.4g\t GPU: %6.4g\t Full: %6.4g\t CPU/GPU: %6.4g\n", n, cpu, gpu, gpuFullTmr.TotalSecs(), cpu/gpu)
+
+	sy.Destroy()
+	gp.Destroy()
+	vgpu.Terminate()
+}
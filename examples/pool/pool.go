@@ -0,0 +1,228 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"cogentcore.org/core/math32"
+	"github.com/emer/gosl/v2/slsync"
+)
+
+//gosl: start pool
+
+// PoolThreads is the number of threads per workgroup, each of which
+// processes every PoolThreads'th neuron in a pool.
+// Must match the numthreads in pool.hlsl, and be a power of 2.
+const PoolThreads = 64
+
+// these groupshared variables are shared among all the threads
+// in a workgroup, which processes one pool.
+// note: they must be declared before any struct methods that use them.
+
+// gosl: groupshared
+var SharedGeSum [PoolThreads]float32
+
+// gosl: groupshared
+var SharedGeMax [PoolThreads]float32
+
+// gosl: groupshared
+var SharedActSum [PoolThreads]float32
+
+// gosl: groupshared
+var SharedGi float32
+
+// Neuron has the per-neuron state
+type Neuron struct {
+
+	// excitatory conductance input
+	Ge float32
+
+	// inhibitory conductance from the pool
+	Gi float32
+
+	// rate-code activation, driven by Ge - Gi
+	Act float32
+
+	pad float32
+}
+
+// Pool has the per-pool inhibition state, computed from the neurons
+// in the pool.
+type Pool struct {
+
+	// average Ge excitatory conductance across the pool
+	GeAvg float32
+
+	// maximum Ge excitatory conductance across the pool
+	GeMax float32
+
+	// average Act activation across the pool
+	ActAvg float32
+
+	// computed FFFB inhibition for the pool
+	Gi float32
+}
+
+// FFFB has the parameters for feedforward (FF) and feedback (FB)
+// inhibition, computed per pool.
+type FFFB struct {
+
+	// overall inhibition gain
+	Gi float32
+
+	// overall inhibitory contribution from feedforward inhibition,
+	// computed from average Ge
+	FF float32
+
+	// overall inhibitory contribution from feedback inhibition,
+	// computed from average Act
+	FB float32
+
+	// feedforward zero point for average Ge: below this level,
+	// no FF inhibition is computed
+	FF0 float32
+
+	// what proportion of the maximum vs. average Ge to use in the
+	// feedforward inhibition computation
+	MaxVsAvg float32
+
+	// number of neurons in each pool
+	NNeurons uint32
+
+	pad, pad1 float32
+}
+
+// FFInhib returns the feedforward inhibition from average and max Ge
+func (fb *FFFB) FFInhib(avgGe, maxGe float32) float32 {
+	ffNetin := avgGe + fb.MaxVsAvg*(maxGe-avgGe)
+	ffi := float32(0)
+	if ffNetin > fb.FF0 {
+		ffi = fb.FF * (ffNetin - fb.FF0)
+	}
+	return ffi
+}
+
+// FBInhib returns the feedback inhibition from average Act
+func (fb *FFFB) FBInhib(avgAct float32) float32 {
+	return fb.FB * avgAct
+}
+
+// PoolInhib computes the pool inhibition from the reduced sums and max,
+// which must be called by one thread (0) after the final ReduceStep.
+func (fb *FFFB) PoolInhib(pl *Pool) {
+	n := float32(fb.NNeurons)
+	pl.GeAvg = SharedGeSum[0] / n
+	pl.GeMax = SharedGeMax[0]
+	pl.ActAvg = SharedActSum[0] / n
+	pl.Gi = fb.Gi * (fb.FFInhib(pl.GeAvg, pl.GeMax) + fb.FBInhib(pl.ActAvg))
+	SharedGi = pl.Gi
+}
+
+// InitShared initializes the shared values for thread ti
+func InitShared(ti uint32) {
+	SharedGeSum[ti] = 0
+	SharedGeMax[ti] = 0
+	SharedActSum[ti] = 0
+}
+
+// AccumNeuron accumulates the given neuron values into the
+// shared values for thread ti.
+func AccumNeuron(ti uint32, nrn *Neuron) {
+	SharedGeSum[ti] += nrn.Ge
+	SharedGeMax[ti] = math32.Max(SharedGeMax[ti], nrn.Ge)
+	SharedActSum[ti] += nrn.Act
+}
+
+// ReduceStep is one step of the tree reduction of the shared values,
+// where each thread ti < stride combines its values with those at
+// ti + stride.  Stride starts at PoolThreads / 2 and halves each step.
+func ReduceStep(ti, stride uint32) {
+	if ti < stride {
+		SharedGeSum[ti] += SharedGeSum[ti+stride]
+		SharedGeMax[ti] = math32.Max(SharedGeMax[ti], SharedGeMax[ti+stride])
+		SharedActSum[ti] += SharedActSum[ti+stride]
+	}
+}
+
+// ApplyInhib applies the pool inhibition to the neuron,
+// updating its activation.
+func ApplyInhib(nrn *Neuron) {
+	nrn.Gi = SharedGi
+	net := nrn.Ge - nrn.Gi
+	if net < 0 {
+		net = 0
+	}
+	nrn.Act = net / (net + 0.3)
+}
+
+//gosl: end pool
+
+// note: only core compute code needs to be in shader -- all init is done CPU-side
+
+func (fb *FFFB) Defaults() {
+	fb.Gi = 1.8
+	fb.FF = 1
+	fb.FB = 1
+	fb.FF0 = 0.1
+	fb.MaxVsAvg = 0
+}
+
+// PoolCPU runs the same computation as the GPU kernel in pool.hlsl
+// for pool pi, running each phase for all threads in turn, which is
+// equivalent to the barriers between phases on the GPU.
+// Because the shared values are global on the CPU, pools must be
+// processed sequentially.
+func PoolCPU(fb *FFFB, pools []Pool, nrns []Neuron, pi uint32) {
+	n := fb.NNeurons
+	for ti := range uint32(PoolThreads) {
+		InitShared(ti)
+		for ni := ti; ni < n; ni += PoolThreads {
+			AccumNeuron(ti, &nrns[pi*n+ni])
+		}
+	}
+	for stride := uint32(PoolThreads / 2); stride > 0; stride >>= 1 {
+		for ti := range uint32(PoolThreads) {
+			ReduceStep(ti, stride)
+		}
+	}
+	fb.PoolInhib(&pools[pi])
+	for ni := range n {
+		ApplyInhib(&nrns[pi*n+ni])
+	}
+}
+
+// PoolKernelCPU is the GPU kernel in pool.hlsl written in Go, for
+// thread ti of the workgroup for pool pi, with the same barriers.
+// It is run for all pools with slsync.RunGroups, which runs the
+// threads of each workgroup in turn up to each barrier, so it computes
+// exactly the same results as PoolCPU.
+func PoolKernelCPU(fb *FFFB, pools []Pool, nrns []Neuron, pi, ti uint32) {
+	n := fb.NNeurons
+	InitShared(ti)
+	for ni := ti; ni < n; ni += PoolThreads {
+		AccumNeuron(ti, &nrns[pi*n+ni])
+	}
+	slsync.GroupBarrier()
+	for stride := uint32(PoolThreads / 2); stride > 0; stride >>= 1 {
+		ReduceStep(ti, stride)
+		slsync.GroupBarrier()
+	}
+	if ti == 0 {
+		fb.PoolInhib(&pools[pi])
+	}
+	slsync.GroupBarrier()
+	for ai := ti; ai < n; ai += PoolThreads {
+		ApplyInhib(&nrns[pi*n+ai])
+	}
+}
+
+// Tol is the tolerance for comparing CPU and GPU results
+const Tol = 1.0e-5
+
+// IsSame compares values at exact and tolerance levels
+func (nrn *Neuron) IsSame(on *Neuron) (exact, tol bool) {
+	exact = nrn.Gi == on.Gi && nrn.Act == on.Act
+	tol = math32.Abs(nrn.Gi-on.Gi) < Tol && math32.Abs(nrn.Act-on.Act) < Tol
+	return
+}
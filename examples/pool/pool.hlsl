@@ -0,0 +1,35 @@
+
+// note: binding is var, set
+[[vk::binding(0, 0)]] RWStructuredBuffer<FFFB> Params;
+[[vk::binding(0, 1)]] RWStructuredBuffer<Pool> Pools;
+[[vk::binding(0, 2)]] RWStructuredBuffer<Neuron> Neurons;
+
+// one workgroup per pool: each thread accumulates every PoolThreads'th
+// neuron into groupshared memory, followed by a tree reduction,
+// with barriers between each phase.
+// numthreads must match PoolThreads.
+[numthreads(64, 1, 1)]
+
+void main(uint3 gid : SV_GroupID, uint3 lid : SV_GroupThreadID) {
+	uint pi = gid.x;
+	uint ti = lid.x;
+	uint n = Params[0].NNeurons;
+	InitShared(ti);
+	for (uint ni = ti; ni < n; ni += PoolThreads) {
+		AccumNeuron(ti, Neurons[pi * n + ni]);
+	}
+	GroupMemoryBarrierWithGroupSync();
+	for (uint stride = PoolThreads / 2; stride > 0; stride >>= 1) {
+		ReduceStep(ti, stride);
+		GroupMemoryBarrierWithGroupSync();
+	}
+	if (ti == 0) {
+		FFFB fb = Params[0]; // local copy: only Pools is written
+		fb.PoolInhib(Pools[pi]);
+	}
+	GroupMemoryBarrierWithGroupSync();
+	for (uint ai = ti; ai < n; ai += PoolThreads) {
+		ApplyInhib(Neurons[pi * n + ai]);
+	}
+}
+
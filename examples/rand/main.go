@@ -11,7 +11,6 @@ import (
 
 	"log/slog"
 
-	"cogentcore.org/core/math32"
 	"cogentcore.org/core/vgpu"
 	"github.com/emer/gosl/v2/sltype"
 	"github.com/emer/gosl/v2/timer"
@@ -41,9 +40,7 @@ func main() {
 	// n := 10
 	n := 10000000
 	threads := 64
-	nInt := int(math32.IntMultiple(float32(n), float32(threads)))
-	n = nInt               // enforce optimal n's -- otherwise requires range checking
-	nGps := nInt / threads // dispatch n
+	nGps := (n + threads - 1) / threads // kernel returns early for idx.x >= n
 
 	dataC := make([]Rnds, n)
 	dataG := make([]Rnds, n)
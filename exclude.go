@@ -0,0 +1,93 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExcludedNames records the -exclude names that were encountered
+// and excluded by the current run of ProcessFiles, for the -strict check.
+var ExcludedNames = map[string]bool{}
+
+// ExcludeReason returns the reason for excluding a function named in
+// the -exclude list: "flag" if -exclude was set on the command line,
+// otherwise "exclude-list" for the default list.
+func ExcludeReason() string {
+	reason := "exclude-list"
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "exclude" {
+			reason = "flag"
+		}
+	})
+	return reason
+}
+
+// ExcludedFuncs records the functions that were excluded from the output
+// by the current run of ProcessFiles, as "name (region)", with the reason,
+// for PrintExcluded.
+var ExcludedFuncs = map[string]string{}
+
+// LogExcluded records the exclusion of the given function from the output,
+// which is passed to the printer for each excluded function, for the
+// summary printed by PrintExcluded.  Functions are excluded if they are in
+// the -exclude list (methods and other functions alike), automatically
+// ("auto") if they have string parameters or results, which cannot be
+// translated (e.g., String methods), or if they are generic ("generic"),
+// as they are translated as their concrete instances.
+func LogExcluded(name, reason string, pos token.Position) {
+	if reason == "exclude" {
+		_, fn, isMeth := strings.Cut(name, ".")
+		if !isMeth {
+			fn = name
+		}
+		ExcludedNames[fn] = true
+		reason = ExcludeReason()
+	}
+	ExcludedFuncs[name+" ("+strings.TrimSuffix(filepath.Base(pos.Filename), ".go")+")"] = reason
+}
+
+// PrintExcluded prints the table of functions that were excluded
+// from the output, with the reason.
+func PrintExcluded() {
+	if len(ExcludedFuncs) == 0 {
+		return
+	}
+	nms := make([]string, 0, len(ExcludedFuncs))
+	for nm := range ExcludedFuncs {
+		nms = append(nms, nm)
+	}
+	sort.Strings(nms)
+	fmt.Printf("\ngosl: excluded functions:\n")
+	for _, nm := range nms {
+		fmt.Printf("    %s\t%s\n", nm, ExcludedFuncs[nm])
+	}
+}
+
+// CheckExcludes returns an error if any of the -exclude names given on
+// the command line were never encountered, which usually indicates a typo,
+// for -strict mode.  The names of the default list are not checked, as
+// packages need not have them.
+func CheckExcludes() error {
+	if ExcludeReason() != "flag" {
+		return nil
+	}
+	var missing []string
+	for nm := range excludeFunMap {
+		if nm != "" && !ExcludedNames[nm] {
+			missing = append(missing, nm)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("gosl: -strict: -exclude functions never encountered (check for typos): %s", strings.Join(missing, ", "))
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestExcludes(t *testing.T) {
+	saved := maps.Clone(excludeFunMap)
+	dxc := *dxcPath
+	t.Cleanup(func() {
+		excludeFunMap, *dxcPath = saved, dxc
+		ResetState()
+	})
+	*dxcPath = ToolNone
+	excludeFunMap = map[string]bool{"AnotherMeth": true, "Typo": true}
+	ExcludedNames["Stale"] = true // from a previous run
+	sls, err := ProcessFiles([]string{"testdata/basic.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sls["basic"]), "AnotherMeth(") {
+		t.Error("AnotherMeth was not excluded")
+	}
+	if len(ExcludedNames) != 1 || !ExcludedNames["AnotherMeth"] {
+		t.Errorf("wrong excluded names: %v", ExcludedNames)
+	}
+	if reason, ok := ExcludedFuncs["ParamStruct.AnotherMeth (basic)"]; !ok || reason != "exclude-list" {
+		t.Errorf("AnotherMeth not recorded with the exclude-list reason: %v", ExcludedFuncs)
+	}
+	if err := CheckExcludes(); err != nil { // only the -exclude flag is checked
+		t.Errorf("-strict error for the default list: %v", err)
+	}
+	ex := *excludeFuns
+	flag.Set("exclude", "AnotherMeth,Typo")
+	defer flag.Set("exclude", ex)
+	err = CheckExcludes()
+	if err == nil || !strings.Contains(err.Error(), "Typo") || strings.Contains(err.Error(), "AnotherMeth") {
+		t.Errorf("wrong -strict error: %v", err)
+	}
+}
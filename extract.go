@@ -6,17 +6,34 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"go/format"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-
-	"slices"
 )
 
+// RegionSources are the source files that contributed code to each
+// output file, in order, as extracted by ExtractGoFiles.
+var RegionSources = map[string][]string{}
+
+// AddRegionSource adds given source file to the list for given output
+// file name, if not already present.
+func AddRegionSource(slFn, fn string) {
+	for _, sf := range RegionSources[slFn] {
+		if sf == fn {
+			return
+		}
+	}
+	RegionSources[slFn] = append(RegionSources[slFn], fn)
+}
+
+// ReadFileLines returns the lines of the given file, without the line
+// endings, which can be CRLF, e.g., on Windows.
 func ReadFileLines(fn string) ([][]byte, error) {
 	nl := []byte("\n")
 	buf, err := os.ReadFile(fn)
@@ -24,21 +41,39 @@ func ReadFileLines(fn string) ([][]byte, error) {
 		fmt.Println(err)
 		return nil, err
 	}
+	buf = bytes.ReplaceAll(buf, []byte("\r\n"), nl)
 	lines := bytes.Split(buf, nl)
 	return lines, nil
 }
 
-// Extracts comment-directive tagged regions from .go files
-func ExtractGoFiles(files []string) map[string][]byte {
+// RegionName returns the name of the output file of a region from the
+// rest of its start directive, e.g., axon in //gosl: start axon, which
+// must be a plain file name, without a path.
+func RegionName(key []byte) (string, error) {
+	nm := string(bytes.TrimSpace(key))
+	if nm == "" || strings.ContainsAny(nm, `/\ `) || nm == "." || nm == ".." {
+		return "", fmt.Errorf("gosl: invalid region name: %q: must be a file name without a path", nm)
+	}
+	return nm, nil
+}
+
+// Extracts comment-directive tagged regions from .go files,
+// returning any errors from the PreExtract hook.
+func ExtractGoFiles(files []string) (map[string][]byte, error) {
 	sls := map[string][][]byte{}
 	key := []byte("//gosl: ")
 	start := []byte("start")
-	hlsl := []byte("hlsl")
 	nohlsl := []byte("nohlsl")
 	end := []byte("end")
+	table := []byte("table")
+	buffer := []byte("buffer")
+	uses := []byte("uses")
+	cpuonly := []byte("cpuonly")
+	endCPUOnly := []byte("end cpuonly")
 	nl := []byte("\n")
 	include := []byte("#include")
 
+	var errs []error
 	for _, fn := range files {
 		if !strings.HasSuffix(fn, ".go") {
 			continue
@@ -47,13 +82,24 @@ func ExtractGoFiles(files []string) map[string][]byte {
 		if err != nil {
 			continue
 		}
+		if GoslHooks.PreExtract != nil {
+			src, err := GoslHooks.PreExtract(fn, bytes.Join(lines, nl))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			lines = bytes.Split(src, nl)
+		}
 
 		inReg := false
 		inHlsl := false
 		inNoHlsl := false
+		inCPUOnly := false
+		var cpuBlocks []CPUOnlyBlock
+		var regions [][2]int // start regions, for CheckUnsafe
 		var outLns [][]byte
 		slFn := ""
-		for _, ln := range lines {
+		for li, ln := range lines {
 			tln := bytes.TrimSpace(ln)
 			isKey := bytes.HasPrefix(tln, key)
 			var keyStr []byte
@@ -62,14 +108,40 @@ func ExtractGoFiles(files []string) map[string][]byte {
 				// fmt.Printf("key: %s\n", string(keyStr))
 			}
 			switch {
+			case inCPUOnly && isKey && bytes.Equal(keyStr, endCPUOnly):
+				cpuBlocks[len(cpuBlocks)-1].End = li + 1
+				inCPUOnly = false
+			case inCPUOnly: // removed from the GPU code
+			case inReg && !inHlsl && !inNoHlsl && isKey && bytes.Equal(keyStr, cpuonly):
+				inCPUOnly = true
+				cpuBlocks = append(cpuBlocks, CPUOnlyBlock{Start: li + 1})
 			case inReg && isKey && bytes.HasPrefix(keyStr, end):
 				if inHlsl || inNoHlsl {
 					outLns = append(outLns, ln)
 				}
 				sls[slFn] = outLns
+				if !inHlsl && !inNoHlsl {
+					regions[len(regions)-1][1] = li + 1
+				}
 				inReg = false
 				inHlsl = false
 				inNoHlsl = false
+			case inReg && !inHlsl && !inNoHlsl && isKey && bytes.HasPrefix(keyStr, table):
+				tlns, err := ExtractTable(string(keyStr[len(table):]), fn, slFn)
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				outLns = append(outLns, tlns...)
+			case inReg && !inHlsl && !inNoHlsl && isKey && bytes.HasPrefix(keyStr, buffer):
+				bd, err := ParseBufferDirective(string(keyStr[len(buffer):]))
+				if err != nil {
+					fmt.Printf("%s:%d: %v\n", fn, li+1, err)
+					continue
+				}
+				outLns = append(outLns, bd.Lines(slFn)...)
+			case inReg && !inHlsl && !inNoHlsl && isKey && bytes.HasPrefix(keyStr, uses):
+				AddRegionUses(slFn, string(keyStr[len(uses):]))
 			case inReg:
 				for pkg := range LoadedPackageNames { // remove package prefixes
 					if !bytes.Contains(ln, include) {
@@ -78,66 +150,132 @@ func ExtractGoFiles(files []string) map[string][]byte {
 				}
 				outLns = append(outLns, ln)
 			case isKey && bytes.HasPrefix(keyStr, start):
+				nm, err := RegionName(keyStr[len(start):])
+				if err != nil {
+					fmt.Printf("%s:%d: %v\n", fn, li+1, err)
+					continue
+				}
 				inReg = true
-				slFn = string(keyStr[len(start)+1:])
+				regions = append(regions, [2]int{li + 1, len(lines) + 1})
+				slFn = nm
 				outLns = sls[slFn]
+				AddRegionSource(slFn, fn)
 			case isKey && bytes.HasPrefix(keyStr, nohlsl):
+				nm, err := RegionName(keyStr[len(nohlsl):])
+				if err != nil {
+					fmt.Printf("%s:%d: %v\n", fn, li+1, err)
+					continue
+				}
 				inReg = true
 				inNoHlsl = true
-				slFn = string(keyStr[len(nohlsl)+1:])
+				slFn = nm
 				outLns = sls[slFn]
+				AddRegionSource(slFn, fn)
 				outLns = append(outLns, ln) // key to include self here
-			case isKey && bytes.HasPrefix(keyStr, hlsl):
+			case isKey && isTargetKey(keyStr):
+				_, rest, _ := targetKey(keyStr)
+				nm, err := RegionName(rest)
+				if err != nil {
+					fmt.Printf("%s:%d: %v\n", fn, li+1, err)
+					continue
+				}
 				inReg = true
 				inHlsl = true
-				slFn = string(keyStr[len(hlsl)+1:])
+				slFn = nm
 				outLns = sls[slFn]
+				AddRegionSource(slFn, fn)
 				outLns = append(outLns, ln)
 			}
 		}
+		if inCPUOnly {
+			fmt.Printf("%s:%d: //gosl: cpuonly without //gosl: end cpuonly\n", fn, cpuBlocks[len(cpuBlocks)-1].Start)
+			cpuBlocks = cpuBlocks[:len(cpuBlocks)-1]
+		}
+		if len(cpuBlocks) > 0 {
+			if err := CheckCPUOnlyBlocks(fn, bytes.Join(lines, nl), cpuBlocks); err != nil {
+				fmt.Println(err)
+			}
+		}
+		if err := CheckUnsafe(fn, bytes.Join(lines, nl), regions, cpuBlocks); err != nil {
+			fmt.Println(err)
+		}
+		if err := CheckRegionImports(fn, bytes.Join(lines, nl), regions, cpuBlocks); err != nil {
+			fmt.Println(err)
+		}
+		progress.Step(fn)
 	}
 
+	progress.Stage("imports", len(sls))
 	rsls := make(map[string][]byte)
 	for fn, lns := range sls {
-		outfn := filepath.Join(*outDir, fn+".go")
+		outfn := filepath.Join(GenDir(), fn+".go")
 		olns := [][]byte{}
 		olns = append(olns, []byte("package main"))
 		olns = append(olns, []byte(`import "math"`))
+		if *goimportsPath == ToolNone {
+			olns = append(olns, SourceImports(RegionSources[fn])...)
+		}
 		olns = append(olns, lns...)
 		res := bytes.Join(olns, nl)
+		if *goimportsPath == ToolNone {
+			if fres, err := format.Source(res); err == nil {
+				res = fres
+			}
+		}
 		ioutil.WriteFile(outfn, res, 0644)
-		cmd := exec.Command("goimports", "-w", fn+".go") // get imports
-		cmd.Dir, _ = filepath.Abs(*outDir)
-		out, err := cmd.CombinedOutput()
-		_ = out
-		// fmt.Printf("\n################\ngoimports output for: %s\n%s\n", outfn, out)
-		if err != nil {
-			log.Println(err)
+		if *goimportsPath != ToolNone {
+			cmd := exec.Command(*goimportsPath, "-w", fn+".go") // get imports
+			cmd.Dir, _ = filepath.Abs(GenDir())
+			out, err := cmd.CombinedOutput()
+			_ = out
+			// fmt.Printf("\n################\ngoimports output for: %s\n%s\n", outfn, out)
+			if err != nil {
+				log.Println(err)
+			}
 		}
 		rsls[fn] = bytes.Join(lns, nl)
+		progress.Step(fn)
 	}
 
-	return rsls
+	return rsls, errors.Join(errs...)
 }
 
 // ExtractHLSL extracts the HLSL code embedded within .Go files.
-// Returns true if HLSL contains a void main( function.
+// Returns true if HLSL contains a void main( function, or another
+// entry point function with a [numthreads( attribute.
 func ExtractHLSL(buf []byte) ([]byte, bool) {
+	return ExtractShader(buf, TargetHLSL)
+}
+
+// ExtractShader extracts the shader code for the given target, e.g.,
+// wgsl, embedded within .Go files: the raw code blocks for the target
+// are uncommented, and those for other targets are removed.
+// Returns true if the code contains an entry point function:
+// void main( or [numthreads( in HLSL, @compute in WGSL, and
+// void main( or layout(local_size in GLSL, and kernel void in MSL.
+func ExtractShader(buf []byte, target string) ([]byte, bool) {
 	key := []byte("//gosl: ")
-	hlsl := []byte("hlsl")
 	nohlsl := []byte("nohlsl")
 	end := []byte("end")
-	nl := []byte("\n")
 	stComment := []byte("/*")
 	edComment := []byte("*/")
 	comment := []byte("// ")
 	pack := []byte("package")
 	imp := []byte("import")
-	main := []byte("void main(")
+	mains := [][]byte{[]byte("void main("), []byte("[numthreads(")}
+	switch target {
+	case TargetWGSL:
+		mains = [][]byte{[]byte("@compute")}
+	case TargetGLSL:
+		mains = [][]byte{[]byte("void main("), []byte("layout(local_size")}
+	case TargetMetal:
+		mains = [][]byte{[]byte("kernel void")}
+	}
 	lparen := []byte("(")
 	rparen := []byte(")")
 
-	lines := bytes.Split(buf, nl)
+	lb := NewLineBuffer(buf)
+	lines := lb.Lines
 
 	mx := min(10, len(lines))
 	stln := 0
@@ -158,14 +296,14 @@ func ExtractHLSL(buf []byte) ([]byte, bool) {
 		}
 	}
 
-	lines = lines[stln:] // get rid of package, import
+	lb.Delete(0, stln) // get rid of package, import
 
 	hasMain := false
 	inHlsl := false
 	inNoHlsl := false
 	noHlslStart := 0
-	for li := 0; li < len(lines); li++ {
-		ln := lines[li]
+	for li := 0; li < lb.Len(); li++ {
+		ln := lb.Lines[li]
 		isKey := bytes.HasPrefix(ln, key)
 		var keyStr []byte
 		if isKey {
@@ -174,36 +312,43 @@ func ExtractHLSL(buf []byte) ([]byte, bool) {
 		}
 		switch {
 		case inNoHlsl && isKey && bytes.HasPrefix(keyStr, end):
-			lines = slices.Delete(lines, noHlslStart, li+1)
-			li -= ((li + 1) - noHlslStart)
+			lb.Delete(noHlslStart, li+1)
+			li = noHlslStart - 1
 			inNoHlsl = false
 		case inHlsl && isKey && bytes.HasPrefix(keyStr, end):
-			lines = slices.Delete(lines, li, li+1)
+			lb.Delete(li, li+1)
 			li--
 			inHlsl = false
 		case inHlsl:
 			del := false
 			switch {
 			case bytes.HasPrefix(ln, stComment) || bytes.HasPrefix(ln, edComment):
-				lines = slices.Delete(lines, li, li+1)
+				lb.Delete(li, li+1)
 				li--
 				del = true
 			case bytes.HasPrefix(ln, comment):
-				lines[li] = ln[3:]
+				lb.Lines[li] = ln[3:]
 			}
 			if !del {
-				if bytes.HasPrefix(lines[li], main) {
-					hasMain = true
+				for _, mn := range mains {
+					if bytes.HasPrefix(lb.Lines[li], mn) {
+						hasMain = true
+					}
 				}
 			}
-		case isKey && bytes.HasPrefix(keyStr, hlsl):
+		case isKey && isTargetKey(keyStr):
+			if tg, _, _ := targetKey(keyStr); tg != target { // removed like nohlsl
+				inNoHlsl = true
+				noHlslStart = li
+				continue
+			}
 			inHlsl = true
-			lines = slices.Delete(lines, li, li+1)
+			lb.Delete(li, li+1)
 			li--
 		case isKey && bytes.HasPrefix(keyStr, nohlsl):
 			inNoHlsl = true
 			noHlslStart = li
 		}
 	}
-	return bytes.Join(lines, nl), hasMain
+	return lb.Bytes(), hasMain
 }
@@ -0,0 +1,81 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegionName(t *testing.T) {
+	for key, want := range map[string]string{" axon": "axon", " axon\r": "axon", "\tchans ": "chans"} {
+		if nm, err := RegionName([]byte(key)); err != nil || nm != want {
+			t.Errorf("RegionName(%q) = %q, %v, want %q", key, nm, err, want)
+		}
+	}
+	for _, key := range []string{"", " ", " sub/axon", ` sub\axon`, " a b", " .."} {
+		if nm, err := RegionName([]byte(key)); err == nil {
+			t.Errorf("RegionName(%q) = %q, expected error", key, nm)
+		}
+	}
+}
+
+// TestExtractCRLF extracts regions from a file with Windows line endings.
+func TestExtractCRLF(t *testing.T) {
+	od, gi := *outDir, *goimportsPath
+	*outDir = t.TempDir()
+	*goimportsPath = ToolNone
+	t.Cleanup(func() {
+		*outDir, *goimportsPath = od, gi
+		ResetState()
+	})
+	src := strings.Join([]string{
+		"package test",
+		"",
+		"//gosl: start crlf",
+		"",
+		"// Gain returns x times the gain",
+		"func Gain(x float32) float32 {",
+		"\treturn 2 * x",
+		"}",
+		"",
+		"//gosl: end crlf",
+		"",
+		"//gosl: start sub\\bad",
+		"func Bad() {}",
+		"//gosl: end sub\\bad",
+		"",
+	}, "\r\n")
+	fn := filepath.Join(t.TempDir(), "crlf.go")
+	os.WriteFile(fn, []byte(src), 0644)
+
+	lines, err := ReadFileLines(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ln := range lines {
+		if bytes.HasSuffix(ln, []byte("\r")) {
+			t.Errorf("line with CR: %q", ln)
+		}
+	}
+
+	sls, err := ExtractGoFiles([]string{fn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sls) != 1 {
+		t.Fatalf("expected only the crlf region, got: %v", sls)
+	}
+	got, ok := sls["crlf"]
+	if !ok || !bytes.Contains(got, []byte("func Gain(x float32) float32 {\n\treturn 2 * x\n}")) || bytes.Contains(got, []byte("\r")) {
+		t.Errorf("wrong crlf region: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(*outDir, "crlf.go")); err != nil {
+		t.Errorf("extracted Go file not written: %v", err)
+	}
+}
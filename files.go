@@ -25,9 +25,32 @@ func IsGoFile(f fs.DirEntry) bool {
 	return !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".go") && !f.IsDir()
 }
 
-func IsHLSLFile(f fs.DirEntry) bool {
+// IsTestFile returns true if the given file name is a Go _test.go file
+func IsTestFile(fn string) bool {
+	return strings.HasSuffix(fn, "_test.go")
+}
+
+// TestFiles returns the _test.go files in the same directory as the
+// given Go files, if the -tests flag is set, and nil otherwise.
+func TestFiles(gofls []string) []string {
+	if !*tests || len(gofls) == 0 {
+		return nil
+	}
+	dir, _ := filepath.Split(gofls[0])
+	tfls, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	return tfls
+}
+
+// IsShaderFile returns true for the shader code files of any of the
+// Targets, e.g., .hlsl, .wgsl, .glsl and .metal files
+func IsShaderFile(f fs.DirEntry) bool {
 	name := f.Name()
-	return !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".hlsl") && !f.IsDir()
+	for _, tg := range Targets {
+		if !strings.HasPrefix(name, ".") && strings.HasSuffix(name, "."+tg) && !f.IsDir() {
+			return true
+		}
+	}
+	return false
 }
 
 func IsSPVFile(f fs.DirEntry) bool {
@@ -69,17 +92,17 @@ func FilesFromPaths(paths []string) []string {
 			var pkgs []*packages.Package
 			dir, fl := filepath.Split(path)
 			if dir != "" && fl != "" && strings.HasSuffix(fl, ".go") {
-				pkgs, err = packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedFiles}, dir)
+				pkgs, err = loadFiles(dir)
 			} else {
 				fl = ""
-				pkgs, err = packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedFiles}, path)
+				pkgs, err = loadFiles(path)
 			}
 			if err != nil {
 				fmt.Println(err)
 				continue
 			}
 			pkg := pkgs[0]
-			gofls := pkg.GoFiles
+			gofls := append(pkg.GoFiles, TestFiles(pkg.GoFiles)...)
 			if len(gofls) == 0 {
 				fmt.Printf("WARNING: no go files found in path: %s\n", path)
 			}
@@ -99,13 +122,20 @@ func FilesFromPaths(paths []string) []string {
 			}
 		case !info.IsDir():
 			path := path
+			if IsTestFile(path) && !*tests {
+				fmt.Printf("skipping test file: %s -- use -tests to include\n", path)
+				continue
+			}
 			fls = AddFile(path, fls, procd)
 		default:
 			// Directories are walked, ignoring non-Go, non-HLSL files.
 			err := filepath.WalkDir(path, func(path string, f fs.DirEntry, err error) error {
-				if err != nil || !(IsGoFile(f) || IsHLSLFile(f)) {
+				if err != nil || !(IsGoFile(f) || IsShaderFile(f)) {
 					return err
 				}
+				if IsTestFile(path) && !*tests {
+					return nil
+				}
 				_, err = f.Info()
 				if err != nil {
 					return nil
@@ -121,6 +151,26 @@ func FilesFromPaths(paths []string) []string {
 	return fls
 }
 
+// loadCache caches the packages loaded by loadFiles, for sharing
+// among the packages in workspace mode, if non-nil.
+var loadCache map[string][]*packages.Package
+
+// loadFiles loads the names and files of the package with given path.
+func loadFiles(path string) ([]*packages.Package, error) {
+	key := path
+	if strings.HasPrefix(path, ".") || filepath.IsAbs(path) {
+		key, _ = filepath.Abs(path)
+	}
+	if pkgs, ok := loadCache[key]; ok {
+		return pkgs, nil
+	}
+	pkgs, err := packages.Load(LoadConfig(packages.NeedName|packages.NeedFiles), path)
+	if err == nil && loadCache != nil {
+		loadCache[key] = pkgs
+	}
+	return pkgs, err
+}
+
 func CopyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -136,13 +186,15 @@ func CopyFile(src, dst string) error {
 	return err
 }
 
-func CopySlrand() error {
-	hdr := "slrand.hlsl"
-	tofn := filepath.Join(*outDir, hdr)
+// CopyPackageHLSL copies the <pkg>.hlsl header file from the given
+// gosl package (e.g., slrand) into the output directory.
+func CopyPackageHLSL(pkgName string) error {
+	hdr := pkgName + ".hlsl"
+	tofn := filepath.Join(GenDir(), hdr)
 
-	pnm := "github.com/emer/gosl/v2/slrand"
+	pnm := "github.com/emer/gosl/v2/" + pkgName
 
-	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedFiles}, pnm)
+	pkgs, err := packages.Load(LoadConfig(packages.NeedName|packages.NeedFiles), pnm)
 	if err != nil {
 		fmt.Println(err)
 		return err
@@ -164,19 +216,22 @@ func CopySlrand() error {
 		return err
 	}
 	dir, _ := filepath.Split(fn)
-	// dir = filepath.Join(dir, "slrand")
 	fmfn := filepath.Join(dir, hdr)
 	CopyFile(fmfn, tofn)
 	return nil
 }
 
-// RemoveGenFiles removes .go, .hlsl, .spv files in shader generated dir
+// RemoveGenFiles removes the .go, .spv, and shader files of all Targets
+// (e.g., .hlsl) in shader generated dir
 func RemoveGenFiles(dir string) {
 	err := filepath.WalkDir(dir, func(path string, f fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if IsGoFile(f) || IsHLSLFile(f) || IsSPVFile(f) {
+		if f.IsDir() && path != dir && strings.HasPrefix(f.Name(), StagingPrefix) {
+			return filepath.SkipDir
+		}
+		if IsGoFile(f) || IsShaderFile(f) || IsSPVFile(f) {
 			os.Remove(path)
 		}
 		return nil
@@ -0,0 +1,244 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/emer/gosl/v2/diff"
+)
+
+// Fix is a rewrite of user source files from the conventions of an
+// earlier version of gosl, or of the packages used with it, to the
+// current ones, applied by gosl fix, in the spirit of go fix.
+type Fix struct {
+
+	// name of the fix, for the -fix flag
+	Name string
+
+	// description of the fix
+	Desc string
+
+	// Fix returns the given Go source with the fix applied
+	Fix func(src []byte) []byte
+}
+
+var (
+	// fixDirectiveRe matches a //gosl: directive with a space after the
+	// //, or without a space after the colon, as in //go: directives
+	fixDirectiveRe = regexp.MustCompile(`(?m)^([ \t]*)//[ \t]*gosl:[ \t]*(\S)`)
+
+	// fixMainRe matches the //gosl: main directive of v1
+	fixMainRe = regexp.MustCompile(`(?m)^([ \t]*)//gosl: main\b`)
+
+	// fixGoslImportRe matches the import paths of the gosl packages
+	fixGoslImportRe = regexp.MustCompile(`"github\.com/(?:emer|goki)/gosl(/[^"]*)?"`)
+
+	// fixMat32ImportRe matches the import paths of the mat32 package
+	fixMat32ImportRe = regexp.MustCompile(`"(?:github\.com/goki/mat32|goki\.dev/mat32(?:/v2)?)"`)
+
+	// fixMat32NameRe matches the uses of the mat32 package
+	fixMat32NameRe = regexp.MustCompile(`\bmat32\.(\w+)`)
+
+	// fixMat32VecRe matches the vector and matrix names of mat32
+	fixMat32VecRe = regexp.MustCompile(`^(New)?(Vec|Mat)([234]i?)(Scalar)?$`)
+)
+
+// Fixes are the fixes of gosl fix, in the order they are applied
+var Fixes = []*Fix{
+	{
+		Name: "directives",
+		Desc: "//gosl: directives with the standard spacing, e.g., // gosl: start or //gosl:start, which are otherwise ignored, and //gosl: main for //gosl: hlsl",
+		Fix: func(src []byte) []byte {
+			src = fixDirectiveRe.ReplaceAll(src, []byte("${1}//gosl: ${2}"))
+			return fixMainRe.ReplaceAll(src, []byte("${1}//gosl: hlsl"))
+		},
+	},
+	{
+		Name: "imports",
+		Desc: "import paths of the gosl packages, e.g., github.com/emer/gosl/slbool and github.com/goki/gosl/slrand, to github.com/emer/gosl/v2",
+		Fix: func(src []byte) []byte {
+			return fixGoslImportRe.ReplaceAllFunc(src, func(m []byte) []byte {
+				sub := string(fixGoslImportRe.FindSubmatch(m)[1])
+				if strings.HasPrefix(string(m), `"github.com/emer/`) && (sub == "/v2" || strings.HasPrefix(sub, "/v2/")) {
+					return m
+				}
+				return []byte(`"github.com/emer/gosl/v2` + sub + `"`)
+			})
+		},
+	},
+	{
+		Name: "math32",
+		Desc: "the mat32 package to cogentcore.org/core/math32, with its renamed types and functions, e.g., mat32.Vec2 to math32.Vector2, and mat32.NewVec2 to math32.Vec2",
+		Fix: func(src []byte) []byte {
+			if !fixMat32ImportRe.Match(src) {
+				return src
+			}
+			src = fixMat32ImportRe.ReplaceAll(src, []byte(`"cogentcore.org/core/math32"`))
+			return fixMat32NameRe.ReplaceAllFunc(src, func(m []byte) []byte {
+				return []byte("math32." + FixMat32Name(string(m[len("mat32."):])))
+			})
+		},
+	},
+}
+
+// FixMat32Name returns the math32 name of the given mat32 name
+func FixMat32Name(nm string) string {
+	sm := fixMat32VecRe.FindStringSubmatch(nm)
+	if sm == nil {
+		return nm
+	}
+	kind := map[string]string{"Vec": "Vector", "Mat": "Matrix"}[sm[2]]
+	switch {
+	case sm[1] == "" && sm[4] == "": // type, e.g., Vec2
+		return kind + sm[3]
+	case sm[4] != "": // e.g., NewVec2Scalar
+		return kind + sm[3] + sm[4]
+	case sm[2] == "Vec": // constructor, e.g., NewVec2
+		return "Vec" + sm[3]
+	}
+	return nm
+}
+
+// FixSource applies the given fixes to the given Go source, returning
+// the fixed source, formatted with gofmt as go fix does (e.g., to sort
+// the fixed imports) if it parses, and the names of the fixes that
+// changed it.
+func FixSource(src []byte, fixes []*Fix) ([]byte, []string) {
+	var applied []string
+	for _, fx := range fixes {
+		fsrc := fx.Fix(src)
+		if !bytes.Equal(fsrc, src) {
+			applied = append(applied, fx.Name)
+			src = fsrc
+		}
+	}
+	if len(applied) > 0 {
+		if fsrc, err := format.Source(src); err == nil {
+			src = fsrc
+		}
+	}
+	return src, applied
+}
+
+// FixFiles returns the Go files in the given paths, which are files, or
+// directories that are walked recursively, e.g., ./..., skipping the
+// testdata, vendor, and hidden directories.
+func FixFiles(paths []string) ([]string, error) {
+	var fls []string
+	for _, p := range paths {
+		p = strings.TrimSuffix(strings.TrimSuffix(p, "..."), "/")
+		if p == "" {
+			p = "."
+		}
+		st, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !st.IsDir() {
+			fls = append(fls, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			nm := d.Name()
+			if d.IsDir() {
+				if path != p && (nm == "testdata" || nm == "vendor" || strings.HasPrefix(nm, ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(nm, ".go") {
+				fls = append(fls, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fls, nil
+}
+
+func fixUsage() {
+	fmt.Fprintf(os.Stderr, "usage: gosl fix [flags] [path ...]\n\nThe fixes are:\n\n")
+	for _, fx := range Fixes {
+		fmt.Fprintf(os.Stderr, "%s\n\t%s\n", fx.Name, fx.Desc)
+	}
+	fmt.Fprintf(os.Stderr, "\nThe flags are:\n\n")
+	flag.PrintDefaults()
+}
+
+// fixMain runs the gosl fix command with the given arguments, which
+// rewrites the Go files in the paths (the current directory tree by
+// default) from the conventions of gosl v1 to the current ones,
+// reporting each file that is fixed, with the names of the fixes, and
+// returns the exit code.
+func fixMain(args []string) int {
+	names := flag.String("fix", "", "comma-separated list of fixes to apply, instead of all of them")
+	showDiff := flag.Bool("diff", false, "print the diffs of the fixes instead of rewriting the files")
+	flag.Usage = fixUsage
+	flag.CommandLine.Parse(args)
+	fixes := Fixes
+	if *names != "" {
+		fixes = nil
+		for _, nm := range strings.Split(*names, ",") {
+			i := slices.IndexFunc(Fixes, func(fx *Fix) bool { return fx.Name == nm })
+			if i < 0 {
+				fmt.Printf("gosl fix: unknown fix %q\n", nm)
+				return 2
+			}
+			fixes = append(fixes, Fixes[i])
+		}
+	}
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"./..."}
+	}
+	fls, err := FixFiles(paths)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	code := 0
+	for _, fn := range fls {
+		src, err := os.ReadFile(fn)
+		if err != nil {
+			fmt.Println(err)
+			code = 1
+			continue
+		}
+		fsrc, applied := FixSource(src, fixes)
+		if len(applied) == 0 {
+			continue
+		}
+		if *showDiff {
+			os.Stdout.Write(diff.Diff(fn, src, fn+" (fixed)", fsrc))
+			continue
+		}
+		st, err := os.Stat(fn)
+		if err == nil {
+			err = os.WriteFile(fn, fsrc, st.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Println(err)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s: fixed %s\n", fn, strings.Join(applied, ", "))
+	}
+	return code
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFix(t *testing.T) {
+	src := `package axon
+
+import (
+	"github.com/emer/gosl/slbool"
+	"github.com/goki/gosl/slrand"
+	"github.com/emer/gosl/v2/sltype"
+	"github.com/goki/mat32"
+)
+
+// gosl: hlsl axon
+// #include "slrand.hlsl"
+//gosl:end axon
+
+//gosl: main axon
+// [numthreads(64, 1, 1)]
+//gosl: end axon
+
+// gosl: start axon
+
+type Neuron struct {
+	On  slbool.Bool
+	Pos mat32.Vec2
+	Idx mat32.Vec3i
+	Ctr sltype.Uint2
+}
+
+func (nrn *Neuron) Init() {
+	nrn.Pos = mat32.NewVec2(0, 1)
+	nrn.Pos.SetScalar(mat32.FastExp(-1))
+	nrn.Pos = mat32.NewVec2Scalar(slrand.Float(&nrn.Ctr, 0))
+}
+
+//gosl: end axon
+`
+	want := `package axon
+
+import (
+	"cogentcore.org/core/math32"
+	"github.com/emer/gosl/v2/slbool"
+	"github.com/emer/gosl/v2/slrand"
+	"github.com/emer/gosl/v2/sltype"
+)
+
+//gosl: hlsl axon
+// #include "slrand.hlsl"
+//gosl: end axon
+
+//gosl: hlsl axon
+// [numthreads(64, 1, 1)]
+//gosl: end axon
+
+//gosl: start axon
+
+type Neuron struct {
+	On  slbool.Bool
+	Pos math32.Vector2
+	Idx math32.Vector3i
+	Ctr sltype.Uint2
+}
+
+func (nrn *Neuron) Init() {
+	nrn.Pos = math32.Vec2(0, 1)
+	nrn.Pos.SetScalar(math32.FastExp(-1))
+	nrn.Pos = math32.Vector2Scalar(slrand.Float(&nrn.Ctr, 0))
+}
+
+//gosl: end axon
+`
+	got, applied := FixSource([]byte(src), Fixes)
+	if string(got) != want {
+		t.Errorf("wrong fix:\n%s\nwant:\n%s", got, want)
+	}
+	if !slices.Equal(applied, []string{"directives", "imports", "math32"}) {
+		t.Errorf("wrong fixes applied: %v", applied)
+	}
+	if _, applied := FixSource(got, Fixes); len(applied) != 0 {
+		t.Errorf("fixes applied to fixed source: %v", applied)
+	}
+	if nm := FixMat32Name("Mat4"); nm != "Matrix4" {
+		t.Errorf("FixMat32Name(Mat4): %s", nm)
+	}
+}
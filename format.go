@@ -0,0 +1,210 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Formatter formats generated shader source code for an output language.
+type Formatter interface {
+	Format(src []byte) ([]byte, error)
+}
+
+// FormatterFunc is a function that implements the Formatter interface.
+type FormatterFunc func(src []byte) ([]byte, error)
+
+func (ff FormatterFunc) Format(src []byte) ([]byte, error) {
+	return ff(src)
+}
+
+// ClangFormat is a Formatter that runs the clang-format tool at Path.
+// clang-format is run in the output directory, so it uses any
+// .clang-format style file there or in a parent directory.
+type ClangFormat struct {
+	Path string
+}
+
+func (cf *ClangFormat) Format(src []byte) ([]byte, error) {
+	// HLSL and GLSL are close enough to C++ for formatting purposes, and MSL is C++
+	cmd := exec.Command(cf.Path, "--assume-filename=shader.cpp")
+	cmd.Dir, _ = filepath.Abs(GenDir())
+	cmd.Stdin = bytes.NewReader(src)
+	var errb bytes.Buffer
+	cmd.Stderr = &errb
+	out, err := cmd.Output()
+	if err != nil {
+		return src, fmt.Errorf("%s: %w: %s", cf.Path, err, errb.String())
+	}
+	return out, nil
+}
+
+// Formatters are the named formatters that can be selected for each
+// output language target with the -format flag.  Additional formatters
+// can be added here.  Any other name is taken as a path to clang-format.
+var Formatters = map[string]Formatter{
+	"builtin": FormatterFunc(IndentBraces),
+	ToolNone:  FormatterFunc(func(src []byte) ([]byte, error) { return src, nil }),
+}
+
+// TargetFormatters are the formatters for each output language target,
+// named by the file extension (e.g., hlsl), as set by the -format flag.
+var TargetFormatters = map[string]Formatter{}
+
+// FormatTargets are the output language targets that can be formatted
+var FormatTargets = []string{"hlsl", "wgsl", "glsl", "metal"}
+
+// FormatArgs sets the TargetFormatters from the -format flag, which is
+// a comma-separated list of [target=]formatter, where a formatter
+// without a target applies to all targets.  The formatter is auto
+// (clang-format if found on the PATH, else builtin), builtin, none,
+// clang-format, or a path to clang-format.
+func FormatArgs() error {
+	for _, fs := range strings.Split(*formatSpec, ",") {
+		fs = strings.TrimSpace(fs)
+		if fs == "" {
+			continue
+		}
+		targets := FormatTargets
+		if tg, nm, ok := strings.Cut(fs, "="); ok {
+			targets = []string{strings.TrimPrefix(tg, ".")}
+			fs = nm
+		}
+		fm, err := NewFormatter(fs)
+		if err != nil {
+			return err
+		}
+		for _, tg := range targets {
+			TargetFormatters[tg] = fm
+			if tg == TargetWGSL && fs == "auto" { // clang-format does not know WGSL
+				TargetFormatters[tg] = Formatters["builtin"]
+			}
+		}
+	}
+	return nil
+}
+
+// NewFormatter returns the Formatter for given -format name
+func NewFormatter(name string) (Formatter, error) {
+	if fm, ok := Formatters[name]; ok {
+		return fm, nil
+	}
+	if name == "auto" {
+		if !*hermetic {
+			if path, err := exec.LookPath("clang-format"); err == nil {
+				return &ClangFormat{Path: path}, nil
+			}
+		}
+		return Formatters["builtin"], nil
+	}
+	if *hermetic && !filepath.IsAbs(name) {
+		return nil, fmt.Errorf("gosl: -hermetic requires -format formatters to be auto, builtin, none, or an absolute path to clang-format, not: %s", name)
+	}
+	return &ClangFormat{Path: name}, nil
+}
+
+// FormatShader formats the given generated shader source for the given
+// output language target (e.g., hlsl) with its formatter, if any.
+// If the formatter fails, the builtin formatter is used instead.
+func FormatShader(target string, src []byte) []byte {
+	fm, ok := TargetFormatters[target]
+	if !ok {
+		return src
+	}
+	out, err := fm.Format(src)
+	if err == nil {
+		return out
+	}
+	fmt.Printf("WARNING: formatting of generated %s code failed, using builtin: %v\n", target, err)
+	out, _ = IndentBraces(src)
+	return out
+}
+
+// IndentBraces is a minimal builtin formatter for C-like shader code,
+// which re-indents each line with tabs according to its brace depth,
+// removes trailing whitespace, and collapses runs of blank lines.
+// Preprocessor directives and the contents of block comments
+// are left as is.
+func IndentBraces(src []byte) ([]byte, error) {
+	nl := []byte("\n")
+	lines := bytes.Split(src, nl)
+	out := make([][]byte, 0, len(lines))
+	depth := 0
+	inBlock := false
+	blank := false
+	for _, ln := range lines {
+		if inBlock {
+			out = append(out, bytes.TrimRight(ln, " \t"))
+			inBlock = !bytes.Contains(ln, []byte("*/"))
+			continue
+		}
+		tln := bytes.TrimSpace(ln)
+		if len(tln) == 0 {
+			if !blank {
+				out = append(out, tln)
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		if tln[0] == '#' {
+			out = append(out, tln)
+			continue
+		}
+		opens, closes, leading, block := braceCounts(tln)
+		d := max(depth-leading, 0)
+		out = append(out, append(bytes.Repeat([]byte("\t"), d), tln...))
+		depth = max(depth+opens-closes, 0)
+		inBlock = block
+	}
+	return bytes.Join(out, nl), nil
+}
+
+// braceCounts returns the number of open and close braces in given
+// trimmed line, outside of comments, strings and chars, along with the
+// number of leading close braces, and whether the line ends within
+// a block comment.
+func braceCounts(ln []byte) (opens, closes, leading int, inBlock bool) {
+	atStart := true
+	for i := 0; i < len(ln); i++ {
+		c := ln[i]
+		if inBlock {
+			if c == '*' && i+1 < len(ln) && ln[i+1] == '/' {
+				inBlock = false
+				i++
+			}
+			continue
+		}
+		switch {
+		case c == '/' && i+1 < len(ln) && ln[i+1] == '/':
+			return
+		case c == '/' && i+1 < len(ln) && ln[i+1] == '*':
+			inBlock = true
+			i++
+		case c == '"' || c == '\'':
+			for i++; i < len(ln) && ln[i] != c; i++ {
+				if ln[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{':
+			opens++
+		case c == '}':
+			closes++
+			if atStart {
+				leading++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' {
+			atStart = false
+		}
+	}
+	return
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestIndentBraces(t *testing.T) {
+	src := `#ifndef __X_HLSL__
+struct Vals {
+  float A; // a { brace in a comment
+float B;
+// misplaced comment
+	float Get() {
+return this.A;   
+}
+
+
+};
+/* block comment
+      left as is */
+void F(int i) {
+if (i > 0) { i = 1; } else {
+    i = 2;
+	}
+	string s = "{";
+}
+`
+	exp := `#ifndef __X_HLSL__
+struct Vals {
+	float A; // a { brace in a comment
+	float B;
+	// misplaced comment
+	float Get() {
+		return this.A;
+	}
+
+};
+/* block comment
+      left as is */
+void F(int i) {
+	if (i > 0) { i = 1; } else {
+		i = 2;
+	}
+	string s = "{";
+}
+`
+	got, err := IndentBraces([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != exp {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, exp)
+	}
+}
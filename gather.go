@@ -0,0 +1,192 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// GatherThreads is the number of threads per workgroup in the
+// generated gather kernels.
+const GatherThreads = 64
+
+// GatherSpec specifies a generated kernel that gathers the values of one
+// variable of a range of the elements of a struct type in a buffer, e.g.,
+// the Ge of the neurons of one layer, into a compact float buffer, so that
+// a view (e.g., emergent's netview) can be updated every frame by reading
+// back only that buffer, instead of all of the elements.  The variables
+// are selected by the VarByIndex index of the -varindex type.
+type GatherSpec struct {
+
+	// the VarIndex of the struct type, e.g., Neuron
+	VarIndex *VarIndex
+
+	// HLSL definition of the type as a plain data struct, without methods,
+	// so the kernel does not depend on other generated code
+	TypeDef string
+}
+
+// NewGatherSpec returns the GatherSpec for given struct type in the
+// package, all of whose fields must be 32 bit basic types.
+func NewGatherSpec(pkg *packages.Package, typ string) (*GatherSpec, error) {
+	vi, err := NewVarIndex(pkg, typ)
+	if err != nil {
+		return nil, err
+	}
+	st := pkg.Types.Scope().Lookup(typ).Type().Underlying().(*types.Struct)
+	def, _, err := plainTypeDef(typ, st)
+	if err != nil {
+		return nil, fmt.Errorf("gosl: -gather %w", err)
+	}
+	return &GatherSpec{VarIndex: vi, TypeDef: def}, nil
+}
+
+// Type returns the name of the struct type
+func (gs *GatherSpec) Type() string {
+	return gs.VarIndex.Type
+}
+
+// Name returns the kernel name, e.g., neurongather
+func (gs *GatherSpec) Name() string {
+	return strings.ToLower(gs.Type()) + "gather"
+}
+
+// Buffers returns the names of the buffers of the kernel: the elements,
+// the parameters, and the gathered values, e.g.,
+// Neurons, NeuronGather, NeuronGatherVals
+func (gs *GatherSpec) Buffers() [3]string {
+	return [3]string{gs.Type() + "s", gs.Type() + "Gather", gs.Type() + "GatherVals"}
+}
+
+// GenGathers generates the gather kernel in the output directory, and the
+// Go file with the GatherParams type, the CPU version, and the GatherVar
+// API in the current directory, for each type in the comma-separated
+// -gather arg, returning the kernel names.  The VarByIndex Go file is also
+// generated for types that are not in -varindex.  The buffers are bound
+// as given by KernelBindings.
+func GenGathers(pkg *packages.Package, spec string) ([]string, error) {
+	vis := map[string]bool{}
+	for _, typ := range strings.Split(*varIndex, ",") {
+		vis[strings.TrimSpace(typ)] = true
+	}
+	var nms []string
+	for _, typ := range strings.Split(spec, ",") {
+		if typ = strings.TrimSpace(typ); typ == "" {
+			continue
+		}
+		gs, err := NewGatherSpec(pkg, typ)
+		if err != nil {
+			return nms, err
+		}
+		if !vis[typ] {
+			if err := GenVarIndexes(pkg, typ); err != nil {
+				return nms, err
+			}
+		}
+		nm := gs.Name()
+		bufs := gs.Buffers()
+		binds := [3][2]int(KernelBindings(nm, bufs[:]))
+		if err := WriteGenerated(filepath.Join(GenDir(), nm+".hlsl"), nm, FormatShader("hlsl", gs.HLSL(binds))); err != nil {
+			return nms, err
+		}
+		gofn := nm + ".go"
+		pnm, _ := DocPackageName(gofn)
+		src, err := gs.Go(pnm)
+		if err != nil {
+			return nms, err
+		}
+		if err := WriteGenerated(gofn, nm, src); err != nil {
+			return nms, err
+		}
+		nms = append(nms, nm)
+	}
+	return nms, nil
+}
+
+// HLSL returns the generated gather kernel source, with the buffers
+// at the given set and binding.  Each thread gathers one value.
+func (gs *GatherSpec) HLSL(binds [3][2]int) []byte {
+	var b bytes.Buffer
+	tp := gs.Type()
+	bufs := gs.Buffers()
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	b.WriteString(gs.TypeDef + "\n")
+	vi := gs.VarIndex.HLSL()
+	b.Write(vi[bytes.Index(vi, []byte("#ifndef")):])
+	fmt.Fprintf(&b, "\n// %sGatherParams are the parameters of the %s kernel\n", tp, gs.Name())
+	fmt.Fprintf(&b, "struct %sGatherParams {\n\tuint Start;\n\tuint N;\n\tint Var;\n\tint pad;\n};\n\n", tp)
+	b.WriteString("// note: binding is var, set\n")
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[0][1], binds[0][0], tp, bufs[0])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%sGatherParams> %s;\n", binds[1][1], binds[1][0], tp, bufs[1])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<float> %s;\n\n", binds[2][1], binds[2][0], bufs[2])
+	fmt.Fprintf(&b, "// each thread writes the value of the %s[0].Var variable of one of\n", bufs[1])
+	fmt.Fprintf(&b, "// the %s[0].N %s from %s[0].Start to %s.\n", bufs[1], bufs[0], bufs[1], bufs[2])
+	fmt.Fprintf(&b, "[numthreads(%d, 1, 1)]\n\n", GatherThreads)
+	b.WriteString("void main(uint3 idx : SV_DispatchThreadID) {\n")
+	fmt.Fprintf(&b, "\t%sGatherParams gp = %s[0];\n", tp, bufs[1])
+	b.WriteString("\tif (idx.x >= gp.N) {\n\t\treturn;\n\t}\n")
+	fmt.Fprintf(&b, "\t%s[idx.x] = %sVarByIndex(%s[gp.Start + idx.x], gp.Var);\n}\n", bufs[2], tp, bufs[0])
+	return b.Bytes()
+}
+
+// Go returns the generated Go source with the GatherParams type,
+// the CPU version of the kernel, and the Gather type with the
+// GatherVar API, in given package.
+func (gs *GatherSpec) Go(pkgName string) ([]byte, error) {
+	var b bytes.Buffer
+	tp := gs.Type()
+	bufs := gs.Buffers()
+	gp := tp + "GatherParams"
+	gt := tp + "Gather"
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	b.WriteString("import \"fmt\"\n\n")
+	fmt.Fprintf(&b, "// %s are the parameters of the %s kernel, in the %s\n", gp, gs.Name(), bufs[1])
+	fmt.Fprintf(&b, "// buffer: the values of the Var variable of the N %s from Start\n", bufs[0])
+	fmt.Fprintf(&b, "// are gathered into the %s buffer.\n", bufs[2])
+	fmt.Fprintf(&b, "type %s struct {\n\tStart uint32\n\tN     uint32\n\tVar   %sVar\n\tpad   int32\n}\n\n", gp, tp)
+	fmt.Fprintf(&b, "// %sCPU gathers the values of the params.Var variable of the\n", gt)
+	fmt.Fprintf(&b, "// params.N %s from params.Start into vals, as the %s kernel does\n", bufs[0], gs.Name())
+	b.WriteString("// on the GPU.\n")
+	fmt.Fprintf(&b, "func %sCPU(%s []%s, params *%s, vals []float32) {\n", gt, bufs[0], tp, gp)
+	fmt.Fprintf(&b, "\tfor i := range params.N {\n\t\tvals[i] = %sVarByIndex(&%s[params.Start+i], params.Var)\n\t}\n}\n\n", tp, bufs[0])
+	fmt.Fprintf(&b, "// %s gathers the values of one %s variable for a named range of\n", gt, tp)
+	fmt.Fprintf(&b, "// %s, e.g., the %s of a layer, for updating a view every frame\n", bufs[0], bufs[0])
+	fmt.Fprintf(&b, "// without reading back all of the %s from the GPU.\n", bufs[0])
+	fmt.Fprintf(&b, "type %s struct {\n\n", gt)
+	fmt.Fprintf(&b, "\t// Ranges are the start index and number of %s of each\n\t// range, e.g., layer, by name.\n", bufs[0])
+	b.WriteString("\tRanges map[string][2]int\n\n")
+	fmt.Fprintf(&b, "\t// Dispatch runs the %s kernel on the GPU: it uploads the params\n", gs.Name())
+	fmt.Fprintf(&b, "\t// to the %s buffer, dispatches params.N threads, and reads\n", bufs[1])
+	fmt.Fprintf(&b, "\t// back the first params.N values of the %s buffer into vals,\n", bufs[2])
+	fmt.Fprintf(&b, "\t// which must have at least MaxN values.  If nil, %sCPU is used.\n", gt)
+	fmt.Fprintf(&b, "\tDispatch func(params *%s, vals []float32) error\n\n", gp)
+	fmt.Fprintf(&b, "\t// %s are used by %sCPU if Dispatch is nil.\n", bufs[0], gt)
+	fmt.Fprintf(&b, "\t%s []%s\n\n", bufs[0], tp)
+	b.WriteString("\tvals []float32\n}\n\n")
+	fmt.Fprintf(&b, "// MaxN returns the largest number of %s in the Ranges,\n", bufs[0])
+	fmt.Fprintf(&b, "// which is the size needed for the %s buffer.\n", bufs[2])
+	fmt.Fprintf(&b, "func (g *%s) MaxN() int {\n\tn := 0\n\tfor _, r := range g.Ranges {\n\t\tn = max(n, r[1])\n\t}\n\treturn n\n}\n\n", gt)
+	fmt.Fprintf(&b, "// GatherVar returns the values of the %s variable with given name for\n", tp)
+	b.WriteString("// the range with given name, e.g., layer.  The returned slice is reused\n// by the next call.\n")
+	fmt.Fprintf(&b, "func (g *%s) GatherVar(rng, varName string) ([]float32, error) {\n", gt)
+	b.WriteString("\tr, ok := g.Ranges[rng]\n\tif !ok {\n")
+	fmt.Fprintf(&b, "\t\treturn nil, fmt.Errorf(\"%s: range not found: %%s\", rng)\n\t}\n", gt)
+	fmt.Fprintf(&b, "\tv := %sVarByName(varName)\n\tif v < 0 {\n", tp)
+	fmt.Fprintf(&b, "\t\treturn nil, fmt.Errorf(\"%s: %s variable not found: %%s\", varName)\n\t}\n", gt, tp)
+	b.WriteString("\tif n := g.MaxN(); len(g.vals) < n {\n\t\tg.vals = make([]float32, n)\n\t}\n")
+	fmt.Fprintf(&b, "\tparams := &%s{Start: uint32(r[0]), N: uint32(r[1]), Var: v}\n", gp)
+	fmt.Fprintf(&b, "\tif g.Dispatch == nil {\n\t\t%sCPU(g.%s, params, g.vals)\n", gt, bufs[0])
+	b.WriteString("\t} else if err := g.Dispatch(params, g.vals); err != nil {\n\t\treturn nil, err\n\t}\n")
+	b.WriteString("\treturn g.vals[:r[1]], nil\n}\n")
+	return format.Source(b.Bytes())
+}
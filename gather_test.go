@@ -0,0 +1,43 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGatherSpec(t *testing.T) {
+	src := "package main\n\ntype Neuron struct {\n\tGe, Act float32\n\tLayIndex uint32\n\tpad float32\n}\n"
+	pkg := testPackage(t, "neuron.go", src)
+	gs, err := NewGatherSpec(pkg, "Neuron")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.Name() != "neurongather" || gs.Buffers() != [3]string{"Neurons", "NeuronGather", "NeuronGatherVals"} {
+		t.Errorf("wrong names: %s %v", gs.Name(), gs.Buffers())
+	}
+	hlsl := string(gs.HLSL([3][2]int{{2, 0}, {7, 0}, {8, 0}}))
+	for _, want := range []string{"struct Neuron {", "float NeuronVarByIndex(in Neuron n, int idx) {", "[[vk::binding(0, 7)]] RWStructuredBuffer<NeuronGatherParams> NeuronGather;", "NeuronGatherVals[idx.x] = NeuronVarByIndex(Neurons[gp.Start + idx.x], gp.Var);"} {
+		if !strings.Contains(hlsl, want) {
+			t.Errorf("missing %q in:\n%s", want, hlsl)
+		}
+	}
+
+	// the generated Go code must compile with the type and VarByIndex code
+	vsrc, err := gs.VarIndex.Go("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gsrc, err := gs.Go("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGeneratedGo(t, pkg, map[string][]byte{"neuronvars.go": vsrc, "neurongather.go": gsrc})
+
+	if _, err := NewGatherSpec(pkg, "Layer"); err == nil {
+		t.Error("expected error for missing type")
+	}
+}
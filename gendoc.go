@@ -0,0 +1,124 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// DocPackageName returns the package name to use for a generated
+// Go file at given path: the package of other Go files in the same
+// directory if present, and otherwise the directory name.
+// The second return value is true if there are other Go files that
+// were not generated, in which case the package doc comment is left to
+// them: generated files, e.g., of a previous run with -kernelids, are
+// ignored for this, so that the output does not depend on them.
+func DocPackageName(path string) (string, bool) {
+	dir, fn := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	fls, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	pnm := ""
+	for _, gf := range fls {
+		if filepath.Base(gf) == fn || IsTestFile(gf) {
+			continue
+		}
+		af, err := parser.ParseFile(token.NewFileSet(), gf, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil || af.Name.Name == "main" && filepath.Clean(dir) == filepath.Clean(*outDir) {
+			continue // skip extracted files kept with -keep
+		}
+		if !ast.IsGenerated(af) {
+			return af.Name.Name, true
+		}
+		if pnm == "" {
+			pnm = af.Name.Name
+		}
+	}
+	if pnm != "" {
+		return pnm, false
+	}
+	ad, _ := filepath.Abs(dir)
+	nm := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return -1
+	}, filepath.Base(ad))
+	return nm, false
+}
+
+// KernelConstName returns the exported Go name for given kernel name
+func KernelConstName(name string) string {
+	nm := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	return "Kernel" + strings.ToUpper(nm[:1]) + nm[1:]
+}
+
+// GenKernelDoc generates the Go documentation file summarizing all
+// of the generated Kernels, written to the given path.
+// Each kernel is documented as a constant holding the path to its
+// compiled .spv file relative to where gosl is run.
+func GenKernelDoc(path string) error {
+	pnm, hasOther := DocPackageName(path)
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	if !hasOther {
+		fmt.Fprintf(&b, "// Package %s contains GPU compute kernels generated by gosl.\n// See the Kernel constants for each kernel's entry point,\n// workgroup size, buffers, and source files.\n", pnm)
+	}
+	fmt.Fprintf(&b, "package %s\n\n", pnm)
+	ks := SortedKernels()
+	if len(ks) > 0 {
+		b.WriteString("// GPU compute kernels generated by gosl, as the path to the compiled\n// SPIR-V file for each kernel, relative to where gosl was run.\nconst (\n")
+		for i, k := range ks {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			k.WriteDoc(&b, "\t")
+			fmt.Fprintf(&b, "\t%s = %q\n", KernelConstName(k.Name), filepath.ToSlash(filepath.Join(*outDir, OutputPath(k.Name)+".spv")))
+		}
+		b.WriteString(")\n")
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	return WriteGenerated(path, "kerneldoc", src)
+}
+
+// WriteDoc writes the doc comment lines for the kernel, with given indent
+func (k *Kernel) WriteDoc(b *bytes.Buffer, ind string) {
+	fmt.Fprintf(b, "%s// %s is the %s kernel, with entry point %s\n", ind, KernelConstName(k.Name), k.Name, k.Entry)
+	fmt.Fprintf(b, "%s// and workgroup size [%d, %d, %d].\n", ind, k.Workgroup[0], k.Workgroup[1], k.Workgroup[2])
+	if len(k.Sources) > 0 {
+		fmt.Fprintf(b, "%s// Sources: %s.\n", ind, filepath.ToSlash(strings.Join(k.Sources, ", ")))
+	}
+	if k.Bounds != "" {
+		fmt.Fprintf(b, "%s// Threads with %s.x >= the number of %s elements return early,\n%s// so the number of workgroups can be rounded up.\n", ind, k.Index, k.Bounds, ind)
+	}
+	if len(k.Buffers) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s// Buffers:\n", ind)
+	for _, bf := range k.Buffers {
+		tp := bf.Kind
+		if bf.Type != "" {
+			tp += "<" + bf.Type + ">"
+		}
+		fmt.Fprintf(b, "%s//   - %s: %s, set %d, binding %d (%s)\n", ind, bf.Name, tp, bf.Set, bf.Binding, bf.Access)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/emer/gosl/v2/slprint"
+)
+
+// InitGlobalsRegions returns the regions whose init functions are called
+// by the kernels of the given region (see slprint.InitGlobalsFunc): the
+// regions that it uses, recursively, in the order of their includes,
+// and then the region itself, for those whose generated source in the
+// given regions defines one.
+func InitGlobalsRegions(fn string, regions map[string][]byte) []string {
+	var rgs []string
+	var visit func(rg string)
+	visit = func(rg string) {
+		if slices.Contains(rgs, rg) {
+			return
+		}
+		for _, urg := range RegionUses[rg] {
+			visit(urg)
+		}
+		rgs = append(rgs, rg)
+	}
+	visit(fn)
+	return slices.DeleteFunc(rgs, func(rg string) bool {
+		return !bytes.Contains(regions[rg], []byte("void "+slprint.InitGlobalsFunc(rg)+"()"))
+	})
+}
+
+// CallInitGlobals returns the given source of the file of the given
+// kernel with calls of the init functions of its InitGlobalsRegions at
+// the start of its entry point, so that the package-level variables that
+// they initialize have their values, as after the package init in Go,
+// and true, or false if there are none.
+func CallInitGlobals(k *Kernel, src []byte, regions map[string][]byte) ([]byte, bool) {
+	rgs := InitGlobalsRegions(k.File, regions)
+	if len(rgs) == 0 {
+		return src, false
+	}
+	loc := regexp.MustCompile(`\bvoid\s+` + regexp.QuoteMeta(k.Entry) + `\s*\([^)]*SV_DispatchThreadID[^)]*\)\s*\{`).FindIndex(src)
+	if loc == nil {
+		return src, false
+	}
+	var pro bytes.Buffer
+	for _, rg := range rgs {
+		fmt.Fprintf(&pro, "\n\t%s();", slprint.InitGlobalsFunc(rg))
+	}
+	return slices.Concat(src[:loc[1]], pro.Bytes(), src[loc[1]:]), true
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCallInitGlobals(t *testing.T) {
+	t.Cleanup(func() { RegionUses = map[string][]string{} })
+	RegionUses = map[string][]string{"axon": {"chans", "globals"}, "chans": {"globals"}}
+	regions := map[string][]byte{
+		"globals": []byte("static float Offset;\n\nvoid GoslInitGlobals_globals() {\n\tOffset = Bias(3);\n}\n"),
+		"chans":   []byte("float Chan() {\n\treturn Offset;\n}\n"),
+		"axon":    []byte("static float Gain;\n\nvoid GoslInitGlobals_axon() {\n\tGain = Chan();\n}\n"),
+	}
+	if got := strings.Join(InitGlobalsRegions("axon", regions), " "); got != "globals axon" {
+		t.Errorf("wrong regions: %s", got)
+	}
+	src := "[numthreads(64, 1, 1)]\nvoid main(uint3 idx : SV_DispatchThreadID) {\n\tData[idx.x] = Gain;\n}\n"
+	out, ok := CallInitGlobals(ParseKernel("axon", []byte(src)), []byte(src), regions)
+	want := "void main(uint3 idx : SV_DispatchThreadID) {\n\tGoslInitGlobals_globals();\n\tGoslInitGlobals_axon();\n\tData[idx.x]"
+	if !ok || !strings.Contains(string(out), want) {
+		t.Errorf("init calls not found in:\n%s", out)
+	}
+	if _, ok := CallInitGlobals(ParseKernel("chans", []byte(src)), []byte(src), map[string][]byte{"chans": regions["chans"]}); ok {
+		t.Error("init calls added without init functions")
+	}
+}
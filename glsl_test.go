@@ -0,0 +1,14 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestGLSL(t *testing.T) {
+	tg := *target
+	*target = TargetGLSL
+	defer func() { *target = tg }()
+	runTest(t, "testdata/glsl/basic.go", "testdata/glsl/basic.golden")
+}
@@ -4,6 +4,7 @@ go 1.22
 
 require (
 	cogentcore.org/core v0.1.3-0.20240501194413-e11b28b7c75f
+	github.com/chewxy/math32 v1.10.1
 	golang.org/x/tools v0.19.0
 )
 
@@ -12,7 +13,6 @@ require (
 	github.com/Masterminds/vcs v1.13.3 // indirect
 	github.com/anthonynsimon/bild v0.13.0 // indirect
 	github.com/aymerick/douceur v0.2.0 // indirect
-	github.com/chewxy/math32 v1.10.1 // indirect
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20240307211618-a69d953ea142 // indirect
 	github.com/goki/freetype v1.0.5 // indirect
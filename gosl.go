@@ -17,14 +17,54 @@ import (
 	"strings"
 
 	"github.com/emer/gosl/v2/slprint"
+	"github.com/emer/gosl/v2/slreload"
 )
 
 // flags
 var (
-	outDir        = flag.String("out", "shaders", "output directory for shader code, relative to where gosl is invoked -- must not be an empty string")
+	outDir        = flag.String("out", "shaders", "output directory for shader code, relative to the package directory: where gosl is run, e.g., by go generate, or -chdir -- must not be an empty string")
+	chdir         = flag.String("chdir", "", "if set, change to this package directory before doing anything else, as with go -C, e.g., to run gosl from a Makefile in another directory: the path args, -out, and the other file flags are then relative to it")
+	target        = flag.String("target", TargetHLSL, "shader language to generate: hlsl, wgsl, glsl, or metal -- only hlsl is compiled, and supports all of the flags")
+	hlslVersion   = flag.String("hlsl", HLSL2018, "HLSL language version of the generated code: 2018 (the default, which glslc and older versions of dxc also compile) or 2021 (compiled by dxc with -HV 2021), which translates generic functions into templates instead of instantiating them, and defines operators for the Add, Sub, Mul and Div methods of structs, which are used for their calls")
 	excludeFuns   = flag.String("exclude", "Update,Defaults", "comma-separated list of names of functions to exclude from exporting to HLSL")
 	keepTmp       = flag.Bool("keep", false, "keep temporary converted versions of the source files, for debugging")
+	keepOnError   = flag.Bool("keep-on-error", false, "keep the partial outputs of a failed generation in its "+StagingPrefix+"* staging directory within the output directory, for debugging -- the output directory itself is only updated when generation succeeds")
 	debug         = flag.Bool("debug", false, "enable debugging messages while running")
+	tests         = flag.Bool("tests", false, "include _test.go files, for test-only kernels that should not be part of production shader outputs -- typically used with a different -out directory")
+	goimportsPath = flag.String("goimports", "goimports", "path to the goimports tool used on the extracted Go code -- set to none to instead use the imports from the source files")
+	dxcPath       = flag.String("dxc", "dxc", "path to the dxc HLSL compiler -- set to none to skip compiling to .spv")
+	requireDxc    = flag.Bool("require-dxc", false, "exit with an error if the dxc compiler is not found, instead of generating the HLSL code only, with a warning, keeping the previous .spv files, which are recorded as stale or missing in the -manifest")
+	cacheDir      = flag.String("cache", "", "GOCACHE directory to use for loading packages -- uses the go default if empty")
+	hermetic      = flag.Bool("hermetic", false, "hermetic build mode (e.g., for Bazel or please): tools must be absolute paths or none, no module downloads, and all outputs must be declared in -outputs")
+	outputs       = flag.String("outputs", "", "comma-separated list of output files relative to -out, which must exactly match those generated in -hermetic mode")
+	docFile       = flag.String("doc", "", "if set, Go file to write documentation of the generated kernels and buffers to, e.g., shaders/doc.go or gpu_doc.go in the model package")
+	kernelIDs     = flag.String("kernelids", "", "if set, Go file to write a KernelID enum and KernelPipelines registry for the generated kernels to, with matching constants in kernelids.hlsl in the output directory")
+	budgetFile    = flag.String("budget", "", "if set, Go file to write a MemoryBudget function to, e.g., gpu_budget.go in the model package, which computes the GPU memory required for the buffers of all kernels given the number of elements of each, with a report to check against device limits")
+	validateFile  = flag.String("validate", "", "if set, Go file to write Validate methods to, e.g., gpu_validate.go in the model package, for the struct types with min / max field tags, e.g., `min:\"1\" max:\"10\"` -- also adds a prologue to the kernels that clamps the tagged fields of the buffer elements indexed by a constant or the thread index to their bounds, for debug builds")
+	statsSpec     = flag.String("stats", "", "if set, generates a kernel computing the mean and max of selected float32 fields of a struct type for each value of an integer group field, specified as Type.GroupField:Field1,Field2,... e.g., Neuron.LayIndex:Act,Ge,Vm,CaSpkP -- writes <type>stats.hlsl in the output directory and <type>stats.go with the Go stats type")
+	activeSpec    = flag.String("active", "", "if set, Type.FlagsField:Mask, e.g., Neuron.Flags:NeuronOff, for which to generate a kernel that lists the indexes of the elements with none of the mask bits set")
+	varIndex      = flag.String("varindex", "", "if set, comma-separated list of struct types, e.g., Neuron,Synapse, for which to generate a <Type>VarByIndex HLSL function returning the field with a given index as a float, for generic monitoring kernels, with the index constants, e.g., NeuronVarGe -- writes <type>vars.hlsl in the output directory, to be included after the type, and <type>vars.go with the matching Go constants")
+	gatherTypes   = flag.String("gather", "", "if set, comma-separated list of struct types, e.g., Neuron, for which to generate a kernel that gathers one -varindex variable of a range of elements")
+	sparseSpec    = flag.String("sparse", "", "if set, Type:Field1,Field2,... e.g., Neuron:Act,Ge,Spike, for which to generate a kernel that reads back only the elements whose selected fields changed")
+	manifestFile  = flag.String("manifest", "", "if set, JSON file to write the interface manifest of the generated GPU code to: struct layouts of the buffer types, and kernel entry points and bindings -- store it with each release, and check later versions against it with: gosl compat -against <manifest> [path ...], which reports breaking changes and exits with an error if there are any")
+	metaFile      = flag.String("meta", "", "if set, Go file to write the metadata of the generated kernels to, e.g., gosl_meta.go in the model package: a GetMeta function returning the kernels, their buffers and workgroup sizes, and the layouts of the buffer struct types with hashes, as an slmeta.Meta for querying at runtime, and the same as JSON in a GoslMetaJSON constant")
+	formatSpec    = flag.String("format", "auto", "formatter for the generated shader code, as a comma-separated list of [target=]formatter, e.g., hlsl=builtin -- the formatter is auto (clang-format if found on the PATH, else builtin), builtin (a minimal brace indenter), none, or a path to clang-format")
+	autotune      = flag.String("autotune", "", "comma-separated list of workgroup sizes, e.g., 32,64,128,256, for which to generate a variant of each 1D kernel, e.g., axon_wg64.hlsl, for autotuning the workgroup size on the current device with the sltune package")
+	pressure      = flag.Int("pressure", 0, "if > 0, estimate the register pressure of the kernels and functions as the peak number of live 32-bit values, and report those exceeding this number, with suggestions for splitting them into phases")
+	repro         = flag.Bool("repro", false, "reproducibility mode: compile with IEEE strictness (dxc -Gis), generate a KernelInvocations dispatch counter with -kernelids, and write a "+ReproManifestFile+" in the output directory with everything that could affect results")
+	watch         = flag.Bool("watch", false, "keep running, and regenerate whenever any of the source files change, writing "+slreload.SignalFile+" in the output directory after each regeneration, for hot-reloading of the shaders into a running process with the slreload package")
+	compare       = flag.Bool("compare", false, "if set, writes "+CompareFile+" in the directory of the //gosl: cpukernel CPU functions, with a RegisterCompareElements function that registers the functions of the 1D kernels for one thread index on an slcpu.Comparer, which runs them every N dispatches on a random sample of the elements and logs their drift from the GPU results")
+	subRange      = flag.String("subrange", "", "if set, comma-separated list of 1D kernels, or all, for which to generate a <kernel>_range variant that only runs on a range of the elements")
+	boundsCheck   = flag.Bool("boundscheck", true, "add an early exit prologue to 1D kernels: if (idx.x >= n) return; where n is the number of elements of the first buffer indexed by idx.x, so that the number of elements does not need to be a multiple of the workgroup size -- kernels that already compare idx.x are not changed")
+	configFile    = flag.String("config", "", "gosl config file, in JSON, with per-project replacement rules for the functions and types of other packages in the HLSL code, e.g., {\"Replace\": {\"Funcs\": {\"mymath.Exp\": \"exp\"}, \"Types\": {\"mymath.Vec4\": \"float4\"}}} -- uses "+DefaultConfigFile+" in the current directory if not set and it exists")
+	subgroups     = flag.Bool("subgroups", false, "allow the slwave subgroup (wave) functions, e.g., slwave.ActiveSum, which require a device and target that support subgroup operations: HLSL shader model 6.0, Vulkan 1.1 subgroups for GLSL, the subgroups feature of WebGPU for WGSL, or the SIMD-group functions of MSL -- adds the GLSL extensions or WGSL enable directive for them to the files with an entry point")
+	vectorize     = flag.Bool("vectorize", false, "pack runs of 2-4 adjacent statements that apply the same arithmetic operation to different float fields of the same variable, e.g., exponential decay updates, into float2-4 vector operations in the generated shader code")
+	vgpuVersion   = flag.String("vgpu", VgpuCurrent, "vgpu API version targeted by the generated Go code that calls vgpu, for users of older vgpu releases: core (cogentcore.org/core/vgpu) or goki (github.com/goki/vgpu/vgpu)")
+	verbose       = flag.Bool("v", false, "verbose mode: report the progress of the run on stderr, with the number of files processed and kernels compiled in each stage, and the elapsed time and estimated time remaining")
+	reportFile    = flag.String("report", "", "if set, JSON file to write a build report to, with the number of files and kernels and the time taken by each stage of the run, for profiling the generation of large packages")
+	only          = flag.String("only", "", "if set, comma-separated list of kernels or regions, e.g., axon, to compile with dxc, skipping the others, whose previously compiled .spv files in the output directory are kept -- for fast edit-compile cycles on one kernel in a package with many")
+	strict        = flag.Bool("strict", false, "strict mode: exit with an error if any -exclude function name was never encountered, e.g., due to a typo, or if any //gosl: cpukernel CPU function does not match its kernel")
+	checkSubset   = flag.Bool("check", false, "check that the code in the gosl regions only uses the supported subset of Go in SUBSET.md, and exit with an error if not")
 	excludeFunMap = map[string]bool{}
 )
 
@@ -41,17 +81,24 @@ const (
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: gosl [flags] [path ...]\n")
+	fmt.Fprintf(os.Stderr, "usage: gosl [flags] [path ...]\n       gosl [flags] ./...\n       gosl compat -against <manifest> [flags] [path ...]\n       gosl fix [flags] [path ...]\n")
 	flag.PrintDefaults()
 }
 
 func main() {
 	flag.Usage = usage
+	if len(os.Args) > 1 && os.Args[1] == "compat" {
+		os.Exit(compatMain(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fix" {
+		os.Exit(fixMain(os.Args[2:]))
+	}
 	flag.Parse()
 	goslMain()
 }
 
 func GoslArgs() {
+	clear(excludeFunMap)
 	exs := *excludeFuns
 	ex := strings.Split(exs, ",")
 	for _, fn := range ex {
@@ -59,13 +106,49 @@ func GoslArgs() {
 	}
 }
 
+// ProcessArgs processes all of the flags after they are set.
+func ProcessArgs() error {
+	GoslArgs()
+	if err := HermeticArgs(); err != nil {
+		return err
+	}
+	if err := FormatArgs(); err != nil {
+		return err
+	}
+	if err := VgpuArgs(); err != nil {
+		return err
+	}
+	if err := ConfigArgs(); err != nil {
+		return err
+	}
+	return CheckTarget()
+}
+
 func goslMain() {
+	if err := Chdir(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if args := flag.Args(); IsWorkspace(args) {
+		if *watch {
+			fmt.Println("gosl: -watch cannot be used in workspace mode")
+			os.Exit(1)
+		}
+		if *only != "" {
+			fmt.Println("gosl: -only cannot be used in workspace mode")
+			os.Exit(1)
+		}
+		if err := GenerateWorkspace(args[0]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 	if *outDir == "" {
 		fmt.Printf("Must have an output directory (default shaders), specified in -out arg\n")
 		return
 	}
 	os.MkdirAll(*outDir, 0755)
-	RemoveGenFiles(*outDir)
 
 	args := flag.Args()
 	if len(args) == 0 {
@@ -73,6 +156,106 @@ func goslMain() {
 		return
 	}
 
-	GoslArgs()
-	ProcessFiles(args)
+	if err := ProcessArgs(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *watch && *hermetic {
+		fmt.Println("gosl: -watch cannot be used with -hermetic")
+		os.Exit(1)
+	}
+	if err := Generate(args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *watch {
+		Watch(args)
+	}
+}
+
+// Generate generates all of the outputs from the given paths,
+// returning an error for the conditions that cause gosl to exit
+// with an error: processing or compiling errors, -strict checks,
+// and -hermetic outputs.  The shader outputs are generated in a
+// staging directory, and only replace the previous ones in the
+// output directory if there are no errors.
+func Generate(args []string) error {
+	progress.Start()
+	if err := BeginOutputs(); err != nil {
+		return err
+	}
+	err := EndOutputs(generate(args))
+	if *reportFile != "" {
+		if rerr := progress.WriteReport(*reportFile); rerr != nil {
+			fmt.Println(rerr)
+		}
+	} else {
+		progress.End()
+	}
+	return err
+}
+
+func generate(args []string) error {
+	if _, err := ProcessFiles(args); err != nil {
+		return err
+	}
+	progress.Kernels = len(Kernels)
+	progress.Stage("outputs", 0)
+	var kferr error
+	if *target == TargetHLSL {
+		kferr = CheckKernelFuncs(FilesFromPaths(args))
+	}
+	if *strict {
+		if err := CheckExcludes(); err != nil {
+			return err
+		}
+		if kferr != nil {
+			return kferr
+		}
+	}
+	if *docFile != "" {
+		if err := GenKernelDoc(*docFile); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if *kernelIDs != "" {
+		if err := GenKernelIDs(*kernelIDs); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if *budgetFile != "" {
+		if err := GenMemoryBudget(*budgetFile); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if *manifestFile != "" {
+		if err := GenInterfaceManifest(*manifestFile); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if *metaFile != "" {
+		if err := GenMeta(*metaFile); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if err := GenBindings(FilesFromPaths(args)); err != nil {
+		fmt.Println(err)
+	}
+	if err := GenPipelines(FilesFromPaths(args)); err != nil {
+		fmt.Println(err)
+	}
+	if *compare {
+		if err := GenCompare(FilesFromPaths(args)); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if *repro {
+		if err := GenReproManifest(FilesFromPaths(args)); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if err := MapOutputs(); err != nil {
+		return err
+	}
+	return CheckOutputs()
 }
@@ -19,15 +19,18 @@ var update = flag.Bool("update", false, "update .golden files")
 
 func runTest(t *testing.T, in, out string) {
 	// process flags
+	gi := *goimportsPath
+	*goimportsPath = ToolNone // the goldens do not depend on goimports
+	defer func() { *goimportsPath = gi }()
 	_, err := os.Lstat(in)
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
-	sls, err := ProcessFiles([]string{in})
-	if err != nil {
-		t.Error(err)
+	sls, terr := ProcessFiles([]string{in})
+	if terr != nil && sls == nil {
+		t.Error(terr)
 		return
 	}
 
@@ -44,6 +47,9 @@ func runTest(t *testing.T, in, out string) {
 		got = b
 		break
 	}
+	if terr != nil { // expected translation errors are part of the golden output
+		got = append(got, transErrorsComment(terr)...)
+	}
 
 	if !bytes.Equal(got, expected) {
 		if *update {
@@ -82,6 +88,9 @@ func TestRewrite(t *testing.T) {
 	if *outDir != "" {
 		os.MkdirAll(*outDir, 0755)
 	}
+	dxc := *dxcPath
+	*dxcPath = ToolNone // only the HLSL is compared
+	defer func() { *dxcPath = dxc }()
 
 	for _, in := range match {
 		name := filepath.Base(in)
@@ -94,3 +103,17 @@ func TestRewrite(t *testing.T) {
 		})
 	}
 }
+
+// transErrorsComment returns the given translation errors as a comment,
+// with the file names of their positions, without directory.
+func transErrorsComment(err error) []byte {
+	var b bytes.Buffer
+	b.WriteString("\n// gosl errors:\n")
+	for _, ln := range strings.Split(err.Error(), "\n") {
+		if i := strings.Index(ln, ": gosl:"); i >= 0 {
+			ln = filepath.Base(ln[:i]) + ln[i:]
+		}
+		b.WriteString("// " + ln + "\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package goslrun is a minimal runtime for the kernels generated by gosl,
+which replaces the GPU setup code otherwise needed in each program with
+a small facade on top of an slgpu.Runtime:
+
+	run := goslrun.New("basic")
+	run.Buffer("Params", params)
+	run.Buffer("Data", data)
+	run.Kernel("basic.spv").Dispatch(n)
+	run.Read("Data", data)
+	run.Release()
+
+Each buffer is a slice of values, or a pointer to a single value, e.g.,
+a struct matching the HLSL buffer element type.  By default, each
+buffer is in its own descriptor set, in order, at binding 0, as in:
+
+	[[vk::binding(0, 0)]] RWStructuredBuffer<ParamStruct> Params;
+	[[vk::binding(0, 1)]] RWStructuredBuffer<DataStruct> Data;
+
+Use BufferAt for other bindings.  All of the buffers and kernels must be
+added before the first Dispatch, which configures the runtime and uploads
+all of the buffers.  Use Upload to upload a buffer again after changing
+its values on the CPU, and Read to read it back after dispatching.
+
+The kernels are compiled with a fixed number of threads per workgroup,
+e.g., [numthreads(64, 1, 1)], which must be the Threads of the Run, but
+the best number differs across devices (e.g., 64 on AMD vs. 32 on NVIDIA).
+For a kernel with -autotune variants, KernelVariants uses the variant with
+the number of threads recommended for the device by slgpu.Device.Threads:
+
+	run.KernelVariants("axon.spv", KernelVariants[KernelIDAxon]).Dispatch(n)
+
+The GPU runtime is the Default one, "vgpu", which must be registered by
+importing its package, as the examples do:
+
+	import _ "github.com/emer/gosl/v2/slgpu/slvgpu"
+
+Each Run has its own runtime, buffers and kernels, and the code generated
+by gosl has no package-level mutable state, so any number of independent
+Runs can be used at the same time, e.g., by the workers of a parameter
+sweep, each in its own goroutine, but each Run must only be used by one
+goroutine at a time.
+
+The first error is recorded, and returned by Dispatch, Read and Err,
+so that the setup calls can be chained without checking each one.
+*/
+package goslrun
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/gosl/v2/slgpu"
+	"github.com/emer/gosl/v2/sltune"
+)
+
+// Default is the name of the slgpu runtime used by New
+var Default = "vgpu"
+
+// Run runs the kernels of a program on the GPU
+type Run struct {
+
+	// name of the program, for error messages
+	Name string
+
+	// directory of the compiled kernel files
+	Dir string
+
+	// number of threads per workgroup of the kernels, for Dispatch
+	Threads int
+
+	// the GPU runtime
+	Runtime slgpu.Runtime
+
+	buffers    []*buffer
+	kernels    map[string]*Kernel
+	configured bool
+	err        error
+}
+
+// buffer is a buffer with the CPU values that it is uploaded from
+type buffer struct {
+	slgpu.BufferSpec
+	data any
+}
+
+// Kernel is a kernel of a Run
+type Kernel struct {
+
+	// name of the kernel: the file name without the extension
+	Name string
+
+	// number of threads per workgroup, for Dispatch
+	Threads int
+
+	run *Run
+}
+
+// New returns a new Run for the program with the given name, on the
+// Default GPU runtime, with kernels in the shaders directory, and 64
+// threads per workgroup, as generated by gosl.
+func New(name string) *Run {
+	rt, err := slgpu.New(Default)
+	run := NewRuntime(name, rt)
+	run.setError(err)
+	return run
+}
+
+// NewRuntime returns a new Run for the program with the given name,
+// on the given GPU runtime, e.g., from slgpu.New.
+func NewRuntime(name string, rt slgpu.Runtime) *Run {
+	return &Run{Name: name, Dir: "shaders", Threads: 64, Runtime: rt, kernels: map[string]*Kernel{}}
+}
+
+// Err returns the first error, if any
+func (run *Run) Err() error {
+	return run.err
+}
+
+// setError records the given error if it is the first one
+func (run *Run) setError(err error) {
+	if err != nil && run.err == nil {
+		run.err = fmt.Errorf("goslrun %s: %w", run.Name, err)
+	}
+}
+
+// Buffer adds a buffer with the given name and values, which must be
+// a slice of values, or a pointer to a single value, at binding 0 of
+// the next descriptor set.
+func (run *Run) Buffer(name string, data any) *Run {
+	return run.BufferAt(name, len(run.buffers), 0, data)
+}
+
+// BufferAt adds a buffer with the given name and values, which must be
+// a slice of values, or a pointer to a single value, at the given
+// descriptor set and binding, as in [[vk::binding(binding, set)]].
+func (run *Run) BufferAt(name string, set, binding int, data any) *Run {
+	if run.configured {
+		run.setError(fmt.Errorf("buffer %s must be added before the first Dispatch", name))
+		return run
+	}
+	sz, n := slgpu.AnyShape(data)
+	run.buffers = append(run.buffers, &buffer{BufferSpec: slgpu.BufferSpec{Name: name, Set: set, Binding: binding, ElemSize: sz, N: n}, data: data})
+	return run
+}
+
+// Kernel returns the kernel from the given compiled kernel file in
+// Dir, e.g., basic.spv, adding it if it has not already been added.
+func (run *Run) Kernel(file string) *Kernel {
+	return run.addKernel(file, 0)
+}
+
+// KernelVariants returns the kernel from the given compiled kernel file
+// in Dir, e.g., axon.spv, adding it if it has not already been added,
+// using its variant with the number of threads recommended for the device
+// by slgpu.Device.Threads, among the given sizes of its -autotune
+// variants, e.g., KernelVariants[KernelIDAxon] as generated with
+// -kernelids.  The kernel itself is used if there are no sizes.
+func (run *Run) KernelVariants(file string, sizes []int) *Kernel {
+	if len(sizes) == 0 {
+		return run.addKernel(file, 0)
+	}
+	dev := run.Device()
+	return run.addKernel(file, dev.Threads(sizes...))
+}
+
+// Device returns the properties of the GPU device of the runtime,
+// which are all unknown (zero) if the runtime does not report them.
+func (run *Run) Device() slgpu.Device {
+	return slgpu.DeviceOf(run.Runtime)
+}
+
+// addKernel adds the kernel from the given compiled kernel file, or its
+// variant with the given number of threads if > 0.
+func (run *Run) addKernel(file string, threads int) *Kernel {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if k, ok := run.kernels[name]; ok {
+		return k
+	}
+	k := &Kernel{Name: name, Threads: run.Threads, run: run}
+	run.kernels[name] = k
+	if run.configured {
+		run.setError(fmt.Errorf("kernel %s must be added before the first Dispatch", name))
+		return k
+	}
+	path := filepath.Join(run.Dir, file)
+	if threads > 0 {
+		k.Threads = threads
+		path = sltune.VariantSPV(path, threads)
+	}
+	if run.err == nil {
+		run.setError(run.Runtime.AddKernel(name, path))
+	}
+	return k
+}
+
+// Config creates all of the buffers, configures the runtime, and uploads
+// all of the buffers.  It is called by the first Dispatch if needed.
+func (run *Run) Config() error {
+	if run.configured || run.err != nil {
+		return run.err
+	}
+	run.configured = true
+	for _, bf := range run.buffers {
+		run.setError(run.Runtime.CreateBuffer(bf.Name, bf.Set, bf.Binding, bf.ElemSize, bf.N))
+	}
+	if run.err != nil {
+		return run.err
+	}
+	run.setError(run.Runtime.Config())
+	for _, bf := range run.buffers {
+		if run.err != nil {
+			break
+		}
+		run.setError(run.Runtime.Upload(bf.Name, slgpu.AnyBytes(bf.data)))
+	}
+	return run.err
+}
+
+// Upload uploads the current values of the buffer with the given name,
+// after any pending dispatches have completed.
+func (run *Run) Upload(name string) error {
+	if err := run.Config(); err != nil {
+		return err
+	}
+	bf := run.buffer(name)
+	if bf == nil {
+		return fmt.Errorf("goslrun %s: buffer %s not found", run.Name, name)
+	}
+	return run.Runtime.Upload(name, slgpu.AnyBytes(bf.data))
+}
+
+// Read reads back the buffer with the given name into the given values,
+// which are typically those that it was added with, after any pending
+// dispatches have completed.
+func (run *Run) Read(name string, data any) error {
+	if err := run.Config(); err != nil {
+		return err
+	}
+	b := slgpu.AnyBytes(data)
+	if err := run.Runtime.Readback(name, b); err != nil {
+		return err
+	}
+	slgpu.CopyFromAnyBytes(data, b)
+	return nil
+}
+
+// buffer returns the buffer with the given name, or nil if none
+func (run *Run) buffer(name string) *buffer {
+	for _, bf := range run.buffers {
+		if bf.Name == name {
+			return bf
+		}
+	}
+	return nil
+}
+
+// Release releases all of the GPU resources
+func (run *Run) Release() {
+	if run.Runtime != nil {
+		run.Runtime.Release()
+	}
+}
+
+// Dispatch runs the kernel on at least n threads, with enough
+// workgroups of Threads threads each, configuring the Run if needed.
+// The kernel must return early for thread indexes >= n, as the kernels
+// generated by gosl do by default.
+func (k *Kernel) Dispatch(n int) error {
+	return k.Dispatch3((n+k.Threads-1)/k.Threads, 1, 1)
+}
+
+// DispatchCount runs the kernel on the number of threads in the first
+// value of the given uint32 count buffer, e.g., NeuronActiveN as
+// written by a kernel generated with gosl -active, which is read back
+// first.  It does nothing if the count is 0.
+func (k *Kernel) DispatchCount(countBuffer string) error {
+	n := []uint32{0}
+	if err := k.run.Read(countBuffer, n); err != nil {
+		return err
+	}
+	if n[0] == 0 {
+		return nil
+	}
+	return k.Dispatch(int(n[0]))
+}
+
+// Dispatch3 runs the kernel on the given number of workgroups in each
+// dimension, configuring the Run if needed.
+func (k *Kernel) Dispatch3(nx, ny, nz int) error {
+	if err := k.run.Config(); err != nil {
+		return err
+	}
+	return k.run.Runtime.Dispatch(k.Name, nx, ny, nz)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goslrun
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/emer/gosl/v2/slgpu/slcpu"
+)
+
+type params struct {
+	Gain float32
+
+	pad, pad1, pad2 float32
+}
+
+type data struct {
+	Raw, Out float32
+
+	pad, pad1 float32
+}
+
+// cpuRuntime returns a new slcpu runtime with the Go versions of the
+// scale kernel, and of the counted kernel, which sets ngroups.
+func cpuRuntime(ngroups *int) *slcpu.Runtime {
+	rt := slcpu.New()
+	rt.RegisterKernel("scale", func(bufs *slcpu.Buffers, groups [3]int) {
+		ps := slcpu.Slice[params](bufs, "Params")
+		ds := slcpu.Slice[data](bufs, "Data")
+		for i := range ds {
+			ds[i].Out = ps[0].Gain * ds[i].Raw
+		}
+		slcpu.Store(bufs, "Data", ds)
+	})
+	rt.RegisterKernel("counted", func(bufs *slcpu.Buffers, groups [3]int) {
+		*ngroups = groups[0]
+	})
+	return rt
+}
+
+func TestRun(t *testing.T) {
+	Default = "cpu"
+	defer func() { Default = "vgpu" }()
+	if run := New("default"); run.Err() != nil {
+		t.Error(run.Err())
+	}
+
+	ngroups := 0
+	pars := &params{Gain: 2}
+	ds := []data{{Raw: 1}, {Raw: 2}, {Raw: 3}}
+	run := NewRuntime("test", cpuRuntime(&ngroups))
+	run.Buffer("Params", pars).Buffer("Data", ds)
+	if err := run.Kernel("scale.spv").Dispatch(len(ds)); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Read("Data", ds); err != nil {
+		t.Fatal(err)
+	}
+	if ds[2].Out != 6 {
+		t.Errorf("Out: got %g, want 6", ds[2].Out)
+	}
+
+	pars.Gain = 3
+	if err := run.Upload("Params"); err != nil {
+		t.Fatal(err)
+	}
+	run.Kernel("scale.spv").Dispatch(len(ds))
+	run.Read("Data", ds)
+	if ds[2].Out != 9 {
+		t.Errorf("Out after Upload: got %g, want 9", ds[2].Out)
+	}
+
+	count := []uint32{0}
+	crun := NewRuntime("count", cpuRuntime(&ngroups))
+	crun.Buffer("DataN", count)
+	if err := crun.Kernel("counted.spv").DispatchCount("DataN"); err != nil || ngroups != 0 {
+		t.Errorf("DispatchCount with 0 count: err %v, groups %d", err, ngroups)
+	}
+	count[0] = 130
+	crun.Upload("DataN")
+	if err := crun.Kernel("counted.spv").DispatchCount("DataN"); err != nil || ngroups != 3 {
+		t.Errorf("DispatchCount with 130 count: err %v, groups %d, want 3", err, ngroups)
+	}
+	crun.Release()
+
+	vrun := NewRuntime("variants", cpuRuntime(&ngroups))
+	vrun.Buffer("DataN", count)
+	vk := vrun.KernelVariants("counted.spv", []int{32, 64, 128})
+	if vk.Threads != 64 {
+		t.Errorf("KernelVariants threads without device properties: got %d, want 64", vk.Threads)
+	}
+	if err := vk.Dispatch(130); err != nil || ngroups != 3 {
+		t.Errorf("Dispatch of variant: err %v, groups %d, want 3", err, ngroups)
+	}
+	vrun.Release()
+
+	run.Buffer("Late", ds)
+	if run.Err() == nil {
+		t.Error("expected error for buffer added after Dispatch")
+	}
+	run.Release()
+}
+
+func TestConcurrentRuns(t *testing.T) {
+	// each worker of a parameter sweep has its own Run and runtime
+	var wg sync.WaitGroup
+	for w, gain := range []float32{2, 3} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pars := &params{Gain: gain}
+			ds := make([]data, 100)
+			ngroups := 0
+			run := NewRuntime(fmt.Sprintf("worker%d", w), cpuRuntime(&ngroups))
+			defer run.Release()
+			run.Buffer("Params", pars).Buffer("Data", ds)
+			kern := run.Kernel("scale.spv")
+			for it := range 50 {
+				for i := range ds {
+					ds[i].Raw = float32(it + i)
+				}
+				if it > 0 {
+					run.Upload("Data")
+				}
+				if err := kern.Dispatch(len(ds)); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := run.Read("Data", ds); err != nil {
+					t.Error(err)
+					return
+				}
+				if want := gain * float32(it+99); ds[99].Out != want {
+					t.Errorf("worker %d, iteration %d: Out got %g, want %g", w, it, ds[99].Out, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gradcheck provides a numerical gradient-check harness for comparing
+the CPU and GPU versions of gosl shared code, e.g., learning rule functions
+such as CaLrn and RLRate.
+
+Simple value diffs can miss translation-induced math differences that only
+show up in the local slope of a function (e.g., a different exp approximation
+or a clamp at a slightly different point). This package perturbs each input
+by +/- Eps and computes central finite-difference gradients for both the CPU
+and GPU versions of the same function, reporting any entries that differ by
+more than the given tolerance.
+
+The GPU side is abstracted as a [Func] that the user implements by copying
+the inputs to the GPU, running the kernel, and copying the outputs back,
+so this package has no dependency on any particular GPU framework.
+*/
+package gradcheck
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Func computes outputs from inputs, e.g., by calling the shared Go
+// function on the CPU or by running the corresponding kernel on the GPU.
+// The out slice is pre-allocated to the number of outputs.
+type Func func(in, out []float32)
+
+// Params are the parameters for the gradient check
+type Params struct {
+
+	// size of the perturbation applied to each input, in each direction
+	Eps float32
+
+	// absolute tolerance for gradient differences
+	Tol float32
+
+	// relative tolerance for gradient differences, as a proportion of the
+	// larger absolute gradient value
+	RelTol float32
+}
+
+// Defaults sets default parameter values
+func (pr *Params) Defaults() {
+	pr.Eps = 1.0e-3
+	pr.Tol = 1.0e-3
+	pr.RelTol = 1.0e-2
+}
+
+// Mismatch records one entry of the gradient that differs between CPU and GPU
+type Mismatch struct {
+
+	// index of the input that was perturbed
+	In int
+
+	// index of the output
+	Out int
+
+	// CPU gradient d Out / d In
+	CPU float32
+
+	// GPU gradient d Out / d In
+	GPU float32
+}
+
+// String returns a readable description of the mismatch
+func (mm *Mismatch) String() string {
+	return fmt.Sprintf("d out[%d] / d in[%d]: CPU: %g  GPU: %g  diff: %g", mm.Out, mm.In, mm.CPU, mm.GPU, mm.CPU-mm.GPU)
+}
+
+// Gradients computes the central finite-difference Jacobian of the given
+// function at the given input values, with nout outputs, as [out][in].
+// The input values are restored to their original values on return.
+func Gradients(fun Func, in []float32, nout int, eps float32) [][]float32 {
+	grads := make([][]float32, nout)
+	for oi := range grads {
+		grads[oi] = make([]float32, len(in))
+	}
+	outp := make([]float32, nout)
+	outm := make([]float32, nout)
+	for ii, iv := range in {
+		in[ii] = iv + eps
+		fun(in, outp)
+		in[ii] = iv - eps
+		fun(in, outm)
+		in[ii] = iv
+		for oi := range grads {
+			grads[oi][ii] = (outp[oi] - outm[oi]) / (2 * eps)
+		}
+	}
+	return grads
+}
+
+// Compare computes the finite-difference gradients of the cpu and gpu
+// functions at the given input values, with nout outputs,
+// and returns the list of entries where they differ beyond tolerance.
+// A NaN or Inf on one side but not the other is always a mismatch.
+func Compare(cpu, gpu Func, in []float32, nout int, pr *Params) []Mismatch {
+	cg := Gradients(cpu, in, nout, pr.Eps)
+	gg := Gradients(gpu, in, nout, pr.Eps)
+	var mms []Mismatch
+	for oi := range cg {
+		for ii := range in {
+			c := cg[oi][ii]
+			g := gg[oi][ii]
+			if !Differ(c, g, pr) {
+				continue
+			}
+			mms = append(mms, Mismatch{In: ii, Out: oi, CPU: c, GPU: g})
+		}
+	}
+	return mms
+}
+
+// Differ returns true if the two gradient values differ beyond the
+// absolute and relative tolerances in the params.
+func Differ(c, g float32, pr *Params) bool {
+	cbad := math.IsNaN(float64(c)) || math.IsInf(float64(c), 0)
+	gbad := math.IsNaN(float64(g)) || math.IsInf(float64(g), 0)
+	if cbad || gbad {
+		return cbad != gbad
+	}
+	df := float32(math.Abs(float64(c - g)))
+	if df <= pr.Tol {
+		return false
+	}
+	mx := float32(math.Max(math.Abs(float64(c)), math.Abs(float64(g))))
+	return df > pr.RelTol*mx
+}
+
+// Report returns a multi-line report of the given mismatches,
+// or an empty string if there are none.
+func Report(name string, mms []Mismatch) string {
+	if len(mms) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "gradcheck: %s: %d gradient mismatches between CPU and GPU:\n", name, len(mms))
+	for i := range mms {
+		b.WriteString("    " + mms[i].String() + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gradcheck
+
+import (
+	"math"
+	"testing"
+)
+
+func sigmoid(in, out []float32) {
+	out[0] = 1 / (1 + float32(math.Exp(float64(-in[0]*in[1]))))
+	out[1] = in[0] * in[0]
+}
+
+func TestCompare(t *testing.T) {
+	pr := &Params{}
+	pr.Defaults()
+	in := []float32{0.5, 2}
+	if mms := Compare(sigmoid, sigmoid, in, 2, pr); len(mms) != 0 {
+		t.Error(Report("same", mms))
+	}
+	if in[0] != 0.5 || in[1] != 2 {
+		t.Errorf("inputs not restored: %v", in)
+	}
+
+	// clamped version has zero slope at the clamp point
+	clamped := func(in, out []float32) {
+		sigmoid(in, out)
+		out[1] = min(out[1], 0.25)
+	}
+	mms := Compare(sigmoid, clamped, in, 2, pr)
+	if len(mms) != 1 || mms[0].Out != 1 || mms[0].In != 0 {
+		t.Errorf("expected one mismatch at out 1, in 0, got:\n%s", Report("clamped", mms))
+	}
+}
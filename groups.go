@@ -0,0 +1,251 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// GroupsBase is the name of the field group for the fields of a split
+// struct without a group tag.
+const GroupsBase = "base"
+
+// GoslTag returns the value of the given key in a gosl struct tag,
+// which is a comma-separated list of key=value options, e.g.,
+// `gosl:"group=act"`, and whether the key is present.
+func GoslTag(tag, key string) (string, bool) {
+	for _, opt := range strings.Split(reflect.StructTag(tag).Get("gosl"), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		if k == key {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
+
+// SplitStruct is a struct type whose fields are assigned to named groups
+// with `gosl:"group=name"` struct tags, which is split into one struct
+// type per group, e.g., NeuronAct for group act of Neuron, so that each
+// group can be stored in its own buffer, and kernels only need to
+// access the groups they use.
+type SplitStruct struct {
+
+	// name of the struct type, e.g., Neuron
+	Type string
+
+	// names of the groups, in order of first appearance
+	Groups []string
+
+	// fields of each group
+	Fields map[string][]SplitField
+}
+
+// SplitField is a field of a SplitStruct
+type SplitField struct {
+
+	// field name
+	Name string
+
+	// Go type
+	Type string
+
+	// HLSL type
+	HLSL string
+}
+
+// FindSplitStructs returns the struct types in the given package with
+// group tags on any of their fields, sorted by name.
+func FindSplitStructs(pkg *packages.Package) ([]*SplitStruct, error) {
+	var sss []*SplitStruct
+	scope := pkg.Types.Scope()
+	for _, nm := range scope.Names() {
+		tn, ok := scope.Lookup(nm).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		st, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		ss := &SplitStruct{Type: nm, Fields: map[string][]SplitField{}}
+		hasGroup := false
+		var ferr error
+		for i := range st.NumFields() {
+			f := st.Field(i)
+			if !f.Exported() && strings.HasPrefix(strings.ToLower(f.Name()), "pad") {
+				continue // each group is padded separately
+			}
+			grp, has := GoslTag(st.Tag(i), "group")
+			if has {
+				if grp == "" || grp == GroupsBase {
+					return nil, fmt.Errorf("gosl: %s.%s: invalid group name: %q", nm, f.Name(), grp)
+				}
+				hasGroup = true
+			} else {
+				grp = GroupsBase
+			}
+			ht := hlslBasicType(f.Type())
+			if ht == "" && ferr == nil {
+				ferr = fmt.Errorf("gosl: %s.%s: fields of a struct with groups must be 32 bit basic types, not: %s", nm, f.Name(), f.Type())
+			}
+			if _, has := ss.Fields[grp]; !has {
+				ss.Groups = append(ss.Groups, grp)
+			}
+			ss.Fields[grp] = append(ss.Fields[grp], SplitField{Name: f.Name(), Type: types.TypeString(f.Type(), types.RelativeTo(pkg.Types)), HLSL: ht})
+		}
+		if !hasGroup {
+			continue
+		}
+		if ferr != nil {
+			return nil, ferr
+		}
+		sss = append(sss, ss)
+	}
+	sort.Slice(sss, func(i, j int) bool { return sss[i].Type < sss[j].Type })
+	return sss, nil
+}
+
+// Name returns the base name of the generated files, e.g., neurongroups
+func (ss *SplitStruct) Name() string {
+	return strings.ToLower(ss.Type) + "groups"
+}
+
+// GroupType returns the name of the struct type for given group,
+// e.g., NeuronAct
+func (ss *SplitStruct) GroupType(grp string) string {
+	return ss.Type + strings.ToUpper(grp[:1]) + grp[1:]
+}
+
+// GroupField returns the name of the field for given group in the
+// Groups type, e.g., Act
+func (ss *SplitStruct) GroupField(grp string) string {
+	return strings.ToUpper(grp[:1]) + grp[1:]
+}
+
+// nPad returns the number of padding fields needed for given group,
+// to be a multiple of 16 bytes.
+func (ss *SplitStruct) nPad(grp string) int {
+	return (4 - len(ss.Fields[grp])%4) % 4
+}
+
+// HLSL returns the HLSL definitions of the group struct types
+func (ss *SplitStruct) HLSL() []byte {
+	var b bytes.Buffer
+	upnm := strings.ToUpper(ss.Name())
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "#ifndef __%s_HLSL__\n#define __%s_HLSL__\n\n", upnm, upnm)
+	for _, grp := range ss.Groups {
+		fmt.Fprintf(&b, "// %s has the %s group of %s fields\n", ss.GroupType(grp), grp, ss.Type)
+		fmt.Fprintf(&b, "struct %s {\n", ss.GroupType(grp))
+		for _, f := range ss.Fields[grp] {
+			fmt.Fprintf(&b, "\t%s %s;\n", f.HLSL, f.Name)
+		}
+		for i := range ss.nPad(grp) {
+			fmt.Fprintf(&b, "\tuint pad%d;\n", i)
+		}
+		b.WriteString("};\n\n")
+	}
+	fmt.Fprintf(&b, "#endif // __%s_HLSL__\n", upnm)
+	return b.Bytes()
+}
+
+// Go returns the Go source with the group types and the composite
+// Groups type, in given package.
+func (ss *SplitStruct) Go(pkgName string) ([]byte, error) {
+	var b bytes.Buffer
+	gt := ss.Type + "Groups"
+	vt := ss.Type + "View"
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	for _, grp := range ss.Groups {
+		fmt.Fprintf(&b, "// %s has the %s group of %s fields,\n// stored in its own buffer\n", ss.GroupType(grp), grp, ss.Type)
+		fmt.Fprintf(&b, "type %s struct {\n", ss.GroupType(grp))
+		for _, f := range ss.Fields[grp] {
+			fmt.Fprintf(&b, "\t%s %s\n", f.Name, f.Type)
+		}
+		if np := ss.nPad(grp); np > 0 {
+			pads := make([]string, np)
+			for i := range pads {
+				pads[i] = fmt.Sprintf("pad%d", i)
+			}
+			fmt.Fprintf(&b, "\n\t%s uint32\n", strings.Join(pads, ", "))
+		}
+		b.WriteString("}\n\n")
+	}
+	fmt.Fprintf(&b, "// %s has the %s values split into one slice per field group,\n", gt, ss.Type)
+	b.WriteString("// each of which is copied to its own GPU buffer.\n")
+	fmt.Fprintf(&b, "type %s struct {\n", gt)
+	for _, grp := range ss.Groups {
+		fmt.Fprintf(&b, "\t%s []%s\n", ss.GroupField(grp), ss.GroupType(grp))
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "// Alloc allocates n values in each group\nfunc (gs *%s) Alloc(n int) {\n", gt)
+	for _, grp := range ss.Groups {
+		fmt.Fprintf(&b, "\tgs.%s = make([]%s, n)\n", ss.GroupField(grp), ss.GroupType(grp))
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "// Len returns the number of values\nfunc (gs *%s) Len() int {\n\treturn len(gs.%s)\n}\n\n", gt, ss.GroupField(ss.Groups[0]))
+	fmt.Fprintf(&b, "// Get returns the %s at given index, assembled from the groups\nfunc (gs *%s) Get(i int) %s {\n\tvar v %s\n", ss.Type, gt, ss.Type, ss.Type)
+	for _, grp := range ss.Groups {
+		for _, f := range ss.Fields[grp] {
+			fmt.Fprintf(&b, "\tv.%s = gs.%s[i].%s\n", f.Name, ss.GroupField(grp), f.Name)
+		}
+	}
+	b.WriteString("\treturn v\n}\n\n")
+	fmt.Fprintf(&b, "// Set sets the %s at given index, into the groups\nfunc (gs *%s) Set(i int, v *%s) {\n", ss.Type, gt, ss.Type)
+	for _, grp := range ss.Groups {
+		for _, f := range ss.Fields[grp] {
+			fmt.Fprintf(&b, "\tgs.%s[i].%s = v.%s\n", ss.GroupField(grp), f.Name, f.Name)
+		}
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "// Split allocates the groups for the given values, and sets them\nfunc (gs *%s) Split(vals []%s) {\n\tgs.Alloc(len(vals))\n\tfor i := range vals {\n\t\tgs.Set(i, &vals[i])\n\t}\n}\n\n", gt, ss.Type)
+	fmt.Fprintf(&b, "// Join sets the given values from the groups\nfunc (gs *%s) Join(vals []%s) {\n\tfor i := range vals {\n\t\tvals[i] = gs.Get(i)\n\t}\n}\n\n", gt, ss.Type)
+	fmt.Fprintf(&b, "// %s is a view of the %s at an index in a %s,\n// with accessors for each field.\ntype %s struct {\n\tGroups *%s\n\tIndex int\n}\n\n", vt, ss.Type, gt, vt, gt)
+	fmt.Fprintf(&b, "// View returns the view of the %s at given index\nfunc (gs *%s) View(i int) %s {\n\treturn %s{Groups: gs, Index: i}\n}\n", ss.Type, gt, vt, vt)
+	for _, grp := range ss.Groups {
+		for _, f := range ss.Fields[grp] {
+			fmt.Fprintf(&b, "\n// %s returns the %s field\nfunc (v %s) %s() %s {\n\treturn v.Groups.%s[v.Index].%s\n}\n", f.Name, f.Name, vt, f.Name, f.Type, ss.GroupField(grp), f.Name)
+			fmt.Fprintf(&b, "\n// Set%s sets the %s field\nfunc (v %s) Set%s(val %s) {\n\tv.Groups.%s[v.Index].%s = val\n}\n", f.Name, f.Name, vt, f.Name, f.Type, ss.GroupField(grp), f.Name)
+		}
+	}
+	return format.Source(b.Bytes())
+}
+
+// GenSplitStructs generates the group types for all of the struct types
+// in the given package with group tags: <type>groups.hlsl in the output
+// directory, to be included in kernels, and <type>groups.go in the
+// current directory, with the Go group types and the Groups and View types.
+func GenSplitStructs(pkg *packages.Package) error {
+	sss, err := FindSplitStructs(pkg)
+	if err != nil {
+		return err
+	}
+	for _, ss := range sss {
+		nm := ss.Name()
+		if err := WriteGenerated(filepath.Join(GenDir(), nm+".hlsl"), nm, FormatShader("hlsl", ss.HLSL())); err != nil {
+			return err
+		}
+		gofn := nm + ".go"
+		pnm, _ := DocPackageName(gofn)
+		src, err := ss.Go(pnm)
+		if err != nil {
+			return err
+		}
+		if err := WriteGenerated(gofn, nm, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
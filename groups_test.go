@@ -0,0 +1,35 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitStructs(t *testing.T) {
+	src := "package main\n\ntype Neuron struct {\n\tAct float32 `gosl:\"group=act\"`\n\tGe float32 `gosl:\"group=syn\"`\n\tVm float32 `gosl:\"group=act\"`\n\tLayIndex uint32\n\n\tpad, pad1 uint32\n}\n\ntype Plain struct {\n\tX float32\n}\n"
+	sss, err := FindSplitStructs(testPackage(t, "nrn.go", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sss) != 1 || sss[0].Type != "Neuron" || strings.Join(sss[0].Groups, ",") != "act,syn,base" {
+		t.Fatalf("wrong split structs: %+v", sss)
+	}
+	ss := sss[0]
+	if len(ss.Fields["act"]) != 2 || len(ss.Fields["base"]) != 1 || ss.nPad("syn") != 3 {
+		t.Errorf("wrong fields: %+v", ss.Fields)
+	}
+	gsrc, err := ss.Go("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(gsrc), "func (v NeuronView) SetVm(val float32)") {
+		t.Errorf("missing view accessor in:\n%s", gsrc)
+	}
+	if !strings.Contains(string(ss.HLSL()), "struct NeuronSyn {\n\tfloat Ge;\n\tuint pad0;") {
+		t.Errorf("wrong HLSL:\n%s", ss.HLSL())
+	}
+}
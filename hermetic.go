@@ -0,0 +1,169 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ToolNone is the tool path value that disables use of a tool
+const ToolNone = "none"
+
+// HermeticArgs checks the tool and output args for -hermetic mode,
+// where tools must not be resolved from the PATH, and all outputs
+// must be declared in advance with -outputs.
+func HermeticArgs() error {
+	if !*hermetic {
+		return nil
+	}
+	var errs []error
+	for _, tl := range []struct{ flag, path string }{{"goimports", *goimportsPath}, {"dxc", *dxcPath}} {
+		if tl.path != ToolNone && !filepath.IsAbs(tl.path) {
+			errs = append(errs, fmt.Errorf("-hermetic: -%s must be an absolute path or %q, not: %q", tl.flag, ToolNone, tl.path))
+		}
+	}
+	if *outputs == "" {
+		errs = append(errs, errors.New("-hermetic: all outputs must be declared with -outputs"))
+	}
+	return errors.Join(errs...)
+}
+
+// LoadConfig returns a packages.Config for loading packages with given mode,
+// which in -hermetic mode disables all network access and module downloads,
+// and uses the -cache directory as the GOCACHE if set.
+func LoadConfig(mode packages.LoadMode) *packages.Config {
+	cfg := &packages.Config{Mode: mode}
+	if !*hermetic && *cacheDir == "" {
+		return cfg
+	}
+	cfg.Env = os.Environ()
+	if *cacheDir != "" {
+		cd, _ := filepath.Abs(*cacheDir)
+		cfg.Env = append(cfg.Env, "GOCACHE="+cd)
+	}
+	if *hermetic {
+		cfg.Env = append(cfg.Env, "GOPROXY=off", "GOFLAGS=-mod=readonly", "GOTOOLCHAIN=local", "GOWORK=off")
+	}
+	return cfg
+}
+
+// SourceImports returns the import lines for all of the given source
+// files, used for the extracted Go files when goimports is not available.
+// Imports that end up unused only generate type checking errors
+// that do not affect the translation.
+func SourceImports(files []string) [][]byte {
+	imps := map[string]bool{}
+	for _, fn := range files {
+		fset := token.NewFileSet()
+		af, err := parser.ParseFile(fset, fn, nil, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, is := range af.Imports {
+			pth, _ := strconv.Unquote(is.Path.Value)
+			if pth == "math" {
+				continue // always included
+			}
+			imp := is.Path.Value
+			if is.Name != nil {
+				imp = is.Name.Name + " " + imp
+			}
+			imps[imp] = true
+		}
+	}
+	sl := make([]string, 0, len(imps))
+	for imp := range imps {
+		sl = append(sl, imp)
+	}
+	sort.Strings(sl)
+	lns := [][]byte{[]byte("import (")}
+	for _, imp := range sl {
+		lns = append(lns, []byte("\t"+imp))
+	}
+	lns = append(lns, []byte(")"))
+	return lns
+}
+
+// writtenOutputs are the paths of the files written by WriteGenerated and
+// the other generators in the current run, for CheckOutputs, including
+// those outside of the output directory, e.g., for -doc and -kernelids.
+var writtenOutputs = map[string]bool{}
+
+// RecordOutput records the given path of a file generated in the current
+// run, whether or not it is written, e.g., when it is unchanged.
+func RecordOutput(path string) {
+	writtenOutputs[filepath.Clean(path)] = true
+}
+
+// CheckOutputs checks that the files generated in the current run exactly
+// match those declared in -outputs, in -hermetic mode: all of the files in
+// the output directory, and those written outside of it, e.g., for -doc,
+// -kernelids and the bindings, relative to it, e.g., ../kernelids.go.
+// The -report file, written after the check, must also be declared.
+func CheckOutputs() error {
+	if !*hermetic {
+		return nil
+	}
+	decl := map[string]bool{}
+	for _, fn := range strings.Split(*outputs, ",") {
+		if fn = strings.TrimSpace(fn); fn != "" {
+			decl[filepath.Clean(fn)] = false
+		}
+	}
+	var errs []error
+	check := func(rel string) {
+		if _, has := decl[rel]; !has {
+			errs = append(errs, fmt.Errorf("-hermetic: undeclared output: %s", rel))
+			return
+		}
+		decl[rel] = true
+	}
+	filepath.WalkDir(GenDir(), func(path string, f fs.DirEntry, err error) error {
+		if err != nil || f.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(GenDir(), path)
+		check(rel)
+		return nil
+	})
+	if *reportFile != "" {
+		RecordOutput(*reportFile)
+	}
+	gd, _ := filepath.Abs(GenDir())
+	od, _ := filepath.Abs(*outDir)
+	var outside []string
+	for path := range writtenOutputs {
+		ap, _ := filepath.Abs(path)
+		if grel, err := filepath.Rel(gd, ap); err == nil && grel != ".." && !strings.HasPrefix(grel, ".."+string(filepath.Separator)) {
+			continue // in the walk, possibly moved by MapOutputs
+		}
+		rel, err := filepath.Rel(od, ap)
+		if err != nil {
+			rel = ap
+		}
+		outside = append(outside, rel)
+	}
+	sort.Strings(outside)
+	for _, rel := range outside {
+		check(rel)
+	}
+	for fn, got := range decl {
+		if !got {
+			errs = append(errs, fmt.Errorf("-hermetic: declared output was not generated: %s", fn))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hermeticOutputs are the outputs of the basic test region with -doc
+// and -kernelids files next to the output directory, relative to it.
+var hermeticOutputs = []string{"basic.hlsl", "kernelids.hlsl", "../hermetic_doc.go", "../hermetic_kernelids.go"}
+
+// setHermetic sets the flags to generate the basic test region in
+// -hermetic mode in shaders/hermetictest, with the given -outputs,
+// restoring them at the end of the test.
+func setHermetic(t *testing.T, outs []string) {
+	od, dxc, gi, hm, os_, df, ki := *outDir, *dxcPath, *goimportsPath, *hermetic, *outputs, *docFile, *kernelIDs
+	*outDir = filepath.Join("shaders", "hermetictest")
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		os.Remove(*docFile)
+		os.Remove(*kernelIDs)
+		*outDir, *dxcPath, *goimportsPath, *hermetic, *outputs, *docFile, *kernelIDs = od, dxc, gi, hm, os_, df, ki
+		ResetState()
+	})
+	ResetState()
+	*dxcPath, *goimportsPath, *hermetic = ToolNone, ToolNone, true
+	*docFile = filepath.Join("shaders", "hermetic_doc.go")
+	*kernelIDs = filepath.Join("shaders", "hermetic_kernelids.go")
+	*outputs = strings.Join(outs, ",")
+}
+
+// readOutputs returns the contents of the given outputs, relative to -out.
+func readOutputs(t *testing.T, outs []string) map[string][]byte {
+	got := map[string][]byte{}
+	for _, fn := range outs {
+		b, err := os.ReadFile(filepath.Join(*outDir, fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[fn] = b
+	}
+	return got
+}
+
+// TestHermeticOutputs generates the basic test region twice in -hermetic
+// mode, with all of its outputs declared, which must be the same each time.
+func TestHermeticOutputs(t *testing.T) {
+	setHermetic(t, hermeticOutputs)
+	if err := Generate([]string{"testdata/basic.go"}); err != nil {
+		t.Fatal(err)
+	}
+	first := readOutputs(t, hermeticOutputs)
+	ResetState()
+	if err := Generate([]string{"testdata/basic.go"}); err != nil {
+		t.Fatal(err)
+	}
+	for fn, b := range readOutputs(t, hermeticOutputs) {
+		if !bytes.Equal(b, first[fn]) {
+			t.Errorf("%s differs between runs:\n%s\n---\n%s", fn, first[fn], b)
+		}
+	}
+}
+
+// TestHermeticUndeclared checks that the undeclared outputs are detected:
+// a -kernelids file outside of the output directory, and a stray file in
+// it, along with the declared outputs that were not generated.
+func TestHermeticUndeclared(t *testing.T) {
+	setHermetic(t, hermeticOutputs[:3])
+	err := Generate([]string{"testdata/basic.go"})
+	if err == nil || !strings.Contains(err.Error(), "undeclared output: "+filepath.Join("..", "hermetic_kernelids.go")) {
+		t.Errorf("undeclared -kernelids file not detected: %v", err)
+	}
+
+	*outputs = "basic.hlsl,missing.hlsl"
+	if err := BeginOutputs(); err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range []string{"basic.hlsl", "stray.hlsl"} {
+		os.WriteFile(filepath.Join(GenDir(), fn), []byte("\n"), 0644)
+	}
+	err = EndOutputs(CheckOutputs())
+	for _, want := range []string{"undeclared output: stray.hlsl", "declared output was not generated: missing.hlsl"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v does not contain %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "basic.hlsl") {
+		t.Errorf("declared output reported: %v", err)
+	}
+}
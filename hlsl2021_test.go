@@ -0,0 +1,21 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHLSL2021(t *testing.T) {
+	hv := *hlslVersion
+	*hlslVersion = HLSL2021
+	defer func() { *hlslVersion = hv }()
+	runTest(t, "testdata/hlsl2021/generic.go", "testdata/hlsl2021/generic.golden")
+	runTest(t, "testdata/hlsl2021/errors.go", "testdata/hlsl2021/errors.golden")
+	if args := DxcArgs("a.hlsl", "main", "a.spv"); !slices.Contains(args, "-HV") {
+		t.Errorf("missing -HV 2021 in dxc args: %v", args)
+	}
+}
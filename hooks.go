@@ -0,0 +1,98 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Hooks are custom passes that are run at fixed points of the generation,
+// e.g., for a downstream framework to expand its own domain-specific
+// macros, without forking gosl.  Any nil hook is skipped.  An error from
+// a hook fails the generation, leaving the previous outputs as they were.
+type Hooks struct {
+
+	// PreExtract is called with the path and contents of each Go source
+	// file before the gosl regions are extracted from it, and returns
+	// the source to extract them from.  The file itself is not changed.
+	PreExtract func(file string, src []byte) ([]byte, error)
+
+	// PostTranslate is called with the name and HLSL code of each
+	// translated region, e.g., axon.hlsl, and returns the code to write
+	// to the output directory, which is then compiled.
+	PostTranslate func(file string, src []byte) ([]byte, error)
+
+	// PostCompile is called with the path of each compiled .spv file,
+	// in the staging directory of the outputs, which it can change.
+	PostCompile func(file string) error
+}
+
+// HooksConfig has the commands run as the Hooks, in the Config file, e.g.:
+//
+//	{"Hooks": {"PostTranslate": "mymacros -hlsl"}}
+//
+// Each command is split into fields, without a shell, and the file name
+// is appended to its arguments.  The PreExtract and PostTranslate commands
+// get the source on stdin, and write the new source to stdout.  A command
+// fails if it exits with a non-zero status.
+type HooksConfig struct {
+
+	// command run as the PreExtract hook
+	PreExtract string
+
+	// command run as the PostTranslate hook
+	PostTranslate string
+
+	// command run as the PostCompile hook
+	PostCompile string
+}
+
+// GoslHooks are the Hooks of the current run, set by ConfigArgs from
+// the HooksConfig of the Config file.
+var GoslHooks Hooks
+
+// Hooks returns the Hooks that run the commands of the config.
+func (hc *HooksConfig) Hooks() Hooks {
+	var hk Hooks
+	if hc.PreExtract != "" {
+		hk.PreExtract = filterHook("PreExtract", hc.PreExtract)
+	}
+	if hc.PostTranslate != "" {
+		hk.PostTranslate = filterHook("PostTranslate", hc.PostTranslate)
+	}
+	if hc.PostCompile != "" {
+		hk.PostCompile = func(file string) error {
+			_, err := runHook("PostCompile", hc.PostCompile, file, nil)
+			return err
+		}
+	}
+	return hk
+}
+
+// filterHook returns a hook that runs the given command as a filter
+// of the source.
+func filterHook(name, command string) func(file string, src []byte) ([]byte, error) {
+	return func(file string, src []byte) ([]byte, error) {
+		return runHook(name, command, file, src)
+	}
+}
+
+// runHook runs the given hook command with given file name and source on
+// stdin, returning its stdout.
+func runHook(name, command, file string, src []byte) ([]byte, error) {
+	args := append(strings.Fields(command), file)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(src)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gosl: %s hook %q failed for %s: %w", name, command, file, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	od, dxc, hk := *outDir, *dxcPath, GoslHooks
+	*outDir = filepath.Join("shaders", "hookstest")
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *dxcPath, GoslHooks = od, dxc, hk
+		ResetState()
+	})
+	*dxcPath = ToolNone
+	var extracted []string
+	GoslHooks = Hooks{
+		PreExtract: func(file string, src []byte) ([]byte, error) {
+			extracted = append(extracted, file)
+			return bytes.ReplaceAll(src, []byte("DataStruct has"), []byte("DataStruct (expanded) has")), nil
+		},
+		PostTranslate: func(file string, src []byte) ([]byte, error) {
+			return append(src, []byte("// post "+file+"\n")...), nil
+		},
+	}
+	if err := Generate([]string{"testdata/basic.go"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(extracted) != 1 || extracted[0] != "testdata/basic.go" {
+		t.Errorf("PreExtract not called for the source file: %v", extracted)
+	}
+	b, _ := os.ReadFile(filepath.Join(*outDir, "basic.hlsl"))
+	for _, want := range []string{"DataStruct (expanded) has", "// post basic.hlsl\n"} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("basic.hlsl does not contain %q", want)
+		}
+	}
+
+	ResetState()
+	GoslHooks.PostTranslate = func(file string, src []byte) ([]byte, error) {
+		return nil, errors.New("macro error")
+	}
+	if err := Generate([]string{"testdata/basic.go"}); err == nil || !strings.Contains(err.Error(), "macro error") {
+		t.Errorf("hook error does not fail the generation: %v", err)
+	}
+	if b2, _ := os.ReadFile(filepath.Join(*outDir, "basic.hlsl")); !bytes.Equal(b, b2) {
+		t.Error("previous outputs changed by a failed hook")
+	}
+}
+
+func TestHooksConfig(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook command is a shell script")
+	}
+	tmp := t.TempDir()
+	script := filepath.Join(tmp, "macros")
+	os.WriteFile(script, []byte("#!/bin/sh\nsed \"s/MACRO/$2 $1/\"\n"), 0755)
+	var cfg Config
+	if err := ParseConfig("gosl.json", []byte(`{"Hooks": {"PostTranslate": "`+script+` -x", "PostCompile": "false"}}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	hk := cfg.Hooks.Hooks()
+	if hk.PreExtract != nil {
+		t.Error("PreExtract hook without a command")
+	}
+	out, err := hk.PostTranslate("axon.hlsl", []byte("x = MACRO;\n"))
+	if err != nil || string(out) != "x = axon.hlsl -x;\n" {
+		t.Errorf("PostTranslate: got %q, %v", out, err)
+	}
+	if err := hk.PostCompile("x.spv"); err == nil || !strings.Contains(err.Error(), "PostCompile") {
+		t.Errorf("PostCompile: expected error, got %v", err)
+	}
+}
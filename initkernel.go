@@ -0,0 +1,313 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// InitThreads is the number of threads per workgroup in the
+// generated init kernels.
+const InitThreads = 64
+
+// InitKernel is a generated kernel that initializes all of the elements
+// of a buffer on the GPU, e.g., the neuron state, so that the initial
+// state does not need to be computed on the CPU and uploaded, which is
+// slow for large models.  It is declared by a directive in the doc
+// comment of a function within a gosl region:
+//
+//	//gosl: init <Buffer> [<ParamsBuffer>[<IndexField>]]
+//
+// where Buffer is the name of the buffer of the elements, and the
+// optional ParamsBuffer is a buffer of parameters, e.g., per layer,
+// indexed by the given field of each element.  The function takes the
+// element index, a random counter for slrand (e.g., for gaussian
+// variability of initial values), the element, and the parameters if
+// any, e.g.:
+//
+//	//gosl: init Neurons Layers[LayIndex]
+//	func InitNeuron(idx uint32, ctr *sltype.Uint2, nrn *Neuron, ly *Layer)
+type InitKernel struct {
+
+	// name of the Go function, e.g., InitNeuron
+	Func string
+
+	// region (output file) with the function, which is included
+	Region string
+
+	// name of the buffer of the elements, e.g., Neurons
+	Buffer string
+
+	// element type of Buffer, e.g., Neuron
+	Type string
+
+	// name of the buffer of the parameters, e.g., Layers, if any
+	ParamsBuffer string
+
+	// element type of ParamsBuffer, e.g., Layer
+	ParamsType string
+
+	// field of the elements with the index of their parameters in
+	// ParamsBuffer, e.g., LayIndex
+	IndexField string
+}
+
+// initParamsRe matches the params buffer arg of an init directive
+var initParamsRe = regexp.MustCompile(`^(\w+)\[(\w+)\]$`)
+
+// isInitDirective returns the args of the init directive in the
+// given doc comment, if any, accepting the // gosl: form that gofmt
+// produces for doc comments.
+func isInitDirective(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, c := range doc.List {
+		txt := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if args, ok := strings.CutPrefix(txt, "gosl: init "); ok {
+			return strings.TrimSpace(args), true
+		}
+	}
+	return "", false
+}
+
+// FindInitKernels returns the InitKernels declared by the functions
+// with an init directive in the given package of extracted regions,
+// and an error for each directive that is not valid.
+func FindInitKernels(pkg *packages.Package) ([]*InitKernel, error) {
+	var iks []*InitKernel
+	var errs []error
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			args, ok := isInitDirective(fd.Doc)
+			if !ok {
+				continue
+			}
+			pos := pkg.Fset.Position(fd.Pos())
+			ik, err := NewInitKernel(pkg, fd, args)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: gosl: init: %s: %w", pos, fd.Name.Name, err))
+				continue
+			}
+			ik.Region = strings.TrimSuffix(filepath.Base(pos.Filename), ".go")
+			iks = append(iks, ik)
+		}
+	}
+	return iks, errors.Join(errs...)
+}
+
+// NewInitKernel returns the InitKernel for given function with an init
+// directive with given args, checking its signature.
+func NewInitKernel(pkg *packages.Package, fd *ast.FuncDecl, args string) (*InitKernel, error) {
+	ik := &InitKernel{Func: fd.Name.Name}
+	fs := strings.Fields(args)
+	if len(fs) < 1 || len(fs) > 2 {
+		return nil, fmt.Errorf("directive must be: init <Buffer> [<ParamsBuffer>[<IndexField>]], not: init %s", args)
+	}
+	ik.Buffer = fs[0]
+	if len(fs) == 2 {
+		m := initParamsRe.FindStringSubmatch(fs[1])
+		if m == nil {
+			return nil, fmt.Errorf("params buffer must be <ParamsBuffer>[<IndexField>], e.g., Layers[LayIndex], not: %s", fs[1])
+		}
+		ik.ParamsBuffer, ik.IndexField = m[1], m[2]
+	}
+	obj, ok := pkg.TypesInfo.Defs[fd.Name].(*types.Func)
+	if !ok || fd.Recv != nil {
+		return nil, errors.New("must be a function, not a method")
+	}
+	sig := obj.Type().(*types.Signature)
+	np := 3
+	if ik.ParamsBuffer != "" {
+		np = 4
+	}
+	ps := sig.Params()
+	if ps.Len() != np || sig.Results().Len() != 0 {
+		return nil, fmt.Errorf("signature must be: func(idx uint32, ctr *sltype.Uint2, elem *Type%s), with a params pointer arg only for a params buffer", map[bool]string{true: ", params *ParamsType"}[np == 4])
+	}
+	if bt, ok := ps.At(0).Type().(*types.Basic); !ok || bt.Kind() != types.Uint32 {
+		return nil, fmt.Errorf("first arg must be the uint32 index, not: %s", ps.At(0).Type())
+	}
+	if nm, ok := pointerNamed(ps.At(1).Type()); !ok || nm.Obj().Name() != "Uint2" || nm.Obj().Pkg() == nil || nm.Obj().Pkg().Name() != "sltype" {
+		return nil, fmt.Errorf("second arg must be the random counter, *sltype.Uint2, not: %s", ps.At(1).Type())
+	}
+	et, ok := pointerNamed(ps.At(2).Type())
+	if !ok {
+		return nil, fmt.Errorf("third arg must be a pointer to the element type, not: %s", ps.At(2).Type())
+	}
+	ik.Type = et.Obj().Name()
+	if np == 4 {
+		pt, ok := pointerNamed(ps.At(3).Type())
+		if !ok {
+			return nil, fmt.Errorf("fourth arg must be a pointer to the params type, not: %s", ps.At(3).Type())
+		}
+		ik.ParamsType = pt.Obj().Name()
+		st, _ := et.Underlying().(*types.Struct)
+		found := false
+		for i := range st.NumFields() {
+			f := st.Field(i)
+			if bt, ok := f.Type().Underlying().(*types.Basic); ok && f.Name() == ik.IndexField && bt.Info()&types.IsInteger != 0 {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s has no integer index field: %s", ik.Type, ik.IndexField)
+		}
+	}
+	return ik, nil
+}
+
+// pointerNamed returns the named struct type pointed to by given type
+func pointerNamed(typ types.Type) (*types.Named, bool) {
+	pt, ok := typ.(*types.Pointer)
+	if !ok {
+		return nil, false
+	}
+	nt, ok := types.Unalias(pt.Elem()).(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	_, ok = nt.Underlying().(*types.Struct)
+	return nt, ok
+}
+
+// Name returns the kernel name, e.g., initneuron
+func (ik *InitKernel) Name() string {
+	return strings.ToLower(ik.Func)
+}
+
+// Buffers returns the names of the buffers of the kernel: the
+// parameters of the kernel (e.g., InitNeuronArgs), the elements,
+// and the params buffer if any.
+func (ik *InitKernel) Buffers() []string {
+	bufs := []string{ik.Func + "Args", ik.Buffer}
+	if ik.ParamsBuffer != "" {
+		bufs = append(bufs, ik.ParamsBuffer)
+	}
+	return bufs
+}
+
+// GenInitKernels generates the init kernel in the output directory, and
+// the Go file with the kernel parameters type, the CPU version, and the
+// GPU dispatch helper in the current directory, for each function with
+// an init directive, returning those generated.  The buffers are bound
+// as given by KernelBindings.
+func GenInitKernels(pkg *packages.Package) ([]*InitKernel, error) {
+	iks, err := FindInitKernels(pkg)
+	var gen []*InitKernel
+	for _, ik := range iks {
+		nm := ik.Name()
+		binds := KernelBindings(nm, ik.Buffers())
+		if err := WriteGenerated(filepath.Join(GenDir(), nm+".hlsl"), nm, FormatShader("hlsl", ik.HLSL(binds))); err != nil {
+			return gen, err
+		}
+		gofn := nm + ".go"
+		pnm, _ := DocPackageName(gofn)
+		src, err := ik.Go(pnm)
+		if err != nil {
+			return gen, err
+		}
+		if err := WriteGenerated(gofn, nm, src); err != nil {
+			return gen, err
+		}
+		gen = append(gen, ik)
+	}
+	return gen, err
+}
+
+// HLSL returns the generated init kernel source, with the buffers
+// at the given set and binding.  Each thread initializes one element.
+// The headers of the gosl packages in the output directory, e.g.,
+// slrand.hlsl, are included before the region with the function.
+func (ik *InitKernel) HLSL(binds [][2]int) []byte {
+	var b bytes.Buffer
+	bufs := ik.Buffers()
+	pt := ik.Func + "Params"
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	for _, hp := range HeaderPackages {
+		if _, err := os.Stat(filepath.Join(GenDir(), hp+".hlsl")); err == nil {
+			fmt.Fprintf(&b, "#include \"%s.hlsl\"\n", hp)
+		}
+	}
+	fmt.Fprintf(&b, "#include \"%s.hlsl\"\n\n", ik.Region)
+	fmt.Fprintf(&b, "// %s are the parameters of the %s kernel\n", pt, ik.Name())
+	fmt.Fprintf(&b, "struct %s {\n\tuint N;\n\tuint Seed;\n\tuint pad, pad1;\n};\n\n", pt)
+	b.WriteString("// note: binding is var, set\n")
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[0][1], binds[0][0], pt, bufs[0])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[1][1], binds[1][0], ik.Type, bufs[1])
+	if ik.ParamsBuffer != "" {
+		fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[2][1], binds[2][0], ik.ParamsType, bufs[2])
+	}
+	fmt.Fprintf(&b, "\n// each thread initializes one of the %s[0].N %s with %s,\n", bufs[0], ik.Buffer, ik.Func)
+	fmt.Fprintf(&b, "// with a random counter starting at %s[0].Seed.\n", bufs[0])
+	fmt.Fprintf(&b, "[numthreads(%d, 1, 1)]\n\n", InitThreads)
+	b.WriteString("void main(uint3 idx : SV_DispatchThreadID) {\n")
+	fmt.Fprintf(&b, "\t%s ip = %s[0];\n", pt, bufs[0])
+	b.WriteString("\tif (idx.x >= ip.N) {\n\t\treturn;\n\t}\n")
+	b.WriteString("\tuint2 ctr = uint2(ip.Seed, 0);\n")
+	elem := ik.Buffer + "[idx.x]"
+	if ik.ParamsBuffer != "" {
+		fmt.Fprintf(&b, "\t%s(idx.x, ctr, %s, %s[%s.%s]);\n}\n", ik.Func, elem, ik.ParamsBuffer, elem, ik.IndexField)
+	} else {
+		fmt.Fprintf(&b, "\t%s(idx.x, ctr, %s);\n}\n", ik.Func, elem)
+	}
+	return b.Bytes()
+}
+
+// Go returns the generated Go source with the kernel parameters type,
+// the CPU version of the kernel, and the GPU dispatch helper, in given
+// package.
+func (ik *InitKernel) Go(pkgName string) ([]byte, error) {
+	var b bytes.Buffer
+	bufs := ik.Buffers()
+	pt := ik.Func + "Params"
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	b.WriteString("import (\n\t\"github.com/emer/gosl/v2/slgpu\"\n\t\"github.com/emer/gosl/v2/sltype\"\n)\n\n")
+	fmt.Fprintf(&b, "// %s are the parameters of the %s kernel, in the %s\n", pt, ik.Name(), bufs[0])
+	fmt.Fprintf(&b, "// buffer: the number N of %s to initialize, and the Seed of the\n", ik.Buffer)
+	b.WriteString("// random counter of each one.\n")
+	fmt.Fprintf(&b, "type %s struct {\n\tN    uint32\n\tSeed uint32\n\n\tpad, pad1 uint32\n}\n\n", pt)
+
+	args := fmt.Sprintf("%s []%s", ik.Buffer, ik.Type)
+	call := fmt.Sprintf("&%s[i]", ik.Buffer)
+	if ik.ParamsBuffer != "" {
+		args += fmt.Sprintf(", %s []%s", ik.ParamsBuffer, ik.ParamsType)
+		call += fmt.Sprintf(", &%s[%s[i].%s]", ik.ParamsBuffer, ik.Buffer, ik.IndexField)
+	}
+	fmt.Fprintf(&b, "// %sCPU initializes the params.N %s with %s on the CPU,\n", ik.Func, ik.Buffer, ik.Func)
+	fmt.Fprintf(&b, "// as the %s kernel does on the GPU.\n", ik.Name())
+	fmt.Fprintf(&b, "func %sCPU(params *%s, %s) {\n", ik.Func, pt, args)
+	fmt.Fprintf(&b, "\tfor i := range params.N {\n\t\tctr := sltype.Uint2{X: params.Seed}\n\t\t%s(i, &ctr, %s)\n\t}\n}\n\n", ik.Func, call)
+
+	fmt.Fprintf(&b, "// %sGPU initializes the params.N %s with %s on the GPU, by\n", ik.Func, ik.Buffer, ik.Func)
+	fmt.Fprintf(&b, "// uploading the params to the %s buffer, and dispatching the %s\n", bufs[0], ik.Name())
+	b.WriteString("// kernel, which must have been added to the runtime with its buffers.\n")
+	if ik.ParamsBuffer != "" {
+		fmt.Fprintf(&b, "// The %s must have been uploaded, but the %s do not need to be,\n", ik.ParamsBuffer, ik.Buffer)
+	} else {
+		fmt.Fprintf(&b, "// The %s do not need to be uploaded first,\n", ik.Buffer)
+	}
+	b.WriteString("// and can be read back when needed on the CPU.\n")
+	fmt.Fprintf(&b, "func %sGPU(rt slgpu.Runtime, params *%s) error {\n", ik.Func, pt)
+	fmt.Fprintf(&b, "\tif err := rt.Upload(%q, slgpu.Bytes([]%s{*params})); err != nil {\n\t\treturn err\n\t}\n", bufs[0], pt)
+	fmt.Fprintf(&b, "\treturn rt.Dispatch(%q, (int(params.N)+%d)/%d, 1, 1)\n}\n", ik.Name(), InitThreads-1, InitThreads)
+	return format.Source(b.Bytes())
+}
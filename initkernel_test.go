@@ -0,0 +1,69 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInitKernel(t *testing.T) {
+	src := `package main
+
+import "github.com/emer/gosl/v2/sltype"
+
+type Neuron struct {
+	Vm, Act  float32
+	LayIndex uint32
+	pad      float32
+}
+
+type Layer struct {
+	VmInit, VmVar float32
+
+	pad, pad1 float32
+}
+
+// InitNeuron initializes the neuron state
+//
+//gosl: init Neurons Layers[LayIndex]
+func InitNeuron(idx uint32, ctr *sltype.Uint2, nrn *Neuron, ly *Layer) {
+	nrn.Vm = ly.VmInit
+}
+
+//gosl: init Neurons Layers[Vm]
+func BadIndex(idx uint32, ctr *sltype.Uint2, nrn *Neuron, ly *Layer) {
+}
+
+//gosl: init Neurons
+func BadSig(idx int32, nrn *Neuron) {
+}
+`
+	pkg := testPackage(t, "axon.go", src)
+	iks, err := FindInitKernels(pkg)
+	if err == nil || !strings.Contains(err.Error(), "BadIndex") || !strings.Contains(err.Error(), "BadSig") {
+		t.Errorf("expected errors for BadIndex and BadSig, got: %v", err)
+	}
+	if len(iks) != 1 {
+		t.Fatalf("expected only InitNeuron, got %d", len(iks))
+	}
+	ik := iks[0]
+	if ik.Name() != "initneuron" || ik.Region != "axon" || ik.Type != "Neuron" || ik.ParamsType != "Layer" || ik.IndexField != "LayIndex" {
+		t.Errorf("wrong init kernel: %+v", ik)
+	}
+	hlsl := string(ik.HLSL([][2]int{{2, 0}, {0, 0}, {0, 1}}))
+	for _, want := range []string{`#include "axon.hlsl"`, "[[vk::binding(0, 2)]] RWStructuredBuffer<InitNeuronParams> InitNeuronArgs;", "[[vk::binding(1, 0)]] RWStructuredBuffer<Layer> Layers;", "InitNeuron(idx.x, ctr, Neurons[idx.x], Layers[Neurons[idx.x].LayIndex]);"} {
+		if !strings.Contains(hlsl, want) {
+			t.Errorf("missing %q in:\n%s", want, hlsl)
+		}
+	}
+
+	// the generated Go code must compile with the function
+	gsrc, err := ik.Go("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGeneratedGo(t, pkg, map[string][]byte{"initneuron.go": gsrc})
+}
@@ -0,0 +1,379 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emer/gosl/v2/slprint"
+	"golang.org/x/tools/go/packages"
+)
+
+// EntryThreads is the default number of threads per workgroup of the
+// generated kernel entry points.
+const EntryThreads = 64
+
+// KernelEntry is a kernel entry point that is generated for a Go function
+// or method within a gosl region, instead of writing the main function by
+// hand in a //gosl: hlsl block.  It is declared by a directive in the doc
+// comment of the function:
+//
+//	//gosl: kernel <Name> [threads=<n>] [<arg>=<Buffer>[<Index>] ...]
+//
+// where Name is the name of the entry point function, and n is the
+// number of threads per workgroup (EntryThreads by default).  Each
+// argument of the function, including the receiver of a method, is
+// either the thread index, as a uint32 for its x component or an
+// sltype.Uint3, or an element of a buffer declared in the region,
+// passed by pointer or value.  The buffer of an element is the one
+// with its type, and its index is the thread index, unless given as
+// arg=Buffer[Index] in the directive, where Index is an HLSL expression
+// that can use the other elements, e.g., ly=Layers[nrn.LayIndex]:
+//
+//	//gosl: kernel CycleNeuron ly=Layers[nrn.LayIndex]
+//	func (ly *Layer) CycleNeuron(ni uint32, nrn *Neuron)
+//
+// The elements are copied into local variables, and those indexed by the
+// thread index and passed by pointer are written back to their buffer,
+// unless it is read-only.  The other elements are shared by the threads,
+// e.g., the layer of the neurons, so they are only read.  An argument
+// can also be a slice of buffer elements, e.g., nrns []Neuron, to which
+// the whole buffer is passed, named as arg=Buffer in the directive if
+// needed, which must be marked with a //gosl: readonly directive if
+// the buffer is read-only, and only then (see slprint.ReadOnlyParams).
+type KernelEntry struct {
+
+	// name of the entry point function
+	Name string
+
+	// name of the Go function
+	Func string
+
+	// receiver type name for a method, which is called on its element
+	Recv string
+
+	// region (output file) with the function
+	Region string
+
+	// number of threads per workgroup
+	Threads int
+
+	// arguments of the function, starting with the receiver of a method
+	Args []*EntryArg
+
+	// position of the Go function, for messages
+	Pos token.Position
+}
+
+// EntryArg is an argument of the function of a KernelEntry
+type EntryArg struct {
+
+	// name of the argument
+	Name string
+
+	// the argument is the thread index, as a uint32 for its x
+	// component, or sltype.Uint3 for all of it
+	Index bool
+
+	// the thread index is an sltype.Uint3
+	Index3 bool
+
+	// element type name, for a buffer element
+	Type string
+
+	// the element is passed by pointer
+	Ptr bool
+
+	// name of the buffer of the element, if given in the directive:
+	// otherwise it is the buffer with the element type
+	Buffer string
+
+	// HLSL index expression of the element, if given in the directive:
+	// otherwise it is the thread index
+	Elem string
+
+	// the argument is a slice of the elements, to which the whole
+	// buffer is passed
+	Slice bool
+
+	// the slice argument is marked with a //gosl: readonly directive
+	ReadOnly bool
+}
+
+// entryArgRe matches an arg=Buffer[Index] arg of a kernel directive
+var entryArgRe = regexp.MustCompile(`^(\w+)=(\w+)(?:\[(.+)\])?$`)
+
+// FindKernelEntries returns the KernelEntries declared by the functions
+// with a kernel directive in the given package of extracted regions,
+// by region, and an error for each directive that is not valid.
+func FindKernelEntries(pkg *packages.Package) (map[string][]*KernelEntry, error) {
+	kes := map[string][]*KernelEntry{}
+	var errs []error
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			args := docDirective(fd.Doc, KernelDirective)
+			if args == "" {
+				continue
+			}
+			pos := pkg.Fset.Position(fd.Pos())
+			ke, err := NewKernelEntry(pkg, fd, args)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: gosl: kernel: %s: %w", pos, fd.Name.Name, err))
+				continue
+			}
+			ke.Pos = pos
+			ke.Region = strings.TrimSuffix(filepath.Base(pos.Filename), ".go")
+			kes[ke.Region] = append(kes[ke.Region], ke)
+		}
+	}
+	for _, es := range kes {
+		sort.Slice(es, func(i, j int) bool { return es[i].Pos.Line < es[j].Pos.Line })
+	}
+	return kes, errors.Join(errs...)
+}
+
+// NewKernelEntry returns the KernelEntry for given function with a kernel
+// directive with given args, checking its signature.
+func NewKernelEntry(pkg *packages.Package, fd *ast.FuncDecl, args string) (*KernelEntry, error) {
+	fs := strings.Fields(args)
+	if !token.IsIdentifier(fs[0]) {
+		return nil, fmt.Errorf("entry point name must be an identifier, not: %s", fs[0])
+	}
+	ke := &KernelEntry{Name: fs[0], Func: fd.Name.Name, Threads: EntryThreads}
+	if fd.Recv == nil && ke.Name == ke.Func {
+		return nil, fmt.Errorf("entry point name must differ from the function name: %s", ke.Name)
+	}
+	obj, ok := pkg.TypesInfo.Defs[fd.Name].(*types.Func)
+	if !ok {
+		return nil, errors.New("not a function")
+	}
+	sig := obj.Type().(*types.Signature)
+	ro := slprint.ReadOnlyParams(fd.Doc)
+	if sig.Results().Len() != 0 {
+		return nil, errors.New("the function must not return values")
+	}
+	if rv := sig.Recv(); rv != nil {
+		ea, err := newEntryArg(rv)
+		if err != nil {
+			return nil, err
+		}
+		if ea.Index {
+			return nil, errors.New("the receiver must be a buffer element")
+		}
+		ke.Recv = ea.Type
+		ke.Args = append(ke.Args, ea)
+	}
+	hasIndex := false
+	for i := range sig.Params().Len() {
+		ea, err := newEntryArg(sig.Params().At(i))
+		if err != nil {
+			return nil, err
+		}
+		ea.ReadOnly = ro[ea.Name]
+		if ea.Index {
+			if hasIndex {
+				return nil, fmt.Errorf("argument %s: only one argument can be the thread index", ea.Name)
+			}
+			hasIndex = true
+		}
+		ke.Args = append(ke.Args, ea)
+	}
+	for _, a := range fs[1:] {
+		if th, ok := strings.CutPrefix(a, "threads="); ok {
+			n, err := strconv.Atoi(th)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("threads must be a positive number, not: %s", th)
+			}
+			ke.Threads = n
+			continue
+		}
+		m := entryArgRe.FindStringSubmatch(a)
+		if m == nil {
+			return nil, fmt.Errorf("directive args must be threads=<n> or <arg>=<Buffer>[<Index>], not: %s", a)
+		}
+		ea := ke.Arg(m[1])
+		if ea == nil || ea.Index {
+			return nil, fmt.Errorf("%s is not a buffer element argument", m[1])
+		}
+		if ea.Slice && m[3] != "" {
+			return nil, fmt.Errorf("%s is a slice argument, which is passed the whole buffer: use %s=%s", m[1], m[1], m[2])
+		}
+		ea.Buffer, ea.Elem = m[2], m[3]
+	}
+	return ke, nil
+}
+
+// newEntryArg returns the EntryArg for given argument of the function
+// of a KernelEntry, which must be the thread index, a buffer element,
+// or a slice of buffer elements.
+func newEntryArg(v *types.Var) (*EntryArg, error) {
+	ea := &EntryArg{Name: v.Name()}
+	if ea.Name == "" || ea.Name == "_" {
+		return nil, errors.New("the arguments must be named")
+	}
+	typ := types.Unalias(v.Type())
+	if bt, ok := typ.(*types.Basic); ok && bt.Kind() == types.Uint32 {
+		ea.Index = true
+		return ea, nil
+	}
+	if pt, ok := typ.(*types.Pointer); ok {
+		typ = types.Unalias(pt.Elem())
+		ea.Ptr = true
+	} else if st, ok := typ.(*types.Slice); ok {
+		typ = types.Unalias(st.Elem())
+		ea.Slice = true
+	}
+	nt, ok := typ.(*types.Named)
+	if ok && !ea.Ptr && nt.Obj().Name() == "Uint3" && nt.Obj().Pkg() != nil && nt.Obj().Pkg().Name() == "sltype" {
+		ea.Index, ea.Index3 = true, true
+		return ea, nil
+	}
+	if _, isStruct := typ.Underlying().(*types.Struct); !ok || !isStruct {
+		return nil, fmt.Errorf("argument %s must be the uint32 or sltype.Uint3 thread index, a buffer element, or a slice of them, not: %s", ea.Name, v.Type())
+	}
+	ea.Type = nt.Obj().Name()
+	return ea, nil
+}
+
+// Arg returns the argument with given name, or nil if none
+func (ke *KernelEntry) Arg(name string) *EntryArg {
+	for _, ea := range ke.Args {
+		if ea.Name == name {
+			return ea
+		}
+	}
+	return nil
+}
+
+// HLSL returns the entry point function for the buffers declared in
+// the given HLSL source of its region, with an error if the buffer
+// of an element is not found.
+func (ke *KernelEntry) HLSL(src []byte) ([]byte, error) {
+	bufs := ParseKernel(ke.Region, src).Buffers
+	var b bytes.Buffer
+	fn := ke.Func
+	if ke.Recv != "" {
+		fn = ke.Recv + "." + fn
+	}
+	fmt.Fprintf(&b, "\n// %s is the kernel entry point generated for %s\n", ke.Name, fn)
+	fmt.Fprintf(&b, "[numthreads(%d, 1, 1)]\n", ke.Threads)
+	fmt.Fprintf(&b, "void %s(uint3 idx : SV_DispatchThreadID) {\n", ke.Name)
+	var call, back []string
+	// elements at the thread index first, as the others can use them
+	elems := make([]*EntryArg, 0, len(ke.Args))
+	for _, ea := range ke.Args {
+		if !ea.Index && !ea.Slice && ea.Elem == "" {
+			elems = append(elems, ea)
+		}
+	}
+	for _, ea := range ke.Args {
+		if ea.Elem != "" {
+			elems = append(elems, ea)
+		}
+	}
+	for _, ea := range elems {
+		bf, err := ke.buffer(ea, bufs)
+		if err != nil {
+			return nil, err
+		}
+		ix := ea.Elem
+		if ix == "" {
+			ix = "idx.x"
+			if ea.Ptr && strings.HasPrefix(bf.Kind, "RW") {
+				back = append(back, fmt.Sprintf("\t%s[%s] = %s;\n", bf.Name, ix, ea.Name))
+			}
+		}
+		fmt.Fprintf(&b, "\t%s %s = %s[%s];\n", ea.Type, ea.Name, bf.Name, ix)
+	}
+	for _, ea := range ke.Args {
+		switch {
+		case ke.Recv != "" && ea == ke.Args[0]:
+		case ea.Index3:
+			call = append(call, "idx")
+		case ea.Index:
+			call = append(call, "idx.x")
+		case ea.Slice:
+			bf, err := ke.buffer(ea, bufs)
+			if err != nil {
+				return nil, err
+			}
+			if rw := strings.HasPrefix(bf.Kind, "RW"); rw == ea.ReadOnly {
+				if rw {
+					return nil, fmt.Errorf("slice argument %s is marked readonly, but buffer %s is not read-only", ea.Name, bf.Name)
+				}
+				return nil, fmt.Errorf("slice argument %s must be marked with //gosl: readonly %s, as buffer %s is read-only", ea.Name, ea.Name, bf.Name)
+			}
+			call = append(call, bf.Name)
+		default:
+			call = append(call, ea.Name)
+		}
+	}
+	if ke.Recv != "" {
+		fmt.Fprintf(&b, "\t%s.%s(%s);\n", ke.Args[0].Name, ke.Func, strings.Join(call, ", "))
+	} else {
+		fmt.Fprintf(&b, "\t%s(%s);\n", ke.Func, strings.Join(call, ", "))
+	}
+	for _, bk := range back {
+		b.WriteString(bk)
+	}
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+// buffer returns the buffer of the given element argument among the
+// given buffers: the one named in the directive, or with its type.
+func (ke *KernelEntry) buffer(ea *EntryArg, bufs []*Buffer) (*Buffer, error) {
+	var found *Buffer
+	for _, bf := range bufs {
+		switch {
+		case ea.Buffer != "" && bf.Name == ea.Buffer:
+			if bf.Type != ea.Type {
+				return nil, fmt.Errorf("buffer %s of argument %s has element type %s, not %s", bf.Name, ea.Name, bf.Type, ea.Type)
+			}
+			return bf, nil
+		case ea.Buffer == "" && bf.Type == ea.Type:
+			if found != nil {
+				return nil, fmt.Errorf("argument %s: multiple buffers have element type %s: %s and %s: use %s=<Buffer> in the directive", ea.Name, ea.Type, found.Name, bf.Name, ea.Name)
+			}
+			found = bf
+		}
+	}
+	if found != nil {
+		return found, nil
+	}
+	if ea.Buffer != "" {
+		return nil, fmt.Errorf("buffer %s of argument %s is not declared in region %s", ea.Buffer, ea.Name, ke.Region)
+	}
+	return nil, fmt.Errorf("no buffer with element type %s for argument %s is declared in region %s", ea.Type, ea.Name, ke.Region)
+}
+
+// GenKernelEntries returns the entry points of the given KernelEntries
+// of a region, for its HLSL source, printing any errors.
+func GenKernelEntries(kes []*KernelEntry, src []byte) []byte {
+	var b []byte
+	for _, ke := range kes {
+		hl, err := ke.HLSL(src)
+		if err != nil {
+			fmt.Printf("%s: gosl: kernel %s: %v\n", ke.Pos, ke.Name, err)
+			continue
+		}
+		b = append(b, hl...)
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKernelEntry(t *testing.T) {
+	src := `package main
+
+type Neuron struct {
+	Vm, Act float32
+
+	pad, pad1 float32
+}
+
+//gosl: start axon
+
+//gosl: kernel Cycle threads=32
+func CycleNeuron(ni uint32, nrn *Neuron) {
+	nrn.Act = nrn.Vm
+}
+
+//gosl: kernel BadArg
+func BadArg(ni uint32, x float32) {
+}
+
+//gosl: kernel BadName
+func BadName(ni uint32, nrn *Neuron) {
+}
+
+//gosl: kernel Index nrn=Neurons[0] ni=Neurons
+func BadIndex(ni uint32, nrn *Neuron) {
+}
+
+//gosl: kernel Sum
+//gosl: readonly nrns
+func SumNeurons(ni uint32, nrns []Neuron) {
+}
+
+//gosl: kernel Slice nrns=Neurons[0]
+func BadSlice(nrns []Neuron) {
+}
+
+//gosl: end axon
+
+//gosl: cpukernel axon
+func AxonCPU(idx uint32, Neurons []Neuron) {
+}
+`
+	pkg := testPackage(t, "axon.go", src)
+	kes, err := FindKernelEntries(pkg)
+	for _, bad := range []string{"BadArg", "BadName", "BadIndex", "BadSlice"} {
+		if err == nil || !strings.Contains(err.Error(), bad) {
+			t.Errorf("expected an error for %s, got: %v", bad, err)
+		}
+	}
+	if es := kes["axon"]; len(es) != 2 || es[0].Name != "Cycle" || es[0].Threads != 32 || es[1].Name != "Sum" {
+		t.Errorf("wrong kernel entries: %+v", es)
+	}
+
+	// only AxonCPU is a CPU kernel function
+	fn := filepath.Join(t.TempDir(), "axon.go")
+	if err := os.WriteFile(fn, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if kfs := FindKernelFuncs([]string{fn}); len(kfs) != 1 || kfs[0].Name != "AxonCPU" {
+		t.Errorf("wrong kernel funcs: %+v", kfs)
+	}
+
+	hlsl, err := kes["axon"][0].HLSL([]byte("[[vk::binding(0, 0)]] RWStructuredBuffer<Neuron> Neurons;\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[numthreads(32, 1, 1)]", "void Cycle(uint3 idx : SV_DispatchThreadID) {", "\tNeuron nrn = Neurons[idx.x];\n\tCycleNeuron(idx.x, nrn);\n\tNeurons[idx.x] = nrn;\n"} {
+		if !strings.Contains(string(hlsl), want) {
+			t.Errorf("missing %q in:\n%s", want, hlsl)
+		}
+	}
+	if _, err := kes["axon"][0].HLSL(nil); err == nil {
+		t.Error("expected an error for a missing buffer")
+	}
+
+	// the whole buffer is passed to a slice, which must be readonly as the buffer
+	hlsl, err = kes["axon"][1].HLSL([]byte("[[vk::binding(0, 0)]] StructuredBuffer<Neuron> Neurons;\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\tSumNeurons(idx.x, Neurons);\n}\n"; !strings.HasSuffix(string(hlsl), want) {
+		t.Errorf("missing %q at the end of:\n%s", want, hlsl)
+	}
+	if _, err := kes["axon"][1].HLSL([]byte("[[vk::binding(0, 0)]] RWStructuredBuffer<Neuron> Neurons;\n")); err == nil || !strings.Contains(err.Error(), "marked readonly") {
+		t.Errorf("expected an error for a readonly slice of a read-write buffer, got: %v", err)
+	}
+}
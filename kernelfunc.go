@@ -0,0 +1,226 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// KernelFunc is a Go function that mirrors a kernel on the CPU,
+// as declared by a directive in the doc comment of the function:
+//
+//	//gosl: cpukernel <name>
+//
+// where name is the kernel (output file) name.  The function takes
+// the thread index as its first argument, as a uint32 for 1D kernels,
+// or sltype.Uint3 for kernels using the y or z index, followed by
+// one slice argument for each buffer of the kernel, in binding order,
+// with the same names (case insensitive) and element types:
+//
+//	//gosl: cpukernel basic
+//	func BasicCPU(idx uint32, Params []ParamStruct, Data []DataStruct)
+//
+// gosl verifies that the function signature matches the kernel,
+// so that the CPU and GPU versions stay aligned.  This is distinct from
+// the //gosl: kernel directive, which declares a KernelEntry for a
+// function within a gosl region.
+type KernelFunc struct {
+
+	// name of the kernel
+	Kernel string
+
+	// name of the Go function
+	Name string
+
+	// position of the Go function, for messages
+	Pos token.Position
+
+	// Go type of the thread index argument, empty if none
+	Index string
+
+	// Go slice arguments, for the buffers
+	Params []KernelParam
+}
+
+// KernelParam is a buffer argument of a KernelFunc
+type KernelParam struct {
+
+	// argument name
+	Name string
+
+	// Go element type of the slice
+	Type string
+}
+
+// The gosl directives in the doc comments of functions
+const (
+	// KernelDirective declares a KernelEntry for a function in a region
+	KernelDirective = "kernel"
+
+	// CPUKernelDirective declares a KernelFunc
+	CPUKernelDirective = "cpukernel"
+)
+
+// docDirective returns the arguments of the gosl directive with given
+// name in the given doc comment, e.g., basic for //gosl: cpukernel basic,
+// or "" if it has none.  The // gosl: form is also accepted: gofmt
+// reformats the lines of doc comments to start with // and a space,
+// except for Go directives, e.g., //go:noinline, which have no space
+// after the colon, unlike the gosl directives.
+func docDirective(doc *ast.CommentGroup, name string) string {
+	if doc == nil {
+		return ""
+	}
+	for _, c := range doc.List {
+		txt := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if args, ok := strings.CutPrefix(txt, "gosl: "+name+" "); ok {
+			return strings.TrimSpace(args)
+		}
+	}
+	return ""
+}
+
+// FindKernelFuncs returns the KernelFuncs declared in given Go files.
+func FindKernelFuncs(files []string) []*KernelFunc {
+	var kfs []*KernelFunc
+	fset := token.NewFileSet()
+	for _, fn := range files {
+		if !strings.HasSuffix(fn, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, fn, nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil {
+				continue
+			}
+			knm := docDirective(fd.Doc, CPUKernelDirective)
+			if knm == "" {
+				continue
+			}
+			kfs = append(kfs, NewKernelFunc(knm, fd, fset.Position(fd.Pos())))
+		}
+	}
+	return kfs
+}
+
+// NewKernelFunc returns a new KernelFunc for given kernel name and function
+func NewKernelFunc(kernel string, fd *ast.FuncDecl, pos token.Position) *KernelFunc {
+	kf := &KernelFunc{Kernel: kernel, Name: fd.Name.Name, Pos: pos}
+	for i, fld := range fd.Type.Params.List {
+		st, isSlice := fld.Type.(*ast.ArrayType)
+		if i == 0 && !isSlice {
+			kf.Index = types.ExprString(fld.Type)
+			if len(fld.Names) > 1 { // e.g., (idx, other uint32)
+				kf.Index += "..."
+			}
+			continue
+		}
+		typ := types.ExprString(fld.Type)
+		if isSlice && st.Len == nil {
+			typ = types.ExprString(st.Elt)
+		}
+		for _, nm := range fld.Names {
+			kf.Params = append(kf.Params, KernelParam{Name: nm.Name, Type: typ})
+		}
+	}
+	return kf
+}
+
+// goElemType returns the HLSL element type corresponding to
+// the given Go slice element type, for comparison with buffer types.
+func goElemType(typ string) string {
+	if _, nm, ok := strings.Cut(typ, "."); ok { // e.g., sltype.Uint2
+		typ = nm
+	}
+	switch typ {
+	case "float32":
+		return "float"
+	case "int32":
+		return "int"
+	case "uint32":
+		return "uint"
+	}
+	return typ
+}
+
+// Check returns a list of mismatches between the KernelFunc
+// and given kernel.
+func (kf *KernelFunc) Check(k *Kernel) []string {
+	var errs []string
+	switch kf.Index {
+	case "":
+		if k.Index != "" {
+			errs = append(errs, fmt.Sprintf("missing thread index argument for %s : SV_DispatchThreadID: must be the first argument, as uint32 or sltype.Uint3", k.Index))
+		}
+	case "uint32":
+		if k.IndexDims > 1 {
+			errs = append(errs, fmt.Sprintf("thread index is uint32, but the kernel uses %d dimensions of %s: use sltype.Uint3", k.IndexDims, k.Index))
+		}
+	case "sltype.Uint3", "Uint3":
+	default:
+		errs = append(errs, fmt.Sprintf("thread index type must be uint32 or sltype.Uint3, not: %s", kf.Index))
+	}
+	n := max(len(kf.Params), len(k.Buffers))
+	for i := 0; i < n; i++ {
+		switch {
+		case i >= len(kf.Params):
+			b := k.Buffers[i]
+			errs = append(errs, fmt.Sprintf("missing argument for buffer %s (set %d, binding %d) of type %s", b.Name, b.Set, b.Binding, b.Type))
+		case i >= len(k.Buffers):
+			errs = append(errs, fmt.Sprintf("argument %s has no corresponding buffer in the kernel", kf.Params[i].Name))
+		default:
+			b := k.Buffers[i]
+			kp := kf.Params[i]
+			if !strings.EqualFold(kp.Name, b.Name) {
+				errs = append(errs, fmt.Sprintf("buffer argument %d is %s, but buffer (set %d, binding %d) is %s", i+1, kp.Name, b.Set, b.Binding, b.Name))
+			}
+			if !strings.EqualFold(goElemType(kp.Type), b.Type) {
+				errs = append(errs, fmt.Sprintf("argument %s has element type %s, but buffer %s has type %s", kp.Name, kp.Type, b.Name, b.Type))
+			}
+		}
+	}
+	return errs
+}
+
+// CheckKernelFuncs checks the KernelFuncs declared in given Go files
+// against the generated Kernels, printing any mismatches, and returns
+// an error if there were any, for -strict mode.
+func CheckKernelFuncs(files []string) error {
+	kfs := FindKernelFuncs(files)
+	sort.Slice(kfs, func(i, j int) bool {
+		return kfs[i].Name < kfs[j].Name
+	})
+	nerr := 0
+	for _, kf := range kfs {
+		var errs []string
+		if k, ok := Kernels[kf.Kernel]; ok {
+			errs = kf.Check(k)
+		} else {
+			errs = []string{fmt.Sprintf("kernel %s not found among the generated kernels", kf.Kernel)}
+		}
+		if len(errs) == 0 {
+			continue
+		}
+		nerr += len(errs)
+		fmt.Printf("\nWARNING: CPU function %s at %s does not match kernel: %s\n", kf.Name, kf.Pos, kf.Kernel)
+		for _, e := range errs {
+			fmt.Printf("    %s\n", e)
+		}
+	}
+	if nerr == 0 {
+		return nil
+	}
+	return fmt.Errorf("gosl: -strict: %d mismatches between CPU kernel functions and kernels", nerr)
+}
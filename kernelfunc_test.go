@@ -0,0 +1,88 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKernelFuncCheck(t *testing.T) {
+	src := []byte(`
+[[vk::binding(0, 0)]] RWStructuredBuffer<ParamStruct> Params;
+[[vk::binding(0, 1)]] RWStructuredBuffer<DataStruct> Data;
+[[vk::binding(1, 1)]] StructuredBuffer<uint2> Indexes;
+
+[numthreads(64, 1, 1)]
+void main(uint3 idx : SV_DispatchThreadID) {
+	Params[0].IntegFromRaw(Data[Indexes[idx.x].x + idx.y]);
+}
+`)
+	k := ParseKernel("basic", src)
+	if k.Index != "idx" || k.IndexDims != 2 {
+		t.Errorf("wrong index: %s dims: %d", k.Index, k.IndexDims)
+	}
+	gosrc := `package main
+
+// gosl: cpukernel basic
+func BasicCPU(idx sltype.Uint3, params []ParamStruct, Data []DataStruct, Indexes []sltype.Uint2) {}
+
+//gosl: cpukernel basic
+func BadCPU(idx uint32, Data []DataStruct, Params []ParamStruct) {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "cpu.go", gosrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kfs []*KernelFunc
+	for _, d := range f.Decls {
+		fd := d.(*ast.FuncDecl)
+		knm := docDirective(fd.Doc, CPUKernelDirective)
+		if knm != "basic" {
+			t.Fatalf("kernel directive not found for: %s", fd.Name.Name)
+		}
+		kfs = append(kfs, NewKernelFunc(knm, fd, fset.Position(fd.Pos())))
+	}
+	if errs := kfs[0].Check(k); len(errs) != 0 {
+		t.Errorf("unexpected mismatches: %v", errs)
+	}
+	// index dims, 2 x (name, type), missing Indexes
+	if errs := kfs[1].Check(k); len(errs) != 6 {
+		t.Errorf("expected 6 mismatches, got %d: %v", len(errs), errs)
+	}
+}
+
+// TestDocDirective checks that the directives are found in the form that
+// gofmt gives them in doc comments.
+func TestDocDirective(t *testing.T) {
+	src, err := format.Source([]byte("package main\n\n// BasicCPU is the CPU version\n//\n//gosl: cpukernel basic\nfunc BasicCPU(idx uint32) {}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "\n// gosl: cpukernel basic\n") {
+		t.Errorf("gofmt did not reformat the directive:\n%s", src)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "cpu.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nm := docDirective(f.Decls[0].(*ast.FuncDecl).Doc, CPUKernelDirective); nm != "basic" {
+		t.Errorf("wrong cpukernel directive: %q", nm)
+	}
+	fn := filepath.Join(t.TempDir(), "cpu.go")
+	if err := os.WriteFile(fn, src, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if kfs := FindKernelFuncs([]string{fn}); len(kfs) != 1 || kfs[0].Name != "BasicCPU" {
+		t.Errorf("wrong kernel funcs: %+v", kfs)
+	}
+}
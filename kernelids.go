@@ -0,0 +1,154 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// KernelIDName returns the name of the KernelID enum value for given kernel
+func KernelIDName(name string) string {
+	return "KernelID" + strings.TrimPrefix(KernelConstName(name), "Kernel")
+}
+
+// GenKernelIDs generates a KernelID enum for all of the generated Kernels
+// in the given Go file path, and matching integer constants in
+// kernelids.hlsl in the output directory, so that dispatch orchestration
+// code on both sides stays in sync with the generated kernels.
+// The Go file also has a generic KernelPipelines registry mapping
+// each KernelID to a pipeline in the GPU binding layer.
+func GenKernelIDs(path string) error {
+	pnm, _ := DocPackageName(path)
+	ks := SortedKernels()
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pnm)
+	b.WriteString("// KernelID identifies a GPU compute kernel generated by gosl,\n// for selecting the pipeline to dispatch.\n// The same values are defined in kernelids.hlsl.\ntype KernelID int32\n\n")
+	b.WriteString("// The generated kernels\nconst (\n")
+	for i, k := range ks {
+		if i == 0 {
+			fmt.Fprintf(&b, "\t%s KernelID = iota\n", KernelIDName(k.Name))
+		} else {
+			fmt.Fprintf(&b, "\t%s\n", KernelIDName(k.Name))
+		}
+	}
+	b.WriteString("\n\t// KernelIDN is the number of kernels\n")
+	if len(ks) == 0 {
+		b.WriteString("\tKernelIDN KernelID = 0\n)\n\n")
+	} else {
+		b.WriteString("\tKernelIDN\n)\n\n")
+	}
+	b.WriteString("// KernelNames are the names of the kernels, indexed by KernelID\nvar KernelNames = [KernelIDN]string{")
+	for i, k := range ks {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q", k.Name)
+	}
+	b.WriteString("}\n\n")
+	b.WriteString("// KernelSPVs are the paths to the compiled SPIR-V files of the kernels,\n// relative to where gosl was run, indexed by KernelID\nvar KernelSPVs = [KernelIDN]string{")
+	for i, k := range ks {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q", filepath.ToSlash(filepath.Join(*outDir, OutputPath(k.Name)+".spv")))
+	}
+	b.WriteString("}\n\n")
+	if slices.ContainsFunc(ks, func(k *Kernel) bool { return len(k.Variants) > 0 }) {
+		b.WriteString("// KernelVariants are the workgroup sizes of the -autotune variants of the\n// kernels, indexed by KernelID, for use with the sltune package\nvar KernelVariants = [KernelIDN][]int{")
+		for i, k := range ks {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if len(k.Variants) == 0 {
+				b.WriteString("nil")
+				continue
+			}
+			fmt.Fprintf(&b, "{%s}", strings.ReplaceAll(strings.Trim(fmt.Sprint(k.Variants), "[]"), " ", ", "))
+		}
+		b.WriteString("}\n\n")
+	}
+	b.WriteString(`// String returns the name of the kernel
+func (id KernelID) String() string {
+	if id < 0 || id >= KernelIDN {
+		return "KernelID(" + strconv.Itoa(int(id)) + ")"
+	}
+	return KernelNames[id]
+}
+
+// KernelPipelines is a registry mapping each KernelID to its pipeline
+// of type P in the GPU binding layer, e.g., *vgpu.Pipeline.
+type KernelPipelines[P any] [KernelIDN]P
+
+// Config configures the pipeline for each kernel using the given function,
+// which is passed the kernel name and the path to its SPIR-V file.
+func (kp *KernelPipelines[P]) Config(newPipeline func(name, spv string) P) {
+	for id := range KernelIDN {
+		kp[id] = newPipeline(KernelNames[id], KernelSPVs[id])
+	}
+}
+
+// Pipeline returns the pipeline for given kernel
+func (kp *KernelPipelines[P]) Pipeline(id KernelID) P {
+	return kp[id]
+}
+`)
+	if *repro {
+		b.WriteString(`
+// KernelInvocations counts the dispatches of each kernel, for pinning
+// the random number counters of each invocation with slrand.Counter.Pin,
+// and hashes the sequence of dispatches, so that the dispatch order
+// can be checked to be the same across runs, for reproducibility.
+type KernelInvocations struct {
+
+	// number of dispatches of each kernel so far
+	N [KernelIDN]uint32
+
+	// FNV-1a hash of the sequence of dispatched kernels
+	Order uint64
+}
+
+// Dispatch records a dispatch of given kernel, and returns its invocation
+// index.  It must be called in the order the dispatches are submitted.
+func (ki *KernelInvocations) Dispatch(id KernelID) uint32 {
+	if ki.Order == 0 {
+		ki.Order = 14695981039346656037
+	}
+	ki.Order = (ki.Order ^ uint64(id)) * 1099511628211
+	n := ki.N[id]
+	ki.N[id]++
+	return n
+}
+`)
+	}
+	src := bytes.Replace(b.Bytes(), []byte("package "+pnm+"\n"), []byte("package "+pnm+"\n\nimport \"strconv\"\n"), 1)
+	src, err := format.Source(src)
+	if err != nil {
+		return err
+	}
+	if err := WriteGenerated(path, "kernelids", src); err != nil {
+		return err
+	}
+	return WriteGenerated(filepath.Join(GenDir(), "kernelids.hlsl"), "kernelids", KernelIDsHLSL(ks))
+}
+
+// KernelIDsHLSL returns the HLSL source defining the KernelID constants
+func KernelIDsHLSL(ks []*Kernel) []byte {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	b.WriteString("#ifndef __KERNELIDS_HLSL__\n#define __KERNELIDS_HLSL__\n\n")
+	b.WriteString("// KernelID values identifying the GPU compute kernels, matching the Go KernelID enum\n")
+	for i, k := range ks {
+		fmt.Fprintf(&b, "static const int %s = %d;\n", KernelIDName(k.Name), i)
+	}
+	fmt.Fprintf(&b, "static const int KernelIDN = %d;\n", len(ks))
+	b.WriteString("\n#endif // __KERNELIDS_HLSL__\n")
+	return b.Bytes()
+}
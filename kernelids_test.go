@@ -0,0 +1,54 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestKernelIDs generates the -kernelids files for the multiple kernels
+// of the entry and basic test regions, which are ordered by kernel name,
+// not by source order, and compares them with the golden files.
+func TestKernelIDs(t *testing.T) {
+	od, dxc, gi := *outDir, *dxcPath, *goimportsPath
+	*outDir = filepath.Join("shaders", "kidtest")
+	os.MkdirAll(*outDir, 0755)
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *dxcPath, *goimportsPath = od, dxc, gi
+		ResetState()
+	})
+	*dxcPath, *goimportsPath = ToolNone, ToolNone
+	ResetState()
+	if _, err := ProcessFiles([]string{"testdata/entry.go", "testdata/basic.go"}); err != nil {
+		t.Fatal(err)
+	}
+	gofn := filepath.Join(*outDir, "kernelids.go")
+	if err := GenKernelIDs(gofn); err != nil {
+		t.Fatal(err)
+	}
+	for fn, golden := range map[string]string{gofn: "testdata/kernelids/kernelids.golden", filepath.Join(*outDir, "kernelids.hlsl"): "testdata/kernelids/kernelids.hlsl.golden"} {
+		got, err := os.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *update {
+			if err := os.WriteFile(golden, got, 0644); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from %s:\n%s", fn, golden, got)
+		}
+	}
+}
@@ -0,0 +1,611 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Access is the type of access a kernel makes to a buffer
+type Access int32
+
+const (
+	// Read means the buffer is only read
+	Read Access = 1 << iota
+
+	// Write means the buffer is (or may be) written
+	Write
+)
+
+// String returns the access as a string: read, write or read/write
+func (ac Access) String() string {
+	switch ac {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Read | Write:
+		return "read/write"
+	}
+	return "unused"
+}
+
+// Buffer has metadata about a buffer variable used in a kernel
+type Buffer struct {
+
+	// variable name of the buffer in the shader
+	Name string
+
+	// HLSL buffer type, e.g., RWStructuredBuffer
+	Kind string
+
+	// element type of the buffer, e.g., Neuron
+	Type string
+
+	// descriptor set number
+	Set int
+
+	// binding number within the set
+	Binding int
+
+	// size in bytes of each element, set for -budget
+	ElemSize int64
+
+	// access to the buffer in the kernel, which is conservative:
+	// passing a buffer element as a function argument or calling
+	// a method on it counts as a possible write.
+	Access Access
+
+	// only bound in the training mode, as declared with a
+	// gosl: train directive: see TrainBuffers
+	Train bool
+
+	// has an element indexed by the x thread index of a 1D kernel,
+	// e.g., Data[idx.x], which is the element of the thread
+	Indexed bool
+}
+
+// Kernel has metadata about a generated compute kernel,
+// which is an entry point function of a shader file.
+type Kernel struct {
+
+	// name of the kernel, which is the output file name without extension
+	Name string
+
+	// name of the shader file without extension, which is the Name
+	// unless the file has multiple entry points (see ParseKernels)
+	File string
+
+	// entry point function name
+	Entry string
+
+	// workgroup size from the numthreads attribute
+	Workgroup [3]int
+
+	// name of the SV_DispatchThreadID entry point parameter, if any
+	Index string
+
+	// number of dimensions of the Index used in the kernel:
+	// 1 if only x is used, 2 for y, 3 for z
+	IndexDims int
+
+	// name of the buffer whose number of elements bounds the thread index
+	// in the -boundscheck early exit prologue, if added
+	Bounds string
+
+	// buffers declared in the kernel, in set, binding order
+	Buffers []*Buffer
+
+	// potential intra-dispatch read / write hazards on the same buffer
+	Hazards []string
+
+	// source files that contributed code to this kernel
+	Sources []string
+
+	// workgroup sizes of the -autotune variants of this kernel
+	Variants []int
+
+	// has a test mode variant without the Train buffers: see GenTestMode
+	TestMode bool
+
+	// has a -subrange variant for a range of elements: see GenSubRange
+	SubRange bool
+}
+
+// Kernels are the kernels generated in the current run, by name
+var Kernels = map[string]*Kernel{}
+
+// SortedKernels returns the Kernels sorted by name
+func SortedKernels() []*Kernel {
+	ks := make([]*Kernel, 0, len(Kernels))
+	for _, k := range Kernels {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool { return ks[i].Name < ks[j].Name })
+	return ks
+}
+
+var (
+	numthreadsRe = regexp.MustCompile(`\[numthreads\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*\)\]`)
+	bindingRe    = regexp.MustCompile(`^\s*\[\[vk::binding\(\s*(\d+)\s*,\s*(\d+)\s*\)\]\]\s*(\w+)\s*(?:<\s*(\w+)\s*>)?\s*(\w+)\s*;`)
+	entryRe      = regexp.MustCompile(`\bvoid\s+(\w+)\s*\([^)]*SV_DispatchThreadID`)
+	entryPointRe = regexp.MustCompile(`\[numthreads\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*\)\]\s*(?:\[[^\]]*\]\s*)*void\s+(\w+)\s*\(([^)]*)\)`)
+	indexRe      = regexp.MustCompile(`(\w+)\s*:\s*SV_DispatchThreadID`)
+)
+
+// ParseKernel parses the kernel metadata from the given final HLSL source.
+func ParseKernel(name string, src []byte) *Kernel {
+	k := &Kernel{Name: name, File: name, Entry: "main", Workgroup: [3]int{1, 1, 1}}
+	code := StripHLSLComments(src)
+	if m := numthreadsRe.FindSubmatch(code); m != nil {
+		for i := range 3 {
+			k.Workgroup[i], _ = strconv.Atoi(string(m[i+1]))
+		}
+	}
+	if m := entryRe.FindSubmatch(code); m != nil {
+		k.Entry = string(m[1])
+	}
+	if m := indexRe.FindSubmatch(code); m != nil {
+		k.Index = string(m[1])
+		k.IndexDims = IndexDims(code, k.Index)
+	}
+	train := TrainBuffers(src)
+	for _, ln := range bytes.Split(code, []byte("\n")) {
+		m := bindingRe.FindSubmatch(ln)
+		if m == nil {
+			continue
+		}
+		b := &Buffer{Kind: string(m[3]), Type: string(m[4]), Name: string(m[5])}
+		b.Binding, _ = strconv.Atoi(string(m[1]))
+		b.Set, _ = strconv.Atoi(string(m[2]))
+		b.Access = BufferAccess(code, b.Name)
+		b.Train = slices.Contains(train, b.Name)
+		if k.IndexDims == 1 {
+			b.Indexed = slices.ContainsFunc(BufferUses(code, b.Name), func(u BufferUse) bool { return u.Index == k.Index+".x" })
+		}
+		if !strings.HasPrefix(b.Kind, "RW") {
+			b.Access &^= Write
+		}
+		k.Buffers = append(k.Buffers, b)
+	}
+	k.Hazards = AliasHazards(code, k.Buffers)
+	sort.SliceStable(k.Buffers, func(i, j int) bool {
+		bi, bj := k.Buffers[i], k.Buffers[j]
+		if bi.Set != bj.Set {
+			return bi.Set < bj.Set
+		}
+		return bi.Binding < bj.Binding
+	})
+	return k
+}
+
+// ParseKernels parses the kernels in the given final HLSL source of the
+// shader file with given name (without extension): one for each entry
+// point function, which has a numthreads attribute, optionally followed
+// by other attributes, e.g., [shader("compute")].  A file with one entry
+// point is one kernel with the name of the file, as with ParseKernel.
+// In a file with multiple entry points, each is a separate kernel named
+// file_entry, e.g., cycle_CycleNeuron, which is compiled from the file
+// with that entry point to its own .spv file, so that many small kernels
+// can share one file.  The buffers and hazards are those of the file.
+func ParseKernels(file string, src []byte) []*Kernel {
+	fk := ParseKernel(file, src)
+	code := StripHLSLComments(src)
+	ms := entryPointRe.FindAllSubmatchIndex(code, -1)
+	if len(ms) <= 1 {
+		return []*Kernel{fk}
+	}
+	ks := make([]*Kernel, len(ms))
+	for i, m := range ms {
+		k := *fk
+		k.Entry = string(code[m[8]:m[9]])
+		k.Name = file + "_" + k.Entry
+		for d := range 3 {
+			k.Workgroup[d], _ = strconv.Atoi(string(code[m[2+2*d]:m[3+2*d]]))
+		}
+		k.Index, k.IndexDims = "", 0
+		if im := indexRe.FindSubmatch(code[m[10]:m[11]]); im != nil {
+			k.Index = string(im[1])
+			k.IndexDims = IndexDims(FuncBody(code, m[1]), k.Index)
+		}
+		ks[i] = &k
+	}
+	return ks
+}
+
+// FuncBody returns the body of the function whose declaration ends
+// at the given position in the (comment-stripped) code, from its
+// opening brace to the matching closing brace.
+func FuncBody(code []byte, pos int) []byte {
+	st := bytes.IndexByte(code[pos:], '{')
+	if st < 0 {
+		return nil
+	}
+	st += pos
+	depth := 0
+	for i := st; i < len(code); i++ {
+		switch code[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return code[st : i+1]
+			}
+		}
+	}
+	return code[st:]
+}
+
+// IndexDims returns the number of dimensions of the given thread index
+// variable used in the code: 1 if only x is used, 2 for y, 3 for z.
+func IndexDims(code []byte, index string) int {
+	re := regexp.MustCompile(`\b` + regexp.QuoteMeta(index) + `\s*\.\s*([xyz]+)\b`)
+	dims := 1
+	for _, m := range re.FindAllSubmatch(code, -1) {
+		for _, c := range m[1] {
+			dims = max(dims, int(c-'x')+1)
+		}
+	}
+	return dims
+}
+
+// StripHLSLComments returns the source with all // and /* */ comments removed,
+// preserving line breaks.
+func StripHLSLComments(src []byte) []byte {
+	var out []byte
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			if i < len(src) {
+				out = append(out, '\n')
+			}
+		case src[i] == '/' && i+1 < len(src) && src[i+1] == '*':
+			i += 2
+			for i+1 < len(src) && !(src[i] == '*' && src[i+1] == '/') {
+				if src[i] == '\n' {
+					out = append(out, '\n')
+				}
+				i++
+			}
+			i++
+		default:
+			out = append(out, src[i])
+		}
+	}
+	return out
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// BufferUse is one indexed use of a buffer element in kernel code
+type BufferUse struct {
+
+	// index expression, with white space removed
+	Index string
+
+	// access to the element in this use
+	Access Access
+
+	// the whole buffer is passed as a function argument, with no Index
+	Whole bool
+}
+
+// BufferAccess returns the access to the given buffer name in the given
+// (comment-stripped) code, based on the uses of its elements: assignment to
+// an element (or a field of it) is a write, as is passing an element as a
+// function argument for an inout or out parameter (a pointer in Go) or
+// calling a method on it that assigns to the fields of its receiver (see
+// CallWrites), and passing the whole buffer to a slice parameter (see
+// slprint.ReadOnlyParams).
+func BufferAccess(code []byte, name string) Access {
+	var ac Access
+	for _, u := range BufferUses(code, name) {
+		ac |= u.Access
+	}
+	return ac
+}
+
+// BufferUses returns all of the indexed uses of elements of the given
+// buffer name in the given (comment-stripped) code, as in [BufferAccess].
+func BufferUses(code []byte, name string) []BufferUse {
+	var uses []BufferUse
+	var defs []byte // function definitions, read when needed
+	writes := func(i int, method string) bool {
+		if defs == nil {
+			defs = append(slices.Clip(code), IncludedCode(code)...)
+		}
+		return CallWrites(defs, code, i, method)
+	}
+	nm := []byte(name)
+	for st := 0; ; {
+		i := bytes.Index(code[st:], nm)
+		if i < 0 {
+			break
+		}
+		i += st
+		st = i + len(nm)
+		if (i > 0 && isIdentByte(code[i-1])) || (st < len(code) && isIdentByte(code[st])) {
+			continue
+		}
+		j := skipSpace(code, st)
+		if j >= len(code) || code[j] != '[' { // declaration or GetDimensions
+			if isArgPos(code, i) && j < len(code) && (code[j] == ',' || code[j] == ')') {
+				uses = append(uses, BufferUse{Access: Read | Write, Whole: true})
+			}
+			continue
+		}
+		u := BufferUse{Access: Read}
+		if isArgPos(code, i) && writes(i, "") {
+			u.Access |= Write
+		}
+		ie := skipBrackets(code, j)
+		u.Index = string(bytes.Join(bytes.Fields(code[j+1:max(ie-1, j+1)]), nil))
+		j = ie
+		for j < len(code) && (code[j] == '.' || isIdentByte(code[j]) || code[j] == '[') {
+			if code[j] == '[' {
+				j = skipBrackets(code, j)
+			} else {
+				j++
+			}
+		}
+		k := skipSpace(code, j)
+		rest := code[k:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("(")): // method call
+			if mi := bytes.LastIndexByte(code[ie:j], '.'); mi < 0 || writes(-1, string(code[ie+mi+1:j])) {
+				u.Access |= Write
+			}
+		case bytes.HasPrefix(rest, []byte("==")):
+		case bytes.HasPrefix(rest, []byte("=")), bytes.HasPrefix(rest, []byte("++")), bytes.HasPrefix(rest, []byte("--")):
+			u.Access |= Write
+		case len(rest) > 1 && rest[1] == '=' && bytes.IndexByte([]byte("+-*/%&|^"), rest[0]) >= 0:
+			u.Access |= Write
+		case len(rest) > 2 && rest[2] == '=' && (bytes.HasPrefix(rest, []byte("<<")) || bytes.HasPrefix(rest, []byte(">>"))):
+			u.Access |= Write
+		}
+		uses = append(uses, u)
+	}
+	return uses
+}
+
+// AliasHazards returns warnings about potential intra-dispatch read / write
+// overlap on the same buffer, based on the index expressions of its uses:
+// an element is read at a different index than where elements are written
+// (e.g., reading neighbor elements while writing the current one), or elements
+// are written at a constant index that is the same for all threads.
+// Different index expressions may of course refer to the same element,
+// so these are only potential hazards.
+func AliasHazards(code []byte, bufs []*Buffer) []string {
+	var hz []string
+	for _, b := range bufs {
+		if b.Access&Write == 0 {
+			continue
+		}
+		uses := BufferUses(code, b.Name)
+		wrs := map[string]bool{}
+		for _, u := range uses {
+			if u.Access&Write != 0 && !u.Whole {
+				wrs[u.Index] = true
+			}
+		}
+		wlist := make([]string, 0, len(wrs))
+		for ix := range wrs {
+			wlist = append(wlist, ix)
+		}
+		sort.Strings(wlist)
+		for _, ix := range wlist {
+			if _, err := strconv.Atoi(ix); err == nil {
+				hz = append(hz, fmt.Sprintf("%s: written at constant index [%s], which is the same element for all threads -- use atomics or restrict writing to one thread", b.Name, ix))
+			}
+		}
+		rds := map[string]bool{}
+		for _, u := range uses {
+			if u.Whole || wrs[u.Index] || rds[u.Index] {
+				continue
+			}
+			rds[u.Index] = true
+			hz = append(hz, fmt.Sprintf("%s: read at index [%s] while written at [%s] -- consider double buffering (separate read and write buffers) or atomics", b.Name, u.Index, strings.Join(wlist, "], [")))
+		}
+	}
+	return hz
+}
+
+// IncludedCode returns the (comment-stripped) code of the files included
+// by the given code, recursively, from the output directory, where the
+// generated files and the copied package files are, skipping those that
+// are not there.
+func IncludedCode(code []byte) []byte {
+	var inc []byte
+	done := map[string]bool{}
+	var add func(code []byte)
+	add = func(code []byte) {
+		for _, m := range includeRe.FindAllSubmatch(code, -1) {
+			fn := string(m[1])
+			if done[fn] {
+				continue
+			}
+			done[fn] = true
+			b, err := os.ReadFile(filepath.Join(GenDir(), fn))
+			if err != nil {
+				continue
+			}
+			b = StripHLSLComments(b)
+			inc = append(append(inc, '\n'), b...)
+			add(b)
+		}
+	}
+	add(code)
+	return inc
+}
+
+// CallWrites returns whether the function call with an argument at given
+// position i of the code may write it, which is the case if a function of
+// that name, defined in the given defs code, has an inout or out parameter
+// for the argument, or, for the given method name (with i -1), if a method
+// of that name assigns to the fields of its receiver (this in HLSL, from a
+// pointer receiver in Go).  Calls of functions that are not defined, e.g.,
+// intrinsics such as InterlockedAdd, may write their arguments.
+func CallWrites(defs, code []byte, i int, method string) bool {
+	if method != "" {
+		return methodWrites(defs, method, map[string]bool{})
+	}
+	fn, arg := callArg(code, i)
+	if fn == "" {
+		return true
+	}
+	found := false
+	for _, m := range funcDefRe(fn).FindAllSubmatch(defs, -1) {
+		found = true
+		params := bytes.Split(m[1], []byte(","))
+		if arg >= len(params) {
+			continue
+		}
+		if f := bytes.Fields(params[arg]); len(f) > 0 && (string(f[0]) == "inout" || string(f[0]) == "out") {
+			return true
+		}
+	}
+	return !found
+}
+
+// thisWriteRe matches an assignment to a field of the receiver of a method.
+var thisWriteRe = regexp.MustCompile(`\bthis\s*\.[\w.\[\]\s]*?(?:[-+*/%&|^]?=[^=]|<<=|>>=|\+\+|--)`)
+
+// thisCallRe matches a call of a method on the receiver of a method.
+var thisCallRe = regexp.MustCompile(`\bthis\s*\.\s*(\w+)\s*\(`)
+
+// methodWrites returns whether a method of the given name, defined in the
+// given code, assigns to the fields of its receiver, directly or by calling
+// its other methods, or true if it is not defined.
+func methodWrites(defs []byte, method string, visited map[string]bool) bool {
+	if visited[method] {
+		return false
+	}
+	visited[method] = true
+	found := false
+	for _, loc := range funcDefRe(method).FindAllIndex(defs, -1) {
+		found = true
+		body := FuncBody(defs, loc[1]-1)
+		if thisWriteRe.Match(body) {
+			return true
+		}
+		for _, m := range thisCallRe.FindAllSubmatch(body, -1) {
+			if methodWrites(defs, string(m[1]), visited) {
+				return true
+			}
+		}
+	}
+	return !found
+}
+
+// funcDefRe returns a regexp matching the definitions of functions of the
+// given name, with their parameters as the submatch.
+func funcDefRe(fn string) *regexp.Regexp {
+	return regexp.MustCompile(`\b\w+\s+` + regexp.QuoteMeta(fn) + `\s*\(([^)]*)\)\s*\{`)
+}
+
+// callArg returns the name of the function called with an argument at the
+// given position of the code, and the index of the argument.
+func callArg(code []byte, i int) (string, int) {
+	arg, depth := 0, 0
+	for j := i - 1; j >= 0; j-- {
+		switch code[j] {
+		case ')', ']':
+			depth++
+		case '[':
+			depth--
+		case ',':
+			if depth == 0 {
+				arg++
+			}
+		case '(':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			ed := j
+			for ed > 0 && (code[ed-1] == ' ' || code[ed-1] == '\t') {
+				ed--
+			}
+			st := ed
+			for st > 0 && isIdentByte(code[st-1]) {
+				st--
+			}
+			return string(code[st:ed]), arg
+		}
+	}
+	return "", 0
+}
+
+func skipSpace(code []byte, i int) int {
+	for i < len(code) && (code[i] == ' ' || code[i] == '\t' || code[i] == '\n' || code[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipBrackets returns the index just past the bracket matching
+// the one at i.
+func skipBrackets(code []byte, i int) int {
+	depth := 0
+	for ; i < len(code); i++ {
+		switch code[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// isArgPos returns true if the position i is at the start of an
+// argument to a function call, as determined by the preceding
+// non-space character being an opening paren (following an identifier)
+// or a comma.
+func isArgPos(code []byte, i int) bool {
+	j := i - 1
+	for j >= 0 && (code[j] == ' ' || code[j] == '\t' || code[j] == '\n') {
+		j--
+	}
+	if j < 0 {
+		return false
+	}
+	if code[j] == ',' {
+		return true
+	}
+	if code[j] != '(' {
+		return false
+	}
+	j--
+	for j >= 0 && code[j] == ' ' {
+		j--
+	}
+	ed := j + 1
+	for j >= 0 && isIdentByte(code[j]) {
+		j--
+	}
+	switch string(code[j+1 : ed]) {
+	case "", "if", "for", "while", "switch", "return":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseKernel(t *testing.T) {
+	src := []byte(`
+// note: binding is var, set
+[[vk::binding(0, 0)]] RWStructuredBuffer<Layer> Layers;
+[[vk::binding(0, 1)]] RWStructuredBuffer<Time> time;
+[[vk::binding(1, 1)]] StructuredBuffer<Index> Indexes;
+[[vk::binding(0, 2)]] RWStructuredBuffer<Neuron> Neurons;
+// [[vk::binding(0, 3)]] RWStructuredBuffer<Synapse> Synapses;
+
+[numthreads(64, 1, 1)]
+void main(uint3 idx : SV_DispatchThreadID) {
+	uint ns;
+	uint st;
+	Neurons.GetDimensions(ns, st);
+	if(idx.x < ns) {
+		float ge = Layers[Indexes[idx.x].X].Ge;
+		Neurons[idx.x].Ge = ge; // Synapses[idx.x].Wt = 0;
+		CycleTime(time[0]);
+	}
+}
+`)
+	k := ParseKernel("axon", src)
+	if k.Entry != "main" || k.Workgroup != [3]int{64, 1, 1} {
+		t.Errorf("wrong entry or workgroup: %s %v", k.Entry, k.Workgroup)
+	}
+	exp := []struct {
+		name   string
+		set    int
+		access Access
+	}{{"Layers", 0, Read}, {"time", 1, Read | Write}, {"Indexes", 1, Read}, {"Neurons", 2, Read | Write}}
+	if len(k.Buffers) != len(exp) {
+		t.Fatalf("expected %d buffers, got %d", len(exp), len(k.Buffers))
+	}
+	for i, e := range exp {
+		b := k.Buffers[i]
+		if b.Name != e.name || b.Set != e.set || b.Access != e.access {
+			t.Errorf("buffer %d: expected %s set %d %s, got %s set %d %s", i, e.name, e.set, e.access, b.Name, b.Set, b.Access)
+		}
+	}
+}
+
+func TestAliasHazards(t *testing.T) {
+	src := []byte(`
+[[vk::binding(0, 0)]] RWStructuredBuffer<Neuron> Neurons;
+[[vk::binding(1, 0)]] RWStructuredBuffer<Neuron> NeuronsOut;
+
+[numthreads(64, 1, 1)]
+void main(uint3 idx : SV_DispatchThreadID) {
+	float ge = Neurons[idx.x - 1].Act + Neurons[ idx.x + 1 ].Act;
+	Neurons[idx.x].Ge = ge;
+	NeuronsOut[idx.x].Ge = Neurons[idx.x].Ge;
+}
+`)
+	k := ParseKernel("gather", src)
+	if len(k.Hazards) != 2 {
+		t.Errorf("expected 2 hazards, got: %v", k.Hazards)
+	}
+}
+
+func TestParseKernels(t *testing.T) {
+	src := []byte(`
+[[vk::binding(0, 0)]] RWStructuredBuffer<float> Data;
+
+[numthreads(64, 1, 1)]
+void Double(uint3 idx : SV_DispatchThreadID) {
+	Data[idx.x] *= 2;
+}
+
+// [numthreads(1, 1, 1)]
+// void Commented(uint3 idx : SV_DispatchThreadID) {}
+
+[numthreads(8, 8, 1)]
+[shader("compute")]
+void Square(uint3 gid : SV_DispatchThreadID) {
+	Data[gid.y * 8 + gid.x] *= Data[gid.x];
+}
+`)
+	ks := ParseKernels("multi", src)
+	if len(ks) != 2 {
+		t.Fatalf("expected 2 kernels, got %d", len(ks))
+	}
+	exp := []struct {
+		name, entry, index string
+		wg                 [3]int
+		dims               int
+	}{{"multi_Double", "Double", "idx", [3]int{64, 1, 1}, 1}, {"multi_Square", "Square", "gid", [3]int{8, 8, 1}, 2}}
+	for i, e := range exp {
+		k := ks[i]
+		if k.Name != e.name || k.File != "multi" || k.Entry != e.entry || k.Index != e.index || k.Workgroup != e.wg || k.IndexDims != e.dims || len(k.Buffers) != 1 {
+			t.Errorf("kernel %d: got %s %s %s %s %v %d", i, k.Name, k.File, k.Entry, k.Index, k.Workgroup, k.IndexDims)
+		}
+	}
+	ks = ParseKernels("one", []byte("[numthreads(64, 1, 1)]\nvoid main(uint3 idx : SV_DispatchThreadID) {\n}\n"))
+	if len(ks) != 1 || ks[0].Name != "one" || ks[0].File != "one" || ks[0].Entry != "main" {
+		t.Errorf("wrong single kernel: %+v", ks)
+	}
+}
+
+// TestExampleHazards checks that the kernels of the examples are generated,
+// including the sendspike and synlearn projection kernels of axon, and
+// that, as they only write the elements of their thread index, they have
+// no potential alias hazards: in particular that calls of methods that do
+// not assign to their receivers, e.g., Params[0].IntegFromRaw in basic,
+// and arguments passed by value, e.g., to RndGen in rand, are reads.  The
+// axon example, with the chans region, has one: the cycle kernel passes
+// time[0] to an inout parameter in all threads, which all write it back.
+func TestExampleHazards(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	od, dxc := *outDir, *dxcPath
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		*outDir, *dxcPath = od, dxc
+		ResetState()
+	})
+	*dxcPath = ToolNone
+	for _, ex := range []struct {
+		dir     string
+		kernels []string
+		hazards map[string]int
+		files   []string
+	}{
+		{"basic", []string{"basic"}, nil, []string{"compute.go"}},
+		{"rand", []string{"rand"}, nil, []string{"rand.go", "rand.hlsl"}},
+		{"axon", []string{"cycle", "sendspike", "synlearn"}, map[string]int{"cycle": 1}, []string{"cogentcore.org/core/math32/fastexp.go", "minmax", "chans/chans.go", "chans", "kinase", "time.go", "neuron.go", "act.go", "learn.go", "layer.go", "prjn.go", "cpu.go", "cycle.hlsl", "sendspike.hlsl", "synlearn.hlsl"}},
+	} {
+		if err := os.Chdir(filepath.Join(wd, "examples", ex.dir)); err != nil {
+			t.Fatal(err)
+		}
+		*outDir = filepath.Join("shaders", "hazardtest")
+		os.MkdirAll(*outDir, 0755)
+		ResetState()
+		_, err := ProcessFiles(ex.files)
+		os.RemoveAll(*outDir)
+		if err != nil {
+			t.Errorf("%s: %v", ex.dir, err)
+			continue
+		}
+		for _, kn := range ex.kernels {
+			if Kernels[kn] == nil {
+				t.Errorf("%s: kernel %s not generated", ex.dir, kn)
+			}
+		}
+		for _, k := range SortedKernels() {
+			if len(k.Hazards) != ex.hazards[k.Name] {
+				t.Errorf("%s: kernel %s: hazards: %v", ex.dir, k.Name, k.Hazards)
+			}
+		}
+	}
+}
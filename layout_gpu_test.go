@@ -0,0 +1,10 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build gpu
+
+package main
+
+// registers the vgpu runtime for: go test -tags gpu -run FuzzStructLayout -gpu vgpu
+import _ "github.com/emer/gosl/v2/slgpu/slvgpu"
@@ -0,0 +1,346 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"go/types"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emer/gosl/v2/alignsl"
+	"github.com/emer/gosl/v2/slgpu"
+	"golang.org/x/tools/go/packages"
+)
+
+var gpuRuntime = flag.String("gpu", "", "slgpu runtime for the GPU roundtrip of FuzzStructLayout, e.g., vgpu, which must be registered by building with -tags gpu")
+
+// layoutField is a field of a random struct type
+type layoutField struct {
+
+	// field name
+	Name string
+
+	// Go type: a 32 bit basic type, sltype vector, or an earlier struct
+	Type string
+
+	// array length, 0 if not an array
+	Len int
+}
+
+// layoutStruct is a random struct type
+type layoutStruct struct {
+	Name   string
+	Fields []layoutField
+}
+
+// layoutTypes are the Go types of the fields of the random structs,
+// with their HLSL types, sizes and alignments.
+var layoutTypes = []struct {
+	Go, HLSL    string
+	Size, Align int
+}{
+	{"float32", "float", 4, 4},
+	{"int32", "int", 4, 4},
+	{"uint32", "uint", 4, 4},
+	{"slbool.Bool4", "uint", 4, 4},
+	{"sltype.Float2", "float2", 8, 8},
+	{"sltype.Uint2", "uint2", 8, 8},
+	{"sltype.Float4", "float4", 16, 16},
+	{"sltype.Uint4", "uint4", 16, 16},
+}
+
+// genLayout returns random struct types, each of which can have fields
+// of the previous ones, padded as required by alignsl, except that
+// vectors are sometimes misaligned, which alignsl must then report.
+func genLayout(rnd *rand.Rand) []*layoutStruct {
+	var lss []*layoutStruct
+	sizes := map[string]int{}
+	for si := range 1 + rnd.Intn(3) {
+		ls := &layoutStruct{Name: fmt.Sprintf("S%d", si)}
+		off, npad := 0, 0
+		pad := func(align int) {
+			for off%align != 0 {
+				ls.Fields = append(ls.Fields, layoutField{Name: fmt.Sprintf("pad%d", npad), Type: "int32"})
+				npad++
+				off += 4
+			}
+		}
+		for fi := range 1 + rnd.Intn(8) {
+			f := layoutField{Name: fmt.Sprintf("F%d", fi)}
+			size, align := 0, 16
+			if si > 0 && rnd.Intn(4) == 0 {
+				f.Type = lss[rnd.Intn(si)].Name
+				size = sizes[f.Type]
+			} else {
+				lt := layoutTypes[rnd.Intn(len(layoutTypes))]
+				f.Type, size, align = lt.Go, lt.Size, lt.Align
+				if align > 4 && rnd.Intn(8) == 0 {
+					align = 4 // misaligned vector
+				}
+			}
+			if rnd.Intn(4) == 0 {
+				f.Len = 1 + rnd.Intn(4)
+				size *= f.Len
+			}
+			pad(align)
+			ls.Fields = append(ls.Fields, f)
+			off += size
+		}
+		pad(16)
+		sizes[ls.Name] = off
+		lss = append(lss, ls)
+	}
+	return lss
+}
+
+// layoutSource returns the Go source of the random struct types in
+// the fuzzlayout region, with a kernel that copies the last one.
+func layoutSource(lss []*layoutStruct) string {
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"github.com/emer/gosl/v2/slbool\"\n\t\"github.com/emer/gosl/v2/sltype\"\n)\n\nvar _ sltype.Float2\nvar _ slbool.Bool4\n\n//gosl: start fuzzlayout\n\n")
+	for _, ls := range lss {
+		fmt.Fprintf(&b, "type %s struct {\n", ls.Name)
+		for _, f := range ls.Fields {
+			if f.Len > 0 {
+				fmt.Fprintf(&b, "\t%s [%d]%s\n", f.Name, f.Len, f.Type)
+			} else {
+				fmt.Fprintf(&b, "\t%s %s\n", f.Name, f.Type)
+			}
+		}
+		b.WriteString("}\n\n")
+	}
+	b.WriteString("//gosl: end fuzzlayout\n\n//gosl: hlsl fuzzlayout\n")
+	top := lss[len(lss)-1].Name
+	fmt.Fprintf(&b, "// [[vk::binding(0, 0)]] RWStructuredBuffer<%s> In;\n// [[vk::binding(0, 1)]] RWStructuredBuffer<%s> Out;\n", top, top)
+	b.WriteString("// [numthreads(64, 1, 1)]\n// void main(uint3 idx : SV_DispatchThreadID) {\n// \tOut[idx.x] = In[idx.x];\n// }\n//gosl: end fuzzlayout\n")
+	return b.String()
+}
+
+// hlslMember is a member of an HLSL struct
+type hlslMember struct {
+	Type, Name string
+	Len        int
+}
+
+// parseHLSLStructs returns the members of the structs in given HLSL code
+func parseHLSLStructs(code string) map[string][]hlslMember {
+	sts := map[string][]hlslMember{}
+	cur := ""
+	for _, ln := range strings.Split(code, "\n") {
+		ln = strings.TrimSpace(ln)
+		switch {
+		case strings.HasPrefix(ln, "struct "):
+			cur = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(ln, "struct "), "{"))
+			sts[cur] = nil
+		case ln == "};":
+			cur = ""
+		case cur == "" || ln == "" || strings.HasPrefix(ln, "//"):
+		default:
+			typ, names, _ := strings.Cut(strings.TrimSuffix(ln, ";"), " ")
+			for _, nm := range strings.Split(names, ",") {
+				m := hlslMember{Type: typ, Name: strings.TrimSpace(nm)}
+				if nm, n, ok := strings.Cut(m.Name, "["); ok {
+					m.Name = nm
+					fmt.Sscanf(n, "%d]", &m.Len)
+				}
+				sts[cur] = append(sts[cur], m)
+			}
+		}
+	}
+	return sts
+}
+
+// hlslLayout returns the size and alignment of given HLSL type in a
+// Storage buffer (std430 rules), and the offsets of its members if a struct.
+func hlslLayout(sts map[string][]hlslMember, typ string) (size, align int, offs []int) {
+	for _, lt := range layoutTypes {
+		if lt.HLSL == typ {
+			return lt.Size, lt.Align, nil
+		}
+	}
+	off := 0
+	align = 4
+	for _, m := range sts[typ] {
+		msz, mal, _ := hlslLayout(sts, m.Type)
+		if m.Len > 0 {
+			msz = m.Len * ((msz + mal - 1) / mal * mal)
+		}
+		off = (off + mal - 1) / mal * mal
+		offs = append(offs, off)
+		off += msz
+		align = max(align, mal)
+	}
+	return (off + align - 1) / align * align, align, offs
+}
+
+// goLeafBytes writes random values for the 32 bit fields of the given Go
+// type at given offset in b, with normal floats that survive a GPU copy.
+func goLeafBytes(rnd *rand.Rand, sizes types.Sizes, typ types.Type, b []byte) {
+	switch ut := typ.Underlying().(type) {
+	case *types.Basic:
+		v := rnd.Uint32()
+		if ut.Kind() == types.Float32 {
+			v = math.Float32bits(float32(rnd.NormFloat64()) + 0.5)
+		}
+		binary.LittleEndian.PutUint32(b, v)
+	case *types.Array:
+		esz := int(sizes.Sizeof(ut.Elem()))
+		for i := range int(ut.Len()) {
+			goLeafBytes(rnd, sizes, ut.Elem(), b[i*esz:])
+		}
+	case *types.Struct:
+		var flds []*types.Var
+		for i := range ut.NumFields() {
+			flds = append(flds, ut.Field(i))
+		}
+		for i, off := range sizes.Offsetsof(flds) {
+			goLeafBytes(rnd, sizes, flds[i].Type(), b[off:])
+		}
+	}
+}
+
+// FuzzStructLayout generates random struct types and runs them through
+// the whole pipeline, verifying that the HLSL struct members are in the
+// same order with the same types as the Go fields, and that the HLSL
+// layout in a Storage buffer is the same as the Go layout, whenever
+// alignsl accepts the Go types.  With -gpu, the last struct type is also
+// copied by a kernel on the GPU, which must roundtrip byte-identical.
+func FuzzStructLayout(f *testing.F) {
+	for _, seed := range []int64{1, 2, 3, 5, 8, 13} {
+		f.Add(seed)
+	}
+	if *gpuRuntime == "" {
+		dxc := *dxcPath
+		*dxcPath = ToolNone
+		defer func() { *dxcPath = dxc }()
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		rnd := rand.New(rand.NewSource(seed))
+		lss := genLayout(rnd)
+		src := layoutSource(lss)
+		fn := filepath.Join(t.TempDir(), "fuzzlayout.go")
+		if err := os.WriteFile(fn, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		pkgs, err := packages.Load(LoadConfig(packages.NeedName|packages.NeedTypes|packages.NeedTypesSizes), "file="+fn)
+		if err != nil || len(pkgs) != 1 || len(pkgs[0].Errors) > 0 {
+			t.Fatalf("loading generated types: %v %v\n%s", err, pkgs, src)
+		}
+		pkg := pkgs[0]
+		if err := alignsl.CheckPackage(pkg); err != nil {
+			t.Skipf("alignsl rejects the generated types: %v", err)
+		}
+		t.Cleanup(func() {
+			for _, ext := range []string{".go", ".hlsl", ".spv"} {
+				os.Remove(filepath.Join(*outDir, "fuzzlayout"+ext))
+			}
+		})
+		sls, err := ProcessFiles([]string{fn})
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := string(sls["fuzzlayout"])
+		sts := parseHLSLStructs(code)
+		for _, ls := range lss {
+			ms := sts[ls.Name]
+			if len(ms) != len(ls.Fields) {
+				t.Fatalf("%s: %d HLSL members != %d Go fields\n%s\n%s", ls.Name, len(ms), len(ls.Fields), src, code)
+			}
+			for i, fl := range ls.Fields {
+				ht := fl.Type
+				for _, lt := range layoutTypes {
+					if lt.Go == fl.Type {
+						ht = lt.HLSL
+					}
+				}
+				if m := ms[i]; m.Name != fl.Name || m.Type != ht || m.Len != fl.Len {
+					t.Fatalf("%s: HLSL member %d: %+v != Go field %+v\n%s", ls.Name, i, m, fl, code)
+				}
+			}
+			st := pkg.Types.Scope().Lookup(ls.Name).Type().Underlying().(*types.Struct)
+			var flds []*types.Var
+			for i := range st.NumFields() {
+				flds = append(flds, st.Field(i))
+			}
+			goOffs := pkg.TypesSizes.Offsetsof(flds)
+			size, _, offs := hlslLayout(sts, ls.Name)
+			if gsz := int(pkg.TypesSizes.Sizeof(st)); size != gsz {
+				t.Errorf("%s: HLSL size %d != Go size %d", ls.Name, size, gsz)
+			}
+			for i := range offs {
+				if offs[i] != int(goOffs[i]) {
+					t.Errorf("%s.%s: HLSL offset %d != Go offset %d", ls.Name, ls.Fields[i].Name, offs[i], goOffs[i])
+				}
+			}
+		}
+		if t.Failed() {
+			t.Logf("Go:\n%s\nHLSL:\n%s", src, code)
+		}
+		if *gpuRuntime != "" {
+			layoutGPU(t, rnd, pkg, lss[len(lss)-1].Name)
+		}
+	})
+}
+
+// layoutGPU copies random values of the given struct type with the
+// fuzzlayout kernel on the GPU, and checks that they roundtrip.
+func layoutGPU(t *testing.T, rnd *rand.Rand, pkg *packages.Package, top string) {
+	spv := filepath.Join(*outDir, "fuzzlayout.spv")
+	if _, err := os.Stat(spv); err != nil {
+		t.Skipf("kernel not compiled (is dxc available?): %v", err)
+	}
+	rt, err := slgpu.New(*gpuRuntime)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer rt.Release()
+	typ := pkg.Types.Scope().Lookup(top).Type()
+	esz := int(pkg.TypesSizes.Sizeof(typ))
+	const n = 64
+	in := make([]byte, n*esz)
+	for i := range n {
+		goLeafBytes(rnd, pkg.TypesSizes, typ, in[i*esz:])
+	}
+	out := make([]byte, n*esz)
+	if err := rt.CreateBuffer("In", 0, 0, esz, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.CreateBuffer("Out", 1, 0, esz, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.AddKernel("fuzzlayout", spv); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Config(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Upload("In", in); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Upload("Out", out); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Dispatch("fuzzlayout", 1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Readback("Out", out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(in, out) {
+		for i := range in {
+			if in[i] != out[i] {
+				t.Fatalf("GPU copy of %s differs at element %d, byte offset %d", top, i/esz, i%esz)
+			}
+		}
+	}
+}
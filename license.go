@@ -0,0 +1,131 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// HeaderConfig configures the license and attribution header that is
+// added at the start of every generated HLSL and Go file, for downstream
+// packages that ship them with licensing requirements, e.g.:
+//
+//	"Header": {
+//		"Template": "Copyright (c) 2024 Acme Inc.\nSPDX-License-Identifier: {{.License}}\nGenerated by gosl from: {{.Source}}",
+//		"License": "Apache-2.0",
+//		"Licenses": {"chans": "MIT"}
+//	}
+//
+// Each line of the template is commented out with //.  The template
+// fields are File, the name of the generated file, Name, the name of its
+// kernel or region, Source, the comma-separated Go source files of the
+// region, if any, and License.
+type HeaderConfig struct {
+
+	// text/template of the header, with the File, Name, Source and
+	// License fields -- no header is added if empty
+	Template string
+
+	// license of the generated files, e.g., an SPDX identifier,
+	// which is also recorded in the -manifest
+	License string
+
+	// licenses of the files of specific kernels or regions, by name,
+	// which override the License, e.g., for code with other
+	// attribution requirements
+	Licenses map[string]string
+}
+
+// HeaderFields are the fields of the HeaderConfig Template
+type HeaderFields struct {
+
+	// name of the generated file, e.g., axon.hlsl
+	File string
+
+	// name of the kernel or region of the file, e.g., axon
+	Name string
+
+	// comma-separated Go source files of the region, if any
+	Source string
+
+	// license of the file
+	License string
+}
+
+// headerTemplate is the parsed HeaderConfig Template, set by ConfigArgs
+var headerTemplate *template.Template
+
+// ParseHeaderTemplate parses the Template of the given HeaderConfig,
+// which is nil if it is empty.
+func ParseHeaderTemplate(hc *HeaderConfig) (*template.Template, error) {
+	if hc.Template == "" {
+		return nil, nil
+	}
+	tm, err := template.New("Header").Option("missingkey=error").Parse(hc.Template)
+	if err != nil {
+		return nil, fmt.Errorf("gosl: config Header.Template: %w", err)
+	}
+	return tm, nil
+}
+
+// LicenseFor returns the license of the kernel or region with
+// given name: the Licenses override if any, or the default License.
+func LicenseFor(name string) string {
+	if lc, ok := GoslConfig.Header.Licenses[name]; ok {
+		return lc
+	}
+	return GoslConfig.Header.License
+}
+
+// GenHeader returns the header of the generated file with given name,
+// for the kernel or region with given name, as line comments, followed
+// by a blank line, or nil if there is no Template, or the file type
+// does not support line comments.
+func GenHeader(file, name string) ([]byte, error) {
+	if headerTemplate == nil {
+		return nil, nil
+	}
+	switch filepath.Ext(file) {
+	case ".go", ".hlsl", ".wgsl", ".glsl", ".metal":
+	default:
+		return nil, nil
+	}
+	var srcs []string
+	for _, sf := range RegionSources[name] {
+		srcs = append(srcs, filepath.ToSlash(sf))
+	}
+	hf := HeaderFields{File: file, Name: name, Source: strings.Join(srcs, ", "), License: LicenseFor(name)}
+	var tb bytes.Buffer
+	if err := headerTemplate.Execute(&tb, &hf); err != nil {
+		return nil, fmt.Errorf("gosl: config Header.Template: %w", err)
+	}
+	var b bytes.Buffer
+	for _, ln := range strings.Split(strings.TrimRight(tb.String(), "\n"), "\n") {
+		if ln = strings.TrimRight(ln, " \t"); ln == "" {
+			b.WriteString("//\n")
+		} else {
+			b.WriteString("// " + ln + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// WriteGenerated writes the given generated source to the file at path,
+// for the kernel or region with given name, with the header of the
+// HeaderConfig, if any.
+func WriteGenerated(path, name string, src []byte) error {
+	hdr, err := GenHeader(filepath.Base(path), name)
+	if err != nil {
+		return err
+	}
+	RecordOutput(path)
+	return os.WriteFile(path, append(hdr, src...), 0644)
+}
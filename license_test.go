@@ -0,0 +1,44 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenHeader(t *testing.T) {
+	gc, ht := GoslConfig, headerTemplate
+	defer func() { GoslConfig, headerTemplate = gc, ht }()
+	var err error
+	if err = ParseConfig("gosl.json", []byte(`{"Header": {"Template": "Copyright (c) Acme\n\nSPDX-License-Identifier: {{.License}}\nfrom: {{.Source}} ({{.File}})", "License": "Apache-2.0", "Licenses": {"chans": "MIT"}}}`), &GoslConfig); err != nil {
+		t.Fatal(err)
+	}
+	if headerTemplate, err = ParseHeaderTemplate(&GoslConfig.Header); err != nil {
+		t.Fatal(err)
+	}
+	RegionSources["chans"] = []string{"chans/chans.go"}
+	defer delete(RegionSources, "chans")
+
+	hdr, err := GenHeader("chans.hlsl", "chans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "// Copyright (c) Acme\n//\n// SPDX-License-Identifier: MIT\n// from: chans/chans.go (chans.hlsl)\n\n"
+	if string(hdr) != want {
+		t.Errorf("got header:\n%s\nwant:\n%s", hdr, want)
+	}
+	if hdr, _ := GenHeader("axon.go", "axon"); !strings.Contains(string(hdr), "SPDX-License-Identifier: Apache-2.0\n") {
+		t.Errorf("default license not in header:\n%s", hdr)
+	}
+	if hdr, _ := GenHeader("axon.spv", "axon"); hdr != nil {
+		t.Errorf("header for a binary file: %q", hdr)
+	}
+
+	err = ParseConfig("gosl.json", []byte(`{"Header": {"Template": "{{.Lisense"}}`), &Config{})
+	if err == nil || !strings.Contains(err.Error(), "Header.Template") {
+		t.Errorf("expected Header.Template error, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"slices"
+)
+
+// LineBuffer has the lines of a source file, for editing passes that
+// insert, delete and move lines, which always update Lines in place,
+// so that the editing passes never operate on a stale or aliased copy.
+// Line ranges are [st, ed) line indexes.
+type LineBuffer struct {
+
+	// the lines, without the newlines
+	Lines [][]byte
+}
+
+// NewLineBuffer returns a new LineBuffer with the lines of given source
+func NewLineBuffer(src []byte) *LineBuffer {
+	return &LineBuffer{Lines: bytes.Split(src, []byte("\n"))}
+}
+
+// Len returns the number of lines
+func (lb *LineBuffer) Len() int {
+	return len(lb.Lines)
+}
+
+// Bytes returns the source, with the lines joined by newlines
+func (lb *LineBuffer) Bytes() []byte {
+	return bytes.Join(lb.Lines, []byte("\n"))
+}
+
+// Insert inserts the given lines before line at, which can be Len
+// to add them at the end.
+func (lb *LineBuffer) Insert(at int, lns ...[]byte) {
+	lb.Lines = slices.Insert(lb.Lines, at, lns...)
+}
+
+// Delete deletes lines [st, ed)
+func (lb *LineBuffer) Delete(st, ed int) {
+	lb.Lines = slices.Delete(lb.Lines, st, ed)
+}
+
+// Move moves lines [st, ed) to before line to, as indexed before the
+// move, which must not be within the moved lines: to <= st or to >= ed.
+func (lb *LineBuffer) Move(to, st, ed int) {
+	if to > st && to < ed {
+		panic("LineBuffer.Move: destination is within the moved lines")
+	}
+	mv := slices.Clone(lb.Lines[st:ed])
+	lb.Delete(st, ed)
+	if to >= ed {
+		to -= ed - st
+	}
+	lb.Insert(to, mv...)
+}
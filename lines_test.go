@@ -0,0 +1,36 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestLineBuffer(t *testing.T) {
+	lb := NewLineBuffer([]byte("a\nb\nc\nd\ne"))
+	check := func(op, want string) {
+		t.Helper()
+		if got := string(lb.Bytes()); got != want {
+			t.Errorf("%s: got %q, want %q", op, got, want)
+		}
+	}
+	lb.Move(1, 3, 5)
+	check("Move up", "a\nd\ne\nb\nc")
+	lb.Move(5, 0, 2)
+	check("Move down", "e\nb\nc\na\nd")
+	lb.Delete(1, 3)
+	check("Delete", "e\na\nd")
+	lb.Insert(1, []byte("x"), []byte("y"))
+	lb.Insert(lb.Len(), []byte("z"))
+	check("Insert", "e\nx\ny\na\nd\nz")
+
+	// edits must not alias the lines of a previous split of the same source
+	src := []byte("1\n2\n3")
+	lb = NewLineBuffer(src)
+	orig := NewLineBuffer(src)
+	lb.Delete(0, 1)
+	lb.Insert(0, []byte("0"))
+	if got := string(orig.Bytes()); got != "1\n2\n3" {
+		t.Errorf("other buffer changed: %q", got)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/gosl/v2/slmeta"
+)
+
+// NewMeta returns the slmeta.Meta of the Kernels and InterfaceStructs
+// in the current run.
+func NewMeta() *slmeta.Meta {
+	m := &slmeta.Meta{}
+	for _, k := range SortedKernels() {
+		mk := slmeta.Kernel{Name: k.Name, Entry: k.Entry, Workgroup: k.Workgroup, SPV: filepath.ToSlash(filepath.Join(*outDir, OutputPath(k.Name)+".spv")), Variants: k.Variants}
+		for _, b := range k.Buffers {
+			mk.Buffers = append(mk.Buffers, slmeta.Buffer{Name: b.Name, Set: b.Set, Binding: b.Binding, Kind: b.Kind, Type: b.Type, Train: b.Train})
+		}
+		if k.TestMode {
+			mk.TestSPV = filepath.ToSlash(filepath.Join(*outDir, OutputPath(slmeta.TestName(k.Name))+".spv"))
+		}
+		m.Kernels = append(m.Kernels, mk)
+	}
+	for _, sl := range InterfaceStructs {
+		ms := slmeta.Struct{Name: sl.Name, Size: sl.Size}
+		for _, f := range sl.Fields {
+			ms.Fields = append(ms.Fields, slmeta.Field{Name: f.Name, Type: f.Type, Offset: f.Offset, Size: f.Size})
+		}
+		m.Structs = append(m.Structs, ms)
+	}
+	m.SetHashes()
+	return m
+}
+
+// GenMeta writes the slmeta.Meta of the generated kernels to the given
+// Go file path, e.g., gosl_meta.go in the model package, as a GetMeta
+// function returning it, and as JSON in a GoslMetaJSON constant.
+func GenMeta(path string) error {
+	pnm, _ := DocPackageName(path)
+	m := NewMeta()
+	js, err := m.JSON()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pnm)
+	b.WriteString("import \"github.com/emer/gosl/v2/slmeta\"\n\n")
+	b.WriteString("// GetMeta returns the metadata of the GPU kernels generated by gosl:\n// the kernels, their buffers and workgroup sizes, and the layouts of\n// the buffer struct types, with hashes for validation.\n// It returns a new Meta at each call, which the caller owns.\n")
+	b.Write(MetaLiteral(m))
+	b.WriteString("// GoslMetaJSON is the metadata of the GPU kernels generated by gosl,\n// as returned by GetMeta, in JSON.\n")
+	fmt.Fprintf(&b, "const GoslMetaJSON = `%s`\n", js)
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	return WriteGenerated(path, "meta", src)
+}
+
+// MetaLiteral returns the Go source of a GetMeta function returning
+// a new slmeta.Meta with the given value at each call, rather than a
+// package-level variable that would be shared by all of its callers.
+func MetaLiteral(m *slmeta.Meta) []byte {
+	lit := func(v any) string {
+		s := fmt.Sprintf("%#v", v)
+		return s[strings.Index(s, "{"):]
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "func GetMeta() *slmeta.Meta {\n\treturn &slmeta.Meta{\n\tHash: %q,\n\tKernels: []slmeta.Kernel{\n", m.Hash)
+	for _, k := range m.Kernels {
+		fmt.Fprintf(&b, "\t\t{Name: %q, Entry: %q, Workgroup: [3]int%s, SPV: %q,", k.Name, k.Entry, lit(k.Workgroup), k.SPV)
+		if len(k.Variants) > 0 {
+			fmt.Fprintf(&b, " Variants: []int%s,", lit(k.Variants))
+		}
+		if k.TestSPV != "" {
+			fmt.Fprintf(&b, " TestSPV: %q,", k.TestSPV)
+		}
+		b.WriteString("\n\t\t\tBuffers: []slmeta.Buffer{\n")
+		for _, bf := range k.Buffers {
+			fmt.Fprintf(&b, "\t\t\t\t{Name:%q, Set:%d, Binding:%d, Kind:%q, Type:%q", bf.Name, bf.Set, bf.Binding, bf.Kind, bf.Type)
+			if bf.Train {
+				b.WriteString(", Train:true")
+			}
+			b.WriteString("},\n")
+		}
+		b.WriteString("\t\t\t}},\n")
+	}
+	b.WriteString("\t},\n\tStructs: []slmeta.Struct{\n")
+	for _, st := range m.Structs {
+		fmt.Fprintf(&b, "\t\t{Name: %q, Size: %d, Hash: %q,\n\t\t\tFields: []slmeta.Field{\n", st.Name, st.Size, st.Hash)
+		for _, f := range st.Fields {
+			fmt.Fprintf(&b, "\t\t\t\t%s,\n", lit(f))
+		}
+		b.WriteString("\t\t\t}},\n")
+	}
+	b.WriteString("\t},\n}\n}\n\n")
+	return b.Bytes()
+}
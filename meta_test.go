@@ -0,0 +1,38 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/emer/gosl/v2/slmeta"
+)
+
+func TestMetaLiteral(t *testing.T) {
+	m := &slmeta.Meta{
+		Kernels: []slmeta.Kernel{{Name: "axon", Entry: "main", Workgroup: [3]int{64, 1, 1}, SPV: "shaders/axon.spv", Variants: []int{32, 64},
+			Buffers: []slmeta.Buffer{{Name: "Neurons", Set: 1, Binding: 0, Kind: "RWStructuredBuffer", Type: "Neuron"}}}},
+		Structs: []slmeta.Struct{{Name: "Neuron", Size: 16, Fields: []slmeta.Field{{Name: "Act", Type: "float32", Offset: 0, Size: 4}}}},
+	}
+	m.SetHashes()
+	src := "package axon\n\n" + string(MetaLiteral(m))
+	if _, err := parser.ParseFile(token.NewFileSet(), "gosl_meta.go", src, 0); err != nil {
+		t.Fatalf("%v:\n%s", err, src)
+	}
+	for _, want := range []string{
+		"func GetMeta() *slmeta.Meta {\n\treturn &slmeta.Meta{\n",
+		`Hash: "` + m.Hash + `"`,
+		`Workgroup: [3]int{64, 1, 1}, SPV: "shaders/axon.spv", Variants: []int{32, 64},`,
+		`{Name:"Neurons", Set:1, Binding:0, Kind:"RWStructuredBuffer", Type:"Neuron"},`,
+		`{Name: "Neuron", Size: 16, Hash: "` + m.Structs[0].Hash + `",`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("missing %s in:\n%s", want, src)
+		}
+	}
+}
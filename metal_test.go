@@ -0,0 +1,61 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/emer/gosl/v2/alignsl"
+)
+
+func TestMetal(t *testing.T) {
+	tg := *target
+	*target = TargetMetal
+	defer func() { *target = tg }()
+	runTest(t, "testdata/metal/basic.go", "testdata/metal/basic.golden")
+	runTest(t, "testdata/metal/errors.go", "testdata/metal/errors.golden")
+}
+
+func TestMSLSizes(t *testing.T) {
+	src := `package p
+
+type Vec3 struct{ X, Y, Z float32 }
+
+type Col struct {
+	A, B, C, E float32
+	Col        Vec3
+	D          float32
+}
+`
+	pkg := testPackage(t, "p.go", src).Types
+	col := pkg.Scope().Lookup("Col").Type().Underlying().(*types.Struct)
+	var flds []*types.Var
+	for i := range col.NumFields() {
+		flds = append(flds, col.Field(i))
+	}
+	ms := alignsl.MSLSizes{alignsl.GPUSizes{types.SizesFor("gc", "amd64")}}
+	offs := ms.Offsetsof(flds)
+	want := []int64{0, 4, 8, 12, 16, 32} // float3 is 16 bytes in MSL, 12 in WGSL
+	for i := range want {
+		if offs[i] != want[i] {
+			t.Errorf("offset of %s: got %d, want %d", flds[i].Name(), offs[i], want[i])
+		}
+	}
+	if sz := ms.Sizeof(col); sz != 48 {
+		t.Errorf("size of Col: got %d, want 48", sz)
+	}
+
+	cx := alignsl.NewContext(types.SizesFor("gc", "amd64"))
+	cx.WGSL = true
+	if alignsl.CheckStruct(cx, col, "Col") {
+		t.Errorf("unexpected WGSL errors for Col: %v", cx.Errs)
+	}
+	cx = alignsl.NewContext(types.SizesFor("gc", "amd64"))
+	cx.WGSL, cx.MSL = true, true
+	if !alignsl.CheckStruct(cx, col, "Col") {
+		t.Error("no MSL errors for Col, with D after a float3")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/emer/gosl/v2/slmeta"
+)
+
+// TestDefine is the macro defined in the test mode variants of the
+// kernels, for excluding the code that uses the training buffers,
+// with #ifndef GOSL_TEST ... #endif
+const TestDefine = "GOSL_TEST"
+
+var (
+	// trainRe matches the directive at the end of the binding of a
+	// buffer that is only used in the training mode
+	trainRe = regexp.MustCompile(`//\s*gosl:\s*train\s*$`)
+
+	// ifTestRe matches the start of a block excluded in test mode
+	ifTestRe = regexp.MustCompile(`^\s*#\s*(ifndef\s+` + TestDefine + `|if\s+!\s*defined\s*\(\s*` + TestDefine + `\s*\))\s*$`)
+
+	ifRe    = regexp.MustCompile(`^\s*#\s*if`)
+	endifRe = regexp.MustCompile(`^\s*#\s*endif\b`)
+)
+
+// TrainBuffers returns the names of the buffers in the given HLSL
+// source that are only bound in the training mode, which is declared
+// with a directive at the end of their binding, e.g.:
+//
+//	[[vk::binding(0, 3)]] RWStructuredBuffer<Synapse> Synapses; // gosl: train
+func TrainBuffers(src []byte) []string {
+	var nms []string
+	for _, ln := range bytes.Split(src, []byte("\n")) {
+		if !trainRe.Match(ln) {
+			continue
+		}
+		if m := bindingRe.FindSubmatch(ln); m != nil {
+			nms = append(nms, string(m[5]))
+		}
+	}
+	return nms
+}
+
+// HasTrain returns true if the kernel has any buffers that are only
+// bound in the training mode, so it has a test mode variant.
+func (k *Kernel) HasTrain() bool {
+	return slices.ContainsFunc(k.Buffers, func(b *Buffer) bool { return b.Train })
+}
+
+// GenTestMode writes the test mode variant of the given kernel with the
+// given source in the output directory, named as in slmeta.TestName,
+// without the bindings of the training buffers, and with TestDefine
+// defined, returning its name.  A warning is printed for each training
+// buffer that is still used outside of an #ifndef GOSL_TEST block, as
+// the variant would not compile.  Only kernels whose file has a single
+// entry point can have a test mode variant.
+func GenTestMode(k *Kernel, src []byte) (string, bool) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "#define %s 1\n", TestDefine)
+	for _, ln := range bytes.SplitAfter(src, []byte("\n")) {
+		if trainRe.Match(bytes.TrimRight(ln, "\r\n")) && bindingRe.Match(ln) {
+			continue
+		}
+		b.Write(ln)
+	}
+	code := StripHLSLComments(TestModeCode(b.Bytes()))
+	for _, bf := range k.Buffers {
+		if bf.Train && BufferAccess(code, bf.Name) != 0 {
+			fmt.Printf("gosl: kernel %s: training buffer %s is used outside of an #ifndef %s block, so the test mode variant does not compile\n", k.Name, bf.Name, TestDefine)
+		}
+	}
+	nm := slmeta.TestName(k.Name)
+	if err := os.WriteFile(filepath.Join(GenDir(), nm+".hlsl"), b.Bytes(), 0644); err != nil {
+		fmt.Println(err)
+		return "", false
+	}
+	k.TestMode = true
+	return nm, true
+}
+
+// TestModeCode returns the given HLSL source without the blocks that
+// are excluded in the test mode, i.e., #ifndef GOSL_TEST ... #endif,
+// for checking the uses of the training buffers.  Any #else part is
+// also removed, as it should not use them either.
+func TestModeCode(src []byte) []byte {
+	var b bytes.Buffer
+	depth := 0 // depth of #if blocks within an excluded block
+	for _, ln := range bytes.SplitAfter(src, []byte("\n")) {
+		switch {
+		case depth == 0 && ifTestRe.Match(ln):
+			depth = 1
+		case depth > 0 && ifRe.Match(ln):
+			depth++
+		case depth > 0 && endifRe.Match(ln):
+			depth--
+		case depth == 0:
+			b.Write(ln)
+		}
+	}
+	return b.Bytes()
+}
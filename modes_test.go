@@ -0,0 +1,67 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTestMode(t *testing.T) {
+	od := *outDir
+	*outDir = t.TempDir()
+	t.Cleanup(func() { *outDir = od })
+
+	src := `#include "axon.hlsl"
+
+[[vk::binding(0, 0)]] RWStructuredBuffer<Neuron> Neurons;
+[[vk::binding(0, 1)]] RWStructuredBuffer<Synapse> Synapses; // gosl: train
+[[vk::binding(1, 1)]] RWStructuredBuffer<float> DWts;  //gosl:train
+
+[numthreads(64, 1, 1)]
+
+void main(uint3 idx : SV_DispatchThreadID) {
+	Neurons[idx.x].Act = 1;
+#ifndef GOSL_TEST
+	Synapses[idx.x].Wt += DWts[idx.x];
+#if GOSL_DEBUG
+	DWts[idx.x] = 0;
+#endif
+#else
+	Neurons[idx.x].Ge = 0;
+#endif
+}
+`
+	if got := TrainBuffers([]byte(src)); !slices.Equal(got, []string{"Synapses", "DWts"}) {
+		t.Errorf("wrong train buffers: %v", got)
+	}
+	k := ParseKernel("learn", []byte(src))
+	if !k.HasTrain() || k.Buffers[0].Train || !k.Buffers[1].Train {
+		t.Errorf("wrong Train buffers: %v, %v", k.Buffers[0], k.Buffers[1])
+	}
+	code := string(TestModeCode([]byte(src)))
+	if strings.Contains(code, "Synapses[idx.x]") || strings.Contains(code, "#endif") || !strings.Contains(code, "Neurons[idx.x].Act = 1;") {
+		t.Errorf("wrong test mode code:\n%s", code)
+	}
+
+	nm, ok := GenTestMode(k, []byte(src))
+	if !ok || nm != "learn_test" || !k.TestMode {
+		t.Fatalf("test mode variant not generated: %q", nm)
+	}
+	b, err := os.ReadFile(filepath.Join(*outDir, nm+".hlsl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsrc := string(b)
+	if !strings.HasPrefix(tsrc, "#define GOSL_TEST 1\n") || strings.Contains(tsrc, "RWStructuredBuffer<Synapse>") || !strings.Contains(tsrc, "RWStructuredBuffer<Neuron> Neurons;") {
+		t.Errorf("wrong test mode variant:\n%s", tsrc)
+	}
+	if tk := ParseKernel(nm, b); len(tk.Buffers) != 1 || tk.HasTrain() {
+		t.Errorf("test mode variant has training buffers: %v", tk.Buffers)
+	}
+}
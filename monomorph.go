@@ -0,0 +1,241 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// MonomorphizeIters is the maximum number of rounds of Monomorphize,
+// each of which instantiates the generic functions called from the
+// instances of the previous round.
+const MonomorphizeIters = 10
+
+// monoEdit replaces the bytes from start to end of a file with text
+type monoEdit struct {
+	start, end int
+	text       string
+}
+
+// Monomorphize expands the generic functions of the given package of
+// extracted regions into a concrete function for each of their type
+// argument lists, e.g., Clamp_float32 for Clamp[float32], which is
+// inserted after the generic function, and replaces the calls of the
+// non-generic functions with calls of the concrete functions, rewriting
+// the extracted files.  The generic functions and the constraint
+// interfaces are kept for type checking, and not translated.  As the
+// concrete functions can call other generic functions, it returns true
+// if any file changed, in which case the package must be loaded again
+// and Monomorphize called again, until it returns false.  With -hlsl
+// 2021, the generic functions are printed as templates instead, and it
+// only checks for generic types and methods.
+func Monomorphize(pkg *packages.Package) (bool, error) {
+	nerr := 0
+	report := func(pos token.Pos, msg string) {
+		nerr++
+		fmt.Printf("%s:\n\tgosl: %s\n", pkg.Fset.PositionFor(pos, true), msg)
+	}
+	generic := map[*types.Func]*ast.FuncDecl{}
+	declFile := map[*ast.FuncDecl]*ast.File{}
+	for _, f := range pkg.Syntax {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv != nil && len(d.Recv.List) > 0 && isGenericRecv(d.Recv.List[0].Type) {
+					report(d.Pos(), fmt.Sprintf("method %s of a generic type cannot be translated: only generic functions are instantiated", d.Name.Name))
+					continue
+				}
+				if d.Type.TypeParams == nil {
+					continue
+				}
+				if fn, ok := pkg.TypesInfo.Defs[d.Name].(*types.Func); ok {
+					generic[fn] = d
+					declFile[d] = f
+				}
+			case *ast.GenDecl:
+				for _, sp := range d.Specs {
+					if ts, ok := sp.(*ast.TypeSpec); ok && ts.TypeParams != nil {
+						report(ts.Pos(), fmt.Sprintf("generic type %s cannot be translated: only generic functions are instantiated", ts.Name.Name))
+					}
+				}
+			}
+		}
+	}
+	if nerr > 0 {
+		return false, fmt.Errorf("gosl: %d generic declarations that cannot be translated", nerr)
+	}
+	if len(generic) == 0 || UseHLSL2021() {
+		return false, nil // templates in HLSL 2021
+	}
+
+	qual := func(p *types.Package) string {
+		if p == pkg.Types {
+			return ""
+		}
+		return p.Name()
+	}
+	// instance returns the concrete function name for the generic
+	// function used by the given identifier, if any
+	instance := func(id *ast.Ident) (*types.Func, []string, string) {
+		in, ok := pkg.TypesInfo.Instances[id]
+		if !ok {
+			return nil, nil, ""
+		}
+		fn, ok := pkg.TypesInfo.Uses[id].(*types.Func)
+		if !ok || generic[fn.Origin()] == nil {
+			return nil, nil, ""
+		}
+		args := make([]string, in.TypeArgs.Len())
+		name := fn.Name()
+		for i := range args {
+			ta := in.TypeArgs.At(i)
+			named := false
+			switch tt := ta.(type) {
+			case *types.Basic:
+				named = true
+			case *types.Named:
+				named = tt.TypeArgs().Len() == 0
+			}
+			if !named {
+				report(id.Pos(), fmt.Sprintf("generic function %s cannot be instantiated with type %s: only basic and named types are supported", fn.Name(), types.TypeString(ta, qual)))
+				return nil, nil, ""
+			}
+			args[i] = types.TypeString(ta, qual)
+			name += "_" + strings.ReplaceAll(args[i], ".", "_")
+		}
+		return fn.Origin(), args, name
+	}
+
+	edits := map[*ast.File][]monoEdit{}
+	added := map[string]bool{}
+	for _, f := range pkg.Syntax {
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Type.TypeParams != nil {
+				continue // the calls in generic functions are instantiated in their concrete copies
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				var id *ast.Ident
+				switch x := n.(type) {
+				case *ast.IndexExpr:
+					id, _ = x.X.(*ast.Ident)
+				case *ast.IndexListExpr:
+					id, _ = x.X.(*ast.Ident)
+				case *ast.Ident:
+					id = x
+				}
+				if id == nil {
+					return true
+				}
+				fn, args, name := instance(id)
+				if fn == nil {
+					return true
+				}
+				edits[f] = append(edits[f], monoEdit{pkg.Fset.Position(n.Pos()).Offset, pkg.Fset.Position(n.End()).Offset, name})
+				if !added[name] && pkg.Types.Scope().Lookup(name) == nil {
+					added[name] = true
+					gd := generic[fn]
+					end := pkg.Fset.Position(gd.End()).Offset
+					src, err := instantiate(pkg, gd, args, name)
+					if err != nil {
+						report(gd.Pos(), err.Error())
+						return false
+					}
+					edits[declFile[gd]] = append(edits[declFile[gd]], monoEdit{end, end, "\n\n" + src})
+				}
+				return false
+			})
+		}
+	}
+	if nerr > 0 {
+		return false, fmt.Errorf("gosl: %d generic function instances that cannot be translated", nerr)
+	}
+	for f, eds := range edits {
+		fn := pkg.Fset.Position(f.Package).Filename
+		src, err := os.ReadFile(fn)
+		if err != nil {
+			return false, err
+		}
+		// in reverse order, with the concrete functions in order of name
+		sort.SliceStable(eds, func(i, j int) bool {
+			if eds[i].start != eds[j].start {
+				return eds[i].start > eds[j].start
+			}
+			return eds[i].text > eds[j].text
+		})
+		for _, ed := range eds {
+			src = append(src[:ed.start], append([]byte(ed.text), src[ed.end:]...)...)
+		}
+		if err := os.WriteFile(fn, src, 0644); err != nil {
+			return false, err
+		}
+	}
+	return len(edits) > 0, nil
+}
+
+// isGenericRecv returns true if the given receiver type is a generic
+// type, with type parameters, e.g., *Vec[T]
+func isGenericRecv(typ ast.Expr) bool {
+	if st, ok := typ.(*ast.StarExpr); ok {
+		typ = st.X
+	}
+	switch typ.(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return true
+	}
+	return false
+}
+
+// instantiate returns the Go source of the concrete function with the
+// given name, of the given generic function with the given type
+// arguments, which replace its type parameters.
+func instantiate(pkg *packages.Package, gd *ast.FuncDecl, args []string, name string) (string, error) {
+	fn := pkg.Fset.Position(gd.Pos()).Filename
+	src, err := os.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	sig := pkg.TypesInfo.Defs[gd.Name].(*types.Func).Type().(*types.Signature)
+	tparams := map[types.Object]string{}
+	for i := range sig.TypeParams().Len() {
+		tparams[sig.TypeParams().At(i).Obj()] = args[i]
+	}
+	off := func(pos token.Pos) int { return pkg.Fset.Position(pos).Offset }
+	start := off(gd.Pos())
+	eds := []monoEdit{
+		{off(gd.Name.Pos()), off(gd.Name.End()), name},
+		{off(gd.Type.TypeParams.Opening), off(gd.Type.TypeParams.Closing) + 1, ""},
+	}
+	ast.Inspect(gd, func(n ast.Node) bool {
+		if n == gd.Type.TypeParams {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			if ta, ok := tparams[pkg.TypesInfo.Uses[id]]; ok {
+				eds = append(eds, monoEdit{off(id.Pos()), off(id.End()), ta})
+			}
+		}
+		return true
+	})
+	sort.Slice(eds, func(i, j int) bool { return eds[i].start < eds[j].start })
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// %s is %s[%s], instantiated by gosl\n", name, gd.Name.Name, strings.Join(args, ", "))
+	pos := start
+	for _, ed := range eds {
+		b.Write(src[pos:ed.start])
+		b.WriteString(ed.text)
+		pos = ed.end
+	}
+	b.Write(src[pos:off(gd.End())])
+	return b.String(), nil
+}
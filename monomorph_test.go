@@ -0,0 +1,31 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMonomorphize(t *testing.T) {
+	od := *outDir
+	*outDir = filepath.Join("shaders", "generictest") // errors leave the extracted files
+	os.MkdirAll(*outDir, 0755)
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir = od
+		ResetState()
+	})
+	runTest(t, "testdata/generic/generic.go", "testdata/generic/generic.golden")
+
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "vec.go")
+	os.WriteFile(fn, []byte("package test\n\n//gosl: start vec\n\ntype Vec[T float32 | int32] struct {\n\tX, Y T\n}\n\n//gosl: end vec\n"), 0644)
+	if _, err := ProcessFiles([]string{fn}); err == nil || !strings.Contains(err.Error(), "generic") {
+		t.Errorf("expected an error for a generic type, got: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// NamesConfig maps the names of the regions and kernels to the paths of
+// their output files, and the kernels to the names of their entry
+// points, so that the generated files follow the naming conventions of
+// a downstream project, e.g.:
+//
+//	"Names": {
+//		"Case": "snake",
+//		"Files": {"axon": "kernels/Axon"},
+//		"Entries": {"axon": "AxonMain"}
+//	}
+//
+// The files are generated under their names, as without a mapping, and
+// moved to their paths when the generation succeeds, with the #include
+// directives between them made relative to their new directories.  The
+// generated Go code refers to the .spv files at their paths.
+type NamesConfig struct {
+
+	// case of the names of the output files that are not in Files: lower
+	// (e.g., CycleNeuron.hlsl to cycleneuron.hlsl) or snake
+	// (cycle_neuron.hlsl), which keep the suffixes of the variants of the
+	// kernels, e.g., cycle_neuron_wg64.spv, as found by sltune
+	Case string
+
+	// output file paths of the regions and kernels, by name, relative to
+	// the output directory, without extension, which can be in
+	// subdirectories, e.g., "axon": "kernels/Axon" -- the other kernels
+	// and variants of their files, e.g., axon_wg64, are next to them,
+	// e.g., kernels/Axon_wg64
+	Files map[string]string
+
+	// names of the entry point functions of the kernels, by kernel name,
+	// e.g., "axon": "AxonMain", for the HLSL target
+	Entries map[string]string
+}
+
+// NameCases are the supported NamesConfig Case values
+var NameCases = []string{"", "lower", "snake"}
+
+// CheckNames checks the given NamesConfig, returning the errors.
+func CheckNames(fn string, nc *NamesConfig) []error {
+	var errs []error
+	if !slices.Contains(NameCases, nc.Case) {
+		errs = append(errs, fmt.Errorf("gosl: config file %s: Names.Case: %q must be lower or snake", fn, nc.Case))
+	}
+	for nm, p := range nc.Files {
+		if cp := path.Clean(p); p == "" || path.IsAbs(p) || cp == "." || cp == ".." || strings.HasPrefix(cp, "../") || strings.Contains(p, "\\") || path.Ext(p) != "" {
+			errs = append(errs, fmt.Errorf("gosl: config file %s: Names.Files: %q: %q must be a slash-separated path relative to the output directory, without extension, e.g., kernels/Axon", fn, nm, p))
+		}
+	}
+	for nm, e := range nc.Entries {
+		if !token.IsIdentifier(e) {
+			errs = append(errs, fmt.Errorf("gosl: config file %s: Names.Entries: %q: %q must be an identifier", fn, nm, e))
+		}
+	}
+	return errs
+}
+
+// HasNames returns true if the GoslConfig maps any output file names
+func HasNames() bool {
+	return GoslConfig.Names.Case != "" || len(GoslConfig.Names.Files) > 0
+}
+
+// OutputPath returns the slash-separated path of the output files of
+// the region or kernel with given name, relative to the output directory,
+// without extension, e.g., kernels/Axon for axon: its Files mapping, or
+// that of the longest name that it extends with an underscore, e.g., for
+// the variants and the kernels of a file with multiple entry points, or
+// else the name with the Case, if any.
+func OutputPath(name string) string {
+	nc := &GoslConfig.Names
+	if p, ok := nc.Files[name]; ok {
+		return path.Clean(p)
+	}
+	base := ""
+	for nm := range nc.Files {
+		if len(nm) > len(base) && strings.HasPrefix(name, nm+"_") {
+			base = nm
+		}
+	}
+	if base != "" {
+		return path.Clean(nc.Files[base]) + CaseName(name[len(base):], nc.Case)
+	}
+	return CaseName(name, nc.Case)
+}
+
+// OutputFile returns the slash-separated path relative to the output
+// directory of the generated file with the given name, e.g., axon.hlsl:
+// that of its OutputPath for the shader and .spv files, with their
+// extension, and the name itself for the others.
+func OutputFile(fn string) string {
+	ext := filepath.Ext(fn)
+	if ext != ".spv" && !slices.Contains(Targets, strings.TrimPrefix(ext, ".")) {
+		return fn
+	}
+	return OutputPath(strings.TrimSuffix(fn, ext)) + ext
+}
+
+// CaseName returns the given name with the given NamesConfig Case:
+// lower case, or snake case, where an underscore is inserted before each
+// upper case letter that starts a word, e.g., CycleNeuron to cycle_neuron,
+// and GPUKernel to gpu_kernel.
+func CaseName(name, cs string) string {
+	switch cs {
+	case "lower":
+		return strings.ToLower(name)
+	case "snake":
+		rs := []rune(name)
+		var b strings.Builder
+		for i, r := range rs {
+			if i > 0 && unicode.IsUpper(r) && rs[i-1] != '_' {
+				prev := rs[i-1]
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]) && unicode.IsUpper(prev)) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+		}
+		return b.String()
+	}
+	return name
+}
+
+// OutputIncludesAt returns the given source of the generated shader file
+// with given name, e.g., axon.hlsl, whose #include directives are
+// relative to the output directory, with those made relative to the
+// directory of its OutputFile, and the includes of the other generated
+// files at their OutputFile paths.
+func OutputIncludesAt(fn string, src []byte) []byte {
+	dir := path.Dir(OutputFile(fn))
+	return includeRe.ReplaceAllFunc(src, func(m []byte) []byte {
+		sm := includeRe.FindSubmatchIndex(m)
+		inc := string(m[sm[2]:sm[3]])
+		if filepath.IsAbs(inc) {
+			return m
+		}
+		to := path.Clean(inc)
+		if !strings.Contains(to, "/") {
+			to = OutputFile(to)
+		}
+		rel, err := filepath.Rel(dir, to)
+		if err != nil {
+			return m
+		}
+		if rel = filepath.ToSlash(rel); rel == inc {
+			return m
+		}
+		return slices.Concat(m[:sm[2]], []byte(rel), m[sm[3]:])
+	})
+}
+
+// MapOutputs moves the shader and .spv files generated in the output
+// directory to their OutputFile paths, with their includes made relative
+// to their new directories (see OutputIncludesAt), if the GoslConfig has
+// any Names.  It is called after all of the files are generated, and
+// before they replace those of the previous run.
+func MapOutputs() error {
+	if !HasNames() {
+		return nil
+	}
+	ents, err := os.ReadDir(GenDir())
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range ents {
+		if !(IsShaderFile(e) || IsSPVFile(e)) {
+			continue
+		}
+		fn := e.Name()
+		ofn := OutputFile(fn)
+		src := filepath.Join(GenDir(), fn)
+		dst := filepath.Join(GenDir(), filepath.FromSlash(ofn))
+		if IsShaderFile(e) {
+			b, err := os.ReadFile(src)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if mb := OutputIncludesAt(fn, b); !bytes.Equal(mb, b) {
+				if err := os.WriteFile(src, mb, 0644); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+		}
+		if ofn == fn {
+			continue
+		}
+		if _, err := os.Stat(dst); err == nil {
+			errs = append(errs, fmt.Errorf("gosl: config Names: %s and another generated file are both mapped to %s", fn, ofn))
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Rename(src, dst); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RenameEntries renames the entry point functions of the given kernels,
+// parsed from the given HLSL source of their file, to their names in the
+// Names.Entries of the GoslConfig, if any, setting their Entry, and
+// returns the source with the renamed functions, and whether any were.
+func RenameEntries(ks []*Kernel, src []byte) ([]byte, bool) {
+	edited := false
+	for _, k := range ks {
+		nm, ok := GoslConfig.Names.Entries[k.Name]
+		if !ok || nm == k.Entry {
+			continue
+		}
+		re := regexp.MustCompile(`(\[numthreads\([^)]*\)\]\s*(?:\[[^\]]*\]\s*)*void\s+)` + regexp.QuoteMeta(k.Entry) + `(\s*\()`)
+		loc := re.FindSubmatchIndex(src)
+		if loc == nil {
+			fmt.Printf("gosl: config Names.Entries: entry point %s of kernel %s not found\n", k.Entry, k.Name)
+			continue
+		}
+		src = slices.Concat(src[:loc[3]], []byte(nm), src[loc[4]:])
+		k.Entry = nm
+		edited = true
+	}
+	return src, edited
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+	saved := GoslConfig
+	defer func() { GoslConfig = saved }()
+
+	for nm, want := range map[string]string{"CycleNeuron": "cycle_neuron", "axon_CycleNeuron": "axon_cycle_neuron", "GPUKernel": "gpu_kernel", "Layer2Pool": "layer2_pool", "axon_wg64": "axon_wg64"} {
+		if got := CaseName(nm, "snake"); got != want {
+			t.Errorf("snake case of %s: %s != %s", nm, got, want)
+		}
+	}
+	GoslConfig.Names = NamesConfig{Case: "snake", Files: map[string]string{"axon": "kernels/Axon"}}
+	for nm, want := range map[string]string{"axon": "kernels/Axon", "axon_wg64": "kernels/Axon_wg64", "axon_CycleNeuron": "kernels/Axon_cycle_neuron", "CycleNeuron": "cycle_neuron", "slrand": "slrand"} {
+		if got := OutputPath(nm); got != want {
+			t.Errorf("output path of %s: %s != %s", nm, got, want)
+		}
+	}
+	if got := OutputFile("gosl_meta.go"); got != "gosl_meta.go" {
+		t.Errorf("Go file was mapped: %s", got)
+	}
+	src := "#include \"slrand.hlsl\"\n#include \"CycleNeuron.hlsl\"\n#include \"../common/util.hlsl\"\n"
+	want := "#include \"../slrand.hlsl\"\n#include \"../cycle_neuron.hlsl\"\n#include \"../../common/util.hlsl\"\n"
+	if got := string(OutputIncludesAt("axon.hlsl", []byte(src))); got != want {
+		t.Errorf("wrong includes:\n%s", got)
+	}
+
+	ks := []*Kernel{{Name: "axon", Entry: "main"}}
+	GoslConfig.Names.Entries = map[string]string{"axon": "AxonMain"}
+	esrc, ok := RenameEntries(ks, []byte("void main2() {}\n[numthreads(64, 1, 1)]\nvoid main(uint3 idx : SV_DispatchThreadID) {\n}\n"))
+	if !ok || ks[0].Entry != "AxonMain" || !strings.Contains(string(esrc), "void AxonMain(uint3 idx") || !strings.Contains(string(esrc), "void main2()") {
+		t.Errorf("entry not renamed: %s\n%s", ks[0].Entry, esrc)
+	}
+
+	err := ParseConfig("gosl.json", []byte(`{"Names": {"Case": "upper", "Files": {"axon": "../Axon", "cycle": "kernels/Cycle.hlsl"}, "Entries": {"axon": "axon-main"}}}`), &Config{})
+	for _, want := range []string{"Names.Case", `"../Axon"`, `"kernels/Cycle.hlsl"`, `"axon-main"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v does not contain %s", err, want)
+		}
+	}
+}
+
+// TestMapOutputs generates the basic test region at a mapped path
+// in a subdirectory, with a renamed entry point.
+func TestMapOutputs(t *testing.T) {
+	od, dxc, saved := *outDir, *dxcPath, GoslConfig
+	*outDir = filepath.Join("shaders", "namestest")
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *dxcPath, GoslConfig = od, dxc, saved
+		ResetState()
+	})
+	*dxcPath = ToolNone
+	GoslConfig.Names = NamesConfig{Files: map[string]string{"basic": "kernels/Basic"}, Entries: map[string]string{"basic": "BasicMain"}}
+	if err := Generate([]string{"testdata/basic.go"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(*outDir, "kernels", "Basic.hlsl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "void BasicMain(uint3 idx : SV_DispatchThreadID)") {
+		t.Errorf("entry point not renamed:\n%s", b)
+	}
+	if _, err := os.Stat(filepath.Join(*outDir, "basic.hlsl")); err == nil {
+		t.Errorf("basic.hlsl not moved")
+	}
+	if k := Kernels["basic"]; k == nil || k.Entry != "BasicMain" {
+		t.Errorf("wrong kernel: %+v", k)
+	}
+}
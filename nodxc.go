@@ -0,0 +1,83 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// SPVStale is the SPVStatus of a kernel whose .spv file was kept
+	// from a previous run, so it may not match the current HLSL code
+	SPVStale = "stale"
+
+	// SPVMissing is the SPVStatus of a kernel that has no .spv file
+	SPVMissing = "missing"
+)
+
+// SPVStatus has the status of the .spv files of the kernels and their
+// variants that were not compiled because dxc was not found, by name,
+// set by SkipCompile: SPVStale or SPVMissing.
+var SPVStatus = map[string]string{}
+
+// FindDxc returns whether the -dxc compiler is found, with an error
+// if it is not found with -require-dxc or -hermetic.
+func FindDxc() (found bool, err error) {
+	if _, err := exec.LookPath(*dxcPath); err == nil {
+		return true, nil
+	}
+	if *requireDxc || *hermetic {
+		return false, fmt.Errorf("gosl: dxc compiler not found: %q -- install the Vulkan SDK, or set -dxc to its path, or to none to skip compiling", *dxcPath)
+	}
+	return false, nil
+}
+
+// SkipCompile is called instead of compiling the given kernels and
+// their -autotune and test mode variants when dxc is not found: it
+// keeps their previously compiled .spv files from the output directory,
+// records them in SPVStatus as SPVStale, or SPVMissing if there are none,
+// and prints a summary, so that downstream builds can decide what to do.
+func SkipCompile(ks []*Kernel, variants map[string]*Kernel) {
+	skip := func(nm string) {
+		src := filepath.Join(*outDir, filepath.FromSlash(OutputPath(nm))+".spv")
+		if _, err := os.Stat(src); err != nil {
+			SPVStatus[nm] = SPVMissing
+			return
+		}
+		if err := CopyFile(src, filepath.Join(GenDir(), nm+".spv")); err != nil {
+			fmt.Println(err)
+		}
+		SPVStatus[nm] = SPVStale
+	}
+	for _, k := range ks {
+		skip(k.Name)
+	}
+	for vn := range variants {
+		skip(vn)
+	}
+	var stale, missing []string
+	for nm, st := range SPVStatus {
+		if st == SPVStale {
+			stale = append(stale, nm)
+		} else {
+			missing = append(missing, nm)
+		}
+	}
+	sort.Strings(stale)
+	sort.Strings(missing)
+	fmt.Printf("\nWARNING: dxc compiler not found: %q -- generated the HLSL code only, without compiling it to .spv\n", *dxcPath)
+	if len(stale) > 0 {
+		fmt.Printf("    stale .spv files, kept from a previous run: %s\n", strings.Join(stale, ", "))
+	}
+	if len(missing) > 0 {
+		fmt.Printf("    missing .spv files: %s\n", strings.Join(missing, ", "))
+	}
+	fmt.Printf("    install the Vulkan SDK, or set -dxc to its path, and run gosl again to compile them -- use -require-dxc to make this an error\n")
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNoDxc generates with a dxc compiler that is not found, and checks
+// that the HLSL is generated, the previous .spv files are kept, and
+// their status is recorded in the manifest.
+func TestNoDxc(t *testing.T) {
+	od, dxc, rq, mf := *outDir, *dxcPath, *requireDxc, *manifestFile
+	*outDir = filepath.Join("shaders", "nodxctest")
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *dxcPath, *requireDxc, *manifestFile = od, dxc, rq, mf
+		ResetState()
+	})
+	tmp := t.TempDir()
+	*dxcPath = filepath.Join(tmp, "nodxc")
+	*manifestFile = filepath.Join(tmp, "manifest.json")
+	other := filepath.Join(tmp, "other.hlsl")
+	os.WriteFile(other, []byte("[[vk::binding(0, 0)]] RWStructuredBuffer<float> Vals;\n[numthreads(64, 1, 1)]\nvoid main(uint3 idx : SV_DispatchThreadID) {\n\tVals[idx.x] = 1;\n}\n"), 0644)
+	args := []string{"testdata/basic.go", other}
+	os.MkdirAll(*outDir, 0755)
+	os.WriteFile(filepath.Join(*outDir, "basic.spv"), []byte("old\n"), 0644)
+
+	if err := Generate(args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(*outDir, "basic.hlsl")); err != nil {
+		t.Error("HLSL not generated:", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(*outDir, "basic.spv")); string(b) != "old\n" {
+		t.Errorf("previous basic.spv not kept: %q", b)
+	}
+	im, err := ReadInterfaceManifest(*manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spv := map[string]string{}
+	for _, k := range im.Kernels {
+		spv[k.Name] = k.SPV
+	}
+	if spv["basic"] != SPVStale || spv["other"] != SPVMissing {
+		t.Errorf("wrong .spv status in manifest: %v", spv)
+	}
+
+	ResetState()
+	*requireDxc = true
+	if err := Generate(args); err == nil {
+		t.Error("no error for a missing dxc with -require-dxc")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OnlyNames returns the kernel and region names from the -only flag,
+// or nil if all kernels are built.
+func OnlyNames() map[string]bool {
+	var nms map[string]bool
+	for _, nm := range strings.Split(*only, ",") {
+		if nm = strings.TrimSpace(nm); nm == "" {
+			continue
+		}
+		if nms == nil {
+			nms = map[string]bool{}
+		}
+		nms[nm] = true
+	}
+	return nms
+}
+
+// CheckOnly returns an error if any of the -only names is not the
+// name of a kernel, or of a region with kernels.
+func CheckOnly() error {
+	var bad []string
+	for nm := range OnlyNames() {
+		found := false
+		for _, k := range Kernels {
+			if k.Name == nm || k.File == nm {
+				found = true
+				break
+			}
+		}
+		if !found {
+			bad = append(bad, nm)
+		}
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+	var kns []string
+	for _, k := range SortedKernels() {
+		kns = append(kns, k.Name)
+	}
+	return fmt.Errorf("gosl: -only: no kernel or region named: %s -- kernels are: %s", strings.Join(bad, ", "), strings.Join(kns, ", "))
+}
+
+// IsOnly returns true if the given kernel is built: with -only, if its
+// name or the name of its region (file) is listed, and otherwise always.
+func IsOnly(k *Kernel) bool {
+	nms := OnlyNames()
+	return nms == nil || nms[k.Name] || nms[k.File]
+}
+
+// KeepOutputs copies the previously compiled .spv files of the given
+// kernels that are not built with -only, and of their -autotune
+// variants, from the output directory into the staging directory,
+// so that they are kept when the staged outputs replace the previous
+// ones.  A kernel that has not been compiled before is reported, as
+// it has no .spv file until it is built.
+func KeepOutputs(ks []*Kernel, variants map[string]*Kernel) {
+	keep := func(nm string) bool {
+		src := filepath.Join(*outDir, filepath.FromSlash(OutputPath(nm))+".spv")
+		if _, err := os.Stat(src); err != nil {
+			return false
+		}
+		if err := CopyFile(src, filepath.Join(GenDir(), nm+".spv")); err != nil {
+			fmt.Println(err)
+		}
+		return true
+	}
+	for _, k := range ks {
+		if IsOnly(k) {
+			continue
+		}
+		if !keep(k.Name) {
+			fmt.Printf("gosl: -only: kernel %s has not been compiled yet, and has no .spv file\n", k.Name)
+		}
+	}
+	for vn, k := range variants {
+		if !IsOnly(k) {
+			keep(vn)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestOnly builds only one of two kernels with -only, using a fake
+// compiler, and checks that the .spv of the other one is kept.
+func TestOnly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake compiler is a shell script")
+	}
+	od, dxc, on := *outDir, *dxcPath, *only
+	*outDir = filepath.Join("shaders", "onlytest")
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *dxcPath, *only = od, dxc, on
+		ResetState()
+	})
+	tmp := t.TempDir()
+	*dxcPath = filepath.Join(tmp, "dxc")
+	os.WriteFile(*dxcPath, []byte("#!/bin/sh\nwhile [ $# -gt 1 ]; do\n\tif [ \"$1\" = -Fo ]; then echo new > \"$2\"; fi\n\tshift\ndone\n"), 0755)
+	other := filepath.Join(tmp, "other.hlsl")
+	os.WriteFile(other, []byte("[[vk::binding(0, 0)]] RWStructuredBuffer<float> Vals;\n[numthreads(64, 1, 1)]\nvoid main(uint3 idx : SV_DispatchThreadID) {\n\tVals[idx.x] = 1;\n}\n"), 0644)
+	args := []string{"testdata/basic.go", other}
+	spv := func(nm string) string {
+		b, _ := os.ReadFile(filepath.Join(*outDir, nm+".spv"))
+		return string(b)
+	}
+
+	if err := Generate(args); err != nil {
+		t.Fatal(err)
+	}
+	for _, nm := range []string{"basic", "other"} {
+		if spv(nm) != "new\n" {
+			t.Fatalf("%s.spv not compiled: %q", nm, spv(nm))
+		}
+		os.WriteFile(filepath.Join(*outDir, nm+".spv"), []byte("old\n"), 0644)
+	}
+
+	ResetState()
+	*only = "other"
+	if err := Generate(args); err != nil {
+		t.Fatal(err)
+	}
+	if spv("other") != "new\n" || spv("basic") != "old\n" {
+		t.Errorf("-only other: got other.spv %q, basic.spv %q", spv("other"), spv("basic"))
+	}
+
+	ResetState()
+	*only = "nokernel"
+	if err := Generate(args); err == nil {
+		t.Error("no error for -only with an unknown kernel")
+	}
+	if spv("basic") != "old\n" {
+		t.Errorf("outputs changed by a failed -only: %q", spv("basic"))
+	}
+}
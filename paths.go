@@ -0,0 +1,93 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// All of the relative paths, i.e., the path args, -out and the other
+// file flags, are relative to the package directory, which is the working
+// directory of gosl: the directory of the package with the //go:generate
+// gosl line for go generate, or the -chdir directory, e.g., for a Makefile
+// in another directory.  The #include paths of the shader files, which
+// are relative to their own directory, are made relative to the output
+// directory when they are copied there (see OutputIncludes), so that the
+// generated code does not depend on where gosl is invoked.
+
+// Chdir changes the working directory to the -chdir directory, if set,
+// which is then the package directory that the relative paths are
+// relative to, as with go -C.
+func Chdir() error {
+	if *chdir == "" {
+		return nil
+	}
+	if err := os.Chdir(*chdir); err != nil {
+		return fmt.Errorf("gosl: -chdir: %w", err)
+	}
+	return nil
+}
+
+// OutputFiles returns the names of the shader files that are written to
+// the output directory, for the given regions and standalone shader files:
+// the regions, the shader files, and the headers of the HeaderPackages.
+func OutputFiles(regions map[string][]byte, shaderFiles []string) []string {
+	ext := ShaderExt()
+	var ofs []string
+	for fn := range regions {
+		ofs = append(ofs, fn+ext)
+	}
+	for _, hlfn := range shaderFiles {
+		ofs = append(ofs, filepath.Base(hlfn))
+	}
+	for _, hp := range HeaderPackages {
+		ofs = append(ofs, hp+".hlsl")
+	}
+	return ofs
+}
+
+// OutputIncludes returns the given shader source, from a file in the
+// given directory, e.g., a shader file or the raw code blocks of a region,
+// with the relative paths of its #include directives, which are relative
+// to that directory, made relative to the output directory, where it is
+// written.  Includes of the files that are written to the output
+// directory, given by outFiles, e.g., the regions, the header packages,
+// and the other shader files, are their file names, as the output
+// directory is flat.  Includes of files that do not exist relative to
+// dir, e.g., those found by the compiler on its include paths, are kept.
+func OutputIncludes(dir string, src []byte, outFiles []string) []byte {
+	outAbs, err := filepath.Abs(*outDir)
+	if err != nil {
+		return src
+	}
+	return includeRe.ReplaceAllFunc(src, func(m []byte) []byte {
+		sm := includeRe.FindSubmatchIndex(m)
+		inc := string(m[sm[2]:sm[3]])
+		if filepath.IsAbs(inc) {
+			return m
+		}
+		rel := filepath.Base(inc)
+		if !slices.Contains(outFiles, rel) {
+			abs, err := filepath.Abs(filepath.Join(dir, inc))
+			if err != nil {
+				return m
+			}
+			if _, err := os.Stat(abs); err != nil {
+				return m
+			}
+			if rel, err = filepath.Rel(outAbs, abs); err != nil {
+				return m
+			}
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == inc {
+			return m
+		}
+		return slices.Concat(m[:sm[2]], []byte(rel), m[sm[3]:])
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOutputIncludes(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "common"), 0755)
+	os.WriteFile(filepath.Join(src, "common", "defs.hlsl"), nil, 0644)
+
+	defOut := *outDir
+	defer func() { *outDir = defOut }()
+	*outDir = filepath.Join(dir, "shaders")
+
+	in := `#include "common/defs.hlsl"
+#include "sub/axon.hlsl"
+#include "slrand.hlsl"
+#include "vendor.hlsl"
+#include "/abs/path.hlsl"
+`
+	want := `#include "../src/common/defs.hlsl"
+#include "axon.hlsl"
+#include "slrand.hlsl"
+#include "vendor.hlsl"
+#include "/abs/path.hlsl"
+`
+	got := string(OutputIncludes(src, []byte(in), []string{"axon.hlsl", "slrand.hlsl"}))
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestTestFiles checks that _test.go files are skipped unless -tests is
+// set, and translates the test-only region of testdata/tests with it.
+func TestTestFiles(t *testing.T) {
+	od, ts, dxc := *outDir, *tests, *dxcPath
+	*outDir = filepath.Join("shaders", "teststest")
+	os.MkdirAll(*outDir, 0755)
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *tests, *dxcPath = od, ts, dxc
+		ResetState()
+	})
+	*dxcPath = ToolNone
+	dir := filepath.Join("testdata", "tests")
+	probe, probeTest := filepath.Join(dir, "probe.go"), filepath.Join(dir, "probe_test.go")
+	*tests = false
+	if fls := FilesFromPaths([]string{dir}); !slices.Equal(fls, []string{probe}) {
+		t.Errorf("test file not skipped in directory: %v", fls)
+	}
+	if fls := FilesFromPaths([]string{probeTest}); len(fls) != 0 {
+		t.Errorf("test file not skipped: %v", fls)
+	}
+	*tests = true
+	if fls := FilesFromPaths([]string{dir}); !slices.Equal(fls, []string{probe, probeTest}) {
+		t.Errorf("test file not included with -tests: %v", fls)
+	}
+	runTest(t, probeTest, filepath.Join(dir, "probe_test.golden"))
+}
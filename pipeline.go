@@ -0,0 +1,419 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PipelinesFile is the name of the Go file generated in the package
+// directory for the pipelines declared by //gosl: pipeline directives.
+const PipelinesFile = "gosl_pipelines.go"
+
+// Pipeline is a sequence of kernels that share buffers, declared by a
+// directive in any comment of the Go files being processed, e.g., in
+// the doc comment of the type that runs the simulation:
+//
+//	//gosl: pipeline <Name> <Kernel> <Kernel> ...
+//
+// A Go runner type, <Name>Pipeline, is generated in the PipelinesFile,
+// which dispatches the kernels in order on an slgpu.Runtime, with a
+// Barrier between the kernels where needed for the buffers they share.
+// The barriers can instead be declared explicitly by name, before a
+// kernel, e.g., barrier:Spikes in:
+//
+//	//gosl: pipeline Cycle GatherSpikes barrier:Spikes CycleNeuron Learn
+//
+// in which case only the named barriers are issued, and they are checked
+// against the buffer access of the kernels.  The runner type then also
+// has methods for split-phase execution, to run the kernels before each
+// named barrier, and the barrier with the kernels after it.
+type Pipeline struct {
+
+	// name of the pipeline, for its runner type
+	Name string
+
+	// names of the kernels, in dispatch order, as given in the
+	// directive: the Name of a Kernel, or its Entry if that is unique
+	Kernels []string
+
+	// names of the explicit barriers, by the index of the kernel in
+	// Kernels that they are before
+	Barriers map[int]string
+
+	// position of the directive, for messages
+	Pos token.Position
+}
+
+// PipelineStep is a kernel dispatched by a Pipeline, with the buffers
+// that need a Barrier before it, because a previous kernel wrote them,
+// or it writes them and a previous kernel used them.
+type PipelineStep struct {
+
+	// kernel dispatched in this step
+	Kernel *Kernel
+
+	// names of the buffers that need a Barrier before the kernel
+	Barrier []string
+
+	// name of the explicit barrier before the kernel, if any
+	Name string
+}
+
+// HasBarrier returns true if a Barrier is issued before the kernel:
+// an explicit barrier, or one needed for the buffers otherwise.
+func (st *PipelineStep) HasBarrier() bool {
+	return st.Name != "" || len(st.Barrier) > 0
+}
+
+// FindPipelines returns the Pipelines declared in the given Go files,
+// with the name and directory of their package, in which the
+// PipelinesFile is generated, and an error for each directive that is
+// not valid.  The pipelines are sorted by name.
+func FindPipelines(files []string) (string, string, []*Pipeline, error) {
+	var pls []*Pipeline
+	var errs []error
+	pkgName, dir := "", ""
+	names := map[string]*Pipeline{}
+	fset := token.NewFileSet()
+	for _, fn := range files {
+		if !strings.HasSuffix(fn, ".go") || filepath.Base(fn) == PipelinesFile {
+			continue
+		}
+		f, err := parser.ParseFile(fset, fn, nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				txt := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+				args, ok := strings.CutPrefix(txt, "gosl: pipeline")
+				if !ok || (args != "" && args[0] != ' ' && args[0] != '\t') {
+					continue
+				}
+				pos := fset.Position(c.Pos())
+				fs := strings.Fields(args)
+				if len(fs) < 2 {
+					errs = append(errs, fmt.Errorf("%s: gosl: pipeline: the directive must be: //gosl: pipeline <Name> <Kernel> <Kernel> ..., not: %s", pos, txt))
+					continue
+				}
+				pl, err := newPipeline(fs)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: gosl: pipeline: %w", pos, err))
+					continue
+				}
+				if pkgName == "" {
+					pkgName, dir = f.Name.Name, filepath.Dir(fn)
+				} else if f.Name.Name != pkgName || filepath.Dir(fn) != dir {
+					errs = append(errs, fmt.Errorf("%s: gosl: pipeline: all pipelines must be declared in one package, in %s", pos, dir))
+					continue
+				}
+				if op := names[pl.Name]; op != nil {
+					errs = append(errs, fmt.Errorf("%s: gosl: pipeline: %s is already declared at %s", pos, pl.Name, op.Pos))
+					continue
+				}
+				pl.Pos = pos
+				names[pl.Name] = pl
+				pls = append(pls, pl)
+			}
+		}
+	}
+	sort.Slice(pls, func(i, j int) bool { return pls[i].Name < pls[j].Name })
+	return pkgName, dir, pls, errors.Join(errs...)
+}
+
+// newPipeline returns the Pipeline for the given fields of a pipeline
+// directive: the name, and the kernels, with the barrier:<Name> tokens
+// of the explicit barriers.
+func newPipeline(fs []string) (*Pipeline, error) {
+	if !token.IsExported(fs[0]) {
+		return nil, fmt.Errorf("name must be an exported identifier, for the %sPipeline type: %s", fs[0], fs[0])
+	}
+	pl := &Pipeline{Name: fs[0]}
+	for _, f := range fs[1:] {
+		bnm, ok := strings.CutPrefix(f, "barrier:")
+		if !ok {
+			pl.Kernels = append(pl.Kernels, f)
+			continue
+		}
+		if !token.IsExported(bnm) {
+			return nil, fmt.Errorf("barrier name must be an exported identifier, for the RunTo%s and RunFrom%s methods: %s", bnm, bnm, f)
+		}
+		if pl.Barriers == nil {
+			pl.Barriers = map[int]string{}
+		}
+		for _, onm := range pl.Barriers {
+			if onm == bnm {
+				return nil, fmt.Errorf("barrier %s is declared more than once", bnm)
+			}
+		}
+		if _, has := pl.Barriers[len(pl.Kernels)]; has {
+			return nil, fmt.Errorf("barrier %s follows another barrier", bnm)
+		}
+		pl.Barriers[len(pl.Kernels)] = bnm
+	}
+	if len(pl.Kernels) == 0 {
+		return nil, fmt.Errorf("%s has no kernels", pl.Name)
+	}
+	if _, has := pl.Barriers[len(pl.Kernels)]; has {
+		return nil, fmt.Errorf("barrier %s must be before a kernel: declare it before the first kernel for the end of the previous Run", pl.Barriers[len(pl.Kernels)])
+	}
+	return pl, nil
+}
+
+// findKernel returns the Kernel with the given name, or else the one
+// with the given entry point, if there is only one.
+func findKernel(name string) *Kernel {
+	if k := Kernels[name]; k != nil {
+		return k
+	}
+	var found *Kernel
+	for _, k := range SortedKernels() {
+		if k.Entry == name {
+			if found != nil {
+				return nil
+			}
+			found = k
+		}
+	}
+	return found
+}
+
+// Steps returns the steps of the pipeline, with the Kernels in order,
+// and the buffers that need a Barrier before each one, from the Access
+// of the kernels to the buffers, by name.  The first step has a Barrier
+// if its kernel depends on the last ones of the previous Run.  With
+// explicit Barriers, only those are issued, and a missing barrier is an
+// error, except before the first kernel, which is a warning, as is an
+// explicit barrier that is not needed.
+func (pl *Pipeline) Steps() ([]*PipelineStep, error) {
+	var errs []error
+	steps := make([]*PipelineStep, 0, len(pl.Kernels))
+	for _, nm := range pl.Kernels {
+		k := findKernel(nm)
+		if k == nil {
+			errs = append(errs, fmt.Errorf("%s: gosl: pipeline %s: kernel %s is not generated, or its entry point is not unique", pl.Pos, pl.Name, nm))
+			continue
+		}
+		steps = append(steps, &PipelineStep{Kernel: k, Name: pl.Barriers[len(steps)]})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	// access to each buffer by the kernels since the last Barrier
+	used := map[string]Access{}
+	// barrier returns the buffers of the given step that need a Barrier
+	barrier := func(st *PipelineStep) []string {
+		var bufs []string
+		for _, bf := range st.Kernel.Buffers {
+			ac := used[bf.Name]
+			if ac&Write != 0 || (ac != 0 && bf.Access&Write != 0) {
+				bufs = append(bufs, bf.Name)
+			}
+		}
+		return bufs
+	}
+	explicit := len(pl.Barriers) > 0
+	for i, st := range steps {
+		if i > 0 {
+			st.Barrier = barrier(st)
+			if explicit {
+				errs = append(errs, pl.checkBarrier(st, false))
+			}
+		}
+		if st.HasBarrier() {
+			clear(used)
+		}
+		for _, bf := range st.Kernel.Buffers {
+			used[bf.Name] |= bf.Access
+		}
+	}
+	// the first kernel after the last ones of the previous Run
+	steps[0].Barrier = barrier(steps[0])
+	if explicit {
+		pl.checkBarrier(steps[0], true)
+	}
+	return steps, errors.Join(errs...)
+}
+
+// checkBarrier checks the explicit barrier of the given step against
+// the buffers that need one, returning an error for a missing barrier,
+// or printing a warning for the first step, and for a barrier that is
+// not needed.  The Barrier buffers of a step without an explicit
+// barrier are then cleared, as it has none.
+func (pl *Pipeline) checkBarrier(st *PipelineStep, first bool) error {
+	k := st.Kernel.Name
+	switch {
+	case st.Name != "" && len(st.Barrier) == 0:
+		if first {
+			fmt.Printf("%s: gosl: pipeline %s: warning: barrier %s before %s is not needed: %s does not use the buffers written by the last kernels of the previous Run, or write those they use\n", pl.Pos, pl.Name, st.Name, k, k)
+		} else {
+			fmt.Printf("%s: gosl: pipeline %s: warning: barrier %s before %s is not needed: %s does not use the buffers written by the kernels since the previous barrier, or write those they use\n", pl.Pos, pl.Name, st.Name, k, k)
+		}
+	case st.Name == "" && len(st.Barrier) > 0:
+		bufs := strings.Join(st.Barrier, ", ")
+		st.Barrier = nil
+		if first {
+			fmt.Printf("%s: gosl: pipeline %s: warning: no barrier before %s, which uses %s with the last kernels of the previous Run: add a barrier before it, unless the runtime waits for them\n", pl.Pos, pl.Name, k, bufs)
+			return nil
+		}
+		return fmt.Errorf("%s: gosl: pipeline %s: no barrier before %s, which uses %s with the kernels since the previous barrier, where one writes what the other uses: add a barrier:<Name> before it", pl.Pos, pl.Name, k, bufs)
+	}
+	return nil
+}
+
+// pipelineField returns the name of the field of the runner type with
+// the number of threads of the given kernel, e.g., NCycleNeuron.
+func pipelineField(k *Kernel) string {
+	return "N" + strings.TrimPrefix(KernelConstName(k.Name), "Kernel")
+}
+
+// pipelineGroups returns the Go expression for the number of workgroups
+// of the given size for the given expression of the number of threads.
+func pipelineGroups(n string, size int) string {
+	if size <= 1 {
+		return n
+	}
+	return fmt.Sprintf("(%s+%d)/%d", n, size-1, size)
+}
+
+// writePipelineSteps writes the Go code of the given steps from the
+// given index up to the given one, with the Barrier before each step
+// that has one, and the Dispatch of its kernel.
+func writePipelineSteps(b *bytes.Buffer, steps []*PipelineStep, from, to int) {
+	for i := from; i < to; i++ {
+		st := steps[i]
+		k := st.Kernel
+		if st.HasBarrier() {
+			cmt := strings.Join(st.Barrier, ", ")
+			if i == 0 && cmt != "" {
+				cmt += " of the previous Run"
+			}
+			if st.Name != "" {
+				cmt = strings.TrimSuffix(st.Name+": "+cmt, ": ")
+			}
+			fmt.Fprintf(b, "\t// %s\n", cmt)
+			b.WriteString("\tif err := pl.Runtime.Barrier(); err != nil {\n\t\treturn err\n\t}\n")
+		}
+		fd := pipelineField(k)
+		wg := k.Workgroup
+		if k.IndexDims > 1 {
+			fmt.Fprintf(b, "\tif err := pl.Runtime.Dispatch(%q, %s, %s, %s); err != nil {\n\t\treturn err\n\t}\n", k.Name, pipelineGroups("pl."+fd+"[0]", wg[0]), pipelineGroups("pl."+fd+"[1]", wg[1]), pipelineGroups("pl."+fd+"[2]", wg[2]))
+		} else {
+			fmt.Fprintf(b, "\tif err := pl.Runtime.Dispatch(%q, %s, 1, 1); err != nil {\n\t\treturn err\n\t}\n", k.Name, pipelineGroups("pl."+fd, wg[0]))
+		}
+	}
+}
+
+// PipelinesGo returns the Go source of the PipelinesFile for the given
+// pipelines, in the given package, with a <Name>Pipeline runner type for
+// each, which has a field for the number of threads of each kernel, and
+// methods to add the kernels to the Runtime, and to dispatch them in
+// order, with the Barriers of the Steps.
+func PipelinesGo(pkgName string, pls []*Pipeline) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	b.WriteString("import (\n\t\"path/filepath\"\n\n\t\"github.com/emer/gosl/v2/slgpu\"\n)\n")
+	var errs []error
+	for _, pl := range pls {
+		steps, err := pl.Steps()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		typ := pl.Name + "Pipeline"
+		fmt.Fprintf(&b, "\n// %s dispatches the kernels of the %s pipeline in order:\n// %s,\n", typ, pl.Name, strings.Join(pl.Kernels, ", "))
+		if len(pl.Barriers) > 0 {
+			b.WriteString("// with the explicit barriers, and methods to run the kernels before\n// each barrier, and the barrier with the kernels after it.\n")
+		} else {
+			b.WriteString("// with a Barrier before each kernel that uses a buffer written by\n// a previous one, or writes a buffer used by a previous one.\n")
+		}
+		fmt.Fprintf(&b, "type %s struct {\n\n\t// Runtime that runs the kernels, with their buffers\n\tRuntime slgpu.Runtime\n", typ)
+		fields := map[string]bool{}
+		for _, st := range steps {
+			k := st.Kernel
+			fd := pipelineField(k)
+			if fields[fd] {
+				continue
+			}
+			fields[fd] = true
+			if k.IndexDims > 1 {
+				fmt.Fprintf(&b, "\n\t// %s is the number of threads of the %s kernel in each\n\t// dimension, from which its numbers of workgroups are computed\n\t%s [3]int\n", fd, k.Name, fd)
+			} else {
+				fmt.Fprintf(&b, "\n\t// %s is the number of threads of the %s kernel,\n\t// from which its number of workgroups is computed\n\t%s int\n", fd, k.Name, fd)
+			}
+		}
+		b.WriteString("}\n\n")
+
+		b.WriteString("// AddKernels adds the kernels of the pipeline to the Runtime, from\n// their compiled shader files in the given directory, e.g., shaders.\n")
+		fmt.Fprintf(&b, "func (pl *%s) AddKernels(dir string) error {\n", typ)
+		added := map[string]bool{}
+		for _, st := range steps {
+			k := st.Kernel
+			if added[k.Name] {
+				continue
+			}
+			added[k.Name] = true
+			fmt.Fprintf(&b, "\tif err := pl.Runtime.AddKernel(%q, filepath.Join(dir, %q)); err != nil {\n\t\treturn err\n\t}\n", k.Name, OutputPath(k.Name)+".spv")
+		}
+		b.WriteString("\treturn nil\n}\n\n")
+
+		b.WriteString("// Run dispatches the kernels of the pipeline in order, with a Barrier\n// where needed, after the buffers have been uploaded to the Runtime.\n")
+		fmt.Fprintf(&b, "func (pl *%s) Run() error {\n", typ)
+		writePipelineSteps(&b, steps, 0, len(steps))
+		b.WriteString("\treturn nil\n}\n")
+		for i, st := range steps {
+			if i == 0 || st.Name == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "\n// RunTo%s dispatches the kernels of the pipeline before the %s\n// barrier, for split-phase execution with RunFrom%s, which runs the\n// rest: together they are the same as Run.\n", st.Name, st.Name, st.Name)
+			fmt.Fprintf(&b, "func (pl *%s) RunTo%s() error {\n", typ, st.Name)
+			writePipelineSteps(&b, steps, 0, i)
+			b.WriteString("\treturn nil\n}\n")
+			fmt.Fprintf(&b, "\n// RunFrom%s issues the %s barrier, and dispatches the kernels of\n// the pipeline after it, after RunTo%s.\n", st.Name, st.Name, st.Name)
+			fmt.Fprintf(&b, "func (pl *%s) RunFrom%s() error {\n", typ, st.Name)
+			writePipelineSteps(&b, steps, i, len(steps))
+			b.WriteString("\treturn nil\n}\n")
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return format.Source(b.Bytes())
+}
+
+// GenPipelines generates the PipelinesFile in the package directory for
+// the pipelines declared by //gosl: pipeline directives in the given
+// files, if any, from the Kernels generated in this run.  It is only
+// written if it changed.
+func GenPipelines(files []string) error {
+	pkgName, dir, pls, err := FindPipelines(files)
+	if err != nil {
+		return err
+	}
+	if len(pls) == 0 {
+		return nil
+	}
+	src, err := PipelinesGo(pkgName, pls)
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(dir, PipelinesFile)
+	RecordOutput(fn)
+	if cur, err := os.ReadFile(fn); err == nil && bytes.Equal(cur, src) {
+		return nil // unchanged, e.g., for -watch
+	}
+	return os.WriteFile(fn, src, 0644)
+}
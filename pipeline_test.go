@@ -0,0 +1,105 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPipelines(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "network.go")
+	src := `package axon
+
+// Network runs the model.
+//
+// gosl: pipeline Cycle GatherSpikes CycleNeuron Learn
+type Network struct{}
+
+//gosl: pipeline Init InitNeurons
+
+//gosl: pipeline Split GatherSpikes barrier:Spikes CycleNeuron barrier:Acts Learn
+`
+	os.WriteFile(fn, []byte(src), 0644)
+	pkgName, pdir, pls, err := FindPipelines([]string{fn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkgName != "axon" || pdir != dir || len(pls) != 3 {
+		t.Fatalf("wrong package %s, dir %s, or pipelines: %d", pkgName, pdir, len(pls))
+	}
+	if pl := pls[0]; pl.Name != "Cycle" || strings.Join(pl.Kernels, " ") != "GatherSpikes CycleNeuron Learn" {
+		t.Errorf("wrong pipeline: %+v", pl)
+	}
+
+	saved := Kernels
+	defer func() { Kernels = saved }()
+	buf := func(name string, ac Access) *Buffer {
+		return &Buffer{Name: name, Kind: "RWStructuredBuffer", Access: ac}
+	}
+	Kernels = map[string]*Kernel{
+		"axon_GatherSpikes": {Name: "axon_GatherSpikes", Entry: "GatherSpikes", Workgroup: [3]int{64, 1, 1},
+			Buffers: []*Buffer{buf("Params", Read), buf("Neurons", Read), buf("Synapses", Read), buf("Pools", Write)}},
+		"axon_CycleNeuron": {Name: "axon_CycleNeuron", Entry: "CycleNeuron", Workgroup: [3]int{64, 1, 1},
+			Buffers: []*Buffer{buf("Params", Read), buf("Neurons", Read|Write), buf("Pools", Read)}},
+		"Learn": {Name: "Learn", Entry: "main", Workgroup: [3]int{8, 8, 1}, IndexDims: 2,
+			Buffers: []*Buffer{buf("Params", Read), buf("Neurons", Read), buf("Synapses", Read|Write)}},
+		"InitNeurons": {Name: "InitNeurons", Entry: "main", Workgroup: [3]int{64, 1, 1},
+			Buffers: []*Buffer{buf("Neurons", Write)}},
+	}
+	steps, err := pls[0].Steps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"Synapses", "Neurons, Pools", "Neurons"} {
+		if got := strings.Join(steps[i].Barrier, ", "); got != want {
+			t.Errorf("step %d: wrong barrier: %q, want %q", i, got, want)
+		}
+	}
+	if pl := pls[2]; strings.Join(pl.Kernels, " ") != "GatherSpikes CycleNeuron Learn" || pl.Barriers[1] != "Spikes" || pl.Barriers[2] != "Acts" {
+		t.Errorf("wrong explicit barriers: %+v", pl)
+	}
+	steps, err = pls[2].Steps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].HasBarrier() || strings.Join(steps[1].Barrier, ", ") != "Neurons, Pools" || steps[2].Name != "Acts" {
+		t.Errorf("wrong explicit barrier steps: %+v, %+v, %+v", steps[0], steps[1], steps[2])
+	}
+	gsrc, err := PipelinesGo(pkgName, pls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type CyclePipeline struct {",
+		"\tNAxon_GatherSpikes int\n",
+		"\tNLearn [3]int\n",
+		`if err := pl.Runtime.AddKernel("axon_CycleNeuron", filepath.Join(dir, "axon_CycleNeuron.spv")); err != nil {`,
+		"\t// Synapses of the previous Run\n\tif err := pl.Runtime.Barrier(); err != nil {",
+		"\t// Neurons, Pools\n\tif err := pl.Runtime.Barrier(); err != nil {",
+		`if err := pl.Runtime.Dispatch("axon_GatherSpikes", (pl.NAxon_GatherSpikes+63)/64, 1, 1); err != nil {`,
+		`if err := pl.Runtime.Dispatch("Learn", (pl.NLearn[0]+7)/8, (pl.NLearn[1]+7)/8, pl.NLearn[2]); err != nil {`,
+		"type InitPipeline struct {",
+		"\t// Neurons of the previous Run\n",
+		"func (pl *SplitPipeline) RunToSpikes() error {\n\tif err := pl.Runtime.Dispatch(\"axon_GatherSpikes\", (pl.NAxon_GatherSpikes+63)/64, 1, 1); err != nil {\n\t\treturn err\n\t}\n\treturn nil\n}",
+		"func (pl *SplitPipeline) RunFromActs() error {\n\t// Acts: Neurons\n\tif err := pl.Runtime.Barrier(); err != nil {",
+	} {
+		if !strings.Contains(string(gsrc), want) {
+			t.Errorf("missing %q in:\n%s", want, gsrc)
+		}
+	}
+
+	os.WriteFile(fn, []byte(src+"\n//gosl: pipeline cycle Learn\n//gosl: pipeline Last Learn barrier:End\n//gosl: pipeline Bad Unknown\n//gosl: pipeline Missing GatherSpikes barrier:Spikes CycleNeuron Learn\n"), 0644)
+	_, _, pls, err = FindPipelines([]string{fn})
+	if err == nil || !strings.Contains(err.Error(), "exported") || !strings.Contains(err.Error(), "barrier End must be before a kernel") {
+		t.Errorf("expected errors for unexported name and last barrier, got: %v", err)
+	}
+	if _, err := PipelinesGo(pkgName, pls); err == nil || !strings.Contains(err.Error(), "kernel Unknown is not generated") || !strings.Contains(err.Error(), "no barrier before Learn, which uses Neurons") {
+		t.Errorf("expected errors for unknown kernel and missing barrier, got: %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Pressure estimates the register pressure of the functions in the
+// shader code, as the peak number of 32-bit values that are live at
+// the same time, because huge monolithic kernels can be register-bound.
+// A value is live from its definition to its last use, extended to the
+// end of any loop it is used in, and struct and array values count all
+// of their elements.  Pointer parameters are copied in and out of the
+// function in HLSL (inout), so they are live for the entire function.
+// Because all functions are inlined in HLSL, the pressure at a call
+// includes the pressure of the called function.
+// This is only a rough estimate, as the shader compiler can eliminate
+// many values, but it is useful for comparing functions and kernels.
+type Pressure struct {
+	pkg *packages.Package
+
+	// function declarations, by function object
+	decls map[types.Object]*ast.FuncDecl
+
+	// peak pressure of each function, including calls
+	peaks map[types.Object]int
+
+	// true while computing the peak of a function, for recursion
+	inProgress map[types.Object]bool
+}
+
+// liveValue is a local variable or parameter for pressure analysis
+type liveValue struct {
+	name string
+
+	// number of 32-bit values
+	size int
+
+	// is a function parameter (or receiver)
+	param bool
+
+	// is a pointer parameter, which is inout in HLSL
+	inout bool
+
+	// positions of the uses of the value
+	uses []token.Pos
+
+	// live range
+	def, last token.Pos
+}
+
+// PhaseSplit is a suggested split of a function into two phases
+type PhaseSplit struct {
+
+	// statement after which the function is split
+	After string
+
+	// peak pressure of the two phases
+	Peak1, Peak2 int
+
+	// values computed in the first phase and used in the second,
+	// which must be stored in an intermediate buffer
+	Values []string
+
+	// total number of 32-bit intermediate values
+	NValues int
+}
+
+// NewPressure returns a new Pressure for the given package.
+func NewPressure(pkg *packages.Package) *Pressure {
+	pr := &Pressure{pkg: pkg, decls: map[types.Object]*ast.FuncDecl{}, peaks: map[types.Object]int{}, inProgress: map[types.Object]bool{}}
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil {
+				if obj := pkg.TypesInfo.Defs[fd.Name]; obj != nil {
+					pr.decls[obj] = fd
+				}
+			}
+		}
+	}
+	return pr
+}
+
+// TypeSlots returns the number of 32-bit values for given type.
+// Pointers count as their element type, as they are copied in HLSL
+// (inout parameters).
+func TypeSlots(typ types.Type) int {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		if t.Kind() == types.Int64 || t.Kind() == types.Uint64 || t.Kind() == types.Float64 {
+			return 2
+		}
+		return 1
+	case *types.Pointer:
+		return TypeSlots(t.Elem())
+	case *types.Array:
+		return int(t.Len()) * TypeSlots(t.Elem())
+	case *types.Struct:
+		n := 0
+		for i := range t.NumFields() {
+			n += TypeSlots(t.Field(i).Type())
+		}
+		return n
+	}
+	return 1
+}
+
+// values returns the live values of given function
+func (pr *Pressure) values(fd *ast.FuncDecl) []*liveValue {
+	info := pr.pkg.TypesInfo
+	vals := map[types.Object]*liveValue{}
+	addParams := func(fl *ast.FieldList) {
+		if fl == nil {
+			return
+		}
+		for _, f := range fl.List {
+			for _, nm := range f.Names {
+				if obj := info.Defs[nm]; obj != nil {
+					lv := &liveValue{name: nm.Name, size: TypeSlots(obj.Type()), param: true, def: fd.Body.Lbrace, last: fd.Body.Lbrace}
+					if _, ok := obj.Type().(*types.Pointer); ok {
+						lv.inout = true
+						lv.last = fd.Body.Rbrace
+					}
+					vals[obj] = lv
+				}
+			}
+		}
+	}
+	addParams(fd.Recv)
+	addParams(fd.Type.Params)
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if obj, ok := info.Defs[id].(*types.Var); ok && !obj.IsField() {
+			vals[obj] = &liveValue{name: id.Name, size: TypeSlots(obj.Type()), def: id.Pos(), last: id.Pos()}
+		} else if lv, ok := vals[info.Uses[id]]; ok {
+			lv.uses = append(lv.uses, id.Pos())
+			lv.last = max(lv.last, id.Pos())
+		}
+		return true
+	})
+	// values used in a loop are live for the entire loop
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+		default:
+			return true
+		}
+		for _, lv := range vals {
+			if lv.def < n.Pos() && lv.last > n.Pos() && lv.last < n.End() {
+				lv.last = n.End()
+			}
+		}
+		return true
+	})
+	vl := make([]*liveValue, 0, len(vals))
+	for _, lv := range vals {
+		vl = append(vl, lv)
+	}
+	sort.Slice(vl, func(i, j int) bool { return vl[i].def < vl[j].def })
+	return vl
+}
+
+// phaseValues returns the values for a phase of a function, in given
+// range, in which inout parameters are only live if used in the phase.
+func phaseValues(vals []*liveValue, start, end token.Pos) []*liveValue {
+	pv := make([]*liveValue, 0, len(vals))
+	for _, lv := range vals {
+		if lv.inout && !slices.ContainsFunc(lv.uses, func(p token.Pos) bool { return p >= start && p <= end }) {
+			continue
+		}
+		pv = append(pv, lv)
+	}
+	return pv
+}
+
+// live returns the total size of values live at given position
+func live(vals []*liveValue, pos token.Pos) int {
+	n := 0
+	for _, lv := range vals {
+		if lv.def <= pos && pos <= lv.last {
+			n += lv.size
+		}
+	}
+	return n
+}
+
+// peakIn returns the peak pressure in given range of function body,
+// including the pressure of called functions.
+func (pr *Pressure) peakIn(vals []*liveValue, node ast.Node, start, end token.Pos) int {
+	peak := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil || n.Pos() < start || n.Pos() > end {
+			return true
+		}
+		switch x := n.(type) {
+		case *ast.Ident:
+			peak = max(peak, live(vals, x.Pos()))
+		case *ast.CallExpr:
+			if obj := pr.callee(x); obj != nil {
+				peak = max(peak, live(vals, x.Pos())+pr.Peak(obj))
+			}
+		}
+		return true
+	})
+	return peak
+}
+
+// callee returns the function object called by given call, if it is
+// a function in the package.
+func (pr *Pressure) callee(call *ast.CallExpr) types.Object {
+	var id *ast.Ident
+	switch f := call.Fun.(type) {
+	case *ast.Ident:
+		id = f
+	case *ast.SelectorExpr:
+		id = f.Sel
+	default:
+		return nil
+	}
+	obj := pr.pkg.TypesInfo.Uses[id]
+	if _, has := pr.decls[obj]; !has {
+		return nil
+	}
+	return obj
+}
+
+// Peak returns the peak pressure of given function, including calls.
+func (pr *Pressure) Peak(obj types.Object) int {
+	if pk, ok := pr.peaks[obj]; ok {
+		return pk
+	}
+	fd := pr.decls[obj]
+	if fd == nil || pr.inProgress[obj] {
+		return 0
+	}
+	pr.inProgress[obj] = true
+	vals := pr.values(fd)
+	pk := max(live(vals, fd.Body.Lbrace), pr.peakIn(vals, fd.Body, fd.Body.Lbrace, fd.Body.Rbrace))
+	delete(pr.inProgress, obj)
+	pr.peaks[obj] = pk
+	return pk
+}
+
+// FuncName returns the name of given function, as Type.Method for methods
+func FuncName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	rt := fd.Recv.List[0].Type
+	if st, ok := rt.(*ast.StarExpr); ok {
+		rt = st.X
+	}
+	return types.ExprString(rt) + "." + fd.Name.Name
+}
+
+// Split returns the best split of given function into two phases
+// at a top-level statement, minimizing the peak pressure of the phases,
+// or nil if no split reduces the peak pressure.
+func (pr *Pressure) Split(obj types.Object) *PhaseSplit {
+	fd := pr.decls[obj]
+	peak := pr.Peak(obj)
+	vals := pr.values(fd)
+	var best *PhaseSplit
+	stmts := fd.Body.List
+	for i := 0; i < len(stmts)-1; i++ {
+		cut := stmts[i].End()
+		sp := &PhaseSplit{After: stmtString(pr.pkg.Fset, stmts[i])}
+		start2 := stmts[i+1].Pos()
+		sp.Peak1 = pr.peakIn(phaseValues(vals, fd.Body.Lbrace, cut), fd.Body, fd.Body.Lbrace, cut)
+		sp.Peak2 = pr.peakIn(phaseValues(vals, start2, fd.Body.Rbrace), fd.Body, start2, fd.Body.Rbrace)
+		for _, lv := range vals {
+			if !lv.param && lv.def < cut && lv.last > cut {
+				sp.Values = append(sp.Values, lv.name)
+				sp.NValues += lv.size
+			}
+		}
+		pk := max(sp.Peak1, sp.Peak2)
+		if pk >= peak {
+			continue
+		}
+		if best == nil || pk < max(best.Peak1, best.Peak2) || (pk == max(best.Peak1, best.Peak2) && sp.NValues < best.NValues) {
+			best = sp
+		}
+	}
+	return best
+}
+
+// stmtString returns the first line of given statement, for messages
+func stmtString(fset *token.FileSet, st ast.Stmt) string {
+	var b bytes.Buffer
+	printer.Fprint(&b, fset, st)
+	s, _, more := strings.Cut(b.String(), "\n")
+	if more {
+		s += " ..."
+	}
+	return s
+}
+
+// hlslCallRe matches function and method calls in HLSL code
+var hlslCallRe = regexp.MustCompile(`\b(\w+)\s*\(`)
+
+// KernelPeak returns the peak pressure of the functions called in the
+// given kernel source, and the name of the function with that peak.
+func (pr *Pressure) KernelPeak(src []byte) (int, types.Object) {
+	called := map[string]bool{}
+	for _, m := range hlslCallRe.FindAllSubmatch(StripHLSLComments(src), -1) {
+		called[string(m[1])] = true
+	}
+	peak := 0
+	var pobj types.Object
+	for obj := range pr.decls {
+		if !called[obj.Name()] {
+			continue
+		}
+		if pk := pr.Peak(obj); pk > peak || (pk == peak && pobj != nil && obj.Name() < pobj.Name()) {
+			peak, pobj = pk, obj
+		}
+	}
+	return peak, pobj
+}
+
+// ReportPressure prints the estimated register pressure of the kernels
+// with given names and sources, and of the functions, exceeding the
+// given threshold, with suggestions for splitting them into phases.
+func ReportPressure(pkg *packages.Package, kernels map[string][]byte, threshold int) {
+	pr := NewPressure(pkg)
+	knms := make([]string, 0, len(kernels))
+	for nm := range kernels {
+		knms = append(knms, nm)
+	}
+	sort.Strings(knms)
+	for _, nm := range knms {
+		pk, obj := pr.KernelPeak(kernels[nm])
+		if pk <= threshold {
+			continue
+		}
+		fd := pr.decls[obj]
+		fmt.Printf("\ngosl: kernel %s: estimated peak of %d live 32-bit values (> %d), in: %s\n", nm, pk, threshold, FuncName(fd))
+	}
+	objs := make([]types.Object, 0, len(pr.decls))
+	for obj := range pr.decls {
+		if pr.Peak(obj) > threshold {
+			objs = append(objs, obj)
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		pi, pj := pr.Peak(objs[i]), pr.Peak(objs[j])
+		if pi != pj {
+			return pi > pj
+		}
+		return FuncName(pr.decls[objs[i]]) < FuncName(pr.decls[objs[j]])
+	})
+	for _, obj := range objs {
+		fd := pr.decls[obj]
+		fmt.Printf("\ngosl: function %s: estimated peak of %d live 32-bit values\n", FuncName(fd), pr.Peak(obj))
+		sp := pr.Split(obj)
+		if sp == nil {
+			fmt.Printf("    no split at a top-level statement reduces the peak: consider splitting the functions it calls\n")
+			continue
+		}
+		fmt.Printf("    suggestion: split into two kernels after: %s\n", sp.After)
+		fmt.Printf("    phase peaks: %d and %d\n", sp.Peak1, sp.Peak2)
+		if len(sp.Values) > 0 {
+			fmt.Printf("    values to store in an intermediate buffer (%d 32-bit values): %s\n", sp.NValues, strings.Join(sp.Values, ", "))
+		}
+	}
+}
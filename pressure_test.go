@@ -0,0 +1,48 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestPressure(t *testing.T) {
+	src := `package main
+
+type Vec struct {
+	X, Y, Z, W float32
+}
+
+func Sum(v *Vec) float32 {
+	return v.X + v.Y + v.Z + v.W
+}
+
+func Cycle(v *Vec, w *Vec, out *Vec) {
+	a := Sum(v) + Sum(w)
+	out.X = a
+	var big [8]float32
+	for i := 0; i < 8; i++ {
+		big[i] = out.X
+	}
+	out.Y = big[7]
+}
+`
+	pkg := testPackage(t, "cycle.go", src)
+	pr := NewPressure(pkg)
+	sum := pkg.Types.Scope().Lookup("Sum")
+	cyc := pkg.Types.Scope().Lookup("Cycle")
+	if pk := pr.Peak(sum); pk != 4 {
+		t.Errorf("Sum: expected peak 4, got %d", pk)
+	}
+	// inout v, w, out, and big and i in the loop
+	if pk := pr.Peak(cyc); pk != 21 {
+		t.Errorf("Cycle: expected peak 21, got %d", pk)
+	}
+	// phase 1 needs v, w, a and Sum, phase 2 a, out, big and i
+	sp := pr.Split(cyc)
+	if sp == nil || sp.After != "a := Sum(v) + Sum(w)" || sp.Peak1 != 13 || sp.Peak2 != 13 || sp.NValues != 1 {
+		t.Fatalf("wrong split: %+v", sp)
+	}
+}
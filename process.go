@@ -12,6 +12,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
@@ -20,6 +21,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/emer/gosl/v2/alignsl"
@@ -27,20 +29,10 @@ import (
 	"golang.org/x/tools/go/packages"
 )
 
-// does all the file processing
-func ProcessFiles(paths []string) (map[string][]byte, error) {
-	fls := FilesFromPaths(paths)
-	gosls := ExtractGoFiles(fls) // extract Go files to shader/*.go
-
-	hlslFiles := []string{}
-	for _, fn := range fls {
-		if strings.HasSuffix(fn, ".hlsl") {
-			hlslFiles = append(hlslFiles, fn)
-		}
-	}
-
-	pf := "./" + *outDir
-	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedTypesSizes}, pf)
+// LoadGenPackage loads the package of the Go files extracted
+// to the given path of the output directory
+func LoadGenPackage(pf string) (*packages.Package, error) {
+	pkgs, err := packages.Load(LoadConfig(packages.NeedName|packages.NeedFiles|packages.NeedCompiledGoFiles|packages.NeedTypes|packages.NeedSyntax|packages.NeedTypesInfo|packages.NeedTypesSizes), pf)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -57,18 +49,103 @@ func ProcessFiles(paths []string) (map[string][]byte, error) {
 		log.Println(err)
 		return nil, err
 	}
-	// fmt.Printf("go files: %+v", pkg.GoFiles)
-	// return nil, err
+	return pkg, nil
+}
+
+// does all the file processing
+func ProcessFiles(paths []string) (map[string][]byte, error) {
+	progress.Stage("files", 0)
+	clear(ExcludedNames) // for this run only, e.g., in -watch mode
+	clear(ExcludedFuncs)
+	fls := FilesFromPaths(paths)
+	progress.Files = len(fls)
+	ext := ShaderExt()
+	shaderFiles := []string{} // standalone shader files for the -target
+	for _, fn := range fls {
+		if strings.HasSuffix(fn, ext) {
+			shaderFiles = append(shaderFiles, fn)
+		}
+	}
+	progress.Stage("extract", len(fls)-len(shaderFiles))
+	gosls, err := ExtractGoFiles(fls) // extract Go files to shader/*.go
+	if err != nil {
+		return nil, err
+	}
+
+	pf := "./" + GenDir()
+	if filepath.IsAbs(GenDir()) {
+		pf = GenDir()
+	}
+	progress.Stage("load", 0)
+	pkg, err := LoadGenPackage(pf)
+	if err != nil {
+		return nil, err
+	}
+	for range MonomorphizeIters {
+		changed, err := Monomorphize(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			break
+		}
+		if pkg, err = LoadGenPackage(pf); err != nil {
+			return nil, err
+		}
+	}
 
 	// map of files with a main function that needs to be compiled
 	needsCompile := map[string]bool{}
 
-	serr := alignsl.CheckPackage(pkg)
+	progress.Stage("check", 0)
+	var serr error
+	switch *target {
+	case TargetWGSL:
+		serr = alignsl.CheckPackageWGSL(pkg)
+	case TargetGLSL:
+		serr = alignsl.CheckPackageStd430(pkg)
+	case TargetMetal:
+		serr = alignsl.CheckPackageMSL(pkg)
+	default:
+		serr = alignsl.CheckPackage(pkg)
+	}
 	if serr != nil {
 		fmt.Println(serr)
 	}
 
-	slrandCopied := false
+	if err := CheckUnits(pkg); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if err := CheckVarStarts(pkg, fls); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := CheckRegionUses(gosls); err != nil {
+		fmt.Println(err)
+	}
+
+	if *checkSubset {
+		if err := CheckSubset(pkg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := CheckSubgroups(pkg); err != nil {
+		return nil, err
+	}
+
+	entries, err := FindKernelEntries(pkg)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	outFiles := OutputFiles(gosls, shaderFiles)
+	renames := map[string]string{}
+	hdrsCopied := map[string]bool{}
+	var transErrs []error // translation errors, reported after all of the files
+	progress.Stage("translate", len(gosls))
 	for fn := range gosls {
 		gofn := fn + ".go"
 		if *debug {
@@ -92,18 +169,39 @@ func ProcessFiles(paths []string) (map[string][]byte, error) {
 		}
 
 		var buf bytes.Buffer
-		cfg := slprint.Config{Mode: printerMode, Tabwidth: tabWidth, ExcludeFuns: excludeFunMap}
-		cfg.Fprint(&buf, pkg, fpos, afile)
-		// ioutil.WriteFile(filepath.Join(*outDir, fn+".tmp"), buf.Bytes(), 0644)
-		slfix, hasSlrand := SlEdits(buf.Bytes())
-		if hasSlrand && !slrandCopied {
+		cfg := slprint.Config{Mode: printerMode, Tabwidth: tabWidth, ExcludeFuns: excludeFunMap, Excluded: LogExcluded, Debug: *debug, Renames: renames, ReplaceFuncs: GoslConfig.Replace.Funcs, ReplaceTypes: GoslConfig.Replace.Types, Target: *target, HLSL2021: UseHLSL2021()}
+		if err := cfg.Fprint(&buf, pkg, fpos, afile); err != nil {
+			transErrs = append(transErrs, err)
+		}
+		// ioutil.WriteFile(filepath.Join(GenDir(), fn+".tmp"), buf.Bytes(), 0644)
+		slfix, hdrs := SlEdits(buf.Bytes())
+		for _, hp := range hdrs {
+			if hdrsCopied[hp] {
+				continue
+			}
+			if *target != TargetHLSL {
+				fmt.Printf("gosl: %s: the %s package has no %s version: its functions are only available in HLSL\n", fn, hp, strings.ToUpper(*target))
+				hdrsCopied[hp] = true
+				continue
+			}
 			if *debug {
-				fmt.Printf("\tcopying slrand.hlsl to shaders\n")
+				fmt.Printf("\tcopying %s.hlsl to shaders\n", hp)
+			}
+			CopyPackageHLSL(hp)
+			hdrsCopied[hp] = true
+		}
+		exsl, hasMain := ExtractShader(slfix, *target)
+		if srcs := RegionSources[fn]; len(srcs) > 0 {
+			exsl = OutputIncludes(filepath.Dir(srcs[0]), exsl, outFiles)
+		}
+		if kes := entries[fn]; len(kes) > 0 {
+			if *target != TargetHLSL {
+				fmt.Printf("gosl: %s: kernel entry points are only generated for -target %s: write them in //gosl: %s blocks\n", fn, TargetHLSL, *target)
+			} else if esrc := GenKernelEntries(kes, exsl); len(esrc) > 0 {
+				exsl = append(exsl, esrc...)
+				hasMain = true
 			}
-			CopySlrand()
-			slrandCopied = true
 		}
-		exsl, hasMain := ExtractHLSL(slfix)
 		gosls[fn] = exsl
 
 		if hasMain {
@@ -113,9 +211,9 @@ func ProcessFiles(paths []string) (map[string][]byte, error) {
 			os.Remove(fpos.Filename)
 		}
 
-		// add hlsl code
-		for _, hlfn := range hlslFiles {
-			if fn+".hlsl" != hlfn {
+		// add shader code, from a file with the same name in any directory
+		for _, hlfn := range shaderFiles {
+			if fn+ext != filepath.Base(hlfn) {
 				continue
 			}
 			buf, err := os.ReadFile(hlfn)
@@ -124,27 +222,66 @@ func ProcessFiles(paths []string) (map[string][]byte, error) {
 				continue
 			}
 			exsl = append(exsl, []byte(fmt.Sprintf("\n// from file: %s\n", hlfn))...)
-			exsl = append(exsl, buf...)
+			AddRegionSource(fn, hlfn)
+			exsl = append(exsl, OutputIncludes(filepath.Dir(hlfn), buf, outFiles)...)
 			gosls[fn] = exsl
 			needsCompile[fn] = true // assume any standalone has main
 			break
 		}
 
-		upfn := strings.ToUpper(fn)
-		once := fmt.Sprintf("#ifndef __%s_HLSL__\n#define __%s_HLSL__\n\n", upfn, upfn)
-		exsl = append([]byte(once), exsl...)
-		oncend := fmt.Sprintf("#endif // __%s_HLSL__\n", upfn)
-		exsl = append(exsl, []byte(oncend)...)
+		if *vectorize {
+			var nv int
+			exsl, nv = Vectorize(pkg.Types, exsl)
+			if *debug && nv > 0 {
+				fmt.Printf("\tvectorized %d runs of statements in %s.hlsl\n", nv, fn)
+			}
+		}
+
+		if *target != TargetWGSL {
+			upfn := strings.ToUpper(fn)
+			uptg := strings.ToUpper(*target)
+			once := fmt.Sprintf("#ifndef __%s_%s__\n#define __%s_%s__\n\n", upfn, uptg, upfn, uptg)
+			exsl = append(append([]byte(once), UsesIncludes(fn)...), exsl...)
+			oncend := fmt.Sprintf("#endif // __%s_%s__\n", upfn, uptg)
+			exsl = append(exsl, []byte(oncend)...)
+			switch {
+			case *target == TargetGLSL && needsCompile[fn]:
+				exsl = append(GLSLVersion(fn), exsl...)
+			case *target == TargetMetal:
+				exsl = append(MetalHeader(), exsl...)
+			}
+		} else { // no preprocessor: the includes are expanded by the loader
+			exsl = append(UsesIncludes(fn), exsl...)
+			if needsCompile[fn] {
+				exsl = append(SubgroupHeader(), exsl...)
+			}
+		}
+
+		slfn := filepath.Join(GenDir(), fn+ext)
+		exsl = FormatShader(*target, exsl)
+		if GoslHooks.PostTranslate != nil {
+			if exsl, err = GoslHooks.PostTranslate(fn+ext, exsl); err != nil {
+				return nil, err
+			}
+		}
+		if err := WriteGenerated(slfn, fn, exsl); err != nil {
+			return nil, err
+		}
+		progress.Step(fn)
+	}
 
-		slfn := filepath.Join(*outDir, fn+".hlsl")
-		ioutil.WriteFile(slfn, exsl, 0644)
+	PrintRenames(renames)
+	PrintExcluded()
+	if len(transErrs) > 0 {
+		return gosls, errors.Join(transErrs...)
 	}
+	progress.Stage("generate", 0)
 
-	// check for hlsl files that had no go equivalent
-	for _, hlfn := range hlslFiles {
+	// check for shader files that had no go equivalent
+	for _, hlfn := range shaderFiles {
 		hasGo := false
 		for fn := range gosls {
-			if fn+".hlsl" == hlfn {
+			if fn+ext == filepath.Base(hlfn) {
 				hasGo = true
 				break
 			}
@@ -153,31 +290,295 @@ func ProcessFiles(paths []string) (map[string][]byte, error) {
 			continue
 		}
 		_, hlfno := filepath.Split(hlfn) // could be in a subdir
-		tofn := filepath.Join(*outDir, hlfno)
-		CopyFile(hlfn, tofn)
-		fn := strings.TrimSuffix(hlfno, ".hlsl")
+		tofn := filepath.Join(GenDir(), hlfno)
+		buf, err := os.ReadFile(hlfn)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		os.WriteFile(tofn, OutputIncludes(filepath.Dir(hlfn), buf, outFiles), 0644)
+		fn := strings.TrimSuffix(hlfno, ext)
 		needsCompile[fn] = true // assume any standalone hlsl is a main
+		AddRegionSource(fn, hlfn)
+	}
+
+	if *target != TargetHLSL {
+		// the generated HLSL code and its kernels are not available
+		if err := GenGPUStructs(pkg); err != nil {
+			fmt.Println(err)
+		}
+		return gosls, nil
+	}
+
+	if err := GenSplitStructs(pkg); err != nil {
+		fmt.Println(err)
+	}
+	if err := GenUniformStructs(pkg); err != nil {
+		fmt.Println(err)
+	}
+	if err := GenGPUStructs(pkg); err != nil {
+		fmt.Println(err)
+	}
+
+	if *statsSpec != "" {
+		nm, err := GenStats(pkg, *statsSpec)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			needsCompile[nm] = true
+			AddRegionSource(nm, *statsSpec)
+		}
 	}
 
+	if *varIndex != "" {
+		if err := GenVarIndexes(pkg, *varIndex); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	var bss []*BoundsStruct
+	if *validateFile != "" {
+		bss, err = FindBoundsStructs(pkg)
+		if err != nil {
+			fmt.Println(err)
+		}
+		if err := GenValidate(*validateFile, bss); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	sizes, err := AutotuneSizes()
+	if err != nil {
+		fmt.Println(err)
+	}
+	// variants are the -autotune variant names, with their kernels
+	variants := map[string]*Kernel{}
+	ksrcs := map[string][]byte{}
+	progress.Stage("kernels", len(needsCompile))
 	for fn := range needsCompile {
-		CompileFile(fn + ".hlsl")
+		src, err := os.ReadFile(filepath.Join(GenDir(), fn+".hlsl"))
+		if err != nil {
+			continue
+		}
+		ks := ParseKernels(fn, src)
+		src, edited := RenameEntries(ks, src)
+		for _, k := range ks {
+			if *boundsCheck {
+				if bsrc, ok := BoundsCheck(k, src); ok {
+					src = bsrc
+					edited = true
+				}
+			}
+			if len(bss) > 0 {
+				if vsrc, ok := ValidateKernel(k, src, bss); ok {
+					src = vsrc
+					edited = true
+				}
+			}
+			if isrc, ok := CallInitGlobals(k, src, gosls); ok {
+				src = isrc
+				edited = true
+			}
+		}
+		if edited {
+			ioutil.WriteFile(filepath.Join(GenDir(), fn+".hlsl"), FormatShader("hlsl", src), 0644)
+		}
+		for _, k := range ks {
+			ksrcs[k.Name] = src
+			k.Sources = RegionSources[fn]
+			Kernels[k.Name] = k
+			if len(sizes) > 0 {
+				if len(ks) > 1 {
+					fmt.Printf("gosl: -autotune: no variants for kernel %s: its file has multiple entry points\n", k.Name)
+				} else {
+					for _, vn := range GenVariants(k, src, sizes) {
+						variants[vn] = k
+					}
+				}
+			}
+			if k.HasTrain() {
+				if len(ks) > 1 {
+					fmt.Printf("gosl: no test mode variant for kernel %s: its file has multiple entry points\n", k.Name)
+				} else if vn, ok := GenTestMode(k, src); ok {
+					variants[vn] = k
+				}
+			}
+		}
+		if len(ks[0].Hazards) > 0 {
+			fmt.Printf("\nWARNING: potential same-buffer read / write hazards in kernel: %s\n", fn)
+			for _, hz := range ks[0].Hazards {
+				fmt.Printf("    %s\n", hz)
+			}
+		}
+		progress.Step(fn)
+	}
+
+	if *activeSpec != "" {
+		nm, err := GenActive(pkg, *activeSpec)
+		if err != nil {
+			fmt.Println(err)
+		} else if src, err := os.ReadFile(filepath.Join(GenDir(), nm+".hlsl")); err == nil {
+			needsCompile[nm] = true
+			AddRegionSource(nm, *activeSpec)
+			k := ParseKernel(nm, src)
+			k.Sources = RegionSources[nm]
+			Kernels[nm] = k
+		}
+	}
+
+	if *gatherTypes != "" {
+		nms, err := GenGathers(pkg, *gatherTypes)
+		if err != nil {
+			fmt.Println(err)
+		}
+		for _, nm := range nms {
+			if src, err := os.ReadFile(filepath.Join(GenDir(), nm+".hlsl")); err == nil {
+				needsCompile[nm] = true
+				AddRegionSource(nm, *gatherTypes)
+				k := ParseKernel(nm, src)
+				k.Sources = RegionSources[nm]
+				Kernels[nm] = k
+			}
+		}
+	}
+
+	if *sparseSpec != "" {
+		nm, err := GenSparse(pkg, *sparseSpec)
+		if err != nil {
+			fmt.Println(err)
+		} else if src, err := os.ReadFile(filepath.Join(GenDir(), nm+".hlsl")); err == nil {
+			needsCompile[nm] = true
+			AddRegionSource(nm, *sparseSpec)
+			k := ParseKernel(nm, src)
+			k.Sources = RegionSources[nm]
+			Kernels[nm] = k
+		}
+	}
+
+	iks, err := GenInitKernels(pkg)
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, ik := range iks {
+		nm := ik.Name()
+		if src, err := os.ReadFile(filepath.Join(GenDir(), nm+".hlsl")); err == nil {
+			needsCompile[nm] = true
+			for _, sf := range RegionSources[ik.Region] {
+				AddRegionSource(nm, sf)
+			}
+			k := ParseKernel(nm, src)
+			k.Sources = RegionSources[nm]
+			Kernels[nm] = k
+		}
+	}
+
+	if err := GenSubRanges(ksrcs, variants); err != nil {
+		fmt.Println(err)
+	}
+
+	if *budgetFile != "" {
+		SetBufferSizes(pkg)
+	}
+	SetInterfaceStructs(pkg)
+
+	if *pressure > 0 {
+		ReportPressure(pkg, ksrcs, *pressure)
+	}
+
+	if err := CheckOnly(); err != nil {
+		return gosls, err
+	}
+
+	var cerr error
+	if *dxcPath != ToolNone {
+		var all, cks []*Kernel
+		for _, k := range SortedKernels() {
+			if needsCompile[k.File] {
+				all = append(all, k)
+				if IsOnly(k) {
+					cks = append(cks, k)
+				}
+			}
+		}
+		if found, err := FindDxc(); !found {
+			if err == nil {
+				SkipCompile(all, variants)
+			}
+			return gosls, err
+		}
+		KeepOutputs(all, variants)
+		for fn, k := range variants {
+			if !IsOnly(k) {
+				delete(variants, fn)
+			}
+		}
+		progress.Stage("compile", len(cks)+len(variants))
+		for _, k := range cks {
+			if err := CompileFile(k.File+".hlsl", k.Entry, k.Name+".spv"); err != nil {
+				cerr = fmt.Errorf("gosl: compiling %s.hlsl failed: %w", k.Name, err)
+			}
+			progress.Step(k.Name)
+		}
+		for fn, k := range variants {
+			if err := CompileFile(fn+".hlsl", k.Entry, fn+".spv"); err != nil {
+				cerr = fmt.Errorf("gosl: compiling %s.hlsl failed: %w", fn, err)
+			}
+			progress.Step(fn)
+		}
+	}
+	return gosls, cerr
+}
+
+// PrintRenames prints the table of identifiers that were renamed
+// to avoid HLSL reserved words or invalid (non-ASCII) identifiers.
+func PrintRenames(renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+	nms := make([]string, 0, len(renames))
+	for nm := range renames {
+		nms = append(nms, nm)
+	}
+	sort.Strings(nms)
+	fmt.Printf("\ngosl: renamed identifiers that are HLSL reserved words or invalid HLSL identifiers:\n")
+	for _, nm := range nms {
+		fmt.Printf("    %s\t-> %s\n", nm, renames[nm])
 	}
-	return gosls, nil
 }
 
-func CompileFile(fn string) error {
-	ext := filepath.Ext(fn)
-	ofn := fn[:len(fn)-len(ext)] + ".spv"
+// DxcArgs returns the arguments to dxc for compiling given file
+// with given entry point to given output file.  In -repro mode, IEEE
+// strictness is forced, so the compiler does not apply any
+// value-changing floating point optimizations.  The HLSL version is
+// set for -hlsl 2021.
+func DxcArgs(fn, entry, ofn string) []string {
+	args := []string{"-spirv", "-O3", "-T", "cs_6_0", "-E", entry}
+	if *hlslVersion == HLSL2021 {
+		args = append(args, "-HV", HLSL2021)
+	}
+	if *repro {
+		args = append(args, "-Gis")
+	}
+	return append(args, "-Fo", ofn, fn)
+}
+
+// CompileFile compiles the given shader file in the output directory,
+// with given entry point, to the given .spv output file, and runs the
+// PostCompile hook on it.
+func CompileFile(fn, entry, ofn string) error {
 	// todo: figure out how to use 1.2 here -- see bug issue #1
 	// cmd := exec.Command("glslc", "-fshader-stage=compute", "-O", "--target-env=vulkan1.1", "-o", ofn, fn)
 	// dxc is the reference compiler for hlsl!
-	cmd := exec.Command("dxc", "-spirv", "-O3", "-T", "cs_6_0", "-E", "main", "-Fo", ofn, fn)
-	cmd.Dir, _ = filepath.Abs(*outDir)
+	cmd := exec.Command(*dxcPath, DxcArgs(fn, entry, ofn)...)
+	cmd.Dir, _ = filepath.Abs(GenDir())
 	out, err := cmd.CombinedOutput()
-	fmt.Printf("\n-----------------------------------------------------\ndxc output for: %s\n%s", fn, out)
+	fmt.Printf("\n-----------------------------------------------------\ndxc output for: %s\n%s", ofn, out)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	if GoslHooks.PostCompile != nil {
+		return GoslHooks.PostCompile(filepath.Join(GenDir(), ofn))
+	}
 	return nil
 }
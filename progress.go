@@ -0,0 +1,156 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ProgressInterval is the minimum interval between the progress
+// messages within a stage, so that large packages report their
+// progress regularly without flooding the output.
+const ProgressInterval = 500 * time.Millisecond
+
+// Progress tracks the stages of a generation run, e.g., translating
+// the files and compiling the kernels, reporting the items done in the
+// current stage, the elapsed time and the estimated time remaining in
+// it on Out with -v, and recording the time of each stage for the
+// -report build report.
+type Progress struct {
+
+	// where the progress messages are written, if -v is set
+	Out io.Writer
+
+	// the stages of the run so far, including the current one
+	Stages []*StageTime
+
+	// number of files processed, and kernels generated, for the report
+	Files, Kernels int
+
+	start, stageStart, last time.Time
+
+	// number of items in the current stage, and the number done
+	total, done int
+
+	// now returns the current time, for testing
+	now func() time.Time
+}
+
+// StageTime is the time taken by a stage of a generation run
+type StageTime struct {
+	Name string
+
+	// number of items processed in the stage, e.g., files or kernels
+	Items int `json:",omitempty"`
+
+	Seconds float64
+}
+
+// BuildReport is the -report build profiling report, in JSON
+type BuildReport struct {
+
+	// number of files processed
+	Files int
+
+	// number of kernels generated
+	Kernels int
+
+	// total time of the run
+	Seconds float64
+
+	// time of each stage of the run, in order
+	Stages []*StageTime
+}
+
+// progress is the Progress of the current run
+var progress = NewProgress()
+
+// NewProgress returns a new Progress writing to os.Stderr
+func NewProgress() *Progress {
+	return &Progress{Out: os.Stderr, now: time.Now}
+}
+
+// Start starts a new run
+func (p *Progress) Start() {
+	p.start = p.now()
+	p.Stages = nil
+	p.Files, p.Kernels = 0, 0
+	p.total, p.done = 0, 0
+}
+
+// Stage ends the current stage, if any, and starts the stage with
+// given name, with given number of items to process (0 if not known).
+func (p *Progress) Stage(name string, items int) {
+	p.End()
+	p.stageStart = p.now()
+	p.last = p.stageStart
+	p.total, p.done = items, 0
+	p.Stages = append(p.Stages, &StageTime{Name: name})
+}
+
+// Step records that the given item of the current stage is done,
+// reporting the progress with -v if ProgressInterval has passed since
+// the last message.
+func (p *Progress) Step(item string) {
+	if len(p.Stages) == 0 {
+		return
+	}
+	p.done++
+	st := p.Stages[len(p.Stages)-1]
+	st.Items = p.done
+	now := p.now()
+	if !*verbose || now.Sub(p.last) < ProgressInterval {
+		return
+	}
+	p.last = now
+	el := now.Sub(p.stageStart)
+	msg := fmt.Sprintf("gosl: %s: %d", st.Name, p.done)
+	if p.total > 0 {
+		msg += fmt.Sprintf("/%d", p.total)
+	}
+	msg += fmt.Sprintf(" %s, elapsed %s", item, el.Round(time.Millisecond))
+	if p.total > p.done {
+		eta := el / time.Duration(p.done) * time.Duration(p.total-p.done)
+		msg += fmt.Sprintf(", ETA %s", eta.Round(time.Millisecond))
+	}
+	fmt.Fprintln(p.Out, msg)
+}
+
+// End ends the current stage, if any, reporting its time with -v
+func (p *Progress) End() {
+	if len(p.Stages) == 0 || p.stageStart.IsZero() {
+		return
+	}
+	st := p.Stages[len(p.Stages)-1]
+	st.Seconds = p.now().Sub(p.stageStart).Seconds()
+	p.stageStart = time.Time{}
+	if !*verbose {
+		return
+	}
+	items := ""
+	if st.Items > 0 {
+		items = fmt.Sprintf(" %d", st.Items)
+	}
+	fmt.Fprintf(p.Out, "gosl: %s: done%s in %.3fs, total %.3fs\n", st.Name, items, st.Seconds, p.now().Sub(p.start).Seconds())
+}
+
+// Report returns the BuildReport of the run, ending the current stage
+func (p *Progress) Report() *BuildReport {
+	p.End()
+	return &BuildReport{Files: p.Files, Kernels: p.Kernels, Seconds: p.now().Sub(p.start).Seconds(), Stages: p.Stages}
+}
+
+// WriteReport writes the BuildReport of the run to the given JSON file
+func (p *Progress) WriteReport(path string) error {
+	b, err := json.MarshalIndent(p.Report(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
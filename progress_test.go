@@ -0,0 +1,37 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	v := *verbose
+	*verbose = true
+	defer func() { *verbose = v }()
+	var out strings.Builder
+	tm := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := &Progress{Out: &out, now: func() time.Time { return tm }}
+	p.Start()
+	p.Stage("translate", 4)
+	for _, fn := range []string{"a", "b", "c", "d"} {
+		tm = tm.Add(300 * time.Millisecond)
+		p.Step(fn)
+	}
+	p.Stage("load", 0)
+	tm = tm.Add(time.Second)
+	rep := p.Report()
+	// the messages are at least ProgressInterval apart
+	exp := "gosl: translate: 2/4 b, elapsed 600ms, ETA 600ms\ngosl: translate: 4/4 d, elapsed 1.2s\ngosl: translate: done 4 in 1.200s, total 1.200s\ngosl: load: done in 1.000s, total 2.200s\n"
+	if out.String() != exp {
+		t.Errorf("wrong output:\n%s\nexpected:\n%s", out.String(), exp)
+	}
+	if len(rep.Stages) != 2 || rep.Stages[0].Items != 4 || rep.Stages[1].Seconds != 1 || rep.Seconds != 2.2 {
+		t.Errorf("wrong report: %+v %+v %+v", rep, rep.Stages[0], rep.Stages[1])
+	}
+}
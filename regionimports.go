@@ -0,0 +1,123 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+)
+
+// RegionPackages are the import paths of the packages that can be used
+// within //gosl: start regions, in addition to the packages being
+// processed: those with a translation for the GPU.
+var RegionPackages = map[string]bool{
+	"math":                              true,
+	"cogentcore.org/core/math32":        true,
+	"github.com/emer/gosl/v2/slbool":    true,
+	"github.com/emer/gosl/v2/slcomplex": true,
+	"github.com/emer/gosl/v2/slfft":     true,
+	"github.com/emer/gosl/v2/slfixed":   true,
+	"github.com/emer/gosl/v2/slint":     true,
+	"github.com/emer/gosl/v2/slmath":    true,
+	"github.com/emer/gosl/v2/slrand":    true,
+	"github.com/emer/gosl/v2/slsync":    true,
+	"github.com/emer/gosl/v2/sltype":    true,
+	"github.com/emer/gosl/v2/slwave":    true,
+}
+
+// RegionImportHints are the suggestions for the uses of the packages
+// that cannot be used within the regions, by import path.
+var RegionImportHints = map[string]string{
+	"fmt":          "remove the printing, or move it into a //gosl: cpuonly block",
+	"log":          "remove the logging, or move it into a //gosl: cpuonly block",
+	"os":           "move the code out of the region, as there are no files or processes on the GPU",
+	"reflect":      "use the types directly, as there is no reflection on the GPU",
+	"strings":      "use integer enums instead of strings, which the GPU does not have",
+	"strconv":      "use integer enums instead of strings, which the GPU does not have",
+	"errors":       "return an integer status code instead of an error",
+	"math/rand":    "use slrand, e.g., slrand.Float(&counter, key), which gives the same numbers on the CPU and GPU",
+	"math/rand/v2": "use slrand, e.g., slrand.Float(&counter, key), which gives the same numbers on the CPU and GPU",
+	"sync":         "use slsync for the barriers of the threads of a workgroup",
+	"sync/atomic":  "use slsync for the barriers of the threads of a workgroup",
+}
+
+// withinLines returns true if the given line is strictly within any of
+// the given line ranges, e.g., the lines of the //gosl: start regions,
+// without the directives.
+func withinLines(line int, rs [][2]int) bool {
+	for _, r := range rs {
+		if line > r[0] && line < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRegionImports checks that the given source file only uses the
+// RegionPackages, and the packages being processed (LoadedPackageNames),
+// within the given //gosl: start regions, as line ranges starting at 1,
+// except in the given CPUOnlyBlocks, returning an error for each use of
+// another package, with a suggestion from the RegionImportHints.  Such
+// uses, e.g., of fmt, otherwise only fail when the generated Go code is
+// type checked, or produce broken shader code.  The unsafe package is
+// checked by CheckUnsafe.
+func CheckRegionImports(fn string, src []byte, regions [][2]int, cpuBlocks []CPUOnlyBlock) error {
+	if len(regions) == 0 {
+		return nil
+	}
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, fn, src, 0)
+	if err != nil {
+		return nil // reported elsewhere
+	}
+	imps := map[string]string{} // path by name, of the forbidden imports
+	for _, is := range af.Imports {
+		pth, _ := strconv.Unquote(is.Path.Value)
+		name := path.Base(pth)
+		if is.Name != nil {
+			name = is.Name.Name
+		}
+		if pth == "unsafe" || name == "_" || name == "." || RegionPackages[pth] || LoadedPackageNames[path.Base(pth)] {
+			continue
+		}
+		imps[name] = pth
+	}
+	if len(imps) == 0 {
+		return nil
+	}
+	cpus := make([][2]int, len(cpuBlocks))
+	for i, bl := range cpuBlocks {
+		cpus[i] = [2]int{bl.Start, bl.End}
+	}
+	var errs []error
+	ast.Inspect(af, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if !ok || id.Obj != nil {
+			return true
+		}
+		pth, ok := imps[id.Name]
+		if !ok {
+			return true
+		}
+		if pos := fset.Position(n.Pos()); withinLines(pos.Line, regions) && !withinLines(pos.Line, cpus) {
+			hint, ok := RegionImportHints[pth]
+			if !ok {
+				hint = "move the code out of the //gosl: start region, or into a //gosl: cpuonly block"
+			}
+			errs = append(errs, fmt.Errorf("%s: %s.%s: package %q cannot be used within a //gosl: start region, which can only use math, math32, the gosl packages, and the packages being processed: %s", pos, id.Name, sel.Sel.Name, pth, hint))
+		}
+		return false
+	})
+	return errors.Join(errs...)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const regionImportsTestSrc = `package main
+
+import (
+	"fmt"
+	"math"
+	rf "reflect"
+
+	"cogentcore.org/core/math32"
+	"github.com/emer/gosl/v2/slbool"
+	"github.com/example/lib"
+)
+
+//gosl: start neuron
+
+type Neuron struct {
+	Act float32
+	On  slbool.Bool
+}
+
+func (nrn *Neuron) Update(x float32) {
+	nrn.Act = math32.Max(x, float32(math.Pi))
+	//gosl: cpuonly
+	fmt.Println(nrn.Act)
+	//gosl: end cpuonly
+	fmt.Println(nrn.Act)
+	nrn.Act += lib.Gain(rf.TypeOf(nrn).Size())
+}
+
+//gosl: end neuron
+
+func (nrn *Neuron) String() string {
+	return fmt.Sprintf("%g", nrn.Act)
+}
+`
+
+func TestCheckRegionImports(t *testing.T) {
+	err := CheckRegionImports("neuron.go", []byte(regionImportsTestSrc), [][2]int{{13, 30}}, []CPUOnlyBlock{{Start: 22, End: 24}})
+	if err == nil {
+		t.Fatal("no error for the uses of fmt, lib and reflect")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`neuron.go:25:2: fmt.Println: package "fmt" cannot be used within a //gosl: start region`,
+		"remove the printing, or move it into a //gosl: cpuonly block",
+		`neuron.go:26:13: lib.Gain: package "github.com/example/lib"`,
+		`neuron.go:26:22: rf.TypeOf: package "reflect"`,
+		"use the types directly",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error does not contain %q:\n%s", want, msg)
+		}
+	}
+	if n := strings.Count(msg, "\n") + 1; n != 3 {
+		t.Errorf("got %d errors, want 3, only in the region and outside of the cpuonly block:\n%s", n, msg)
+	}
+
+	LoadedPackageNames["lib"] = true
+	defer delete(LoadedPackageNames, "lib")
+	err = CheckRegionImports("neuron.go", []byte(regionImportsTestSrc), [][2]int{{13, 30}}, []CPUOnlyBlock{{Start: 22, End: 24}})
+	if err == nil || strings.Contains(err.Error(), "lib.Gain") {
+		t.Errorf("the processed package lib is reported: %v", err)
+	}
+}
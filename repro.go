@@ -0,0 +1,195 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	rdebug "runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ReproManifestFile is the name of the reproducibility manifest
+// written in the output directory in -repro mode.
+const ReproManifestFile = "repro_manifest.json"
+
+// ReproManifest records everything in the generation of the shaders
+// that could affect the results of a model, for -repro mode.
+// The GPU device and driver must be recorded by the model at runtime.
+type ReproManifest struct {
+
+	// version of gosl, from the build info
+	Gosl string
+
+	// version of Go used to build gosl
+	Go string
+
+	// command line flags that were set
+	Flags map[string]string
+
+	// output of dxc --version, or none
+	Compiler string
+
+	// arguments passed to dxc, with the file names as {in} and {out},
+	// and the entry point as {entry}
+	CompilerArgs []string
+
+	// SHA-256 hashes of the source files
+	Sources []FileHash
+
+	// SHA-256 hashes of the generated files in the output directory
+	Outputs []FileHash
+
+	// the generated kernels
+	Kernels []ReproKernel
+
+	// things to be aware of for reproducibility
+	Notes []string
+}
+
+// FileHash is the SHA-256 hash of a file, for a ReproManifest
+type FileHash struct {
+	File   string
+	SHA256 string
+}
+
+// ReproKernel records a kernel, for a ReproManifest
+type ReproKernel struct {
+	Name      string
+	Workgroup [3]int
+
+	// HLSL intrinsic functions used in the kernel, whose precision
+	// differs across GPU vendors and drivers
+	Intrinsics []string `json:",omitempty"`
+}
+
+// ReproIntrinsics are the HLSL intrinsics with vendor-dependent precision,
+// i.e., transcendental functions that are not required to be correctly
+// rounded.
+var ReproIntrinsics = []string{"exp", "exp2", "log", "log2", "log10", "pow", "sin", "cos", "tan", "asin", "acos", "atan", "atan2", "sinh", "cosh", "tanh", "sqrt", "rsqrt", "rcp"}
+
+var intrinsicRe = regexp.MustCompile(`\b(\w+)\s*\(`)
+
+// KernelIntrinsics returns the ReproIntrinsics used in given source
+func KernelIntrinsics(src []byte) []string {
+	var ins []string
+	for _, m := range intrinsicRe.FindAllSubmatch(StripHLSLComments(src), -1) {
+		nm := string(m[1])
+		if slices.Contains(ReproIntrinsics, nm) && !slices.Contains(ins, nm) {
+			ins = append(ins, nm)
+		}
+	}
+	sort.Strings(ins)
+	return ins
+}
+
+var includeRe = regexp.MustCompile(`(?m)^\s*#include\s+"([^"]+)"`)
+
+// IncludedSource returns the source of given file in the output
+// directory, followed by the sources of the files it includes,
+// recursively, skipping those already visited.
+func IncludedSource(fn string, visited map[string]bool) []byte {
+	if visited[fn] {
+		return nil
+	}
+	visited[fn] = true
+	src, err := os.ReadFile(filepath.Join(GenDir(), fn))
+	if err != nil {
+		return nil
+	}
+	for _, m := range includeRe.FindAllSubmatch(src, -1) {
+		src = append(src, IncludedSource(string(m[1]), visited)...)
+	}
+	return src
+}
+
+// HashFile returns the FileHash for given file
+func HashFile(fn string) (FileHash, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return FileHash{File: fn}, err
+	}
+	sum := sha256.Sum256(b)
+	return FileHash{File: filepath.ToSlash(fn), SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+// GoslVersion returns the version of gosl from the build info
+func GoslVersion() string {
+	if bi, ok := rdebug.ReadBuildInfo(); ok {
+		return bi.Main.Version
+	}
+	return "unknown"
+}
+
+// GenReproManifest writes the ReproManifestFile in the output directory,
+// for the given source files and the generated Kernels.
+func GenReproManifest(files []string) error {
+	rm := &ReproManifest{Gosl: GoslVersion(), Go: runtime.Version(), Flags: map[string]string{}, Compiler: ToolNone}
+	flag.Visit(func(f *flag.Flag) {
+		rm.Flags[f.Name] = f.Value.String()
+	})
+	if *dxcPath != ToolNone {
+		rm.CompilerArgs = DxcArgs("{in}", "{entry}", "{out}")
+		if out, err := exec.Command(*dxcPath, "--version").CombinedOutput(); err == nil {
+			rm.Compiler = strings.TrimSpace(string(out))
+		} else {
+			rm.Compiler = *dxcPath
+		}
+	}
+	for _, fn := range files {
+		fh, err := HashFile(fn)
+		if err != nil {
+			return err
+		}
+		rm.Sources = append(rm.Sources, fh)
+	}
+	outs, _ := filepath.Glob(filepath.Join(GenDir(), "*"))
+	sort.Strings(outs)
+	for _, fn := range outs {
+		ext := filepath.Ext(fn)
+		if ext != ".hlsl" && ext != ".spv" {
+			continue
+		}
+		fh, err := HashFile(fn)
+		if err != nil {
+			return err
+		}
+		if HasNames() && ext == ".hlsl" { // as written by MapOutputs
+			b, _ := os.ReadFile(fn)
+			sum := sha256.Sum256(OutputIncludesAt(filepath.Base(fn), b))
+			fh.SHA256 = hex.EncodeToString(sum[:])
+		}
+		fh.File = filepath.ToSlash(filepath.Join(*outDir, OutputFile(filepath.Base(fn))))
+		rm.Outputs = append(rm.Outputs, fh)
+	}
+	nondet := false
+	for _, k := range SortedKernels() {
+		rk := ReproKernel{Name: k.Name, Workgroup: k.Workgroup}
+		rk.Intrinsics = KernelIntrinsics(IncludedSource(k.Name+".hlsl", map[string]bool{}))
+		nondet = nondet || len(rk.Intrinsics) > 0
+		rm.Kernels = append(rm.Kernels, rk)
+	}
+	rm.Notes = append(rm.Notes, "record the GPU device name and driver version at runtime: results can differ across them")
+	if nondet {
+		rm.Notes = append(rm.Notes, "kernels use intrinsics whose precision differs across GPU vendors and drivers: use fixed math library versions (e.g., math32.FastExp) for identical results across machines")
+	}
+	if len(Kernels) > 0 && *kernelIDs == "" {
+		rm.Notes = append(rm.Notes, "use -kernelids for the KernelInvocations dispatch counter, to pin random number counters and check the dispatch order")
+	}
+	b, err := json.MarshalIndent(rm, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(GenDir(), ReproManifestFile), append(b, '\n'), 0644)
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKernelIntrinsics(t *testing.T) {
+	src := []byte(`
+// float y = sin(x);
+void main(uint3 idx : SV_DispatchThreadID) {
+	float a = exp(-Data[idx.x].Integ) + FastExp(1.0) + exp (2.0);
+	Data[idx.x].Exp = pow(a, 2.0) + max(a, 0.0);
+}
+`)
+	ins := KernelIntrinsics(src)
+	if strings.Join(ins, ",") != "exp,pow" {
+		t.Errorf("wrong intrinsics: %v", ins)
+	}
+}
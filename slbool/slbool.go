@@ -6,6 +6,8 @@
 package slbool defines a HLSL friendly int32 Bool type.
 The standard HLSL bool type causes obscure errors,
 and the int32 obeys the 4 byte basic alignment requirements.
+Bool2 and Bool4 pack two or four bools into a single uint32,
+with the Get and Set functions, which are HLSL macros in slbool.hlsl.
 
 gosl automatically converts this Go code into appropriate HLSL code.
 */
@@ -82,3 +84,52 @@ func FromBool(b bool) Bool {
 	}
 	return False
 }
+
+// Bool2 is two HLSL friendly bools packed into the low 2 bits of a
+// uint32, which takes a single 4 byte slot in a struct, instead of two
+// [Bool] fields, with their padding.  Use [Get] and [Set] for the bools,
+// which are BoolGet and BoolSet macros in slbool.hlsl.
+type Bool2 uint32
+
+// Bool4 is four HLSL friendly bools packed into the low 4 bits of a
+// uint32, which takes a single 4 byte slot in a struct, instead of four
+// [Bool] fields.  Use [Get] and [Set] for the bools, which are BoolGet
+// and BoolSet macros in slbool.hlsl.
+type Bool4 uint32
+
+// Packed is a [Bool2] or [Bool4] type of bools packed into a uint32
+type Packed interface {
+	Bool2 | Bool4
+}
+
+// Get returns the bool at the given index (0-1 for [Bool2], 0-3 for [Bool4])
+func Get[P Packed](b P, i uint32) bool {
+	return (uint32(b)>>i)&1 != 0
+}
+
+// Set sets the bool at the given index (0-1 for [Bool2], 0-3 for [Bool4])
+func Set[P Packed](b *P, i uint32, v bool) {
+	if v {
+		*b |= P(1 << i)
+	} else {
+		*b &^= P(1 << i)
+	}
+}
+
+// Pack2 returns the given bools packed into a [Bool2], in order
+func Pack2(x, y bool) Bool2 {
+	return Bool2(bit(x) | bit(y)<<1)
+}
+
+// Pack4 returns the given bools packed into a [Bool4], in order
+func Pack4(x, y, z, w bool) Bool4 {
+	return Bool4(bit(x) | bit(y)<<1 | bit(z)<<2 | bit(w)<<3)
+}
+
+// bit returns 1 for true and 0 for false
+func bit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
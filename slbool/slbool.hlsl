@@ -0,0 +1,28 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Original file is in Go package: github.com/emer/gosl/v2/slbool
+// See README.md there for documentation.
+
+// These macros get and set the bools packed into the low bits of the
+// uint of the slbool.Bool2 and Bool4 types, with equivalent Go versions
+// in slbool.go.  Macros work on any lvalue, e.g., a field of a buffer
+// element, which a function could only change as a local inout copy.
+
+#ifndef __SLBOOL_HLSL__
+#define __SLBOOL_HLSL__
+
+// BoolGet returns the bool at index i of the packed bools b
+#define BoolGet(b, i) ((((b) >> (i)) & 1u) != 0)
+
+// BoolSet sets the bool at index i of the packed bools b to v
+#define BoolSet(b, i, v) ((b) = (v) ? ((b) | (1u << (i))) : ((b) & ~(1u << (i))))
+
+// BoolPack2 returns the given bools packed into a Bool2 uint
+#define BoolPack2(x, y) (uint(x) | (uint(y) << 1))
+
+// BoolPack4 returns the given bools packed into a Bool4 uint
+#define BoolPack4(x, y, z, w) (uint(x) | (uint(y) << 1) | (uint(z) << 2) | (uint(w) << 3))
+
+#endif // __SLBOOL_HLSL__
@@ -0,0 +1,27 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slbool
+
+import "testing"
+
+func TestPacked(t *testing.T) {
+	var b Bool4
+	Set(&b, 1, true)
+	Set(&b, 3, true)
+	Set(&b, 3, false)
+	Set(&b, 0, true)
+	if b != Pack4(true, true, false, false) || b != 3 {
+		t.Errorf("wrong packed bools: %b", b)
+	}
+	for i, want := range []bool{true, true, false, false} {
+		if Get(b, uint32(i)) != want {
+			t.Errorf("bool %d != %v", i, want)
+		}
+	}
+	p := Pack2(false, true)
+	if Get(p, 0) || !Get(p, 1) || p != 2 {
+		t.Errorf("wrong Bool2: %b", p)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slcomplex
+
+import (
+	"cogentcore.org/core/math32"
+	"github.com/emer/gosl/v2/sltype"
+)
+
+// These are Go versions of the same complex number functions available
+// in slcomplex.hlsl, for FFT-based analysis, e.g., of LFP signals.
+// A complex number is a float2 on the GPU, with the real part in x
+// and the imaginary part in y, which is also how gosl translates
+// complex64 values and operations.
+
+// Complex is a complex number as a float2: X is the real part,
+// and Y is the imaginary part. It has the same memory layout as
+// a complex64.
+type Complex = sltype.Float2
+
+// New returns a new complex number with given real and imaginary parts
+func New(re, im float32) Complex {
+	return Complex{X: re, Y: im}
+}
+
+// FromComplex64 converts a complex64 into a Complex
+func FromComplex64(c complex64) Complex {
+	return Complex{X: real(c), Y: imag(c)}
+}
+
+// ToComplex64 converts a Complex into a complex64
+func ToComplex64(c Complex) complex64 {
+	return complex(c.X, c.Y)
+}
+
+// Add returns a + b
+func Add(a, b Complex) Complex {
+	return Complex{X: a.X + b.X, Y: a.Y + b.Y}
+}
+
+// Sub returns a - b
+func Sub(a, b Complex) Complex {
+	return Complex{X: a.X - b.X, Y: a.Y - b.Y}
+}
+
+// Mul returns a * b
+func Mul(a, b Complex) Complex {
+	return Complex{X: a.X*b.X - a.Y*b.Y, Y: a.X*b.Y + a.Y*b.X}
+}
+
+// Div returns a / b, using the textbook formula, as on the GPU,
+// which can differ in the last bits from Go complex64 division.
+func Div(a, b Complex) Complex {
+	d := b.X*b.X + b.Y*b.Y
+	return Complex{X: (a.X*b.X + a.Y*b.Y) / d, Y: (a.Y*b.X - a.X*b.Y) / d}
+}
+
+// Conj returns the complex conjugate of c
+func Conj(c Complex) Complex {
+	return Complex{X: c.X, Y: -c.Y}
+}
+
+// Scale returns c scaled by the real value s
+func Scale(c Complex, s float32) Complex {
+	return Complex{X: c.X * s, Y: c.Y * s}
+}
+
+// Abs returns the magnitude of c
+func Abs(c Complex) float32 {
+	return math32.Sqrt(c.X*c.X + c.Y*c.Y)
+}
+
+// Abs2 returns the squared magnitude of c, i.e., the power
+func Abs2(c Complex) float32 {
+	return c.X*c.X + c.Y*c.Y
+}
+
+// Arg returns the phase angle of c, in radians, in [-Pi, Pi]
+func Arg(c Complex) float32 {
+	return math32.Atan2(c.Y, c.X)
+}
+
+// Exp returns e^c
+func Exp(c Complex) Complex {
+	return Scale(Expi(c.Y), math32.Exp(c.X))
+}
+
+// Expi returns e^(i theta): the unit complex number at angle theta,
+// e.g., the twiddle factors of an FFT.
+func Expi(theta float32) Complex {
+	return Complex{X: math32.Cos(theta), Y: math32.Sin(theta)}
+}
+
+// Polar returns the complex number with magnitude r and angle theta
+func Polar(r, theta float32) Complex {
+	return Scale(Expi(theta), r)
+}
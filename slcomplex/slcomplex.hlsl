@@ -0,0 +1,91 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Original file is in Go package: github.com/emer/gosl/v2/slcomplex
+// See README.md there for documentation.
+
+// These complex number functions support FFT-based analysis, with
+// equivalent Go versions available in slcomplex.go.  A complex number
+// is a float2, with the real part in x and the imaginary part in y.
+
+#ifndef __SLCOMPLEX_HLSL__
+#define __SLCOMPLEX_HLSL__
+
+// ComplexNew returns a new complex number with given real and imaginary parts
+float2 ComplexNew(float re, float im) {
+	return float2(re, im);
+}
+
+// ComplexFromComplex64 returns c, which is already a float2 on the GPU
+float2 ComplexFromComplex64(float2 c) {
+	return c;
+}
+
+// ComplexToComplex64 returns c, which is already a float2 on the GPU
+float2 ComplexToComplex64(float2 c) {
+	return c;
+}
+
+// ComplexAdd returns a + b
+float2 ComplexAdd(float2 a, float2 b) {
+	return a + b;
+}
+
+// ComplexSub returns a - b
+float2 ComplexSub(float2 a, float2 b) {
+	return a - b;
+}
+
+// ComplexMul returns a * b
+float2 ComplexMul(float2 a, float2 b) {
+	return float2(a.x * b.x - a.y * b.y, a.x * b.y + a.y * b.x);
+}
+
+// ComplexDiv returns a / b
+float2 ComplexDiv(float2 a, float2 b) {
+	float d = b.x * b.x + b.y * b.y;
+	return float2((a.x * b.x + a.y * b.y) / d, (a.y * b.x - a.x * b.y) / d);
+}
+
+// ComplexConj returns the complex conjugate of c
+float2 ComplexConj(float2 c) {
+	return float2(c.x, -c.y);
+}
+
+// ComplexScale returns c scaled by the real value s
+float2 ComplexScale(float2 c, float s) {
+	return float2(c.x * s, c.y * s);
+}
+
+// ComplexAbs returns the magnitude of c
+float ComplexAbs(float2 c) {
+	return sqrt(c.x * c.x + c.y * c.y);
+}
+
+// ComplexAbs2 returns the squared magnitude of c, i.e., the power
+float ComplexAbs2(float2 c) {
+	return c.x * c.x + c.y * c.y;
+}
+
+// ComplexArg returns the phase angle of c, in radians, in [-Pi, Pi]
+float ComplexArg(float2 c) {
+	return atan2(c.y, c.x);
+}
+
+// ComplexExpi returns e^(i theta): the unit complex number at angle theta
+float2 ComplexExpi(float theta) {
+	return float2(cos(theta), sin(theta));
+}
+
+// ComplexExp returns e^c
+float2 ComplexExp(float2 c) {
+	return ComplexScale(ComplexExpi(c.y), exp(c.x));
+}
+
+// ComplexPolar returns the complex number with magnitude r and angle theta
+float2 ComplexPolar(float r, float theta) {
+	return ComplexScale(ComplexExpi(theta), r);
+}
+
+#endif // __SLCOMPLEX_HLSL__
@@ -0,0 +1,33 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slcomplex
+
+import (
+	"math/cmplx"
+	"testing"
+
+	"cogentcore.org/core/math32"
+)
+
+func TestArith(t *testing.T) {
+	a, b := complex64(complex(1.5, -2)), complex64(complex(-0.25, 3))
+	ca, cb := FromComplex64(a), FromComplex64(b)
+	near := func(nm string, got Complex, want complex64) {
+		t.Helper()
+		if cmplx.Abs(complex128(ToComplex64(got)-want)) > 1e-5 {
+			t.Errorf("%s: got %v, want %v", nm, got, want)
+		}
+	}
+	near("Add", Add(ca, cb), a+b)
+	near("Sub", Sub(ca, cb), a-b)
+	near("Mul", Mul(ca, cb), a*b)
+	near("Div", Div(ca, cb), a/b)
+	near("Conj", Conj(ca), complex(1.5, 2))
+	near("Exp", Exp(ca), complex64(cmplx.Exp(complex128(a))))
+	near("Polar", Polar(Abs(ca), Arg(ca)), a)
+	if d := Abs2(ca) - 6.25; math32.Abs(d) > 1e-6 {
+		t.Errorf("Abs2: got %g, want 6.25", Abs2(ca))
+	}
+}
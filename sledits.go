@@ -6,42 +6,29 @@ package main
 
 import (
 	"bytes"
+	"slices"
 	"strings"
 )
 
-// MoveLines moves the st,ed region to 'to' line
-func MoveLines(lines *[][]byte, to, st, ed int) {
-	mvln := (*lines)[st:ed]
-	btwn := (*lines)[to:st]
-	aft := (*lines)[ed:len(*lines)]
-	nln := make([][]byte, to, len(*lines))
-	copy(nln, (*lines)[:to])
-	nln = append(nln, mvln...)
-	nln = append(nln, btwn...)
-	nln = append(nln, aft...)
-	*lines = nln
-}
-
 // SlEdits performs post-generation edits for hlsl
 // * moves hlsl segments around, e.g., methods
 // into their proper classes
 // * fixes printf, slice other common code
-// returns true if a slrand. prefix was found -- drives copying
-// of that file.
-func SlEdits(src []byte) ([]byte, bool) {
-	// return src // uncomment to show original without edits
-	nl := []byte("\n")
-	lines := bytes.Split(src, nl)
+// returns the HeaderPackages whose prefix was found (e.g., slrand.),
+// which drives copying of their header files.
+func SlEdits(src []byte) ([]byte, []string) {
+	// return src, nil // uncomment to show original without edits
+	lb := NewLineBuffer(src)
 
-	lines = SlEditsMethMove(lines)
-	hasSlrand := SlEditsReplace(lines)
+	SlEditsMethMove(lb)
+	hdrs := SlEditsReplace(lb.Lines)
 
-	return bytes.Join(lines, nl), hasSlrand
+	return lb.Bytes(), hdrs
 }
 
 // SlEditsMethMove moves hlsl segments around, e.g., methods
 // into their proper classes
-func SlEditsMethMove(lines [][]byte) [][]byte {
+func SlEditsMethMove(lb *LineBuffer) {
 	type sted struct {
 		st, ed int
 	}
@@ -63,10 +50,10 @@ func SlEditsMethMove(lines [][]byte) [][]byte {
 
 	li := 0
 	for {
-		if li >= len(lines) {
+		if li >= lb.Len() {
 			break
 		}
-		ln := lines[li]
+		ln := lb.Lines[li]
 		if len(ln) >= 2 && string(ln[0:1]) == "//" {
 			if curComSt >= 0 {
 				lastComEd = li
@@ -102,16 +89,16 @@ func SlEditsMethMove(lines [][]byte) [][]byte {
 				cl := tag[len(endclass):]
 				st := classes[cl]
 				classes[cl] = sted{st: st.st, ed: li - 1}
-				lines = append(lines[:li], lines[li+1:]...) // delete marker
+				lb.Delete(li, li+1) // delete marker
 				// fmt.Printf("cl: %s at %v\n", cl, classes[cl])
 				li--
 			case strings.HasPrefix(tag, method):
 				cl := tag[len(method):]
-				lines = append(lines[:li], lines[li+1:]...) // delete marker
+				lb.Delete(li, li+1) // delete marker
 				li--
 				lastMeth = cl
 				if lastComEd == li {
-					lines = append(lines[:lastComSt], lines[lastComEd+1:]...) // delete comments
+					lb.Delete(lastComSt, lastComEd+1) // delete comments
 					lastMethSt = lastComSt
 					li = lastComSt - 1
 				} else {
@@ -120,16 +107,32 @@ func SlEditsMethMove(lines [][]byte) [][]byte {
 			case tag == endmethod:
 				se, ok := classes[lastMeth]
 				if ok {
-					lines = append(lines[:li], lines[li+1:]...) // delete marker
-					MoveLines(&lines, se.ed, lastMethSt, li+1)  // extra blank
-					classes[lastMeth] = sted{st: se.st, ed: se.ed + ((li + 1) - lastMethSt)}
+					lb.Delete(li, li+1) // delete marker
+					// the doc comment and signature are indented as the closing
+					// brace of the method, as their indentation depends on the
+					// comments and declarations printed before them
+					ind := lb.Lines[li-1][:len(lb.Lines[li-1])-len(bytes.TrimLeft(lb.Lines[li-1], " \t"))]
+					for mi := lastMethSt; mi < li; mi++ {
+						ml := bytes.TrimLeft(lb.Lines[mi], " \t")
+						lb.Lines[mi] = append(slices.Clone(ind), ml...)
+						if !bytes.HasPrefix(ml, []byte("//")) {
+							break
+						}
+					}
+					lb.Move(se.ed, lastMethSt, li+1) // extra blank
+					nmv := (li + 1) - lastMethSt
+					for cl, ce := range classes { // classes in between are moved down
+						if cl != lastMeth && ce.st >= se.ed && ce.st < lastMethSt {
+							classes[cl] = sted{st: ce.st + nmv, ed: ce.ed + nmv}
+						}
+					}
+					classes[lastMeth] = sted{st: se.st, ed: se.ed + nmv}
 					li -= 2
 				}
 			}
 		}
 		li++
 	}
-	return lines
 }
 
 type Replace struct {
@@ -148,6 +151,29 @@ var Replaces = []Replace{
 	{[]byte("math.Float32bits("), []byte("asuint(")},
 	{[]byte("shaders."), []byte("")},
 	{[]byte("slrand."), []byte("Rand")},
+	{[]byte("slfixed."), []byte("Fixed")},
+	{[]byte("slmath."), []byte("Slmath")}, // before the math. prefix
+	{[]byte("slcomplex.Complex"), []byte("float2")},
+	{[]byte("slcomplex."), []byte("Complex")},
+	{[]byte("complex64"), []byte("float2")},
+	{[]byte("slsync.GroupBarrier("), []byte("GroupMemoryBarrierWithGroupSync(")},
+	{[]byte("slsync.DeviceBarrier("), []byte("DeviceMemoryBarrierWithGroupSync(")},
+	{[]byte("slsync.AllBarrier("), []byte("AllMemoryBarrierWithGroupSync(")},
+	{[]byte("slsync."), []byte("")},
+	{[]byte("slwave.LaneIndex("), []byte("WaveGetLaneIndex(")},
+	{[]byte("slwave.LaneCount("), []byte("WaveGetLaneCount(")},
+	{[]byte("slwave.IsFirstLane("), []byte("WaveIsFirstLane(")},
+	{[]byte("slwave.ActiveSum("), []byte("WaveActiveSum(")},
+	{[]byte("slwave.ActiveMin("), []byte("WaveActiveMin(")},
+	{[]byte("slwave.ActiveMax("), []byte("WaveActiveMax(")},
+	{[]byte("slwave.ActiveAllTrue("), []byte("WaveActiveAllTrue(")},
+	{[]byte("slwave.ActiveAnyTrue("), []byte("WaveActiveAnyTrue(")},
+	{[]byte("slwave.ReadLaneFirst("), []byte("WaveReadLaneFirst(")},
+	{[]byte("slwave.ReadLaneAt("), []byte("WaveReadLaneAt(")},
+	{[]byte("slwave.PrefixSum("), []byte("WavePrefixSum(")},
+	{[]byte("slint.MinInt("), []byte("min(")},
+	{[]byte("slint.MaxInt("), []byte("max(")},
+	{[]byte("slint.Clamp("), []byte("clamp(")},
 	{[]byte("sltype.U"), []byte("u")},
 	{[]byte("sltype.F"), []byte("f")},
 	{[]byte(".SetFromVector2("), []byte("=(")},
@@ -157,6 +183,12 @@ var Replaces = []Replace{
 	{[]byte(".SetBool(true)"), []byte("=1")},
 	{[]byte(".SetBool(false)"), []byte("=0")},
 	{[]byte(".SetBool("), []byte("=int(")},
+	{[]byte("slbool.Bool2"), []byte("uint")},
+	{[]byte("slbool.Bool4"), []byte("uint")},
+	{[]byte("slbool.Get("), []byte("BoolGet(")},
+	{[]byte("slbool.Set("), []byte("BoolSet(")},
+	{[]byte("slbool.Pack2("), []byte("BoolPack2(")},
+	{[]byte("slbool.Pack4("), []byte("BoolPack4(")},
 	{[]byte("slbool.Bool"), []byte("int")},
 	{[]byte("slbool.True"), []byte("1")},
 	{[]byte("slbool.False"), []byte("0")},
@@ -176,6 +208,186 @@ var Replaces = []Replace{
 	// {[]byte(""), []byte("")},
 }
 
+// WGSLReplaces are the Replaces for the WGSL -target.  The slprint
+// translation already uses the WGSL names of the types, so these are
+// mainly for the functions of other packages.
+var WGSLReplaces = []Replace{
+	{[]byte("float32"), []byte("f32")},
+	{[]byte("float64"), []byte("f32")},
+	{[]byte("uint32"), []byte("u32")},
+	{[]byte("int32"), []byte("i32")},
+	{[]byte("math32.FastExp("), []byte("exp(")},
+	{[]byte("math.Float32frombits("), []byte("bitcast<f32>(")},
+	{[]byte("math.Float32bits("), []byte("bitcast<u32>(")},
+	{[]byte("shaders."), []byte("")},
+	{[]byte("slsync.GroupBarrier("), []byte("workgroupBarrier(")},
+	{[]byte("slsync.DeviceBarrier("), []byte("storageBarrier(")},
+	{[]byte("slsync.AllBarrier("), []byte("storageBarrier(); workgroupBarrier(")},
+	{[]byte("slsync."), []byte("")},
+	{[]byte("slwave.IsFirstLane("), []byte("subgroupElect(")},
+	{[]byte("slwave.ActiveSum("), []byte("subgroupAdd(")},
+	{[]byte("slwave.ActiveMin("), []byte("subgroupMin(")},
+	{[]byte("slwave.ActiveMax("), []byte("subgroupMax(")},
+	{[]byte("slwave.ActiveAllTrue("), []byte("subgroupAll(")},
+	{[]byte("slwave.ActiveAnyTrue("), []byte("subgroupAny(")},
+	{[]byte("slwave.ReadLaneFirst("), []byte("subgroupBroadcastFirst(")},
+	{[]byte("slwave.ReadLaneAt("), []byte("subgroupShuffle(")},
+	{[]byte("slwave.PrefixSum("), []byte("subgroupExclusiveAdd(")},
+	{[]byte("slint.MinInt("), []byte("min(")},
+	{[]byte("slint.MaxInt("), []byte("max(")},
+	{[]byte("slint.Clamp("), []byte("clamp(")},
+	{[]byte(".SetFromVector2("), []byte("=(")},
+	{[]byte(".SetFrom2("), []byte("=(")},
+	{[]byte(".IsTrue()"), []byte("==1")},
+	{[]byte(".IsFalse()"), []byte("==0")},
+	{[]byte(".SetBool(true)"), []byte("=1")},
+	{[]byte(".SetBool(false)"), []byte("=0")},
+	{[]byte(".SetBool("), []byte("=i32(")},
+	{[]byte("slbool.Bool2"), []byte("u32")},
+	{[]byte("slbool.Bool4"), []byte("u32")},
+	{[]byte("slbool.Bool"), []byte("i32")},
+	{[]byte("slbool.True"), []byte("1")},
+	{[]byte("slbool.False"), []byte("0")},
+	{[]byte("slbool.IsTrue("), []byte("(1 == ")},
+	{[]byte("slbool.IsFalse("), []byte("(0 == ")},
+	{[]byte("slbool.FromBool("), []byte("i32(")},
+	{[]byte("bools.ToFloat32("), []byte("f32(")},
+	{[]byte("bools.FromFloat32("), []byte("bool(")},
+	{[]byte("num.FromBool[f32]("), []byte("f32(")},
+	{[]byte("num.ToBool("), []byte("bool(")},
+}
+
+// GLSLReplaces are the Replaces for the GLSL -target, which has different
+// names than HLSL for vector types, bit casts, and barriers.
+var GLSLReplaces = []Replace{
+	{[]byte("float32"), []byte("float")},
+	{[]byte("float64"), []byte("double")},
+	{[]byte("uint32"), []byte("uint")},
+	{[]byte("int32"), []byte("int")},
+	{[]byte("int64"), []byte("int64_t")},
+	{[]byte("math32.FastExp("), []byte("FastExp(")},
+	{[]byte("math.Float32frombits("), []byte("uintBitsToFloat(")},
+	{[]byte("math.Float32bits("), []byte("floatBitsToUint(")},
+	{[]byte("math32.Atan2("), []byte("atan(")},
+	{[]byte("math.Atan2("), []byte("atan(")},
+	{[]byte("shaders."), []byte("")},
+	{[]byte("slsync.GroupBarrier("), []byte("memoryBarrierShared(); barrier(")},
+	{[]byte("slsync.DeviceBarrier("), []byte("memoryBarrierBuffer(); barrier(")},
+	{[]byte("slsync.AllBarrier("), []byte("memoryBarrier(); barrier(")},
+	{[]byte("slsync."), []byte("")},
+	{[]byte("slwave.LaneIndex()"), []byte("gl_SubgroupInvocationID")},
+	{[]byte("slwave.LaneCount()"), []byte("gl_SubgroupSize")},
+	{[]byte("slwave.IsFirstLane("), []byte("subgroupElect(")},
+	{[]byte("slwave.ActiveSum("), []byte("subgroupAdd(")},
+	{[]byte("slwave.ActiveMin("), []byte("subgroupMin(")},
+	{[]byte("slwave.ActiveMax("), []byte("subgroupMax(")},
+	{[]byte("slwave.ActiveAllTrue("), []byte("subgroupAll(")},
+	{[]byte("slwave.ActiveAnyTrue("), []byte("subgroupAny(")},
+	{[]byte("slwave.ReadLaneFirst("), []byte("subgroupBroadcastFirst(")},
+	{[]byte("slwave.ReadLaneAt("), []byte("subgroupShuffle(")},
+	{[]byte("slwave.PrefixSum("), []byte("subgroupExclusiveAdd(")},
+	{[]byte("slint.MinInt("), []byte("min(")},
+	{[]byte("slint.MaxInt("), []byte("max(")},
+	{[]byte("slint.Clamp("), []byte("clamp(")},
+	{[]byte("sltype.Float2"), []byte("vec2")},
+	{[]byte("sltype.Float3"), []byte("vec3")},
+	{[]byte("sltype.Float4"), []byte("vec4")},
+	{[]byte("sltype.Int2"), []byte("ivec2")},
+	{[]byte("sltype.Int3"), []byte("ivec3")},
+	{[]byte("sltype.Int4"), []byte("ivec4")},
+	{[]byte("sltype.Uint2"), []byte("uvec2")},
+	{[]byte("sltype.Uint3"), []byte("uvec3")},
+	{[]byte("sltype.Uint4"), []byte("uvec4")},
+	{[]byte("sltype.U"), []byte("u")},
+	{[]byte("sltype.F"), []byte("f")},
+	{[]byte("sltype.I"), []byte("i")},
+	{[]byte(".SetFromVector2("), []byte("=(")},
+	{[]byte(".SetFrom2("), []byte("=(")},
+	{[]byte(".IsTrue()"), []byte("==1")},
+	{[]byte(".IsFalse()"), []byte("==0")},
+	{[]byte(".SetBool(true)"), []byte("=1")},
+	{[]byte(".SetBool(false)"), []byte("=0")},
+	{[]byte(".SetBool("), []byte("=int(")},
+	{[]byte("slbool.Bool2"), []byte("uint")},
+	{[]byte("slbool.Bool4"), []byte("uint")},
+	{[]byte("slbool.Bool"), []byte("int")},
+	{[]byte("slbool.True"), []byte("1")},
+	{[]byte("slbool.False"), []byte("0")},
+	{[]byte("slbool.IsTrue("), []byte("(1 == ")},
+	{[]byte("slbool.IsFalse("), []byte("(0 == ")},
+	{[]byte("slbool.FromBool("), []byte("int(")},
+	{[]byte("bools.ToFloat32("), []byte("float(")},
+	{[]byte("bools.FromFloat32("), []byte("bool(")},
+	{[]byte("num.FromBool[float]("), []byte("float(")},
+	{[]byte("num.ToBool("), []byte("bool(")},
+}
+
+// MetalReplaces are the Replaces for the Metal -target, which has no
+// double or int64_t, and different names than HLSL for bit casts and
+// barriers.
+var MetalReplaces = []Replace{
+	{[]byte("float32"), []byte("float")},
+	{[]byte("float64"), []byte("float")},
+	{[]byte("uint32"), []byte("uint")},
+	{[]byte("int32"), []byte("int")},
+	{[]byte("uint64"), []byte("ulong")},
+	{[]byte("int64"), []byte("long")},
+	{[]byte("math32.FastExp("), []byte("FastExp(")},
+	{[]byte("math.Float32frombits("), []byte("as_type<float>(")},
+	{[]byte("math.Float32bits("), []byte("as_type<uint>(")},
+	{[]byte("shaders."), []byte("")},
+	{[]byte("slsync.GroupBarrier("), []byte("threadgroup_barrier(mem_flags::mem_threadgroup")},
+	{[]byte("slsync.DeviceBarrier("), []byte("threadgroup_barrier(mem_flags::mem_device")},
+	{[]byte("slsync.AllBarrier("), []byte("threadgroup_barrier(mem_flags::mem_device | mem_flags::mem_threadgroup")},
+	{[]byte("slsync."), []byte("")},
+	{[]byte("slwave.IsFirstLane("), []byte("simd_is_first(")},
+	{[]byte("slwave.ActiveSum("), []byte("simd_sum(")},
+	{[]byte("slwave.ActiveMin("), []byte("simd_min(")},
+	{[]byte("slwave.ActiveMax("), []byte("simd_max(")},
+	{[]byte("slwave.ActiveAllTrue("), []byte("simd_all(")},
+	{[]byte("slwave.ActiveAnyTrue("), []byte("simd_any(")},
+	{[]byte("slwave.ReadLaneFirst("), []byte("simd_broadcast_first(")},
+	{[]byte("slwave.ReadLaneAt("), []byte("simd_shuffle(")},
+	{[]byte("slwave.PrefixSum("), []byte("simd_prefix_exclusive_sum(")},
+	{[]byte("slint.MinInt("), []byte("min(")},
+	{[]byte("slint.MaxInt("), []byte("max(")},
+	{[]byte("slint.Clamp("), []byte("clamp(")},
+	{[]byte("sltype.U"), []byte("u")},
+	{[]byte("sltype.F"), []byte("f")},
+	{[]byte(".SetFromVector2("), []byte("=(")},
+	{[]byte(".SetFrom2("), []byte("=(")},
+	{[]byte(".IsTrue()"), []byte("==1")},
+	{[]byte(".IsFalse()"), []byte("==0")},
+	{[]byte(".SetBool(true)"), []byte("=1")},
+	{[]byte(".SetBool(false)"), []byte("=0")},
+	{[]byte(".SetBool("), []byte("=int(")},
+	{[]byte("slbool.Bool2"), []byte("uint")},
+	{[]byte("slbool.Bool4"), []byte("uint")},
+	{[]byte("slbool.Bool"), []byte("int")},
+	{[]byte("slbool.True"), []byte("1")},
+	{[]byte("slbool.False"), []byte("0")},
+	{[]byte("slbool.IsTrue("), []byte("(1 == ")},
+	{[]byte("slbool.IsFalse("), []byte("(0 == ")},
+	{[]byte("slbool.FromBool("), []byte("int(")},
+	{[]byte("bools.ToFloat32("), []byte("float(")},
+	{[]byte("bools.FromFloat32("), []byte("bool(")},
+	{[]byte("num.FromBool[float]("), []byte("float(")},
+	{[]byte("num.ToBool("), []byte("bool(")},
+}
+
+// TargetReplaces returns the Replaces for the -target
+func TargetReplaces() []Replace {
+	switch *target {
+	case TargetWGSL:
+		return WGSLReplaces
+	case TargetGLSL:
+		return GLSLReplaces
+	case TargetMetal:
+		return MetalReplaces
+	}
+	return Replaces
+}
+
 func MathReplaceAll(mat, ln []byte) []byte {
 	ml := len(mat)
 	st := 0
@@ -194,28 +406,51 @@ func MathReplaceAll(mat, ln []byte) []byte {
 	}
 }
 
+// HeaderPackages are the gosl packages that have a <pkg>.hlsl
+// header file, which is copied to the output directory when
+// the <pkg>. prefix is used.
+var HeaderPackages = []string{"slrand", "slfixed", "slcomplex", "slmath", "slbool"}
+
+// HeaderFuncs are the prefixes of the functions that use the header
+// file of those HeaderPackages whose other names are translated by the
+// Replaces, e.g., slbool.Bool, so that the header is only copied for them.
+var HeaderFuncs = map[string][]string{
+	"slbool": {"slbool.Get(", "slbool.Set(", "slbool.Pack2(", "slbool.Pack4("},
+}
+
+// usesHeader returns true if the given line uses the header file of
+// the given HeaderPackages package.
+func usesHeader(ln []byte, hp string) bool {
+	fns, ok := HeaderFuncs[hp]
+	if !ok {
+		return bytes.Contains(ln, []byte(hp+"."))
+	}
+	return slices.ContainsFunc(fns, func(fn string) bool { return bytes.Contains(ln, []byte(fn)) })
+}
+
 // SlEditsReplace replaces Go with equivalent HLSL code
-// returns true if has slrand. -- auto include that header file
-// if so.
-func SlEditsReplace(lines [][]byte) bool {
+// returns the HeaderPackages used -- auto include those header files.
+func SlEditsReplace(lines [][]byte) []string {
 	mt32 := []byte("math32.")
 	mth := []byte("math.")
-	slr := []byte("slrand.")
 	include := []byte("#include")
-	hasSlrand := false
+	replaces := TargetReplaces()
+	var hdrs []string
 	for li, ln := range lines {
 		if bytes.Contains(ln, include) {
 			continue
 		}
-		for _, r := range Replaces {
-			if !hasSlrand && bytes.Contains(ln, slr) {
-				hasSlrand = true
+		for _, hp := range HeaderPackages {
+			if usesHeader(ln, hp) && !slices.Contains(hdrs, hp) {
+				hdrs = append(hdrs, hp)
 			}
+		}
+		for _, r := range replaces {
 			ln = bytes.ReplaceAll(ln, r.From, r.To)
 		}
 		ln = MathReplaceAll(mt32, ln)
 		ln = MathReplaceAll(mth, ln)
 		lines[li] = ln
 	}
-	return hasSlrand
+	return hdrs
 }
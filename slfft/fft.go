@@ -0,0 +1,128 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slfft
+
+import (
+	"fmt"
+	"math/bits"
+	"sync"
+
+	"github.com/emer/gosl/v2/goslrun"
+	"github.com/emer/gosl/v2/slcomplex"
+	"github.com/emer/gosl/v2/slgpu"
+)
+
+// FFT computes the FFTs of batches of signals of a power-of-two size,
+// on the GPU with the slfft kernel if GPU has been called, or else on
+// the CPU, with the same code.
+type FFT struct {
+
+	// parameters, with the signal size N
+	Params Params
+
+	// GPU run of the slfft kernel, if using the GPU
+	run *goslrun.Run
+
+	// number of values in the GPU data buffer
+	nData int
+
+	// GPU data buffer
+	data []slcomplex.Complex
+}
+
+// cpuMu protects the global shared memory used by TransformCPU
+var cpuMu sync.Mutex
+
+// New returns a new FFT for signals of n values, which must be a
+// power of 2 between 2 and MaxN.
+func New(n int) (*FFT, error) {
+	if n < 2 || n > MaxN || n&(n-1) != 0 {
+		return nil, fmt.Errorf("slfft: size must be a power of 2 between 2 and %d: %d", MaxN, n)
+	}
+	f := &FFT{}
+	f.Params.N = uint32(n)
+	f.Params.Log2N = uint32(bits.TrailingZeros(uint(n)))
+	return f, nil
+}
+
+// GPU has the FFT run on the GPU, with the slfft kernel compiled into
+// the given directory by gosl, e.g., shaders, on the goslrun.Default
+// runtime.  The GPU is configured by the first transform, for the
+// number of signals transformed, which must then stay the same.
+func (f *FFT) GPU(dir string) *FFT {
+	return f.gpu(goslrun.New("slfft"), dir)
+}
+
+// GPURuntime is GPU on the given runtime, e.g., an slcpu.Runtime with
+// the Go version of the slfft kernel registered.
+func (f *FFT) GPURuntime(dir string, rt slgpu.Runtime) *FFT {
+	return f.gpu(goslrun.NewRuntime("slfft", rt), dir)
+}
+
+func (f *FFT) gpu(run *goslrun.Run, dir string) *FFT {
+	f.run = run
+	f.run.Dir = dir
+	f.run.Threads = Threads
+	return f
+}
+
+// Forward computes the forward FFT of each signal in data, in place,
+// where the length of data must be a multiple of the size N.
+func (f *FFT) Forward(data []slcomplex.Complex) error {
+	f.Params.Inverse = 0
+	return f.transform(data)
+}
+
+// Inverse computes the inverse FFT of each signal in data, in place,
+// scaled by 1/N, so that it inverts Forward.
+func (f *FFT) Inverse(data []slcomplex.Complex) error {
+	f.Params.Inverse = 1
+	return f.transform(data)
+}
+
+// Release releases the GPU resources, if any
+func (f *FFT) Release() {
+	if f.run != nil {
+		f.run.Release()
+	}
+}
+
+func (f *FFT) transform(data []slcomplex.Complex) error {
+	n := int(f.Params.N)
+	if len(data)%n != 0 {
+		return fmt.Errorf("slfft: number of values: %d is not a multiple of the size: %d", len(data), n)
+	}
+	f.Params.NSignals = uint32(len(data) / n)
+	if f.run == nil {
+		cpuMu.Lock()
+		defer cpuMu.Unlock()
+		for si := range f.Params.NSignals {
+			TransformCPU(&f.Params, data, si)
+		}
+		return nil
+	}
+	if f.data == nil {
+		f.nData = len(data)
+		f.data = make([]slcomplex.Complex, len(data))
+		f.run.Buffer("FFTParams", &f.Params).Buffer("Data", f.data)
+	} else if len(data) != f.nData {
+		return fmt.Errorf("slfft: number of values on the GPU: %d must stay: %d", len(data), f.nData)
+	}
+	copy(f.data, data)
+	k := f.run.Kernel("slfft.spv")
+	if err := f.run.Config(); err != nil {
+		return err
+	}
+	if err := f.run.Upload("FFTParams"); err != nil {
+		return err
+	}
+	if err := f.run.Upload("Data"); err != nil {
+		return err
+	}
+	if err := k.Dispatch3(int(f.Params.NSignals), 1, 1); err != nil {
+		return err
+	}
+	return f.run.Read("Data", data)
+}
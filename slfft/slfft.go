@@ -0,0 +1,151 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slfft
+
+import (
+	"cogentcore.org/core/math32"
+	"github.com/emer/gosl/v2/slcomplex"
+)
+
+// This is the code shared between the CPU and the slfft kernel, which
+// gosl generates when given the slfft package path, e.g.:
+//
+//	gosl github.com/emer/gosl/v2/slfft
+//
+// Each workgroup transforms one signal of N complex values, in place,
+// using a radix-2 decimation-in-time FFT in groupshared memory.
+
+//gosl: hlsl slfft
+// #include "slcomplex.hlsl"
+//gosl: end slfft
+
+//gosl: start slfft
+
+// Threads is the number of threads per workgroup, each of which
+// processes every Threads'th value or butterfly of a signal.
+// Must match the numthreads of the kernel, and be a power of 2.
+const Threads = 256
+
+// MaxN is the maximum number of values in a signal, which is the
+// size of the groupshared memory: 16KB of float2 values.
+const MaxN = 2048
+
+// note: groupshared variables must be declared before any
+// struct methods that use them.
+
+// gosl: groupshared
+var Shared [MaxN]slcomplex.Complex
+
+// BitReverse returns the low bits of i in reverse order
+func BitReverse(i, bits uint32) uint32 {
+	r := uint32(0)
+	for b := uint32(0); b < bits; b++ {
+		r = (r << 1) | (i & 1)
+		i >>= 1
+	}
+	return r
+}
+
+// Params has the parameters of an FFT
+type Params struct {
+
+	// number of complex values in each signal: a power of 2 <= MaxN
+	N uint32
+
+	// log2 of N: the number of butterfly stages
+	Log2N uint32
+
+	// 1 for the inverse transform, which is scaled by 1/N, else 0
+	Inverse uint32
+
+	// number of signals, each transformed by one workgroup
+	NSignals uint32
+}
+
+// Load loads value i of the signal into its bit-reversed position
+// in the shared memory, which is the first phase.
+func (ps *Params) Load(i uint32, v slcomplex.Complex) {
+	Shared[BitReverse(i, ps.Log2N)] = v
+}
+
+// Butterfly computes butterfly j < N/2 of the stage with the given
+// stride (1, 2, 4, ... N/2), combining the values at a and
+// a + stride with a twiddle factor.
+func (ps *Params) Butterfly(j, stride uint32) {
+	k := j % stride
+	a := (j/stride)*2*stride + k
+	b := a + stride
+	th := -math32.Pi * float32(k) / float32(stride)
+	if ps.Inverse == 1 {
+		th = -th
+	}
+	t := slcomplex.Mul(slcomplex.Expi(th), Shared[b])
+	u := Shared[a]
+	Shared[a] = slcomplex.Add(u, t)
+	Shared[b] = slcomplex.Sub(u, t)
+}
+
+// Store returns value i of the transformed signal, scaled by 1/N
+// for the inverse transform, which is the last phase.
+func (ps *Params) Store(i uint32) slcomplex.Complex {
+	if ps.Inverse == 1 {
+		return slcomplex.Scale(Shared[i], 1.0/float32(ps.N))
+	}
+	return Shared[i]
+}
+
+//gosl: end slfft
+
+// TransformCPU runs the same computation as the slfft kernel for
+// signal si of data, running each phase for all threads in turn,
+// which is equivalent to the barriers between phases on the GPU.
+// Because the shared values are global on the CPU, signals must be
+// transformed sequentially.
+func TransformCPU(ps *Params, data []slcomplex.Complex, si uint32) {
+	off := si * ps.N
+	for i := range ps.N {
+		ps.Load(i, data[off+i])
+	}
+	for stride := uint32(1); stride < ps.N; stride <<= 1 {
+		for j := range ps.N / 2 {
+			ps.Butterfly(j, stride)
+		}
+	}
+	for i := range ps.N {
+		data[off+i] = ps.Store(i)
+	}
+}
+
+//gosl: hlsl slfft
+/*
+// // note: binding is var, set
+[[vk::binding(0, 0)]] RWStructuredBuffer<Params> FFTParams;
+[[vk::binding(0, 1)]] RWStructuredBuffer<float2> Data;
+
+// // one workgroup per signal, with barriers between each phase.
+// // numthreads must match Threads.
+[numthreads(256, 1, 1)]
+
+void main(uint3 gid : SV_GroupID, uint3 lid : SV_GroupThreadID) {
+	uint si = gid.x;
+	uint ti = lid.x;
+	Params ps = FFTParams[0];
+	uint off = si * ps.N;
+	for (uint i = ti; i < ps.N; i += Threads) {
+		ps.Load(i, Data[off + i]);
+	}
+	GroupMemoryBarrierWithGroupSync();
+	for (uint stride = 1; stride < ps.N; stride <<= 1) {
+		for (uint j = ti; j < ps.N / 2; j += Threads) {
+			ps.Butterfly(j, stride);
+		}
+		GroupMemoryBarrierWithGroupSync();
+	}
+	for (uint k = ti; k < ps.N; k += Threads) {
+		Data[off + k] = ps.Store(k);
+	}
+}
+*/
+//gosl: end slfft
@@ -0,0 +1,99 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slfft
+
+import (
+	"math"
+	"testing"
+
+	"github.com/emer/gosl/v2/slcomplex"
+	"github.com/emer/gosl/v2/slgpu/slcpu"
+)
+
+// dft is the direct O(N^2) discrete Fourier transform of one signal
+func dft(x []slcomplex.Complex) []slcomplex.Complex {
+	n := len(x)
+	y := make([]slcomplex.Complex, n)
+	for k := range n {
+		var re, im float64
+		for j := range n {
+			th := -2 * math.Pi * float64(j*k) / float64(n)
+			c, s := math.Cos(th), math.Sin(th)
+			re += float64(x[j].X)*c - float64(x[j].Y)*s
+			im += float64(x[j].X)*s + float64(x[j].Y)*c
+		}
+		y[k] = slcomplex.New(float32(re), float32(im))
+	}
+	return y
+}
+
+func testSignals(n, ns int) []slcomplex.Complex {
+	data := make([]slcomplex.Complex, n*ns)
+	for i := range data {
+		data[i] = slcomplex.New(float32(math.Sin(float64(i)*0.7)), float32(i%5)*0.1)
+	}
+	return data
+}
+
+func checkFFT(t *testing.T, f *FFT, n, ns int) {
+	t.Helper()
+	data := testSignals(n, ns)
+	orig := append([]slcomplex.Complex(nil), data...)
+	if err := f.Forward(data); err != nil {
+		t.Fatal(err)
+	}
+	for si := range ns {
+		want := dft(orig[si*n : (si+1)*n])
+		for k := range n {
+			if slcomplex.Abs(slcomplex.Sub(data[si*n+k], want[k])) > 1e-3 {
+				t.Fatalf("signal %d value %d: got %v, want %v", si, k, data[si*n+k], want[k])
+			}
+		}
+	}
+	if err := f.Inverse(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		if slcomplex.Abs(slcomplex.Sub(data[i], orig[i])) > 1e-4 {
+			t.Fatalf("inverse value %d: got %v, want %v", i, data[i], orig[i])
+		}
+	}
+}
+
+func TestFFT(t *testing.T) {
+	f, err := New(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFFT(t, f, 64, 3)
+	if _, err := New(48); err == nil {
+		t.Error("expected error for size that is not a power of 2")
+	}
+	if err := f.Forward(make([]slcomplex.Complex, 100)); err == nil {
+		t.Error("expected error for data that is not a multiple of the size")
+	}
+}
+
+// TestFFTGPU tests the GPU path, on the slcpu runtime with the
+// same code as the kernel.
+func TestFFTGPU(t *testing.T) {
+	rt := slcpu.New()
+	rt.RegisterKernel("slfft", func(bufs *slcpu.Buffers, groups [3]int) {
+		ps := slcpu.Slice[Params](bufs, "FFTParams")
+		data := slcpu.Slice[slcomplex.Complex](bufs, "Data")
+		for si := range uint32(groups[0]) {
+			TransformCPU(&ps[0], data, si)
+		}
+		slcpu.Store(bufs, "Data", data)
+	})
+
+	f, _ := New(32)
+	f.GPURuntime("shaders", rt)
+	defer f.Release()
+	checkFFT(t, f, 32, 4)
+	if err := f.Forward(make([]slcomplex.Complex, 64)); err == nil {
+		t.Error("expected error for a different number of values on the GPU")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slfixed
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// These are Go versions of the same fixed-point functions available in
+// slfixed.hlsl, for deterministic accumulation using int32 atomics,
+// because float atomics are unsupported or non-deterministic on the GPU.
+
+const (
+	// FracBits is the number of fractional bits in the Q15.16 format
+	FracBits = 16
+
+	// Scale is the float value of 1 in fixed point: 1 << FracBits
+	Scale = float32(1 << FracBits)
+
+	// MaxFloat is the largest float magnitude that can be represented
+	MaxFloat = float32(math.MaxInt32) / Scale
+)
+
+// Debug enables overflow detection on the CPU, counting overflows in
+// conversion and addition, which can be checked with [Overflows].
+var Debug = false
+
+var overflows atomic.Int64
+
+// Overflows returns the number of overflows detected since the last
+// [ResetOverflows], when [Debug] is enabled.
+func Overflows() int64 {
+	return overflows.Load()
+}
+
+// ResetOverflows resets the overflow count to zero
+func ResetOverflows() {
+	overflows.Store(0)
+}
+
+// FromFloat converts a float32 value into Q15.16 fixed point,
+// rounding to nearest, and clamping out-of-range values.
+func FromFloat(v float32) int32 {
+	if v >= MaxFloat {
+		if Debug {
+			overflows.Add(1)
+		}
+		return math.MaxInt32
+	}
+	if v <= -MaxFloat {
+		if Debug {
+			overflows.Add(1)
+		}
+		return -math.MaxInt32
+	}
+	if v < 0 {
+		return int32(v*Scale - 0.5)
+	}
+	return int32(v*Scale + 0.5)
+}
+
+// ToFloat converts a Q15.16 fixed point value into a float32
+func ToFloat(v int32) float32 {
+	return float32(v) / Scale
+}
+
+// AddOverflows returns true if adding a and b overflows int32,
+// which is available on both CPU and GPU for detecting overflows.
+func AddOverflows(a, b int32) bool {
+	s := a + b
+	return (a >= 0 && b >= 0 && s < 0) || (a < 0 && b < 0 && s >= 0)
+}
+
+// Add returns the sum of two fixed point values, counting
+// overflows if [Debug] is enabled.
+func Add(a, b int32) int32 {
+	if Debug && AddOverflows(a, b) {
+		overflows.Add(1)
+	}
+	return a + b
+}
+
+// AtomicAdd atomically adds v to the fixed point value at dest,
+// using InterlockedAdd on the GPU. Because the sum of integers is
+// independent of order, the result is deterministic, unlike float
+// atomics. On the GPU, dest must be a buffer element or groupshared
+// variable, not a function argument (which is a local copy).
+// Overflows are counted if [Debug] is enabled.
+func AtomicAdd(dest *int32, v int32) {
+	n := atomic.AddInt32(dest, v)
+	if Debug && AddOverflows(n-v, v) {
+		overflows.Add(1)
+	}
+}
+
+// AtomicAddFloat converts v to fixed point and atomically adds it to
+// the fixed point value at dest, as in [AtomicAdd].
+func AtomicAddFloat(dest *int32, v float32) {
+	AtomicAdd(dest, FromFloat(v))
+}
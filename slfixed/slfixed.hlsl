@@ -0,0 +1,60 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Original file is in Go package: github.com/emer/gosl/v2/slfixed
+// See README.md there for documentation.
+
+// These fixed-point functions support deterministic accumulation
+// using int32 atomics, with equivalent Go versions available in slfixed.go.
+// Values are in the Q15.16 format: 16 fractional bits.
+
+#ifndef __SLFIXED_HLSL__
+#define __SLFIXED_HLSL__
+
+static const int FixedFracBits = 16;
+static const float FixedScale = 65536.0;
+static const float FixedMaxFloat = 32768.0;
+
+// FixedFromFloat converts a float value into Q15.16 fixed point,
+// rounding to nearest, and clamping out-of-range values.
+int FixedFromFloat(float v) {
+	if (v >= FixedMaxFloat) {
+		return 2147483647;
+	}
+	if (v <= -FixedMaxFloat) {
+		return -2147483647;
+	}
+	if (v < 0) {
+		return int(v * FixedScale - 0.5);
+	}
+	return int(v * FixedScale + 0.5);
+}
+
+// FixedToFloat converts a Q15.16 fixed point value into a float
+float FixedToFloat(int v) {
+	return float(v) / FixedScale;
+}
+
+// FixedAddOverflows returns true if adding a and b overflows int32
+bool FixedAddOverflows(int a, int b) {
+	int s = a + b;
+	return (a >= 0 && b >= 0 && s < 0) || (a < 0 && b < 0 && s >= 0);
+}
+
+// FixedAdd returns the sum of two fixed point values
+int FixedAdd(int a, int b) {
+	return a + b;
+}
+
+// FixedAtomicAdd atomically adds v to the fixed point value at dest,
+// which must be a buffer element or groupshared variable.
+// This is a macro because atomics cannot operate on inout function args,
+// which are local copies.
+#define FixedAtomicAdd(dest, v) InterlockedAdd(dest, v)
+
+// FixedAtomicAddFloat converts v to fixed point and atomically adds
+// it to the fixed point value at dest, as in FixedAtomicAdd.
+#define FixedAtomicAddFloat(dest, v) InterlockedAdd(dest, FixedFromFloat(v))
+
+#endif // __SLFIXED_HLSL__
@@ -0,0 +1,49 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slfixed
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	for _, v := range []float32{0, 1, -1, 0.5, -0.25, 1.0 / 65536, 1000.125} {
+		if r := ToFloat(FromFloat(v)); r != v {
+			t.Errorf("round trip of %g gave: %g", v, r)
+		}
+	}
+	Debug = true
+	ResetOverflows()
+	if FromFloat(1e6) != math.MaxInt32 || FromFloat(-1e6) != -math.MaxInt32 {
+		t.Errorf("out of range values not clamped")
+	}
+	Add(math.MaxInt32, 1)
+	if Overflows() != 3 {
+		t.Errorf("expected 3 overflows, got: %d", Overflows())
+	}
+	Debug = false
+}
+
+func TestAtomicAdd(t *testing.T) {
+	var sum int32
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			AtomicAddFloat(&sum, float32(i)*0.01)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	exp := int32(0)
+	for i := range 100 {
+		exp += FromFloat(float32(i) * 0.01)
+	}
+	if sum != exp {
+		t.Errorf("atomic sum: %d != expected: %d", sum, exp)
+	}
+}
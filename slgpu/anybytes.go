@@ -0,0 +1,34 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slgpu
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AnyShape returns the element size and number of elements of the given
+// slice of values, or pointer to a single value, which can be used as
+// the data of a buffer with AnyBytes.
+func AnyShape(v any) (elemSize, n int) {
+	rv, n := anyValues(v)
+	return int(rv.Type().Elem().Size()), n
+}
+
+// anyValues returns the reflect value of the given slice of values,
+// or pointer to a value, and the number of values.
+func anyValues(v any) (reflect.Value, int) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		return rv, rv.Len()
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return rv, 0
+		}
+		return rv, 1
+	}
+	panic(fmt.Sprintf("slgpu: %T is not a slice or pointer for a GPU buffer", v))
+}
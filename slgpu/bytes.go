@@ -0,0 +1,71 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !js && !slsafe
+
+package slgpu
+
+import "unsafe"
+
+// Bytes returns the bytes of the given slice of values, e.g., structs
+// that match the buffer element type, for Upload and Readback.
+// This returns a view of the same memory, without copying.
+func Bytes[T any](s []T) []byte {
+	var v T
+	if len(s) == 0 || unsafe.Sizeof(v) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*int(unsafe.Sizeof(v)))
+}
+
+// Values returns the values of type T in the given bytes.
+// This returns a view of the same memory, without copying.
+func Values[T any](b []byte) []T {
+	var v T
+	sz := int(unsafe.Sizeof(v))
+	if len(b) < sz || sz == 0 {
+		return nil
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(&b[0])), len(b)/sz)
+}
+
+// CopyToBytes copies the given values to the given bytes,
+// e.g., from Bytes.  It does nothing if they are the same memory.
+func CopyToBytes[T any](b []byte, s []T) {
+	if sb := Bytes(s); !sameMemory(b, sb) {
+		copy(b, sb)
+	}
+}
+
+// CopyFromBytes copies the given bytes, e.g., after Readback,
+// to the given values.  It does nothing if they are the same memory.
+func CopyFromBytes[T any](s []T, b []byte) {
+	if sb := Bytes(s); !sameMemory(b, sb) {
+		copy(sb, b)
+	}
+}
+
+// AnyBytes returns the bytes of the given slice of values, or pointer to
+// a value, as in Bytes, for when the type is only known at run time.
+// This returns a view of the same memory, without copying.
+func AnyBytes(v any) []byte {
+	rv, n := anyValues(v)
+	if sz := int(rv.Type().Elem().Size()); n > 0 && sz > 0 {
+		return unsafe.Slice((*byte)(rv.UnsafePointer()), n*sz)
+	}
+	return nil
+}
+
+// CopyFromAnyBytes copies the given bytes, e.g., after Readback, to the
+// given slice of values, or pointer to a value, as in CopyFromBytes.
+// It does nothing if they are the same memory.
+func CopyFromAnyBytes(v any, b []byte) {
+	if sb := AnyBytes(v); !sameMemory(b, sb) {
+		copy(sb, b)
+	}
+}
+
+func sameMemory(a, b []byte) bool {
+	return len(a) > 0 && len(b) > 0 && &a[0] == &b[0]
+}
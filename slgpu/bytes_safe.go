@@ -0,0 +1,175 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build js || slsafe
+
+package slgpu
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Bytes returns the bytes of the given slice of values, e.g., structs
+// that match the buffer element type, for Upload and Readback.
+// This returns an encoded copy, in the memory layout of the values.
+func Bytes[T any](s []T) []byte {
+	b := make([]byte, len(s)*int(reflect.TypeFor[T]().Size()))
+	CopyToBytes(b, s)
+	return b
+}
+
+// Values returns the values of type T in the given bytes.
+// This returns a decoded copy.
+func Values[T any](b []byte) []T {
+	sz := int(reflect.TypeFor[T]().Size())
+	if sz == 0 {
+		return nil
+	}
+	s := make([]T, len(b)/sz)
+	CopyFromBytes(s, b)
+	return s
+}
+
+// CopyToBytes encodes the given values to the given bytes,
+// e.g., from Bytes, in the memory layout of the values.
+// Unexported fields are padding, which is left as is.
+func CopyToBytes[T any](b []byte, s []T) {
+	sz := int(reflect.TypeFor[T]().Size())
+	for i := range s {
+		if (i+1)*sz > len(b) {
+			break
+		}
+		encodeValue(b[i*sz:(i+1)*sz], reflect.ValueOf(&s[i]).Elem())
+	}
+}
+
+// CopyFromBytes decodes the given bytes, e.g., after Readback,
+// to the given values, in their memory layout.
+// Unexported fields are padding, which is left as is.
+func CopyFromBytes[T any](s []T, b []byte) {
+	sz := int(reflect.TypeFor[T]().Size())
+	for i := range s {
+		if (i+1)*sz > len(b) {
+			break
+		}
+		decodeValue(b[i*sz:(i+1)*sz], reflect.ValueOf(&s[i]).Elem())
+	}
+}
+
+// AnyBytes returns the bytes of the given slice of values, or pointer to
+// a value, as in Bytes, for when the type is only known at run time.
+// This returns an encoded copy, in the memory layout of the values.
+func AnyBytes(v any) []byte {
+	rv, n := anyValues(v)
+	sz := int(rv.Type().Elem().Size())
+	b := make([]byte, n*sz)
+	for i := range n {
+		encodeValue(b[i*sz:(i+1)*sz], anyIndex(rv, i))
+	}
+	return b
+}
+
+// CopyFromAnyBytes decodes the given bytes, e.g., after Readback, to the
+// given slice of values, or pointer to a value, as in CopyFromBytes.
+func CopyFromAnyBytes(v any, b []byte) {
+	rv, n := anyValues(v)
+	sz := int(rv.Type().Elem().Size())
+	for i := range n {
+		if (i+1)*sz > len(b) {
+			break
+		}
+		decodeValue(b[i*sz:(i+1)*sz], anyIndex(rv, i))
+	}
+}
+
+// anyIndex returns the value at the given index of the
+// given slice, or the value pointed to by the given pointer.
+func anyIndex(rv reflect.Value, i int) reflect.Value {
+	if rv.Kind() == reflect.Pointer {
+		return rv.Elem()
+	}
+	return rv.Index(i)
+}
+
+var le = binary.LittleEndian
+
+func encodeValue(b []byte, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		b[0] = 0
+		if v.Bool() {
+			b[0] = 1
+		}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		putUint(b, uint64(v.Int()), v.Type().Size())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint, reflect.Uintptr:
+		putUint(b, v.Uint(), v.Type().Size())
+	case reflect.Float32:
+		le.PutUint32(b, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		le.PutUint64(b, math.Float64bits(v.Float()))
+	case reflect.Array:
+		esz := v.Type().Elem().Size()
+		for i := range v.Len() {
+			encodeValue(b[uintptr(i)*esz:], v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			if f := t.Field(i); f.IsExported() {
+				encodeValue(b[f.Offset:], v.Field(i))
+			}
+		}
+	default:
+		panic(fmt.Sprintf("slgpu: type %s cannot be used in a GPU buffer", v.Type()))
+	}
+}
+
+func decodeValue(b []byte, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(b[0] != 0)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		sz := v.Type().Size()
+		u := getUint(b, sz)
+		v.SetInt(int64(u<<(64-8*sz)) >> (64 - 8*sz)) // sign extend
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint, reflect.Uintptr:
+		v.SetUint(getUint(b, v.Type().Size()))
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(le.Uint32(b))))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(le.Uint64(b)))
+	case reflect.Array:
+		esz := v.Type().Elem().Size()
+		for i := range v.Len() {
+			decodeValue(b[uintptr(i)*esz:], v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			if f := t.Field(i); f.IsExported() {
+				decodeValue(b[f.Offset:], v.Field(i))
+			}
+		}
+	default:
+		panic(fmt.Sprintf("slgpu: type %s cannot be used in a GPU buffer", v.Type()))
+	}
+}
+
+func putUint(b []byte, u uint64, sz uintptr) {
+	for i := range sz {
+		b[i] = byte(u >> (8 * i))
+	}
+}
+
+func getUint(b []byte, sz uintptr) uint64 {
+	var u uint64
+	for i := range sz {
+		u |= uint64(b[i]) << (8 * i)
+	}
+	return u
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slgpu
+
+// DefaultThreads is the number of threads per workgroup recommended by
+// Device.Threads when the subgroup size of the device is unknown.
+var DefaultThreads = 64
+
+// VendorSubgroupSizes are the subgroup sizes of the GPU vendors, by PCI
+// vendor ID, for runtimes that cannot query the subgroup size of the
+// device: 64 for AMD (wavefronts), and 32 for NVIDIA (warps) and Apple.
+var VendorSubgroupSizes = map[uint32]int{
+	0x1002: 64, // AMD
+	0x10DE: 32, // NVIDIA
+	0x106B: 32, // Apple
+}
+
+// Device has the properties of the GPU device of a Runtime that
+// determine the recommended number of threads per workgroup of
+// the kernels (see Threads), which differs across vendors,
+// e.g., 64 on AMD vs. 32 on NVIDIA and Apple M1.
+type Device struct {
+
+	// name of the device, e.g., for an sltune.Cache
+	Name string
+
+	// PCI vendor ID of the device, e.g., 0x10DE for NVIDIA
+	VendorID uint32
+
+	// number of threads in a subgroup (warp or wavefront) of the
+	// device, or 0 if unknown
+	SubgroupSize int
+
+	// maximum number of threads in a 1D workgroup, or 0 if unknown
+	MaxThreads int
+}
+
+// DeviceRuntime is a Runtime that reports the properties of its device.
+type DeviceRuntime interface {
+	Runtime
+
+	// Device returns the properties of the GPU device.
+	Device() Device
+}
+
+// DeviceOf returns the properties of the device of the given Runtime,
+// which are all unknown (zero) if it is not a DeviceRuntime, e.g., for
+// the cpu runtime.
+func DeviceOf(rt Runtime) Device {
+	if dr, ok := rt.(DeviceRuntime); ok {
+		return dr.Device()
+	}
+	return Device{}
+}
+
+// Threads returns the recommended number of threads per workgroup on
+// the device, which is the subgroup size, so that each workgroup is one
+// full subgroup, or DefaultThreads if it is unknown, up to MaxThreads.
+// If workgroup sizes are given, e.g., those of the -autotune variants of
+// a kernel, it returns the one to use: the smallest multiple of the
+// recommended size, or else the closest to it, that is not more than
+// MaxThreads.  sltune benchmarks the variants instead, to find the
+// fastest size, but this is a good default without benchmarking.
+func (d *Device) Threads(sizes ...int) int {
+	th := d.SubgroupSize
+	if th <= 0 {
+		th = DefaultThreads
+	}
+	if d.MaxThreads > 0 {
+		th = min(th, d.MaxThreads)
+	}
+	if len(sizes) == 0 {
+		return th
+	}
+	best, bestDist, bestMult := 0, 0, false
+	for _, sz := range sizes {
+		if sz <= 0 || (d.MaxThreads > 0 && sz > d.MaxThreads) {
+			continue
+		}
+		mult := sz%th == 0
+		dist := max(sz-th, th-sz)
+		if best == 0 || (mult && !bestMult) || (mult == bestMult && dist < bestDist) {
+			best, bestDist, bestMult = sz, dist, mult
+		}
+	}
+	if best == 0 { // all too large: use the smallest
+		best = sizes[0]
+		for _, sz := range sizes {
+			best = min(best, sz)
+		}
+	}
+	return best
+}
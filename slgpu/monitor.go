@@ -0,0 +1,321 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slgpu
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CounterRuntime is a Runtime that samples performance counters of its
+// device, e.g., with vendor extensions of the GPU API, for a Monitor.
+type CounterRuntime interface {
+	Runtime
+
+	// Counters returns the current values of the device counters, by name,
+	// e.g., CounterUtilization.  It is called from the sampling goroutine
+	// of the Monitor, so it must be safe to call concurrently with the
+	// other methods.
+	Counters() (map[string]float64, error)
+}
+
+// Device counter names with a standard meaning in the Metrics of a Monitor.
+const (
+	// CounterUtilization is the fraction of time that the GPU was busy,
+	// from 0 to 1, which is also exported as the utilization gauge.
+	CounterUtilization = "gpu_utilization"
+
+	// CounterMemoryBandwidth is the device memory bandwidth used by the
+	// kernels, in bytes per second.
+	CounterMemoryBandwidth = "memory_bandwidth_bytes"
+)
+
+// Metrics are the performance metrics of a Runtime sampled by a Monitor,
+// over the interval since the previous sample, and in total since it
+// was created.
+type Metrics struct {
+
+	// time of the sample
+	Time time.Time
+
+	// duration of the interval since the previous sample
+	Interval time.Duration
+
+	// number of kernel dispatches in the interval
+	Dispatches int64
+
+	// number of bytes uploaded to the GPU in the interval
+	UploadBytes int64
+
+	// number of bytes read back from the GPU in the interval
+	ReadbackBytes int64
+
+	// time spent by the host in Wait, Upload and Readback in the interval,
+	// including the copies of the data, not only waiting for the GPU
+	WaitTime time.Duration
+
+	// fraction of the interval spent by the host in Wait, Upload and
+	// Readback, WaitTime / Interval, from 0 to 1, which is not the GPU
+	// utilization: that is only known from the CounterUtilization device
+	// counter of a CounterRuntime
+	HostWaitFraction float64
+
+	// bandwidth of the transfers between the host and the GPU in the
+	// interval, in bytes per second
+	TransferBandwidth float64
+
+	// totals of the counts since the Monitor was created
+	TotalDispatches, TotalUploadBytes, TotalReadbackBytes int64
+
+	// total time spent waiting for the GPU since the Monitor was created
+	TotalWaitTime time.Duration
+
+	// device counters of a CounterRuntime, by name, e.g., CounterMemoryBandwidth
+	Counters map[string]float64
+}
+
+// Sink receives the Metrics sampled by a Monitor, e.g., LogSink.
+type Sink func(ms *Metrics)
+
+// LogSink returns a Sink that logs the Metrics as structured attributes
+// to the given logger, or slog.Default if nil, at the Info level.
+func LogSink(logger *slog.Logger) Sink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(ms *Metrics) {
+		attrs := []any{"interval", ms.Interval, "dispatches", ms.Dispatches, "upload_bytes", ms.UploadBytes, "readback_bytes", ms.ReadbackBytes, "wait", ms.WaitTime, "host_wait_fraction", ms.HostWaitFraction, "transfer_bandwidth", ms.TransferBandwidth}
+		for _, nm := range ms.counterNames() {
+			attrs = append(attrs, nm, ms.Counters[nm])
+		}
+		logger.Info("slgpu: metrics", attrs...)
+	}
+}
+
+// counterNames returns the sorted names of the device counters
+func (ms *Metrics) counterNames() []string {
+	nms := make([]string, 0, len(ms.Counters))
+	for nm := range ms.Counters {
+		nms = append(nms, nm)
+	}
+	sort.Strings(nms)
+	return nms
+}
+
+// WritePrometheus writes the Metrics in the Prometheus text exposition
+// format, with the totals as counters, and the interval rates and device
+// counters as gauges, all prefixed with gosl_gpu_.  The utilization gauge
+// is only written with the CounterUtilization device counter.
+func (ms *Metrics) WritePrometheus(w io.Writer) error {
+	var err error
+	metric := func(name, typ, help string, val float64) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, "# HELP gosl_gpu_%s %s\n# TYPE gosl_gpu_%s %s\ngosl_gpu_%s %s\n", name, help, name, typ, name, strconv.FormatFloat(val, 'g', -1, 64))
+	}
+	metric("dispatches_total", "counter", "Number of kernel dispatches.", float64(ms.TotalDispatches))
+	metric("upload_bytes_total", "counter", "Number of bytes uploaded to the GPU.", float64(ms.TotalUploadBytes))
+	metric("readback_bytes_total", "counter", "Number of bytes read back from the GPU.", float64(ms.TotalReadbackBytes))
+	metric("wait_seconds_total", "counter", "Time spent by the host in Wait, Upload and Readback.", ms.TotalWaitTime.Seconds())
+	metric("host_wait_fraction", "gauge", "Fraction of time spent by the host in Wait, Upload and Readback.", ms.HostWaitFraction)
+	if u, ok := ms.Counters[CounterUtilization]; ok {
+		metric("utilization", "gauge", "Fraction of time that the GPU was busy, from the device counter.", u)
+	}
+	metric("transfer_bandwidth_bytes", "gauge", "Bandwidth of the transfers between the host and the GPU, in bytes per second.", ms.TransferBandwidth)
+	if err != nil || len(ms.Counters) == 0 {
+		return err
+	}
+	if _, err := io.WriteString(w, "# HELP gosl_gpu_counter Device counters.\n# TYPE gosl_gpu_counter gauge\n"); err != nil {
+		return err
+	}
+	for _, nm := range ms.counterNames() {
+		if _, err := fmt.Fprintf(w, "gosl_gpu_counter{name=%q} %s\n", nm, strconv.FormatFloat(ms.Counters[nm], 'g', -1, 64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Monitor is a Runtime that counts the dispatches and transfers of the
+// Runtime that it wraps, and the time spent in Wait, Upload and Readback, and
+// samples them periodically in a goroutine, along with the device
+// counters of a CounterRuntime, passing the Metrics to its Sinks, e.g.,
+// to log them during long-running simulations:
+//
+//	mon := slgpu.NewMonitor(rt, time.Minute, slgpu.LogSink(nil))
+//	mon.Start()
+//	rt = mon // use the Monitor as the Runtime
+//
+// The latest Metrics are served in the Prometheus text format by its
+// ServeHTTP method, e.g., http.Handle("/metrics", mon), or can be
+// exported with Latest, e.g., from the functions of Prometheus gauges.
+type Monitor struct {
+	Runtime
+
+	// interval between samples, which is a minute if not > 0
+	Interval time.Duration
+
+	// sinks that receive the sampled Metrics
+	Sinks []Sink
+
+	mu      sync.Mutex
+	last    time.Time
+	cur     Metrics // counts of the current interval, and totals
+	latest  Metrics
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewMonitor returns a new Monitor of the given Runtime, sampling at the
+// given interval once started, passing the Metrics to the given sinks.
+func NewMonitor(rt Runtime, interval time.Duration, sinks ...Sink) *Monitor {
+	return &Monitor{Runtime: rt, Interval: interval, Sinks: sinks, last: time.Now()}
+}
+
+// Start starts the sampling goroutine, if not already started.
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop, m.stopped = make(chan struct{}), make(chan struct{})
+	go m.run(m.stop, m.stopped)
+}
+
+// Stop stops the sampling goroutine, if started, waiting until it exits.
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	stop, stopped := m.stop, m.stopped
+	m.stop, m.stopped = nil, nil
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// run samples the metrics at each interval, until stopped.
+func (m *Monitor) run(stop, stopped chan struct{}) {
+	defer close(stopped)
+	iv := m.Interval
+	if iv <= 0 {
+		iv = time.Minute
+	}
+	tick := time.NewTicker(iv)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			m.Sample()
+		}
+	}
+}
+
+// Sample samples the Metrics of the interval since the previous sample
+// now, and passes them to the Sinks, returning them.  It is called
+// periodically once started, and can also be called directly.
+func (m *Monitor) Sample() Metrics {
+	var cnts map[string]float64
+	if cr, ok := m.Runtime.(CounterRuntime); ok {
+		if c, err := cr.Counters(); err == nil {
+			cnts = c
+		}
+	}
+	m.mu.Lock()
+	now := time.Now()
+	ms := m.cur
+	ms.Time, ms.Interval, ms.Counters = now, now.Sub(m.last), cnts
+	if secs := ms.Interval.Seconds(); secs > 0 {
+		ms.HostWaitFraction = min(ms.WaitTime.Seconds()/secs, 1)
+		ms.TransferBandwidth = float64(ms.UploadBytes+ms.ReadbackBytes) / secs
+	}
+	m.last = now
+	m.cur = Metrics{TotalDispatches: ms.TotalDispatches, TotalUploadBytes: ms.TotalUploadBytes, TotalReadbackBytes: ms.TotalReadbackBytes, TotalWaitTime: ms.TotalWaitTime}
+	m.latest = ms
+	sinks := m.Sinks
+	m.mu.Unlock()
+	for _, s := range sinks {
+		s(&ms)
+	}
+	return ms
+}
+
+// Latest returns the latest sampled Metrics, which are zero before the
+// first sample.
+func (m *Monitor) Latest() Metrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.latest
+}
+
+// ServeHTTP serves the latest Metrics in the Prometheus text format.
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ms := m.Latest()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	ms.WritePrometheus(w)
+}
+
+// count adds the given counts to the current interval and totals.
+func (m *Monitor) count(dispatches, upload, readback int64, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &m.cur
+	c.Dispatches += dispatches
+	c.TotalDispatches += dispatches
+	c.UploadBytes += upload
+	c.TotalUploadBytes += upload
+	c.ReadbackBytes += readback
+	c.TotalReadbackBytes += readback
+	c.WaitTime += wait
+	c.TotalWaitTime += wait
+}
+
+// Device returns the properties of the device of the wrapped Runtime.
+func (m *Monitor) Device() Device {
+	return DeviceOf(m.Runtime)
+}
+
+func (m *Monitor) Upload(name string, data []byte) error {
+	st := time.Now()
+	err := m.Runtime.Upload(name, data)
+	m.count(0, int64(len(data)), 0, time.Since(st))
+	return err
+}
+
+func (m *Monitor) Dispatch(kernel string, nx, ny, nz int) error {
+	err := m.Runtime.Dispatch(kernel, nx, ny, nz)
+	m.count(1, 0, 0, 0)
+	return err
+}
+
+func (m *Monitor) Wait() error {
+	st := time.Now()
+	err := m.Runtime.Wait()
+	m.count(0, 0, 0, time.Since(st))
+	return err
+}
+
+func (m *Monitor) Readback(name string, data []byte) error {
+	st := time.Now()
+	err := m.Runtime.Readback(name, data)
+	m.count(0, 0, int64(len(data)), time.Since(st))
+	return err
+}
+
+// Release stops the sampling goroutine, and releases the wrapped Runtime.
+func (m *Monitor) Release() {
+	m.Stop()
+	m.Runtime.Release()
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slgpu
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRuntime is a Runtime that does nothing, with device counters
+type testRuntime struct {
+	released bool
+}
+
+func (rt *testRuntime) CreateBuffer(name string, set, binding, elemSize, n int) error { return nil }
+func (rt *testRuntime) AddKernel(name, file string) error                             { return nil }
+func (rt *testRuntime) Config() error                                                 { return nil }
+func (rt *testRuntime) Upload(name string, data []byte) error                         { return nil }
+func (rt *testRuntime) Dispatch(kernel string, nx, ny, nz int) error                  { return nil }
+func (rt *testRuntime) Barrier() error                                                { return nil }
+func (rt *testRuntime) Readback(name string, data []byte) error                       { return nil }
+func (rt *testRuntime) Release()                                                      { rt.released = true }
+
+func (rt *testRuntime) Wait() error {
+	time.Sleep(time.Millisecond)
+	return nil
+}
+
+func (rt *testRuntime) Counters() (map[string]float64, error) {
+	return map[string]float64{CounterMemoryBandwidth: 1e9}, nil
+}
+
+func TestMonitor(t *testing.T) {
+	trt := &testRuntime{}
+	var sampled []Metrics
+	mon := NewMonitor(trt, time.Hour, func(ms *Metrics) { sampled = append(sampled, *ms) })
+	var rt Runtime = mon
+	rt.Upload("Data", make([]byte, 64))
+	rt.Dispatch("basic", 1, 1, 1)
+	rt.Dispatch("basic", 1, 1, 1)
+	rt.Readback("Data", make([]byte, 32))
+	rt.Wait()
+	ms := mon.Sample()
+	if ms.Dispatches != 2 || ms.UploadBytes != 64 || ms.ReadbackBytes != 32 || ms.WaitTime < time.Millisecond {
+		t.Errorf("wrong counts: %+v", ms)
+	}
+	if ms.HostWaitFraction <= 0 || ms.HostWaitFraction > 1 || ms.TransferBandwidth <= 0 || ms.Counters[CounterMemoryBandwidth] != 1e9 {
+		t.Errorf("wrong rates or counters: %+v", ms)
+	}
+	rt.Dispatch("basic", 1, 1, 1)
+	ms = mon.Sample()
+	if ms.Dispatches != 1 || ms.TotalDispatches != 3 || ms.UploadBytes != 0 || ms.TotalUploadBytes != 64 {
+		t.Errorf("wrong interval or total counts: %+v", ms)
+	}
+	if len(sampled) != 2 || mon.Latest().TotalDispatches != 3 {
+		t.Errorf("sinks got %d samples", len(sampled))
+	}
+
+	var b strings.Builder
+	if err := ms.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# TYPE gosl_gpu_dispatches_total counter\ngosl_gpu_dispatches_total 3\n", "gosl_gpu_readback_bytes_total 32\n", `gosl_gpu_counter{name="memory_bandwidth_bytes"} 1e+09`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "gosl_gpu_utilization") {
+		t.Errorf("utilization without the device counter:\n%s", b.String())
+	}
+	ms.Counters[CounterUtilization] = 0.5
+	b.Reset()
+	ms.WritePrometheus(&b)
+	if !strings.Contains(b.String(), "gosl_gpu_utilization 0.5\n") {
+		t.Errorf("missing utilization of the device counter:\n%s", b.String())
+	}
+
+	mon.Interval = time.Millisecond
+	mon.Start()
+	time.Sleep(20 * time.Millisecond)
+	rt.Release()
+	if !trt.released || len(sampled) < 3 {
+		t.Errorf("sampling goroutine: %d samples, released: %v", len(sampled), trt.released)
+	}
+}
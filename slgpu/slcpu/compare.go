@@ -0,0 +1,267 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slcpu
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+
+	"github.com/emer/gosl/v2/slgpu"
+)
+
+// ElementFunc is the Go version of a 1D kernel for the one thread with
+// the given index, e.g., a //gosl: cpukernel CPU function, which gets the
+// buffers with Slice and stores any it writes with Store, as a KernelFunc.
+type ElementFunc func(bufs *Buffers, idx uint32)
+
+// Element is the Go version of a 1D kernel for one thread, registered
+// with Comparer.RegisterElement.
+type Element struct {
+
+	// number of threads per workgroup of the kernel
+	Threads int
+
+	// names of all of the buffers of the kernel, which are read back
+	// from the GPU before the dispatch for the Func
+	Buffers []string
+
+	// names of the buffers that the kernel writes at the thread index,
+	// e.g., Neurons for Neurons[idx], whose elements at the sampled
+	// thread indexes are compared
+	Compare []string
+
+	// Go version of the kernel for one thread
+	Func ElementFunc
+}
+
+// Drift is the result of a comparison of a GPU dispatch of a kernel
+// with its Go version on the CPU, by a Comparer.
+type Drift struct {
+
+	// name of the kernel
+	Kernel string
+
+	// number of the compared dispatch, from 1, among all of the
+	// dispatches of the Comparer
+	Dispatch int64
+
+	// number of sampled thread indexes
+	Samples int
+
+	// number of compared 32 bit values, e.g., float32 fields
+	Values int
+
+	// number of compared values that differ by more than the Tolerance,
+	// including those that are not normal float32 numbers, e.g., integer
+	// fields, which must be equal
+	Mismatches int
+
+	// maximum absolute difference of the float32 values
+	MaxAbs float64
+
+	// maximum difference of the float32 values relative to the larger
+	// magnitude of the two
+	MaxRel float64
+
+	// buffer and element index of the MaxAbs difference, or of the first
+	// mismatch if it is not a float32 difference
+	Buffer string
+	Index  int
+}
+
+// DriftSink receives the Drift of each comparison of a Comparer,
+// e.g., DriftLogSink.
+type DriftSink func(d *Drift)
+
+// DriftLogSink returns a DriftSink that logs the Drift as structured
+// attributes to the given logger, or slog.Default if nil, at the Info
+// level, or at the Warn level if there are any Mismatches.
+func DriftLogSink(logger *slog.Logger) DriftSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(d *Drift) {
+		lvl := slog.LevelInfo
+		if d.Mismatches > 0 {
+			lvl = slog.LevelWarn
+		}
+		logger.Log(context.Background(), lvl, "slcpu: drift", "kernel", d.Kernel, "dispatch", d.Dispatch, "samples", d.Samples, "values", d.Values, "mismatches", d.Mismatches, "max_abs", d.MaxAbs, "max_rel", d.MaxRel, "buffer", d.Buffer, "index", d.Index)
+	}
+}
+
+// Comparer is a slgpu.Runtime that validates the GPU Runtime that it
+// wraps during long runs, without stopping them: every Every dispatches,
+// the next dispatch of a kernel with a registered Element (see
+// RegisterElement) is also run on the CPU, for Samples random thread
+// indexes, and the values written at those indexes are compared with
+// those of the GPU, passing the Drift to the Sink:
+//
+//	cmp := slcpu.NewComparer(rt, 1000, 64, slcpu.DriftLogSink(nil))
+//	RegisterCompareElements(cmp) // generated by gosl -compare
+//	rt = cmp // use the Comparer as the Runtime
+//
+// The buffers must be created through the Comparer, which records their
+// sizes.  The buffers of the kernel are read back before and after the
+// compared dispatch, and the Element runs on the buffers from before it,
+// for each sampled thread index in turn.  Kernels that read the values
+// written by other threads in the same dispatch can thus differ.
+type Comparer struct {
+	slgpu.Runtime
+
+	// number of dispatches between comparisons, which compares every
+	// dispatch if not > 0
+	Every int
+
+	// number of random thread indexes sampled in each comparison,
+	// which is 1 if not > 0
+	Samples int
+
+	// tolerance of the differences of the float32 values, which are
+	// mismatches if greater than Tolerance * (1 + the larger magnitude)
+	Tolerance float64
+
+	// sink that receives the Drift of each comparison, if any
+	Sink DriftSink
+
+	elements   map[string]*Element
+	specs      map[string]*slgpu.BufferSpec
+	rand       *rand.Rand
+	dispatches int64
+	next       int64
+}
+
+// NewComparer returns a new Comparer of the given Runtime, comparing
+// the given number of samples every given number of dispatches, with a
+// Tolerance of 1e-4, passing the Drift to the given sink.
+func NewComparer(rt slgpu.Runtime, every, samples int, sink DriftSink) *Comparer {
+	return &Comparer{Runtime: rt, Every: every, Samples: samples, Tolerance: 1e-4, Sink: sink, elements: map[string]*Element{}, specs: map[string]*slgpu.BufferSpec{}, rand: rand.New(rand.NewPCG(1, 2)), next: int64(max(every, 1))}
+}
+
+// RegisterElement registers the Go version of the 1D kernel with given
+// name for one thread, e.g., from the gosl_compare.go file generated by
+// gosl -compare, so that its dispatches are compared.
+func (c *Comparer) RegisterElement(name string, el *Element) {
+	c.elements[name] = el
+}
+
+func (c *Comparer) CreateBuffer(name string, set, binding, elemSize, n int) error {
+	c.specs[name] = &slgpu.BufferSpec{Name: name, Set: set, Binding: binding, ElemSize: elemSize, N: n}
+	return c.Runtime.CreateBuffer(name, set, binding, elemSize, n)
+}
+
+func (c *Comparer) Dispatch(kernel string, nx, ny, nz int) error {
+	c.dispatches++
+	if c.dispatches < c.next {
+		return c.Runtime.Dispatch(kernel, nx, ny, nz)
+	}
+	el, ok := c.elements[kernel]
+	if !ok {
+		return c.Runtime.Dispatch(kernel, nx, ny, nz)
+	}
+	c.next = c.dispatches + int64(max(c.Every, 1))
+	d, err := c.compare(kernel, el, nx, ny, nz)
+	if err != nil {
+		return err
+	}
+	if c.Sink != nil {
+		c.Sink(d)
+	}
+	return nil
+}
+
+// readback returns the named buffers read back from the GPU
+func (c *Comparer) readback(names []string) (*Buffers, error) {
+	bufs := &Buffers{specs: map[string]*slgpu.BufferSpec{}, data: map[string][]byte{}}
+	for _, nm := range names {
+		bs, ok := c.specs[nm]
+		if !ok {
+			return nil, fmt.Errorf("slcpu: buffer %s not created", nm)
+		}
+		b := make([]byte, bs.Size())
+		if err := c.Runtime.Readback(nm, b); err != nil {
+			return nil, err
+		}
+		bufs.specs[nm], bufs.data[nm] = bs, b
+	}
+	return bufs, nil
+}
+
+// compare dispatches the given kernel on the GPU, and runs its Element
+// on the CPU for the sampled thread indexes, returning the Drift.
+func (c *Comparer) compare(kernel string, el *Element, nx, ny, nz int) (*Drift, error) {
+	cpu, err := c.readback(el.Buffers)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Runtime.Dispatch(kernel, nx, ny, nz); err != nil {
+		return nil, err
+	}
+	gpu, err := c.readback(el.Compare)
+	if err != nil {
+		return nil, err
+	}
+	n := nx * max(el.Threads, 1)
+	for _, nm := range el.Compare {
+		n = min(n, gpu.specs[nm].N)
+	}
+	d := &Drift{Kernel: kernel, Dispatch: c.dispatches}
+	if n <= 0 {
+		return d, nil
+	}
+	idxs := make([]int, max(c.Samples, 1))
+	for i := range idxs {
+		idxs[i] = c.rand.IntN(n)
+		el.Func(cpu, uint32(idxs[i]))
+	}
+	d.Samples = len(idxs)
+	for _, nm := range el.Compare {
+		sz := gpu.specs[nm].ElemSize
+		for _, ix := range idxs {
+			st := ix * sz
+			c.compareValues(d, nm, ix, gpu.data[nm][st:st+sz], cpu.data[nm][st:st+sz])
+		}
+	}
+	return d, nil
+}
+
+// compareValues compares the given element of the given buffer from the
+// GPU and the CPU, as 32 bit values, adding the differences to the Drift.
+func (c *Comparer) compareValues(d *Drift, buf string, idx int, gpu, cpu []byte) {
+	for i := 0; i+4 <= len(gpu); i += 4 {
+		d.Values++
+		gb := uint32(gpu[i]) | uint32(gpu[i+1])<<8 | uint32(gpu[i+2])<<16 | uint32(gpu[i+3])<<24
+		cb := uint32(cpu[i]) | uint32(cpu[i+1])<<8 | uint32(cpu[i+2])<<16 | uint32(cpu[i+3])<<24
+		if gb == cb {
+			continue
+		}
+		gv, cv := float64(math.Float32frombits(gb)), float64(math.Float32frombits(cb))
+		if !isNormal(gb) || !isNormal(cb) {
+			if d.Mismatches == 0 && d.MaxAbs == 0 {
+				d.Buffer, d.Index = buf, idx
+			}
+			d.Mismatches++
+			continue
+		}
+		mag := max(math.Abs(gv), math.Abs(cv))
+		diff := math.Abs(gv - cv)
+		if diff > c.Tolerance*(1+mag) {
+			d.Mismatches++
+		}
+		if diff > d.MaxAbs {
+			d.MaxAbs, d.Buffer, d.Index = diff, buf, idx
+		}
+		d.MaxRel = max(d.MaxRel, diff/mag)
+	}
+}
+
+// isNormal returns true if the given bits are a normal float32 number or
+// zero, and not a denormal, e.g., a small integer, infinity or NaN.
+func isNormal(bits uint32) bool {
+	exp := (bits >> 23) & 0xff
+	return exp != 0xff && (exp != 0 || bits&0x7fffffff == 0)
+}
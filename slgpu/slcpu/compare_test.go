@@ -0,0 +1,63 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slcpu
+
+import (
+	"testing"
+
+	"github.com/emer/gosl/v2/slgpu"
+)
+
+func TestComparer(t *testing.T) {
+	// the "GPU" version drifts from the CPU version for the odd elements
+	rt := New()
+	rt.RegisterKernel("drift", func(bufs *Buffers, groups [3]int) {
+		ds := Slice[data](bufs, "Data")
+		for i := range ds {
+			ds[i].Out = 2 * ds[i].Raw
+			if i%2 == 1 {
+				ds[i].Out += 0.5
+			}
+		}
+		Store(bufs, "Data", ds)
+	})
+	var drifts []Drift
+	cmp := NewComparer(rt, 3, 16, func(d *Drift) { drifts = append(drifts, *d) })
+	cmp.RegisterElement("drift", &Element{Threads: 64, Buffers: []string{"Data"}, Compare: []string{"Data"},
+		Func: func(bufs *Buffers, idx uint32) {
+			ds := Slice[data](bufs, "Data")
+			ds[idx].Out = 2 * ds[idx].Raw
+			Store(bufs, "Data", ds)
+		}})
+	ds := make([]data, 100)
+	for i := range ds {
+		ds[i].Raw = float32(i)
+	}
+	cmp.CreateBuffer("Data", 0, 0, 16, len(ds))
+	cmp.AddKernel("drift", "shaders/drift.spv")
+	cmp.Config()
+	cmp.Upload("Data", slgpu.Bytes(ds))
+	for range 7 {
+		if err := cmp.Dispatch("drift", 2, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(drifts) != 2 || drifts[0].Dispatch != 3 || drifts[1].Dispatch != 6 {
+		t.Fatalf("wrong comparisons: %+v", drifts)
+	}
+	d := drifts[0]
+	if d.Samples != 16 || d.Values != 64 || d.Mismatches == 0 || d.MaxAbs != 0.5 || d.Buffer != "Data" || d.Index%2 != 1 {
+		t.Errorf("wrong drift: %+v", d)
+	}
+
+	cmp.Tolerance = 1
+	drifts = nil
+	for range 3 {
+		cmp.Dispatch("drift", 2, 1, 1)
+	}
+	if len(drifts) != 1 || drifts[0].Mismatches != 0 || drifts[0].MaxAbs != 0.5 {
+		t.Errorf("differences within the tolerance should not be mismatches: %+v", drifts)
+	}
+}
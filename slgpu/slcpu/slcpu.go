@@ -0,0 +1,176 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package slcpu implements the slgpu.Runtime interface on the CPU,
+registered as "cpu", by running Go versions of the kernels, e.g., the
+//gosl: cpukernel CPU functions.  It is a fallback for where no GPU is
+available, and is pure Go, so it also runs in the browser under GOOS=js,
+where slgpu encodes the buffers without using unsafe.
+
+The Go version of each kernel is registered on the Runtime with
+RegisterKernel, and gets the buffers with Slice, and stores any it
+writes with Store, which does nothing unless the buffers are encoded:
+
+	rt := slcpu.New()
+	rt.RegisterKernel("basic", func(bufs *slcpu.Buffers, groups [3]int) {
+		params := slcpu.Slice[ParamStruct](bufs, "Params")
+		data := slcpu.Slice[DataStruct](bufs, "Data")
+		for i := range data {
+			BasicCPU(uint32(i), params, data)
+		}
+		slcpu.Store(bufs, "Data", data)
+	})
+
+A Comparer wraps a GPU Runtime to periodically run the Go versions of
+the 1D kernels for one thread, registered with its RegisterElement, on a
+random sample of the elements, and compare them with the GPU results,
+for online validation of long runs.
+
+The kernels and elements are registered on each Runtime and Comparer,
+not globally, so that independent models with the same kernel names,
+e.g., the workers of a parameter sweep, can run in the same process.
+*/
+package slcpu
+
+import (
+	"fmt"
+
+	"github.com/emer/gosl/v2/slgpu"
+)
+
+func init() {
+	slgpu.Register("cpu", func() (slgpu.Runtime, error) {
+		return New(), nil
+	})
+}
+
+// KernelFunc is the Go version of a kernel, which is called once for each
+// dispatch, with the number of workgroups dispatched in each dimension.
+// It must run the kernel for all of the threads, which is typically all
+// of the elements of the buffer indexed by the thread index, as in the
+// bounds-checked kernels.
+type KernelFunc func(bufs *Buffers, groups [3]int)
+
+// Buffers are the buffers of a Runtime, by name
+type Buffers struct {
+	specs map[string]*slgpu.BufferSpec
+	data  map[string][]byte
+}
+
+// Bytes returns the bytes of the buffer with given name, or nil if none
+func (bf *Buffers) Bytes(name string) []byte {
+	return bf.data[name]
+}
+
+// Slice returns the values of the buffer with given name, as type T,
+// which is a view of the buffer, unless slgpu encodes the buffers,
+// in which case Store must be called to store any changes.
+func Slice[T any](bufs *Buffers, name string) []T {
+	return slgpu.Values[T](bufs.data[name])
+}
+
+// Store stores the given values from Slice in the buffer with given name,
+// which does nothing if they are a view of the buffer.
+func Store[T any](bufs *Buffers, name string, s []T) {
+	slgpu.CopyToBytes(bufs.data[name], s)
+}
+
+// Runtime is a slgpu.Runtime that runs the registered Go versions
+// of the kernels on the CPU.
+type Runtime struct {
+	Buffers
+	funcs   map[string]KernelFunc
+	kernels map[string]KernelFunc
+}
+
+// New returns a new Runtime, without any kernels registered
+func New() *Runtime {
+	rt := &Runtime{funcs: map[string]KernelFunc{}, kernels: map[string]KernelFunc{}}
+	rt.specs = map[string]*slgpu.BufferSpec{}
+	rt.data = map[string][]byte{}
+	return rt
+}
+
+func (rt *Runtime) CreateBuffer(name string, set, binding, elemSize, n int) error {
+	if _, has := rt.specs[name]; has {
+		return fmt.Errorf("slcpu: buffer %s already created", name)
+	}
+	bs := &slgpu.BufferSpec{Name: name, Set: set, Binding: binding, ElemSize: elemSize, N: n}
+	rt.specs[name] = bs
+	rt.data[name] = make([]byte, bs.Size())
+	return nil
+}
+
+// RegisterKernel registers the Go version of the kernel with given name,
+// for AddKernel.
+func (rt *Runtime) RegisterKernel(name string, fun KernelFunc) {
+	rt.funcs[name] = fun
+}
+
+func (rt *Runtime) AddKernel(name, file string) error {
+	fun, ok := rt.funcs[name]
+	if !ok {
+		return fmt.Errorf("slcpu: no Go version of kernel %s registered with RegisterKernel", name)
+	}
+	rt.kernels[name] = fun
+	return nil
+}
+
+func (rt *Runtime) Config() error {
+	return nil
+}
+
+// buffer returns the bytes of the buffer with given name, checking
+// the size of given data.
+func (rt *Runtime) buffer(name string, data []byte) ([]byte, error) {
+	bs, ok := rt.specs[name]
+	if !ok {
+		return nil, fmt.Errorf("slcpu: buffer %s not created", name)
+	}
+	if err := bs.CheckSize(data); err != nil {
+		return nil, err
+	}
+	return rt.data[name], nil
+}
+
+func (rt *Runtime) Upload(name string, data []byte) error {
+	b, err := rt.buffer(name, data)
+	if err != nil {
+		return err
+	}
+	copy(b, data)
+	return nil
+}
+
+func (rt *Runtime) Dispatch(kernel string, nx, ny, nz int) error {
+	fun, ok := rt.kernels[kernel]
+	if !ok {
+		return fmt.Errorf("slcpu: kernel %s not added", kernel)
+	}
+	fun(&rt.Buffers, [3]int{nx, ny, nz})
+	return nil
+}
+
+func (rt *Runtime) Barrier() error {
+	return nil
+}
+
+func (rt *Runtime) Wait() error {
+	return nil
+}
+
+func (rt *Runtime) Readback(name string, data []byte) error {
+	b, err := rt.buffer(name, data)
+	if err != nil {
+		return err
+	}
+	copy(data, b)
+	return nil
+}
+
+func (rt *Runtime) Release() {
+	rt.specs = map[string]*slgpu.BufferSpec{}
+	rt.data = map[string][]byte{}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slcpu
+
+import (
+	"testing"
+
+	"github.com/emer/gosl/v2/slgpu"
+)
+
+type params struct {
+	Gain float32
+
+	pad, pad1, pad2 float32
+}
+
+type data struct {
+	Raw, Out float32
+
+	pad, pad1 float32
+}
+
+func TestRuntime(t *testing.T) {
+	rt, err := slgpu.New("cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.(*Runtime).RegisterKernel("scale", func(bufs *Buffers, groups [3]int) {
+		ps := Slice[params](bufs, "Params")
+		ds := Slice[data](bufs, "Data")
+		for i := range ds {
+			ds[i].Out = ps[0].Gain * ds[i].Raw
+		}
+		Store(bufs, "Data", ds)
+	})
+	ps := []params{{Gain: 2}}
+	ds := []data{{Raw: 1}, {Raw: 2}, {Raw: 3}}
+	rt.CreateBuffer("Params", 0, 0, 16, len(ps))
+	rt.CreateBuffer("Data", 1, 0, 16, len(ds))
+	if err := rt.AddKernel("scale", "shaders/scale.spv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.AddKernel("none", "shaders/none.spv"); err == nil {
+		t.Error("expected error for unregistered kernel")
+	}
+	rt.Config()
+	rt.Upload("Params", slgpu.Bytes(ps))
+	rt.Upload("Data", slgpu.Bytes(ds))
+	if err := rt.Dispatch("scale", 1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 16*len(ds))
+	if err := rt.Readback("Data", b); err != nil {
+		t.Fatal(err)
+	}
+	slgpu.CopyFromBytes(ds, b)
+	for i, d := range ds {
+		if d.Out != 2*d.Raw {
+			t.Errorf("%d: Out = %g, want %g", i, d.Out, 2*d.Raw)
+		}
+	}
+	if err := rt.Readback("Data", b[:4]); err == nil {
+		t.Error("expected error for wrong size")
+	}
+	rt.Release()
+}
+
+// TestIndependentRuntimes checks that the kernels of two runtimes with
+// the same name, e.g., of two models, are independent.
+func TestIndependentRuntimes(t *testing.T) {
+	var rts []*Runtime
+	for _, gain := range []float32{2, 3} {
+		rt := New()
+		rt.RegisterKernel("scale", func(bufs *Buffers, groups [3]int) {
+			ds := Slice[data](bufs, "Data")
+			ds[0].Out = gain * ds[0].Raw
+			Store(bufs, "Data", ds)
+		})
+		rt.CreateBuffer("Data", 0, 0, 16, 1)
+		if err := rt.AddKernel("scale", "shaders/scale.spv"); err != nil {
+			t.Fatal(err)
+		}
+		rt.Upload("Data", slgpu.Bytes([]data{{Raw: 1}}))
+		rts = append(rts, rt)
+	}
+	for i, rt := range rts {
+		rt.Dispatch("scale", 1, 1, 1)
+		ds := []data{{}}
+		b := make([]byte, 16)
+		rt.Readback("Data", b)
+		slgpu.CopyFromBytes(ds, b)
+		if want := float32(2 + i); ds[0].Out != want {
+			t.Errorf("runtime %d: Out = %g, want %g", i, ds[0].Out, want)
+		}
+	}
+}
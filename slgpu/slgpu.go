@@ -0,0 +1,197 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package slgpu abstracts the GPU runtime used to run the kernels
+generated by gosl behind a small Runtime interface, so that the same
+host code can run on different GPU binding layers, selected by name
+in the configuration of a model:
+
+	rt, err := slgpu.New(cfg.GPU) // e.g., "vgpu"
+	rt.CreateBuffer("Params", 0, 0, int(unsafe.Sizeof(ParamStruct{})), 1)
+	rt.CreateBuffer("Data", 1, 0, int(unsafe.Sizeof(DataStruct{})), n)
+	rt.AddKernel("basic", "shaders/basic.spv")
+	rt.Config()
+	rt.Upload("Params", slgpu.Bytes(params))
+	rt.Upload("Data", slgpu.Bytes(data))
+	rt.Dispatch("basic", (n+63)/64, 1, 1)
+	b := slgpu.Bytes(data)
+	rt.Readback("Data", b)
+	slgpu.CopyFromBytes(data, b)
+	rt.Release()
+
+Implementations register themselves with Register in an init function,
+so they are available by importing their package, e.g., for vgpu:
+
+	import _ "github.com/emer/gosl/v2/slgpu/slvgpu"
+
+The WebGPU (wgpu-go) implementation is in the separate slwgpu module, so
+that gosl does not depend on wgpu-go, and registers itself as "wgpu" when
+imported, loading the kernels generated with gosl -target wgsl:
+
+	import _ "github.com/emer/gosl/v2/slgpu/slwgpu"
+
+Runtimes that implement DeviceRuntime, e.g., vgpu, report the properties
+of their Device, which recommends the number of threads per workgroup
+of the kernels for the device with Device.Threads.
+
+A Monitor wraps a Runtime to count its dispatches and transfers, and
+sample them periodically with the device counters of a CounterRuntime,
+for logging or exporting to Prometheus in long-running simulations.
+
+The slcpu package implements a "cpu" Runtime that runs Go versions of
+the kernels, as a fallback where no GPU is available, including in the
+browser under GOOS=js.
+
+Bytes, Values, CopyToBytes and CopyFromBytes convert between slices of
+values and bytes.  By default, they use the same memory without copying
+where possible.  With GOOS=js or the slsafe build tag, they encode and
+decode the values field by field instead, without any use of unsafe.
+*/
+package slgpu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Runtime is a GPU runtime that runs compute kernels on storage buffers.
+// All of the buffers and kernels must be created before Config,
+// after which the buffers can be uploaded, the kernels dispatched,
+// and the buffers read back.  Dispatches run in order, and may run
+// asynchronously until Readback or Wait.
+type Runtime interface {
+
+	// CreateBuffer declares a storage buffer with the given name, at the
+	// given descriptor set and binding, as in [[vk::binding(binding, set)]],
+	// with n elements of elemSize bytes each.
+	CreateBuffer(name string, set, binding, elemSize, n int) error
+
+	// AddKernel adds a compute kernel with the given name, from the
+	// given compiled shader file, e.g., shaders/basic.spv.
+	AddKernel(name, file string) error
+
+	// Config allocates the buffers and configures the kernels.
+	Config() error
+
+	// Upload copies the given data to the buffer with the given name,
+	// after any pending dispatches have completed.  The data must be
+	// the size of the buffer.
+	Upload(name string, data []byte) error
+
+	// Dispatch runs the kernel with the given name on the given number
+	// of workgroups in each dimension.
+	Dispatch(kernel string, nx, ny, nz int) error
+
+	// Barrier ensures that the buffer writes of all previous dispatches
+	// are visible to subsequent dispatches.
+	Barrier() error
+
+	// Wait waits until all pending dispatches have completed.
+	Wait() error
+
+	// Readback copies the buffer with the given name to the given data,
+	// after any pending dispatches have completed.  The data must be
+	// the size of the buffer.
+	Readback(name string, data []byte) error
+
+	// Release releases all of the GPU resources.
+	Release()
+}
+
+var (
+	runtimesMu sync.Mutex
+	runtimes   = map[string]func() (Runtime, error){}
+)
+
+// Register registers a Runtime implementation with the given name,
+// and function that returns a new Runtime.
+func Register(name string, newFunc func() (Runtime, error)) {
+	runtimesMu.Lock()
+	defer runtimesMu.Unlock()
+	runtimes[name] = newFunc
+}
+
+// Runtimes returns the names of the registered Runtime implementations.
+func Runtimes() []string {
+	runtimesMu.Lock()
+	defer runtimesMu.Unlock()
+	nms := make([]string, 0, len(runtimes))
+	for nm := range runtimes {
+		nms = append(nms, nm)
+	}
+	sort.Strings(nms)
+	return nms
+}
+
+// WGPU is the name of the WebGPU (wgpu-go) Runtime of the separate slwgpu
+// module, for which New returns an explanatory error unless it is imported.
+const WGPU = "wgpu"
+
+// New returns a new Runtime of the registered implementation with the
+// given name.
+func New(name string) (Runtime, error) {
+	runtimesMu.Lock()
+	newFunc, ok := runtimes[name]
+	runtimesMu.Unlock()
+	if !ok && name == WGPU {
+		return nil, fmt.Errorf("slgpu: GPU runtime %q is not registered: import github.com/emer/gosl/v2/slgpu/slwgpu (a separate module), and generate the kernels with -target wgsl: registered: %s", name, strings.Join(Runtimes(), ", "))
+	}
+	if !ok {
+		return nil, fmt.Errorf("slgpu: GPU runtime %q is not registered (import its package): registered: %s", name, strings.Join(Runtimes(), ", "))
+	}
+	return newFunc()
+}
+
+// BufferSpec is the specification of a buffer passed to CreateBuffer,
+// for use by implementations.
+type BufferSpec struct {
+	Name     string
+	Set      int
+	Binding  int
+	ElemSize int
+	N        int
+}
+
+// Size returns the size of the buffer in bytes
+func (bs *BufferSpec) Size() int {
+	return bs.ElemSize * bs.N
+}
+
+// CheckSize returns an error if the given data is not the size of the buffer
+func (bs *BufferSpec) CheckSize(data []byte) error {
+	if len(data) != bs.Size() {
+		return fmt.Errorf("slgpu: buffer %s is %d bytes, not %d", bs.Name, bs.Size(), len(data))
+	}
+	return nil
+}
+
+// SortSpecs sorts the buffer specs by set and then binding, and returns
+// an error if any bindings are duplicated, or missing within a set, or
+// any sets are missing, as required by binding layers that assign
+// bindings in order.
+func SortSpecs(specs []*BufferSpec) error {
+	sort.SliceStable(specs, func(i, j int) bool {
+		if specs[i].Set != specs[j].Set {
+			return specs[i].Set < specs[j].Set
+		}
+		return specs[i].Binding < specs[j].Binding
+	})
+	set, bind := -1, 0
+	for _, bs := range specs {
+		if bs.Set != set {
+			if bs.Set != set+1 {
+				return fmt.Errorf("slgpu: buffer %s: set %d should be %d: sets must be consecutive from 0", bs.Name, bs.Set, set+1)
+			}
+			set, bind = bs.Set, 0
+		}
+		if bs.Binding != bind {
+			return fmt.Errorf("slgpu: buffer %s: binding %d in set %d should be %d: bindings must be consecutive from 0", bs.Name, bs.Binding, bs.Set, bind)
+		}
+		bind++
+	}
+	return nil
+}
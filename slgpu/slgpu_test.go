@@ -0,0 +1,130 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slgpu
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	Register("test", func() (Runtime, error) { return nil, nil })
+	if !slices.Contains(Runtimes(), "test") {
+		t.Errorf("test runtime not registered: %v", Runtimes())
+	}
+	if _, err := New("test"); err != nil {
+		t.Error(err)
+	}
+	if _, err := New("none"); err == nil {
+		t.Error("expected error for unregistered runtime")
+	}
+	if _, err := New(WGPU); err == nil || !strings.Contains(err.Error(), "slgpu/slwgpu") {
+		t.Errorf("expected error for the wgpu runtime: %v", err)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	type elem struct {
+		A    float32
+		B    int32
+		pad  uint32
+		Arr  [2]uint32
+		pad1 [3]float32
+	}
+	es := []elem{{A: 1, B: -2, Arr: [2]uint32{3, 4}}, {A: 5, B: 6}}
+	b := Bytes(es)
+	if len(b) != 2*32 {
+		t.Fatalf("len: got %d, want 64", len(b))
+	}
+	if b[4] != 0xfe || b[12] != 3 || b[32+4] != 6 {
+		t.Errorf("unexpected encoding: % x", b)
+	}
+	b[32+4] = 7
+	CopyFromBytes(es, b)
+	if es[1].B != 7 || es[0].B != -2 {
+		t.Errorf("CopyFromBytes: got B = %d, %d", es[0].B, es[1].B)
+	}
+	vs := Values[elem](b)
+	if len(vs) != 2 || vs[0].Arr[1] != 4 || vs[1].A != 5 {
+		t.Errorf("Values: got %+v", vs)
+	}
+	es[0].A = 2
+	CopyToBytes(b, es)
+	if vs := Values[elem](b); vs[0].A != 2 {
+		t.Errorf("CopyToBytes: got A = %g", vs[0].A)
+	}
+}
+
+func TestAnyBytes(t *testing.T) {
+	type elem struct {
+		A   float32
+		B   int32
+		pad [2]uint32
+	}
+	es := []elem{{A: 1, B: -2}, {A: 5, B: 6}}
+	if sz, n := AnyShape(es); sz != 16 || n != 2 {
+		t.Errorf("AnyShape: got %d, %d", sz, n)
+	}
+	b := AnyBytes(es)
+	if string(b) != string(Bytes(es)) {
+		t.Errorf("AnyBytes: got % x", b)
+	}
+	e := &elem{}
+	if sz, n := AnyShape(e); sz != 16 || n != 1 {
+		t.Errorf("AnyShape pointer: got %d, %d", sz, n)
+	}
+	CopyFromAnyBytes(e, b[16:])
+	if e.A != 5 || e.B != 6 {
+		t.Errorf("CopyFromAnyBytes: got %+v", *e)
+	}
+}
+
+func TestSortSpecs(t *testing.T) {
+	specs := []*BufferSpec{{Name: "Data", Set: 1}, {Name: "Idx", Set: 0, Binding: 1}, {Name: "Params", Set: 0}}
+	if err := SortSpecs(specs); err != nil {
+		t.Fatal(err)
+	}
+	var nms []string
+	for _, bs := range specs {
+		nms = append(nms, bs.Name)
+	}
+	if !slices.Equal(nms, []string{"Params", "Idx", "Data"}) {
+		t.Errorf("order: %v", nms)
+	}
+	if err := SortSpecs([]*BufferSpec{{Name: "Data", Set: 1}}); err == nil {
+		t.Error("expected error for missing set 0")
+	}
+	if err := SortSpecs([]*BufferSpec{{Name: "A"}, {Name: "B"}}); err == nil {
+		t.Error("expected error for duplicate binding")
+	}
+}
+
+func TestDeviceThreads(t *testing.T) {
+	amd := Device{VendorID: 0x1002, SubgroupSize: 64, MaxThreads: 1024}
+	nv := Device{VendorID: 0x10DE, SubgroupSize: 32, MaxThreads: 1024}
+	small := Device{SubgroupSize: 32, MaxThreads: 16}
+	tests := []struct {
+		dev   Device
+		sizes []int
+		want  int
+	}{
+		{Device{}, nil, 64},
+		{amd, nil, 64},
+		{nv, nil, 32},
+		{small, nil, 16},
+		{nv, []int{32, 64, 128, 256}, 32},
+		{amd, []int{32, 64, 128, 256}, 64},
+		{amd, []int{128, 256}, 128},
+		{amd, []int{48, 96}, 48},
+		{small, []int{32, 64}, 32},
+		{Device{}, []int{256, 32}, 256},
+	}
+	for _, tc := range tests {
+		if got := tc.dev.Threads(tc.sizes...); got != tc.want {
+			t.Errorf("Threads(%v) on %+v: got %d, want %d", tc.sizes, tc.dev, got, tc.want)
+		}
+	}
+}
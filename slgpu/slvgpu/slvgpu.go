@@ -0,0 +1,212 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package slvgpu implements the slgpu.Runtime and slgpu.DeviceRuntime
+// interfaces with vgpu, registered as "vgpu".  vgpu must be used from
+// the main thread, which must be locked with runtime.LockOSThread in an
+// init function.
+package slvgpu
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"cogentcore.org/core/vgpu"
+	"github.com/emer/gosl/v2/slgpu"
+)
+
+func init() {
+	slgpu.Register("vgpu", func() (slgpu.Runtime, error) {
+		return New()
+	})
+}
+
+// newMu serializes New, as the initialization of vgpu and the
+// configuration of a GPU use the global state of the Vulkan loader.
+var newMu sync.Mutex
+
+// Runtime is a slgpu.Runtime using vgpu, with a compute System
+// in which each buffer is a storage Var with one Value.
+type Runtime struct {
+
+	// the GPU device
+	GPU *vgpu.GPU
+
+	// the compute system with the vars and pipelines
+	System *vgpu.System
+
+	specs      []*slgpu.BufferSpec
+	bufs       map[string]*slgpu.BufferSpec
+	vals       map[string]*vgpu.Value
+	configured bool
+	recording  bool
+}
+
+// New returns a new Runtime on the default compute GPU device, with its
+// own GPU and System, so that independent Runtimes, e.g., of the workers
+// of a parameter sweep, can be created from different goroutines.
+func New() (*Runtime, error) {
+	newMu.Lock()
+	defer newMu.Unlock()
+	if err := vgpu.InitNoDisplay(); err != nil {
+		return nil, err
+	}
+	gp := vgpu.NewComputeGPU()
+	if err := gp.Config("slgpu"); err != nil {
+		return nil, err
+	}
+	rt := &Runtime{GPU: gp, bufs: map[string]*slgpu.BufferSpec{}, vals: map[string]*vgpu.Value{}}
+	rt.System = gp.NewComputeSystem("slgpu")
+	return rt, nil
+}
+
+// Device returns the properties of the GPU device.  vgpu does not query
+// the subgroup properties of the device, so its subgroup size is that
+// of its vendor, in slgpu.VendorSubgroupSizes.
+func (rt *Runtime) Device() slgpu.Device {
+	props := &rt.GPU.GPUProperties
+	lim := &props.Limits
+	return slgpu.Device{Name: rt.GPU.DeviceName, VendorID: props.VendorID, SubgroupSize: slgpu.VendorSubgroupSizes[props.VendorID], MaxThreads: int(min(lim.MaxComputeWorkGroupSize[0], lim.MaxComputeWorkGroupInvocations))}
+}
+
+func (rt *Runtime) CreateBuffer(name string, set, binding, elemSize, n int) error {
+	if rt.configured {
+		return fmt.Errorf("slvgpu: buffer %s must be created before Config", name)
+	}
+	if _, has := rt.bufs[name]; has {
+		return fmt.Errorf("slvgpu: buffer %s already created", name)
+	}
+	bs := &slgpu.BufferSpec{Name: name, Set: set, Binding: binding, ElemSize: elemSize, N: n}
+	rt.specs = append(rt.specs, bs)
+	rt.bufs[name] = bs
+	return nil
+}
+
+func (rt *Runtime) AddKernel(name, file string) error {
+	if rt.configured {
+		return fmt.Errorf("slvgpu: kernel %s must be added before Config", name)
+	}
+	pl := rt.System.NewPipeline(name)
+	pl.AddShaderFile(name, vgpu.ComputeShader, file)
+	return nil
+}
+
+func (rt *Runtime) Config() error {
+	if err := slgpu.SortSpecs(rt.specs); err != nil {
+		return err
+	}
+	vars := rt.System.Vars()
+	var st *vgpu.VarSet
+	vrs := map[string]*vgpu.Var{}
+	for _, bs := range rt.specs {
+		if bs.Binding == 0 {
+			if st != nil {
+				st.ConfigValues(1)
+			}
+			st = vars.AddSet()
+		}
+		vrs[bs.Name] = st.AddStruct(bs.Name, bs.ElemSize, bs.N, vgpu.Storage, vgpu.ComputeShader)
+	}
+	if st != nil {
+		st.ConfigValues(1)
+	}
+	rt.System.Config()
+	for _, bs := range rt.specs {
+		vl, err := vrs[bs.Name].Values.ValueByIndexTry(0)
+		if err != nil {
+			return err
+		}
+		rt.vals[bs.Name] = vl
+		if err := vars.BindDynamicValueIndex(bs.Set, bs.Name, 0); err != nil {
+			return err
+		}
+	}
+	rt.configured = true
+	return nil
+}
+
+// buffer returns the spec and value of the buffer with given name,
+// checking the size of given data.
+func (rt *Runtime) buffer(name string, data []byte) (*slgpu.BufferSpec, *vgpu.Value, error) {
+	bs, ok := rt.bufs[name]
+	if !ok || !rt.configured {
+		return nil, nil, fmt.Errorf("slvgpu: buffer %s not created and configured", name)
+	}
+	if err := bs.CheckSize(data); err != nil {
+		return nil, nil, err
+	}
+	return bs, rt.vals[name], nil
+}
+
+func (rt *Runtime) Upload(name string, data []byte) error {
+	_, vl, err := rt.buffer(name, data)
+	if err != nil {
+		return err
+	}
+	if err := rt.Wait(); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		vl.CopyFromBytes(unsafe.Pointer(&data[0]))
+	}
+	rt.System.Mem.SyncToGPU()
+	return nil
+}
+
+func (rt *Runtime) Dispatch(kernel string, nx, ny, nz int) error {
+	pl, err := rt.System.PipelineByNameTry(kernel)
+	if err != nil {
+		return err
+	}
+	cmd := rt.System.ComputeCmdBuff()
+	if !rt.recording {
+		rt.System.CmdResetBindVars(cmd, 0)
+		rt.recording = true
+	}
+	pl.ComputeDispatch(cmd, nx, ny, nz)
+	return nil
+}
+
+func (rt *Runtime) Barrier() error {
+	if rt.recording {
+		rt.System.ComputeWaitMemWriteRead(rt.System.ComputeCmdBuff())
+	}
+	return nil
+}
+
+func (rt *Runtime) Wait() error {
+	if !rt.recording {
+		return nil
+	}
+	cmd := rt.System.ComputeCmdBuff()
+	rt.System.ComputeCmdEnd(cmd)
+	rt.System.ComputeSubmitWait(cmd)
+	rt.recording = false
+	return nil
+}
+
+func (rt *Runtime) Readback(name string, data []byte) error {
+	bs, vl, err := rt.buffer(name, data)
+	if err != nil {
+		return err
+	}
+	if err := rt.Wait(); err != nil {
+		return err
+	}
+	if err := rt.System.Mem.SyncValueIndexFromGPU(bs.Set, name, 0); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		vl.CopyToBytes(unsafe.Pointer(&data[0]))
+	}
+	return nil
+}
+
+// Release destroys the System and GPU.  If the program uses a display,
+// vgpu.Terminate must still be called at the end.
+func (rt *Runtime) Release() {
+	rt.System.Destroy()
+	rt.GPU.Destroy()
+}
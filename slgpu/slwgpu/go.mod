@@ -0,0 +1,7 @@
+module github.com/emer/gosl/v2/slgpu/slwgpu
+
+go 1.22
+
+require github.com/emer/gosl/v2 v2.0.0-00010101000000-000000000000
+
+replace github.com/emer/gosl/v2 => ../..
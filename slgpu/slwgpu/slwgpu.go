@@ -0,0 +1,385 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package slwgpu implements the slgpu.Runtime and slgpu.DeviceRuntime
+// interfaces with WebGPU, using wgpu-go (github.com/cogentcore/webgpu),
+// registered as "wgpu" (slgpu.WGPU).  It runs the kernels generated with
+// gosl -target wgsl, e.g., shaders/basic.wgsl, instead of the .spv files.
+//
+// It is a separate module, so that gosl does not depend on wgpu-go and
+// its native library: add it to a model with
+//
+//	go get github.com/emer/gosl/v2/slgpu/slwgpu
+//
+// and import it for its registration:
+//
+//	import _ "github.com/emer/gosl/v2/slgpu/slwgpu"
+package slwgpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/cogentcore/webgpu/wgpu"
+	"github.com/emer/gosl/v2/slgpu"
+)
+
+func init() {
+	slgpu.Register(slgpu.WGPU, func() (slgpu.Runtime, error) {
+		return New()
+	})
+}
+
+// Runtime is a slgpu.Runtime using WebGPU, with a storage buffer for each
+// buffer, and a compute pipeline for each kernel, with a bind group for
+// each set of the buffers that the kernel uses.  Each Dispatch is a
+// compute pass of a command encoder that is submitted by Wait, so that
+// the writes of each dispatch are visible to the next ones.
+type Runtime struct {
+
+	// the WebGPU instance, adapter and device
+	Instance *wgpu.Instance
+	Adapter  *wgpu.Adapter
+	Device   *wgpu.Device
+
+	// the queue of the device
+	Queue *wgpu.Queue
+
+	specs      []*slgpu.BufferSpec
+	bufs       map[string]*buffer
+	kernels    map[string]*kernel
+	encoder    *wgpu.CommandEncoder
+	configured bool
+}
+
+// buffer is a storage buffer, with a buffer to map for reading it back
+type buffer struct {
+	spec    *slgpu.BufferSpec
+	buf     *wgpu.Buffer
+	staging *wgpu.Buffer
+}
+
+// kernel is a compute pipeline, with the bindings used by its shader,
+// and the bind groups of the buffers at those bindings, by set
+type kernel struct {
+	name     string
+	src      string
+	bindings map[int][]int
+	pipeline *wgpu.ComputePipeline
+	groups   map[int]*wgpu.BindGroup
+}
+
+// New returns a new Runtime on the high performance adapter, with the
+// limits of the adapter, e.g., for the number of storage buffers per
+// kernel and their size, which are higher than the WebGPU defaults.
+func New() (*Runtime, error) {
+	rt := &Runtime{bufs: map[string]*buffer{}, kernels: map[string]*kernel{}}
+	rt.Instance = wgpu.CreateInstance(nil)
+	ad, err := rt.Instance.RequestAdapter(&wgpu.RequestAdapterOptions{PowerPreference: wgpu.PowerPreferenceHighPerformance})
+	if err != nil {
+		rt.Instance.Release()
+		return nil, fmt.Errorf("slwgpu: no adapter: %w", err)
+	}
+	rt.Adapter = ad
+	lim := ad.GetLimits()
+	dev, err := ad.RequestDevice(&wgpu.DeviceDescriptor{Label: "slgpu", RequiredLimits: &wgpu.RequiredLimits{Limits: lim.Limits}})
+	if err != nil {
+		ad.Release()
+		rt.Instance.Release()
+		return nil, fmt.Errorf("slwgpu: no device: %w", err)
+	}
+	rt.Device = dev
+	rt.Queue = dev.GetQueue()
+	return rt, nil
+}
+
+// Device returns the properties of the GPU device.  WebGPU does not
+// report the subgroup size, so it is that of its vendor, in
+// slgpu.VendorSubgroupSizes.
+func (rt *Runtime) Device() slgpu.Device {
+	info := rt.Adapter.GetInfo()
+	lim := rt.Device.GetLimits().Limits
+	return slgpu.Device{Name: info.Name, VendorID: info.VendorId, SubgroupSize: slgpu.VendorSubgroupSizes[info.VendorId], MaxThreads: int(min(lim.MaxComputeWorkgroupSizeX, lim.MaxComputeInvocationsPerWorkgroup))}
+}
+
+func (rt *Runtime) CreateBuffer(name string, set, binding, elemSize, n int) error {
+	if rt.configured {
+		return fmt.Errorf("slwgpu: buffer %s must be created before Config", name)
+	}
+	if _, has := rt.bufs[name]; has {
+		return fmt.Errorf("slwgpu: buffer %s already created", name)
+	}
+	bs := &slgpu.BufferSpec{Name: name, Set: set, Binding: binding, ElemSize: elemSize, N: n}
+	rt.specs = append(rt.specs, bs)
+	rt.bufs[name] = &buffer{spec: bs}
+	return nil
+}
+
+// AddKernel adds the kernel from the given WGSL file, e.g.,
+// shaders/basic.wgsl, with its #include lines expanded (see ReadWGSL).
+func (rt *Runtime) AddKernel(name, file string) error {
+	if rt.configured {
+		return fmt.Errorf("slwgpu: kernel %s must be added before Config", name)
+	}
+	src, err := ReadWGSL(file)
+	if err != nil {
+		return err
+	}
+	rt.kernels[name] = &kernel{name: name, src: src, bindings: Bindings(src)}
+	return nil
+}
+
+// bufferSize returns the size of the WebGPU buffer for the given spec:
+// its size rounded up to a multiple of 4 bytes, and at least 4.
+func bufferSize(bs *slgpu.BufferSpec) uint64 {
+	return uint64(max(4, (bs.Size()+3)&^3))
+}
+
+func (rt *Runtime) Config() error {
+	if err := slgpu.SortSpecs(rt.specs); err != nil {
+		return err
+	}
+	at := map[[2]int]*buffer{}
+	for _, bs := range rt.specs {
+		b := rt.bufs[bs.Name]
+		sz := bufferSize(bs)
+		var err error
+		b.buf, err = rt.Device.CreateBuffer(&wgpu.BufferDescriptor{Label: bs.Name, Size: sz, Usage: wgpu.BufferUsageStorage | wgpu.BufferUsageCopyDst | wgpu.BufferUsageCopySrc})
+		if err != nil {
+			return fmt.Errorf("slwgpu: buffer %s: %w", bs.Name, err)
+		}
+		b.staging, err = rt.Device.CreateBuffer(&wgpu.BufferDescriptor{Label: bs.Name + "Staging", Size: sz, Usage: wgpu.BufferUsageMapRead | wgpu.BufferUsageCopyDst})
+		if err != nil {
+			return fmt.Errorf("slwgpu: buffer %s: %w", bs.Name, err)
+		}
+		at[[2]int{bs.Set, bs.Binding}] = b
+	}
+	for _, k := range rt.kernels {
+		sm, err := rt.Device.CreateShaderModule(&wgpu.ShaderModuleDescriptor{Label: k.name, WGSLDescriptor: &wgpu.ShaderModuleWGSLDescriptor{Code: k.src}})
+		if err != nil {
+			return fmt.Errorf("slwgpu: kernel %s: %w", k.name, err)
+		}
+		k.pipeline, err = rt.Device.CreateComputePipeline(&wgpu.ComputePipelineDescriptor{Label: k.name, Compute: wgpu.ProgrammableStageDescriptor{Module: sm, EntryPoint: "main"}})
+		sm.Release()
+		if err != nil {
+			return fmt.Errorf("slwgpu: kernel %s: %w", k.name, err)
+		}
+		// the automatic layout of the pipeline only has the bindings
+		// used by the kernel, so its bind groups must only have those
+		k.groups = map[int]*wgpu.BindGroup{}
+		for set, binds := range k.bindings {
+			var ents []wgpu.BindGroupEntry
+			for _, bi := range binds {
+				b, ok := at[[2]int{set, bi}]
+				if !ok {
+					return fmt.Errorf("slwgpu: kernel %s: no buffer created at set %d, binding %d", k.name, set, bi)
+				}
+				ents = append(ents, wgpu.BindGroupEntry{Binding: uint32(bi), Buffer: b.buf, Size: wgpu.WholeSize})
+			}
+			bgl := k.pipeline.GetBindGroupLayout(uint32(set))
+			bg, err := rt.Device.CreateBindGroup(&wgpu.BindGroupDescriptor{Label: k.name, Layout: bgl, Entries: ents})
+			bgl.Release()
+			if err != nil {
+				return fmt.Errorf("slwgpu: kernel %s: set %d: %w", k.name, set, err)
+			}
+			k.groups[set] = bg
+		}
+	}
+	rt.configured = true
+	return nil
+}
+
+// buffer returns the buffer with given name, checking the size of given data.
+func (rt *Runtime) buffer(name string, data []byte) (*buffer, error) {
+	b, ok := rt.bufs[name]
+	if !ok || !rt.configured {
+		return nil, fmt.Errorf("slwgpu: buffer %s not created and configured", name)
+	}
+	if err := b.spec.CheckSize(data); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (rt *Runtime) Upload(name string, data []byte) error {
+	b, err := rt.buffer(name, data)
+	if err != nil {
+		return err
+	}
+	if err := rt.Wait(); err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data)%4 != 0 { // writes must be a multiple of 4 bytes
+		data = append(data[:len(data):len(data)], make([]byte, 4-len(data)%4)...)
+	}
+	rt.Queue.WriteBuffer(b.buf, 0, data)
+	return nil
+}
+
+func (rt *Runtime) Dispatch(kernel string, nx, ny, nz int) error {
+	k, ok := rt.kernels[kernel]
+	if !ok || !rt.configured {
+		return fmt.Errorf("slwgpu: kernel %s not added and configured", kernel)
+	}
+	if rt.encoder == nil {
+		enc, err := rt.Device.CreateCommandEncoder(nil)
+		if err != nil {
+			return err
+		}
+		rt.encoder = enc
+	}
+	pass := rt.encoder.BeginComputePass(nil)
+	pass.SetPipeline(k.pipeline)
+	for set, bg := range k.groups {
+		pass.SetBindGroup(uint32(set), bg, nil)
+	}
+	pass.DispatchWorkgroups(uint32(nx), uint32(ny), uint32(nz))
+	pass.End()
+	pass.Release()
+	return nil
+}
+
+// Barrier does nothing, as each Dispatch is a separate compute pass,
+// and WebGPU makes the writes of each pass visible to the next ones.
+func (rt *Runtime) Barrier() error {
+	return nil
+}
+
+// submit finishes the current command encoder, if any, and submits it.
+func (rt *Runtime) submit() error {
+	if rt.encoder == nil {
+		return nil
+	}
+	enc := rt.encoder
+	rt.encoder = nil
+	cmd, err := enc.Finish(nil)
+	enc.Release()
+	if err != nil {
+		return err
+	}
+	rt.Queue.Submit(cmd)
+	cmd.Release()
+	return nil
+}
+
+func (rt *Runtime) Wait() error {
+	if rt.encoder == nil {
+		return nil
+	}
+	if err := rt.submit(); err != nil {
+		return err
+	}
+	rt.Device.Poll(true, nil)
+	return nil
+}
+
+func (rt *Runtime) Readback(name string, data []byte) error {
+	b, err := rt.buffer(name, data)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return rt.Wait()
+	}
+	if rt.encoder == nil {
+		enc, err := rt.Device.CreateCommandEncoder(nil)
+		if err != nil {
+			return err
+		}
+		rt.encoder = enc
+	}
+	sz := bufferSize(b.spec)
+	rt.encoder.CopyBufferToBuffer(b.buf, 0, b.staging, 0, sz)
+	if err := rt.submit(); err != nil {
+		return err
+	}
+	var status wgpu.BufferMapAsyncStatus
+	b.staging.MapAsync(wgpu.MapModeRead, 0, sz, func(st wgpu.BufferMapAsyncStatus) {
+		status = st
+	})
+	rt.Device.Poll(true, nil)
+	if status != wgpu.BufferMapAsyncStatusSuccess {
+		return fmt.Errorf("slwgpu: buffer %s: mapping for readback failed: %v", name, status)
+	}
+	copy(data, b.staging.GetMappedRange(0, uint(sz)))
+	b.staging.Unmap()
+	return nil
+}
+
+// Release releases all of the WebGPU resources.
+func (rt *Runtime) Release() {
+	if rt.encoder != nil {
+		rt.encoder.Release()
+		rt.encoder = nil
+	}
+	for _, k := range rt.kernels {
+		for _, bg := range k.groups {
+			bg.Release()
+		}
+		if k.pipeline != nil {
+			k.pipeline.Release()
+		}
+	}
+	for _, b := range rt.bufs {
+		if b.buf != nil {
+			b.buf.Release()
+			b.staging.Release()
+		}
+	}
+	rt.Queue.Release()
+	rt.Device.Release()
+	rt.Adapter.Release()
+	rt.Instance.Release()
+}
+
+var (
+	includeRe = regexp.MustCompile(`(?m)^\s*#include\s+"([^"]+)"\s*$`)
+	bindingRe = regexp.MustCompile(`@group\((\d+)\)\s*@binding\((\d+)\)\s*var\s*<\s*storage\b`)
+)
+
+// ReadWGSL returns the WGSL source of the given file, with its #include
+// lines, e.g., for //gosl: uses regions, replaced by the included files,
+// relative to its directory, each only once, as WGSL has no preprocessor.
+func ReadWGSL(file string) (string, error) {
+	return readWGSL(file, map[string]bool{})
+}
+
+func readWGSL(file string, done map[string]bool) (string, error) {
+	done[filepath.Clean(file)] = true
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var rerr error
+	src := includeRe.ReplaceAllStringFunc(string(b), func(ln string) string {
+		inc := filepath.Join(filepath.Dir(file), includeRe.FindStringSubmatch(ln)[1])
+		if done[filepath.Clean(inc)] || rerr != nil {
+			return ""
+		}
+		isrc, err := readWGSL(inc, done)
+		if err != nil {
+			rerr = err
+		}
+		return isrc
+	})
+	return src, rerr
+}
+
+// Bindings returns the bindings of the storage buffers declared in the
+// given WGSL source, by set (group).
+func Bindings(src string) map[int][]int {
+	binds := map[int][]int{}
+	for _, m := range bindingRe.FindAllStringSubmatch(src, -1) {
+		set, _ := strconv.Atoi(m[1])
+		bi, _ := strconv.Atoi(m[2])
+		binds[set] = append(binds[set], bi)
+	}
+	return binds
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+package slint has integer helper functions that gosl translates into
+the HLSL (and WGSL and GLSL) min, max and clamp intrinsics, so that the
+same code can be used on the CPU and GPU without importing another
+package just for them, e.g., slint.MinInt(a, b) is min(a, b).
+
+The Go min and max builtins are also translated, for ints and floats.
+*/
+package slint
+
+// Integer is the constraint for the integer types of the functions,
+// including named types, e.g., type NeuronFlags int32.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// MinInt returns the smaller of a and b.
+func MinInt[T Integer](a, b T) T {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// MaxInt returns the larger of a and b.
+func MaxInt[T Integer](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Clamp returns x clamped to the range from lo to hi, inclusive,
+// which is hi if hi < lo, as for the HLSL clamp.
+func Clamp[T Integer](x, lo, hi T) T {
+	return MinInt(MaxInt(x, lo), hi)
+}
@@ -0,0 +1,25 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slint
+
+import "testing"
+
+func TestMinMax(t *testing.T) {
+	if MinInt(3, -2) != -2 || MaxInt(3, -2) != 3 {
+		t.Errorf("MinInt, MaxInt: got %d, %d", MinInt(3, -2), MaxInt(3, -2))
+	}
+	if MinInt[uint32](3, 7) != 3 || MaxInt[uint32](3, 7) != 7 {
+		t.Error("MinInt, MaxInt of uint32 wrong")
+	}
+	type flags int32
+	if MaxInt(flags(1), flags(4)) != flags(4) {
+		t.Error("MaxInt of named type wrong")
+	}
+	for _, tc := range [][4]int32{{5, 0, 10, 5}, {-1, 0, 10, 0}, {11, 0, 10, 10}, {5, 6, 4, 4}} {
+		if got := Clamp(tc[0], tc[1], tc[2]); got != tc[3] {
+			t.Errorf("Clamp(%d, %d, %d): got %d, want %d", tc[0], tc[1], tc[2], got, tc[3])
+		}
+	}
+}
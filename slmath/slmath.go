@@ -0,0 +1,117 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slmath
+
+import (
+	"math"
+)
+
+// These are Go versions of the same math functions available in
+// slmath.hlsl, which only use additions, multiplications and bit
+// operations, which are correctly rounded on both CPU and GPU, so that
+// their results are identical.  Each product is explicitly rounded to
+// float32, so that the Go compiler does not fuse it with an addition
+// into an FMA instruction, as the precise variables prevent in HLSL.
+// The exported functions use them when built with the slmathgpu tag,
+// and the standard float32 math otherwise.
+
+// gpuExp is a quartic spline approximation to the Exp function, by
+// N.N. Schraudolph, as in math32.FastExp, with the overflow range
+// returning +Inf.
+func gpuExp(x float32) float32 {
+	if x <= -88.02969 {
+		return 0
+	}
+	if x >= 88.72283 {
+		return float32(math.Inf(1))
+	}
+	i := int32(float32(12102203*x)) + 127*(1<<23)
+	m := i >> 7 & 0xFFFF // copy mantissa
+	i += (((((((((((3537 * m) >> 16) + 13668) * m) >> 18) + 15817) * m) >> 14) - 80470) * m) >> 11)
+	return math.Float32frombits(uint32(i))
+}
+
+// gpuLog is the natural logarithm, as in the Cephes logf, for the
+// normal positive values of x, -Inf for 0, and NaN for negative values.
+func gpuLog(x float32) float32 {
+	bits := math.Float32bits(x)
+	switch {
+	case x == 0:
+		return float32(math.Inf(-1))
+	case x < 0:
+		return float32(math.NaN())
+	case bits>>23&0xFF == 0xFF: // +Inf or NaN
+		return x
+	}
+	e := int32(bits>>23&0xFF) - 126
+	m := math.Float32frombits(bits&0x807FFFFF | 0x3F000000) // in [0.5, 1)
+	if m < 0.70710678 {
+		e--
+		m = m + m - 1
+	} else {
+		m = m - 1
+	}
+	z := float32(m * m)
+	p := float32(7.0376836292e-2*m) - 1.1514610310e-1
+	p = float32(p*m) + 1.1676998740e-1
+	p = float32(p*m) - 1.2420140846e-1
+	p = float32(p*m) + 1.4249322787e-1
+	p = float32(p*m) - 1.6668057665e-1
+	p = float32(p*m) + 2.0000714765e-1
+	p = float32(p*m) - 2.4999993993e-1
+	p = float32(p*m) + 3.3333331174e-1
+	y := float32(float32(m*z) * p)
+	fe := float32(e)
+	y += float32(-2.12194440e-4 * fe)
+	y += float32(-0.5 * z)
+	r := m + y
+	return r + float32(0.693359375*fe)
+}
+
+// gpuRecip returns 1 / x, with an initial estimate from the bits of x,
+// refined by Newton iterations, instead of a division, which is not
+// correctly rounded on the GPU.
+func gpuRecip(x float32) float32 {
+	r := math.Float32frombits(0x7EF311C7 - math.Float32bits(x))
+	for range 3 {
+		r = float32(r * (2 - float32(x*r)))
+	}
+	return r
+}
+
+// gpuPow returns x to the power y, as Exp(y * Log(x)), for positive x,
+// with Pow(x, 0) = 1, and Pow(0, y) = 0 for y > 0.
+func gpuPow(x, y float32) float32 {
+	if y == 0 {
+		return 1
+	}
+	if x == 0 && y > 0 {
+		return 0
+	}
+	return gpuExp(float32(y * gpuLog(x)))
+}
+
+// gpuLogistic returns the logistic sigmoid 1 / (1 + Exp(-x))
+func gpuLogistic(x float32) float32 {
+	if x <= -88 {
+		return 0
+	}
+	return gpuRecip(1 + gpuExp(-x))
+}
+
+// gpuTanh returns the hyperbolic tangent 1 - 2 / (Exp(2x) + 1),
+// or x - x^3 / 3 for small x, which avoids the cancellation.
+func gpuTanh(x float32) float32 {
+	if x > -0.01 && x < 0.01 {
+		return x - float32(float32(float32(x*x)*x)*0.33333334)
+	}
+	if x >= 9 {
+		return 1
+	}
+	if x <= -9 {
+		return -1
+	}
+	return 1 - float32(2*gpuRecip(gpuExp(2*x)+1))
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Original file is in Go package: github.com/emer/gosl/v2/slmath
+// See README.md there for documentation.
+
+// These math functions only use additions, multiplications and bit
+// operations, which are correctly rounded, and precise variables, which
+// prevent fusing them into FMA instructions, so that their results are
+// identical to those of the Go versions in slmath.go, built with the
+// slmathgpu tag.
+
+#ifndef __SLMATH_HLSL__
+#define __SLMATH_HLSL__
+
+// SlmathExp is a quartic spline approximation to the Exp function, by
+// N.N. Schraudolph, as in math32.FastExp, with the overflow range
+// returning +Inf.
+float SlmathExp(float x) {
+	if (x <= -88.02969) {
+		return 0;
+	}
+	if (x >= 88.72283) {
+		return asfloat(0x7f800000);
+	}
+	precise float xs = 12102203.0 * x;
+	int i = int(xs) + 127 * (1 << 23);
+	int m = (i >> 7) & 0xFFFF; // copy mantissa
+	i += (((((((((((3537 * m) >> 16) + 13668) * m) >> 18) + 15817) * m) >> 14) - 80470) * m) >> 11);
+	return asfloat(uint(i));
+}
+
+// SlmathLog is the natural logarithm, as in the Cephes logf, for the
+// normal positive values of x, -Inf for 0, and NaN for negative values.
+float SlmathLog(float x) {
+	uint bits = asuint(x);
+	if (x == 0) {
+		return asfloat(0xff800000);
+	}
+	if (x < 0) {
+		return asfloat(0x7fc00000);
+	}
+	if (((bits >> 23) & 0xFF) == 0xFF) {
+		return x;
+	}
+	int e = int((bits >> 23) & 0xFF) - 126;
+	precise float m = asfloat((bits & 0x807FFFFF) | 0x3F000000);
+	if (m < 0.70710678) {
+		e--;
+		m = m + m - 1;
+	} else {
+		m = m - 1;
+	}
+	precise float z = m * m;
+	precise float p = 7.0376836292e-2 * m - 1.1514610310e-1;
+	p = p * m + 1.1676998740e-1;
+	p = p * m - 1.2420140846e-1;
+	p = p * m + 1.4249322787e-1;
+	p = p * m - 1.6668057665e-1;
+	p = p * m + 2.0000714765e-1;
+	p = p * m - 2.4999993993e-1;
+	p = p * m + 3.3333331174e-1;
+	precise float y = m * z * p;
+	precise float fe = float(e);
+	y += -2.12194440e-4 * fe;
+	y += -0.5 * z;
+	precise float r = m + y;
+	return r + 0.693359375 * fe;
+}
+
+// SlmathRecip returns 1 / x, with an initial estimate from the bits of x,
+// refined by Newton iterations, instead of a division, which is not
+// correctly rounded on the GPU.
+float SlmathRecip(float x) {
+	precise float r = asfloat(0x7EF311C7 - asuint(x));
+	for (int k = 0; k < 3; k++) {
+		r = r * (2 - x * r);
+	}
+	return r;
+}
+
+// SlmathPow returns x to the power y, as Exp(y * Log(x)), for positive x,
+// with Pow(x, 0) = 1, and Pow(0, y) = 0 for y > 0.
+float SlmathPow(float x, float y) {
+	if (y == 0) {
+		return 1;
+	}
+	if (x == 0 && y > 0) {
+		return 0;
+	}
+	precise float yl = y * SlmathLog(x);
+	return SlmathExp(yl);
+}
+
+// SlmathLogistic returns the logistic sigmoid 1 / (1 + Exp(-x))
+float SlmathLogistic(float x) {
+	if (x <= -88) {
+		return 0;
+	}
+	precise float d = 1 + SlmathExp(-x);
+	return SlmathRecip(d);
+}
+
+// SlmathTanh returns the hyperbolic tangent 1 - 2 / (Exp(2x) + 1),
+// or x - x^3 / 3 for small x, which avoids the cancellation.
+float SlmathTanh(float x) {
+	if (x > -0.01 && x < 0.01) {
+		precise float x3 = x * x * x * 0.33333334;
+		return x - x3;
+	}
+	if (x >= 9) {
+		return 1;
+	}
+	if (x <= -9) {
+		return -1;
+	}
+	precise float d = SlmathExp(2 * x) + 1;
+	precise float t = 2 * SlmathRecip(d);
+	return 1 - t;
+}
+
+#endif // __SLMATH_HLSL__
@@ -0,0 +1,37 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build slmathgpu
+
+package slmath
+
+// GPU is true when built with the slmathgpu tag, where the functions
+// compute the same results as on the GPU, and false otherwise, where
+// they use the standard float32 math.
+const GPU = true
+
+// Exp returns e to the power x, with the FastExp approximation
+func Exp(x float32) float32 {
+	return gpuExp(x)
+}
+
+// Log returns the natural logarithm of x
+func Log(x float32) float32 {
+	return gpuLog(x)
+}
+
+// Pow returns x to the power y, as Exp(y * Log(x)), for positive x
+func Pow(x, y float32) float32 {
+	return gpuPow(x, y)
+}
+
+// Logistic returns the logistic sigmoid 1 / (1 + Exp(-x))
+func Logistic(x float32) float32 {
+	return gpuLogistic(x)
+}
+
+// Tanh returns the hyperbolic tangent of x
+func Tanh(x float32) float32 {
+	return gpuTanh(x)
+}
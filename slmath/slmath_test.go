@@ -0,0 +1,49 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slmath
+
+import (
+	"math"
+	"testing"
+)
+
+// relErr returns the error of v relative to the float64 value w
+func relErr(v float32, w float64) float64 {
+	return math.Abs(float64(v)-w) / max(math.Abs(w), 1e-6)
+}
+
+func TestGPUMath(t *testing.T) {
+	for _, x := range []float32{-80, -10, -1.5, -0.1, -0.005, 0, 0.02, 0.3, 1, 2.5, 10, 80} {
+		if e := relErr(gpuExp(x), math.Exp(float64(x))); e > 1e-4 {
+			t.Errorf("Exp(%g) relative error: %g", x, e)
+		}
+		if e := relErr(gpuLogistic(x), 1/(1+math.Exp(-float64(x)))); e > 1e-4 {
+			t.Errorf("Logistic(%g) relative error: %g", x, e)
+		}
+		if e := relErr(gpuTanh(x), math.Tanh(float64(x))); e > 1e-4 {
+			t.Errorf("Tanh(%g) relative error: %g", x, e)
+		}
+	}
+	for _, x := range []float32{1e-20, 0.01, 0.5, 0.70710677, 1, 1.5, 2, 10, 12345.6, 1e30} {
+		if e := math.Abs(float64(gpuLog(x)) - math.Log(float64(x))); e > 1e-6*max(1, math.Abs(math.Log(float64(x)))) {
+			t.Errorf("Log(%g) error: %g", x, e)
+		}
+		if e := relErr(gpuRecip(x), 1/float64(x)); e > 1e-6 {
+			t.Errorf("Recip(%g) relative error: %g", x, e)
+		}
+		if e := relErr(gpuPow(x, 0.5), math.Sqrt(float64(x))); e > 1e-3 {
+			t.Errorf("Pow(%g, 0.5) relative error: %g", x, e)
+		}
+	}
+	if !math.IsInf(float64(gpuExp(100)), 1) || gpuExp(-100) != 0 || !math.IsInf(float64(gpuLog(0)), -1) || !math.IsNaN(float64(gpuLog(-1))) {
+		t.Errorf("wrong special cases")
+	}
+	if gpuPow(3, 0) != 1 || gpuPow(0, 2) != 0 || gpuTanh(20) != 1 || gpuLogistic(-100) != 0 {
+		t.Errorf("wrong special cases of Pow, Tanh or Logistic")
+	}
+	if GPU && (Exp(1.5) != gpuExp(1.5) || Log(3) != gpuLog(3) || Tanh(0.5) != gpuTanh(0.5)) {
+		t.Errorf("the slmathgpu functions do not use the GPU versions")
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package slmeta has the metadata of the GPU kernels generated by gosl,
+for querying them at runtime, e.g., to list the kernels in a UI, or to
+check that the Go types match the layouts of the buffers in the
+generated code.  gosl -meta=gosl_meta.go writes the metadata into the
+model package, as a Meta value returned by GetMeta, and as JSON in the
+GoslMetaJSON constant:
+
+	meta := GetMeta()
+	for _, k := range meta.Kernels {
+		fmt.Println(k.Name, k.Workgroup, len(k.Buffers))
+	}
+	if err := meta.CheckSize("Neuron", unsafe.Sizeof(Neuron{})); err != nil {
+		...
+	}
+
+The Hash of each struct layout and of the whole interface changes
+whenever anything changes that requires regenerating the shaders or
+the buffer setup code, so it can be stored with saved buffer contents,
+e.g., checkpoints, and compared when they are loaded.
+*/
+package slmeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"slices"
+)
+
+// Meta is the metadata of the GPU kernels generated by gosl
+type Meta struct {
+
+	// hash of the interface between the CPU and the GPU code:
+	// the kernels and their bindings, and the struct layouts
+	Hash string
+
+	// the kernels, sorted by name
+	Kernels []Kernel
+
+	// layouts of the struct types in the buffers, including
+	// nested struct types, sorted by name
+	Structs []Struct
+}
+
+// Kernel is the metadata of a generated kernel
+type Kernel struct {
+
+	// name of the kernel, which is the shader file name without extension
+	Name string
+
+	// entry point function name
+	Entry string
+
+	// workgroup size from the numthreads attribute
+	Workgroup [3]int
+
+	// path to the compiled SPIR-V file, relative to where gosl was run
+	SPV string
+
+	// buffers declared in the kernel, in set, binding order
+	Buffers []Buffer
+
+	// workgroup sizes of the -autotune variants of the kernel, if any
+	Variants []int `json:",omitempty"`
+
+	// path to the compiled SPIR-V file of the test mode variant of the
+	// kernel, named as in TestName, without the Train buffers, if any
+	TestSPV string `json:",omitempty"`
+}
+
+// Buffer is a buffer binding of a kernel
+type Buffer struct {
+	Name    string
+	Set     int
+	Binding int
+
+	// kind of buffer, e.g., RWStructuredBuffer
+	Kind string
+
+	// element type, e.g., Neuron
+	Type string `json:",omitempty"`
+
+	// only bound in the training mode, and not in the test mode
+	// variant of the kernel, e.g., the buffers used for learning
+	Train bool `json:",omitempty"`
+}
+
+// Struct is the GPU layout of a struct type
+type Struct struct {
+	Name string
+
+	// size in bytes on the GPU
+	Size int64
+
+	// GPU fields, in order, excluding CPU-only fields
+	Fields []Field
+
+	// hash of the layout
+	Hash string
+}
+
+// Field is the GPU layout of a struct field
+type Field struct {
+	Name   string
+	Type   string
+	Offset int64
+	Size   int64
+}
+
+// Parse parses the Meta from the given JSON, e.g., GoslMetaJSON
+func Parse(b []byte) (*Meta, error) {
+	m := &Meta{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("slmeta: %w", err)
+	}
+	return m, nil
+}
+
+// JSON returns the Meta as indented JSON
+func (m *Meta) JSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "\t")
+}
+
+// Kernel returns the kernel with given name, or nil if none
+func (m *Meta) Kernel(name string) *Kernel {
+	i := slices.IndexFunc(m.Kernels, func(k Kernel) bool { return k.Name == name })
+	if i < 0 {
+		return nil
+	}
+	return &m.Kernels[i]
+}
+
+// Struct returns the struct type with given name, or nil if none
+func (m *Meta) Struct(name string) *Struct {
+	i := slices.IndexFunc(m.Structs, func(s Struct) bool { return s.Name == name })
+	if i < 0 {
+		return nil
+	}
+	return &m.Structs[i]
+}
+
+// Buffer returns the buffer with given name, or nil if none
+func (k *Kernel) Buffer(name string) *Buffer {
+	i := slices.IndexFunc(k.Buffers, func(b Buffer) bool { return b.Name == name })
+	if i < 0 {
+		return nil
+	}
+	return &k.Buffers[i]
+}
+
+// TestName returns the name of the test mode variant of the kernel with
+// given name, e.g., cycle_test, which does not bind the Train buffers.
+func TestName(kernel string) string {
+	return kernel + "_test"
+}
+
+// Mode returns the path to the SPIR-V file and the buffers of the kernel
+// for the training or test mode, for creating the pipeline and binding
+// the buffers at runtime.  In test mode, a kernel with a test mode
+// variant does not bind the Train buffers, which reduces the number of
+// descriptors, and ensures that they are not written.  A kernel without
+// a test mode variant is the same in both modes.
+func (k *Kernel) Mode(test bool) (string, []Buffer) {
+	if !test || k.TestSPV == "" {
+		return k.SPV, k.Buffers
+	}
+	var bufs []Buffer
+	for _, b := range k.Buffers {
+		if !b.Train {
+			bufs = append(bufs, b)
+		}
+	}
+	return k.TestSPV, bufs
+}
+
+// CheckSize returns an error if the struct type with given name is not
+// in the Meta, or its size on the GPU differs from the given size of the
+// Go type, e.g., unsafe.Sizeof(Neuron{}), which happens when the Go type
+// has changed since the shaders were generated.  Types with CPU-only
+// fields are larger in Go.
+func (m *Meta) CheckSize(name string, size uintptr) error {
+	s := m.Struct(name)
+	if s == nil {
+		return fmt.Errorf("slmeta: struct type %s is not in the generated GPU code", name)
+	}
+	if int64(size) != s.Size {
+		return fmt.Errorf("slmeta: struct type %s is %d bytes in Go, and %d bytes in the generated GPU code: regenerate the shaders with gosl", name, size, s.Size)
+	}
+	return nil
+}
+
+// SetHashes sets the Hash of each struct and of the Meta
+func (m *Meta) SetHashes() {
+	for i := range m.Structs {
+		m.Structs[i].Hash = m.Structs[i].LayoutHash()
+	}
+	m.Hash = m.InterfaceHash()
+}
+
+// LayoutHash returns the hash of the layout of the struct,
+// as a hexadecimal FNV-1a 64 bit hash.
+func (s *Struct) LayoutHash() string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s %d\n", s.Name, s.Size)
+	for _, f := range s.Fields {
+		fmt.Fprintf(h, "%s %s %d %d\n", f.Name, f.Type, f.Offset, f.Size)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// InterfaceHash returns the hash of the interface between the CPU and
+// the GPU code: the entry points, workgroup sizes and bindings of the
+// kernels, and the struct layouts, as a hexadecimal FNV-1a 64 bit hash.
+func (m *Meta) InterfaceHash() string {
+	h := fnv.New64a()
+	for _, k := range m.Kernels {
+		fmt.Fprintf(h, "kernel %s %s %v\n", k.Name, k.Entry, k.Workgroup)
+		for _, b := range k.Buffers {
+			fmt.Fprintf(h, "%s %d %d %s %s\n", b.Name, b.Set, b.Binding, b.Kind, b.Type)
+			if b.Train {
+				fmt.Fprintf(h, "train %s\n", b.Name)
+			}
+		}
+	}
+	for i := range m.Structs {
+		fmt.Fprintf(h, "struct %s\n", m.Structs[i].LayoutHash())
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
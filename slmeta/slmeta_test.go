@@ -0,0 +1,71 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slmeta
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testMeta() *Meta {
+	m := &Meta{
+		Kernels: []Kernel{{Name: "axon", Entry: "main", Workgroup: [3]int{64, 1, 1}, SPV: "shaders/axon.spv",
+			Buffers: []Buffer{{Name: "Neurons", Set: 1, Binding: 0, Kind: "RWStructuredBuffer", Type: "Neuron"}}}},
+		Structs: []Struct{{Name: "Neuron", Size: 16,
+			Fields: []Field{{Name: "Act", Type: "float32", Offset: 0, Size: 4}, {Name: "Ge", Type: "float32", Offset: 4, Size: 4},
+				{Name: "Gi", Type: "float32", Offset: 8, Size: 4}, {Name: "pad", Type: "float32", Offset: 12, Size: 4}}}},
+	}
+	m.SetHashes()
+	return m
+}
+
+func TestMeta(t *testing.T) {
+	m := testMeta()
+	if k := m.Kernel("axon"); k == nil || k.Buffer("Neurons") == nil || k.Buffer("Synapses") != nil || m.Kernel("cycle") != nil {
+		t.Errorf("wrong Kernel / Buffer lookup")
+	}
+	if m.CheckSize("Neuron", 16) != nil || m.CheckSize("Neuron", 20) == nil || m.CheckSize("Synapse", 16) == nil {
+		t.Errorf("wrong CheckSize")
+	}
+	js, err := m.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm, err := Parse(js)
+	if err != nil || !reflect.DeepEqual(pm, m) {
+		t.Errorf("JSON round trip: %v:\n%s", err, js)
+	}
+
+	// any change in a layout or binding changes the hashes
+	m2 := testMeta()
+	m2.Structs[0].Fields[1].Name = "Gi"
+	m2.Structs[0].Fields[2].Name = "Ge"
+	m2.SetHashes()
+	if m2.Structs[0].Hash == m.Structs[0].Hash || m2.Hash == m.Hash {
+		t.Errorf("layout change did not change the hashes")
+	}
+	m3 := testMeta()
+	m3.Kernels[0].Buffers[0].Set = 2
+	m3.SetHashes()
+	if m3.Structs[0].Hash != m.Structs[0].Hash || m3.Hash == m.Hash {
+		t.Errorf("binding change did not change only the interface hash")
+	}
+}
+
+func TestMode(t *testing.T) {
+	m := testMeta()
+	k := &m.Kernels[0]
+	if spv, bufs := k.Mode(true); spv != k.SPV || len(bufs) != 1 {
+		t.Errorf("kernel without test mode variant: %s %v", spv, bufs)
+	}
+	k.Buffers = append(k.Buffers, Buffer{Name: "Synapses", Set: 2, Binding: 0, Kind: "RWStructuredBuffer", Type: "Synapse", Train: true})
+	k.TestSPV = "shaders/" + TestName("axon") + ".spv"
+	if spv, bufs := k.Mode(false); spv != k.SPV || len(bufs) != 2 {
+		t.Errorf("wrong train mode: %s %v", spv, bufs)
+	}
+	if spv, bufs := k.Mode(true); spv != "shaders/axon_test.spv" || len(bufs) != 1 || bufs[0].Name != "Neurons" {
+		t.Errorf("wrong test mode: %s %v", spv, bufs)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// gosl: a local pointer variable defined as the address of an element
+// or field, e.g., ly := &lays[nrn.LayIndex], or as a copy of another
+// pointer, e.g., n := nrn, is an alias of that element in Go, but HLSL
+// has no pointers, so declaring it would make a copy, and all writes
+// through it, including by methods called on it, would be silently lost.
+// Instead, the definition is omitted, and each use of the variable is
+// printed as the element expression, e.g., lays[nrn.LayIndex].CycleNeuron(ge),
+// which operates on the element itself.  This is only valid if the
+// variables of the index expressions, e.g., nrn.LayIndex, are not
+// assigned within the scope of the alias, so that it always refers to
+// the same element, which is reported as an error otherwise.
+
+// elemAliases returns the local pointer variables of the given function
+// that are aliases of another expression, with that expression.
+func (p *printer) elemAliases(d *ast.FuncDecl) map[types.Object]ast.Expr {
+	if p.pkg == nil || p.pkg.TypesInfo == nil || d.Body == nil {
+		return nil
+	}
+	var aliases map[types.Object]ast.Expr
+	ast.Inspect(d.Body, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || as.Tok != token.DEFINE || len(as.Lhs) != 1 || len(as.Rhs) != 1 {
+			return true
+		}
+		id, ok := as.Lhs[0].(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj, ok := p.pkg.TypesInfo.Defs[id].(*types.Var)
+		if !ok {
+			return true
+		}
+		if _, isPtr := obj.Type().Underlying().(*types.Pointer); !isPtr {
+			return true
+		}
+		var elem ast.Expr
+		switch x := stripParensAlways(as.Rhs[0]).(type) {
+		case *ast.UnaryExpr:
+			if x.Op == token.AND {
+				elem = stripParensAlways(x.X)
+			}
+		case *ast.Ident:
+			elem = x
+		}
+		if elem == nil || !isElemExpr(elem) {
+			return true
+		}
+		if aliases == nil {
+			aliases = map[types.Object]ast.Expr{}
+		}
+		aliases[obj] = elem
+		return true
+	})
+	for obj, elem := range aliases {
+		if mod := p.aliasModified(d, obj, elem, aliases); mod != "" {
+			fmt.Printf("%s:\n\tgosl: the pointer %s to %s is a copy in HLSL, where writes through it are lost, because %s is assigned within its scope, which would change the element it refers to: use a separate index variable, or the element directly\n", p.pkg.Fset.PositionFor(obj.Pos(), true).String(), obj.Name(), types.ExprString(elem), mod)
+			delete(aliases, obj)
+		}
+	}
+	return aliases
+}
+
+// isElemExpr returns true if the given expression is a variable, or an
+// element or field of one, e.g., lays[nrn.LayIndex].Params.
+func isElemExpr(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return isElemExpr(x.X)
+	case *ast.IndexExpr:
+		return isElemExpr(x.X)
+	case *ast.StarExpr:
+		return isElemExpr(x.X)
+	case *ast.ParenExpr:
+		return isElemExpr(x.X)
+	}
+	return false
+}
+
+// isAliasDef returns true if the given statement is the definition
+// of an alias, which is not printed.
+func (p *printer) isAliasDef(s ast.Stmt) bool {
+	if len(p.aliases) == 0 {
+		return false
+	}
+	as, ok := s.(*ast.AssignStmt)
+	if !ok || as.Tok != token.DEFINE || len(as.Lhs) != 1 {
+		return false
+	}
+	id, ok := as.Lhs[0].(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = p.aliases[p.pkg.TypesInfo.Defs[id]]
+	return ok
+}
+
+// aliasOf returns the expression that the given identifier is an alias
+// of, if it is one.
+func (p *printer) aliasOf(x *ast.Ident) (ast.Expr, bool) {
+	if len(p.aliases) == 0 {
+		return nil, false
+	}
+	ax, ok := p.aliases[p.pkg.TypesInfo.Uses[x]]
+	return ax, ok
+}
+
+// aliasModified returns the expression that modifies a variable of the
+// index expressions of the given alias within its scope, after its
+// definition, or "" if there is none.  Only assignments and increments
+// are checked: functions and methods called with pointers are assumed
+// not to change the index fields, e.g., nrn.LayIndex.
+func (p *printer) aliasModified(d *ast.FuncDecl, obj types.Object, elem ast.Expr, aliases map[types.Object]ast.Expr) string {
+	var idxs []string
+	ast.Inspect(elem, func(n ast.Node) bool {
+		if ix, ok := n.(*ast.IndexExpr); ok {
+			ast.Inspect(ix.Index, func(n ast.Node) bool {
+				if x, ok := n.(ast.Expr); ok && isElemExpr(x) {
+					if pth := p.elemPath(x, aliases); pth != "" {
+						idxs = append(idxs, pth)
+					}
+					return false
+				}
+				return true
+			})
+		}
+		return true
+	})
+	if len(idxs) == 0 {
+		return ""
+	}
+	start, end := obj.Pos(), obj.Parent().End()
+	mod := ""
+	modified := func(x ast.Expr) {
+		if mod != "" || x.Pos() < start || x.Pos() >= end {
+			return
+		}
+		pth := p.elemPath(stripParensAlways(x), aliases)
+		if pth == "" {
+			return
+		}
+		for _, ip := range idxs {
+			if pathPrefix(pth, ip) || pathPrefix(ip, pth) {
+				mod = types.ExprString(x)
+				return
+			}
+		}
+	}
+	ast.Inspect(d.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if x.Tok != token.DEFINE {
+				for _, lhs := range x.Lhs {
+					modified(lhs)
+				}
+			}
+		case *ast.IncDecStmt:
+			modified(x.X)
+		}
+		return true
+	})
+	return mod
+}
+
+// elemPath returns the path of the given variable, or element or field
+// of one, e.g., nrn.LayIndex, with the variable object as the root,
+// the aliases replaced with their expressions, and any index as [], or
+// "" if it is not a variable.
+func (p *printer) elemPath(x ast.Expr, aliases map[types.Object]ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.Ident:
+		obj, ok := p.pkg.TypesInfo.Uses[x].(*types.Var)
+		if !ok {
+			return ""
+		}
+		if ax, ok := aliases[obj]; ok {
+			return p.elemPath(ax, aliases)
+		}
+		return fmt.Sprintf("%p", obj)
+	case *ast.SelectorExpr:
+		if pth := p.elemPath(x.X, aliases); pth != "" {
+			return pth + "." + x.Sel.Name
+		}
+	case *ast.IndexExpr:
+		if pth := p.elemPath(x.X, aliases); pth != "" {
+			return pth + "[]"
+		}
+	case *ast.StarExpr:
+		return p.elemPath(x.X, aliases)
+	case *ast.ParenExpr:
+		return p.elemPath(x.X, aliases)
+	}
+	return ""
+}
+
+// pathPrefix returns true if the element path a contains b,
+// i.e., b is a prefix of a, at a field or index boundary.
+func pathPrefix(a, b string) bool {
+	if !strings.HasPrefix(a, b) {
+		return false
+	}
+	return len(a) == len(b) || a[len(b)] == '.' || a[len(b)] == '['
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// Slice parameters of functions, e.g., nrns []Neuron, are buffers of a
+// runtime length in HLSL: RWStructuredBuffer<Neuron> nrns, or
+// StructuredBuffer<Neuron> nrns if the parameter is named in a
+// //gosl: readonly directive in the doc comment of the function:
+//
+//	// gosl: readonly lays
+//	func Update(nrns []Neuron, lays []Layer, i uint32)
+//
+// The elements are indexed as in Go, e.g., nrns[i].Act, and the
+// arguments must be buffers of the same kind, e.g., passed by the
+// kernel entry points (see KernelEntry in gosl).  There are no slice
+// types in the other targets, where the slice parameters are reported.
+
+// ReadOnlyParams returns the names of the parameters in the //gosl:
+// readonly directives of the given doc comment of a function, accepting
+// the // gosl: form that gofmt produces for doc comments.
+func ReadOnlyParams(doc *ast.CommentGroup) map[string]bool {
+	if doc == nil {
+		return nil
+	}
+	var ro map[string]bool
+	for _, c := range doc.List {
+		txt := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		nms, ok := strings.CutPrefix(txt, "gosl: readonly ")
+		if !ok {
+			continue
+		}
+		if ro == nil {
+			ro = map[string]bool{}
+		}
+		for _, nm := range strings.Fields(nms) {
+			ro[nm] = true
+		}
+	}
+	return ro
+}
+
+// sliceParam returns the element type of the given parameter if it is
+// a slice, and nil otherwise.
+func sliceParam(par *ast.Field) ast.Expr {
+	if at, ok := stripParensAlways(par.Type).(*ast.ArrayType); ok && at.Len == nil {
+		return at.Elt
+	}
+	return nil
+}
+
+// bufferParams prints the given slice parameter, with given element type,
+// as a buffer parameter for each of its names (see ReadOnlyParams).
+func (p *printer) bufferParams(par *ast.Field, elt ast.Expr) {
+	if p.glsl() || p.metal() {
+		p.transError(par.Pos(), "slice parameters are only supported in HLSL, as StructuredBuffer parameters")
+	}
+	for i, nm := range par.Names {
+		if i > 0 {
+			p.print(token.COMMA, blank)
+		}
+		if p.readOnly[nm.Name] {
+			p.print("StructuredBuffer<")
+		} else {
+			p.print("RWStructuredBuffer<")
+		}
+		p.expr(elt)
+		p.print(">", blank)
+		p.expr(nm)
+	}
+}
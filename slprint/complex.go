@@ -0,0 +1,197 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// gosl: complex64 values are translated into float2 values, with the real
+// part in x and the imaginary part in y, as for slcomplex.Complex.  The
+// + and - operators work as is on float2, while * and / are translated
+// into calls to the slcomplex functions, e.g., slcomplex.Mul(a, b), which
+// requires including slcomplex.hlsl.  Constants are written as float2
+// literals, and the complex, real and imag builtins are translated.
+// complex128 and math/cmplx are not supported, and are reported.
+
+// complexKind returns the kind of complex type of the given expression,
+// types.Complex64 or types.Complex128, or types.Invalid if not complex.
+func (p *printer) complexKind(x ast.Expr) types.BasicKind {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return types.Invalid
+	}
+	tp := p.pkg.TypesInfo.TypeOf(x)
+	if tp == nil {
+		return types.Invalid
+	}
+	bt, ok := tp.Underlying().(*types.Basic)
+	if !ok || bt.Info()&types.IsComplex == 0 {
+		return types.Invalid
+	}
+	if bt.Kind() == types.Complex128 {
+		return types.Complex128
+	}
+	return types.Complex64 // including untyped complex constants
+}
+
+// complexError reports an unsupported use of complex numbers, once per
+// line, except when measuring the size of nodes in RawFormat.
+func (p *printer) complexError(x ast.Node, msg string) {
+	if p.Config.Mode&RawFormat != 0 {
+		return
+	}
+	pos := p.pkg.Fset.PositionFor(x.Pos(), true)
+	key := fmt.Sprintf("%s:%d", pos.Filename, pos.Line)
+	if p.complexErrs[key] {
+		return
+	}
+	if p.complexErrs == nil {
+		p.complexErrs = map[string]bool{}
+	}
+	p.complexErrs[key] = true
+	p.transError(x.Pos(), "%s", msg)
+}
+
+// complexConst prints the given complex constant value as a float2
+func (p *printer) complexConst(val constant.Value) {
+	f32 := types.Typ[types.Float32]
+	p.print("float2(", ConstLiteral(constant.Real(val), f32), ", ", ConstLiteral(constant.Imag(val), f32), ")")
+}
+
+// complexExpr prints the given expression if it involves complex numbers
+// in a way that must be translated, returning false otherwise.
+func (p *printer) complexExpr(expr ast.Expr, depth int) bool {
+	switch expr.(type) {
+	case *ast.BinaryExpr, *ast.UnaryExpr, *ast.CallExpr, *ast.Ident, *ast.BasicLit, *ast.SelectorExpr:
+	default:
+		return false
+	}
+	kind := p.complexKind(expr)
+	if (p.wgsl() || p.glsl() || p.metal()) && kind != types.Invalid {
+		p.complexError(expr, "complex numbers are not supported in "+strings.ToUpper(p.Target))
+		return false
+	}
+	if kind == types.Complex128 {
+		p.complexError(expr, "complex128 is not supported: use complex64, which is translated into float2")
+		return false
+	}
+	if kind != types.Invalid {
+		if tv := p.pkg.TypesInfo.Types[expr]; tv.Value != nil {
+			p.complexConst(tv.Value)
+			return true
+		}
+	}
+	switch x := expr.(type) {
+	case *ast.BinaryExpr:
+		if p.complexKind(x.X) == types.Invalid {
+			return false
+		}
+		switch x.Op {
+		case token.MUL:
+			p.complexCall("slcomplex.Mul", depth, x.X, x.Y)
+		case token.QUO:
+			p.complexCall("slcomplex.Div", depth, x.X, x.Y)
+		case token.EQL:
+			p.complexCall("all", depth, &ast.BinaryExpr{X: x.X, OpPos: x.OpPos, Op: x.Op, Y: x.Y})
+		case token.NEQ:
+			p.complexCall("any", depth, &ast.BinaryExpr{X: x.X, OpPos: x.OpPos, Op: x.Op, Y: x.Y})
+		default:
+			return false
+		}
+		return true
+	case *ast.CallExpr:
+		return p.complexBuiltin(x, depth)
+	}
+	return false
+}
+
+// complexCall prints a call to the given function with the given args
+func (p *printer) complexCall(fun string, depth int, args ...ast.Expr) {
+	p.print(fun, token.LPAREN)
+	for i, a := range args {
+		if i > 0 {
+			p.print(token.COMMA, blank)
+		}
+		if b, ok := a.(*ast.BinaryExpr); ok && (b.Op == token.EQL || b.Op == token.NEQ) {
+			p.binaryExpr(b, token.LowestPrec, cutoff(b, depth+1), depth+1)
+			continue
+		}
+		p.expr0(a, depth+1)
+	}
+	p.print(token.RPAREN)
+}
+
+// complexBuiltin prints the complex, real and imag builtins, and
+// conversions to complex64, reporting calls to math/cmplx.
+func (p *printer) complexBuiltin(x *ast.CallExpr, depth int) bool {
+	fun := stripParensAlways(x.Fun)
+	if sel, ok := fun.(*ast.SelectorExpr); ok {
+		if id, ok := sel.X.(*ast.Ident); ok {
+			if pn, ok := p.pkg.TypesInfo.Uses[id].(*types.PkgName); ok && pn.Imported().Path() == "math/cmplx" {
+				p.complexError(x, "math/cmplx is not supported: use the slcomplex functions, e.g., slcomplex.Abs(slcomplex.FromComplex64(c))")
+			}
+		}
+		return false
+	}
+	id, ok := fun.(*ast.Ident)
+	if !ok || len(x.Args) == 0 {
+		return false
+	}
+	switch obj := p.pkg.TypesInfo.Uses[id].(type) {
+	case *types.Builtin:
+		switch obj.Name() {
+		case "complex":
+			p.complexCall("float2", depth, x.Args...)
+		case "real", "imag":
+			if p.complexKind(x.Args[0]) == types.Complex128 {
+				p.complexError(x, "complex128 is not supported: use complex64, which is translated into float2")
+			}
+			p.expr1(x.Args[0], token.HighestPrec, depth)
+			if obj.Name() == "real" {
+				p.print(token.PERIOD, "x")
+			} else {
+				p.print(token.PERIOD, "y")
+			}
+		default:
+			return false
+		}
+		return true
+	case *types.TypeName:
+		if p.complexKind(x) == types.Invalid {
+			return false
+		}
+		if p.complexKind(x.Args[0]) == types.Invalid {
+			p.complexError(x, "only complex values can be converted to complex64: use complex(re, 0) for real values")
+			return false
+		}
+		p.expr1(x.Args[0], token.HighestPrec, depth)
+		return true
+	}
+	return false
+}
+
+// complexAssign prints the *= and /= assignments of complex values,
+// e.g., a *= b as a = slcomplex.Mul(a, b), returning false otherwise.
+func (p *printer) complexAssign(s *ast.AssignStmt) bool {
+	if len(s.Lhs) != 1 || len(s.Rhs) != 1 || (s.Tok != token.MUL_ASSIGN && s.Tok != token.QUO_ASSIGN) {
+		return false
+	}
+	if p.complexKind(s.Lhs[0]) != types.Complex64 || p.wgsl() || p.glsl() || p.metal() {
+		return false
+	}
+	fun := "slcomplex.Mul"
+	if s.Tok == token.QUO_ASSIGN {
+		fun = "slcomplex.Div"
+	}
+	p.expr0(s.Lhs[0], 1)
+	p.print(blank, s.TokPos, token.ASSIGN, blank)
+	p.complexCall(fun, 1, s.Lhs[0], s.Rhs[0])
+	return true
+}
@@ -0,0 +1,333 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Package-level variables with initial values in HLSL are uniform
+// constants unless they are static, and their initializers are ignored,
+// so computed values, e.g., var TauFact = math32.Pow(2, -0.5), would be
+// uninitialized.  The float values of constant expressions, including
+// arithmetic and calls of the foldFuncs of the MathPackages, are instead
+// computed by gosl, as in Go, and printed as static const values, or
+// static if the variable is assigned in the package:
+//
+//	static const float TauFact = 0.7071067691;
+//
+// The other variables with initial values are declared static without
+// them, as they can use the functions of the file, which are not yet
+// declared, and are assigned in an init function at the end of the file,
+// named by InitGlobalsFunc, which gosl calls at the start of each kernel
+// that includes the file, as it runs package init functions in Go.  This
+// is reported, as it can be slow, e.g., for calls of package functions:
+//
+//	static float Offset;
+//	...
+//	void GoslInitGlobals_globals() {
+//		Offset = Bias(3);
+//	}
+
+// foldFuncs are the functions of the MathPackages whose calls with
+// constant arguments are computed by gosl, by name.
+var foldFuncs = map[string]func(a []float64) float64{
+	"Abs":   func(a []float64) float64 { return math.Abs(a[0]) },
+	"Sqrt":  func(a []float64) float64 { return math.Sqrt(a[0]) },
+	"Exp":   func(a []float64) float64 { return math.Exp(a[0]) },
+	"Exp2":  func(a []float64) float64 { return math.Exp2(a[0]) },
+	"Log":   func(a []float64) float64 { return math.Log(a[0]) },
+	"Log2":  func(a []float64) float64 { return math.Log2(a[0]) },
+	"Log10": func(a []float64) float64 { return math.Log10(a[0]) },
+	"Sin":   func(a []float64) float64 { return math.Sin(a[0]) },
+	"Cos":   func(a []float64) float64 { return math.Cos(a[0]) },
+	"Tan":   func(a []float64) float64 { return math.Tan(a[0]) },
+	"Asin":  func(a []float64) float64 { return math.Asin(a[0]) },
+	"Acos":  func(a []float64) float64 { return math.Acos(a[0]) },
+	"Atan":  func(a []float64) float64 { return math.Atan(a[0]) },
+	"Tanh":  func(a []float64) float64 { return math.Tanh(a[0]) },
+	"Floor": func(a []float64) float64 { return math.Floor(a[0]) },
+	"Ceil":  func(a []float64) float64 { return math.Ceil(a[0]) },
+	"Pow":   func(a []float64) float64 { return math.Pow(a[0], a[1]) },
+	"Atan2": func(a []float64) float64 { return math.Atan2(a[0], a[1]) },
+	"Mod":   func(a []float64) float64 { return math.Mod(a[0], a[1]) },
+	"Max":   func(a []float64) float64 { return math.Max(a[0], a[1]) },
+	"Min":   func(a []float64) float64 { return math.Min(a[0], a[1]) },
+}
+
+// isFloatType returns true if the given type is a float type
+func isFloatType(typ types.Type) bool {
+	bt, ok := typ.Underlying().(*types.Basic)
+	return ok && bt.Info()&types.IsFloat != 0
+}
+
+// roundTo returns the given value rounded to the given float type,
+// which is float32 unless it is a float64.
+func roundTo(v float64, typ types.Type) float64 {
+	if bt, ok := typ.Underlying().(*types.Basic); ok && (bt.Kind() == types.Float64 || bt.Kind() == types.UntypedFloat) {
+		return v
+	}
+	return float64(float32(v))
+}
+
+// packageVar returns the package-level variable defined by the given name,
+// or nil if it is not one.
+func (p *printer) packageVar(nm *ast.Ident) *types.Var {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return nil
+	}
+	v, ok := p.pkg.TypesInfo.Defs[nm].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Parent() != v.Pkg().Scope() {
+		return nil
+	}
+	return v
+}
+
+// varInit returns the initializer expression of the given package-level
+// variable, if any.
+func (p *printer) varInit(v *types.Var) ast.Expr {
+	for _, f := range p.pkg.Syntax {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.VAR {
+				continue
+			}
+			for _, s := range gd.Specs {
+				vs := s.(*ast.ValueSpec)
+				if len(vs.Values) != len(vs.Names) {
+					continue
+				}
+				for i, nm := range vs.Names {
+					if p.pkg.TypesInfo.Defs[nm] == v {
+						return vs.Values[i]
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isAssigned returns true if the given package-level variable is assigned,
+// incremented or has its address taken in the package.
+func (p *printer) isAssigned(v *types.Var) bool {
+	if p.assigned == nil {
+		p.assigned = map[types.Object]bool{}
+		mark := func(x ast.Expr) {
+			for {
+				switch xx := x.(type) {
+				case *ast.IndexExpr:
+					x = xx.X
+					continue
+				case *ast.SelectorExpr:
+					x = xx.X
+					continue
+				case *ast.ParenExpr:
+					x = xx.X
+					continue
+				case *ast.Ident:
+					if obj := p.pkg.TypesInfo.Uses[xx]; obj != nil {
+						p.assigned[obj] = true
+					}
+				}
+				return
+			}
+		}
+		for _, f := range p.pkg.Syntax {
+			ast.Inspect(f, func(n ast.Node) bool {
+				switch x := n.(type) {
+				case *ast.AssignStmt:
+					for _, l := range x.Lhs {
+						mark(l)
+					}
+				case *ast.IncDecStmt:
+					mark(x.X)
+				case *ast.UnaryExpr:
+					if x.Op == token.AND {
+						mark(x.X)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return p.assigned[v]
+}
+
+// foldFloat returns the float value of the given expression, if it is a
+// constant expression of arithmetic, conversions, calls of the foldFuncs
+// of the MathPackages, and other package-level variables with such
+// values, computed with the float32 rounding of Go, or false if not.
+func (p *printer) foldFloat(x ast.Expr, depth int) (float64, bool) {
+	tv, ok := p.pkg.TypesInfo.Types[x]
+	if !ok || depth > 32 {
+		return 0, false
+	}
+	if tv.Value != nil {
+		if tv.Value.Kind() != constant.Int && tv.Value.Kind() != constant.Float {
+			return 0, false
+		}
+		v, _ := constant.Float64Val(constant.ToFloat(tv.Value))
+		return roundTo(v, tv.Type), true
+	}
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return p.foldFloat(x.X, depth+1)
+	case *ast.UnaryExpr:
+		v, ok := p.foldFloat(x.X, depth+1)
+		if !ok || x.Op != token.SUB && x.Op != token.ADD {
+			return 0, false
+		}
+		if x.Op == token.SUB {
+			v = -v
+		}
+		return v, true
+	case *ast.BinaryExpr:
+		a, ok := p.foldFloat(x.X, depth+1)
+		if !ok || !isFloatType(tv.Type) {
+			return 0, false
+		}
+		b, ok := p.foldFloat(x.Y, depth+1)
+		if !ok {
+			return 0, false
+		}
+		switch x.Op {
+		case token.ADD:
+			return roundTo(a+b, tv.Type), true
+		case token.SUB:
+			return roundTo(a-b, tv.Type), true
+		case token.MUL:
+			return roundTo(a*b, tv.Type), true
+		case token.QUO:
+			return roundTo(a/b, tv.Type), true
+		}
+	case *ast.Ident:
+		v, ok := p.pkg.TypesInfo.Uses[x].(*types.Var)
+		if !ok || v.Pkg() == nil || v.Parent() != v.Pkg().Scope() || !isFloatType(v.Type()) || p.isAssigned(v) {
+			return 0, false
+		}
+		if init := p.varInit(v); init != nil {
+			return p.foldFloat(init, depth+1)
+		}
+	case *ast.CallExpr:
+		if ftv, ok := p.pkg.TypesInfo.Types[x.Fun]; ok && ftv.IsType() { // conversion
+			if len(x.Args) != 1 || !isFloatType(tv.Type) {
+				return 0, false
+			}
+			v, ok := p.foldFloat(x.Args[0], depth+1)
+			return roundTo(v, tv.Type), ok
+		}
+		sel, ok := x.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return 0, false
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return 0, false
+		}
+		pn, ok := p.pkg.TypesInfo.Uses[id].(*types.PkgName)
+		if !ok || !MathPackages[pn.Imported().Path()] {
+			return 0, false
+		}
+		fn, ok := p.pkg.TypesInfo.Uses[sel.Sel].(*types.Func)
+		if !ok || foldFuncs[fn.Name()] == nil {
+			return 0, false
+		}
+		if fn.Type().(*types.Signature).Params().Len() != len(x.Args) {
+			return 0, false
+		}
+		args := make([]float64, len(x.Args))
+		for i, a := range x.Args {
+			if args[i], ok = p.foldFloat(a, depth+1); !ok {
+				return 0, false
+			}
+		}
+		return roundTo(foldFuncs[fn.Name()](args), tv.Type), true
+	}
+	return 0, false
+}
+
+// InitGlobalsFunc returns the name of the function that initializes the
+// package-level variables of the given region, e.g., GoslInitGlobals_axon
+// for axon, which is defined at the end of its file if it has any
+// variables whose values are not computed by gosl.
+func InitGlobalsFunc(region string) string {
+	return "GoslInitGlobals_" + region
+}
+
+// globalVarSpec prints the given package-level var spec with values, in
+// HLSL, as static variables (see foldFloat), without the final semicolon,
+// returning false if it is not one, e.g., for groupshared variables.
+func (p *printer) globalVarSpec(s *ast.ValueSpec) bool {
+	if p.wgsl() || p.glsl() || p.metal() || p.groupShared || len(s.Values) != len(s.Names) {
+		return false
+	}
+	vars := make([]*types.Var, len(s.Names))
+	for i, nm := range s.Names {
+		if vars[i] = p.packageVar(nm); vars[i] == nil {
+			return false
+		}
+		if _, isArray := vars[i].Type().Underlying().(*types.Array); isArray {
+			return false
+		}
+	}
+	p.print(s.Pos())
+	for i, nm := range s.Names {
+		v := vars[i]
+		if i > 0 {
+			p.print(token.SEMICOLON, blank)
+		}
+		p.print("static", blank)
+		val, folded := 0.0, false
+		if isFloatType(v.Type()) {
+			val, folded = p.foldFloat(s.Values[i], 0)
+		}
+		if folded && !p.isAssigned(v) {
+			p.print(token.CONST, blank)
+		}
+		p.print(p.typeName(v.Type()), blank)
+		p.expr(nm)
+		if !folded {
+			p.globalInits = append(p.globalInits, &ast.ValueSpec{Names: []*ast.Ident{nm}, Values: []ast.Expr{s.Values[i]}})
+			fmt.Printf("%s:\n\tgosl: package-level var %s is initialized at the start of each kernel, by %s, because its value cannot be computed by gosl from constants and math functions\n", p.pkg.Fset.PositionFor(nm.Pos(), true).String(), nm.Name, p.initGlobalsFunc())
+			continue
+		}
+		p.print(blank, token.ASSIGN, blank)
+		p.print(ConstLiteral(constant.MakeFloat64(val), v.Type()))
+	}
+	return true
+}
+
+// initGlobalsFunc returns the InitGlobalsFunc of the file being printed,
+// whose name is that of its region.
+func (p *printer) initGlobalsFunc() string {
+	return InitGlobalsFunc(strings.TrimSuffix(filepath.Base(p.pos.Filename), ".go"))
+}
+
+// printGlobalInits prints the init function of the file, which assigns
+// the values of the globalInits, in order, if any.
+func (p *printer) printGlobalInits() {
+	if len(p.globalInits) == 0 {
+		return
+	}
+	p.print(newline, newline)
+	p.print(fmt.Sprintf("// %s initializes the package-level variables at the start of each kernel", p.initGlobalsFunc()), newline)
+	p.print(fmt.Sprintf("void %s() {", p.initGlobalsFunc()), indent)
+	for _, s := range p.globalInits {
+		p.print(newline)
+		p.expr(s.Names[0])
+		p.print(blank, token.ASSIGN, blank)
+		p.expr(s.Values[0])
+		p.print(token.SEMICOLON)
+	}
+	p.print(unindent, newline, "}")
+}
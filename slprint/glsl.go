@@ -0,0 +1,176 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/emer/gosl/v2/alignsl"
+)
+
+// gosl: with Config.Target = TargetGLSL, the Go code is printed as GLSL 450
+// instead of HLSL.  GLSL is close to HLSL, so most of the translation is
+// the same, with these differences: GLSL has no methods, so methods are
+// functions named Type_Method, with the receiver as the first parameter,
+// inout for pointer receivers, and are called as Type_Method(x, a).
+// GLSL has no typedef, so named types of basic types are replaced by the
+// basic type where used, as aliases are.  Vector types are vec2, ivec2,
+// and uvec2, etc, constants are const instead of static const, and
+// groupshared variables are shared.  Operands of shift and bitwise
+// operators are parenthesized as in WGSL (see wgslParens), as their
+// precedence in Go differs from C, and multiple variables defined
+// in one statement are defined in separate statements.
+
+// TargetGLSL is the Config.Target for printing GLSL code
+const TargetGLSL = "glsl"
+
+// GLSLReservedWords are GLSL keywords and reserved words, and builtin
+// type names, that are valid Go identifiers, which must be renamed.
+var GLSLReservedWords = map[string]bool{
+	"active": true, "asm": true, "atomic_uint": true, "attribute": true, "bool": true,
+	"buffer": true, "bvec2": true, "bvec3": true, "bvec4": true, "cast": true, "centroid": true,
+	"class": true, "coherent": true, "common": true, "discard": true, "do": true, "double": true,
+	"dmat2": true, "dmat3": true, "dmat4": true, "dvec2": true, "dvec3": true, "dvec4": true,
+	"enum": true, "extern": true, "external": true, "filter": true, "fixed": true, "flat": true,
+	"float": true, "fvec2": true, "fvec3": true, "fvec4": true, "half": true, "highp": true,
+	"hvec2": true, "hvec3": true, "hvec4": true, "image1D": true, "image2D": true, "image3D": true,
+	"imageCube": true, "in": true, "inline": true, "inout": true, "input": true, "int": true,
+	"invariant": true, "isampler2D": true, "ivec2": true, "ivec3": true, "ivec4": true, "layout": true,
+	"long": true, "lowp": true, "mat2": true, "mat2x2": true, "mat2x3": true, "mat2x4": true,
+	"mat3": true, "mat3x2": true, "mat3x3": true, "mat3x4": true, "mat4": true, "mat4x2": true,
+	"mat4x3": true, "mat4x4": true, "mediump": true, "namespace": true, "noinline": true,
+	"noperspective": true, "out": true, "output": true, "partition": true, "patch": true,
+	"precise": true, "precision": true, "public": true, "readonly": true, "resource": true,
+	"restrict": true, "sample": true, "sampler1D": true, "sampler2D": true, "sampler3D": true,
+	"samplerCube": true, "shared": true, "short": true, "sizeof": true, "smooth": true,
+	"static": true, "subroutine": true, "superp": true, "template": true, "this": true,
+	"typedef": true, "uint": true, "uniform": true, "union": true, "unsigned": true, "using": true,
+	"usampler2D": true, "uvec2": true, "uvec3": true, "uvec4": true, "varying": true, "vec2": true,
+	"vec3": true, "vec4": true, "void": true, "volatile": true, "while": true, "writeonly": true,
+}
+
+// GLSLMathVars are the GLSL expressions for the MathVars
+var GLSLMathVars = map[string]string{
+	"Infinity": "uintBitsToFloat(0x7f800000u)",
+}
+
+// glsl returns true if printing GLSL code
+func (p *printer) glsl() bool {
+	return p.Target == TargetGLSL
+}
+
+// glslVectorName returns the GLSL vector type name for the given
+// vector struct type, e.g., vec2 for sltype.Float2, ivec2 for
+// sltype.Int2, and uvec2 for sltype.Uint2.
+func glslVectorName(st *types.Struct) string {
+	pre := ""
+	switch st.Field(0).Type().Underlying().(*types.Basic).Kind() {
+	case types.Int32:
+		pre = "i"
+	case types.Uint32:
+		pre = "u"
+	}
+	return fmt.Sprintf("%svec%d", pre, st.NumFields())
+}
+
+// glslTypedef returns the basic type of the given type name if it is a
+// named type of the current package with a basic underlying type, e.g.,
+// type NeuronFlags int32, which has no typedef in GLSL: it is printed as
+// the basic type where used.
+func (p *printer) glslTypedef(tn *types.TypeName) (*types.Basic, bool) {
+	if tn.Pkg() != p.pkg.Types || tn.IsAlias() {
+		return nil, false
+	}
+	bt, ok := tn.Type().Underlying().(*types.Basic)
+	return bt, ok
+}
+
+// methodFuncName returns the name of the function for the given method
+// in WGSL and GLSL, which have no methods: Type_Method.
+func (p *printer) methodFuncName(fn *types.Func) string {
+	rt := fn.Type().(*types.Signature).Recv().Type()
+	if pt, ok := rt.(*types.Pointer); ok {
+		rt = pt.Elem()
+	}
+	tn := types.Unalias(rt).(*types.Named).Obj()
+	return p.objName(tn, tn.Name()) + "_" + p.objName(fn, fn.Name())
+}
+
+// methodCall returns the method of the current package called by the
+// given call expression, which is printed as a call of its function
+// in WGSL and GLSL (see methodFuncName), with whether it has a pointer
+// receiver, or nil if it is not such a method call.  Methods of other
+// packages, e.g., slbool.Bool.IsTrue, are translated by the replace table.
+func (p *printer) methodCall(x *ast.CallExpr) (*types.Func, bool) {
+	sel, ok := x.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, false
+	}
+	s := p.pkg.TypesInfo.Selections[sel]
+	if s == nil || s.Kind() != types.MethodVal || s.Obj().Pkg() != p.pkg.Types {
+		return nil, false
+	}
+	fn := s.Obj().(*types.Func)
+	rt := fn.Type().(*types.Signature).Recv().Type()
+	pt, ptrRecv := rt.(*types.Pointer)
+	if ptrRecv {
+		rt = pt.Elem()
+	}
+	if _, ok := types.Unalias(rt).(*types.Named); !ok {
+		return nil, false
+	}
+	return fn, ptrRecv
+}
+
+// glslCall prints the given call if it is a method call, as a call of the
+// Type_Method function with the receiver as the first argument, which is
+// an inout parameter for a pointer receiver, returning false otherwise.
+func (p *printer) glslCall(x *ast.CallExpr, depth int) bool {
+	fn, _ := p.methodCall(x)
+	if fn == nil {
+		return false
+	}
+	p.print(x.Pos(), p.methodFuncName(fn), x.Lparen, token.LPAREN)
+	p.expr0(x.Fun.(*ast.SelectorExpr).X, depth)
+	for _, a := range x.Args {
+		p.print(token.COMMA, blank)
+		p.expr0(a, depth+1)
+	}
+	p.print(x.Rparen, token.RPAREN)
+	return true
+}
+
+// glslSignature prints the name and parameters of the given method in
+// GLSL, as a function named Type_Method with the receiver as the first
+// parameter, e.g., float Params_Decay(inout Params ps, float v).
+func (p *printer) glslSignature(d *ast.FuncDecl) {
+	p.print(d.Name.Pos(), p.methodFuncName(p.pkg.TypesInfo.Defs[d.Name].(*types.Func)))
+	params := *d.Type.Params
+	params.List = append(d.Recv.List[:1:1], params.List...)
+	p.parameters(&params, funcParam)
+}
+
+// glslTypeName returns the GLSL name of the given type of a local
+// variable, as in typeName, with the basic types of the glslTypedef types.
+func (p *printer) glslTypeName(typ types.Type) (string, bool) {
+	typ = types.Unalias(typ)
+	if pt, ok := typ.(*types.Pointer); ok {
+		typ = types.Unalias(pt.Elem())
+	}
+	nt, ok := typ.(*types.Named)
+	if !ok {
+		return "", false
+	}
+	if bt, ok := p.glslTypedef(nt.Obj()); ok {
+		return bt.Name(), true
+	}
+	if st, ok := nt.Underlying().(*types.Struct); ok && alignsl.VectorSize(st) > 0 {
+		return glslVectorName(st), true
+	}
+	return "", false
+}
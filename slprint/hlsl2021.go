@@ -0,0 +1,169 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// HLSL 2021, which dxc compiles with -HV 2021, adds templates and
+// operator overloading to HLSL, which are used when Config.HLSL2021 is
+// set.  Generic functions are printed as template functions, with the
+// template arguments of each call, e.g., Clamp<float>(x, 0, hi), which
+// are given explicitly because HLSL does not deduce them from untyped
+// constants as Go does.  The Add, Sub, Mul and Div methods of structs
+// that take and return their own type, e.g., func (a Complex) Add(b
+// Complex) Complex, also define the +, -, * and / operators, which are
+// used for their calls, e.g., (a + b) for a.Add(b).  The default HLSL
+// 2018 output is also compiled by glslc and older versions of dxc, for
+// which gosl instantiates the generic functions instead.
+
+// operatorMethods are the operators defined by the methods of structs
+// with the given names in HLSL 2021 (see operatorMethod).
+var operatorMethods = map[string]string{"Add": "+", "Sub": "-", "Mul": "*", "Div": "/"}
+
+// hlsl2021 returns true if HLSL 2021 code is printed.
+func (p *printer) hlsl2021() bool {
+	return p.HLSL2021 && !p.wgsl() && !p.glsl() && !p.metal()
+}
+
+// operatorMethod returns the operator defined by the given method in
+// HLSL 2021, if it is one of the operatorMethods of a struct type, with
+// a value receiver and one parameter of that type, returning that type,
+// and "" otherwise.
+func operatorMethod(fn *types.Func) string {
+	op, ok := operatorMethods[fn.Name()]
+	if !ok {
+		return ""
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() == nil || sig.Params().Len() != 1 || sig.Results().Len() != 1 {
+		return ""
+	}
+	rt := sig.Recv().Type()
+	if _, ok := rt.Underlying().(*types.Struct); !ok {
+		return ""
+	}
+	if !types.Identical(sig.Params().At(0).Type(), rt) || !types.Identical(sig.Results().At(0).Type(), rt) {
+		return ""
+	}
+	return op
+}
+
+// operatorDecl prints the operator overload for the given method, if it
+// is an operatorMethod, which calls the method, after the method in the
+// struct.
+func (p *printer) operatorDecl(d *ast.FuncDecl) {
+	fn, ok := p.pkg.TypesInfo.Defs[d.Name].(*types.Func)
+	if !ok {
+		return
+	}
+	op := operatorMethod(fn)
+	if op == "" {
+		return
+	}
+	tnm := p.methRecvType(d.Recv.List[0].Type)
+	arg := "b"
+	if nms := d.Type.Params.List[0].Names; len(nms) == 1 && nms[0].Name != "_" {
+		arg = nms[0].Name
+	}
+	// the position is kept at the end of the method, which is not followed
+	// by the operator in the source, so the comments after it stay there
+	pos := p.pos
+	for _, ln := range [][]any{
+		{newline, newline, fmt.Sprintf("// operator%s is %s", op, d.Name.Name)},
+		{newline, fmt.Sprintf("%s operator%s(%s %s) {", tnm, op, tnm, arg)},
+		{indent, newline, fmt.Sprintf("return this.%s(%s);", d.Name.Name, arg)},
+		{unindent, newline, "}"},
+	} {
+		p.pos = pos
+		p.print(ln...)
+	}
+	p.pos = pos
+}
+
+// operatorCall prints the given call of an operatorMethod as the
+// operator, e.g., (a + b) for a.Add(b), returning false if it is not one.
+func (p *printer) operatorCall(x *ast.CallExpr) bool {
+	sel, ok := x.Fun.(*ast.SelectorExpr)
+	if !ok || len(x.Args) != 1 {
+		return false
+	}
+	fn, ok := p.pkg.TypesInfo.Uses[sel.Sel].(*types.Func)
+	if !ok {
+		return false
+	}
+	op := operatorMethod(fn)
+	if op == "" {
+		return false
+	}
+	p.print(token.LPAREN)
+	p.expr(sel.X)
+	p.print(blank, op, blank)
+	p.expr(x.Args[0])
+	p.print(x.Rparen, token.RPAREN)
+	return true
+}
+
+// templateDecl prints the template declaration of the given generic
+// function, e.g., template<typename T>, before its signature.
+func (p *printer) templateDecl(d *ast.FuncDecl) {
+	var nms []string
+	for _, f := range d.Type.TypeParams.List {
+		for _, nm := range f.Names {
+			nms = append(nms, "typename "+nm.Name)
+		}
+	}
+	p.print("template<"+strings.Join(nms, ", ")+">", newline)
+}
+
+// isTemplateCall returns true if the given expression is an instance of
+// a generic function of the package, e.g., Clamp or Clamp[int32] in a
+// call, whose template arguments are printed by templateArgs.  The
+// generic functions of other packages, e.g., slrand.Float for both
+// counter types, are overloaded in their HLSL headers instead.
+func (p *printer) isTemplateCall(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	if !ok || p.pkg == nil || p.pkg.TypesInfo == nil {
+		return false
+	}
+	if _, ok := p.pkg.TypesInfo.Instances[id]; !ok {
+		return false
+	}
+	fn, ok := p.pkg.TypesInfo.Uses[id].(*types.Func)
+	return ok && fn.Pkg() == p.pkg.Types
+}
+
+// templateArgs prints the template arguments of the given instance of
+// a generic function (see isTemplateCall), e.g., <float> for Clamp in
+// Clamp(x, 0, hi), where x is a float32.  The arguments must be basic
+// types, named types that are not generic, or the type parameters of
+// the template in which it is called.
+func (p *printer) templateArgs(id *ast.Ident) {
+	in := p.pkg.TypesInfo.Instances[id]
+	args := make([]string, in.TypeArgs.Len())
+	for i := range args {
+		ta := in.TypeArgs.At(i)
+		switch tt := ta.(type) {
+		case *types.Basic:
+			args[i] = tt.Name()
+		case *types.Named:
+			if tt.TypeArgs().Len() == 0 {
+				args[i] = tt.Obj().Name()
+			}
+		case *types.TypeParam: // in a template
+			args[i] = tt.Obj().Name()
+		}
+		if args[i] == "" {
+			p.transError(id.Pos(), "generic function %s cannot be called with type %s: only basic and named types are supported", id.Name, ta)
+			args[i] = ta.String()
+		}
+	}
+	p.print("<" + strings.Join(args, ", ") + ">")
+}
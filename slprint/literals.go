@@ -0,0 +1,209 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/emer/gosl/v2/alignsl"
+)
+
+// gosl: struct composite literals, e.g., F32{Min: 0, Max: 1}, are
+// translated into HLSL initializer lists, with all of the GPU fields of
+// the struct in order, and the zero value for the omitted fields, e.g.,
+// F32 t = {0, 1, 0, 0}; and nested literals are nested lists.  HLSL only
+// allows initializer lists in declarations, so literals elsewhere, e.g.,
+// in assignments, returns, or as function arguments, are declared as
+// temporary variables before the statement (see hoistTemps).  Vector
+// literals, e.g., sltype.Float2{X: 1, Y: x}, are vector constructors:
+// float2(1, x), which are valid anywhere.  In WGSL, struct and array
+// literals are also constructors, e.g., F32(0, 1, 0, 0).
+
+// litStruct returns the struct type of the given composite literal,
+// and the number of vector components if it is a vector, or nil if it
+// is not a struct literal.
+func (p *printer) litStruct(x *ast.CompositeLit) (*types.Struct, int) {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return nil, 0
+	}
+	tp := p.pkg.TypesInfo.TypeOf(x)
+	if tp == nil {
+		return nil, 0
+	}
+	st, ok := tp.Underlying().(*types.Struct)
+	if !ok {
+		return nil, 0
+	}
+	return st, alignsl.VectorSize(st)
+}
+
+// isStructLit returns true if the given expression is a struct
+// composite literal, which is not a vector
+func (p *printer) isStructLit(x ast.Expr) bool {
+	cl, ok := x.(*ast.CompositeLit)
+	if !ok {
+		return false
+	}
+	st, n := p.litStruct(cl)
+	return st != nil && n == 0
+}
+
+// litValues returns the values of the given struct composite literal
+// by field name, for keyed or positional elements.
+func litValues(x *ast.CompositeLit, st *types.Struct) map[string]ast.Expr {
+	vals := map[string]ast.Expr{}
+	for i, el := range x.Elts {
+		if kv, ok := el.(*ast.KeyValueExpr); ok {
+			if id, ok := kv.Key.(*ast.Ident); ok {
+				vals[id.Name] = kv.Value
+			}
+		} else if i < st.NumFields() {
+			vals[st.Field(i).Name()] = el
+		}
+	}
+	return vals
+}
+
+// compositeLit prints the given struct composite literal as an HLSL
+// initializer list, or a vector literal as a vector constructor,
+// returning false if it is not a struct literal.
+func (p *printer) compositeLit(x *ast.CompositeLit, depth int) bool {
+	st, n := p.litStruct(x)
+	if st == nil {
+		return p.wgsl() && p.arrayLit(x, depth)
+	}
+	vals := litValues(x, st)
+	if n > 0 {
+		p.print(x.Pos(), p.vectorName(st), token.LPAREN)
+		for i := range n {
+			if i > 0 {
+				p.print(token.COMMA, blank)
+			}
+			if v, ok := vals[st.Field(i).Name()]; ok {
+				p.expr0(v, depth+1)
+			} else {
+				p.print("0")
+			}
+		}
+		p.print(token.RPAREN)
+		return true
+	}
+	open, close := token.LBRACE, token.RBRACE
+	if p.wgsl() {
+		p.print(x.Pos(), p.wgslTypeName(p.pkg.TypesInfo.TypeOf(x)))
+		open, close = token.LPAREN, token.RPAREN
+	}
+	p.print(x.Lbrace, open)
+	for i, f := range alignsl.GPUFields(st) {
+		if i > 0 {
+			p.print(token.COMMA, blank)
+		}
+		v, ok := vals[f.Name()]
+		switch {
+		case !ok && p.wgsl():
+			p.synth(p.zero(f.Type()))
+		case !ok:
+			p.print(p.zero(f.Type()))
+		case !p.arrayLit(v, depth):
+			p.expr0(v, depth+1)
+		}
+	}
+	p.print(close)
+	return true
+}
+
+// arrayLit prints the given array composite literal within an initializer
+// list as a nested list of its elements, with zero values for the omitted
+// elements, returning false if it is not an array literal.
+func (p *printer) arrayLit(x ast.Expr, depth int) bool {
+	cl, ok := x.(*ast.CompositeLit)
+	if !ok || p.pkg == nil || p.pkg.TypesInfo == nil {
+		return false
+	}
+	at, ok := p.pkg.TypesInfo.TypeOf(cl).Underlying().(*types.Array)
+	if !ok {
+		return false
+	}
+	vals := make([]ast.Expr, at.Len())
+	i := 0
+	for _, el := range cl.Elts {
+		if kv, ok := el.(*ast.KeyValueExpr); ok {
+			if iv, ok := constant.Int64Val(p.pkg.TypesInfo.Types[kv.Key].Value); ok {
+				i = int(iv)
+			}
+			el = kv.Value
+		}
+		if i < len(vals) {
+			vals[i] = el
+		}
+		i++
+	}
+	open, close := token.LBRACE, token.RBRACE
+	if p.wgsl() {
+		p.print(cl.Pos(), p.wgslTypeName(at))
+		open, close = token.LPAREN, token.RPAREN
+	}
+	p.print(cl.Lbrace, open)
+	for i, v := range vals {
+		if i > 0 {
+			p.print(token.COMMA, blank)
+		}
+		switch {
+		case v == nil && p.wgsl():
+			p.synth(p.zero(at.Elem()))
+		case v == nil:
+			p.print(p.zero(at.Elem()))
+		case !p.arrayLit(v, depth):
+			p.expr0(v, depth+1)
+		}
+	}
+	p.print(close)
+	return true
+}
+
+// vectorTypeName returns the HLSL vector type name for the given
+// vector struct type, e.g., float2 for sltype.Float2.
+func vectorTypeName(st *types.Struct) string {
+	nm := "float"
+	switch st.Field(0).Type().Underlying().(*types.Basic).Kind() {
+	case types.Int32:
+		nm = "int"
+	case types.Uint32:
+		nm = "uint"
+	}
+	return fmt.Sprintf("%s%d", nm, st.NumFields())
+}
+
+// zeroValue returns the HLSL zero value of the given type in an
+// initializer list.
+func zeroValue(typ types.Type) string {
+	switch ut := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case ut.Info()&types.IsBoolean != 0:
+			return "false"
+		case ut.Info()&types.IsComplex != 0:
+			return "{0, 0}"
+		}
+	case *types.Struct:
+		var zs []string
+		for _, f := range alignsl.GPUFields(ut) {
+			zs = append(zs, zeroValue(f.Type()))
+		}
+		return "{" + strings.Join(zs, ", ") + "}"
+	case *types.Array:
+		zs := make([]string, ut.Len())
+		for i := range zs {
+			zs[i] = zeroValue(ut.Elem())
+		}
+		return "{" + strings.Join(zs, ", ") + "}"
+	}
+	return "0"
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// gosl: Go for loops with only a condition, e.g., for k < n, or none,
+// are printed as while loops: while (k < n), while (true).  A C for loop
+// header can only have single expressions as its init and post statements,
+// so loops with a parallel assignment in them, e.g.,
+// for i, j := 0, n; i < j; i, j = i+1, j-1, are lowered to a while loop,
+// within a block that declares the init variables, with the post
+// statement at the end of the body, and before each continue of the loop,
+// so that it is run on every iteration, as in Go.
+
+// forStmt prints the given for loop.
+func (p *printer) forStmt(s *ast.ForStmt) {
+	defer func(post ast.Stmt) {
+		p.contPost = post
+	}(p.contPost)
+	p.contPost = nil // continue in this loop runs its own post
+	if isParallelAssign(s.Init) || isParallelAssign(s.Post) {
+		p.lowerFor(s)
+		return
+	}
+	if s.Init == nil && s.Post == nil {
+		p.whileHeader(s.Cond)
+		p.block(s.Body, 1)
+		return
+	}
+	p.print(token.FOR)
+	p.controlClause(true, s.Init, s.Cond, s.Post)
+	p.block(s.Body, 1)
+}
+
+// isParallelAssign returns true if the given statement assigns or
+// defines multiple variables, e.g., i, j = i+1, j-1.
+func isParallelAssign(s ast.Stmt) bool {
+	as, ok := s.(*ast.AssignStmt)
+	return ok && len(as.Lhs) > 1 && len(as.Lhs) == len(as.Rhs)
+}
+
+// whileHeader prints the header of a while loop with given condition,
+// which is true if nil.
+func (p *printer) whileHeader(cond ast.Expr) {
+	p.print("while", blank, token.LPAREN)
+	if cond != nil {
+		p.expr(stripParens(cond))
+	} else {
+		p.print("true")
+	}
+	p.print(token.RPAREN, blank)
+}
+
+// lowerFor prints the given for loop as a while loop, with its init
+// statement in an enclosing block, and its post statement at the end of
+// the body, unless it is unreachable, and before each continue.
+func (p *printer) lowerFor(s *ast.ForStmt) {
+	if s.Init != nil {
+		p.print(token.LBRACE, indent, newline)
+		p.simpleStmts(s.Init)
+		p.print(newline)
+	}
+	p.whileHeader(s.Cond)
+	p.print(s.Body.Lbrace, token.LBRACE)
+	p.contPost = s.Post
+	p.stmtList(s.Body.List, 1, s.Post == nil)
+	if n := len(s.Body.List); s.Post != nil && (n == 0 || !terminates(s.Body.List[n-1])) {
+		p.print(indent, newline)
+		p.simpleStmts(s.Post)
+		p.print(unindent)
+	}
+	p.contPost = nil
+	p.linebreak(p.lineFor(s.Body.Rbrace), 1, ignore, true)
+	p.print(s.Body.Rbrace, token.RBRACE)
+	if s.Init != nil {
+		p.print(unindent, newline, token.RBRACE)
+	}
+}
+
+// continueStmt prints a continue statement, with the post statement of
+// the loop before it if the loop is lowered.
+func (p *printer) continueStmt(s *ast.BranchStmt) {
+	if p.contPost != nil && s.Label == nil {
+		p.simpleStmts(p.contPost)
+		p.print(newline)
+	}
+	p.print(s.Tok, ";")
+}
+
+// simpleStmts prints the given init or post statement of a for loop
+// as one or more statements, each ending with a semicolon, e.g.,
+// i = i + 1; j = j - 1; for i, j = i+1, j-1.  The source position
+// is restored afterwards, as the statement is printed out of order.
+func (p *printer) simpleStmts(s ast.Stmt) {
+	pos := p.pos
+	defer func() { p.pos = pos }()
+	as, ok := s.(*ast.AssignStmt)
+	if !ok || !isParallelAssign(s) {
+		p.stmt(s, false, false)
+		return
+	}
+	// the values must be evaluated before any of the variables are
+	// assigned, so temporary variables are needed if a value uses a
+	// variable that is assigned before it, e.g., for a, b = b, a.
+	useTemps := false
+	for k, rhs := range as.Rhs {
+		ast.Inspect(rhs, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				obj := p.pkg.TypesInfo.Uses[id]
+				for _, lhs := range as.Lhs[:k] {
+					if lid, ok := lhs.(*ast.Ident); ok && obj != nil && p.pkg.TypesInfo.ObjectOf(lid) == obj {
+						useTemps = true
+					}
+				}
+			}
+			return !useTemps
+		})
+	}
+	decl := func(lhs ast.Expr) {
+		if lid, ok := lhs.(*ast.Ident); ok && as.Tok == token.DEFINE {
+			if obj := p.pkg.TypesInfo.Defs[lid]; obj != nil {
+				p.declStart(obj.Type())
+				p.expr(lhs)
+				p.declEnd(obj.Type())
+				return
+			}
+		}
+		p.expr(lhs)
+	}
+	var temps []string
+	if useTemps {
+		for _, rhs := range as.Rhs {
+			nm := fmt.Sprintf("_t%d", p.nTemps)
+			p.nTemps++
+			temps = append(temps, nm)
+			typ := types.Default(p.pkg.TypesInfo.TypeOf(rhs))
+			p.declStart(typ)
+			p.print(nm)
+			p.declEnd(typ)
+			p.print(blank, token.ASSIGN, blank)
+			p.expr(rhs)
+			p.print(token.SEMICOLON, newline)
+		}
+	}
+	for k, lhs := range as.Lhs {
+		if k > 0 {
+			p.print(newline)
+		}
+		if id, ok := lhs.(*ast.Ident); ok && id.Name == "_" {
+			continue
+		}
+		decl(lhs)
+		p.print(blank, token.ASSIGN, blank)
+		if useTemps {
+			p.print(temps[k])
+		} else {
+			p.expr(as.Rhs[k])
+		}
+		p.print(token.SEMICOLON)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MathPackages are the import paths of the packages whose constants
+// are translated into HLSL literals, e.g., math.MaxFloat32, including
+// the chewxy/math32 package that goimports can find for math32 in the
+// extracted files, which the cogentcore math32 package wraps.
+var MathPackages = map[string]bool{
+	"math":                       true,
+	"cogentcore.org/core/math32": true,
+	"github.com/chewxy/math32":   true,
+}
+
+// MathVars are the HLSL expressions for the variables of the MathPackages,
+// which have no constant value, e.g., math32.Infinity.
+var MathVars = map[string]string{
+	"Infinity": "asfloat(0x7f800000)",
+}
+
+// MathConsts are the values of the float32 and int32 limit constants
+// of the MathPackages, which are commonly redefined by hand in shader code.
+// User constants with the same name and value are folded, so that they
+// are only defined once in a shader that includes several definitions.
+var MathConsts = map[string]float64{
+	"MaxFloat32":             math.MaxFloat32,
+	"SmallestNonzeroFloat32": math.SmallestNonzeroFloat32,
+	"MaxInt32":               math.MaxInt32,
+	"MinInt32":               math.MinInt32,
+	"MaxUint32":              math.MaxUint32,
+}
+
+// ConstLiteral returns the HLSL literal for the given constant value of
+// the given type, with 10 significant digits for floats, which exactly
+// represents any float32 value, e.g., 3.402823466e+38 for math.MaxFloat32,
+// as FLT_MAX in C.
+func ConstLiteral(val constant.Value, typ types.Type) string {
+	bt, _ := typ.Underlying().(*types.Basic)
+	switch {
+	case bt == nil:
+		return val.ExactString()
+	case bt.Info()&types.IsFloat != 0:
+		f, _ := constant.Float64Val(constant.ToFloat(val))
+		s := strconv.FormatFloat(f, 'g', 10, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s
+	case bt.Info()&types.IsUnsigned != 0:
+		return constant.ToInt(val).ExactString() + "u"
+	case bt.Info()&types.IsInteger != 0:
+		if v, exact := constant.Int64Val(constant.ToInt(val)); exact && v == math.MinInt32 {
+			return "(-2147483647 - 1)" // 2147483648 is out of range for int
+		}
+		return constant.ToInt(val).ExactString()
+	}
+	return val.ExactString()
+}
+
+// mathConst returns the HLSL literal for the given selector if it is
+// a constant or variable of one of the MathPackages, e.g., math.MaxFloat32.
+func (p *printer) mathConst(x *ast.SelectorExpr) (string, bool) {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return "", false
+	}
+	id, ok := x.X.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	pn, ok := p.pkg.TypesInfo.Uses[id].(*types.PkgName)
+	if !ok || !MathPackages[pn.Imported().Path()] {
+		return "", false
+	}
+	switch p.pkg.TypesInfo.Uses[x.Sel].(type) {
+	case *types.Const:
+		tv, ok := p.pkg.TypesInfo.Types[x]
+		if !ok || tv.Value == nil {
+			return "", false
+		}
+		return ConstLiteral(tv.Value, types.Default(tv.Type)), true
+	case *types.Var:
+		vars := MathVars
+		switch {
+		case p.wgsl():
+			vars = WGSLMathVars
+		case p.glsl():
+			vars = GLSLMathVars
+		case p.metal():
+			vars = MetalMathVars
+		}
+		ex, ok := vars[x.Sel.Name]
+		return ex, ok
+	}
+	return "", false
+}
+
+// foldedConst returns the include guard for the given package-level
+// constant spec if it redefines one of the MathConsts with the same
+// value, e.g., const MaxFloat32 = 3.402823466e+38, or "" otherwise.
+func (p *printer) foldedConst(s *ast.ValueSpec) string {
+	if p.pkg == nil || p.pkg.TypesInfo == nil || len(s.Names) != 1 {
+		return ""
+	}
+	nm := s.Names[0]
+	mv, has := MathConsts[nm.Name]
+	if !has {
+		return ""
+	}
+	c, ok := p.pkg.TypesInfo.Defs[nm].(*types.Const)
+	if !ok || c.Pkg() == nil || c.Parent() != c.Pkg().Scope() {
+		return ""
+	}
+	v, _ := constant.Float64Val(constant.ToFloat(c.Val()))
+	if bt, ok := c.Type().Underlying().(*types.Basic); ok && bt.Info()&types.IsInteger != 0 {
+		if v != mv {
+			return ""
+		}
+	} else if float32(v) != float32(mv) {
+		return ""
+	}
+	return "__" + nm.Name + "__"
+}
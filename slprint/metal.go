@@ -0,0 +1,98 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// gosl: with Config.Target = TargetMetal, the Go code is printed as the
+// Metal Shading Language (MSL), for Metal on macOS.  MSL is C++, and close
+// to HLSL, so most of the translation is the same, including the vector
+// type names (float2, etc) and typedefs, with these differences: methods
+// are functions named Type_Method as in GLSL, with the receiver as the
+// first parameter, instead of C++ member functions, which would depend on
+// the address space of the receiver.  Pointer parameters are thread
+// references: thread T& x, instead of inout, to which the arguments are
+// passed as is.  Package constants are in the constant address space, and
+// local constants are const.  Operands of shift and bitwise operators are
+// parenthesized as in WGSL (see wgslParens), and multiple variables defined
+// in one statement are defined in separate statements, as in GLSL.
+// Variables at program scope are not supported in MSL: groupshared
+// variables must be declared as threadgroup variables in the kernel.
+
+// TargetMetal is the Config.Target for printing MSL code
+const TargetMetal = "metal"
+
+// MetalReservedWords are MSL and C++ keywords and reserved words, and
+// builtin type names, that are valid Go identifiers, which must be renamed.
+var MetalReservedWords = map[string]bool{
+	"alignas": true, "alignof": true, "array": true, "asm": true, "auto": true, "bool": true,
+	"bool2": true, "bool3": true, "bool4": true, "catch": true, "char": true, "class": true,
+	"constant": true, "constexpr": true, "delete": true, "device": true, "do": true,
+	"double": true, "enum": true, "explicit": true, "export": true, "extern": true,
+	"float": true, "float2": true, "float3": true, "float4": true, "fragment": true,
+	"friend": true, "half": true, "half2": true, "half3": true, "half4": true, "inline": true,
+	"int": true, "int2": true, "int3": true, "int4": true, "kernel": true, "long": true,
+	"metal": true, "mutable": true, "namespace": true, "new": true, "noexcept": true,
+	"nullptr": true, "operator": true, "private": true, "protected": true, "public": true,
+	"register": true, "sampler": true, "short": true, "signed": true, "sizeof": true,
+	"static": true, "static_assert": true, "template": true, "this": true, "thread": true,
+	"threadgroup": true, "throw": true, "try": true, "typedef": true, "typename": true,
+	"uchar": true, "uint": true, "uint2": true, "uint3": true, "uint4": true, "ulong": true,
+	"union": true, "unsigned": true, "ushort": true, "using": true, "vertex": true,
+	"virtual": true, "void": true, "volatile": true, "while": true,
+}
+
+// MetalMathVars are the MSL expressions for the MathVars
+var MetalMathVars = map[string]string{
+	"Infinity": "INFINITY",
+}
+
+// metal returns true if printing MSL code
+func (p *printer) metal() bool {
+	return p.Target == TargetMetal
+}
+
+// metalRef prints the & of a thread reference parameter in MSL after the
+// type of the given parameter, if it is a pointer (see inoutPtr), which
+// is not supported for arrays, as the references would be an array of
+// references.
+func (p *printer) metalRef(par *ast.Field, dims []ast.Expr) {
+	if _, ok := stripParensAlways(par.Type).(*ast.StarExpr); !ok || !p.metal() {
+		return
+	}
+	if len(dims) > 0 {
+		p.transError(par.Pos(), "pointers to arrays are not supported as parameters in MSL: pass the element, or an index into a buffer, instead")
+	}
+	p.print("&")
+}
+
+// metalConstKey returns the qualifier of the constant with the given name
+// in MSL: constant at package scope, and const in functions.
+func (p *printer) metalConstKey(name *ast.Ident) string {
+	if obj := p.pkg.TypesInfo.Defs[name]; obj != nil && obj.Parent() == p.pkg.Types.Scope() {
+		return "constant"
+	}
+	return "const"
+}
+
+// metalGroupShared reports the groupshared variables of the given
+// declaration, which are threadgroup variables in MSL that can only be
+// declared in the kernel function.
+func (p *printer) metalGroupShared(d *ast.GenDecl) {
+	for _, s := range d.Specs {
+		vs, ok := s.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		for _, nm := range vs.Names {
+			if _, ok := p.pkg.TypesInfo.Defs[nm].(*types.Var); ok {
+				p.transError(nm.Pos(), "groupshared variables cannot be declared at program scope in MSL: declare %s as a threadgroup variable in the kernel function, in a //gosl: metal block", nm.Name)
+			}
+		}
+	}
+}
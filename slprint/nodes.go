@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/token"
 	"go/types"
 	"math"
@@ -26,6 +27,8 @@ import (
 	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/emer/gosl/v2/alignsl"
 )
 
 // Formatting issues:
@@ -369,19 +372,34 @@ func (p *printer) parameters(fields *ast.FieldList, mode paramMode) {
 			} else if i > 0 {
 				p.print(blank)
 			}
+			if elt := sliceParam(par); elt != nil && mode == funcParam {
+				p.bufferParams(par, elt)
+				prevLine = parLineEnd
+				continue
+			}
 			// parameter type -- gosl = type first, replace ptr star with `inout`
-			p.expr(p.inoutPtr(stripParensAlways(par.Type)))
+			// (thread T& in MSL, see metalRef)
+			// and array dimensions after the name
+			ptyp, dims := arrayDims(p.inoutPtr(stripParensAlways(par.Type)))
+			p.expr(ptyp)
+			p.metalRef(par, dims)
 			p.print(blank)
 			// parameter names
 			if len(par.Names) > 1 {
 				for ni, nm := range par.Names {
 					if ni > 0 {
 						p.print(token.COMMA, blank)
-						p.expr(p.inoutPtr(stripParensAlways(par.Type)))
+						ptyp, _ = arrayDims(p.inoutPtr(stripParensAlways(par.Type)))
+						p.expr(ptyp)
+						p.metalRef(par, nil)
 						p.print(blank)
 					}
 					p.expr0(nm, 1)
+					p.arrayDimList(dims)
 				}
+			} else if len(dims) > 0 {
+				p.identList(par.Names, ws == indent)
+				p.arrayDimList(dims)
 			} else {
 				// Very subtle: If we indented before (ws == ignore), identList
 				// won't indent again. If we didn't (ws == indent), identList will
@@ -483,8 +501,8 @@ func (p *printer) signatureDecl(d *ast.FuncDecl) {
 	n := res.NumFields()
 	if n > 0 {
 		// res != nil
-		if n == 1 && res.List[0].Names == nil {
-			// single anonymous res; no ()'s
+		if n == 1 {
+			// single res; no ()'s, named res is declared in the body
 			p.expr(stripParensAlways(res.List[0].Type))
 		} else {
 			p.parameters(res, funcParam)
@@ -493,9 +511,13 @@ func (p *printer) signatureDecl(d *ast.FuncDecl) {
 		p.print("void")
 	}
 	p.print(blank)
+	if d.Recv != nil && (p.glsl() || p.metal()) {
+		p.glslSignature(d)
+		return
+	}
 	p.expr(d.Name)
 
-	if sig.TypeParams != nil {
+	if sig.TypeParams != nil && !p.hlsl2021() {
 		p.parameters(sig.TypeParams, funcTParam)
 	}
 	if sig.Params != nil {
@@ -540,6 +562,25 @@ func (p *printer) setLineComment(text string) {
 	p.setComment(&ast.CommentGroup{List: []*ast.Comment{{Slash: token.NoPos, Text: text}}})
 }
 
+// isExcludedField returns true if the given struct field is excluded
+// from the GPU struct with a gosl:"-" tag.
+func isExcludedField(f *ast.Field) bool {
+	if f.Tag == nil {
+		return false
+	}
+	tag, err := strconv.Unquote(f.Tag.Value)
+	return err == nil && alignsl.Excluded(tag)
+}
+
+// excludedNote returns the note printed in place of the given excluded field
+func excludedNote(f *ast.Field) string {
+	nms := make([]string, len(f.Names))
+	for j, nm := range f.Names {
+		nms[j] = nm.Name
+	}
+	return fmt.Sprintf("// %s: CPU-only, excluded by gosl:\"-\" (the Go type needs a GPU mirror)", strings.Join(nms, ", "))
+}
+
 func (p *printer) fieldList(fields *ast.FieldList, isStruct, isIncomplete bool) {
 	lbrace := fields.Opening
 	list := fields.List
@@ -598,18 +639,39 @@ func (p *printer) fieldList(fields *ast.FieldList, isStruct, isIncomplete bool)
 			sep = blank
 		}
 		var line int
+		prevExcluded := false
 		for i, f := range list {
+			excluded := isExcludedField(f)
 			if i > 0 {
-				p.linebreak(p.lineFor(f.Pos()), 1, ignore, p.linesFrom(line) > 0)
+				// gosl: excluded field notes are in their own alignment section
+				p.linebreak(p.lineFor(f.Pos()), 1, ignore, p.linesFrom(line) > 0 || excluded || prevExcluded)
 			}
+			prevExcluded = excluded
 			extraTabs := 0
 			p.setComment(f.Doc)
 			p.recordLine(&line)
+			if excluded {
+				// gosl: CPU-only fields are omitted, with a note
+				p.print(f.Pos(), excludedNote(f))
+				continue
+			}
 			if len(f.Names) > 0 {
 				// named fields
-				p.expr(f.Type)
+				typ, dims := arrayDims(f.Type)
+				p.expr(typ)
 				p.print(sep)
-				p.identList(f.Names, false)
+				if len(dims) == 0 {
+					p.identList(f.Names, false)
+				} else {
+					// gosl: array fields are declared as name[len]
+					for j, nm := range f.Names {
+						if j > 0 {
+							p.print(token.COMMA, blank)
+						}
+						p.expr(nm)
+						p.arrayDimList(dims)
+					}
+				}
 				extraTabs = 1
 			} else {
 				// anonymous field
@@ -827,7 +889,11 @@ func (p *printer) binaryExpr(x *ast.BinaryExpr, prec1, cutoff, depth int) {
 	printBlank := prec < cutoff
 
 	ws := indent
-	p.expr1(x.X, prec, depth+diffPrec(x.X, prec))
+	if p.wgsl() || p.glsl() || p.metal() {
+		p.wgslOperand(x.Op, x.X, false, prec, depth+diffPrec(x.X, prec))
+	} else {
+		p.expr1(x.X, prec, depth+diffPrec(x.X, prec))
+	}
 	if printBlank {
 		p.print(blank)
 	}
@@ -853,7 +919,11 @@ func (p *printer) binaryExpr(x *ast.BinaryExpr, prec1, cutoff, depth int) {
 	if printBlank {
 		p.print(blank)
 	}
-	p.expr1(x.Y, prec+1, depth+1)
+	if p.wgsl() || p.glsl() || p.metal() {
+		p.wgslOperand(x.Op, x.Y, true, prec+1, depth+1)
+	} else {
+		p.expr1(x.Y, prec+1, depth+1)
+	}
 	if ws == ignore {
 		p.print(unindent)
 	}
@@ -866,13 +936,32 @@ func isBinary(expr ast.Expr) bool {
 
 func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 	p.print(expr.Pos())
+	if nm, ok := p.temps[expr]; ok {
+		p.print(nm)
+		return
+	}
+	if p.complexExpr(expr, depth) {
+		return
+	}
 
 	switch x := expr.(type) {
 	case *ast.BadExpr:
 		p.print("BadExpr")
 
 	case *ast.Ident:
+		if p.isFuncRecv(x) {
+			p.print("this") // gosl: e.g., return of a value receiver
+			break
+		}
+		if ax, ok := p.aliasOf(x); ok {
+			p.expr1(ax, prec1, depth)
+			p.pos = p.posFor(x.End()) // continue from the use, not the definition
+			break
+		}
 		p.print(x)
+		if p.hlsl2021() && p.isTemplateCall(x) {
+			p.templateArgs(x)
+		}
 
 	case *ast.BinaryExpr:
 		if depth < 1 {
@@ -896,8 +985,12 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 			p.print(token.RPAREN)
 		} else {
 			// no parenthesis needed
-			// gosl: don't de-reference pointers
-			// p.print(token.MUL)
+			// gosl: don't de-reference pointers, except in WGSL
+			if p.wgsl() {
+				p.print(token.MUL)
+				p.expr1(x.X, prec, depth)
+				break
+			}
 			p.expr(x.X)
 		}
 
@@ -910,7 +1003,10 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 			p.print(token.RPAREN)
 		} else {
 			// no parenthesis needed
-			if x.Op != token.AND { // no & addr-of
+			switch {
+			case (p.wgsl() || p.glsl() || p.metal()) && x.Op == token.XOR:
+				p.print("~")
+			case x.Op != token.AND || p.wgsl(): // no & addr-of, except in WGSL
 				p.print(x.Op)
 			}
 			if x.Op == token.RANGE {
@@ -958,6 +1054,10 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 		p.print(x.Rparen, token.RPAREN)
 
 	case *ast.IndexExpr:
+		if p.hlsl2021() && p.isTemplateCall(x.X) {
+			p.expr(x.X) // with the template arguments
+			break
+		}
 		// TODO(gri): should treat[] like parentheses and undo one level of depth
 		p.expr1(x.X, token.HighestPrec, 1)
 		p.print(x.Lbrack, token.LBRACK)
@@ -965,6 +1065,10 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 		p.print(x.Rbrack, token.RBRACK)
 
 	case *ast.IndexListExpr:
+		if p.hlsl2021() && p.isTemplateCall(x.X) {
+			p.expr(x.X) // with the template arguments
+			break
+		}
 		// TODO(gri): as for IndexExpr, should treat [] like parentheses and undo
 		// one level of depth
 		p.expr1(x.X, token.HighestPrec, 1)
@@ -1017,6 +1121,18 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 		if len(x.Args) > 1 {
 			depth++
 		}
+		if p.minMax(x, depth) {
+			break
+		}
+		if p.wgsl() && p.wgslCall(x, depth) {
+			break
+		}
+		if (p.glsl() || p.metal()) && p.glslCall(x, depth) {
+			break
+		}
+		if p.hlsl2021() && p.operatorCall(x) {
+			break
+		}
 		var wasIndented bool
 		if _, ok := x.Fun.(*ast.FuncType); ok {
 			// conversions to literal function types require parentheses around the type
@@ -1045,6 +1161,9 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 		}
 
 	case *ast.CompositeLit:
+		if p.compositeLit(x, depth) {
+			break
+		}
 		// composite literal elements that are composite literals themselves may have the type omitted
 		if x.Type != nil {
 			p.expr1(x.Type, token.HighestPrec, depth)
@@ -1205,6 +1324,16 @@ func (p *printer) fixSlbool(x *ast.CallExpr, prec1, depth int) bool {
 	return true
 }
 
+// isFuncRecv returns true if the given identifier is the receiver
+// of the current method.
+func (p *printer) isFuncRecv(x *ast.Ident) bool {
+	if p.curFuncRecv == nil || x.Name != p.curFuncRecv.Name || p.pkg == nil || p.pkg.TypesInfo == nil {
+		return false
+	}
+	obj := p.pkg.TypesInfo.Uses[x]
+	return obj != nil && obj == p.pkg.TypesInfo.Defs[p.curFuncRecv]
+}
+
 func (p *printer) possibleSelectorExpr(expr ast.Expr, prec1, depth int) bool {
 	if x, ok := expr.(*ast.SelectorExpr); ok {
 		return p.selectorExpr(x, depth, true) // method
@@ -1216,6 +1345,18 @@ func (p *printer) possibleSelectorExpr(expr ast.Expr, prec1, depth int) bool {
 // selectorExpr handles an *ast.SelectorExpr node and reports whether x spans
 // multiple lines.
 func (p *printer) selectorExpr(x *ast.SelectorExpr, depth int, isMethod bool) bool {
+	if lit, ok := p.mathConst(x); ok {
+		p.print(&ast.BasicLit{ValuePos: x.Pos(), Kind: token.FLOAT, Value: lit})
+		return false
+	}
+	if to, ok := p.replacement(x.Sel); ok {
+		p.print(x.Pos(), to)
+		return false
+	}
+	if to, ok := p.resolvedType(x.Sel); ok {
+		p.print(x.Pos(), to)
+		return false
+	}
 	// gosl: replace receiver with this.
 	if id, ok := x.X.(*ast.Ident); ok && p.curFuncRecv != nil && id.Name == p.curFuncRecv.Name {
 		p.print("this")
@@ -1255,7 +1396,11 @@ func (p *printer) stmtList(list []ast.Stmt, nindent int, nextIsRBrace bool) {
 	}
 	var line int
 	i := 0
-	for _, s := range list {
+	for j, s := range list {
+		if p.isAliasDef(s) {
+			p.pos = p.posFor(s.End()) // no empty line in its place
+			continue
+		}
 		// ignore empty statements (was issue 3466)
 		if _, isEmpty := s.(*ast.EmptyStmt); !isEmpty {
 			// nindent == 0 only for lists of switch/select case clauses;
@@ -1266,7 +1411,10 @@ func (p *printer) stmtList(list []ast.Stmt, nindent int, nextIsRBrace bool) {
 				p.linebreak(p.lineFor(s.Pos()), 1, ignore, i == 0 || nindent == 0 || p.linesFrom(line) > 0)
 			}
 			p.recordLine(&line)
+			p.hoistTemps(s)
+			p.markOverwritten(list, j)
 			p.stmt(s, nextIsRBrace && i == len(list)-1, false)
+			clear(p.temps)
 			// labeled statements put labels on a separate line, but here
 			// we only care about the start line of the actual statement
 			// without label - correct line for each label
@@ -1340,6 +1488,10 @@ func stripParensAlways(x ast.Expr) ast.Expr {
 // gosl: replace pointer type with `inout`
 func (p *printer) inoutPtr(x ast.Expr) ast.Expr {
 	if sx, ok := x.(*ast.StarExpr); ok {
+		if p.metal() { // thread T& x: see metalRef
+			p.print("thread", blank)
+			return sx.X
+		}
 		p.print("inout", blank)
 		return sx.X
 	}
@@ -1418,50 +1570,437 @@ func (p *printer) indentList(list []ast.Expr) bool {
 	return false
 }
 
-// caseClause processes a CaseClause
+// caseClause processes a CaseClause, with a case label for each of
+// its values, e.g., case 1: case 2: {, and a break at the end of its
+// block unless it terminates or falls through to the next case.
 func (p *printer) caseClause(s *ast.CaseClause, nextIsRBrace bool) {
 	if s.List != nil {
-		p.print(token.CASE, blank)
-		// p.exprList(s.Pos(), s.List, 1, 0, s.Colon, false)
-		// glslc compiler crashes if expr is the label -- convert to int.
-		gotInt := false
-		if len(s.List) != 1 {
-			fmt.Printf("%s:\n\tglslc switch only allows single-arg case values that translate to an int\n", p.pkg.Fset.PositionFor(s.Pos(), true).String())
+		for i, x := range s.List {
+			if i > 0 {
+				p.print(blank)
+			}
+			p.print(token.CASE, blank)
+			p.caseValue(x)
+			if i < len(s.List)-1 {
+				p.print(token.COLON)
+			}
+		}
+	} else {
+		p.print(token.DEFAULT)
+	}
+	p.print(s.Colon, token.COLON)
+	body := s.Body
+	var fallsThrough *ast.BranchStmt
+	if n := len(body); n > 0 {
+		if fbr, ok := body[n-1].(*ast.BranchStmt); ok && fbr.Tok == token.FALLTHROUGH {
+			fallsThrough = fbr
+			body = body[:n-1]
+		}
+	}
+	if fallsThrough != nil && len(body) == 0 {
+		p.print(formfeed, "// fallthrough")
+		return
+	}
+	p.print(token.LBRACE) // Go implies new context, C doesn't
+	p.stmtList(body, 1, nextIsRBrace)
+	switch {
+	case fallsThrough != nil:
+		p.print(formfeed, fallsThrough.Pos(), "\t// fallthrough", formfeed, token.RBRACE)
+	case len(body) > 0 && terminates(body[len(body)-1]):
+		p.print(formfeed, token.RBRACE)
+	default:
+		p.print(formfeed, "\tbreak; ", token.RBRACE)
+	}
+}
+
+// caseValue prints the given switch case value as a literal of the type
+// of the switch value, e.g., 2u for a uint32 value, as the case labels
+// must be integer constants, including those of the enum types, which
+// are typed in Go but not in the shader languages.
+func (p *printer) caseValue(x ast.Expr) {
+	if tv := p.pkg.TypesInfo.Types[x]; tv.Value != nil {
+		p.print(ConstLiteral(tv.Value, tv.Type))
+		return
+	}
+	p.transError(x.Pos(), "switch case values must be constants: use a switch without a value for other cases")
+	p.expr(x)
+}
+
+// switchStmt prints the given switch statement.  An init statement is
+// defined in a block enclosing the switch statement, which has none in
+// the shader languages, and a switch statement without a value is
+// printed as an if-else chain (see taglessSwitch), as case labels must
+// be constants.
+func (p *printer) switchStmt(s *ast.SwitchStmt, nextIsRBrace bool) {
+	if s.Init != nil {
+		p.print(token.LBRACE, indent, formfeed)
+		p.stmt(s.Init, false, false)
+		p.print(formfeed)
+		p.switchStmt(&ast.SwitchStmt{Switch: s.Switch, Tag: s.Tag, Body: s.Body}, true)
+		p.print(unindent, formfeed, token.RBRACE)
+		return
+	}
+	switch {
+	case s.Tag == nil:
+		p.taglessSwitch(s, nextIsRBrace)
+	case p.wgsl():
+		p.wgslSwitch(s)
+	default:
+		p.print(token.SWITCH)
+		p.controlClause(false, nil, s.Tag, nil)
+		p.block(s.Body, 0)
+	}
+}
+
+// taglessSwitch prints the given switch statement without a value as
+// an if-else chain of its cases in order, with the values of a case
+// joined with ||, and the default case as the final else, which is
+// equivalent as the cases are evaluated in order and the default case
+// only runs if none match.  A break at the end of a case is omitted,
+// and fallthrough and other breaks of the switch are not supported.
+func (p *printer) taglessSwitch(s *ast.SwitchStmt, nextIsRBrace bool) {
+	var first, last *ast.IfStmt
+	var def *ast.BlockStmt
+	for i, st := range s.Body.List {
+		cc := st.(*ast.CaseClause)
+		rbrace := s.Body.Rbrace
+		if i < len(s.Body.List)-1 {
+			rbrace = s.Body.List[i+1].Pos()
+		}
+		list, brk := p.taglessCaseBody(cc)
+		if brk.IsValid() {
+			rbrace = brk
+		}
+		body := &ast.BlockStmt{Lbrace: cc.Colon, List: list, Rbrace: rbrace}
+		if cc.List == nil {
+			def = body
+			continue
+		}
+		cond := cc.List[0]
+		for _, x := range cc.List[1:] {
+			cond = &ast.BinaryExpr{X: cond, OpPos: x.Pos(), Op: token.LOR, Y: x}
+		}
+		is := &ast.IfStmt{If: cc.Case, Cond: cond, Body: body}
+		if first == nil {
+			first = is
 		} else {
-			vle := s.List[0]
-			if id, ok := vle.(*ast.Ident); ok {
-				if def, ok := p.pkg.TypesInfo.Uses[id]; ok {
-					if cd, ok := def.(*types.Const); ok {
-						p.print(cd.Val().String())
-						gotInt = true
-					}
+			last.Else = is
+		}
+		last = is
+	}
+	switch {
+	case first == nil && def == nil:
+		p.print(s.Body.Lbrace, token.LBRACE, s.Body.Rbrace, token.RBRACE)
+	case first == nil:
+		p.block(def, 1)
+	default:
+		if def != nil {
+			last.Else = def
+		}
+		p.stmt(first, nextIsRBrace, false)
+	}
+	p.print(s.Body.Rbrace) // the default case can be out of order
+}
+
+// taglessCaseBody returns the statements of the given case of a switch
+// statement without a value, without a final break, whose position is
+// returned if any, reporting the other breaks of the switch, and
+// fallthrough, which cannot be translated in an if-else chain.
+func (p *printer) taglessCaseBody(cc *ast.CaseClause) ([]ast.Stmt, token.Pos) {
+	body := cc.Body
+	brk := token.NoPos
+	if n := len(body); n > 0 {
+		if br, ok := body[n-1].(*ast.BranchStmt); ok && br.Tok == token.BREAK && br.Label == nil {
+			body = body[:n-1]
+			brk = br.Pos()
+		}
+	}
+	for _, st := range body {
+		ast.Inspect(st, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt, *ast.FuncLit:
+				return false
+			case *ast.BranchStmt:
+				if x.Tok == token.FALLTHROUGH || (x.Tok == token.BREAK && x.Label == nil) {
+					p.transError(x.Pos(), "%s in a switch statement without a value is not supported, as it is translated into an if-else chain", x.Tok)
 				}
-			} else if bl, ok := vle.(*ast.BasicLit); ok {
-				p.print(bl)
-				gotInt = true
-			} else {
-				fmt.Printf("gosl: unsupported switch case value: %#v\n", vle)
 			}
+			return true
+		})
+	}
+	return body, brk
+}
+
+// copyElems returns the base expression, offset expression (nil if none),
+// and number of elements of an argument to the copy builtin.
+// n is -1 if the number of elements is not a compile-time constant.
+func (p *printer) copyElems(x ast.Expr) (base, off ast.Expr, n int) {
+	n = -1
+	base = x
+	if sx, ok := x.(*ast.SliceExpr); ok {
+		base = sx.X
+		off = sx.Low
+	}
+	lenOf := func(ex ast.Expr) int {
+		tp := p.pkg.TypesInfo.TypeOf(ex)
+		if tp == nil {
+			return -1
+		}
+		if pt, ok := tp.Underlying().(*types.Pointer); ok {
+			tp = pt.Elem()
+		}
+		if at, ok := tp.Underlying().(*types.Array); ok {
+			return int(at.Len())
+		}
+		return -1
+	}
+	constOf := p.intConst
+	sx, isSlice := x.(*ast.SliceExpr)
+	if !isSlice {
+		return base, off, lenOf(x)
+	}
+	lo := 0
+	if sx.Low != nil {
+		if lo = constOf(sx.Low); lo < 0 {
+			return
 		}
-		if !gotInt {
-			fmt.Printf("%s:\n\tglslc switch only allows single-arg case values that translate to an int\n", p.pkg.Fset.PositionFor(s.Pos(), true).String())
-			p.exprList(s.Pos(), s.List, 1, 0, s.Colon, false)
+	}
+	hi := -1
+	if sx.High != nil {
+		hi = constOf(sx.High)
+	} else {
+		hi = lenOf(sx.X)
+	}
+	if hi < 0 {
+		return
+	}
+	n = hi - lo
+	return
+}
+
+// intConst returns the value of the given constant int expression,
+// or -1 if it is not a non-negative constant.
+func (p *printer) intConst(ex ast.Expr) int {
+	tv, ok := p.pkg.TypesInfo.Types[ex]
+	if !ok || tv.Value == nil {
+		return -1
+	}
+	v, ok := constant.Int64Val(tv.Value)
+	if !ok || v < 0 {
+		return -1
+	}
+	return int(v)
+}
+
+// gosl: copyStmt translates the copy(dst, src) builtin into an explicit
+// element loop, which requires the number of elements to be known
+// at translation time (arrays, or slices of arrays with constant bounds),
+// and is otherwise a translation error.  As with memmove, the loop runs
+// backwards when the elements are copied to a later offset of the same
+// array, e.g., copy(arr[1:4], arr[0:3]).
+// returns false if this is not a call to copy.
+func (p *printer) copyStmt(x *ast.CallExpr) bool {
+	id, ok := x.Fun.(*ast.Ident)
+	if !ok || id.Name != "copy" || len(x.Args) != 2 {
+		return false
+	}
+	if _, isBuiltin := p.pkg.TypesInfo.Uses[id].(*types.Builtin); !isBuiltin {
+		return false
+	}
+	dst, doff, dn := p.copyElems(x.Args[0])
+	src, soff, sn := p.copyElems(x.Args[1])
+	if dn < 0 || sn < 0 {
+		p.transError(x.Pos(), "copy requires arrays or slices of arrays with constant bounds")
+		return true
+	}
+	if dn != sn && p.Debug {
+		fmt.Printf("%s:\n\tgosl: copy sizes differ: dst: %d src: %d -- copying %d\n", p.pkg.Fset.PositionFor(x.Pos(), true).String(), dn, sn, min(dn, sn))
+	}
+	n := min(dn, sn)
+	elem := func(base, off ast.Expr) {
+		p.expr1(base, token.UnaryPrec, 1)
+		p.print(token.LBRACK)
+		if off != nil {
+			p.expr1(off, token.ADD.Precedence(), 1)
+			p.print(token.ADD)
 		}
+		p.print("_ci", token.RBRACK)
+	}
+	offOf := func(off ast.Expr) int {
+		if off == nil {
+			return 0
+		}
+		return p.intConst(off)
+	}
+	if types.ExprString(dst) == types.ExprString(src) && offOf(doff) > offOf(soff) {
+		p.print(fmt.Sprintf("for (%s = %d; _ci >= 0; _ci--) { ", p.intVar("_ci"), n-1))
 	} else {
-		p.print(token.DEFAULT)
+		p.print(fmt.Sprintf("for (%s = 0; _ci < %d; _ci++) { ", p.intVar("_ci"), n))
 	}
-	p.print(s.Colon, token.COLON)
-	if len(s.Body) == 1 {
-		if fbr, ok := s.Body[0].(*ast.BranchStmt); ok {
-			if fbr.Tok == token.FALLTHROUGH {
-				p.print(formfeed, "// fallthrough")
-				return
+	elem(dst, doff)
+	p.print(blank, token.ASSIGN, blank)
+	elem(src, soff)
+	p.print("; }")
+	return true
+}
+
+// gosl: minMax translates the min and max builtins, for ints and floats,
+// into the min and max functions of the shader language, which have two
+// arguments, so that more arguments are nested: min(a, b, c) is
+// min(a, min(b, c)), and min(a) is a.  returns false if this is not
+// a call to min or max with other than two arguments, which is printed
+// as is.
+func (p *printer) minMax(x *ast.CallExpr, depth int) bool {
+	id, ok := x.Fun.(*ast.Ident)
+	if !ok || (id.Name != "min" && id.Name != "max") || len(x.Args) == 2 || len(x.Args) == 0 {
+		return false
+	}
+	if _, isBuiltin := p.pkg.TypesInfo.Uses[id].(*types.Builtin); !isBuiltin {
+		return false
+	}
+	n := len(x.Args)
+	if n == 1 {
+		p.expr1(x.Args[0], token.HighestPrec, depth)
+		return true
+	}
+	p.print(x.Pos())
+	for _, a := range x.Args[:n-1] {
+		p.print(id.Name, token.LPAREN)
+		p.expr0(a, depth+1)
+		p.print(token.COMMA, blank)
+	}
+	p.expr0(x.Args[n-1], depth+1)
+	for range n - 1 {
+		p.print(token.RPAREN)
+	}
+	return true
+}
+
+// gosl: makeArray translates the definition of a local slice with make
+// or a slice literal, e.g., tmp := make([]float32, 4), into a local fixed
+// array, which requires the length to be a compile-time constant, greater
+// than zero, as HLSL has no empty arrays, and is otherwise a translation
+// error.  The elements of a make array are explicitly zero initialized,
+// with the zero value initializer list, as for local arrays (see localZero).
+// returns false if this is not such a definition.
+func (p *printer) makeArray(s *ast.AssignStmt) bool {
+	if s.Tok != token.DEFINE || len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+		return false
+	}
+	id, ok := s.Lhs[0].(*ast.Ident)
+	if !ok {
+		return false
+	}
+	var elt ast.Expr
+	var elts []ast.Expr
+	n := int64(-1)
+	switch x := stripParensAlways(s.Rhs[0]).(type) {
+	case *ast.CallExpr:
+		fid, ok := x.Fun.(*ast.Ident)
+		if !ok || fid.Name != "make" || len(x.Args) < 2 {
+			return false
+		}
+		if _, isBuiltin := p.pkg.TypesInfo.Uses[fid].(*types.Builtin); !isBuiltin {
+			return false
+		}
+		at, ok := x.Args[0].(*ast.ArrayType)
+		if !ok || at.Len != nil {
+			return false
+		}
+		elt = at.Elt
+		if tv := p.pkg.TypesInfo.Types[x.Args[1]]; tv.Value != nil {
+			if v, exact := constant.Int64Val(constant.ToInt(tv.Value)); exact {
+				n = v
+			}
+		}
+		if n < 0 {
+			p.transError(x.Pos(), "make requires a constant length to be translated into a local array: use a const length, declare a fixed array (e.g., var %s [4]float32), or use a buffer instead", id.Name)
+			return true
+		}
+	case *ast.CompositeLit:
+		at, ok := x.Type.(*ast.ArrayType)
+		if !ok || at.Len != nil {
+			return false
+		}
+		for _, el := range x.Elts {
+			if _, isKey := el.(*ast.KeyValueExpr); isKey {
+				p.transError(x.Pos(), "keyed slice literals cannot be translated into a local array: list all of the elements in order instead")
+				return true
 			}
 		}
+		elt = at.Elt
+		elts = x.Elts
+		n = int64(len(elts))
+	default:
+		return false
 	}
-	p.print(token.LBRACE) // Go implies new context, C doesn't
-	p.stmtList(s.Body, 1, nextIsRBrace)
-	p.print(formfeed, "\tbreak; ", token.RBRACE)
+	if n == 0 {
+		p.transError(s.Pos(), "%s has no elements, which cannot be translated into a local array, as HLSL has no empty arrays", id.Name)
+		return true
+	}
+	if p.wgsl() { // zero initialized
+		p.wgslMakeArray(id, elt, n, elts)
+		return true
+	}
+	p.expr(elt)
+	p.print(blank)
+	p.expr(id)
+	p.print(fmt.Sprintf("[%d]", n))
+	if elts != nil {
+		p.print(blank, token.ASSIGN, blank, token.LBRACE)
+		p.exprList(token.NoPos, elts, 1, 0, token.NoPos, false)
+		p.print(token.RBRACE, ";")
+		return true
+	}
+	p.print(blank, token.ASSIGN, blank, zeroValue(types.NewArray(p.pkg.TypesInfo.TypeOf(elt), n)), ";")
+	return true
+}
+
+// gosl: structAssign translates a whole-struct assignment into a
+// member-wise copy of each field, recursively for struct fields.
+// Only applies when the right hand side is an addressable
+// expression that can be safely evaluated multiple times.
+// returns false if not a struct assignment.
+func (p *printer) structAssign(s *ast.AssignStmt) bool {
+	if p.wgsl() { // structs are assigned as is
+		return false
+	}
+	if s.Tok != token.ASSIGN || len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+		return false
+	}
+	switch stripParensAlways(s.Rhs[0]).(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.StarExpr:
+	default:
+		return false
+	}
+	tp := p.pkg.TypesInfo.TypeOf(s.Lhs[0])
+	if tp == nil {
+		return false
+	}
+	st, ok := tp.Underlying().(*types.Struct)
+	if !ok || st.NumFields() == 0 || alignsl.VectorSize(st) > 0 { // vectors are assigned as is
+		return false
+	}
+	var fields func(st *types.Struct, path string)
+	fields = func(st *types.Struct, path string) {
+		for i := 0; i < st.NumFields(); i++ {
+			fl := st.Field(i)
+			if fl.Name() == "_" {
+				continue
+			}
+			fp := path + "." + fl.Name()
+			if fst, ok := fl.Type().Underlying().(*types.Struct); ok && fst.NumFields() > 0 && alignsl.VectorSize(fst) == 0 {
+				fields(fst, fp)
+				continue
+			}
+			p.expr1(stripParensAlways(s.Lhs[0]), token.UnaryPrec, 1)
+			p.print(fp, blank, token.ASSIGN, blank)
+			p.expr1(stripParensAlways(s.Rhs[0]), token.UnaryPrec, 1)
+			p.print(fp, ";", blank)
+		}
+	}
+	fields(st, "")
+	return true
 }
 
 func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
@@ -1496,6 +2035,9 @@ func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
 
 	case *ast.ExprStmt:
 		const depth = 1
+		if cx, ok := s.X.(*ast.CallExpr); ok && p.copyStmt(cx) {
+			break
+		}
 		p.expr0(s.X, depth)
 		if !nosemi {
 			p.print(";")
@@ -1516,20 +2058,35 @@ func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
 		}
 
 	case *ast.AssignStmt:
+		if p.structAssign(s) || p.makeArray(s) {
+			break
+		}
+		if p.complexAssign(s) {
+			if !nosemi {
+				p.print(";")
+			}
+			break
+		}
+		if (p.wgsl() || p.glsl() || p.metal()) && isParallelAssign(s) {
+			// gosl: WGSL has no comma operator, and GLSL and MSL do not
+			// declare multiple variables with it
+			p.simpleStmts(s)
+			break
+		}
 		var depth = 1
 		if len(s.Lhs) > 1 && len(s.Rhs) > 1 {
 			depth++
 		}
 		if s.Tok == token.DEFINE && len(s.Lhs) == 1 {
 			if lid, isId := s.Lhs[0].(*ast.Ident); isId {
-				if def, has := p.pkg.TypesInfo.Defs[lid]; has {
-					// fmt.Println(def)
-					nm := def.Type().String()
-					_, nm = filepath.Split(nm) // get rid of any paths
-					// fmt.Println(nm)
-					p.print(nm, blank)
+				def, has := p.pkg.TypesInfo.Defs[lid]
+				if has {
+					p.declStart(def.Type())
 				}
 				p.exprList(s.Pos(), s.Lhs, depth, 0, s.TokPos, false)
+				if has {
+					p.declEnd(def.Type())
+				}
 			} else {
 				p.exprList(s.Pos(), s.Lhs, depth, 0, s.TokPos, false)
 			}
@@ -1549,7 +2106,11 @@ func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
 		default:
 			p.print(blank, s.TokPos, s.Tok, blank)
 		}
-		p.exprList(s.TokPos, s.Rhs, depth, 0, token.NoPos, false)
+		if p.wgsl() && (s.Tok == token.SHL_ASSIGN || s.Tok == token.SHR_ASSIGN) {
+			p.wgslOperand(s.Tok, s.Rhs[0], true, token.LowestPrec, depth)
+		} else {
+			p.exprList(s.TokPos, s.Rhs, depth, 0, token.NoPos, false)
+		}
 		if !nosemi {
 			p.print(";")
 		}
@@ -1564,6 +2125,9 @@ func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
 
 	case *ast.ReturnStmt:
 		p.print(token.RETURN)
+		if s.Results == nil && p.curResult != nil {
+			p.print(blank, p.identName(p.curResult.Names[0]))
+		}
 		if s.Results != nil {
 			p.print(blank)
 			// Use indentList heuristic to make corner cases look
@@ -1586,6 +2150,10 @@ func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
 		}
 
 	case *ast.BranchStmt:
+		if s.Tok == token.CONTINUE {
+			p.continueStmt(s)
+			break
+		}
 		p.print(s.Tok)
 		if s.Label != nil {
 			p.print(blank)
@@ -1619,9 +2187,7 @@ func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
 		p.caseClause(s, nextIsRBrace)
 
 	case *ast.SwitchStmt:
-		p.print(token.SWITCH)
-		p.controlClause(false, s.Init, s.Tag, nil)
-		p.block(s.Body, 0)
+		p.switchStmt(s, nextIsRBrace)
 
 	case *ast.TypeSwitchStmt:
 		p.print(token.SWITCH)
@@ -1656,11 +2222,16 @@ func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
 		}
 
 	case *ast.ForStmt:
-		p.print(token.FOR)
-		p.controlClause(true, s.Init, s.Cond, s.Post)
-		p.block(s.Body, 1)
+		p.forStmt(s)
 
 	case *ast.RangeStmt:
+		defer func(post ast.Stmt) {
+			p.contPost = post
+		}(p.contPost)
+		p.contPost = nil
+		if p.intRange(s) {
+			break
+		}
 		p.print(token.FOR, blank)
 		if s.Key != nil {
 			p.expr(s.Key)
@@ -1682,6 +2253,84 @@ func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace, nosemi bool) {
 	}
 }
 
+// typeName returns the name to print for the given type of a local variable
+func (p *printer) typeName(typ types.Type) string {
+	if p.wgsl() {
+		return p.wgslTypeName(typ)
+	}
+	if nm, ok := p.glslTypeName(typ); ok && p.glsl() {
+		return nm
+	}
+	typ = types.Unalias(typ)
+	if pt, ok := typ.(*types.Pointer); ok { // gosl: no pointers in HLSL
+		typ = types.Unalias(pt.Elem())
+	}
+	if bt, ok := basicNamed(typ, p.pkg.Types); ok {
+		return bt.Name()
+	}
+	if st, ok := typ.Underlying().(*types.Struct); ok && alignsl.VectorSize(st) > 0 {
+		return vectorTypeName(st)
+	}
+	nm := typ.String()
+	_, nm = filepath.Split(nm) // get rid of any paths
+	if nt, ok := typ.(*types.Named); ok && nt.Obj().Pkg() == p.pkg.Types {
+		nm = p.objName(nt.Obj(), nt.Obj().Name())
+	}
+	return nm
+}
+
+// intRange prints a range over an integer (Go 1.22), e.g., for i := range n,
+// as a standard for loop: for (int i = 0; i < n; i++).  Note that n is
+// evaluated on each iteration, and changes to i in the loop body affect
+// the iteration, unlike in Go.
+func (p *printer) intRange(s *ast.RangeStmt) bool {
+	if p.pkg == nil || p.pkg.TypesInfo == nil || s.Value != nil {
+		return false
+	}
+	tv, ok := p.pkg.TypesInfo.Types[s.X]
+	if !ok {
+		return false
+	}
+	if bt, ok := tv.Type.Underlying().(*types.Basic); !ok || bt.Info()&types.IsInteger == 0 {
+		return false
+	}
+	typ := types.Default(tv.Type)
+	key := s.Key
+	if id, ok := key.(*ast.Ident); ok && id.Name == "_" {
+		key = nil
+	}
+	if key != nil && s.Tok == token.DEFINE {
+		if def, has := p.pkg.TypesInfo.Defs[key.(*ast.Ident)]; has && def != nil {
+			typ = def.Type()
+		}
+	}
+	printKey := func() {
+		if key != nil {
+			p.expr(key)
+		} else {
+			p.print("_i")
+		}
+	}
+	p.print(token.FOR, blank, token.LPAREN)
+	decl := key == nil || s.Tok == token.DEFINE
+	if decl {
+		p.declStart(typ)
+	}
+	printKey()
+	if decl {
+		p.declEnd(typ)
+	}
+	p.print(blank, token.ASSIGN, blank, "0", token.SEMICOLON, blank)
+	printKey()
+	p.print(blank, token.LSS, blank)
+	p.expr(stripParens(s.X))
+	p.print(token.SEMICOLON, blank)
+	printKey()
+	p.print(token.INC, token.RPAREN, blank)
+	p.block(s.Body, 1)
+	return true
+}
+
 // ----------------------------------------------------------------------------
 // Declarations
 
@@ -1751,30 +2400,70 @@ func keepTypeColumn(specs []ast.Spec) []bool {
 
 func (p *printer) valueSpec(s *ast.ValueSpec, keepType bool, tok token.Token, firstSpec *ast.ValueSpec, isIota bool, idx int) {
 	p.setComment(s.Doc)
+	if p.wgsl() {
+		p.wgslValueSpec(s, tok)
+		p.setComment(s.Comment)
+		return
+	}
+	if tok == token.VAR && p.globalVarSpec(s) {
+		p.print(";")
+		p.setComment(s.Comment)
+		return
+	}
+	guard := ""
+	if tok == token.CONST {
+		guard = p.foldedConst(s)
+	}
+	if guard != "" {
+		p.print(s.Pos(), "#ifndef "+guard, formfeed, "#define "+guard, formfeed)
+	}
 	extraTabs := 2
 	// gosl: key to use Pos() as first arg to trigger emitting of comments!
-	switch tok {
-	case token.CONST:
+	switch {
+	case tok == token.CONST && p.glsl():
+		p.print(s.Pos(), tok, blank)
+	case tok == token.CONST && p.metal():
+		p.print(s.Pos(), p.metalConstKey(s.Names[0]), blank)
+	case tok == token.CONST:
 		p.print(s.Pos(), "static", blank, tok, blank)
-	case token.TYPE:
+	case tok == token.TYPE:
 		p.print(s.Pos(), "typedef", blank)
 	}
+	if tok == token.VAR && p.groupShared {
+		p.print(p.groupSharedKey(), blank)
+	}
+	var dims []ast.Expr
+	untyped := false
 	if s.Type != nil {
-		p.expr(s.Type)
+		var elt ast.Expr
+		elt, dims = arrayDims(s.Type)
+		p.expr(elt)
 	} else if tok == token.CONST && firstSpec.Type != nil {
 		p.expr(firstSpec.Type)
+	} else if tok == token.CONST {
+		p.print(p.untypedConstType(s.Names[0]))
+	} else if tok == token.VAR {
+		untyped = p.untypedVarSpec(s, vtab)
 	}
-	p.print(vtab)
-	p.identList(s.Names, false) // always present
-	if isIota {
+	switch {
+	case untyped:
+		extraTabs--
+	case isIota:
+		p.print(vtab)
+		p.identList(s.Names, false)
 		p.print(vtab, token.ASSIGN, blank)
 		p.print(fmt.Sprintf("%d", idx))
-	} else if s.Values != nil {
-		p.print(vtab, token.ASSIGN, blank)
-		p.exprList(token.NoPos, s.Values, 1, 0, token.NoPos, false)
-		extraTabs--
+	default:
+		p.print(vtab)
+		p.valueSpecNames(s.Names, s.Values, dims, vtab, false, p.localZero(s))
+		if s.Values != nil {
+			extraTabs--
+		}
 	}
 	p.print(";")
+	if guard != "" {
+		p.print(formfeed, "#endif")
+	}
 	if s.Comment != nil {
 		for ; extraTabs > 0; extraTabs-- {
 			p.print(vtab)
@@ -1846,25 +2535,83 @@ func (p *printer) spec(spec ast.Spec, n int, doIndent bool, tok token.Token) {
 			p.internalError("expected n = 1; got", n)
 		}
 		p.setComment(s.Doc)
+		if p.wgsl() {
+			p.wgslValueSpec(s, tok)
+			p.setComment(s.Comment)
+			break
+		}
+		if tok == token.VAR && p.globalVarSpec(s) {
+			p.print(";")
+			p.setComment(s.Comment)
+			break
+		}
+		guard := ""
 		if tok == token.CONST {
-			p.print(s.Pos(), tok, blank)
+			guard = p.foldedConst(s)
+		}
+		if guard != "" {
+			p.print(s.Pos(), "#ifndef "+guard, formfeed, "#define "+guard, formfeed)
+		}
+		if tok == token.CONST {
+			if p.glsl() {
+				p.print(s.Pos(), tok, blank)
+			} else if p.metal() {
+				p.print(s.Pos(), p.metalConstKey(s.Names[0]), blank)
+			} else {
+				p.print(s.Pos(), "static", blank, tok, blank)
+			}
+			if s.Type == nil {
+				if ct := p.untypedConstType(s.Names[0]); ct != "" {
+					p.print(ct, blank)
+				}
+			}
 		} else {
 			p.print(s.Pos(), ignore)
+			if p.groupShared {
+				p.print(p.groupSharedKey(), blank)
+			}
 		}
+		var dims []ast.Expr
 		if s.Type != nil {
-			p.expr(s.Type)
+			var elt ast.Expr
+			elt, dims = arrayDims(s.Type)
+			p.expr(elt)
 			p.print(blank)
+		} else if tok == token.VAR && p.untypedVarSpec(s, blank) {
+			p.print(";")
+			if guard != "" {
+				p.print(formfeed, "#endif")
+			}
+			p.setComment(s.Comment)
+			break
 		}
-		p.identList(s.Names, doIndent) // always present
-		if s.Values != nil {
-			p.print(blank, token.ASSIGN, blank)
-			p.exprList(token.NoPos, s.Values, 1, 0, token.NoPos, false)
-		}
+		p.valueSpecNames(s.Names, s.Values, dims, blank, doIndent, p.localZero(s))
 		p.print(";")
+		if guard != "" {
+			p.print(formfeed, "#endif")
+		}
 		p.setComment(s.Comment)
 
 	case *ast.TypeSpec:
+		if s.Assign.IsValid() {
+			// gosl: aliases are replaced by the aliased type where used
+			return
+		}
+		if _, ok := s.Type.(*ast.InterfaceType); ok {
+			// gosl: interfaces are only constraints of generic functions
+			return
+		}
+		if tn, ok := p.pkg.TypesInfo.Defs[s.Name].(*types.TypeName); ok && p.glsl() {
+			if _, ok := p.glslTypedef(tn); ok { // no typedef in GLSL: as aliases
+				return
+			}
+		}
 		p.setComment(s.Doc)
+		if p.wgsl() {
+			p.wgslTypeSpec(s)
+			p.setComment(s.Comment)
+			break
+		}
 		st, isStruct := s.Type.(*ast.StructType)
 		if isStruct {
 			p.print(st.Pos(), token.STRUCT, blank)
@@ -1880,9 +2627,6 @@ func (p *printer) spec(spec ast.Spec, n int, doIndent bool, tok token.Token) {
 		// } else {
 		// 	p.print(vtab)
 		// }
-		if s.Assign.IsValid() {
-			p.print(token.ASSIGN, blank)
-		}
 		if isStruct {
 			p.expr(s.Type)
 		}
@@ -1900,8 +2644,176 @@ func (p *printer) spec(spec ast.Spec, n int, doIndent bool, tok token.Token) {
 	}
 }
 
+// untypedConstType returns the HLSL type for an untyped constant,
+// which must have an explicit type in HLSL, based on its default type.
+func (p *printer) untypedConstType(name *ast.Ident) string {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return ""
+	}
+	obj := p.pkg.TypesInfo.Defs[name]
+	if obj == nil {
+		return ""
+	}
+	bt, ok := obj.Type().Underlying().(*types.Basic)
+	if !ok {
+		return ""
+	}
+	switch {
+	case bt.Info()&types.IsBoolean != 0:
+		return "bool"
+	case bt.Info()&types.IsUnsigned != 0:
+		return "uint"
+	case bt.Info()&types.IsInteger != 0:
+		return "int"
+	case bt.Info()&types.IsFloat != 0:
+		return "float"
+	case bt.Info()&types.IsComplex != 0:
+		return "float2"
+	}
+	return ""
+}
+
+// valueSpecNames prints the names of a const or var spec, with their
+// array dimensions, and the values, which are paired with each name if
+// there is one value per name, e.g., var a, b int32 = 1, 2 is declared
+// as int a = 1, b = 2 in HLSL.  The given separator precedes the =.
+// If zero is not empty, it is the zero value assigned to each name
+// without a value (see localZero).
+func (p *printer) valueSpecNames(names []*ast.Ident, values []ast.Expr, dims []ast.Expr, sep whiteSpace, doIndent bool, zero string) {
+	if zero != "" {
+		for i, nm := range names {
+			if i > 0 {
+				p.print(token.COMMA, blank)
+			}
+			p.expr(nm)
+			p.arrayDimList(dims)
+			p.print(blank, token.ASSIGN, blank, zero)
+		}
+		return
+	}
+	if len(names) > 1 && len(values) == len(names) {
+		for i, nm := range names {
+			if i > 0 {
+				p.print(token.COMMA, blank)
+			}
+			p.expr(nm)
+			p.arrayDimList(dims)
+			p.print(blank, token.ASSIGN, blank)
+			p.expr(values[i])
+		}
+		return
+	}
+	if len(dims) == 0 {
+		p.identList(names, doIndent)
+	} else {
+		for i, nm := range names {
+			if i > 0 {
+				p.print(token.COMMA, blank)
+			}
+			p.expr(nm)
+			p.arrayDimList(dims)
+		}
+	}
+	if values != nil {
+		p.print(sep, token.ASSIGN, blank)
+		p.exprList(token.NoPos, values, 1, 0, token.NoPos, false)
+	}
+}
+
+// untypedVarSpec prints a var spec without a type, e.g., var c, d = x, 2*x,
+// with the types of the values, which are declared separately if they
+// differ, e.g., float c = x; int n = 2; returning false if the types
+// are not known.  The given separator follows the type.
+func (p *printer) untypedVarSpec(s *ast.ValueSpec, sep whiteSpace) bool {
+	if p.pkg == nil || p.pkg.TypesInfo == nil || len(s.Values) == 0 {
+		return false
+	}
+	tns := make([]string, len(s.Names))
+	same := true
+	for i, nm := range s.Names {
+		obj := p.pkg.TypesInfo.Defs[nm]
+		if obj == nil {
+			return false
+		}
+		tns[i] = p.typeName(obj.Type())
+		same = same && tns[i] == tns[0]
+	}
+	if same || len(s.Values) != len(s.Names) {
+		p.print(tns[0], sep)
+		p.valueSpecNames(s.Names, s.Values, nil, sep, false, "")
+		return true
+	}
+	for i, nm := range s.Names {
+		if i > 0 {
+			p.print(token.SEMICOLON, blank)
+		}
+		p.print(tns[i], blank)
+		p.expr(nm)
+		p.print(blank, token.ASSIGN, blank)
+		p.expr(s.Values[i])
+	}
+	return true
+}
+
+// arrayDims returns the element type and lengths of the given
+// (possibly multi-dimensional) fixed-size array type, which are
+// declared as name[len] in HLSL, or the type itself if not an array.
+func arrayDims(typ ast.Expr) (ast.Expr, []ast.Expr) {
+	var dims []ast.Expr
+	for {
+		at, ok := typ.(*ast.ArrayType)
+		if !ok || at.Len == nil {
+			return typ, dims
+		}
+		dims = append(dims, at.Len)
+		typ = at.Elt
+	}
+}
+
+// arrayDimList prints the [len] array dimensions after a declared name.
+func (p *printer) arrayDimList(dims []ast.Expr) {
+	for _, d := range dims {
+		p.print(token.LBRACK)
+		p.expr(d)
+		p.print(token.RBRACK)
+	}
+}
+
+// isGroupShared returns true if the given doc comment contains
+// the //gosl: groupshared directive, marking variables as shared
+// among the threads of a workgroup.
+func isGroupShared(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		// note: goimports reformats the directive as a "// gosl:" doc comment
+		if strings.TrimSpace(strings.TrimPrefix(c.Text, "//")) == "gosl: groupshared" {
+			return true
+		}
+	}
+	return false
+}
+
+// groupSharedKey returns the storage qualifier of groupshared variables:
+// shared in GLSL, and threadgroup in MSL.
+func (p *printer) groupSharedKey() string {
+	switch {
+	case p.glsl():
+		return "shared"
+	case p.metal():
+		return "threadgroup"
+	}
+	return "groupshared"
+}
+
 func (p *printer) genDecl(d *ast.GenDecl) {
 	p.setComment(d.Doc)
+	p.groupShared = d.Tok == token.VAR && isGroupShared(d.Doc)
+	if p.groupShared && p.metal() {
+		p.metalGroupShared(d)
+	}
+	defer func() { p.groupShared = false }()
 	// note: critical to print here to trigger comment generation in right place
 	if d.Tok == token.IMPORT {
 		p.print(d.Pos(), d.Tok, blank)
@@ -1982,7 +2894,7 @@ func (p *printer) nodeSize(n ast.Node, maxSize int) (size int) {
 	// nodeSize computation must be independent of particular
 	// style so that we always get the same decision; print
 	// in RawFormat
-	cfg := Config{Mode: RawFormat}
+	cfg := Config{Mode: RawFormat, Target: p.Target}
 	var buf bytes.Buffer
 	if err := cfg.fprint(&buf, p.pkg, p.pos, n, p.nodeSizes); err != nil {
 		return
@@ -2052,8 +2964,23 @@ func (p *printer) funcBody(headerSize int, sep whiteSpace, b *ast.BlockStmt) {
 	}(p.level)
 	p.level = 0
 
+	if p.curResult != nil || len(p.varParams) > 0 {
+		if sep != ignore {
+			p.print(blank)
+		}
+		p.print(b.Lbrace, token.LBRACE, indent, newline)
+		p.bodyDecls()
+		p.print(unindent)
+		p.stmtList(b.List, 1, true)
+		p.linebreak(p.lineFor(b.Rbrace), 1, ignore, true)
+		p.print(b.Rbrace, token.RBRACE)
+		return
+	}
+
 	const maxSize = 100
-	if headerSize+p.bodySize(b, maxSize) <= maxSize {
+	// gosl: headerSize is negative if the header is printed on a new line
+	// after an unflushed declaration, e.g., a const, so it is not reliable.
+	if headerSize >= 0 && headerSize+p.bodySize(b, maxSize) <= maxSize {
 		p.print(sep, b.Lbrace, token.LBRACE)
 		if len(b.List) > 0 {
 			p.print(blank)
@@ -2090,25 +3017,87 @@ func (p *printer) methRecvType(typ ast.Expr) string {
 	case *ast.StarExpr:
 		return p.methRecvType(x.X)
 	case *ast.Ident:
-		return x.Name
+		return p.identName(x)
 	default:
 		return fmt.Sprintf("recv type unknown: %+T", x)
 	}
 	return ""
 }
 
+// excludeFunc returns the reason the given function is excluded from
+// the output, or "" if not, as in [ExcludeFunc], except that generic
+// functions are printed as templates in HLSL 2021.
+func (p *printer) excludeFunc(d *ast.FuncDecl) string {
+	reason := ExcludeFunc(p.pkg.TypesInfo, p.ExcludeFuns, d)
+	if reason == "generic" && p.hlsl2021() {
+		return ""
+	}
+	return reason
+}
+
+// ExcludeFunc returns the reason the given function is excluded from
+// the output, or "" if not: "exclude" for the excludeFuns names, and
+// "auto" for functions with string parameters or results, which are
+// CPU-only (e.g., String methods), and "generic" for generic functions,
+// which are translated as their concrete instances.
+func ExcludeFunc(info *types.Info, excludeFuns map[string]bool, d *ast.FuncDecl) string {
+	if excludeFuns[d.Name.Name] {
+		return "exclude"
+	}
+	if d.Type.TypeParams != nil {
+		return "generic"
+	}
+	obj, ok := info.Defs[d.Name].(*types.Func)
+	if !ok {
+		return ""
+	}
+	sig := obj.Type().(*types.Signature)
+	for _, tup := range []*types.Tuple{sig.Params(), sig.Results()} {
+		for i := range tup.Len() {
+			if bt, ok := tup.At(i).Type().Underlying().(*types.Basic); ok && bt.Info()&types.IsString != 0 {
+				return "auto"
+			}
+		}
+	}
+	return ""
+}
+
 func (p *printer) funcDecl(d *ast.FuncDecl) {
 	p.setComment(d.Doc)
 	// We have to save startCol only after emitting FUNC; otherwise it can be on a
 	// different line (all whitespace preceding the FUNC is emitted only when the
 	// FUNC is emitted).
 	startCol := p.out.Column - len("func ")
-	if d.Recv != nil {
-		for ex := range p.ExcludeFuns {
-			if d.Name.Name == ex {
-				return
+	if reason := p.excludeFunc(d); reason != "" {
+		if p.Excluded != nil {
+			nm := d.Name.Name
+			if d.Recv != nil {
+				nm = p.methRecvType(d.Recv.List[0].Type) + "." + nm
 			}
+			p.Excluded(nm, reason, p.pkg.Fset.PositionFor(d.Pos(), true))
 		}
+		return
+	}
+	if p.wgsl() {
+		p.wgslFuncDecl(d, startCol)
+		return
+	}
+	p.nTemps = 0
+	p.curResult = p.resultVar(d)
+	p.shadows = p.shadowNames(d)
+	p.aliases = p.elemAliases(d)
+	p.readOnly = ReadOnlyParams(d.Doc)
+	if d.Recv != nil && (p.glsl() || p.metal()) { // function with the receiver as first param
+		p.print(d.Pos(), ignore)
+		p.signatureDecl(d)
+		p.funcBody(p.distanceFrom(d.Pos(), startCol), vtab, d.Body)
+		p.curResult = nil
+		p.shadows = nil
+		p.aliases = nil
+		p.readOnly = nil
+		return
+	}
+	if d.Recv != nil {
 		if d.Recv.List[0].Names != nil {
 			p.curFuncRecv = d.Recv.List[0].Names[0]
 			// fmt.Printf("cur func recv: %v\n", p.curFuncRecv)
@@ -2126,11 +3115,21 @@ func (p *printer) funcDecl(d *ast.FuncDecl) {
 		// p.parameters(d.Recv, funcParam) // method: print receiver
 	} else {
 		p.print(d.Pos(), ignore) // trigger emission of comments!
+		if d.Type.TypeParams != nil {
+			p.templateDecl(d)
+		}
 	}
 	// p.expr(d.Name) // gosl -- done below
 	p.signatureDecl(d)
 	p.funcBody(p.distanceFrom(d.Pos(), startCol), vtab, d.Body)
+	p.curResult = nil
+	p.shadows = nil
+	p.aliases = nil
+	p.readOnly = nil
 	if d.Recv != nil {
+		if p.hlsl2021() {
+			p.operatorDecl(d)
+		}
 		p.curFuncRecv = nil
 		p.print(unindent)
 		p.print(newline, "<<<<EndMethod>>>>", newline)
@@ -2196,5 +3195,6 @@ func (p *printer) file(src *ast.File) {
 	p.print(src.Pos(), token.PACKAGE, blank)
 	p.expr(src.Name)
 	p.declList(src.Decls)
+	p.printGlobalInits()
 	p.print(newline)
 }
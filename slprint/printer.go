@@ -12,10 +12,12 @@
 package slprint
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/build/constraint"
 	"go/token"
+	"go/types"
 	"io"
 	"os"
 	"strings"
@@ -64,6 +66,9 @@ type printer struct {
 	pkg  *packages.Package
 	fset *token.FileSet
 
+	// gosl: lines with reported unsupported uses of complex numbers
+	complexErrs map[string]bool
+
 	// Current state
 	output       []byte       // raw printer result
 	indent       int          // current indentation
@@ -102,7 +107,20 @@ type printer struct {
 	cachedPos  token.Pos
 	cachedLine int // line corresponding to cachedPos
 
-	curFuncRecv *ast.Ident // current function receiver
+	curFuncRecv *ast.Ident                // current function receiver
+	curResult   *ast.Field                // current function named result
+	groupShared bool                      // current var decl is marked with //gosl: groupshared
+	temps       map[ast.Expr]string       // temporary variables for calls in the current statement
+	nTemps      int                       // number of temporary variables in the current function
+	overwritten map[types.Object]bool     // local variables that are assigned by the statement after their declaration
+	shadows     map[types.Object]string   // new names of the local variables that shadow others in the current function
+	aliases     map[types.Object]ast.Expr // element expressions of the local pointer variables in the current function
+	contPost    ast.Stmt                  // post statement of the current lowered for loop, run before each continue
+	varParams   []*ast.Ident              // parameters of the current function that are copied into local variables, in WGSL
+	readOnly    map[string]bool           // slice parameters of the current function in //gosl: readonly directives
+	assigned    map[types.Object]bool     // package-level variables that are assigned in the package
+	errs        []error                   // translation errors, returned by Fprint
+	globalInits []*ast.ValueSpec          // package-level var specs with values computed by the init function of the file, one name per spec
 }
 
 func (p *printer) init(cfg *Config, pkg *packages.Package, pos token.Position, nodeSizes map[ast.Node]int) {
@@ -115,6 +133,16 @@ func (p *printer) init(cfg *Config, pkg *packages.Package, pos token.Position, n
 	p.cachedPos = -1
 }
 
+// transError reports a translation error at the given position, for Go
+// code that cannot be translated, which fails the generation: the
+// printing continues, and the errors are returned by Fprint.
+func (p *printer) transError(pos token.Pos, format string, args ...any) {
+	ps := p.pkg.Fset.PositionFor(pos, true).String()
+	msg := fmt.Sprintf(format, args...)
+	fmt.Printf("%s:\n\tgosl: %s\n", ps, msg)
+	p.errs = append(p.errs, fmt.Errorf("%s: gosl: %s", ps, msg))
+}
+
 func (p *printer) internalError(msg ...any) {
 	if debug {
 		fmt.Print(p.pos.String() + ": ")
@@ -930,7 +958,7 @@ func (p *printer) print(args ...any) {
 			continue
 
 		case *ast.Ident:
-			data = x.Name
+			data = p.identName(x)
 			impliedSemi = true
 			p.lastTok = token.IDENT
 
@@ -1305,6 +1333,31 @@ type Config struct {
 	Tabwidth    int  // default: 8
 	Indent      int  // default: 0 (all code is indented at least by this much)
 	ExcludeFuns map[string]bool
+	Debug       bool // enable extra translation-time checks and messages
+
+	// Excluded is called for each function that is excluded from the
+	// output, with the reason, if non-nil: ExcludeFuns functions have
+	// a reason of "exclude", and functions that cannot be translated
+	// (e.g., using strings) are automatically excluded with "auto".
+	Excluded func(name, reason string, pos token.Position)
+
+	// Renames records identifiers renamed to avoid HLSL reserved words
+	// and invalid identifiers, as original -> new name, if non-nil
+	Renames map[string]string
+
+	// ReplaceFuncs and ReplaceTypes replace the uses of the functions and
+	// types of other packages, by qualified name: package name or import
+	// path, dot, name, e.g., mymath.Exp, with the given HLSL names, e.g., exp
+	ReplaceFuncs, ReplaceTypes map[string]string
+
+	// Target is the shader language to print: TargetWGSL for WGSL,
+	// TargetGLSL for GLSL, otherwise HLSL
+	Target string
+
+	// HLSL2021 prints HLSL 2021 code, with templates for the generic
+	// functions and operator overloads (see operatorMethod), for dxc
+	// with -HV 2021
+	HLSL2021 bool
 }
 
 // fprint implements Fprint and takes a nodesSizes map for setting up the printer state.
@@ -1356,7 +1409,9 @@ func (cfg *Config) fprint(output io.Writer, pkg *packages.Package, pos token.Pos
 	if tw, _ := output.(*tabwriter.Writer); tw != nil {
 		err = tw.Flush()
 	}
-
+	if err == nil {
+		err = errors.Join(p.errs...)
+	}
 	return
 }
 
@@ -1369,6 +1424,8 @@ type CommentedNode struct {
 
 // Fprint "pretty-prints" an AST node to output for a given configuration cfg.
 // Position information is interpreted relative to the file set fset.
+// The output is written even if there are translation errors, for Go
+// code that cannot be translated, which are returned.
 // The node type must be *ast.File, *CommentedNode, []ast.Decl, []ast.Stmt,
 // or assignment-compatible to ast.Expr, ast.Decl, ast.Spec, or ast.Stmt.
 func (cfg *Config) Fprint(output io.Writer, pkg *packages.Package, pos token.Position, node any) error {
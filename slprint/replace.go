@@ -0,0 +1,37 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// replacement returns the HLSL name that replaces the function or type
+// of another package used by the given selector, from ReplaceFuncs or
+// ReplaceTypes, by import path or package name.
+func (p *printer) replacement(sel *ast.Ident) (string, bool) {
+	if p.pkg == nil || p.pkg.TypesInfo == nil || (len(p.ReplaceFuncs) == 0 && len(p.ReplaceTypes) == 0) {
+		return "", false
+	}
+	obj := p.pkg.TypesInfo.Uses[sel]
+	if obj == nil || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+		return "", false // not package level, e.g., a method or field
+	}
+	var rs map[string]string
+	switch obj.(type) {
+	case *types.Func:
+		rs = p.ReplaceFuncs
+	case *types.TypeName:
+		rs = p.ReplaceTypes
+	default:
+		return "", false
+	}
+	if to, ok := rs[obj.Pkg().Path()+"."+obj.Name()]; ok {
+		return to, true
+	}
+	to, ok := rs[obj.Pkg().Name()+"."+obj.Name()]
+	return to, ok
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReservedWords are HLSL keywords and reserved words that are valid
+// Go identifiers, which must be renamed to avoid compile errors: those
+// of the keywords and reserved words tables of the HLSL reference,
+// except for the Go keywords, e.g., for and switch, and predeclared
+// true and false, with the sized scalar types of Shader Model 6.
+var ReservedWords = map[string]bool{
+	"AppendStructuredBuffer": true, "asm": true, "asm_fragment": true, "auto": true, "bool": true, "BlendState": true,
+	"Buffer": true, "ByteAddressBuffer": true, "catch": true, "cbuffer": true, "centroid": true,
+	"char": true, "class": true, "column_major": true, "compile": true, "compile_fragment": true,
+	"CompileShader": true, "const_cast": true, "ComputeShader": true, "ConsumeStructuredBuffer": true,
+	"delete": true, "DepthStencilState": true, "DepthStencilView": true, "discard": true, "do": true,
+	"double": true, "DomainShader": true, "dword": true, "dynamic_cast": true, "explicit": true,
+	"export": true, "extern": true, "float": true, "friend": true, "fxgroup": true,
+	"GeometryShader": true, "groupshared": true, "half": true, "Hullshader": true, "in": true,
+	"inline": true, "inout": true, "InputPatch": true, "int": true, "int64_t": true, "interface": true,
+	"line": true, "lineadj": true, "linear": true, "LineStream": true, "long": true, "matrix": true,
+	"min16float": true, "min10float": true, "min16int": true, "min12int": true, "min16uint": true,
+	"mutable": true, "namespace": true, "new": true, "nointerpolation": true, "noperspective": true,
+	"NULL": true, "operator": true, "out": true, "OutputPatch": true, "packoffset": true,
+	"pass": true, "pixelfragment": true, "PixelShader": true, "point": true, "PointStream": true,
+	"precise": true, "private": true, "protected": true, "public": true, "RasterizerState": true,
+	"register": true, "reinterpret_cast": true, "RenderTargetView": true, "row_major": true,
+	"RWBuffer": true, "RWByteAddressBuffer": true, "RWStructuredBuffer": true, "RWTexture1D": true,
+	"RWTexture2D": true, "RWTexture3D": true, "sample": true, "sampler": true, "SamplerState": true,
+	"SamplerComparisonState": true, "shared": true, "short": true, "signed": true, "sizeof": true,
+	"snorm": true, "stateblock": true, "stateblock_state": true, "static": true, "static_cast": true,
+	"string": true, "struct": true, "StructuredBuffer": true, "tbuffer": true, "technique": true,
+	"technique10": true, "technique11": true, "template": true, "texture": true, "Texture1D": true,
+	"Texture2D": true, "Texture3D": true, "TextureCube": true, "this": true, "throw": true,
+	"triangle": true, "triangleadj": true, "TriangleStream": true, "try": true, "typedef": true,
+	"typename": true, "uint": true, "uniform": true, "union": true, "unorm": true, "unsigned": true,
+	"using": true, "vector": true, "vertexfragment": true, "VertexShader": true, "virtual": true,
+	"void": true, "volatile": true, "while": true,
+	"enum": true, "globallycoherent": true, "HullShader": true, "RWTexture1DArray": true,
+	"RWTexture2DArray": true, "Texture1DArray": true, "Texture2DArray": true, "Texture2DMS": true,
+	"Texture2DMSArray": true, "TextureCubeArray": true, "sampler1D": true, "sampler2D": true,
+	"sampler3D": true, "samplerCUBE": true, "int16_t": true, "int32_t": true,
+	"uint16_t": true, "uint32_t": true, "uint64_t": true, "float16_t": true, "float32_t": true,
+	"float64_t": true,
+}
+
+// SafeIdent returns a name that is safe to use as an HLSL identifier:
+// reserved words get an underscore suffix, and non-ASCII runes are
+// replaced with uXXXX codes, also with an underscore suffix.
+// The second return value is false if no renaming was needed.
+func SafeIdent(nm string) (string, bool) {
+	return safeIdent(ReservedWords, nm)
+}
+
+// safeIdent returns a name that is safe to use as an identifier,
+// as in SafeIdent, given the reserved words of the target language.
+func safeIdent(reserved map[string]bool, nm string) (string, bool) {
+	if reserved[nm] {
+		return nm + "_", true
+	}
+	if !hasNonASCII(nm) {
+		return nm, false
+	}
+	var b strings.Builder
+	for _, r := range nm {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		} else {
+			fmt.Fprintf(&b, "u%04x", r)
+		}
+	}
+	b.WriteString("_")
+	return b.String(), true
+}
+
+func hasNonASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// identName returns the name to print for given identifier, renaming
+// user-defined identifiers that collide with HLSL reserved words or that
+// are not valid HLSL identifiers, and recording the mapping in Renames.
+// Local variables that shadow others in the current function are
+// renamed as given by shadowNames.  Predeclared Go identifiers (types,
+// true, false, etc) and package names are never renamed.
+func (p *printer) identName(x *ast.Ident) string {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return x.Name
+	}
+	obj := p.pkg.TypesInfo.Defs[x]
+	if obj == nil {
+		obj = p.pkg.TypesInfo.Uses[x]
+		if tn, ok := obj.(*types.TypeName); ok && tn.IsAlias() && tn.Pkg() == p.pkg.Types {
+			return p.typeName(tn.Type())
+		}
+		if tn, ok := obj.(*types.TypeName); ok && p.glsl() {
+			if bt, ok := p.glslTypedef(tn); ok {
+				return bt.Name()
+			}
+		}
+	}
+	if nm, ok := p.shadows[obj]; ok {
+		return p.objName(obj, nm)
+	}
+	return p.objName(obj, x.Name)
+}
+
+// resolvedType returns the name to print for the given type name used
+// in a selector of another package, e.g., chans.Volts, if it is an alias
+// of a basic type or a named type with a basic underlying type, which
+// have no typedef in the HLSL code: they are printed as the basic type.
+func (p *printer) resolvedType(sel *ast.Ident) (string, bool) {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return "", false
+	}
+	tn, ok := p.pkg.TypesInfo.Uses[sel].(*types.TypeName)
+	if !ok || tn.Pkg() == nil || tn.Pkg() == p.pkg.Types {
+		return "", false
+	}
+	_, isBasic := types.Unalias(tn.Type()).(*types.Basic)
+	if _, isNamed := basicNamed(tn.Type(), p.pkg.Types); !isBasic && !isNamed {
+		return "", false
+	}
+	return p.typeName(tn.Type()), true
+}
+
+// basicNamed returns the basic underlying type of the given type if it
+// is a named type of another package than the given one with a basic
+// underlying type, e.g., type Volts float32 in a chans package, which is
+// printed as the basic type.  Named types of the given package are
+// printed by name, as they are defined by a typedef.
+func basicNamed(typ types.Type, pkg *types.Package) (*types.Basic, bool) {
+	nt, ok := types.Unalias(typ).(*types.Named)
+	if !ok || nt.Obj().Pkg() == nil || nt.Obj().Pkg() == pkg {
+		return nil, false
+	}
+	bt, ok := nt.Underlying().(*types.Basic)
+	return bt, ok
+}
+
+// objName returns the name to print for given object with given name,
+// as in identName.
+func (p *printer) objName(obj types.Object, name string) string {
+	if obj == nil || obj.Parent() == types.Universe {
+		return name
+	}
+	if _, isPkg := obj.(*types.PkgName); isPkg {
+		return name
+	}
+	reserved := ReservedWords
+	switch {
+	case p.wgsl():
+		reserved = WGSLReservedWords
+	case p.glsl():
+		reserved = GLSLReservedWords
+	case p.metal():
+		reserved = MetalReservedWords
+	}
+	nm, renamed := safeIdent(reserved, name)
+	if renamed && p.Renames != nil {
+		p.Renames[name] = nm
+	}
+	return nm
+}
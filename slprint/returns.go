@@ -0,0 +1,71 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// gosl: HLSL has no named results, so a named result, e.g.,
+// func F(x float32) (y float32), is declared as a local variable at the
+// start of the function body, zero-initialized as in Go, and a bare
+// return returns it: return y;.  Returns can be anywhere, e.g., in loops
+// and switch cases, where the break that ends each case in HLSL is only
+// added if the case does not already end with a return or other branch,
+// as it would be unreachable.
+
+// resultVar returns the field of the single named result of the given
+// function, or nil if it does not have one.  Multiple results are
+// reported as an error, as they are not supported in HLSL.
+func (p *printer) resultVar(d *ast.FuncDecl) *ast.Field {
+	res := d.Type.Results
+	if res.NumFields() > 1 {
+		p.transError(d.Pos(), "multiple return values are not supported: %s", d.Name.Name)
+		return nil
+	}
+	if res.NumFields() == 1 && len(res.List[0].Names) == 1 && res.List[0].Names[0].Name != "_" {
+		return res.List[0]
+	}
+	return nil
+}
+
+// resultDecl prints the declaration of the current named result
+// variable, initialized to its zero value, which is implicit in WGSL.
+func (p *printer) resultDecl() {
+	name := p.curResult.Names[0]
+	if p.wgsl() {
+		typ := p.pkg.TypesInfo.TypeOf(p.curResult.Type)
+		p.print(token.VAR, blank, p.identName(name), token.COLON, blank, p.wgslTypeName(typ), token.SEMICOLON)
+		return
+	}
+	p.expr(stripParensAlways(p.curResult.Type))
+	p.print(blank, p.identName(name), blank, token.ASSIGN, blank)
+	zero := "0"
+	if p.pkg != nil && p.pkg.TypesInfo != nil {
+		if obj := p.pkg.TypesInfo.Defs[name]; obj != nil {
+			zero = zeroValue(obj.Type())
+		}
+	}
+	p.print(zero, token.SEMICOLON)
+}
+
+// terminates returns true if the given statement always ends with a
+// return or other branch, so no statement after it can be reached.
+func terminates(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok == token.BREAK || s.Tok == token.CONTINUE || s.Tok == token.GOTO
+	case *ast.BlockStmt:
+		return len(s.List) > 0 && terminates(s.List[len(s.List)-1])
+	case *ast.IfStmt:
+		return s.Else != nil && terminates(s.Body) && terminates(s.Else)
+	case *ast.LabeledStmt:
+		return terminates(s.Stmt)
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+)
+
+// gosl: a local variable or constant that shadows another one of the
+// same function, e.g., x := x + 1 in an if block, where x is a parameter,
+// is renamed with a numbered suffix, e.g., x_1, that is not otherwise used
+// in the function or package.  The scoping rules of HLSL differ from Go
+// in subtle ways, e.g., in float x = x + 1; the x on the right is the new,
+// uninitialized x, so the generated code has unique names instead.
+
+// shadowNames returns the new names of the local variables and constants
+// of the given function that shadow another one in an enclosing scope of
+// the function.
+func (p *printer) shadowNames(d *ast.FuncDecl) map[types.Object]string {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return nil
+	}
+	fs := p.pkg.TypesInfo.Scopes[d.Type]
+	if fs == nil {
+		return nil
+	}
+	used := map[string]bool{}
+	var collect func(s *types.Scope)
+	collect = func(s *types.Scope) {
+		for _, nm := range s.Names() {
+			used[nm] = true
+		}
+		for i := range s.NumChildren() {
+			collect(s.Child(i))
+		}
+	}
+	collect(fs)
+
+	var names map[types.Object]string
+	var rename func(s *types.Scope, outer map[string]bool)
+	rename = func(s *types.Scope, outer map[string]bool) {
+		inner := map[string]bool{}
+		for nm := range outer {
+			inner[nm] = true
+		}
+		for _, nm := range s.Names() {
+			obj := s.Lookup(nm)
+			switch obj.(type) {
+			case *types.Var, *types.Const:
+			default:
+				continue
+			}
+			if outer[nm] {
+				nn := nm
+				for i := 1; used[nn] || p.pkg.Types.Scope().Lookup(nn) != nil; i++ {
+					nn = fmt.Sprintf("%s_%d", nm, i)
+				}
+				used[nn] = true
+				if names == nil {
+					names = map[types.Object]string{}
+				}
+				names[obj] = nn
+			}
+			inner[nm] = true
+		}
+		for i := range s.NumChildren() {
+			rename(s.Child(i), inner)
+		}
+	}
+	rename(fs, map[string]bool{})
+	return names
+}
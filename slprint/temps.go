@@ -0,0 +1,151 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// tempExprs returns the expressions in the given statement that are
+// declared as temporary variables before it: calls that return a struct
+// by value, on which a method is then called, e.g., a.Range() in
+// a.Range().Clip(x), and struct composite literals other than the values
+// of variable declarations, which are only valid in declarations in HLSL,
+// e.g., F32{Max: x} in UseF32(F32{Max: x}), innermost first.  Struct
+// literals nested in other struct literals are part of their initializer
+// list.  The conditions of if and switch statements are included, but
+// only the first operand of && and || expressions, which is always
+// evaluated.
+func (p *printer) tempExprs(stmt ast.Stmt) []ast.Expr {
+	var xs []ast.Expr
+	decls := map[ast.Expr]bool{}
+	switch s := stmt.(type) {
+	case *ast.ExprStmt:
+		xs = []ast.Expr{s.X}
+	case *ast.AssignStmt:
+		xs = s.Rhs
+		if s.Tok == token.DEFINE {
+			for _, x := range s.Rhs {
+				decls[x] = true
+			}
+		}
+	case *ast.ReturnStmt:
+		xs = s.Results
+	case *ast.DeclStmt:
+		if gd, ok := s.Decl.(*ast.GenDecl); ok && gd.Tok == token.VAR {
+			for _, sp := range gd.Specs {
+				for _, x := range sp.(*ast.ValueSpec).Values {
+					xs = append(xs, x)
+					decls[x] = true
+				}
+			}
+		}
+	case *ast.IfStmt:
+		if s.Init == nil {
+			xs = []ast.Expr{s.Cond}
+		}
+	case *ast.SwitchStmt:
+		if s.Init == nil && s.Tag != nil {
+			xs = []ast.Expr{s.Tag}
+		}
+	}
+	var temps []ast.Expr
+	inLit := 0
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BinaryExpr:
+			if x.Op == token.LAND || x.Op == token.LOR {
+				ast.Inspect(x.X, visit)
+				return false
+			}
+		case *ast.CompositeLit:
+			if !p.isStructLit(x) || p.wgsl() { // constructors in WGSL
+				return true
+			}
+			inLit++
+			for _, el := range x.Elts {
+				ast.Inspect(el, visit)
+			}
+			inLit--
+			if inLit == 0 && !decls[x] {
+				temps = append(temps, x)
+			}
+			return false
+		case *ast.CallExpr:
+			// arguments first, as they are evaluated before the call
+			ast.Inspect(x.Fun, visit)
+			for _, a := range x.Args {
+				ast.Inspect(a, visit)
+			}
+			if sel, ok := x.Fun.(*ast.SelectorExpr); ok {
+				if in, ok := stripParensAlways(sel.X).(*ast.CallExpr); ok && p.isStructCall(in) {
+					temps = append(temps, in)
+				}
+			}
+			return false
+		}
+		return true
+	}
+	for _, x := range xs {
+		ast.Inspect(x, visit)
+	}
+	return temps
+}
+
+// isStructCall returns true if the given call returns a struct by value,
+// and is not a type conversion.
+func (p *printer) isStructCall(x *ast.CallExpr) bool {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return false
+	}
+	if tv, ok := p.pkg.TypesInfo.Types[x.Fun]; !ok || tv.IsType() {
+		return false
+	}
+	tp := p.pkg.TypesInfo.TypeOf(x)
+	if tp == nil {
+		return false
+	}
+	_, ok := tp.Underlying().(*types.Struct)
+	return ok
+}
+
+// hoistTemps declares a temporary variable for each of the tempExprs
+// in the given statement, before the statement, e.g.,
+//
+//	MinMax _t0 = this.Range();
+//	float y = _t0.Clip(x);
+//
+// for y := ps.Range().Clip(x) in Go, so that methods are only called on
+// variables in HLSL, and F32 _t1 = {0, x, 0, 0}; for a struct literal.
+// The temporaries are used in place of the expressions when printing
+// the statement.
+func (p *printer) hoistTemps(stmt ast.Stmt) {
+	calls := p.tempExprs(stmt)
+	if len(calls) == 0 {
+		return
+	}
+	if p.temps == nil {
+		p.temps = map[ast.Expr]string{}
+	}
+	for _, c := range calls {
+		nm := fmt.Sprintf("_t%d", p.nTemps)
+		p.nTemps++
+		typ := p.pkg.TypesInfo.TypeOf(c)
+		p.print(c.Pos())
+		p.declStart(typ)
+		p.print(nm)
+		p.declEnd(typ)
+		p.print(blank, token.ASSIGN, blank)
+		p.expr(c)
+		p.print(";", newline)
+		p.temps[c] = nm
+	}
+}
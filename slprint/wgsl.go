@@ -0,0 +1,680 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/emer/gosl/v2/alignsl"
+)
+
+// gosl: with Config.Target = TargetWGSL, the Go code is printed as WGSL
+// instead of HLSL.  Declarations have the type after the name, e.g.,
+// var x: f32 = y; fn F(x: f32) -> f32, and struct fields are separated by
+// commas.  WGSL has no methods, so methods are functions named Type_Method,
+// with the receiver as the first parameter, a ptr<function, Type> for
+// pointer receivers, and are called as Type_Method(&x, a).  Pointers are
+// kept as in Go, as WGSL has them, so local pointer variables are let
+// declarations instead of aliases (see elemAliases).  Parameters are
+// immutable in WGSL, so those assigned in the body are copied into local
+// variables.  Struct and array literals are constructors, e.g.,
+// F32(0, x, 0, 0), which are valid anywhere, and variables are zero
+// initialized, as in Go.  Switch cases have no break, cases that only
+// fallthrough are merged into the next one, and an empty default case is
+// added if there is none, as it is required.  Operands of shift and bitwise
+// operators are parenthesized, as WGSL does not define their precedence
+// relative to other operators, and shift amounts are converted to u32.
+
+// TargetWGSL is the Config.Target for printing WGSL code
+const TargetWGSL = "wgsl"
+
+// WGSLReservedWords are WGSL keywords, reserved words, and predeclared
+// type names, that are valid Go identifiers, which must be renamed.
+var WGSLReservedWords = map[string]bool{
+	"NULL": true, "Self": true, "abstract": true, "active": true, "alias": true, "alignas": true,
+	"alignof": true, "array": true, "as": true, "asm": true, "asm_fragment": true, "async": true,
+	"atomic": true, "attribute": true, "auto": true, "await": true, "become": true, "binding_array": true,
+	"bitcast": true, "bool": true, "cast": true, "catch": true, "class": true, "co_await": true,
+	"co_return": true, "co_yield": true, "coherent": true, "column_major": true, "common": true,
+	"compile": true, "compile_fragment": true, "concept": true, "const_assert": true, "const_cast": true,
+	"consteval": true, "constexpr": true, "constinit": true, "continuing": true, "crate": true,
+	"debugger": true, "decltype": true, "delete": true, "demote": true, "demote_to_helper": true,
+	"diagnostic": true, "discard": true, "do": true, "dynamic_cast": true, "enable": true, "enum": true,
+	"explicit": true, "export": true, "extends": true, "extern": true, "external": true, "f16": true,
+	"f32": true, "filter": true, "final": true, "finally": true, "fn": true, "friend": true, "from": true,
+	"fxgroup": true, "get": true, "groupshared": true, "highp": true, "i32": true, "impl": true,
+	"implements": true, "inline": true, "instanceof": true, "layout": true, "let": true, "loop": true,
+	"lowp": true, "macro": true, "macro_rules": true, "mat2x2": true, "mat2x3": true, "mat2x4": true,
+	"mat3x2": true, "mat3x3": true, "mat3x4": true, "mat4x2": true, "mat4x3": true, "mat4x4": true,
+	"match": true, "mediump": true, "meta": true, "mod": true, "module": true, "move": true, "mut": true,
+	"mutable": true, "namespace": true, "new": true, "nil": true, "noexcept": true, "noinline": true,
+	"nointerpolation": true, "non_coherent": true, "noncoherent": true, "noperspective": true,
+	"null": true, "nullptr": true, "of": true, "operator": true, "override": true, "packoffset": true,
+	"partition": true, "pass": true, "patch": true, "pixelfragment": true, "precise": true,
+	"precision": true, "premerge": true, "priv": true, "protected": true, "ptr": true, "pub": true,
+	"public": true, "readonly": true, "ref": true, "regardless": true, "register": true,
+	"reinterpret_cast": true, "require": true, "requires": true, "resource": true, "restrict": true,
+	"sampler": true, "self": true, "set": true, "shared": true, "sizeof": true, "smooth": true,
+	"snorm": true, "static": true, "static_assert": true, "static_cast": true, "std": true,
+	"subroutine": true, "super": true, "target": true, "template": true, "this": true,
+	"thread_local": true, "throw": true, "trait": true, "try": true, "typedef": true, "typeid": true,
+	"typename": true, "typeof": true, "u32": true, "union": true, "unless": true, "unorm": true,
+	"unsafe": true, "unsized": true, "use": true, "using": true, "varying": true, "vec2": true,
+	"vec3": true, "vec4": true, "virtual": true, "volatile": true, "wgsl": true, "where": true,
+	"while": true, "with": true, "writeonly": true, "yield": true,
+}
+
+// WGSLMathVars are the WGSL expressions for the MathVars
+var WGSLMathVars = map[string]string{
+	"Infinity": "bitcast<f32>(0x7f800000u)",
+}
+
+// wgsl returns true if printing WGSL code
+func (p *printer) wgsl() bool {
+	return p.Target == TargetWGSL
+}
+
+// synth prints the given text, which is not in the Go source, e.g.,
+// a type name, without advancing the source position, so that the
+// comments after it in the source are not printed before it.
+func (p *printer) synth(text string) {
+	pos := p.pos
+	p.print(text)
+	p.pos = pos
+}
+
+// wgslTypeName returns the WGSL name of the given type
+func (p *printer) wgslTypeName(typ types.Type) string {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Basic:
+		return wgslBasicName(t)
+	case *types.Pointer:
+		return "ptr<function, " + p.wgslTypeName(t.Elem()) + ">"
+	case *types.Array:
+		return fmt.Sprintf("array<%s, %d>", p.wgslTypeName(t.Elem()), t.Len())
+	case *types.Named:
+		if t.Obj().Pkg() == p.pkg.Types {
+			return p.objName(t.Obj(), t.Obj().Name())
+		}
+		switch ut := t.Underlying().(type) {
+		case *types.Basic:
+			return wgslBasicName(ut)
+		case *types.Struct:
+			if alignsl.VectorSize(ut) > 0 {
+				return wgslVectorName(ut)
+			}
+		}
+		if t.Obj().Pkg() != nil {
+			return t.Obj().Pkg().Name() + "." + t.Obj().Name()
+		}
+		return t.Obj().Name()
+	}
+	return typ.String()
+}
+
+// wgslBasicName returns the WGSL name of the given basic type:
+// float types are f32, as WGSL has no f64, and 64 bit integer types,
+// which WGSL does not have, keep their Go name, and are reported
+// by the WGSL compiler.
+func wgslBasicName(bt *types.Basic) string {
+	switch {
+	case bt.Kind() == types.Int64 || bt.Kind() == types.Uint64:
+		return bt.Name()
+	case bt.Info()&types.IsBoolean != 0:
+		return "bool"
+	case bt.Info()&types.IsFloat != 0:
+		return "f32"
+	case bt.Info()&types.IsComplex != 0:
+		return "vec2<f32>"
+	case bt.Info()&types.IsUnsigned != 0:
+		return "u32"
+	case bt.Info()&types.IsInteger != 0:
+		return "i32"
+	}
+	return bt.Name()
+}
+
+// wgslVectorName returns the WGSL vector type name for the given
+// vector struct type, e.g., vec2<f32> for sltype.Float2.
+func wgslVectorName(st *types.Struct) string {
+	return fmt.Sprintf("vec%d<%s>", st.NumFields(), wgslBasicName(st.Field(0).Type().Underlying().(*types.Basic)))
+}
+
+// vectorName returns the name of the given vector struct type in
+// the target language.
+func (p *printer) vectorName(st *types.Struct) string {
+	switch {
+	case p.wgsl():
+		return wgslVectorName(st)
+	case p.glsl():
+		return glslVectorName(st)
+	}
+	return vectorTypeName(st)
+}
+
+// zero returns the zero value of the given type in the target language:
+// an initializer list in HLSL, and a zero value constructor in WGSL,
+// e.g., F32().
+func (p *printer) zero(typ types.Type) string {
+	if p.wgsl() {
+		return p.wgslTypeName(typ) + "()"
+	}
+	return zeroValue(typ)
+}
+
+// declStart prints the start of the declaration of a local variable of
+// the given type, before its name: the type in HLSL, e.g., float x,
+// and var in WGSL, e.g., var x: f32, or let for a pointer.
+func (p *printer) declStart(typ types.Type) {
+	if !p.wgsl() {
+		p.print(p.typeName(typ), blank)
+		return
+	}
+	if _, isPtr := types.Unalias(typ).(*types.Pointer); isPtr {
+		p.print("let", blank)
+		return
+	}
+	p.print(token.VAR, blank)
+}
+
+// declEnd prints the end of the declaration of a local variable of the
+// given type, after its name: nothing in HLSL, and the type in WGSL,
+// except for pointers, which have the address space of their value.
+func (p *printer) declEnd(typ types.Type) {
+	if !p.wgsl() {
+		return
+	}
+	if _, isPtr := types.Unalias(typ).(*types.Pointer); isPtr {
+		return
+	}
+	p.print(token.COLON, blank)
+	p.synth(p.wgslTypeName(typ))
+}
+
+// intVar returns the declaration of a local int loop variable with
+// the given name, without its value.
+func (p *printer) intVar(nm string) string {
+	if p.wgsl() {
+		return "var " + nm + ": i32"
+	}
+	return "int " + nm
+}
+
+// wgslFuncDecl prints the given function or method declaration in WGSL.
+func (p *printer) wgslFuncDecl(d *ast.FuncDecl, startCol int) {
+	p.nTemps = 0
+	p.curResult = p.resultVar(d)
+	p.shadows = p.shadowNames(d)
+	p.varParams = p.wgslVarParams(d)
+	p.print(d.Pos(), ignore) // trigger emission of comments!
+	p.wgslSignature(d)
+	p.funcBody(p.distanceFrom(d.Pos(), startCol), vtab, d.Body)
+	p.curResult = nil
+	p.shadows = nil
+	p.varParams = nil
+}
+
+// wgslSignature prints the header of the given function declaration:
+// fn Name(x: f32) -> f32, with the receiver of a method as the first
+// parameter of a function named Type_Method.
+func (p *printer) wgslSignature(d *ast.FuncDecl) {
+	p.print("fn", blank)
+	fields := d.Type.Params.List
+	if d.Recv != nil {
+		p.print(p.methodFuncName(p.pkg.TypesInfo.Defs[d.Name].(*types.Func)))
+		fields = append(d.Recv.List[:1:1], fields...)
+	} else {
+		p.expr(d.Name)
+	}
+	p.print(token.LPAREN)
+	n := 0
+	param := func(nm *ast.Ident, typ types.Type) {
+		if n > 0 {
+			p.print(token.COMMA, blank)
+		}
+		n++
+		switch {
+		case nm == nil || nm.Name == "_":
+			p.print(fmt.Sprintf("_p%d", n))
+		case p.isVarParam(nm):
+			p.print(nm.Pos(), p.identName(nm)+"_in")
+		default:
+			p.expr(nm)
+		}
+		p.print(token.COLON, blank)
+		p.synth(p.wgslTypeName(typ))
+	}
+	for _, f := range fields {
+		typ := p.pkg.TypesInfo.TypeOf(f.Type)
+		if sliceParam(f) != nil {
+			p.transError(f.Pos(), "slice parameters are only supported in HLSL, as StructuredBuffer parameters")
+		}
+		if len(f.Names) == 0 {
+			param(nil, typ)
+		}
+		for _, nm := range f.Names {
+			param(nm, typ)
+		}
+	}
+	p.print(token.RPAREN)
+	if res := d.Type.Results; res.NumFields() == 1 {
+		p.print(blank, "->", blank)
+		p.synth(p.wgslTypeName(p.pkg.TypesInfo.TypeOf(res.List[0].Type)))
+	}
+}
+
+// wgslVarParams returns the parameters and receiver of the given function
+// that are modified in its body, and must be copied into local variables
+// in WGSL, where parameters are immutable: those that are assigned, or
+// incremented, or have their address taken, or a method with a pointer
+// receiver called on them, or on one of their fields or elements.
+// Writes through pointer parameters do not modify the parameter itself.
+func (p *printer) wgslVarParams(d *ast.FuncDecl) []*ast.Ident {
+	params := map[types.Object]*ast.Ident{}
+	for _, fl := range []*ast.FieldList{d.Recv, d.Type.Params} {
+		if fl == nil {
+			continue
+		}
+		for _, f := range fl.List {
+			for _, nm := range f.Names {
+				if obj := p.pkg.TypesInfo.Defs[nm]; obj != nil {
+					params[obj] = nm
+				}
+			}
+		}
+	}
+	mod := map[*ast.Ident]bool{}
+	mark := func(x ast.Expr) {
+		for {
+			switch t := x.(type) {
+			case *ast.SelectorExpr:
+				x = t.X
+			case *ast.IndexExpr:
+				x = t.X
+			case *ast.ParenExpr:
+				x = t.X
+			case *ast.Ident:
+				obj := p.pkg.TypesInfo.Uses[t]
+				if _, isPtr := obj.Type().Underlying().(*types.Pointer); params[obj] != nil && !isPtr {
+					mod[params[obj]] = true
+				}
+				return
+			default:
+				return
+			}
+		}
+	}
+	ast.Inspect(d.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if x.Tok != token.DEFINE {
+				for _, lhs := range x.Lhs {
+					mark(lhs)
+				}
+			}
+		case *ast.RangeStmt:
+			if x.Tok == token.ASSIGN {
+				mark(x.Key)
+				if x.Value != nil {
+					mark(x.Value)
+				}
+			}
+		case *ast.IncDecStmt:
+			mark(x.X)
+		case *ast.UnaryExpr:
+			if x.Op == token.AND {
+				mark(x.X)
+			}
+		case *ast.CallExpr:
+			if id, ok := x.Fun.(*ast.Ident); ok && id.Name == "copy" && len(x.Args) == 2 {
+				mark(x.Args[0])
+			}
+			if sel, ok := x.Fun.(*ast.SelectorExpr); ok {
+				if s := p.pkg.TypesInfo.Selections[sel]; s != nil && s.Kind() == types.MethodVal {
+					if _, isPtr := s.Obj().Type().(*types.Signature).Recv().Type().(*types.Pointer); isPtr {
+						mark(sel.X)
+					}
+				}
+			}
+		}
+		return true
+	})
+	var vars []*ast.Ident
+	for _, fl := range []*ast.FieldList{d.Recv, d.Type.Params} {
+		if fl == nil {
+			continue
+		}
+		for _, f := range fl.List {
+			for _, nm := range f.Names {
+				if mod[nm] {
+					vars = append(vars, nm)
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// isVarParam returns true if the given parameter name is one of the
+// varParams of the current function.
+func (p *printer) isVarParam(nm *ast.Ident) bool {
+	for _, vp := range p.varParams {
+		if vp == nm {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyDecls prints the declarations at the start of the current function
+// body: the named result variable, and the local copies of the varParams.
+func (p *printer) bodyDecls() {
+	for i, vp := range p.varParams {
+		if i > 0 {
+			p.print(newline)
+		}
+		nm := p.identName(vp)
+		p.print(token.VAR, blank, nm, blank, token.ASSIGN, blank, nm+"_in", token.SEMICOLON)
+	}
+	if p.curResult != nil {
+		if len(p.varParams) > 0 {
+			p.print(newline)
+		}
+		p.resultDecl()
+	}
+}
+
+// wgslCall prints the given call if it is a method call, as a call of the
+// Type_Method function with the receiver as the first argument, or its
+// address for a pointer receiver, or a type conversion, with the WGSL type
+// name, e.g., i32(x), returning false otherwise.
+func (p *printer) wgslCall(x *ast.CallExpr, depth int) bool {
+	if tv, ok := p.pkg.TypesInfo.Types[x.Fun]; ok && tv.IsType() {
+		p.print(x.Pos(), p.wgslTypeName(tv.Type), x.Lparen, token.LPAREN)
+		p.exprList(x.Lparen, x.Args, depth, commaTerm, x.Rparen, false)
+		p.print(x.Rparen, token.RPAREN)
+		return true
+	}
+	fn, ptrRecv := p.methodCall(x)
+	if fn == nil {
+		return false
+	}
+	sel := x.Fun.(*ast.SelectorExpr)
+	_, ptrX := p.pkg.TypesInfo.TypeOf(sel.X).Underlying().(*types.Pointer)
+	p.print(x.Pos(), p.methodFuncName(fn), x.Lparen, token.LPAREN)
+	switch {
+	case ptrRecv && !ptrX:
+		p.print(token.AND)
+		p.expr1(sel.X, token.UnaryPrec, depth)
+	case !ptrRecv && ptrX:
+		p.print(token.MUL)
+		p.expr1(sel.X, token.UnaryPrec, depth)
+	default:
+		p.expr0(sel.X, depth)
+	}
+	for _, a := range x.Args {
+		p.print(token.COMMA, blank)
+		p.expr0(a, depth+1)
+	}
+	p.print(x.Rparen, token.RPAREN)
+	return true
+}
+
+// wgslParens returns true if the given operand of a binary expression
+// with the given operator must be parenthesized in WGSL, which only allows
+// unary operands for shift operators, and for bitwise operators, except
+// for a chain of the same operator, and does not allow mixing && and ||.
+func wgslParens(op token.Token, x ast.Expr) bool {
+	bx, ok := x.(*ast.BinaryExpr)
+	if !ok {
+		return false
+	}
+	arith := func(op token.Token) bool {
+		return op == token.ADD || op == token.SUB || op == token.MUL || op == token.QUO || op == token.REM
+	}
+	shift := func(op token.Token) bool {
+		return op == token.SHL || op == token.SHR
+	}
+	bitAnd := func(op token.Token) bool {
+		return op == token.AND || op == token.AND_NOT
+	}
+	switch op {
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+		return !arith(bx.Op)
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return !arith(bx.Op) && !shift(bx.Op)
+	case token.LAND, token.LOR:
+		return (bx.Op == token.LAND || bx.Op == token.LOR) && bx.Op != op
+	case token.AND, token.AND_NOT:
+		return !bitAnd(bx.Op)
+	case token.OR, token.XOR:
+		return bx.Op != op
+	}
+	return true
+}
+
+// wgslShiftAmount returns true if the given shift amount must be
+// converted to u32 in WGSL, as it is not unsigned or an untyped constant.
+func (p *printer) wgslShiftAmount(x ast.Expr) bool {
+	tv, ok := p.pkg.TypesInfo.Types[x]
+	if !ok {
+		return false
+	}
+	bt, ok := tv.Type.Underlying().(*types.Basic)
+	return !ok || (bt.Info()&types.IsUnsigned == 0 && bt.Info()&types.IsUntyped == 0)
+}
+
+// wgslOperand prints the given operand of a binary expression with the
+// given operator, parenthesized if needed (see wgslParens), and converted
+// to u32 for the amount of a shift in WGSL.  It is also used for GLSL,
+// where the parentheses preserve the Go precedence of the bitwise
+// operators, which is higher than that of comparisons, unlike in C.
+func (p *printer) wgslOperand(op token.Token, x ast.Expr, isY bool, prec, depth int) {
+	switch {
+	case isY && p.wgsl() && (op == token.SHL || op == token.SHR || op == token.SHL_ASSIGN || op == token.SHR_ASSIGN) && p.wgslShiftAmount(x):
+		p.print("u32", token.LPAREN)
+		p.expr0(x, depth)
+		p.print(token.RPAREN)
+	case wgslParens(op, x):
+		p.print(token.LPAREN)
+		p.expr0(x, reduceDepth(depth))
+		p.print(token.RPAREN)
+	default:
+		p.expr1(x, prec, depth)
+	}
+}
+
+// wgslSwitch prints the given switch statement in WGSL, where each case
+// is a block without a break, a case that only falls through to the
+// next one is merged into it, e.g., case A, B: {, and an empty default
+// case is added if there is none.
+func (p *printer) wgslSwitch(s *ast.SwitchStmt) {
+	p.print(token.SWITCH)
+	p.controlClause(false, nil, s.Tag, nil)
+	p.print(s.Body.Lbrace, token.LBRACE)
+	var sels []ast.Expr       // selectors of the cases that fall through
+	var first *ast.CaseClause // first of the merged cases
+	hasDefault := false
+	for i, st := range s.Body.List {
+		cc := st.(*ast.CaseClause)
+		if sels == nil {
+			first = cc
+		}
+		list := cc.List
+		if list == nil {
+			list = []ast.Expr{ast.NewIdent("default")}
+			hasDefault = true
+		}
+		if n := len(cc.Body); n > 0 {
+			if br, ok := cc.Body[n-1].(*ast.BranchStmt); ok && br.Tok == token.FALLTHROUGH {
+				if n > 1 || i == len(s.Body.List)-1 {
+					p.transError(br.Pos(), "fallthrough is only supported in WGSL for cases without other statements, which are merged into the next case")
+				} else {
+					sels = append(sels, list...)
+					continue
+				}
+			}
+		}
+		p.linebreak(p.lineFor(first.Pos()), 1, ignore, true)
+		p.print(first.Pos())
+		if sels != nil || cc.List != nil {
+			p.print(token.CASE, blank)
+		}
+		for j, x := range append(sels, list...) {
+			if j > 0 {
+				p.print(token.COMMA, blank)
+			}
+			if tv := p.pkg.TypesInfo.Types[x]; tv.Value != nil {
+				p.print(x.Pos(), ConstLiteral(tv.Value, tv.Type))
+			} else {
+				p.expr(x)
+			}
+		}
+		sels = nil
+		p.print(cc.Colon, token.COLON, blank, token.LBRACE)
+		p.stmtList(cc.Body, 1, true)
+		p.print(formfeed, token.RBRACE)
+	}
+	if !hasDefault {
+		p.print(newline, "default: {}")
+	}
+	p.linebreak(p.lineFor(s.Body.Rbrace), 1, ignore, true)
+	p.print(s.Body.Rbrace, token.RBRACE)
+}
+
+// wgslMakeArray prints the definition of a local array with the given
+// name, element type, and length, and the elements of a slice literal,
+// if any, as an array constructor.
+func (p *printer) wgslMakeArray(id *ast.Ident, elt ast.Expr, n int64, elts []ast.Expr) {
+	at := fmt.Sprintf("array<%s, %d>", p.wgslTypeName(p.pkg.TypesInfo.TypeOf(elt)), n)
+	p.print(token.VAR, blank)
+	p.expr(id)
+	p.print(token.COLON, blank, at)
+	if elts != nil {
+		p.print(blank, token.ASSIGN, blank, at, token.LPAREN)
+		p.exprList(token.NoPos, elts, 1, 0, token.NoPos, false)
+		p.print(token.RPAREN)
+	}
+	p.print(";")
+}
+
+// wgslValueSpec prints the given const or var spec in WGSL, with one
+// declaration per name, e.g., const A: i32 = 1; var x: f32 = y;
+// with var<private> for package-level variables, and var<workgroup>
+// for //gosl: groupshared ones.  Untyped constants are abstract in WGSL,
+// as in Go, and those without a value in a group, or with a value using
+// iota, have their constant value.
+func (p *printer) wgslValueSpec(s *ast.ValueSpec, tok token.Token) {
+	for i, nm := range s.Names {
+		if i > 0 {
+			p.print(blank)
+		}
+		obj := p.pkg.TypesInfo.Defs[nm]
+		if obj == nil {
+			continue
+		}
+		p.print(nm.Pos(), tok)
+		if tok == token.VAR && obj.Parent() == p.pkg.Types.Scope() {
+			if p.groupShared {
+				p.print("<workgroup>")
+			} else {
+				p.print("<private>")
+			}
+		}
+		p.print(blank)
+		p.expr(nm)
+		if bt, ok := obj.Type().(*types.Basic); tok == token.VAR || !ok || bt.Info()&types.IsUntyped == 0 {
+			p.print(token.COLON, blank)
+			p.synth(p.wgslTypeName(obj.Type()))
+		}
+		var val ast.Expr
+		if len(s.Values) == len(s.Names) {
+			val = s.Values[i]
+		}
+		if c, ok := obj.(*types.Const); ok && (val == nil || usesIota(val)) {
+			p.print(blank, token.ASSIGN, blank)
+			p.synth(ConstLiteral(c.Val(), c.Type()))
+		} else if val != nil {
+			p.print(blank, token.ASSIGN, blank)
+			p.expr(val)
+		}
+		p.print(token.SEMICOLON)
+	}
+}
+
+// usesIota returns true if the given constant expression uses iota
+func usesIota(x ast.Expr) bool {
+	found := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// wgslTypeSpec prints the given type spec in WGSL: a struct, or an
+// alias for other types, e.g., alias NeuronFlags = i32;
+func (p *printer) wgslTypeSpec(s *ast.TypeSpec) {
+	st, isStruct := s.Type.(*ast.StructType)
+	if !isStruct {
+		p.print(s.Pos(), "alias", blank)
+		p.expr(s.Name)
+		p.print(blank, token.ASSIGN, blank)
+		p.synth(p.wgslTypeName(p.pkg.TypesInfo.TypeOf(s.Type)))
+		p.print(token.SEMICOLON)
+		return
+	}
+	p.print(st.Pos(), token.STRUCT, blank)
+	p.expr(s.Name)
+	p.wgslFieldList(st.Fields)
+}
+
+// wgslFieldList prints the fields of a struct in WGSL, as name: type,
+// with a comma after each field.  Embedded fields are named by their type.
+func (p *printer) wgslFieldList(fields *ast.FieldList) {
+	p.print(blank, fields.Opening, token.LBRACE, indent, formfeed)
+	sep := vtab
+	if len(fields.List) == 1 {
+		sep = blank
+	}
+	var line int
+	prevExcluded := false
+	for i, f := range fields.List {
+		excluded := isExcludedField(f)
+		if i > 0 {
+			p.linebreak(p.lineFor(f.Pos()), 1, ignore, p.linesFrom(line) > 0 || excluded || prevExcluded)
+		}
+		prevExcluded = excluded
+		p.setComment(f.Doc)
+		p.recordLine(&line)
+		if excluded {
+			p.print(f.Pos(), excludedNote(f))
+			continue
+		}
+		typ := p.pkg.TypesInfo.TypeOf(f.Type)
+		if len(f.Names) == 0 {
+			p.print(f.Pos(), p.wgslTypeName(typ), token.COLON, sep, p.wgslTypeName(typ), token.COMMA)
+		}
+		for j, nm := range f.Names {
+			if j > 0 {
+				p.print(blank)
+			}
+			p.expr(nm)
+			p.print(token.COLON, sep)
+			p.synth(p.wgslTypeName(typ))
+			p.print(token.COMMA)
+		}
+		if f.Comment != nil {
+			p.print(sep)
+			p.setComment(f.Comment)
+		}
+	}
+	p.print(unindent, formfeed, fields.Closing, token.RBRACE)
+}
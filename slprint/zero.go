@@ -0,0 +1,80 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slprint
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// gosl: local struct and array variables declared without a value, e.g.,
+// var x SomeStruct, are zero-initialized in Go, but undefined in HLSL,
+// so they are explicitly initialized with the zero value initializer list,
+// e.g., SomeStruct x = {0, 0, 0, 0}; unless the next statement assigns
+// the whole variable without using it, e.g., x = Other(y).
+
+// localZero returns the zero value initializer list for the variables
+// of the given var spec if they are local struct or array variables
+// declared without a value, that are not overwritten by the next
+// statement, and otherwise returns "".
+func (p *printer) localZero(s *ast.ValueSpec) string {
+	if p.pkg == nil || p.pkg.TypesInfo == nil || len(s.Values) > 0 || len(s.Names) == 0 {
+		return ""
+	}
+	obj := p.pkg.TypesInfo.Defs[s.Names[0]]
+	if obj == nil || obj.Parent() == p.pkg.Types.Scope() || obj.Parent() == nil || p.overwritten[obj] {
+		return ""
+	}
+	switch obj.Type().Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return zeroValue(obj.Type())
+	}
+	return ""
+}
+
+// markOverwritten records the variable declared without a value by the
+// statement at given index in the list, if the next statement assigns
+// the whole variable without using it, so it does not need to be
+// zero-initialized.
+func (p *printer) markOverwritten(list []ast.Stmt, i int) {
+	if p.pkg == nil || p.pkg.TypesInfo == nil || i+1 >= len(list) {
+		return
+	}
+	ds, ok := list[i].(*ast.DeclStmt)
+	if !ok {
+		return
+	}
+	gd, ok := ds.Decl.(*ast.GenDecl)
+	if !ok || gd.Tok != token.VAR || len(gd.Specs) != 1 {
+		return
+	}
+	vs := gd.Specs[0].(*ast.ValueSpec)
+	if len(vs.Names) != 1 || len(vs.Values) > 0 {
+		return
+	}
+	as, ok := list[i+1].(*ast.AssignStmt)
+	if !ok || as.Tok != token.ASSIGN || len(as.Lhs) != 1 || len(as.Rhs) != 1 {
+		return
+	}
+	obj := p.pkg.TypesInfo.Defs[vs.Names[0]]
+	if id, ok := as.Lhs[0].(*ast.Ident); !ok || obj == nil || p.pkg.TypesInfo.Uses[id] != obj {
+		return
+	}
+	used := false
+	ast.Inspect(as.Rhs[0], func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && p.pkg.TypesInfo.Uses[id] == obj {
+			used = true
+		}
+		return !used
+	})
+	if used {
+		return
+	}
+	if p.overwritten == nil {
+		p.overwritten = map[types.Object]bool{}
+	}
+	p.overwritten[obj] = true
+}
@@ -11,8 +11,18 @@ import (
 	"github.com/emer/gosl/v2/sltype"
 )
 
-// These are Go versions of the same Philox2x32 based random number generator
-// functions available in .HLSL.
+// These are Go versions of the same Philox2x32 and Philox4x32 based
+// random number generator functions available in .HLSL.
+
+// Philox is the type of the counter of the random number functions,
+// which selects the generator: sltype.Uint2 for Philox2x32, with 64 bits
+// of counter and 32 bits of key, or sltype.Uint4 for Philox4x32-10,
+// with 128 bits of counter and 64 bits of key, which mixes more bits in
+// each call, for heavy use, e.g., per synapse, at about twice the cost.
+// In HLSL, the functions are overloaded for uint2 and uint4 counters.
+type Philox interface {
+	sltype.Uint2 | sltype.Uint4
+}
 
 // MulHiLo64 is the fast, simpler version when 64 bit uints become available
 func MulHiLo64(a, b uint32) (lo, hi uint32) {
@@ -61,6 +71,31 @@ func Philox2x32(counter sltype.Uint2, key uint32) sltype.Uint2 {
 	return counter
 }
 
+// Philox4x32round does one round of updating of the 4x32 counter
+func Philox4x32round(counter *sltype.Uint4, key sltype.Uint2) {
+	lo0, hi0 := MulHiLo64(0xD2511F53, counter.X)
+	lo1, hi1 := MulHiLo64(0xCD9E8D57, counter.Z)
+	counter.X, counter.Y, counter.Z, counter.W = hi1^counter.Y^key.X, lo1, hi0^counter.W^key.Y, lo0
+}
+
+// Philox4x32bumpkey does one round of updating of the 2x32 key
+func Philox4x32bumpkey(key *sltype.Uint2) {
+	key.X += 0x9E3779B9
+	key.Y += 0xBB67AE85
+}
+
+// Philox4x32 implements the stateless counter-based RNG algorithm
+// with 10 rounds, returning a random number as 4 uint32 32 bit values,
+// given a 128 bit counter and 64 bit key input that determine the result.
+func Philox4x32(counter sltype.Uint4, key sltype.Uint2) sltype.Uint4 {
+	for range 9 {
+		Philox4x32round(&counter, key)
+		Philox4x32bumpkey(&key)
+	}
+	Philox4x32round(&counter, key) // 10
+	return counter
+}
+
 // Uint32ToFloat converts a uint32 32 bit integer into a 32 bit float
 // in the (0,1) interval (i.e., exclusive of 0 and 1).
 // This differs from the Go standard by excluding 0, which is handy for passing
@@ -128,77 +163,129 @@ func CounterAdd(counter *sltype.Uint2, inc uint32) {
 	}
 }
 
+// Counter4Incr increments the given Philox4x32 counter as if it was
+// a 128 bit integer, starting with X.
+func Counter4Incr(counter *sltype.Uint4) {
+	Counter4Add(counter, 1)
+}
+
+// Counter4Add adds the given increment to the Philox4x32 counter,
+// carrying over to Y, Z and W.
+func Counter4Add(counter *sltype.Uint4, inc uint32) {
+	x := counter.X + inc
+	carry := x < counter.X
+	counter.X = x
+	if carry {
+		counter.Y++
+		if counter.Y == 0 {
+			counter.Z++
+			if counter.Z == 0 {
+				counter.W++
+			}
+		}
+	}
+}
+
 ////////////////////////////////////////////////////////////
 //   Methods below provide a standard interface
 //   with more readable names, mapping onto the Go rand methods.
 //   These are what should be called by end-user code.
 
 // Uint2 returns two uniformly distributed 32 unsigned integers,
-// based on given counter and key.
-// The counter is incremented by 1 (in a 64-bit equivalent manner)
+// based on given counter and key, with the Philox2x32 generator for an
+// sltype.Uint2 counter, or the first two of the Uint4 integers of the
+// Philox4x32 generator for an sltype.Uint4 counter (see Philox).
+// The counter is incremented by 1 (as a 64 or 128 bit integer)
 // as a result of this call, ensuring that the next call will produce
 // the next random numberin the sequence.  The key should be the
 // unique index of the element being updated.
-func Uint2(counter *sltype.Uint2, key uint32) sltype.Uint2 {
-	res := Philox2x32(*counter, key)
-	CounterIncr(counter)
+func Uint2[C Philox](counter *C, key uint32) sltype.Uint2 {
+	if c4, ok := any(counter).(*sltype.Uint4); ok {
+		res := Uint4(c4, key)
+		return sltype.Uint2{res.X, res.Y}
+	}
+	c := any(counter).(*sltype.Uint2)
+	res := Philox2x32(*c, key)
+	CounterIncr(c)
 	return res
 }
 
 // Uint32 returns a uniformly distributed 32 unsigned integer,
 // based on given counter and key.
-// The counter is incremented by 1 (in a 64-bit equivalent manner)
+// The counter is incremented by 1 (as a 64 or 128 bit integer)
+// as a result of this call, ensuring that the next call will produce
+// the next random number in the sequence.  The key should be the
+// unique index of the element being updated.
+func Uint32[C Philox](counter *C, key uint32) uint32 {
+	return Uint2(counter, key).X
+}
+
+// Uint4 returns four uniformly distributed 32 unsigned integers,
+// based on given Philox4x32 counter and key, which is the
+// sltype.Uint2{key, 0} key of Philox4x32.
+// The counter is incremented by 1 (as a 128 bit integer)
 // as a result of this call, ensuring that the next call will produce
 // the next random number in the sequence.  The key should be the
 // unique index of the element being updated.
-func Uint32(counter *sltype.Uint2, key uint32) uint32 {
-	res := Philox2x32(*counter, key)
-	CounterIncr(counter)
-	return res.X
+func Uint4(counter *sltype.Uint4, key uint32) sltype.Uint4 {
+	res := Philox4x32(*counter, sltype.Uint2{key, 0})
+	Counter4Incr(counter)
+	return res
+}
+
+// Float4 returns four uniformly distributed 32 floats
+// in range (0,1) based on given Philox4x32 counter and key.
+// The counter is incremented by 1 (as a 128 bit integer)
+// as a result of this call, ensuring that the next call will produce
+// the next random number in the sequence.  The key should be the
+// unique index of the element being updated.
+func Float4(counter *sltype.Uint4, key uint32) sltype.Float4 {
+	ur := Uint4(counter, key)
+	return sltype.Float4{Uint32ToFloat(ur.X), Uint32ToFloat(ur.Y), Uint32ToFloat(ur.Z), Uint32ToFloat(ur.W)}
 }
 
 // Float2 returns two uniformly distributed 32 floats
 // in range (0,1) based on given counter and key.
-// The counter is incremented by 1 (in a 64-bit equivalent manner)
+// The counter is incremented by 1 (as a 64 or 128 bit integer)
 // as a result of this call, ensuring that the next call will produce
 // the next random number in the sequence.  The key should be the
 // unique index of the element being updated.
-func Float2(counter *sltype.Uint2, key uint32) sltype.Float2 {
+func Float2[C Philox](counter *C, key uint32) sltype.Float2 {
 	return Uint2ToFloat(Uint2(counter, key))
 }
 
 // Float returns a uniformly distributed 32 float
 // in range (0,1) based on given counter and key.
-// The counter is incremented by 1 (in a 64-bit equivalent manner)
+// The counter is incremented by 1 (as a 64 or 128 bit integer)
 // as a result of this call, ensuring that the next call will produce
 // the next random number in the sequence.  The key should be the
 // unique index of the element being updated.
-func Float(counter *sltype.Uint2, key uint32) float32 {
+func Float[C Philox](counter *C, key uint32) float32 {
 	return Uint32ToFloat(Uint32(counter, key))
 }
 
 // Float112 returns two uniformly distributed 32 floats
 // in range [-1,1] based on given counter and key.
-// The counter is incremented by 1 (in a 64-bit equivalent manner)
+// The counter is incremented by 1 (as a 64 or 128 bit integer)
 // as a result of this call, ensuring that the next call will produce
 // the next random number in the sequence.  The key should be the
 // unique index of the element being updated.
-func Float112(counter *sltype.Uint2, key uint32) sltype.Float2 {
+func Float112[C Philox](counter *C, key uint32) sltype.Float2 {
 	return Uint2ToFloat11(Uint2(counter, key))
 }
 
 // Float11 returns a uniformly distributed 32 float
 // in range [-1,1] based on given counter and key.
-// The counter is incremented by 1 (in a 64-bit equivalent manner)
+// The counter is incremented by 1 (as a 64 or 128 bit integer)
 // as a result of this call, ensuring that the next call will produce
 // the next random number in the sequence.  The key should be the
 // unique index of the element being updated.
-func Float11(counter *sltype.Uint2, key uint32) float32 {
+func Float11[C Philox](counter *C, key uint32) float32 {
 	return Uint32ToFloat11(Uint32(counter, key))
 }
 
 // BoolP returns a bool true value with probability p
-func BoolP(counter *sltype.Uint2, key uint32, p float32) bool {
+func BoolP[C Philox](counter *C, key uint32, p float32) bool {
 	return (Float(counter, key) < p)
 }
 
@@ -213,7 +300,7 @@ func SincosPi(x float32) (s, c float32) {
 // with zero mean and unit variance.
 // This is done very efficiently using the Box-Muller algorithm
 // that consumes two random 32 bit uint32 values.
-func NormFloat2(counter *sltype.Uint2, key uint32) sltype.Float2 {
+func NormFloat2[C Philox](counter *C, key uint32) sltype.Float2 {
 	ur := Uint2(counter, key)
 	var f sltype.Float2
 	f.X, f.Y = SincosPi(Uint32ToFloat11(ur.X))
@@ -226,17 +313,57 @@ func NormFloat2(counter *sltype.Uint2, key uint32) sltype.Float2 {
 // NormFloat returns a random 32 bit floating number
 // distributed according to the normal, Gaussian distribution
 // with zero mean and unit variance.
-func NormFloat(counter *sltype.Uint2, key uint32) float32 {
+func NormFloat[C Philox](counter *C, key uint32) float32 {
 	f := NormFloat2(counter, key)
 	return f.X
 }
 
 // Uintn returns a uint32 in the range [0,n)
-func Uintn(counter *sltype.Uint2, key uint32, n uint32) uint32 {
+func Uintn[C Philox](counter *C, key uint32, n uint32) uint32 {
 	v := Float(counter, key)
 	return uint32(v * float32(n))
 }
 
+// Gamma returns a random 32 bit floating number distributed according
+// to the gamma distribution with given shape (k) and scale (theta),
+// which has a mean of shape * scale, using the Marsaglia-Tsang
+// rejection method, for shape >= 1, which consumes two counter
+// increments per try, and accepts over 95% of the tries.
+// For shape < 1, the result for shape + 1 is multiplied by
+// Float^(1 / shape), which consumes one more counter increment.
+func Gamma[C Philox](counter *C, key uint32, shape, scale float32) float32 {
+	boost := float32(1)
+	if shape < 1 {
+		boost = math32.Pow(Float(counter, key), 1/shape)
+		shape += 1
+	}
+	d := shape - 1.0/3.0
+	c := 1 / math32.Sqrt(9*d)
+	for {
+		x := NormFloat(counter, key)
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := Float(counter, key)
+		x2 := x * x
+		if u < 1-0.0331*x2*x2 || math32.Log(u) < 0.5*x2+d*(1-v+math32.Log(v)) {
+			return scale * boost * d * v
+		}
+	}
+}
+
+// Beta returns a random 32 bit floating number in the (0,1) interval
+// distributed according to the beta distribution with given a (alpha)
+// and b (beta) shape parameters, which has a mean of a / (a + b),
+// from two Gamma numbers with unit scale: x / (x + y).
+func Beta[C Philox](counter *C, key uint32, a, b float32) float32 {
+	x := Gamma(counter, key, a, 1)
+	y := Gamma(counter, key, b, 1)
+	return x / (x + y)
+}
+
 // Counter is used for storing the random counter using aligned 16 byte storage,
 // with convenience methods for typical use cases.
 // It retains a copy of the last Seed value, which is applied to the Hi uint32 value.
@@ -283,6 +410,17 @@ func (ct *Counter) Seed(seed uint32) {
 	ct.HiSeed = seed
 }
 
+// Pin sets the counter for given invocation index of given kernel,
+// as a function of these values and the Seed only, so that the random
+// numbers do not depend on the number of random numbers generated in
+// other invocations or kernels, nor on the order of their dispatch,
+// for reproducible results across runs and machines.
+// The starting point is itself random over the full 64 bits,
+// so sequences of different invocations are very unlikely to overlap.
+func (ct *Counter) Pin(kernel, invocation uint32) {
+	ct.Set(Philox2x32(sltype.Uint2{invocation, kernel}, ct.HiSeed))
+}
+
 // Add increments the counter by given amount.
 // Call this after thread completion with number of random numbers
 // generated per thread.
@@ -292,3 +430,55 @@ func (ct *Counter) Add(inc uint32) sltype.Uint2 {
 	ct.Set(c)
 	return c
 }
+
+// Counter4 is used for storing the random counter of the Philox4x32
+// generator (see Philox) using aligned 16 byte storage, as Counter.
+// It retains a copy of the last Seed value, which is applied to the W value.
+type Counter4 struct {
+
+	// counter, incremented from X
+	Counter sltype.Uint4
+
+	// last seed value set by Seed method, restored by Reset()
+	HiSeed uint32
+
+	pad, pad1, pad2 uint32
+}
+
+// Reset resets counter to last set Seed state
+func (ct *Counter4) Reset() {
+	ct.Counter = sltype.Uint4{W: ct.HiSeed}
+}
+
+// Uint4 returns counter as a Uint4
+func (ct *Counter4) Uint4() sltype.Uint4 {
+	return ct.Counter
+}
+
+// Set sets the counter from a Uint4
+func (ct *Counter4) Set(c sltype.Uint4) {
+	ct.Counter = c
+}
+
+// Seed sets the W uint32 value from given seed, saving it in HiSeed field.
+// Each increment in seed generates a unique sequence of 2^96 numbers.
+// Resets X, Y and Z to 0.
+// This same seed will be restored during Reset
+func (ct *Counter4) Seed(seed uint32) {
+	ct.HiSeed = seed
+	ct.Reset()
+}
+
+// Pin sets the counter for given invocation index of given kernel,
+// as a function of these values and the Seed only, as Counter.Pin.
+func (ct *Counter4) Pin(kernel, invocation uint32) {
+	ct.Set(Philox4x32(sltype.Uint4{X: invocation, Y: kernel}, sltype.Uint2{ct.HiSeed, 0}))
+}
+
+// Add increments the counter by given amount.
+// Call this after thread completion with number of random numbers
+// generated per thread.
+func (ct *Counter4) Add(inc uint32) sltype.Uint4 {
+	Counter4Add(&ct.Counter, inc)
+	return ct.Counter
+}
@@ -7,7 +7,8 @@
 
 // These random number generation (RNG) functions are optimized for
 // use on the GPU, with equivalent Go versions available in slrand.go.
-// This is using the Philox2x32 counter-based RNG.
+// This is using the Philox2x32 counter-based RNG, or Philox4x32-10 for
+// uint4 counters, for which each of the Rand functions is overloaded.
 
 // vulkan glslang does not support 64 bit integers:
 // https://github.com/KhronosGroup/glslang/issues/2965
@@ -83,6 +84,35 @@ uint2 Philox2x32(uint2 counter, uint key) {
 	return counter;
 }
 
+// Philox4x32round does one round of updating of the 4x32 counter
+void Philox4x32round(inout uint4 counter, uint2 key) {
+	uint hi0;
+	uint lo0;
+	uint hi1;
+	uint lo1;
+	MulHiLo32(0xD2511F53, counter.x, lo0, hi0);
+	MulHiLo32(0xCD9E8D57, counter.z, lo1, hi1);
+	counter = uint4(hi1 ^ counter.y ^ key.x, lo1, hi0 ^ counter.w ^ key.y, lo0);
+}
+
+// Philox4x32bumpkey does one round of updating of the 2x32 key
+void Philox4x32bumpkey(inout uint2 key) {
+	key.x += uint(0x9E3779B9);
+	key.y += uint(0xBB67AE85);
+}
+
+// Philox4x32 implements the stateless counter-based RNG algorithm
+// with 10 rounds, returning a random number as 4 uint32 32 bit values,
+// given a 128 bit counter and 64 bit key input that determine the result.
+uint4 Philox4x32(uint4 counter, uint2 key) {
+	for (int i = 0; i < 9; i++) {
+		Philox4x32round(counter, key);
+		Philox4x32bumpkey(key);
+	}
+	Philox4x32round(counter, key); // 10
+	return counter;
+}
+
 // UintToFloat converts a uint 32 bit integer into a 32 bit float
 // in the (0,1) interval (i.e., exclusive of 1).
 // This differs from the Go standard by excluding 0, which is handy for passing
@@ -149,6 +179,29 @@ void CounterAdd(inout uint2 counter, uint inc) {
 	}
 }
 
+// Counter4Add adds the given increment to the Philox4x32 counter,
+// carrying over to y, z and w.
+void Counter4Add(inout uint4 counter, uint inc) {
+	uint x = counter.x + inc;
+	bool carry = x < counter.x;
+	counter.x = x;
+	if(carry) {
+		counter.y++;
+		if(counter.y == 0) {
+			counter.z++;
+			if(counter.z == 0) {
+				counter.w++;
+			}
+		}
+	}
+}
+
+// Counter4Incr increments the given Philox4x32 counter as if it was
+// a 128 bit integer, starting with x.
+void Counter4Incr(inout uint4 counter) {
+	Counter4Add(counter, 1);
+}
+
 ////////////////////////////////////////////////////////////
 //   Methods below provide a standard interface
 //   with more readable names, mapping onto the Go rand methods.
@@ -258,6 +311,163 @@ uint RandUintn(inout uint2 counter, uint key, uint n) {
 	return uint(v * float(n));
 }
 
+// RandGamma returns a random 32 bit floating number distributed according
+// to the gamma distribution with given shape (k) and scale (theta),
+// which has a mean of shape * scale, using the Marsaglia-Tsang
+// rejection method, for shape >= 1, which consumes two counter
+// increments per try, and accepts over 95% of the tries.
+// For shape < 1, the result for shape + 1 is multiplied by
+// RandFloat^(1 / shape), which consumes one more counter increment.
+float RandGamma(inout uint2 counter, uint key, float shape, float scale) {
+	float boost = 1.0;
+	if (shape < 1.0) {
+		boost = pow(RandFloat(counter, key), 1.0 / shape);
+		shape += 1.0;
+	}
+	float d = shape - 1.0 / 3.0;
+	float c = 1.0 / sqrt(9.0 * d);
+	for (;;) {
+		float x = RandNormFloat(counter, key);
+		float v = 1.0 + c * x;
+		if (v <= 0.0) {
+			continue;
+		}
+		v = v * v * v;
+		float u = RandFloat(counter, key);
+		float x2 = x * x;
+		if (u < 1.0 - 0.0331 * x2 * x2 || log(u) < 0.5 * x2 + d * (1.0 - v + log(v))) {
+			return scale * boost * d * v;
+		}
+	}
+	return 0.0;
+}
+
+// RandBeta returns a random 32 bit floating number in the (0,1) interval
+// distributed according to the beta distribution with given a (alpha)
+// and b (beta) shape parameters, which has a mean of a / (a + b),
+// from two RandGamma numbers with unit scale: x / (x + y).
+float RandBeta(inout uint2 counter, uint key, float a, float b) {
+	float x = RandGamma(counter, key, a, 1.0);
+	float y = RandGamma(counter, key, b, 1.0);
+	return x / (x + y);
+}
+
+////////////////////////////////////////////////////////////
+//   Overloads of the methods above for Philox4x32 uint4 counters,
+//   which use the uint2(key, 0) key of Philox4x32.
+
+// RandUint4 returns four uniformly distributed 32 unsigned integers,
+// based on given Philox4x32 counter and key.
+// The counter is incremented by 1 (as a 128 bit integer)
+// as a result of this call, ensuring that the next call will produce
+// the next random number in the sequence.  The key should be the 
+// unique index of the element being updated.
+uint4 RandUint4(inout uint4 counter, uint key) {
+	uint4 res = Philox4x32(counter, uint2(key, 0));
+	Counter4Incr(counter);
+	return res;
+}
+
+// RandFloat4 returns four uniformly distributed 32 floats
+// in range (0,1) based on given Philox4x32 counter and key.
+float4 RandFloat4(inout uint4 counter, uint key) {
+	uint4 ur = RandUint4(counter, key);
+	return float4(UintToFloat(ur.x), UintToFloat(ur.y), UintToFloat(ur.z), UintToFloat(ur.w));
+}
+
+// RandUint2 returns the first two of the RandUint4 integers
+// for a Philox4x32 counter.
+uint2 RandUint2(inout uint4 counter, uint key) {
+	return RandUint4(counter, key).xy;
+}
+
+// RandUint returns the first of the RandUint4 integers
+// for a Philox4x32 counter.
+uint RandUint(inout uint4 counter, uint key) {
+	return RandUint4(counter, key).x;
+}
+
+// RandFloat2 for a Philox4x32 counter
+float2 RandFloat2(inout uint4 counter, uint key) {
+	return Uint2ToFloat(RandUint2(counter, key));
+}
+
+// RandFloat for a Philox4x32 counter
+float RandFloat(inout uint4 counter, uint key) {
+	return UintToFloat(RandUint(counter, key));
+}
+
+// RandFloat112 for a Philox4x32 counter
+float2 RandFloat112(inout uint4 counter, uint key) {
+	return Uint2ToFloat11(RandUint2(counter, key));
+}
+
+// RandFloat11 for a Philox4x32 counter
+float RandFloat11(inout uint4 counter, uint key) {
+	return UintToFloat11(RandUint(counter, key));
+}
+
+// RandBoolP for a Philox4x32 counter
+bool RandBoolP(inout uint4 counter, uint key, float p) {
+	return (RandFloat(counter, key) < p);
+}
+
+// RandNormFloat2 for a Philox4x32 counter
+float2 RandNormFloat2(inout uint4 counter, uint key) {
+	uint2 ur = RandUint2(counter, key);
+	float r;
+	float2 f;
+	sincospi(UintToFloat11(ur.x), f.x, f.y);
+	r = sqrt(-2. * log(UintToFloat(ur.y))); // guaranteed to avoid 0.
+	f.x *= r;
+	f.y *= r;
+	return f;
+}
+
+// RandNormFloat for a Philox4x32 counter
+float RandNormFloat(inout uint4 counter, uint key) {
+	float2 f = RandNormFloat2(counter, key);
+	return f.x;
+}
+
+// RandUintn for a Philox4x32 counter
+uint RandUintn(inout uint4 counter, uint key, uint n) {
+	float v = RandFloat(counter, key);
+	return uint(v * float(n));
+}
+
+// RandGamma for a Philox4x32 counter
+float RandGamma(inout uint4 counter, uint key, float shape, float scale) {
+	float boost = 1.0;
+	if (shape < 1.0) {
+		boost = pow(RandFloat(counter, key), 1.0 / shape);
+		shape += 1.0;
+	}
+	float d = shape - 1.0 / 3.0;
+	float c = 1.0 / sqrt(9.0 * d);
+	for (;;) {
+		float x = RandNormFloat(counter, key);
+		float v = 1.0 + c * x;
+		if (v <= 0.0) {
+			continue;
+		}
+		v = v * v * v;
+		float u = RandFloat(counter, key);
+		float x2 = x * x;
+		if (u < 1.0 - 0.0331 * x2 * x2 || log(u) < 0.5 * x2 + d * (1.0 - v + log(v))) {
+			return scale * boost * d * v;
+		}
+	}
+	return 0.0;
+}
+
+// RandBeta for a Philox4x32 counter
+float RandBeta(inout uint4 counter, uint key, float a, float b) {
+	float x = RandGamma(counter, key, a, 1.0);
+	float y = RandGamma(counter, key, b, 1.0);
+	return x / (x + y);
+}
+
 // Counter is used for storing the random counter using aligned 16 byte storage,
 // with convenience methods for typical use cases.
 // It retains a copy of the last Seed value, which is applied to the Hi uint32 value.
@@ -311,3 +521,44 @@ struct RandCounter {
 	}
 };
 
+// RandCounter4 is used for storing the random counter of the Philox4x32
+// generator using aligned 16 byte storage, as RandCounter.
+// It retains a copy of the last Seed value, which is applied to the w value.
+struct RandCounter4 {
+	uint4 Counter;
+	uint HiSeed;
+
+	uint pad, pad1, pad2;
+
+	// Reset resets counter to last set Seed state
+	void Reset() {
+		this.Counter = uint4(0, 0, 0, this.HiSeed);
+	}
+
+	// Uint4 returns counter as a Uint4
+	uint4 Uint4() {
+		return this.Counter;
+	}
+
+	// Set sets the counter from a Uint4
+	void Set(uint4 c) {
+		this.Counter = c;
+	}
+
+	// Seed sets the w uint32 value from given seed, saving it in HiSeed field.
+	// Each increment in seed generates a unique sequence of 2^96 numbers.
+	// Resets x, y and z to 0.
+	// This same seed will be restored during Reset
+	void Seed(uint seed) {
+		this.HiSeed = seed;
+		this.Reset();
+	}
+
+	// Add increments the counter by given amount.
+	// Call this after thread completion with number of random numbers
+	// generated per thread.
+	uint4 Add(int inc) {
+		Counter4Add(this.Counter, inc);
+		return this.Counter;
+	}
+};
@@ -115,3 +115,91 @@ func TestIntn(t *testing.T) {
 		// fmt.Printf("%d\t%d\n", i, r)
 	}
 }
+
+func TestCounterPin(t *testing.T) {
+	var a, b Counter
+	a.Seed(10)
+	b.Seed(10)
+	a.Add(100) // numbers generated previously do not matter
+	a.Pin(2, 5)
+	b.Pin(2, 5)
+	if a.Uint2() != b.Uint2() {
+		t.Errorf("pinned counters differ: %v != %v", a.Uint2(), b.Uint2())
+	}
+	b.Pin(2, 6)
+	if a.Uint2() == b.Uint2() {
+		t.Errorf("pinned counters of different invocations are the same")
+	}
+}
+
+func TestGammaBeta(t *testing.T) {
+	moments := func(f func(ctr *sltype.Uint2, key uint32) float32) (mean, vr float64) {
+		var counter sltype.Uint2
+		n := 20000
+		var sum, ss float64
+		for i := range n {
+			v := float64(f(&counter, uint32(i%16)))
+			sum += v
+			ss += v * v
+		}
+		mean = sum / float64(n)
+		return mean, ss/float64(n) - mean*mean
+	}
+	for _, tv := range []struct {
+		shape, scale float32
+	}{{2, 3}, {0.5, 1}, {9, 0.5}} {
+		mean, vr := moments(func(ctr *sltype.Uint2, key uint32) float32 { return Gamma(ctr, key, tv.shape, tv.scale) })
+		wm, wv := float64(tv.shape*tv.scale), float64(tv.shape*tv.scale*tv.scale)
+		if math.Abs(mean-wm) > 0.05*wm || math.Abs(vr-wv) > 0.1*wv {
+			t.Errorf("Gamma(%g, %g): mean %g, var %g, want %g, %g", tv.shape, tv.scale, mean, vr, wm, wv)
+		}
+	}
+	mean, _ := moments(func(ctr *sltype.Uint2, key uint32) float32 { return Beta(ctr, key, 2, 5) })
+	if math.Abs(mean-2.0/7.0) > 0.01 {
+		t.Errorf("Beta(2, 5): mean %g, want %g", mean, 2.0/7.0)
+	}
+
+	a := sltype.Uint2{X: 7, Y: 3}
+	b := a
+	if Beta(&a, 4, 0.5, 2) != Beta(&b, 4, 0.5, 2) || a != b {
+		t.Errorf("Beta is not deterministic given the same counter and key")
+	}
+}
+
+// Known Answer Test for Philox4x32-10 values from the DEShawREsearch reference impl
+func TestKAT4x32(t *testing.T) {
+	kats := []struct {
+		ctr sltype.Uint4
+		key sltype.Uint2
+		res sltype.Uint4
+	}{{sltype.Uint4{0, 0, 0, 0}, sltype.Uint2{0, 0}, sltype.Uint4{0x6627e8d5, 0xe169c58d, 0xbc57ac4c, 0x9b00dbd8}},
+		{sltype.Uint4{0xffffffff, 0xffffffff, 0xffffffff, 0xffffffff}, sltype.Uint2{0xffffffff, 0xffffffff}, sltype.Uint4{0x408f276d, 0x41c83b0e, 0xa20bc7c6, 0x6d5451fd}},
+		{sltype.Uint4{0x243f6a88, 0x85a308d3, 0x13198a2e, 0x03707344}, sltype.Uint2{0xa4093822, 0x299f31d0}, sltype.Uint4{0xd16cfe09, 0x94fdcceb, 0x5001e420, 0x24126ea1}}}
+
+	for _, tv := range kats {
+		r := Philox4x32(tv.ctr, tv.key)
+		if r != tv.res {
+			t.Errorf("ctr: %v  key: %v != result: %x -- got: %x", tv.ctr, tv.key, tv.res, r)
+		}
+	}
+}
+
+func TestCounter4(t *testing.T) {
+	ctr := sltype.Uint4{X: 0xfffffffe, Y: 0xffffffff, Z: 0xffffffff}
+	Counter4Add(&ctr, 3)
+	if ctr != (sltype.Uint4{X: 1, W: 1}) {
+		t.Errorf("Should be 1, 0, 0, 1: %v", ctr)
+	}
+	var c4 Counter4
+	c4.Seed(10)
+	a := Float(&c4.Counter, 3)
+	c4.Reset()
+	b := Float4(&c4.Counter, 3)
+	if a != b.X || c4.Counter != (sltype.Uint4{X: 1, W: 10}) {
+		t.Errorf("Float and Float4 should use the same counter: %g != %g, %v", a, b.X, c4.Counter)
+	}
+	var c2 sltype.Uint2
+	if Float(&c2, 3) == a {
+		t.Errorf("Philox2x32 and Philox4x32 should differ")
+	}
+}
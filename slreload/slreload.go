@@ -0,0 +1,217 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package slreload supports hot-reloading of recompiled SPIR-V shader
+files into a running process, e.g., for interactive tuning of a model:
+edit a Go region, regenerate with gosl (or keep gosl -watch running),
+and the running sim picks up the new kernels without a restart.
+
+A Watcher polls the modification times of the .spv files, and records
+those that changed.  It never touches the GPU itself: the Apply method
+must be called by the code that dispatches the kernels, between
+dispatches, and it calls the Reload function for each changed file,
+which rebuilds the pipeline in the GPU binding layer, e.g., for vgpu:
+
+	w := slreload.NewWatcher(func(name, spv string) error {
+		sy.Device.DeviceWaitIdle() // no dispatches in flight
+		pl := sy.PipelineMap[name]
+		pl.Destroy()
+		if err := pl.Shaders[0].OpenFile(sy.Device.Device, spv); err != nil {
+			return err
+		}
+		pl.Config() // any command buffers must be recorded again
+		return nil
+	}, KernelSPVs[:]...)
+	w.Start(500 * time.Millisecond)
+	defer w.Stop()
+	...
+	for cyc := range ncycles {
+		w.Apply() // reload between dispatches
+		pl.ComputeDispatch(cmd, nGps, 1, 1)
+		...
+	}
+
+When gosl is run with -watch, it writes the SignalFile in its output
+directory after all of the shaders have been regenerated and compiled,
+and a Watcher with a Signal file only reports changes after that file
+is updated, so partially written files are never loaded.  Otherwise,
+a change is only reported once the file has been stable for one poll.
+*/
+package slreload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignalFile is the name of the file that gosl -watch writes in its
+// output directory after regenerating and compiling the shaders.
+const SignalFile = "reload.stamp"
+
+// Watcher watches SPIR-V files for changes, and reloads the changed
+// ones when Apply is called between dispatches.
+type Watcher struct {
+
+	// Reload is called by Apply for each changed file, with the kernel
+	// name (the file name without extension) and the path to the file.
+	// It must rebuild the pipeline for the kernel.
+	Reload func(name, spv string) error
+
+	// Signal is the path to a signal file (e.g., shaders/reload.stamp
+	// written by gosl -watch) that must be updated for changes to
+	// be reported, if non-empty.  It is set by NewWatcher if the
+	// SignalFile exists in the directory of the first file.
+	Signal string
+
+	// mu protects the fields below, which are accessed by the
+	// polling goroutine and by Apply.
+	mu sync.Mutex
+
+	// last modification times of the files that have been reloaded,
+	// or were present at the start.
+	modTimes map[string]time.Time
+
+	// modification times seen in the last poll, for changed files
+	// that are pending stability.
+	pending map[string]time.Time
+
+	// files that have changed and need to be reloaded.
+	changed map[string]bool
+
+	// last modification time of the signal file
+	signalTime time.Time
+
+	// closed to stop the polling goroutine
+	stop chan struct{}
+}
+
+// NewWatcher returns a new Watcher for the given SPIR-V files,
+// with the given Reload function.
+func NewWatcher(reload func(name, spv string) error, files ...string) *Watcher {
+	w := &Watcher{Reload: reload, modTimes: map[string]time.Time{}, pending: map[string]time.Time{}, changed: map[string]bool{}}
+	for _, fn := range files {
+		w.Add(fn)
+	}
+	if len(files) > 0 {
+		sig := filepath.Join(filepath.Dir(files[0]), SignalFile)
+		if st, err := os.Stat(sig); err == nil {
+			w.Signal = sig
+			w.signalTime = st.ModTime()
+		}
+	}
+	return w
+}
+
+// Add adds the given SPIR-V file to be watched.
+func (w *Watcher) Add(spv string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var mt time.Time
+	if st, err := os.Stat(spv); err == nil {
+		mt = st.ModTime()
+	}
+	w.modTimes[spv] = mt
+}
+
+// Poll checks the files for changes, and returns the number of files
+// that are changed and waiting to be reloaded by Apply.
+// It is called periodically after Start.
+func (w *Watcher) Poll() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Signal != "" {
+		st, err := os.Stat(w.Signal)
+		if err != nil || !st.ModTime().After(w.signalTime) {
+			return len(w.changed)
+		}
+		w.signalTime = st.ModTime()
+	}
+	for fn, mt := range w.modTimes {
+		st, err := os.Stat(fn)
+		if err != nil || st.Size() == 0 || st.ModTime().Equal(mt) {
+			delete(w.pending, fn) // missing (e.g., being regenerated) or unchanged
+			continue
+		}
+		if w.Signal == "" {
+			if pt, ok := w.pending[fn]; !ok || !pt.Equal(st.ModTime()) {
+				w.pending[fn] = st.ModTime() // wait for it to be stable
+				continue
+			}
+			delete(w.pending, fn)
+		}
+		w.modTimes[fn] = st.ModTime()
+		w.changed[fn] = true
+	}
+	return len(w.changed)
+}
+
+// Start starts polling the files for changes at given interval,
+// in a separate goroutine, until Stop is called.
+func (w *Watcher) Start(interval time.Duration) {
+	w.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+				w.Poll()
+			}
+		}
+	}(w.stop)
+}
+
+// Stop stops polling started by Start.
+func (w *Watcher) Stop() {
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// Changed returns the names of the kernels that are changed and
+// waiting to be reloaded by Apply.
+func (w *Watcher) Changed() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var nms []string
+	for fn := range w.changed {
+		nms = append(nms, KernelName(fn))
+	}
+	return nms
+}
+
+// Apply calls Reload for each changed file, and returns any errors.
+// It must be called between dispatches, by the code that dispatches
+// the kernels, so that the pipelines are never rebuilt while in use.
+// A file that fails to reload is retried after its next change.
+func (w *Watcher) Apply() error {
+	w.mu.Lock()
+	var fns []string
+	for fn := range w.changed {
+		fns = append(fns, fn)
+	}
+	clear(w.changed)
+	w.mu.Unlock()
+	var errs []error
+	for _, fn := range fns {
+		if err := w.Reload(KernelName(fn), fn); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// KernelName returns the kernel name for given SPIR-V file,
+// which is the file name without extension.
+func KernelName(spv string) string {
+	return strings.TrimSuffix(filepath.Base(spv), filepath.Ext(spv))
+}
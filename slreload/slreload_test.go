@@ -0,0 +1,70 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slreload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, fn string, mt time.Time) {
+	if err := os.WriteFile(fn, []byte("spv"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(fn, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	spv := filepath.Join(dir, "axon.spv")
+	t0 := time.Now().Add(-time.Hour)
+	writeFile(t, spv, t0)
+	var reloaded []string
+	w := NewWatcher(func(name, spv string) error {
+		reloaded = append(reloaded, name)
+		return nil
+	}, spv)
+	if w.Signal != "" || w.Poll() != 0 {
+		t.Fatalf("no signal file or changes expected")
+	}
+	writeFile(t, spv, t0.Add(time.Second))
+	if w.Poll() != 0 {
+		t.Errorf("change must be stable for one poll")
+	}
+	if w.Poll() != 1 {
+		t.Errorf("stable change not reported")
+	}
+	if err := w.Apply(); err != nil || len(reloaded) != 1 || reloaded[0] != "axon" {
+		t.Errorf("reload not applied: %v %v", reloaded, err)
+	}
+	if w.Poll() != 0 {
+		t.Errorf("no changes expected after Apply")
+	}
+}
+
+func TestWatcherSignal(t *testing.T) {
+	dir := t.TempDir()
+	spv := filepath.Join(dir, "axon.spv")
+	sig := filepath.Join(dir, SignalFile)
+	t0 := time.Now().Add(-time.Hour)
+	writeFile(t, spv, t0)
+	writeFile(t, sig, t0)
+	w := NewWatcher(func(name, spv string) error { return nil }, spv)
+	if w.Signal != sig {
+		t.Fatalf("signal file not found")
+	}
+	writeFile(t, spv, t0.Add(time.Second))
+	if w.Poll() != 0 {
+		t.Errorf("changes must wait for the signal")
+	}
+	writeFile(t, sig, t0.Add(2*time.Second))
+	if w.Poll() != 1 || w.Changed()[0] != "axon" {
+		t.Errorf("change not reported after signal")
+	}
+}
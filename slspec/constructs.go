@@ -0,0 +1,675 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slspec
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// hlsl returns the support map for the given HLSL status
+func hlsl(st Status) map[Target]Status {
+	return map[Target]Status{HLSL: st}
+}
+
+// Constructs are the Go constructs of the specification, in the order
+// of the documentation.  IDs must never be reused for a different
+// construct, and any change in the constructs or their status requires
+// incrementing the Version.
+var Constructs = []*Construct{
+	{
+		ID:      "functions",
+		Name:    "Functions, with pointer parameters as inout",
+		Support: hlsl(Supported),
+		Example: `func Scale(x float32, y *float32) float32 {
+	*y = 2 * x
+	return x + *y
+}`,
+		Want: map[Target]string{HLSL: "float Scale(float x, inout float y) {"},
+	},
+	{
+		ID:      "methods",
+		Name:    "Methods of struct types",
+		Support: hlsl(Supported),
+		Note:    "The receiver is this.",
+		Example: `type Acts struct {
+	Act float32
+	Ge  float32
+	pad, pad1 float32
+}
+
+func (ac *Acts) Step(dt float32) {
+	ac.Act += dt * (ac.Ge - ac.Act)
+}`,
+		Want: map[Target]string{HLSL: "this.Act += dt * (this.Ge - this.Act);"},
+	},
+	{
+		ID:      "named-result",
+		Name:    "A single named result",
+		Support: hlsl(Supported),
+		Example: `func Half(x float32) (y float32) {
+	y = 0.5 * x
+	return
+}`,
+		Want: map[Target]string{HLSL: "float y = 0;"},
+	},
+	{
+		ID:      "arrays",
+		Name:    "Fixed-size arrays",
+		Support: hlsl(Supported),
+		Example: `func Sum4(x float32) float32 {
+	var a [4]float32
+	a[1] = x
+	return a[0] + a[1]
+}`,
+		Want: map[Target]string{HLSL: "float a[4]"},
+	},
+	{
+		ID:      "composite-literals",
+		Name:    "Struct composite literals",
+		Support: hlsl(Supported),
+		Example: `type Span struct {
+	Min, Max, pad, pad1 float32
+}
+
+func Unit() Span {
+	t := Span{Max: 1}
+	return t
+}`,
+		Want: map[Target]string{HLSL: "Span t = {0, 1, 0, 0};"},
+	},
+	{
+		ID:      "constants",
+		Name:    "Constants, with simple iota",
+		Support: hlsl(Supported),
+		Note:    "Enum types must be int32 or uint32 constants.",
+		Example: `const (
+	A int32 = iota
+	B
+)`,
+		Want: map[Target]string{HLSL: "static const int B = 1;"},
+	},
+	{
+		ID:      "math-constants",
+		Name:    "Constants of the math package",
+		Support: hlsl(Supported),
+		Imports: []string{"math"},
+		Example: `func Big() float32 {
+	return math.MaxFloat32
+}`,
+		Want: map[Target]string{HLSL: "3.402823466e+38"},
+	},
+	{
+		ID:      "for",
+		Name:    "Three-clause for loops",
+		Support: hlsl(Supported),
+		Example: `func Count(n int32) int32 {
+	s := int32(0)
+	for i := int32(0); i < n; i++ {
+		s += i
+	}
+	return s
+}`,
+		Want: map[Target]string{HLSL: "for (int i = int(0); i < n; i++) {"},
+	},
+	{
+		ID:      "for-while",
+		Name:    "Condition-only and infinite for loops",
+		Support: hlsl(Supported),
+		Example: `func Halve(k int32) int32 {
+	for k > 1 {
+		k /= 2
+	}
+	return k
+}`,
+		Want: map[Target]string{HLSL: "while (k > 1) {"},
+	},
+	{
+		ID:      "for-parallel",
+		Name:    "For loops with parallel init or post assignments",
+		Support: hlsl(Supported),
+		Note:    "Lowered to a while loop.",
+		Example: `func Meet(n int32) int32 {
+	s := int32(0)
+	for i, j := int32(0), n; i < j; i, j = i+1, j-1 {
+		s++
+	}
+	return s
+}`,
+		Want: map[Target]string{HLSL: "while (i < j) {"},
+	},
+	{
+		ID:      "range-int",
+		Name:    "Range over an integer",
+		Support: hlsl(Supported),
+		Note:    "The bound is evaluated on each iteration.",
+		Example: `func Tri(n int32) int32 {
+	s := int32(0)
+	for i := range n {
+		s += i
+	}
+	return s
+}`,
+		Want: map[Target]string{HLSL: "for (int i = 0; i < n; i++) {"},
+	},
+	{
+		ID:      "switch",
+		Name:    "Switch with a tag",
+		Support: hlsl(Supported),
+		Example: `func Pick(k int32) float32 {
+	switch k {
+	case 0:
+		return 1
+	case 1:
+		return 2
+	}
+	return 0
+}`,
+		Want: map[Target]string{HLSL: "switch (k) {"},
+	},
+	{
+		ID:      "switch-init",
+		Name:    "Switch statements with an init statement",
+		Support: hlsl(Supported),
+		Note:    "The init statement is defined in a block enclosing the switch statement.",
+		Example: `func Sel(x int32) int32 {
+	switch y := x * 2; y {
+	case 2:
+		return 1
+	}
+	return 0
+}`,
+		Want: map[Target]string{HLSL: "int y = x * 2;"},
+	},
+	{
+		ID:      "case-list",
+		Name:    "Switch cases with multiple values",
+		Support: hlsl(Supported),
+		Example: `func Group(k int32) int32 {
+	switch k {
+	case 1, 2:
+		return 1
+	}
+	return 0
+}`,
+		Want: map[Target]string{HLSL: "case 1: case 2:{"},
+	},
+	{
+		ID:      "fallthrough",
+		Name:    "Fallthrough in switch cases",
+		Support: hlsl(Supported),
+		Note:    "The break at the end of the case is omitted.",
+		Example: `func Fall(k int32) int32 {
+	r := int32(0)
+	switch k {
+	case 0:
+		r++
+		fallthrough
+	case 1:
+		r++
+	}
+	return r
+}`,
+		Want: map[Target]string{HLSL: "// fallthrough"},
+	},
+	{
+		ID:      "switch-tagless",
+		Name:    "Switch statements without a tag",
+		Support: hlsl(Partial),
+		Note:    "Translated into an if-else chain, so the cases cannot fallthrough, or break other than at their end.",
+		Example: `func Sign(x float32) float32 {
+	switch {
+	case x < 0:
+		return -1
+	}
+	return 1
+}`,
+		Want: map[Target]string{HLSL: "if (x < 0) {"},
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			ss, ok := n.(*ast.SwitchStmt)
+			return ok && ss.Tag == nil
+		},
+	},
+	{
+		ID:      "make-slice",
+		Name:    "Local slices from make or a slice literal",
+		Support: hlsl(Partial),
+		Note:    "The length must be constant: translated into a local array.",
+		Example: `func Tmp() float32 {
+	tmp := make([]float32, 4)
+	tmp[2] = 1
+	return tmp[2]
+}`,
+		Want: map[Target]string{HLSL: "float tmp[4]"},
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			at, ok := n.(*ast.ArrayType)
+			return ok && at.Len == nil && (isMake(parent) || isCompositeLit(parent))
+		},
+	},
+	{
+		ID:      "copy",
+		Name:    "The copy builtin",
+		Support: hlsl(Partial),
+		Note:    "The number of elements must be constant: translated into a loop.",
+		Example: `func Copy4(x float32) float32 {
+	var a, b [4]float32
+	a[0] = x
+	copy(b[:], a[:])
+	return b[0]
+}`,
+		Want: map[Target]string{HLSL: "b[_ci] = a[_ci]"},
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			return isBuiltinCall(n, info, "copy")
+		},
+	},
+	{
+		ID:      "min-max",
+		Name:    "The min and max builtins",
+		Support: hlsl(Supported),
+		Note:    "More than two arguments are nested, as the HLSL functions have two.",
+		Example: `func Limit(x, lo, hi float32) float32 {
+	return max(lo, x, min(x, hi))
+}`,
+		Want: map[Target]string{HLSL: "return max(lo, max(x, min(x, hi)));"},
+	},
+	{
+		ID:      "local-pointers",
+		Name:    "Local pointers to elements",
+		Support: hlsl(Partial),
+		Note:    "Replaced by the element expression, whose indexes must not change in the scope of the pointer.",
+		Example: `type Elem struct {
+	V, pad, pad1, pad2 float32
+}
+
+func Bump(es *[4]Elem, i int32) {
+	e := &es[i]
+	e.V += 1
+}`,
+		Want: map[Target]string{HLSL: "es[i].V += 1;"},
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			as, ok := n.(*ast.AssignStmt)
+			if !ok || as.Tok != token.DEFINE || len(as.Rhs) != 1 {
+				return false
+			}
+			ue, ok := as.Rhs[0].(*ast.UnaryExpr)
+			return ok && ue.Op == token.AND
+		},
+	},
+	{
+		ID:      "float64",
+		Name:    "float64 values",
+		Support: hlsl(Partial),
+		Note:    "Translated into double, which requires device support for 64-bit floats.",
+		Example: `func Prec(x float64) float64 {
+	return x * 2
+}`,
+		Want: map[Target]string{HLSL: "double Prec(double x) {"},
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			return isBasicType(n, info, types.Float64)
+		},
+	},
+	{
+		ID:      "int64",
+		Name:    "int64 and uint64 values",
+		Support: hlsl(Partial),
+		Note:    "Translated into int64_t and uint64_t, which require device support for 64-bit integers.",
+		Example: `func Wide(x int64) int64 {
+	return x + 1
+}`,
+		Want: map[Target]string{HLSL: "int64_t Wide(int64_t x) {"},
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			return isBasicType(n, info, types.Int64) || isBasicType(n, info, types.Uint64)
+		},
+	},
+	{
+		ID:      "multiple-results",
+		Name:    "Functions with multiple results",
+		Support: hlsl(Unsupported),
+		Note:    "Return a struct, or use pointer parameters.",
+		Example: `func Both(x float32) (float32, float32) {
+	return x, -x
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			ft, ok := n.(*ast.FuncType)
+			return ok && ft.Results.NumFields() > 1
+		},
+	},
+	{
+		ID:      "slices",
+		Name:    "Slice variables, results and fields",
+		Support: hlsl(Unsupported),
+		Note:    "Use arrays, or global buffers.",
+		Example: `func First() float32 {
+	var xs []float32
+	return xs[0]
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			at, ok := n.(*ast.ArrayType)
+			return ok && at.Len == nil && !isMake(parent) && !isCompositeLit(parent) && !isParam(parent, info)
+		},
+	},
+	{
+		ID:      "slice-params",
+		Name:    "Slice parameters of functions",
+		Support: hlsl(Partial),
+		Note:    "Translated into RWStructuredBuffer parameters, or StructuredBuffer for those named in a //gosl: readonly directive of the function, whose arguments must be global buffers of the same kind, e.g., passed by kernel entry points.  len is not supported: pass the number of elements.",
+		Example: `type Neuron struct {
+	Act, Ge float32
+
+	pad, pad1 float32
+}
+
+func Update(nrns []Neuron, i uint32) {
+	nrns[i].Act += nrns[i].Ge
+}`,
+		Want: map[Target]string{HLSL: "void Update(RWStructuredBuffer<Neuron> nrns, uint i) {"},
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			at, ok := n.(*ast.ArrayType)
+			return ok && at.Len == nil && isParam(parent, info)
+		},
+	},
+	{
+		ID:      "range-collection",
+		Name:    "Range over arrays, slices, strings, maps and channels",
+		Support: hlsl(Unsupported),
+		Note:    "Use an index loop, e.g., for i := range len(a).",
+		Example: `func Total(a [4]float32) float32 {
+	s := float32(0)
+	for _, v := range a {
+		s += v
+	}
+	return s
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			rs, ok := n.(*ast.RangeStmt)
+			if !ok || info == nil {
+				return false
+			}
+			tp := info.TypeOf(rs.X)
+			if tp == nil {
+				return false
+			}
+			bt, ok := tp.Underlying().(*types.Basic)
+			return !ok || bt.Info()&types.IsInteger == 0
+		},
+	},
+	{
+		ID:      "if-init",
+		Name:    "If statements with an init statement",
+		Support: hlsl(Unsupported),
+		Note:    "Define the variable before the if statement.",
+		Example: `func Clip(x float32) float32 {
+	if y := x * 2; y > 1 {
+		return 1
+	}
+	return x
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			is, ok := n.(*ast.IfStmt)
+			return ok && is.Init != nil
+		},
+	},
+	{
+		ID:      "type-switch",
+		Name:    "Type switches and assertions",
+		Support: hlsl(Unsupported),
+		Note:    "There are no interfaces in HLSL.",
+		Example: `func Kind(x any) int32 {
+	switch x.(type) {
+	case float32:
+		return 1
+	}
+	return 0
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			switch n.(type) {
+			case *ast.TypeSwitchStmt, *ast.TypeAssertExpr:
+				return true
+			}
+			return false
+		},
+	},
+	{
+		ID:      "goto",
+		Name:    "Labels, goto, and labeled break and continue",
+		Support: hlsl(Unsupported),
+		Note:    "Use a flag variable.",
+		Example: `func Find(n int32) int32 {
+outer:
+	for i := int32(0); i < n; i++ {
+		for j := int32(0); j < n; j++ {
+			if i*j > n {
+				break outer
+			}
+		}
+	}
+	return n
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			switch x := n.(type) {
+			case *ast.LabeledStmt:
+				return true
+			case *ast.BranchStmt:
+				return x.Tok == token.GOTO || x.Label != nil
+			}
+			return false
+		},
+	},
+	{
+		ID:      "multi-assign",
+		Name:    "Assignments of multiple variables, outside of for loop headers",
+		Support: hlsl(Unsupported),
+		Note:    "Use separate assignments, with temporary variables if needed.",
+		Example: `func Swap(a, b float32) float32 {
+	a, b = b, a
+	return a - b
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			as, ok := n.(*ast.AssignStmt)
+			if !ok || len(as.Lhs) < 2 {
+				return false
+			}
+			if fs, ok := parent.(*ast.ForStmt); ok && (fs.Init == n || fs.Post == n) {
+				return false
+			}
+			return true
+		},
+	},
+	{
+		ID:      "func-literals",
+		Name:    "Function literals and closures",
+		Support: hlsl(Unsupported),
+		Note:    "Use named functions.",
+		Example: `func Twice(x float32) float32 {
+	f := func(y float32) float32 { return 2 * y }
+	return f(x)
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			_, ok := n.(*ast.FuncLit)
+			return ok
+		},
+	},
+	{
+		ID:      "concurrency",
+		Name:    "Goroutines, channels, select and defer",
+		Support: hlsl(Unsupported),
+		Note:    "Each kernel thread runs sequentially.",
+		Example: `func Later(x *float32) {
+	defer func() { *x = 0 }()
+	*x = 1
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			switch n.(type) {
+			case *ast.GoStmt, *ast.DeferStmt, *ast.SelectStmt, *ast.SendStmt, *ast.ChanType:
+				return true
+			}
+			return false
+		},
+	},
+	{
+		ID:      "maps",
+		Name:    "Maps",
+		Support: hlsl(Unsupported),
+		Note:    "Use arrays indexed by constants.",
+		Example: `func Lookup(k int32) float32 {
+	m := map[int32]float32{1: 2}
+	return m[k]
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			_, ok := n.(*ast.MapType)
+			return ok
+		},
+	},
+	{
+		ID:      "strings",
+		Name:    "Strings",
+		Support: hlsl(Unsupported),
+		Note:    "Functions with string parameters or results are excluded automatically.",
+		Example: `const Label = "act"`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			bl, ok := n.(*ast.BasicLit)
+			if !ok || bl.Kind != token.STRING {
+				return false
+			}
+			fd, ok := parent.(*ast.Field)
+			return !ok || fd.Tag != bl // struct tags are ignored
+		},
+	},
+	{
+		ID:      "interfaces",
+		Name:    "Interface types",
+		Support: hlsl(Unsupported),
+		Note:    "Use struct types.  Constraints of generic functions are only used for type checking.",
+		Example: `type Stepper interface {
+	Step(dt float32)
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			it, ok := n.(*ast.InterfaceType)
+			if !ok {
+				return false
+			}
+			if tv, ok := info.Types[it]; ok {
+				if iface, ok := tv.Type.Underlying().(*types.Interface); ok && !iface.IsMethodSet() {
+					return false // a constraint with a type set
+				}
+			}
+			return true
+		},
+	},
+	{
+		ID:      "generic-functions",
+		Name:    "Generic functions",
+		Support: hlsl(Partial),
+		Note:    "Instantiated into a function for each list of type arguments, which must be basic or named types, e.g., Max_float32 for Max[float32].",
+		Example: `func Max[T int32 | float32](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func Larger(a, b float32) float32 {
+	return Max(a, b)
+}`,
+		Want: map[Target]string{HLSL: "float Max_float(float a, float b) {"},
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return false
+			}
+			_, isInst := info.Instances[id]
+			_, isFunc := info.Uses[id].(*types.Func)
+			return isInst && isFunc
+		},
+	},
+	{
+		ID:      "generic-types",
+		Name:    "Generic types",
+		Support: hlsl(Unsupported),
+		Note:    "Write a type for each type argument.",
+		Example: `type Pair[T int32 | float32] struct {
+	A, B T
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			ts, ok := n.(*ast.TypeSpec)
+			return ok && ts.TypeParams.NumFields() > 0
+		},
+	},
+	{
+		ID:      "append",
+		Name:    "The append builtin",
+		Support: hlsl(Unsupported),
+		Note:    "Arrays have a fixed size: use an index.",
+		Example: `func Grow(x float32) float32 {
+	var a [4]float32
+	s := append(a[:0], x)
+	return s[0]
+}`,
+		Detect: func(n, parent ast.Node, info *types.Info) bool {
+			return isBuiltinCall(n, info, "append")
+		},
+	},
+}
+
+// isMake returns true if the given node is a call of make
+func isMake(n ast.Node) bool {
+	ce, ok := n.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	id, ok := ce.Fun.(*ast.Ident)
+	return ok && id.Name == "make"
+}
+
+// isCompositeLit returns true if the given node is a composite literal
+func isCompositeLit(n ast.Node) bool {
+	_, ok := n.(*ast.CompositeLit)
+	return ok
+}
+
+// isParam returns true if the given node is a named parameter of a
+// function, e.g., the parent of its type
+func isParam(n ast.Node, info *types.Info) bool {
+	fl, ok := n.(*ast.Field)
+	if !ok || len(fl.Names) == 0 {
+		return false
+	}
+	v, ok := info.Defs[fl.Names[0]].(*types.Var)
+	return ok && !v.IsField() && v.Parent() != nil && v.Parent() != v.Pkg().Scope()
+}
+
+// isBuiltinCall returns true if the given node is a call of the
+// builtin function with given name
+func isBuiltinCall(n ast.Node, info *types.Info, name string) bool {
+	ce, ok := n.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	id, ok := ce.Fun.(*ast.Ident)
+	if !ok || id.Name != name {
+		return false
+	}
+	if info == nil {
+		return true
+	}
+	_, ok = info.Uses[id].(*types.Builtin)
+	return ok
+}
+
+// isBasicType returns true if the given node is an identifier that
+// names the given basic type
+func isBasicType(n ast.Node, info *types.Info, kind types.BasicKind) bool {
+	id, ok := n.(*ast.Ident)
+	if !ok || info == nil {
+		return false
+	}
+	tn, ok := info.Uses[id].(*types.TypeName)
+	if !ok {
+		return false
+	}
+	bt, ok := tn.Type().(*types.Basic)
+	return ok && bt.Kind() == kind
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package slspec is the specification of the subset of Go that gosl supports
+in the code within gosl regions: a table of Go constructs, each with its
+support status for each target shader language, and an example.  The
+SUBSET.md documentation and the conformance test suite of gosl are
+generated from it, and gosl -check reports the uses of the unsupported
+constructs, with the Version of this specification:
+
+	for _, c := range slspec.Constructs {
+		fmt.Println(c.ID, c.Support[slspec.HLSL])
+	}
+
+The Version is incremented whenever a construct is added or removed, or
+its support status changes, so that downstream code can depend on a
+given version of the subset.
+*/
+package slspec
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"hash/fnv"
+	"slices"
+	"strings"
+)
+
+// Version is the version of the specification of the supported Go
+// subset, which is incremented whenever a construct is added or removed,
+// or its support status changes.
+const Version = "5"
+
+// Target is a target shader language of gosl
+type Target string
+
+const (
+	// HLSL is the HLSL target, compiled to SPIR-V with dxc
+	HLSL Target = "hlsl"
+)
+
+// Targets are all of the target shader languages
+var Targets = []Target{HLSL}
+
+// Status is the support status of a construct in a target
+type Status int32
+
+const (
+	// Unsupported constructs are not translated correctly, and
+	// are reported by gosl -check
+	Unsupported Status = iota
+
+	// Partial support means that the construct is translated, with the
+	// restrictions in the Note of the construct
+	Partial
+
+	// Supported constructs are fully translated
+	Supported
+)
+
+// String returns the status as a string
+func (st Status) String() string {
+	switch st {
+	case Supported:
+		return "supported"
+	case Partial:
+		return "partial"
+	}
+	return "unsupported"
+}
+
+// Construct is a Go construct, with its support status in each target
+type Construct struct {
+
+	// stable identifier of the construct, e.g., if-init, which is used
+	// in the diagnostics, and never reused for a different construct
+	ID string
+
+	// short description of the construct
+	Name string
+
+	// support status in each target
+	Support map[Target]Status
+
+	// restrictions of partial support, or how to avoid an unsupported
+	// construct
+	Note string
+
+	// Go source of an example of the construct, as declarations
+	// within a gosl region, which is translated by the conformance
+	// suite
+	Example string
+
+	// import paths used by the Example, if any
+	Imports []string
+
+	// part of the translation of the Example, for each target where
+	// the construct is supported, which the conformance suite checks
+	Want map[Target]string
+
+	// Detect returns true if the given node, with given parent,
+	// is this construct, for reporting the uses of unsupported and
+	// partially supported constructs.  It is nil for the supported
+	// constructs.
+	Detect func(n, parent ast.Node, info *types.Info) bool
+}
+
+// Lookup returns the construct with given ID, or nil if none
+func Lookup(id string) *Construct {
+	i := slices.IndexFunc(Constructs, func(c *Construct) bool { return c.ID == id })
+	if i < 0 {
+		return nil
+	}
+	return Constructs[i]
+}
+
+// Hash returns a hash of the IDs and support status of the constructs,
+// as a hexadecimal FNV-1a 64 bit hash, which changes whenever the
+// Version must be incremented.
+func Hash() string {
+	h := fnv.New64a()
+	for _, c := range Constructs {
+		fmt.Fprintf(h, "%s", c.ID)
+		for _, tg := range Targets {
+			fmt.Fprintf(h, " %s=%s", tg, c.Support[tg])
+		}
+		fmt.Fprintln(h)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Diagnostic is a use of an unsupported or partially supported construct
+type Diagnostic struct {
+
+	// position of the use
+	Pos token.Pos
+
+	// the construct
+	Construct *Construct
+
+	// support status of the construct in the target
+	Status Status
+}
+
+// Message returns the message of the diagnostic for the given target,
+// with the construct ID and the Version of the specification.
+func (d *Diagnostic) Message(target Target) string {
+	c := d.Construct
+	verb := "is not supported"
+	if d.Status == Partial {
+		verb = "is only partially supported"
+	}
+	return fmt.Sprintf("%s %s in %s (gosl Go subset v%s: %s): %s", c.Name, verb, strings.ToUpper(string(target)), Version, c.ID, c.Note)
+}
+
+// Check returns the uses of the unsupported and partially supported
+// constructs in the target in the given files, which are the gosl regions,
+// in order.  The functions for which skip returns true, e.g., those that
+// are excluded from the translation, are not checked.
+func Check(files []*ast.File, info *types.Info, target Target, skip func(fd *ast.FuncDecl) bool) []Diagnostic {
+	var diags []Diagnostic
+	for _, f := range files {
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && skip != nil && skip(fd) {
+				continue
+			}
+			if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				continue
+			}
+			var stack []ast.Node
+			ast.Inspect(d, func(n ast.Node) bool {
+				if n == nil {
+					stack = stack[:len(stack)-1]
+					return true
+				}
+				var parent ast.Node
+				if len(stack) > 0 {
+					parent = stack[len(stack)-1]
+				}
+				stack = append(stack, n)
+				for _, c := range Constructs {
+					st := c.Support[target]
+					if st != Supported && c.Detect != nil && c.Detect(n, parent, info) {
+						diags = append(diags, Diagnostic{Pos: n.Pos(), Construct: c, Status: st})
+					}
+				}
+				return true
+			})
+		}
+	}
+	return diags
+}
+
+// Markdown returns the documentation of the specification, in Markdown:
+// a table of the constructs with their support status in each target,
+// and the examples.
+func Markdown() []byte {
+	var b bytes.Buffer
+	b.WriteString("<!-- Code generated by \"go test -run TestSubsetDoc -update\" from the slspec package; DO NOT EDIT. -->\n\n")
+	fmt.Fprintf(&b, "# Supported Go subset, version %s\n\n", Version)
+	b.WriteString("This is the subset of Go that `gosl` supports in the code within `//gosl: start` regions, as specified in the [slspec](slspec) package.  `gosl -check` reports the uses of the unsupported constructs with their IDs.  The version is incremented whenever a construct is added or removed, or its support status changes.\n\n")
+	b.WriteString("| ID | Construct |")
+	for _, tg := range Targets {
+		fmt.Fprintf(&b, " %s |", strings.ToUpper(string(tg)))
+	}
+	b.WriteString(" Notes |\n|----|-----------|")
+	for range Targets {
+		b.WriteString("------|")
+	}
+	b.WriteString("-------|\n")
+	for _, c := range Constructs {
+		fmt.Fprintf(&b, "| [%s](#%s) | %s |", c.ID, c.ID, c.Name)
+		for _, tg := range Targets {
+			fmt.Fprintf(&b, " %s |", c.Support[tg])
+		}
+		fmt.Fprintf(&b, " %s |\n", c.Note)
+	}
+	b.WriteString("\n## Examples\n")
+	for _, c := range Constructs {
+		fmt.Fprintf(&b, "\n### %s\n\n%s:\n\n```Go\n%s\n```\n", c.ID, c.Name, strings.TrimSpace(c.Example))
+		for _, tg := range Targets {
+			if w, ok := c.Want[tg]; ok {
+				fmt.Fprintf(&b, "\nis translated into %s containing:\n\n```%s\n%s\n```\n", strings.ToUpper(string(tg)), tg, w)
+			}
+		}
+	}
+	return b.Bytes()
+}
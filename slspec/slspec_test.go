@@ -0,0 +1,128 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slspec
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+// versionHashes are the Hash of each Version of the specification
+var versionHashes = map[string]string{
+	"1": "2255e5ea021d0be6",
+	"2": "52a699aba17d550b",
+	"3": "ecaa61b7db6c6186",
+	"4": "4b5dae8e86825885",
+	"5": "e8e8059f32eaab71",
+}
+
+func TestVersion(t *testing.T) {
+	if h := Hash(); versionHashes[Version] != h {
+		t.Errorf("the constructs have changed since version %s: increment the Version, and add its hash %q to versionHashes", Version, h)
+	}
+	ids := map[string]bool{}
+	md := Markdown()
+	for _, c := range Constructs {
+		if ids[c.ID] {
+			t.Errorf("duplicate construct ID: %s", c.ID)
+		}
+		ids[c.ID] = true
+		if Lookup(c.ID) != c {
+			t.Errorf("wrong Lookup of %s", c.ID)
+		}
+		st := c.Support[HLSL]
+		if (st == Supported) != (c.Detect == nil) || (st == Unsupported) != (c.Want[HLSL] == "") {
+			t.Errorf("construct %s: Detect must be set unless supported, and Want only if supported", c.ID)
+		}
+		if !bytes.Contains(md, []byte("### "+c.ID+"\n")) {
+			t.Errorf("construct %s is not documented", c.ID)
+		}
+	}
+	if Lookup("gotos") != nil {
+		t.Errorf("Lookup of unknown ID")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	src := `package test
+
+type Neuron struct {
+	Act float32 ` + "`desc:\"activation\"`" + `
+}
+
+func Sum(xs []float32) (float32, int32) {
+	s := float32(0)
+	for _, x := range xs {
+		s += x
+	}
+	for i := range 4 {
+		s += float32(i)
+	}
+	return s, 0
+}
+
+func Name(x int32) string {
+	return "neuron"
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}, Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}}
+	if _, err := (&types.Config{}).Check("test", fset, []*ast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+	skip := func(fd *ast.FuncDecl) bool { return fd.Name.Name == "Name" }
+	var got []string
+	for _, d := range Check([]*ast.File{f}, info, HLSL, skip) {
+		got = append(got, d.Construct.ID)
+	}
+	want := []string{"multiple-results", "slice-params", "range-collection"}
+	if len(got) != len(want) {
+		t.Fatalf("wrong diagnostics: %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("wrong diagnostics: %v, want %v", got, want)
+		}
+	}
+}
+
+// TestExamples checks that the uses of the unsupported and partially
+// supported constructs in their examples are reported.
+func TestExamples(t *testing.T) {
+	for _, c := range Constructs {
+		if c.Detect == nil {
+			continue
+		}
+		src := "package test\n\n"
+		for _, im := range c.Imports {
+			src += "import \"" + im + "\"\n\n"
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, c.ID+".go", src+c.Example+"\n", 0)
+		if err != nil {
+			t.Fatalf("%s: %v", c.ID, err)
+		}
+		info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}, Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}, Instances: map[*ast.Ident]types.Instance{}}
+		if _, err := (&types.Config{Importer: importer.Default()}).Check("test", fset, []*ast.File{f}, info); err != nil {
+			t.Fatalf("%s: %v", c.ID, err)
+		}
+		found := false
+		for _, d := range Check([]*ast.File{f}, info, HLSL, nil) {
+			found = found || d.Construct == c
+		}
+		if !found {
+			t.Errorf("the use of %s in its example is not reported", c.ID)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slsync
+
+import (
+	"fmt"
+	"sync"
+)
+
+// groupThread is a thread of the workgroup run by RunGroups
+type groupThread struct {
+	grp  *group
+	lid  int
+	wake chan struct{}
+	done bool
+}
+
+// group is a workgroup run by RunGroups, of which exactly one
+// thread runs at any time.
+type group struct {
+	gid     int
+	threads []*groupThread
+	exited  int
+	done    chan struct{}
+
+	// panic value of a thread, or of a barrier error
+	panicked any
+}
+
+var (
+	// runMu serializes RunGroups calls, so that current is only
+	// used by one workgroup at a time.
+	runMu sync.Mutex
+
+	// current is the thread that is running in RunGroups, if any
+	current *groupThread
+)
+
+// RunGroups runs the given kernel function on the CPU for each of the
+// given number of workgroups, with the given number of threads per
+// workgroup, emulating the execution model of a GPU kernel dispatch
+// with deterministic ordering, for comparing CPU and GPU results
+// like-for-like.  The function is called with the index of the
+// workgroup, as SV_GroupID in HLSL, and of the thread within it,
+// as SV_GroupThreadID, and the thread index is gid * threads + lid,
+// as SV_DispatchThreadID.
+//
+// The workgroups are run in turn, so that package-level groupshared
+// variables are only used by one workgroup at a time, as on the GPU.
+// Within a workgroup, each thread runs in its own goroutine, but only
+// one runs at any time, in order of lid, until it reaches a group
+// barrier (GroupBarrier, DeviceBarrier or AllBarrier) or returns:
+// all of the threads run the code up to each barrier in turn, before
+// any of them runs the code after it.  Unlike running all of the
+// thread indexes of each phase in a loop, the kernel function can be
+// written exactly as the GPU kernel, with the barriers within it.
+//
+// As on the GPU, barriers must be reached by all of the threads of the
+// workgroup: RunGroups panics if some threads have returned while others
+// wait at a barrier, which is undefined behavior on the GPU.  A panic in
+// the kernel function is also raised by RunGroups, and the other threads
+// of the workgroup are left blocked.
+// Calls to RunGroups from different goroutines are run one at a time.
+func RunGroups(groups, threads int, fun func(gid, lid uint32)) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	for gi := range groups {
+		g := &group{gid: gi, threads: make([]*groupThread, threads), done: make(chan struct{})}
+		for li := range threads {
+			th := &groupThread{grp: g, lid: li, wake: make(chan struct{})}
+			g.threads[li] = th
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						g.panicked = r
+						close(g.done)
+					}
+				}()
+				<-th.wake
+				fun(uint32(gi), uint32(li))
+				th.done = true
+				g.exited++
+				g.next(th)
+			}()
+		}
+		if threads > 0 {
+			g.run(g.threads[0])
+			<-g.done
+		}
+		current = nil
+		if g.panicked != nil {
+			panic(g.panicked)
+		}
+	}
+}
+
+// run runs the given thread, until it blocks or returns
+func (g *group) run(th *groupThread) {
+	current = th
+	th.wake <- struct{}{}
+}
+
+// next runs the next thread after given one, which has reached a
+// barrier or returned: the next thread in order of lid that has not
+// returned, or the first one after the last, for the next phase.
+func (g *group) next(th *groupThread) {
+	for li := th.lid + 1; li < len(g.threads); li++ {
+		if !g.threads[li].done {
+			g.run(g.threads[li])
+			return
+		}
+	}
+	if g.exited == len(g.threads) {
+		close(g.done)
+		return
+	}
+	if g.exited > 0 {
+		panic(fmt.Sprintf("slsync: %d of the %d threads of workgroup %d wait at a barrier that the others returned without reaching: barriers must be reached by all threads", len(g.threads)-g.exited, len(g.threads), g.gid))
+	}
+	for _, nt := range g.threads {
+		if !nt.done {
+			g.run(nt)
+			return
+		}
+	}
+}
+
+// barrier blocks the current thread until all of the threads
+// of its workgroup have reached the barrier, within RunGroups.
+func barrier() bool {
+	th := current
+	if th == nil {
+		return false
+	}
+	th.grp.next(th)
+	<-th.wake
+	return true
+}
+
+// Thread returns the index of the current thread within its workgroup,
+// and the number of threads per workgroup, within RunGroups, with ok =
+// false otherwise.  It is for packages that emulate other operations
+// among the threads of a workgroup on top of RunGroups, e.g., slwave.
+func Thread() (lid, threads int, ok bool) {
+	th := current
+	if th == nil {
+		return 0, 0, false
+	}
+	return th.lid, len(th.grp.threads), true
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slsync
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testThreads = 8
+
+// testShared is a groupshared variable of the test kernel
+var testShared [testThreads]float32
+
+// testSum is the tree reduction kernel of the pool example,
+// summing the data of each workgroup into sums.
+func testSum(data, sums []float32, gid, lid uint32) {
+	testShared[lid] = data[gid*testThreads+lid]
+	GroupBarrier()
+	for stride := uint32(testThreads / 2); stride > 0; stride >>= 1 {
+		if lid < stride {
+			testShared[lid] += testShared[lid+stride]
+		}
+		GroupBarrier()
+	}
+	if lid == 0 {
+		sums[gid] = testShared[0]
+	}
+}
+
+func TestRunGroups(t *testing.T) {
+	const groups = 5
+	data := make([]float32, groups*testThreads)
+	for i := range data {
+		data[i] = float32(i)
+	}
+	sums := make([]float32, groups)
+	RunGroups(groups, testThreads, func(gid, lid uint32) {
+		testSum(data, sums, gid, lid)
+	})
+	for gi, s := range sums {
+		want := float32(0)
+		for _, d := range data[gi*testThreads : (gi+1)*testThreads] {
+			want += d
+		}
+		if s != want {
+			t.Errorf("group %d: sum %g != %g", gi, s, want)
+		}
+	}
+
+	// each phase runs all of the threads in turn, in order
+	var order []uint32
+	RunGroups(2, 3, func(gid, lid uint32) {
+		order = append(order, gid*10+lid)
+		AllBarrier()
+		order = append(order, 100+gid*10+lid)
+	})
+	if want := []uint32{0, 1, 2, 100, 101, 102, 10, 11, 12, 110, 111, 112}; !slices.Equal(order, want) {
+		t.Errorf("order %v != %v", order, want)
+	}
+}
+
+func TestRunGroupsDivergent(t *testing.T) {
+	defer func() {
+		r := recover()
+		if s, _ := r.(string); !strings.Contains(s, "barrier") {
+			t.Errorf("no panic for a barrier not reached by all threads: %v", r)
+		}
+	}()
+	RunGroups(1, 4, func(gid, lid uint32) {
+		if lid < 2 {
+			GroupBarrier()
+		}
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package slsync provides barrier and memory fence functions that
+// gosl translates into the corresponding HLSL intrinsics, so that
+// algorithms requiring coordination among the threads of a workgroup
+// can be expressed in code shared between the CPU and GPU.
+// On the CPU, where each thread index is typically processed in turn,
+// or in independent goroutines, memory fences are no-ops and
+// the group sync barriers just yield the processor, except within
+// RunGroups, which emulates the workgroups of the GPU, where they
+// block until all threads of the workgroup have reached them.
+package slsync
+
+import "runtime"
+
+// GroupBarrier blocks until all threads in the workgroup have reached
+// this point, and all groupshared memory accesses are complete.
+// HLSL: GroupMemoryBarrierWithGroupSync().
+// On the CPU, this calls runtime.Gosched, except within RunGroups.
+func GroupBarrier() {
+	if !barrier() {
+		runtime.Gosched()
+	}
+}
+
+// GroupMemoryBarrier blocks until all groupshared memory accesses
+// are complete, without synchronizing threads.
+// HLSL: GroupMemoryBarrier(). No-op on the CPU.
+func GroupMemoryBarrier() {}
+
+// DeviceMemoryBarrier blocks until all device (buffer) memory accesses
+// are complete, without synchronizing threads.
+// HLSL: DeviceMemoryBarrier(). No-op on the CPU.
+func DeviceMemoryBarrier() {}
+
+// DeviceBarrier blocks until all threads in the workgroup have reached
+// this point, and all device memory accesses are complete.
+// HLSL: DeviceMemoryBarrierWithGroupSync().
+// On the CPU, this calls runtime.Gosched, except within RunGroups.
+func DeviceBarrier() {
+	if !barrier() {
+		runtime.Gosched()
+	}
+}
+
+// AllMemoryBarrier blocks until all memory accesses are complete,
+// without synchronizing threads.
+// HLSL: AllMemoryBarrier(). No-op on the CPU.
+func AllMemoryBarrier() {}
+
+// AllBarrier blocks until all threads in the workgroup have reached
+// this point, and all memory accesses are complete.
+// HLSL: AllMemoryBarrierWithGroupSync().
+// On the CPU, this calls runtime.Gosched, except within RunGroups.
+func AllBarrier() {
+	if !barrier() {
+		runtime.Gosched()
+	}
+}
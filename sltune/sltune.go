@@ -0,0 +1,154 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package sltune autotunes the workgroup size of each kernel on the
+current GPU device, because the optimal number of threads differs
+across vendors (e.g., 64 vs. 32 for AMD vs. NVIDIA), and caches
+the best size per device and kernel in a local JSON file.
+
+gosl -autotune=32,64,128,256 generates a variant of each 1D kernel
+for each workgroup size, e.g., shaders/axon_wg32.spv, which only
+differ in the numthreads attribute (see VariantSPV).  The kernels
+must check the thread index against the number of items, because
+the number of workgroups dispatched depends on the size.
+At startup, the binding layer benchmarks the variants, e.g., for vgpu:
+
+	tc := sltune.Open("gpu_autotune.json")
+	threads, err := tc.Autotune(gp.DeviceName, "axon", KernelVariants[KernelIDAxon], func(threads int) error {
+		pl := sy.NewPipeline(sltune.VariantName("axon", threads))
+		pl.AddShaderFile("axon", vgpu.ComputeShader, sltune.VariantSPV(KernelSPVs[KernelIDAxon], threads))
+		... // config, dispatch (n + threads - 1) / threads groups, and wait
+		return nil
+	})
+
+and then uses the variant with the best size.  Subsequent runs on the
+same device use the cached size without benchmarking.
+*/
+package sltune
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reps is the number of times each workgroup size is run by Tune,
+// in addition to an initial warmup run.
+var Reps = 10
+
+// VariantName returns the name of the variant of the given kernel
+// with the given workgroup size, e.g., axon_wg64.
+func VariantName(kernel string, threads int) string {
+	return kernel + "_wg" + strconv.Itoa(threads)
+}
+
+// VariantSPV returns the path to the SPIR-V file of the variant of the
+// kernel with the given SPIR-V file path, with the given workgroup size,
+// e.g., shaders/axon_wg64.spv for shaders/axon.spv.
+func VariantSPV(spv string, threads int) string {
+	dir, fn := filepath.Split(spv)
+	ext := filepath.Ext(fn)
+	return dir + VariantName(strings.TrimSuffix(fn, ext), threads) + ext
+}
+
+// Cache has the best workgroup size for each kernel on each device,
+// which is saved in a local JSON file.
+type Cache struct {
+
+	// file the cache is saved in
+	File string `json:"-"`
+
+	// best workgroup size, by device name and then kernel name
+	Best map[string]map[string]int
+}
+
+// Open returns a Cache for the given file, which is loaded if it exists.
+// An unreadable file is ignored, as the sizes are simply tuned again.
+func Open(file string) *Cache {
+	c := &Cache{File: file}
+	if b, err := os.ReadFile(file); err == nil {
+		json.Unmarshal(b, c)
+	}
+	if c.Best == nil {
+		c.Best = map[string]map[string]int{}
+	}
+	return c
+}
+
+// Threads returns the cached workgroup size for given device and kernel,
+// and whether it was found.
+func (c *Cache) Threads(device, kernel string) (int, bool) {
+	th, ok := c.Best[device][kernel]
+	return th, ok
+}
+
+// Set sets the workgroup size for given device and kernel.
+func (c *Cache) Set(device, kernel string, threads int) {
+	dm, ok := c.Best[device]
+	if !ok {
+		dm = map[string]int{}
+		c.Best[device] = dm
+	}
+	dm[kernel] = threads
+}
+
+// Save saves the cache to its file.
+func (c *Cache) Save() error {
+	b, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.File, b, 0644)
+}
+
+// Autotune returns the best workgroup size among the given sizes for
+// the given device and kernel, from the cache if present, and otherwise
+// by benchmarking with Tune, in which case the cache is updated and saved.
+func (c *Cache) Autotune(device, kernel string, sizes []int, run func(threads int) error) (int, error) {
+	if th, ok := c.Threads(device, kernel); ok && slices.Contains(sizes, th) {
+		return th, nil
+	}
+	th, err := Tune(sizes, run)
+	if err != nil {
+		return th, err
+	}
+	c.Set(device, kernel, th)
+	return th, c.Save()
+}
+
+// Tune benchmarks the given workgroup sizes with the given run function,
+// which must run the kernel variant with the given size to completion,
+// and returns the size with the smallest median time over Reps runs.
+func Tune(sizes []int, run func(threads int) error) (int, error) {
+	if len(sizes) == 0 {
+		return 0, errors.New("sltune: no workgroup sizes to tune")
+	}
+	best := sizes[0]
+	var bestDur time.Duration
+	for i, th := range sizes {
+		if err := run(th); err != nil { // warmup, e.g., pipeline creation
+			return best, err
+		}
+		durs := make([]time.Duration, Reps)
+		for r := range durs {
+			st := time.Now()
+			if err := run(th); err != nil {
+				return best, err
+			}
+			durs[r] = time.Since(st)
+		}
+		slices.Sort(durs)
+		med := durs[len(durs)/2]
+		if i == 0 || med < bestDur {
+			best, bestDur = th, med
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sltune
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVariantSPV(t *testing.T) {
+	if s := VariantSPV("shaders/axon.spv", 64); s != "shaders/axon_wg64.spv" {
+		t.Errorf("wrong variant: %s", s)
+	}
+}
+
+func TestAutotune(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "tune.json")
+	c := Open(fn)
+	runs := 0
+	run := func(threads int) error {
+		runs++
+		if threads != 64 {
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	}
+	th, err := c.Autotune("dev", "axon", []int{32, 64, 128}, run)
+	if err != nil || th != 64 {
+		t.Fatalf("expected 64, got %d %v", th, err)
+	}
+	runs = 0
+	th, _ = Open(fn).Autotune("dev", "axon", []int{32, 64, 128}, run)
+	if th != 64 || runs != 0 {
+		t.Errorf("cached size not used: %d runs: %d", th, runs)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+package slwave has subgroup (wave) functions that gosl translates into
+the corresponding intrinsics of each target, e.g., WaveActiveSum in HLSL,
+for fast reductions and broadcasts among the threads of a subgroup (a
+wave, warp or SIMD-group), without groupshared memory and barriers.
+They require the -subgroups flag of gosl, as the device and the target
+must support them, and some are not available on all targets.
+
+On the CPU, within slsync.RunGroups, each workgroup is divided into
+subgroups of Size threads, which are the lanes of the subgroup, and each
+function combines the values of all of the lanes, as on the GPU, so the
+functions must be called by all of the threads of the workgroup, in
+uniform control flow, as the barriers of slsync are.  Otherwise, e.g.,
+when each thread index is processed in turn in a loop, each thread is a
+subgroup with a single lane.
+*/
+package slwave
+
+import (
+	"github.com/emer/gosl/v2/slsync"
+)
+
+// Size is the number of threads in an emulated subgroup on the CPU,
+// within slsync.RunGroups, e.g., 32 for NVIDIA and Apple, or 64 for AMD,
+// to test the code with the subgroup size of a given device.
+var Size = 32
+
+// Number is the constraint for the value types of the arithmetic
+// functions, including named types, e.g., type NeuronFlags int32.
+type Number interface {
+	~float32 | ~int32 | ~uint32
+}
+
+// lanes are the values of the threads of the current workgroup in
+// slsync.RunGroups, by thread index, for the current function.
+var lanes []any
+
+// gather returns the given value of each of the lanes of the subgroup of
+// the current thread, and its lane index, within slsync.RunGroups, and
+// only that of the current thread otherwise.  It blocks until all of the
+// threads of the workgroup have called it.
+func gather[T any](v T) ([]T, int) {
+	lid, threads, ok := slsync.Thread()
+	if !ok {
+		return []T{v}, 0
+	}
+	if len(lanes) != threads {
+		lanes = make([]any, threads)
+	}
+	lanes[lid] = v
+	slsync.GroupBarrier()
+	sz := max(Size, 1)
+	st := lid / sz * sz
+	vals := make([]T, min(sz, threads-st))
+	for i := range vals {
+		vals[i] = lanes[st+i].(T)
+	}
+	slsync.GroupBarrier() // all have read the lanes before the next function
+	return vals, lid - st
+}
+
+// LaneIndex returns the index of the current thread in its subgroup.
+// HLSL: WaveGetLaneIndex(), GLSL: gl_SubgroupInvocationID.
+// Not available in MSL and WGSL, where it is a kernel parameter.
+func LaneIndex() uint32 {
+	lid, _, ok := slsync.Thread()
+	if !ok {
+		return 0
+	}
+	return uint32(lid % max(Size, 1))
+}
+
+// LaneCount returns the number of threads in a subgroup.
+// HLSL: WaveGetLaneCount(), GLSL: gl_SubgroupSize.
+// Not available in MSL and WGSL, where it is a kernel parameter.
+func LaneCount() uint32 {
+	if _, _, ok := slsync.Thread(); !ok {
+		return 1
+	}
+	return uint32(max(Size, 1))
+}
+
+// IsFirstLane returns true for the first active lane of the subgroup,
+// which is lane 0 on the CPU, as all of the lanes are active.
+// HLSL: WaveIsFirstLane(), GLSL and WGSL: subgroupElect(),
+// MSL: simd_is_first().
+func IsFirstLane() bool {
+	return LaneIndex() == 0
+}
+
+// ActiveSum returns the sum of the given value over the lanes of the
+// subgroup, to all of them.  HLSL: WaveActiveSum(v),
+// GLSL and WGSL: subgroupAdd(v), MSL: simd_sum(v).
+func ActiveSum[T Number](v T) T {
+	vals, _ := gather(v)
+	var s T
+	for _, lv := range vals {
+		s += lv
+	}
+	return s
+}
+
+// ActiveMin returns the minimum of the given value over the lanes of
+// the subgroup, to all of them.  HLSL: WaveActiveMin(v),
+// GLSL and WGSL: subgroupMin(v), MSL: simd_min(v).
+func ActiveMin[T Number](v T) T {
+	vals, _ := gather(v)
+	m := vals[0]
+	for _, lv := range vals[1:] {
+		m = min(m, lv)
+	}
+	return m
+}
+
+// ActiveMax returns the maximum of the given value over the lanes of
+// the subgroup, to all of them.  HLSL: WaveActiveMax(v),
+// GLSL and WGSL: subgroupMax(v), MSL: simd_max(v).
+func ActiveMax[T Number](v T) T {
+	vals, _ := gather(v)
+	m := vals[0]
+	for _, lv := range vals[1:] {
+		m = max(m, lv)
+	}
+	return m
+}
+
+// ActiveAllTrue returns true if the given value is true for all of the
+// lanes of the subgroup.  HLSL: WaveActiveAllTrue(b),
+// GLSL and WGSL: subgroupAll(b), MSL: simd_all(b).
+func ActiveAllTrue(b bool) bool {
+	vals, _ := gather(b)
+	for _, lv := range vals {
+		if !lv {
+			return false
+		}
+	}
+	return true
+}
+
+// ActiveAnyTrue returns true if the given value is true for any of the
+// lanes of the subgroup.  HLSL: WaveActiveAnyTrue(b),
+// GLSL and WGSL: subgroupAny(b), MSL: simd_any(b).
+func ActiveAnyTrue(b bool) bool {
+	vals, _ := gather(b)
+	for _, lv := range vals {
+		if lv {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadLaneFirst returns the given value of the first active lane of the
+// subgroup, to all of the lanes.  HLSL: WaveReadLaneFirst(v),
+// GLSL and WGSL: subgroupBroadcastFirst(v), MSL: simd_broadcast_first(v).
+func ReadLaneFirst[T Number](v T) T {
+	vals, _ := gather(v)
+	return vals[0]
+}
+
+// ReadLaneAt returns the given value of the given lane of the subgroup,
+// which is undefined on the GPU if the lane is not active, and wraps
+// around the lanes on the CPU.  HLSL: WaveReadLaneAt(v, lane),
+// GLSL and WGSL: subgroupShuffle(v, lane), MSL: simd_shuffle(v, lane).
+func ReadLaneAt[T Number](v T, lane uint32) T {
+	vals, _ := gather(v)
+	return vals[int(lane)%len(vals)]
+}
+
+// PrefixSum returns the sum of the given value over the lanes of the
+// subgroup before the current one, which is 0 for the first lane.
+// HLSL: WavePrefixSum(v), GLSL and WGSL: subgroupExclusiveAdd(v),
+// MSL: simd_prefix_exclusive_sum(v).
+func PrefixSum[T Number](v T) T {
+	vals, li := gather(v)
+	var s T
+	for _, lv := range vals[:li] {
+		s += lv
+	}
+	return s
+}
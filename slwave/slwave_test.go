@@ -0,0 +1,59 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slwave
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/emer/gosl/v2/slsync"
+)
+
+func TestRunGroups(t *testing.T) {
+	defer func(sz int) { Size = sz }(Size)
+	Size = 4
+	const groups, threads = 2, 6 // the last subgroup of each has 2 lanes
+	n := groups * threads
+	sums, prefix, first, at, maxs := make([]int32, n), make([]int32, n), make([]int32, n), make([]int32, n), make([]int32, n)
+	lane := make([]uint32, n)
+	allT, anyT := make([]bool, n), make([]bool, n)
+	slsync.RunGroups(groups, threads, func(gid, lid uint32) {
+		i := gid*threads + lid
+		v := int32(i)
+		sums[i] = ActiveSum(v)
+		prefix[i] = PrefixSum(v)
+		first[i] = ReadLaneFirst(v)
+		at[i] = ReadLaneAt(v, 1)
+		maxs[i] = ActiveMax(v)
+		lane[i] = LaneIndex()
+		allT[i] = ActiveAllTrue(v%2 == 0)
+		anyT[i] = ActiveAnyTrue(v == 3)
+	})
+	check := func(name string, got, want []int32) {
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: %v != %v", name, got, want)
+		}
+	}
+	check("ActiveSum", sums, []int32{6, 6, 6, 6, 9, 9, 30, 30, 30, 30, 21, 21})
+	check("PrefixSum", prefix, []int32{0, 0, 1, 3, 0, 4, 0, 6, 13, 21, 0, 10})
+	check("ReadLaneFirst", first, []int32{0, 0, 0, 0, 4, 4, 6, 6, 6, 6, 10, 10})
+	check("ReadLaneAt", at, []int32{1, 1, 1, 1, 5, 5, 7, 7, 7, 7, 11, 11})
+	check("ActiveMax", maxs, []int32{3, 3, 3, 3, 5, 5, 9, 9, 9, 9, 11, 11})
+	if want := []uint32{0, 1, 2, 3, 0, 1, 0, 1, 2, 3, 0, 1}; !slices.Equal(lane, want) {
+		t.Errorf("LaneIndex: %v != %v", lane, want)
+	}
+	if slices.Contains(allT, true) {
+		t.Errorf("ActiveAllTrue: %v", allT)
+	}
+	if want := []bool{true, true, true, true, false, false, false, false, false, false, false, false}; !slices.Equal(anyT, want) {
+		t.Errorf("ActiveAnyTrue: %v != %v", anyT, want)
+	}
+}
+
+func TestSingleLane(t *testing.T) {
+	if ActiveSum(float32(2.5)) != 2.5 || PrefixSum(int32(3)) != 0 || ReadLaneAt(uint32(7), 5) != 7 || LaneCount() != 1 || !IsFirstLane() {
+		t.Error("functions outside of slsync.RunGroups must operate on a single lane")
+	}
+}
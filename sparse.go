@@ -0,0 +1,313 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// SparseThreads is the number of threads per workgroup in the
+// generated sparse readback kernel.
+const SparseThreads = 64
+
+// SparseSpec specifies a generated sparse readback kernel, which writes
+// the values of selected fields of only the elements of a struct type in
+// a buffer that changed since the previous readback into a compact buffer,
+// with their indexes, so that monitoring code can read back just those
+// instead of the whole buffer every trial, parsed from the -sparse arg:
+// Type:Field1,Field2,...  Float fields change when their absolute
+// difference from the previous value is more than a threshold set at run
+// time, and integer fields on any change.
+type SparseSpec struct {
+
+	// name of the struct type, e.g., Neuron
+	Type string
+
+	// names of the fields to read back
+	Fields []string
+
+	// Go types of the Fields, as declared in the package
+	FieldTypes []string
+
+	// whether each of the Fields is a float32
+	IsFloat []bool
+
+	// HLSL types of the Fields
+	HLSLTypes []string
+
+	// HLSL definition of Type as a plain data struct, without methods,
+	// so the kernel does not depend on other generated code
+	TypeDef string
+}
+
+// ParseSparseSpec parses the -sparse arg: Type:Field1,Field2,...
+func ParseSparseSpec(spec string) (*SparseSpec, error) {
+	tp, fs, ok := strings.Cut(spec, ":")
+	if !ok || tp == "" || strings.Contains(tp, ".") || strings.TrimSpace(fs) == "" {
+		return nil, fmt.Errorf("gosl: -sparse must be of the form Type:Field1,Field2,... -- got: %q", spec)
+	}
+	ss := &SparseSpec{Type: tp}
+	for _, f := range strings.Split(fs, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			ss.Fields = append(ss.Fields, f)
+		}
+	}
+	return ss, nil
+}
+
+// Name returns the kernel name, e.g., neuronsparse
+func (ss *SparseSpec) Name() string {
+	return strings.ToLower(ss.Type) + "sparse"
+}
+
+// EntryType returns the name of the struct type of the entries with
+// the index and field values of one element, e.g., NeuronSparseEntry
+func (ss *SparseSpec) EntryType() string {
+	return ss.Type + "SparseEntry"
+}
+
+// Buffers returns the names of the buffers of the kernel: the elements,
+// the parameters, the previous values of each element, the values of
+// the changed elements, and their number, e.g., Neurons, NeuronSparse,
+// NeuronSparsePrev, NeuronSparseVals, NeuronSparseN
+func (ss *SparseSpec) Buffers() [5]string {
+	sp := ss.Type + "Sparse"
+	return [5]string{ss.Type + "s", sp, sp + "Prev", sp + "Vals", sp + "N"}
+}
+
+// Check checks that the type and fields exist in the given package
+// with the right types, and sets the field types and TypeDef.
+func (ss *SparseSpec) Check(pkg *packages.Package) error {
+	obj := pkg.Types.Scope().Lookup(ss.Type)
+	if obj == nil {
+		return fmt.Errorf("gosl: -sparse type not found in gosl regions: %s", ss.Type)
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return fmt.Errorf("gosl: -sparse type is not a struct: %s", ss.Type)
+	}
+	def, _, err := plainTypeDef(ss.Type, st)
+	if err != nil {
+		return fmt.Errorf("gosl: -sparse %w", err)
+	}
+	ss.TypeDef = def
+	ss.FieldTypes, ss.IsFloat, ss.HLSLTypes = nil, nil, nil
+	for _, f := range ss.Fields {
+		var ft types.Type
+		for i := range st.NumFields() {
+			if st.Field(i).Name() == f {
+				ft = st.Field(i).Type()
+			}
+		}
+		if ft == nil {
+			return fmt.Errorf("gosl: -sparse field not found in %s: %s", ss.Type, f)
+		}
+		ht := hlslBasicType(ft)
+		ss.FieldTypes = append(ss.FieldTypes, types.TypeString(ft, types.RelativeTo(pkg.Types)))
+		ss.IsFloat = append(ss.IsFloat, ht == "float")
+		ss.HLSLTypes = append(ss.HLSLTypes, ht)
+	}
+	return nil
+}
+
+// nPad returns the number of uint32 padding fields needed after
+// the index and the fields of the entry, to be a multiple of 16 bytes.
+func (ss *SparseSpec) nPad() int {
+	return (4 - (1+len(ss.Fields))%4) % 4
+}
+
+// GenSparse generates the sparse readback kernel in the output directory,
+// and the Go file with the CPU version and decoding in the current
+// directory, for the given -sparse spec, returning the kernel name.
+// The buffers are bound as given by KernelBindings.
+func GenSparse(pkg *packages.Package, spec string) (string, error) {
+	ss, err := ParseSparseSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	if err := ss.Check(pkg); err != nil {
+		return "", err
+	}
+	nm := ss.Name()
+	bufs := ss.Buffers()
+	binds := [5][2]int(KernelBindings(nm, bufs[:]))
+	if err := WriteGenerated(filepath.Join(GenDir(), nm+".hlsl"), nm, FormatShader("hlsl", ss.HLSL(binds))); err != nil {
+		return "", err
+	}
+	gofn := nm + ".go"
+	pnm, _ := DocPackageName(gofn)
+	src, err := ss.Go(pnm)
+	if err != nil {
+		return "", err
+	}
+	return nm, WriteGenerated(gofn, nm, src)
+}
+
+// HLSL returns the generated sparse readback kernel source, with the
+// buffers at the given set and binding.  Each thread compares the fields
+// of one element with their previous values, and if any changed, updates
+// them and appends the entry to the values, at the position given by an
+// atomic increment of the count, so the entries are not in order.
+func (ss *SparseSpec) HLSL(binds [5][2]int) []byte {
+	var b bytes.Buffer
+	bufs := ss.Buffers()
+	et := ss.EntryType()
+	pt := ss.Type + "SparseParams"
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	b.WriteString(ss.TypeDef + "\n")
+	fmt.Fprintf(&b, "// %s are the parameters of the %s kernel\n", pt, ss.Name())
+	fmt.Fprintf(&b, "struct %s {\n\tuint Start;\n\tuint N;\n\tfloat Threshold;\n\tint All;\n};\n\n", pt)
+	fmt.Fprintf(&b, "// %s is the index and the read back fields of one of the %s\n", et, bufs[0])
+	fmt.Fprintf(&b, "struct %s {\n\tuint Index;\n", et)
+	for i, f := range ss.Fields {
+		fmt.Fprintf(&b, "\t%s %s;\n", ss.HLSLTypes[i], f)
+	}
+	for i := range ss.nPad() {
+		fmt.Fprintf(&b, "\tuint pad%d;\n", i)
+	}
+	b.WriteString("};\n\n")
+	b.WriteString("// note: binding is var, set\n")
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[0][1], binds[0][0], ss.Type, bufs[0])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[1][1], binds[1][0], pt, bufs[1])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[2][1], binds[2][0], et, bufs[2])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<%s> %s;\n", binds[3][1], binds[3][0], et, bufs[3])
+	fmt.Fprintf(&b, "[[vk::binding(%d, %d)]] RWStructuredBuffer<uint> %s;\n\n", binds[4][1], binds[4][0], bufs[4])
+	fmt.Fprintf(&b, "// each thread appends the entry of one of the %s[0].N %s from\n", bufs[1], bufs[0])
+	fmt.Fprintf(&b, "// %s[0].Start to %s if its %s changed since the previous\n", bufs[1], bufs[3], strings.Join(ss.Fields, ", "))
+	fmt.Fprintf(&b, "// readback in %s, or if %s[0].All is set, and increments %s[0],\n", bufs[2], bufs[1], bufs[4])
+	b.WriteString("// which must be zero before the dispatch.\n")
+	fmt.Fprintf(&b, "[numthreads(%d, 1, 1)]\n\n", SparseThreads)
+	b.WriteString("void main(uint3 idx : SV_DispatchThreadID) {\n")
+	fmt.Fprintf(&b, "\t%s sp = %s[0];\n", pt, bufs[1])
+	b.WriteString("\tif (idx.x >= sp.N) {\n\t\treturn;\n\t}\n")
+	b.WriteString("\tuint i = sp.Start + idx.x;\n")
+	fmt.Fprintf(&b, "\t%s prev = %s[i];\n\t%s cur = prev;\n\tcur.Index = i;\n", et, bufs[2], et)
+	b.WriteString("\tbool changed = sp.All != 0;\n")
+	for i, f := range ss.Fields {
+		fmt.Fprintf(&b, "\tcur.%s = %s[i].%s;\n", f, bufs[0], f)
+		if ss.IsFloat[i] {
+			fmt.Fprintf(&b, "\tif (abs(cur.%s - prev.%s) > sp.Threshold) {\n\t\tchanged = true;\n\t}\n", f, f)
+		} else {
+			fmt.Fprintf(&b, "\tif (cur.%s != prev.%s) {\n\t\tchanged = true;\n\t}\n", f, f)
+		}
+	}
+	b.WriteString("\tif (!changed) {\n\t\treturn;\n\t}\n")
+	fmt.Fprintf(&b, "\t%s[i] = cur;\n\tuint o;\n\tInterlockedAdd(%s[0], 1, o);\n\t%s[o] = cur;\n}\n", bufs[2], bufs[4], bufs[3])
+	return b.Bytes()
+}
+
+// Go returns the generated Go source with the SparseParams and
+// SparseEntry types, the CPU version of the kernel, the decoding of
+// the entries, and the Sparse type with the Readback API, in given package.
+func (ss *SparseSpec) Go(pkgName string) ([]byte, error) {
+	var b bytes.Buffer
+	tp := ss.Type
+	bufs := ss.Buffers()
+	et := ss.EntryType()
+	pt := tp + "SparseParams"
+	st := tp + "Sparse"
+	flds := strings.Join(ss.Fields, ", ")
+	hasFloat := false
+	for _, fl := range ss.IsFloat {
+		hasFloat = hasFloat || fl
+	}
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	if hasFloat {
+		b.WriteString("import \"math\"\n\n")
+	}
+	fmt.Fprintf(&b, "// %s are the parameters of the %s kernel, in the %s\n", pt, ss.Name(), bufs[1])
+	fmt.Fprintf(&b, "// buffer: the entries of the N %s from Start whose %s\n", bufs[0], flds)
+	fmt.Fprintf(&b, "// changed since the previous readback, or all of them if All is set,\n")
+	fmt.Fprintf(&b, "// are written to the %s buffer.  Float fields change when their\n", bufs[3])
+	b.WriteString("// absolute difference is more than Threshold, and integer fields on\n// any change.\n")
+	fmt.Fprintf(&b, "type %s struct {\n\tStart     uint32\n\tN         uint32\n\tThreshold float32\n\tAll       int32\n}\n\n", pt)
+	fmt.Fprintf(&b, "// %s is the index and the read back fields of one of the %s,\n", et, bufs[0])
+	fmt.Fprintf(&b, "// in the %s and %s buffers.\n", bufs[2], bufs[3])
+	fmt.Fprintf(&b, "type %s struct {\n\tIndex uint32\n", et)
+	for i, f := range ss.Fields {
+		fmt.Fprintf(&b, "\t%s %s\n", f, ss.FieldTypes[i])
+	}
+	for i := range ss.nPad() {
+		fmt.Fprintf(&b, "\tpad%d uint32\n", i)
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "// %sChanged returns whether the fields of cur changed from prev,\n", st)
+	fmt.Fprintf(&b, "// as in the %s kernel.\n", ss.Name())
+	fmt.Fprintf(&b, "func %sChanged(cur, prev *%s, threshold float32) bool {\n", st, et)
+	for i, f := range ss.Fields {
+		if ss.IsFloat[i] {
+			fmt.Fprintf(&b, "\tif math.Abs(float64(cur.%s-prev.%s)) > float64(threshold) {\n\t\treturn true\n\t}\n", f, f)
+		} else {
+			fmt.Fprintf(&b, "\tif cur.%s != prev.%s {\n\t\treturn true\n\t}\n", f, f)
+		}
+	}
+	if !hasFloat {
+		b.WriteString("\t_ = threshold\n")
+	}
+	b.WriteString("\treturn false\n}\n\n")
+	fmt.Fprintf(&b, "// %sCPU writes the entries of the params.N %s from params.Start\n", st, bufs[0])
+	fmt.Fprintf(&b, "// that changed since the previous values in prev to vals, updating prev,\n")
+	fmt.Fprintf(&b, "// and returns their number, as the %s kernel does on the GPU, but\n", ss.Name())
+	fmt.Fprintf(&b, "// in order.  prev must have the same length as %s.\n", bufs[0])
+	fmt.Fprintf(&b, "func %sCPU(%s []%s, params *%s, prev, vals []%s) int {\n", st, bufs[0], tp, pt, et)
+	b.WriteString("\tn := 0\n\tfor i := params.Start; i < params.Start+params.N; i++ {\n")
+	fmt.Fprintf(&b, "\t\tcur := %s{Index: i", et)
+	for _, f := range ss.Fields {
+		fmt.Fprintf(&b, ", %s: %s[i].%s", f, bufs[0], f)
+	}
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "\t\tif params.All == 0 && !%sChanged(&cur, &prev[i], params.Threshold) {\n\t\t\tcontinue\n\t\t}\n", st)
+	b.WriteString("\t\tprev[i] = cur\n\t\tvals[n] = cur\n\t\tn++\n\t}\n\treturn n\n}\n\n")
+	fmt.Fprintf(&b, "// %sDecode sets the %s of the %s with the\n", st, flds, bufs[0])
+	b.WriteString("// indexes of the given entries to their values.\n")
+	fmt.Fprintf(&b, "func %sDecode(%s []%s, vals []%s) {\n", st, bufs[0], tp, et)
+	fmt.Fprintf(&b, "\tfor i := range vals {\n\t\te := &vals[i]\n\t\tv := &%s[e.Index]\n", bufs[0])
+	for _, f := range ss.Fields {
+		fmt.Fprintf(&b, "\t\tv.%s = e.%s\n", f, f)
+	}
+	b.WriteString("\t}\n}\n\n")
+	fmt.Fprintf(&b, "// %s reads back the %s of only the %s that changed\n", st, flds, bufs[0])
+	fmt.Fprintf(&b, "// since the previous readback, e.g., for monitoring every trial without\n")
+	fmt.Fprintf(&b, "// reading back all of the %s from the GPU.\n", bufs[0])
+	fmt.Fprintf(&b, "type %s struct {\n\n", st)
+	b.WriteString("\t// Threshold is the absolute change of the float fields above which\n\t// they are read back: 0 for any change.\n")
+	b.WriteString("\tThreshold float32\n\n")
+	fmt.Fprintf(&b, "\t// Dispatch runs the %s kernel on the GPU: it uploads the params\n", ss.Name())
+	fmt.Fprintf(&b, "\t// to the %s buffer and zero to %s[0], dispatches params.N\n", bufs[1], bufs[4])
+	fmt.Fprintf(&b, "\t// threads, reads back %s[0], and that number of entries of the\n", bufs[4])
+	fmt.Fprintf(&b, "\t// %s buffer into vals, returning the number.  The %s\n", bufs[3], bufs[2])
+	fmt.Fprintf(&b, "\t// and %s buffers must have as many entries as the %s.\n", bufs[3], bufs[0])
+	fmt.Fprintf(&b, "\t// If nil, %sCPU is used.\n", st)
+	fmt.Fprintf(&b, "\tDispatch func(params *%s, vals []%s) (int, error)\n\n", pt, et)
+	fmt.Fprintf(&b, "\t// %s are used by %sCPU if Dispatch is nil.\n", bufs[0], st)
+	fmt.Fprintf(&b, "\t%s []%s\n\n", bufs[0], tp)
+	fmt.Fprintf(&b, "\tprev   []%s\n\tvals   []%s\n\tsynced bool\n}\n\n", et, et)
+	fmt.Fprintf(&b, "// Reset makes the next Readback read back all of the %s,\n", bufs[0])
+	b.WriteString("// e.g., after they are initialized or uploaded to the GPU.\n")
+	fmt.Fprintf(&b, "func (s *%s) Reset() {\n\ts.synced = false\n}\n\n", st)
+	fmt.Fprintf(&b, "// Readback sets the %s of the given %s, which are a copy\n", flds, bufs[0])
+	fmt.Fprintf(&b, "// of those on the GPU, to those that changed since the previous readback,\n")
+	b.WriteString("// returning their number.  The first readback after a Reset reads back all\n// of them.\n")
+	fmt.Fprintf(&b, "func (s *%s) Readback(%s []%s) (int, error) {\n", st, bufs[0], tp)
+	fmt.Fprintf(&b, "\tn := len(%s)\n", bufs[0])
+	fmt.Fprintf(&b, "\tif len(s.vals) < n {\n\t\ts.vals = make([]%s, n)\n\t}\n", et)
+	fmt.Fprintf(&b, "\tparams := &%s{N: uint32(n), Threshold: s.Threshold}\n", pt)
+	b.WriteString("\tif !s.synced {\n\t\tparams.All = 1\n\t}\n\tnv := 0\n")
+	b.WriteString("\tif s.Dispatch == nil {\n")
+	fmt.Fprintf(&b, "\t\tif len(s.prev) < n {\n\t\t\ts.prev = make([]%s, n)\n\t\t}\n", et)
+	fmt.Fprintf(&b, "\t\tnv = %sCPU(s.%s, params, s.prev, s.vals)\n", st, bufs[0])
+	b.WriteString("\t} else {\n\t\tvar err error\n\t\tif nv, err = s.Dispatch(params, s.vals); err != nil {\n\t\t\treturn 0, err\n\t\t}\n\t}\n")
+	fmt.Fprintf(&b, "\t%sDecode(%s, s.vals[:nv])\n", st, bufs[0])
+	b.WriteString("\ts.synced = true\n\treturn nv, nil\n}\n")
+	return format.Source(b.Bytes())
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSparseSpec(t *testing.T) {
+	if _, err := ParseSparseSpec("Neuron.Act"); err == nil {
+		t.Error("expected error for bad spec")
+	}
+	src := "package main\n\ntype Flags int32\n\ntype Neuron struct {\n\tAct, Ge float32\n\tFlags Flags\n\tpad float32\n}\n"
+	pkg := testPackage(t, "neuron.go", src)
+	ss, err := ParseSparseSpec("Neuron:Act,Flags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.Check(pkg); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Name() != "neuronsparse" || ss.Buffers() != [5]string{"Neurons", "NeuronSparse", "NeuronSparsePrev", "NeuronSparseVals", "NeuronSparseN"} {
+		t.Errorf("wrong names: %s %v", ss.Name(), ss.Buffers())
+	}
+	hlsl := string(ss.HLSL([5][2]int{{0, 0}, {3, 0}, {3, 1}, {3, 2}, {3, 3}}))
+	for _, want := range []string{"struct NeuronSparseEntry {\n\tuint Index;\n\tfloat Act;\n\tint Flags;\n\tuint pad0;\n};", "[[vk::binding(3, 3)]] RWStructuredBuffer<uint> NeuronSparseN;", "if (abs(cur.Act - prev.Act) > sp.Threshold) {", "if (cur.Flags != prev.Flags) {", "InterlockedAdd(NeuronSparseN[0], 1, o);"} {
+		if !strings.Contains(hlsl, want) {
+			t.Errorf("missing %q in:\n%s", want, hlsl)
+		}
+	}
+
+	// the generated Go code must compile with the type
+	gsrc, err := ss.Go("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGeneratedGo(t, pkg, map[string][]byte{"neuronsparse.go": gsrc})
+	if !strings.Contains(string(gsrc), "\tFlags Flags\n") {
+		t.Errorf("entry field does not have the declared type:\n%s", gsrc)
+	}
+
+	if ss, _ := ParseSparseSpec("Neuron:Vm"); ss.Check(pkg) == nil {
+		t.Error("expected error for missing field")
+	}
+}
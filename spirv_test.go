@@ -0,0 +1,171 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/emer/gosl/v2/diff"
+)
+
+// SPIRVSnapshotsEnv is the environment variable that enables
+// TestSPIRVSnapshots, which needs dxc and spirv-dis on the PATH.
+const SPIRVSnapshotsEnv = "GOSL_SPIRV_SNAPSHOTS"
+
+// spirvSnapshotDir has the stored disassembly of the kernels
+// of the testdata files, as <kernel>.spvasm
+var spirvSnapshotDir = filepath.Join("testdata", "spirv")
+
+var (
+	spirvIDRe = regexp.MustCompile(`%[0-9]+\b`)
+
+	// instructions with compiler versions, file names, and line numbers
+	spirvDebugRe = regexp.MustCompile(`^\s*(%\w+ = )?Op(Source\w*|ModuleProcessed|String|Line|NoLine)\b`)
+)
+
+// NormalizeSPIRV returns the given spirv-dis disassembly without the
+// header comments and debug instructions, which depend on the compiler
+// version and paths, and with the numeric IDs renumbered in order of
+// first use, so that unrelated changes do not change all of the IDs.
+func NormalizeSPIRV(dis []byte) []byte {
+	ids := map[string]string{}
+	var b bytes.Buffer
+	for _, ln := range strings.Split(string(dis), "\n") {
+		tl := strings.TrimSpace(ln)
+		if tl == "" || strings.HasPrefix(tl, ";") || spirvDebugRe.MatchString(ln) {
+			continue
+		}
+		tl = spirvIDRe.ReplaceAllStringFunc(tl, func(id string) string {
+			nid, ok := ids[id]
+			if !ok {
+				nid = fmt.Sprintf("%%%d", len(ids)+1)
+				ids[id] = nid
+			}
+			return nid
+		})
+		b.WriteString(tl + "\n")
+	}
+	return b.Bytes()
+}
+
+func TestNormalizeSPIRV(t *testing.T) {
+	dis := `; SPIR-V
+; Version: 1.0
+; Generator: Google spiregg; 0
+; Bound: 40
+               OpCapability Shader
+               OpMemoryModel Logical GLSL450
+               OpEntryPoint GLCompute %main "main" %gl_GlobalInvocationID
+          %3 = OpString "/tmp/x/basic.hlsl"
+               OpSource HLSL 600 %3
+               OpModuleProcessed "dxc-commit-hash: 1234"
+       %uint = OpTypeInt 32 0
+         %17 = OpConstant %uint 0
+         %12 = OpTypePointer Uniform %uint
+       %main = OpFunction %void None %12
+               OpLine %3 10 1
+         %25 = OpAccessChain %12 %Data %17
+`
+	want := `OpCapability Shader
+OpMemoryModel Logical GLSL450
+OpEntryPoint GLCompute %main "main" %gl_GlobalInvocationID
+%uint = OpTypeInt 32 0
+%1 = OpConstant %uint 0
+%2 = OpTypePointer Uniform %uint
+%main = OpFunction %void None %2
+%3 = OpAccessChain %2 %Data %1
+`
+	if got := string(NormalizeSPIRV([]byte(dis))); got != want {
+		t.Errorf("wrong normalized disassembly:\n%s", diff.Diff("expected", []byte(want), "got", []byte(got)))
+	}
+}
+
+// TestSPIRVSnapshots compiles the kernels of the testdata files, and
+// compares the normalized spirv-dis disassembly of each with the snapshot
+// stored in testdata/spirv, to catch regressions in the quality of the
+// generated code, e.g., extra instructions.  It only runs if the
+// GOSL_SPIRV_SNAPSHOTS environment variable is set, as it needs dxc and
+// spirv-dis, and the output depends on their versions.  Use -update to
+// write the snapshots after a deliberate change, or a tool update:
+// otherwise, a missing or stale snapshot is a failure.
+func TestSPIRVSnapshots(t *testing.T) {
+	if os.Getenv(SPIRVSnapshotsEnv) == "" {
+		t.Skipf("set %s=1 to compare the SPIR-V disassembly of the kernels with the snapshots (needs dxc and spirv-dis)", SPIRVSnapshotsEnv)
+	}
+	for _, tool := range []string{"dxc", "spirv-dis"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Fatalf("%s is set, but %s is not on the PATH", SPIRVSnapshotsEnv, tool)
+		}
+	}
+	match, err := filepath.Glob("testdata/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	od, dxc := *outDir, *dxcPath
+	*outDir = filepath.Join("shaders", "spirvtest")
+	*dxcPath = "dxc"
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *dxcPath = od, dxc
+		ResetState()
+	})
+	if *update {
+		os.MkdirAll(spirvSnapshotDir, 0755)
+	} else if snaps, _ := filepath.Glob(filepath.Join(spirvSnapshotDir, "*.spvasm")); len(snaps) == 0 {
+		t.Fatalf("no snapshots in %s: run with %s=1 go test -run TestSPIRVSnapshots -update to write them", spirvSnapshotDir, SPIRVSnapshotsEnv)
+	}
+	kernels := map[string]bool{}
+	for _, in := range match {
+		ResetState()
+		if err := Generate([]string{in}); err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		spvs, _ := filepath.Glob(filepath.Join(*outDir, "*.spv"))
+		for _, spv := range spvs {
+			nm := strings.TrimSuffix(filepath.Base(spv), ".spv")
+			kernels[nm] = true
+			t.Run(nm, func(t *testing.T) {
+				dis, err := exec.Command("spirv-dis", spv).Output()
+				if err != nil {
+					t.Fatalf("spirv-dis %s: %v", spv, err)
+				}
+				got := NormalizeSPIRV(dis)
+				snap := filepath.Join(spirvSnapshotDir, nm+".spvasm")
+				expected, err := os.ReadFile(snap)
+				if err == nil && bytes.Equal(got, expected) {
+					return
+				}
+				if *update {
+					if err := os.WriteFile(snap, got, 0644); err != nil {
+						t.Error(err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("no snapshot of kernel %s (from %s): %v: run with -update to write it", nm, in, err)
+				}
+				t.Errorf("SPIR-V of %s (from %s) != %s\n%s", nm, in, snap, diff.Diff("expected", expected, "got", got))
+			})
+		}
+	}
+	snaps, _ := filepath.Glob(filepath.Join(spirvSnapshotDir, "*.spvasm"))
+	for _, snap := range snaps {
+		if nm := strings.TrimSuffix(filepath.Base(snap), ".spvasm"); !kernels[nm] {
+			if *update {
+				os.Remove(snap)
+				continue
+			}
+			t.Errorf("stale snapshot %s: no kernel %s in the testdata files: run with -update to remove it", snap, nm)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// StagingPrefix is the name prefix of the staging directories within
+// the output directory, which are hidden from the go tool.
+const StagingPrefix = ".gosl-"
+
+// stagingDir is the staging directory that generated files are written
+// to between BeginOutputs and EndOutputs.
+var stagingDir string
+
+// GenDir returns the directory that generated files are written to:
+// the staging directory while generating, else the output directory.
+func GenDir() string {
+	if stagingDir != "" {
+		return stagingDir
+	}
+	return *outDir
+}
+
+// BeginOutputs creates a new staging directory within the output directory,
+// which all of the generated files are written to until EndOutputs,
+// so that the output directory is only updated if everything succeeds.
+func BeginOutputs() error {
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(*outDir, StagingPrefix)
+	if err != nil {
+		return err
+	}
+	stagingDir = dir
+	clear(writtenOutputs)
+	return nil
+}
+
+// EndOutputs ends the generation started by BeginOutputs, with the given
+// error from generating: if nil, the previously generated files in the
+// output directory are replaced with the staged ones, else the output
+// directory is left as it was.  The staging directory is always removed,
+// except after an error with -keep-on-error, for debugging.
+func EndOutputs(err error) error {
+	dir := stagingDir
+	stagingDir = ""
+	if err == nil {
+		err = commitOutputs(dir)
+	}
+	if err != nil && *keepOnError {
+		fmt.Printf("gosl: generation failed, partial outputs kept in: %s\n", dir)
+		return err
+	}
+	os.RemoveAll(dir)
+	return err
+}
+
+// commitOutputs replaces the generated files in the output directory
+// with those in the given staging directory, including those in its
+// subdirectories, e.g., for the paths of the Names config.
+func commitOutputs(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	RemoveGenFiles(*outDir)
+	return filepath.WalkDir(dir, func(path string, f fs.DirEntry, err error) error {
+		if err != nil || f.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(*outDir, rel)
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		return os.Rename(path, to)
+	})
+}
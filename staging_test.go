@@ -0,0 +1,69 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStagingFailure injects a compile failure, with a missing compiler
+// and -require-dxc, and checks that the previous outputs are kept, and the staging directory
+// removed, except with -keep-on-error.
+func TestStagingFailure(t *testing.T) {
+	od, dxc, rq, koe := *outDir, *dxcPath, *requireDxc, *keepOnError
+	*outDir = filepath.Join("shaders", "stagingtest")
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *dxcPath, *requireDxc, *keepOnError = od, dxc, rq, koe
+		ResetState()
+	})
+	os.MkdirAll(*outDir, 0755)
+	prev := filepath.Join(*outDir, "basic.hlsl")
+	os.WriteFile(prev, []byte("// previous\n"), 0644)
+	staged := func() []string {
+		dirs, _ := filepath.Glob(filepath.Join(*outDir, StagingPrefix+"*"))
+		return dirs
+	}
+
+	*dxcPath = filepath.Join(t.TempDir(), "nodxc")
+	*requireDxc = true
+	if err := Generate([]string{"testdata/basic.go"}); err == nil {
+		t.Fatal("no error with a missing compiler")
+	}
+	if b, _ := os.ReadFile(prev); string(b) != "// previous\n" {
+		t.Errorf("previous output was changed by a failed generation:\n%s", b)
+	}
+	if dirs := staged(); len(dirs) != 0 {
+		t.Errorf("staging directories not removed: %v", dirs)
+	}
+
+	ResetState()
+	*keepOnError = true
+	if err := Generate([]string{"testdata/basic.go"}); err == nil {
+		t.Fatal("no error with a missing compiler")
+	}
+	dirs := staged()
+	if len(dirs) != 1 {
+		t.Fatalf("staging directory not kept with -keep-on-error: %v", dirs)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[0], "basic.hlsl")); err != nil {
+		t.Errorf("partial output not kept with -keep-on-error: %v", err)
+	}
+	os.RemoveAll(dirs[0])
+
+	ResetState()
+	*dxcPath = ToolNone
+	if err := Generate([]string{"testdata/basic.go"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(prev); string(b) == "// previous\n" {
+		t.Errorf("previous output not replaced by a successful generation")
+	}
+	if dirs := staged(); len(dirs) != 0 {
+		t.Errorf("staging directories not removed: %v", dirs)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// StatsThreads is the number of threads per workgroup in the
+// generated stats kernel.
+const StatsThreads = 64
+
+// StatsSpec specifies a generated statistics kernel, computing the
+// mean and max of selected float32 fields of a struct type in a buffer,
+// for each value of an integer group field (e.g., Neuron.LayIndex),
+// parsed from the -stats arg: Type.GroupField:Field1,Field2,...
+type StatsSpec struct {
+
+	// name of the struct type, e.g., Neuron
+	Type string
+
+	// name of the integer field with the group index, e.g., LayIndex
+	Group string
+
+	// names of the float32 fields to compute stats on
+	Fields []string
+
+	// HLSL definition of Type as a plain data struct, without methods,
+	// so the stats kernel does not depend on other generated code
+	TypeDef string
+}
+
+// ParseStatsSpec parses the -stats arg: Type.GroupField:Field1,Field2,...
+func ParseStatsSpec(spec string) (*StatsSpec, error) {
+	tg, fs, ok := strings.Cut(spec, ":")
+	tp, grp, ok2 := strings.Cut(tg, ".")
+	if !ok || !ok2 || tp == "" || grp == "" || fs == "" {
+		return nil, fmt.Errorf("gosl: -stats must be of the form Type.GroupField:Field1,Field2,... -- got: %q", spec)
+	}
+	ss := &StatsSpec{Type: tp, Group: grp}
+	for _, f := range strings.Split(fs, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			ss.Fields = append(ss.Fields, f)
+		}
+	}
+	return ss, nil
+}
+
+// Name returns the kernel name, e.g., neuronstats
+func (ss *StatsSpec) Name() string {
+	return strings.ToLower(ss.Type) + "stats"
+}
+
+// StatsType returns the name of the stats struct type, e.g., NeuronStats
+func (ss *StatsSpec) StatsType() string {
+	return ss.Type + "Stats"
+}
+
+// Check checks that the type and fields exist in the given package
+// with the right types, and sets the TypeDef for the type.
+func (ss *StatsSpec) Check(pkg *packages.Package) error {
+	obj := pkg.Types.Scope().Lookup(ss.Type)
+	if obj == nil {
+		return fmt.Errorf("gosl: -stats type not found in gosl regions: %s", ss.Type)
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return fmt.Errorf("gosl: -stats type is not a struct: %s", ss.Type)
+	}
+	def, fields, err := plainTypeDef(ss.Type, st)
+	if err != nil {
+		return fmt.Errorf("gosl: -stats %w", err)
+	}
+	ss.TypeDef = def
+	gt, ok := fields[ss.Group]
+	if bt, isBasic := gt.(*types.Basic); !ok || !isBasic || bt.Info()&types.IsInteger == 0 {
+		return fmt.Errorf("gosl: -stats group field must be an integer field of %s: %s", ss.Type, ss.Group)
+	}
+	for _, f := range ss.Fields {
+		ft, ok := fields[f]
+		if bt, isBasic := ft.(*types.Basic); !ok || !isBasic || bt.Kind() != types.Float32 {
+			return fmt.Errorf("gosl: -stats field must be a float32 field of %s: %s", ss.Type, f)
+		}
+	}
+	return nil
+}
+
+// plainTypeDef returns the HLSL definition of the given struct type as
+// a plain data struct, without methods, so that generated kernels do not
+// depend on other generated code, and the underlying types of its fields.
+// All of the fields must be 32 bit basic types.
+func plainTypeDef(typ string, st *types.Struct) (string, map[string]types.Type, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "struct %s {\n", typ)
+	fields := map[string]types.Type{}
+	for i := range st.NumFields() {
+		f := st.Field(i)
+		fields[f.Name()] = f.Type().Underlying()
+		ht := hlslBasicType(f.Type())
+		if ht == "" {
+			return "", nil, fmt.Errorf("type %s field %s is not a 32 bit basic type: %s", typ, f.Name(), f.Type())
+		}
+		fmt.Fprintf(&b, "\t%s %s;\n", ht, f.Name())
+	}
+	b.WriteString("};\n")
+	return b.String(), fields, nil
+}
+
+// hlslBasicType returns the HLSL type for given 32 bit basic type
+// (or named type based on one), or "" if not supported.
+func hlslBasicType(typ types.Type) string {
+	bt, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return ""
+	}
+	switch bt.Kind() {
+	case types.Float32:
+		return "float"
+	case types.Int32:
+		return "int"
+	case types.Uint32:
+		return "uint"
+	}
+	return ""
+}
+
+// nPad returns the number of uint32 padding fields needed after
+// the stats fields and N, to be a multiple of 16 bytes.
+func (ss *StatsSpec) nPad() int {
+	return (4 - (2*len(ss.Fields)+1)%4) % 4
+}
+
+// GenStats generates the stats kernel in the output directory,
+// and the Go file with the stats type and accessors in the current
+// directory, for the given -stats spec, returning the kernel name.
+func GenStats(pkg *packages.Package, spec string) (string, error) {
+	ss, err := ParseStatsSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	if err := ss.Check(pkg); err != nil {
+		return "", err
+	}
+	nm := ss.Name()
+	if err := WriteGenerated(filepath.Join(GenDir(), nm+".hlsl"), nm, FormatShader("hlsl", ss.HLSL())); err != nil {
+		return "", err
+	}
+	gofn := nm + ".go"
+	pnm, _ := DocPackageName(gofn)
+	src, err := ss.Go(pnm)
+	if err != nil {
+		return "", err
+	}
+	return nm, WriteGenerated(gofn, nm, src)
+}
+
+// HLSL returns the generated stats kernel source
+func (ss *StatsSpec) HLSL() []byte {
+	var b bytes.Buffer
+	st := ss.StatsType()
+	nf := len(ss.Fields)
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	b.WriteString(ss.TypeDef + "\n")
+	fmt.Fprintf(&b, "struct %s {\n", st)
+	for _, f := range ss.Fields {
+		fmt.Fprintf(&b, "\tfloat %sMean;\n\tfloat %sMax;\n", f, f)
+	}
+	b.WriteString("\tuint N;\n")
+	for i := range ss.nPad() {
+		fmt.Fprintf(&b, "\tuint pad%d;\n", i)
+	}
+	b.WriteString("};\n\n")
+	b.WriteString("// note: binding is var, set\n")
+	fmt.Fprintf(&b, "[[vk::binding(0, 0)]] RWStructuredBuffer<%s> %ss;\n", ss.Type, ss.Type)
+	fmt.Fprintf(&b, "[[vk::binding(0, 1)]] RWStructuredBuffer<%s> Stats;\n\n", st)
+	fmt.Fprintf(&b, "groupshared float %sSum[%d][%d];\n", st, nf, StatsThreads)
+	fmt.Fprintf(&b, "groupshared float %sMax[%d][%d];\n", st, nf, StatsThreads)
+	fmt.Fprintf(&b, "groupshared uint %sN[%d];\n\n", st, StatsThreads)
+	fmt.Fprintf(&b, "// one workgroup per %s value: each thread accumulates every %d'th\n", ss.Group, StatsThreads)
+	fmt.Fprintf(&b, "// %s with that %s, followed by a tree reduction.\n", ss.Type, ss.Group)
+	fmt.Fprintf(&b, "[numthreads(%d, 1, 1)]\n\n", StatsThreads)
+	b.WriteString("void main(uint3 gid : SV_GroupID, uint3 lid : SV_GroupThreadID) {\n")
+	b.WriteString("\tuint gi = gid.x;\n\tuint ti = lid.x;\n\tuint ns;\n\tuint st;\n")
+	fmt.Fprintf(&b, "\t%ss.GetDimensions(ns, st);\n", ss.Type)
+	for fi := range ss.Fields {
+		fmt.Fprintf(&b, "\t%sSum[%d][ti] = 0;\n\t%sMax[%d][ti] = -3.402823466e+38;\n", st, fi, st, fi)
+	}
+	fmt.Fprintf(&b, "\t%sN[ti] = 0;\n", st)
+	fmt.Fprintf(&b, "\tfor (uint i = ti; i < ns; i += %d) {\n", StatsThreads)
+	fmt.Fprintf(&b, "\t\tif (uint(%ss[i].%s) != gi) {\n\t\t\tcontinue;\n\t\t}\n", ss.Type, ss.Group)
+	for fi, f := range ss.Fields {
+		fmt.Fprintf(&b, "\t\t%sSum[%d][ti] += %ss[i].%s;\n", st, fi, ss.Type, f)
+		fmt.Fprintf(&b, "\t\t%sMax[%d][ti] = max(%sMax[%d][ti], %ss[i].%s);\n", st, fi, st, fi, ss.Type, f)
+	}
+	fmt.Fprintf(&b, "\t\t%sN[ti]++;\n\t}\n", st)
+	b.WriteString("\tGroupMemoryBarrierWithGroupSync();\n")
+	fmt.Fprintf(&b, "\tfor (uint stride = %d; stride > 0; stride >>= 1) {\n\t\tif (ti < stride) {\n", StatsThreads/2)
+	for fi := range ss.Fields {
+		fmt.Fprintf(&b, "\t\t\t%sSum[%d][ti] += %sSum[%d][ti+stride];\n", st, fi, st, fi)
+		fmt.Fprintf(&b, "\t\t\t%sMax[%d][ti] = max(%sMax[%d][ti], %sMax[%d][ti+stride]);\n", st, fi, st, fi, st, fi)
+	}
+	fmt.Fprintf(&b, "\t\t\t%sN[ti] += %sN[ti+stride];\n", st, st)
+	b.WriteString("\t\t}\n\t\tGroupMemoryBarrierWithGroupSync();\n\t}\n")
+	b.WriteString("\tif (ti == 0) {\n")
+	fmt.Fprintf(&b, "\t\tuint n = %sN[0];\n\t\tStats[gi].N = n;\n", st)
+	for fi, f := range ss.Fields {
+		fmt.Fprintf(&b, "\t\tStats[gi].%sMean = (n > 0) ? %sSum[%d][0] / float(n) : 0;\n", f, st, fi)
+		fmt.Fprintf(&b, "\t\tStats[gi].%sMax = (n > 0) ? %sMax[%d][0] : 0;\n", f, st, fi)
+	}
+	b.WriteString("\t}\n}\n")
+	return b.Bytes()
+}
+
+// Go returns the generated Go source for the stats type and accessors,
+// in given package.
+func (ss *StatsSpec) Go(pkgName string) ([]byte, error) {
+	var b bytes.Buffer
+	st := ss.StatsType()
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	fmt.Fprintf(&b, "// %s has the mean and max of selected %s fields for each\n", st, ss.Type)
+	fmt.Fprintf(&b, "// value of %s.%s, computed on the GPU by the %s kernel\n", ss.Type, ss.Group, ss.Name())
+	fmt.Fprintf(&b, "// (dispatched with one workgroup per %s value), so that monitoring\n", ss.Group)
+	fmt.Fprintf(&b, "// only requires reading back this small buffer.\n")
+	fmt.Fprintf(&b, "type %s struct {\n", st)
+	for _, f := range ss.Fields {
+		fmt.Fprintf(&b, "\n\t// mean of %s\n\t%sMean float32\n\n\t// max of %s\n\t%sMax float32\n", f, f, f, f)
+	}
+	fmt.Fprintf(&b, "\n\t// number of %s values with this %s\n\tN uint32\n", ss.Type, ss.Group)
+	if np := ss.nPad(); np > 0 {
+		pads := make([]string, np)
+		for i := range pads {
+			pads[i] = fmt.Sprintf("pad%d", i)
+		}
+		fmt.Fprintf(&b, "\n\t%s uint32\n", strings.Join(pads, ", "))
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "// %sFields are the names of the %s fields in %s\n", st, ss.Type, st)
+	fmt.Fprintf(&b, "var %sFields = []string{", st)
+	for i, f := range ss.Fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q", f)
+	}
+	b.WriteString("}\n\n")
+	for _, stat := range []string{"Mean", "Max"} {
+		fmt.Fprintf(&b, "// %s returns the %s of given %s field, which must be\n// one of the %sFields, and otherwise returns 0.\n", stat, strings.ToLower(stat), ss.Type, st)
+		fmt.Fprintf(&b, "func (st *%s) %s(field string) float32 {\n\tswitch field {\n", st, stat)
+		for _, f := range ss.Fields {
+			fmt.Fprintf(&b, "\tcase %q:\n\t\treturn st.%s%s\n", f, f, stat)
+		}
+		b.WriteString("\t}\n\treturn 0\n}\n\n")
+	}
+	fmt.Fprintf(&b, "// %sCPU computes the same stats as the %s kernel on the CPU\n", st, ss.Name())
+	b.WriteString("// (up to differences in the order of floating point summation),\n")
+	fmt.Fprintf(&b, "// from the given %s values into stats, indexed by %s.\n", ss.Type, ss.Group)
+	fmt.Fprintf(&b, "func %sCPU(vals []%s, stats []%s) {\n", st, ss.Type, st)
+	fmt.Fprintf(&b, "\tfor i := range stats {\n\t\tstats[i] = %s{}\n\t}\n", st)
+	b.WriteString("\tfor i := range vals {\n\t\tv := &vals[i]\n")
+	fmt.Fprintf(&b, "\t\tgi := int(v.%s)\n\t\tif gi < 0 || gi >= len(stats) {\n\t\t\tcontinue\n\t\t}\n", ss.Group)
+	b.WriteString("\t\tst := &stats[gi]\n")
+	for _, f := range ss.Fields {
+		fmt.Fprintf(&b, "\t\tst.%sMean += v.%s\n", f, f)
+		fmt.Fprintf(&b, "\t\tif st.N == 0 || v.%s > st.%sMax {\n\t\t\tst.%sMax = v.%s\n\t\t}\n", f, f, f, f)
+	}
+	b.WriteString("\t\tst.N++\n\t}\n")
+	b.WriteString("\tfor i := range stats {\n\t\tst := &stats[i]\n\t\tif st.N == 0 {\n\t\t\tcontinue\n\t\t}\n")
+	for _, f := range ss.Fields {
+		fmt.Fprintf(&b, "\t\tst.%sMean /= float32(st.N)\n", f)
+	}
+	b.WriteString("\t}\n}\n")
+	return format.Source(b.Bytes())
+}
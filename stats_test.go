@@ -0,0 +1,35 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStatsSpec(t *testing.T) {
+	ss, err := ParseStatsSpec("Neuron.LayIndex:Act, Ge,Vm,CaSpkP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Type != "Neuron" || ss.Group != "LayIndex" || strings.Join(ss.Fields, ",") != "Act,Ge,Vm,CaSpkP" {
+		t.Errorf("wrong parse: %+v", ss)
+	}
+	if ss.Name() != "neuronstats" || ss.StatsType() != "NeuronStats" || ss.nPad() != 3 {
+		t.Errorf("wrong names or padding: %s %s %d", ss.Name(), ss.StatsType(), ss.nPad())
+	}
+	src, err := ss.Go("axon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "CaSpkPMax float32") {
+		t.Errorf("missing stats field in:\n%s", src)
+	}
+	for _, bad := range []string{"Neuron", "Neuron:Act", "Neuron.LayIndex:", ".LayIndex:Act"} {
+		if _, err := ParseStatsSpec(bad); err == nil {
+			t.Errorf("expected error for: %q", bad)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"slices"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// SubgroupRequires are the capabilities of the device and the target
+// that the slwave subgroup (wave) functions require, for each -target,
+// which must be enabled with the -subgroups flag.
+var SubgroupRequires = map[string]string{
+	TargetHLSL:  "shader model 6.0 wave intrinsics, as compiled by dxc with -T cs_6_0",
+	TargetWGSL:  "the subgroups feature of WebGPU, enabled with enable subgroups; in the files with an entry point",
+	TargetGLSL:  "Vulkan 1.1 subgroup operations, with the GL_KHR_shader_subgroup extensions declared in the files with an entry point",
+	TargetMetal: "the SIMD-group functions of MSL",
+}
+
+// SubgroupUnsupported are the slwave functions that are not available
+// in each -target, where the lane index and count are kernel parameters:
+// [[thread_index_in_simdgroup]] and [[threads_per_simdgroup]] in MSL,
+// and @builtin(subgroup_invocation_id) and @builtin(subgroup_size)
+// in WGSL, which can be passed to the functions that need them.
+var SubgroupUnsupported = map[string][]string{
+	TargetWGSL:  {"LaneIndex", "LaneCount"},
+	TargetMetal: {"LaneIndex", "LaneCount"},
+}
+
+// GLSLSubgroupExtensions are the GLSL extensions for the slwave functions
+var GLSLSubgroupExtensions = []string{"GL_KHR_shader_subgroup_basic", "GL_KHR_shader_subgroup_vote", "GL_KHR_shader_subgroup_arithmetic", "GL_KHR_shader_subgroup_ballot", "GL_KHR_shader_subgroup_shuffle"}
+
+// CheckSubgroups checks the uses of the slwave package in the given
+// package of extracted regions, reporting each use without the
+// -subgroups flag, of a function that is not available in the -target,
+// or of anything other than a function, e.g., slwave.Size, which is
+// only for the CPU, and returns an error if there are any.
+func CheckSubgroups(pkg *packages.Package) error {
+	type use struct {
+		obj types.Object
+		pos token.Position
+	}
+	var uses []use
+	for id, obj := range pkg.TypesInfo.Uses {
+		if obj.Pkg() == nil || obj.Pkg().Name() != "slwave" || obj.Pkg() == pkg.Types {
+			continue
+		}
+		if _, ok := obj.(*types.TypeName); ok { // e.g., the Number constraint
+			continue
+		}
+		pos := pkg.Fset.PositionFor(id.Pos(), true)
+		uses = append(uses, use{obj, pos})
+	}
+	sort.Slice(uses, func(i, j int) bool {
+		pi, pj := uses[i].pos, uses[j].pos
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	nerr := 0
+	for _, u := range uses {
+		var msg string
+		switch {
+		case !isFunc(u.obj):
+			msg = fmt.Sprintf("slwave.%s cannot be used in GPU code: only the slwave functions are translated", u.obj.Name())
+		case !*subgroups:
+			msg = fmt.Sprintf("slwave.%s requires the -subgroups flag, as the device and the target must support subgroup operations: %s", u.obj.Name(), SubgroupRequires[*target])
+		case slices.Contains(SubgroupUnsupported[*target], u.obj.Name()):
+			msg = fmt.Sprintf("slwave.%s is not available in -target %s, where it is a kernel parameter: pass it to the function instead", u.obj.Name(), *target)
+		default:
+			continue
+		}
+		nerr++
+		fmt.Printf("%s:\n\tgosl: %s\n", u.pos, msg)
+	}
+	if nerr > 0 {
+		return fmt.Errorf("gosl: %d uses of slwave subgroup functions that are not supported", nerr)
+	}
+	return nil
+}
+
+// isFunc returns true if the given object is a function
+func isFunc(obj types.Object) bool {
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// SubgroupHeader returns the declarations of the subgroup capabilities
+// for a shader file with an entry point in the -target, with -subgroups:
+// the extensions for GLSL, and enable subgroups; for WGSL.
+func SubgroupHeader() []byte {
+	if !*subgroups {
+		return nil
+	}
+	switch *target {
+	case TargetGLSL:
+		var b []byte
+		for _, ext := range GLSLSubgroupExtensions {
+			b = fmt.Appendf(b, "#extension %s : require\n", ext)
+		}
+		return b
+	case TargetWGSL:
+		return []byte("enable subgroups;\n\n")
+	}
+	return nil
+}
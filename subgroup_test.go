@@ -0,0 +1,38 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubgroups(t *testing.T) {
+	od, sg, tg, gi := *outDir, *subgroups, *target, *goimportsPath
+	*outDir = filepath.Join("shaders", "wavetest") // errors leave the extracted files
+	os.MkdirAll(*outDir, 0755)
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *subgroups, *target, *goimportsPath = od, sg, tg, gi
+		ResetState()
+	})
+	*subgroups, *goimportsPath = false, ToolNone
+	if _, err := ProcessFiles([]string{"testdata/subgroup/wave.go"}); err == nil || !strings.Contains(err.Error(), "slwave") {
+		t.Errorf("expected an error for slwave without -subgroups, got: %v", err)
+	}
+	*subgroups = true
+	runTest(t, "testdata/subgroup/wave.go", "testdata/subgroup/wave.golden")
+
+	*target = TargetMetal
+	if _, err := ProcessFiles([]string{"testdata/subgroup/wave.go"}); err == nil {
+		t.Error("expected an error for slwave.LaneIndex in MSL")
+	}
+	*target = TargetGLSL
+	if hdr := string(SubgroupHeader()); !strings.Contains(hdr, "#extension GL_KHR_shader_subgroup_arithmetic : require\n") {
+		t.Errorf("missing GLSL subgroup extensions in:\n%s", hdr)
+	}
+}
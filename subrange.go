@@ -0,0 +1,201 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// SubRangeFile is the name of the Go file generated in the current
+	// directory with the dispatch helpers of the -subrange variants.
+	SubRangeFile = "gosl_subrange.go"
+
+	// SubRangeBuffer is the name of the buffer with the range of
+	// elements of the -subrange variants, in the shaders and runtime.
+	SubRangeBuffer = "GoslRange"
+)
+
+// SubRangeName returns the name of the -subrange variant of the given
+// kernel, e.g., axon_range for axon.
+func SubRangeName(name string) string {
+	return name + "_range"
+}
+
+// SubRangeKernels returns the kernels selected by the -subrange flag,
+// from the given ones with the given sources by name: all of those
+// without a SubRangeReason for all, or those with the names in the
+// comma-separated list, reporting the names of other kernels.
+func SubRangeKernels(ks []*Kernel, ksrcs map[string][]byte) []*Kernel {
+	if *subRange == "" {
+		return nil
+	}
+	if *subRange == "all" {
+		return slices.DeleteFunc(slices.Clone(ks), func(k *Kernel) bool { return SubRangeReason(k, ksrcs[k.Name]) != "" })
+	}
+	var sel []*Kernel
+	for _, nm := range strings.Split(*subRange, ",") {
+		nm = strings.TrimSpace(nm)
+		i := slices.IndexFunc(ks, func(k *Kernel) bool { return k.Name == nm })
+		if i < 0 {
+			fmt.Printf("gosl: -subrange: no kernel %s with a variant: see the kernels in the doc or -kernelids\n", nm)
+			continue
+		}
+		sel = append(sel, ks[i])
+	}
+	return sel
+}
+
+// SubRangeSet returns the descriptor set of the SubRangeBuffer, which is
+// the one after the last set of the buffers of all of the Kernels, so
+// that it is not used by any of them.
+func SubRangeSet() int {
+	set := -1
+	for _, k := range Kernels {
+		for _, b := range k.Buffers {
+			set = max(set, b.Set)
+		}
+	}
+	return set + 1
+}
+
+// GenSubRange writes the -subrange variant of the given 1D kernel with
+// the given source in the output directory, named as in SubRangeName,
+// which dispatches threads for only a range of the elements, e.g., the
+// neurons of one layer, instead of all of them, returning its name.
+// The range is read from the SubRangeBuffer, at the given set and
+// binding 0, and a prologue adds its Start to the thread index, and
+// returns early for the threads beyond its N elements:
+//
+//	idx.x += GoslRange[0].Start;
+//	if (idx.x >= GoslRange[0].Start + GoslRange[0].N) {
+//		return;
+//	}
+//
+// Only the kernels without a SubRangeReason can have a variant, and
+// false is returned for the others, with the reason.
+func GenSubRange(k *Kernel, src []byte, set int) (string, bool) {
+	if reason := SubRangeReason(k, src); reason != "" {
+		fmt.Printf("gosl: -subrange: no variant for kernel %s: %s\n", k.Name, reason)
+		return "", false
+	}
+	var decl []int
+	for _, m := range entryPointRe.FindAllSubmatchIndex(src, -1) {
+		if string(src[m[8]:m[9]]) == k.Entry {
+			decl = m
+		}
+	}
+	loc := regexp.MustCompile(`\bvoid\s+` + regexp.QuoteMeta(k.Entry) + `\s*\([^)]*SV_DispatchThreadID[^)]*\)\s*\{`).FindIndex(src)
+	if decl == nil || loc == nil {
+		return "", false
+	}
+	var b bytes.Buffer
+	b.Write(src[:decl[0]])
+	fmt.Fprintf(&b, "// %sParams is the range of elements of the %s kernel\n", SubRangeBuffer, SubRangeName(k.Name))
+	fmt.Fprintf(&b, "struct %sParams {\n\tuint Start;\n\tuint N;\n\n\tuint pad, pad1;\n};\n\n", SubRangeBuffer)
+	fmt.Fprintf(&b, "[[vk::binding(0, %d)]] StructuredBuffer<%sParams> %s;\n\n", set, SubRangeBuffer, SubRangeBuffer)
+	b.Write(src[decl[0]:loc[1]])
+	fmt.Fprintf(&b, "\n\t%s.x += %s[0].Start;\n\tif (%s.x >= %s[0].Start + %s[0].N) {\n\t\treturn;\n\t}\n", k.Index, SubRangeBuffer, k.Index, SubRangeBuffer, SubRangeBuffer)
+	b.Write(src[loc[1]:])
+	nm := SubRangeName(k.Name)
+	if err := os.WriteFile(filepath.Join(GenDir(), nm+".hlsl"), FormatShader("hlsl", b.Bytes()), 0644); err != nil {
+		fmt.Println(err)
+		return "", false
+	}
+	k.SubRange = true
+	return nm, true
+}
+
+// SubRangeReason returns the reason the given kernel with the given
+// source cannot have a -subrange variant, or "" if it can: only 1D
+// kernels can run on a range of elements, and kernels with barriers
+// cannot return early, as all threads must reach the barriers.
+func SubRangeReason(k *Kernel, src []byte) string {
+	switch {
+	case k.Index == "" || k.IndexDims != 1 || k.Workgroup[1] != 1 || k.Workgroup[2] != 1:
+		return "only 1D kernels can run on a range of elements"
+	case bytes.Contains(StripHLSLComments(src), []byte("WithGroupSync")):
+		return "it has barriers, which all threads must reach"
+	}
+	return ""
+}
+
+// subRangeFunc returns the name of the Go dispatch helper of the
+// -subrange variant of the given kernel, e.g., DispatchAxonRange.
+func subRangeFunc(k *Kernel) string {
+	return "Dispatch" + strings.TrimPrefix(KernelConstName(k.Name), "Kernel") + "Range"
+}
+
+// SubRangeGo returns the Go source of the SubRangeFile for the -subrange
+// variants of the given kernels, in the given package, with the SubRange
+// buffer at the given set: the GoslRange type of the buffer, a
+// ConfigGoslRange function that creates it and adds the variants to an
+// slgpu.Runtime, and a dispatch helper for each variant, which uploads
+// the range and dispatches the workgroups for only its elements.
+func SubRangeGo(pkgName string, ks []*Kernel, set int) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	b.WriteString("import (\n\t\"path/filepath\"\n\n\t\"github.com/emer/gosl/v2/slgpu\"\n)\n\n")
+	fmt.Fprintf(&b, "// %s is the range of elements of the -subrange kernel variants,\n// in the %s buffer: the Start and number N of the elements.\n", SubRangeBuffer, SubRangeBuffer)
+	fmt.Fprintf(&b, "type %s struct {\n\tStart uint32\n\tN     uint32\n\n\tpad, pad1 uint32\n}\n\n", SubRangeBuffer)
+	fmt.Fprintf(&b, "// Config%s creates the %s buffer, at set %d, binding 0, and\n// adds the -subrange kernel variants to the given runtime, from their\n// compiled shader files in the given directory, e.g., shaders.\n", SubRangeBuffer, SubRangeBuffer, set)
+	fmt.Fprintf(&b, "func Config%s(rt slgpu.Runtime, dir string) error {\n", SubRangeBuffer)
+	fmt.Fprintf(&b, "\tif err := rt.CreateBuffer(%q, %d, 0, 16, 1); err != nil {\n\t\treturn err\n\t}\n", SubRangeBuffer, set)
+	for _, k := range ks {
+		nm := SubRangeName(k.Name)
+		fmt.Fprintf(&b, "\tif err := rt.AddKernel(%q, filepath.Join(dir, %q)); err != nil {\n\t\treturn err\n\t}\n", nm, OutputPath(nm)+".spv")
+	}
+	b.WriteString("\treturn nil\n}\n")
+	for _, k := range ks {
+		nm := SubRangeName(k.Name)
+		fn := subRangeFunc(k)
+		th := k.Workgroup[0]
+		fmt.Fprintf(&b, "\n// %s dispatches the %s kernel on only the count elements\n// from start, e.g., the neurons of one layer, with the %s variant,\n", fn, k.Name, nm)
+		fmt.Fprintf(&b, "// by uploading the range to the %s buffer, which waits for any\n// pending dispatches, and dispatching (count+%d)/%d workgroups.\n", SubRangeBuffer, th-1, th)
+		fmt.Fprintf(&b, "func %s(rt slgpu.Runtime, start, count int) error {\n", fn)
+		b.WriteString("\tif count <= 0 {\n\t\treturn nil\n\t}\n")
+		fmt.Fprintf(&b, "\tif err := rt.Upload(%q, slgpu.Bytes([]%s{{Start: uint32(start), N: uint32(count)}})); err != nil {\n\t\treturn err\n\t}\n", SubRangeBuffer, SubRangeBuffer)
+		fmt.Fprintf(&b, "\treturn rt.Dispatch(%q, (count+%d)/%d, 1, 1)\n}\n", nm, th-1, th)
+	}
+	return format.Source(b.Bytes())
+}
+
+// GenSubRanges generates the -subrange variants of the selected kernels
+// (see SubRangeKernels), with the given sources by kernel name, adding
+// them to the given variants to compile, and the SubRangeFile with their
+// dispatch helpers, in the current directory.
+func GenSubRanges(ksrcs map[string][]byte, variants map[string]*Kernel) error {
+	var cands []*Kernel
+	for _, k := range SortedKernels() {
+		if _, ok := ksrcs[k.Name]; ok {
+			cands = append(cands, k)
+		}
+	}
+	set := SubRangeSet()
+	var gen []*Kernel
+	for _, k := range SubRangeKernels(cands, ksrcs) {
+		if nm, ok := GenSubRange(k, ksrcs[k.Name], set); ok {
+			variants[nm] = k
+			gen = append(gen, k)
+		}
+	}
+	if len(gen) == 0 {
+		return nil
+	}
+	pnm, _ := DocPackageName(SubRangeFile)
+	src, err := SubRangeGo(pnm, gen, set)
+	if err != nil {
+		return err
+	}
+	return WriteGenerated(SubRangeFile, SubRangeBuffer, src)
+}
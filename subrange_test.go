@@ -0,0 +1,72 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubRange(t *testing.T) {
+	od := *outDir
+	*outDir = t.TempDir()
+	t.Cleanup(func() { *outDir = od })
+
+	src := `#include "axon.hlsl"
+
+[[vk::binding(0, 0)]] RWStructuredBuffer<Neuron> Neurons;
+[[vk::binding(0, 1)]] StructuredBuffer<Layer> Layers;
+
+[numthreads(64, 1, 1)]
+
+void main(uint3 idx : SV_DispatchThreadID) {
+	Neurons[idx.x].Act = Layers[Neurons[idx.x].LayIndex].Gain;
+}
+`
+	k := ParseKernel("CycleNeuron", []byte(src))
+	nm, ok := GenSubRange(k, []byte(src), 2)
+	if !ok || nm != "CycleNeuron_range" || !k.SubRange {
+		t.Fatalf("sub-range variant not generated: %q", nm)
+	}
+	b, err := os.ReadFile(filepath.Join(*outDir, nm+".hlsl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsrc := string(b)
+	for _, want := range []string{
+		"[[vk::binding(0, 2)]] StructuredBuffer<GoslRangeParams> GoslRange;",
+		"idx.x += GoslRange[0].Start;",
+		"if (idx.x >= GoslRange[0].Start + GoslRange[0].N) {",
+	} {
+		if !strings.Contains(rsrc, want) {
+			t.Errorf("missing %q in variant:\n%s", want, rsrc)
+		}
+	}
+	if rk := ParseKernel(nm, b); rk.Entry != "main" || len(rk.Buffers) != 3 {
+		t.Errorf("wrong variant kernel: %s, %d buffers", rk.Entry, len(rk.Buffers))
+	}
+
+	k2 := ParseKernel("Learn", []byte(strings.Replace(src, "numthreads(64, 1, 1)", "numthreads(8, 8, 1)", 1)))
+	if SubRangeReason(k2, []byte(src)) == "" {
+		t.Errorf("2D kernel should not have a sub-range variant")
+	}
+
+	gsrc, err := SubRangeGo("axon", []*Kernel{k}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`if err := rt.CreateBuffer("GoslRange", 2, 0, 16, 1); err != nil {`,
+		`if err := rt.AddKernel("CycleNeuron_range", filepath.Join(dir, "CycleNeuron_range.spv")); err != nil {`,
+		"func DispatchCycleNeuronRange(rt slgpu.Runtime, start, count int) error {",
+		`return rt.Dispatch("CycleNeuron_range", (count+63)/64, 1, 1)`,
+	} {
+		if !strings.Contains(string(gsrc), want) {
+			t.Errorf("missing %q in:\n%s", want, gsrc)
+		}
+	}
+}
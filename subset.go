@@ -0,0 +1,38 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+
+	"github.com/emer/gosl/v2/slprint"
+	"github.com/emer/gosl/v2/slspec"
+	"golang.org/x/tools/go/packages"
+)
+
+// CheckSubset reports the uses of the constructs of Go that are not
+// supported in HLSL in the given package of extracted gosl regions,
+// as specified by the slspec package, for -check mode, and returns an
+// error if there are any.  The uses of partially supported constructs
+// are also reported with -debug.  Excluded functions are not checked.
+func CheckSubset(pkg *packages.Package) error {
+	skip := func(fd *ast.FuncDecl) bool {
+		return slprint.ExcludeFunc(pkg.TypesInfo, excludeFunMap, fd) != ""
+	}
+	nerr := 0
+	for _, d := range slspec.Check(pkg.Syntax, pkg.TypesInfo, slspec.HLSL, skip) {
+		if d.Status == slspec.Unsupported {
+			nerr++
+		} else if !*debug {
+			continue
+		}
+		fmt.Printf("%s:\n\tgosl: %s\n", pkg.Fset.PositionFor(d.Pos, true).String(), d.Message(slspec.HLSL))
+	}
+	if nerr > 0 {
+		return fmt.Errorf("gosl: %d uses of Go constructs that are not supported in HLSL (gosl Go subset v%s): see SUBSET.md", nerr, slspec.Version)
+	}
+	return nil
+}
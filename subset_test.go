@@ -0,0 +1,79 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emer/gosl/v2/diff"
+	"github.com/emer/gosl/v2/slspec"
+)
+
+// TestConformance translates the example of each construct of the
+// supported Go subset with -check: the supported and partially supported
+// constructs must pass the check, and be translated as specified, and
+// the unsupported ones must fail the check.
+func TestConformance(t *testing.T) {
+	od, dxc, chk := *outDir, *dxcPath, *checkSubset
+	*outDir = filepath.Join("shaders", "conftest")
+	os.MkdirAll(*outDir, 0755)
+	t.Cleanup(func() {
+		os.RemoveAll(*outDir)
+		*outDir, *dxcPath, *checkSubset = od, dxc, chk
+		ResetState()
+	})
+	*dxcPath = ToolNone
+	*checkSubset = true
+	for _, c := range slspec.Constructs {
+		t.Run(c.ID, func(t *testing.T) {
+			ResetState()
+			var b strings.Builder
+			b.WriteString("package test\n\n")
+			for _, im := range c.Imports {
+				fmt.Fprintf(&b, "import %q\n\n", im)
+			}
+			b.WriteString("//gosl: start conf\n\n" + c.Example + "\n\n//gosl: end conf\n")
+			fn := filepath.Join(t.TempDir(), "conf.go")
+			if err := os.WriteFile(fn, []byte(b.String()), 0644); err != nil {
+				t.Fatal(err)
+			}
+			sls, err := ProcessFiles([]string{fn})
+			if c.Support[slspec.HLSL] == slspec.Unsupported {
+				if err == nil {
+					t.Errorf("unsupported construct %s passes -check", c.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := c.Want[slspec.HLSL]; !bytes.Contains(sls["conf"], []byte(want)) {
+				t.Errorf("translation of %s does not contain %q:\n%s", c.ID, want, sls["conf"])
+			}
+		})
+	}
+}
+
+// TestSubsetDoc checks that SUBSET.md is generated from the current
+// specification, and writes it with -update.
+func TestSubsetDoc(t *testing.T) {
+	got := slspec.Markdown()
+	expected, _ := os.ReadFile("SUBSET.md")
+	if bytes.Equal(got, expected) {
+		return
+	}
+	if *update {
+		if err := os.WriteFile("SUBSET.md", got, 0666); err != nil {
+			t.Error(err)
+		}
+		return
+	}
+	t.Errorf("SUBSET.md is not up to date: run go test -run TestSubsetDoc -update\n%s", diff.Diff("SUBSET.md", expected, "got", got))
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Table is a constant lookup table read from a CSV or JSON file at
+// generation time, as specified by a directive within a gosl region:
+//
+//	//gosl: table <file.csv> as <Name> [N]float32
+//
+// which is emitted as a Go array in a generated Go file next to the
+// source file, and as a static const array in the HLSL output,
+// keeping the file as the single source of truth.
+type Table struct {
+
+	// file the table is read from, relative to the Go source file
+	File string
+
+	// Go and HLSL name of the table
+	Name string
+
+	// number of values, which must match the file if > 0,
+	// and otherwise is set from the file ([] or [...] in the directive)
+	N int
+
+	// Go element type: float32, int32 or uint32
+	Type string
+
+	// table values, in row-major order for CSV files,
+	// or flattened in order for nested JSON arrays
+	Values []float64
+}
+
+// ParseTableDirective parses the arguments of a table directive:
+// <file> as <Name> [N]<type>
+func ParseTableDirective(args string) (*Table, error) {
+	fs := strings.Fields(args)
+	if len(fs) != 4 || fs[1] != "as" || !strings.HasPrefix(fs[3], "[") {
+		return nil, fmt.Errorf("gosl: table directive must be of the form: //gosl: table <file.csv> as <Name> [N]float32 -- got: %q", args)
+	}
+	tb := &Table{File: fs[0], Name: fs[2]}
+	ns, typ, ok := strings.Cut(fs[3][1:], "]")
+	if !ok {
+		return nil, fmt.Errorf("gosl: table %s: invalid array type: %s", tb.Name, fs[3])
+	}
+	switch typ {
+	case "float32", "int32", "uint32":
+		tb.Type = typ
+	default:
+		return nil, fmt.Errorf("gosl: table %s: element type must be float32, int32 or uint32, not: %s", tb.Name, typ)
+	}
+	if ns != "" && ns != "..." {
+		n, err := strconv.Atoi(ns)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("gosl: table %s: invalid array length: %s", tb.Name, ns)
+		}
+		tb.N = n
+	}
+	return tb, nil
+}
+
+// Load reads the table values from its file, relative to given directory.
+func (tb *Table) Load(dir string) error {
+	fn := filepath.Join(dir, tb.File)
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(fn)) {
+	case ".json":
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return fmt.Errorf("gosl: table %s: %s: %w", tb.Name, fn, err)
+		}
+		if err := tb.addJSON(v); err != nil {
+			return fmt.Errorf("gosl: table %s: %s: %w", tb.Name, fn, err)
+		}
+	default:
+		rd := csv.NewReader(bytes.NewReader(b))
+		rd.FieldsPerRecord = -1
+		rd.Comment = '#'
+		recs, err := rd.ReadAll()
+		if err != nil {
+			return fmt.Errorf("gosl: table %s: %s: %w", tb.Name, fn, err)
+		}
+		for ri, rec := range recs {
+			for _, f := range rec {
+				f = strings.TrimSpace(f)
+				if f == "" {
+					continue
+				}
+				v, err := strconv.ParseFloat(f, 64)
+				if err != nil {
+					if ri == 0 && len(tb.Values) == 0 {
+						break // header row
+					}
+					return fmt.Errorf("gosl: table %s: %s line %d: %w", tb.Name, fn, ri+1, err)
+				}
+				tb.Values = append(tb.Values, v)
+			}
+		}
+	}
+	if tb.N == 0 {
+		tb.N = len(tb.Values)
+	} else if tb.N != len(tb.Values) {
+		return fmt.Errorf("gosl: table %s: %s has %d values, not %d", tb.Name, fn, len(tb.Values), tb.N)
+	}
+	return nil
+}
+
+// addJSON adds the values from given JSON number or (nested) array
+func (tb *Table) addJSON(v any) error {
+	switch x := v.(type) {
+	case float64:
+		tb.Values = append(tb.Values, x)
+	case []any:
+		for _, e := range x {
+			if err := tb.addJSON(e); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("values must be numbers or arrays of numbers, not: %v", x)
+	}
+	return nil
+}
+
+// valueList returns the values as a comma-separated list of literals
+func (tb *Table) valueList() string {
+	vs := make([]string, len(tb.Values))
+	for i, v := range tb.Values {
+		if tb.Type == "float32" {
+			vs[i] = strconv.FormatFloat(v, 'g', -1, 32)
+		} else {
+			vs[i] = strconv.FormatInt(int64(v), 10)
+		}
+	}
+	return strings.Join(vs, ", ")
+}
+
+// HLSLType returns the HLSL element type
+func (tb *Table) HLSLType() string {
+	switch tb.Type {
+	case "int32":
+		return "int"
+	case "uint32":
+		return "uint"
+	}
+	return "float"
+}
+
+// GoDecl returns the Go declaration of the table array
+func (tb *Table) GoDecl() string {
+	return fmt.Sprintf("var %s = [%d]%s{%s}", tb.Name, tb.N, tb.Type, tb.valueList())
+}
+
+// RegionLines returns the lines for the extracted Go code of given
+// region: the Go array for type checking, in a nohlsl block,
+// and the HLSL static const array, in an hlsl block.
+func (tb *Table) RegionLines(slFn string) [][]byte {
+	return [][]byte{
+		[]byte(""),
+		[]byte("//gosl: nohlsl " + slFn),
+		[]byte(""), // not a doc comment, which gofmt would reformat
+		[]byte(tb.GoDecl()),
+		[]byte(""),
+		[]byte("//gosl: end " + slFn),
+		[]byte(""),
+		[]byte("//gosl: hlsl " + slFn),
+		[]byte(fmt.Sprintf("// // %s is the lookup table from %s", tb.Name, filepath.Base(tb.File))),
+		[]byte(fmt.Sprintf("// static const %s %s[%d] = {%s};", tb.HLSLType(), tb.Name, tb.N, tb.valueList())),
+		[]byte("//gosl: end " + slFn),
+		[]byte(""),
+	}
+}
+
+// GoFileName returns the name of the generated Go file for the table,
+// in given directory.
+func (tb *Table) GoFileName(dir string) string {
+	return filepath.Join(dir, strings.ToLower(tb.Name)+"_table.go")
+}
+
+// WriteGo writes the generated Go file with the table array
+// into given directory, next to the source file with the directive.
+func (tb *Table) WriteGo(dir string) error {
+	fn := tb.GoFileName(dir)
+	pnm, _ := DocPackageName(fn)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"gosl\" from %s; DO NOT EDIT.\n\n", filepath.Base(tb.File))
+	fmt.Fprintf(&b, "package %s\n\n", pnm)
+	fmt.Fprintf(&b, "// %s is the lookup table from %s\n", tb.Name, filepath.Base(tb.File))
+	b.WriteString(tb.GoDecl() + "\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	return WriteGenerated(fn, tb.Name, src)
+}
+
+// ExtractTable processes a table directive with given arguments in the
+// given Go source file and region, returning the lines to add to the
+// extracted Go code for the region.
+func ExtractTable(args, srcFn, slFn string) ([][]byte, error) {
+	tb, err := ParseTableDirective(args)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(srcFn)
+	if err := tb.Load(dir); err != nil {
+		return nil, err
+	}
+	if err := tb.WriteGo(dir); err != nil {
+		return nil, err
+	}
+	return tb.RegionLines(slFn), nil
+}
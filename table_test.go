@@ -0,0 +1,49 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "lut.csv"), []byte("x,exp\n1,2.5\n3,0.125\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "idx.json"), []byte("[[1, 2], [3]]"), 0644)
+
+	tb, err := ParseTableDirective(" lut.csv as ExpLUT [4]float32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "var ExpLUT = [4]float32{1, 2.5, 3, 0.125}"; tb.GoDecl() != exp {
+		t.Errorf("got: %s expected: %s", tb.GoDecl(), exp)
+	}
+
+	tb, err = ParseTableDirective("idx.json as Idxs []int32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if tb.N != 3 || tb.HLSLType() != "int" || tb.valueList() != "1, 2, 3" {
+		t.Errorf("wrong json table: %+v", tb)
+	}
+
+	tb, _ = ParseTableDirective("lut.csv as ExpLUT [5]float32")
+	if err := tb.Load(dir); err == nil {
+		t.Errorf("expected error for wrong number of values")
+	}
+	for _, bad := range []string{"lut.csv ExpLUT [4]float32", "lut.csv as ExpLUT [4]float64", "lut.csv as ExpLUT [x]float32"} {
+		if _, err := ParseTableDirective(bad); err == nil {
+			t.Errorf("expected error for: %q", bad)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/emer/gosl/v2/slprint"
+)
+
+const (
+	// TargetHLSL is the -target for HLSL code, compiled to .spv with dxc
+	TargetHLSL = "hlsl"
+
+	// TargetWGSL is the -target for WGSL code, for WebGPU
+	TargetWGSL = slprint.TargetWGSL
+
+	// TargetGLSL is the -target for GLSL 450 compute shaders, for Vulkan
+	// tools that only accept GLSL
+	TargetGLSL = slprint.TargetGLSL
+
+	// TargetMetal is the -target for the Metal Shading Language (MSL),
+	// for Metal on macOS without MoltenVK
+	TargetMetal = slprint.TargetMetal
+)
+
+const (
+	// HLSL2018 is the default -hlsl version, which is compiled by glslc
+	// and older versions of dxc, as well as current ones
+	HLSL2018 = "2018"
+
+	// HLSL2021 is the -hlsl version with templates and operator
+	// overloading, which dxc compiles with -HV 2021
+	HLSL2021 = "2021"
+)
+
+// Targets are the shader languages that can be generated with the -target
+// flag, named by their file extension.  Each has its own raw code blocks
+// in the gosl regions, e.g., //gosl: wgsl axon, which are only included
+// in the code for that target.
+var Targets = []string{TargetHLSL, TargetWGSL, TargetGLSL, TargetMetal}
+
+// HLSLOnlyFlags are the flags that only apply to the HLSL target, as they
+// generate or analyze HLSL code, or compile it with dxc, which are
+// reported as errors for other targets.  The code of the other targets
+// is not compiled.
+var HLSLOnlyFlags = []string{"active", "autotune", "budget", "compare", "doc", "gather", "hlsl", "kernelids", "manifest", "meta", "only", "pressure", "repro", "require-dxc", "sparse", "stats", "subrange", "validate", "varindex", "vectorize"}
+
+// UseHLSL2021 returns true if HLSL 2021 code is generated, for -hlsl
+// 2021, with templates for the generic functions instead of their
+// instances from Monomorphize, and operator overloads.
+func UseHLSL2021() bool {
+	return *target == TargetHLSL && *hlslVersion == HLSL2021
+}
+
+// ShaderExt returns the file extension of the generated shader code
+// for the -target, e.g., .hlsl
+func ShaderExt() string {
+	return "." + *target
+}
+
+// CheckTarget checks the -target flag, and that none of the
+// HLSLOnlyFlags are set for other targets.
+func CheckTarget() error {
+	if !slices.Contains(Targets, *target) {
+		return fmt.Errorf("gosl: -target must be one of: %s, not: %s", strings.Join(Targets, ", "), *target)
+	}
+	if *hlslVersion != HLSL2018 && *hlslVersion != HLSL2021 {
+		return fmt.Errorf("gosl: -hlsl must be %s or %s, not: %s", HLSL2018, HLSL2021, *hlslVersion)
+	}
+	if *target == TargetHLSL {
+		return nil
+	}
+	var bad []string
+	flag.Visit(func(f *flag.Flag) {
+		if slices.Contains(HLSLOnlyFlags, f.Name) {
+			bad = append(bad, "-"+f.Name)
+		}
+	})
+	if len(bad) > 0 {
+		sort.Strings(bad)
+		return fmt.Errorf("gosl: %s cannot be used with -target %s: only with -target %s", strings.Join(bad, ", "), *target, TargetHLSL)
+	}
+	return nil
+}
+
+// targetKey returns the target of the given //gosl: key, e.g., wgsl for
+// //gosl: wgsl axon, and the rest of the key after it, with ok = false
+// if the key does not start with one of the Targets.
+func targetKey(key []byte) (tg string, rest []byte, ok bool) {
+	for _, tg := range Targets {
+		if len(key) > len(tg) && string(key[:len(tg)]) == tg && (key[len(tg)] == ' ' || key[len(tg)] == '\t') {
+			return tg, key[len(tg):], true
+		}
+	}
+	for _, tg := range Targets {
+		if string(key) == tg {
+			return tg, nil, true
+		}
+	}
+	return "", nil, false
+}
+
+// isTargetKey returns true if the given //gosl: key starts a raw code
+// block for one of the Targets, e.g., wgsl axon.
+func isTargetKey(key []byte) bool {
+	_, _, ok := targetKey(key)
+	return ok
+}
+
+// GLSLVersion returns the #version directive for the GLSL file with the
+// given name, which has an entry point, as it must be the first directive
+// of a GLSL shader, with the extension for the #include of any uses,
+// and those of the subgroup functions with -subgroups.
+func GLSLVersion(fn string) []byte {
+	ver := "#version 450\n"
+	if len(RegionUses[fn]) > 0 {
+		ver += "#extension GL_GOOGLE_include_directive : require\n"
+	}
+	ver += string(SubgroupHeader())
+	return []byte(ver + "\n")
+}
+
+// MetalHeader returns the header of the MSL files, which include the
+// Metal standard library for the math functions and types.
+func MetalHeader() []byte {
+	return []byte("#include <metal_stdlib>\nusing namespace metal;\n\n")
+}
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"github.com/emer/gosl/v2/slbool"
+	"github.com/emer/gosl/v2/sltype"
+)
+
+//gosl: start alias
+
+// Ge is an alias for the conductance type
+type Ge = float32
+
+// Count is an alias for an unsigned count
+type Count = uint32
+
+// Nrn is an alias for a struct type
+type Nrn = Neuron
+
+// NeuronFlags are named types, which are defined by a typedef
+type NeuronFlags int32
+
+const NeuronOff NeuronFlags = 1
+
+// Neuron has fields of alias and named types
+type Neuron struct {
+	G     Ge
+	Flags NeuronFlags
+	N     Count
+	Arr   [2]Ge
+	On    slbool.Bool
+	Vm    sltype.Float
+	pad   int32
+}
+
+// Step has parameters, results and locals of alias and named types
+func (nrn *Neuron) Step(dt Ge, o *Nrn) Ge {
+	var g Ge = nrn.G * dt
+	n := Count(2)
+	nrn.N += n
+	x := g
+	var on slbool.Bool = nrn.On
+	vm := sltype.Float(x)
+	if on == slbool.True && (nrn.Flags&NeuronOff) == 0 {
+		o.G = x + vm
+	}
+	var f NeuronFlags = NeuronOff
+	nrn.Flags |= f
+	return g
+}
+
+//gosl: end alias
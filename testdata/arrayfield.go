@@ -0,0 +1,25 @@
+package test
+
+//gosl: start arrayfield
+
+// Layer has the parameters of a layer
+type Layer struct {
+	Gain, Bias float32
+	Taus       [3]float32
+	Lo, Hi     [2]int32
+
+	pad, pad1, pad2 float32
+}
+
+// Params has the parameters of all layers
+type Params struct {
+	Layers [4]Layer
+	Grid   [2][4]uint32
+}
+
+// TauSum returns the sum of the time constants
+func (ly *Layer) TauSum() float32 {
+	return ly.Taus[0] + ly.Taus[1] + ly.Taus[2]
+}
+
+//gosl: end arrayfield
@@ -0,0 +1,29 @@
+package test
+
+import "github.com/emer/gosl/v2/slsync"
+
+//gosl: start barrier
+
+// Pool has the sums of the activations of a pool of neurons
+type Pool struct {
+	Sum, Max float32
+
+	pad, pad1 float32
+}
+
+// SumActs adds the given activation to the pool, with each of the
+// barriers and memory fences of slsync between the steps
+func (pl *Pool) SumActs(act float32) {
+	pl.Sum += act
+	slsync.GroupBarrier()
+	slsync.GroupMemoryBarrier()
+	if act > pl.Max {
+		pl.Max = act
+	}
+	slsync.DeviceMemoryBarrier()
+	slsync.DeviceBarrier()
+	slsync.AllMemoryBarrier()
+	slsync.AllBarrier()
+}
+
+//gosl: end barrier
@@ -0,0 +1,31 @@
+package test
+
+import "github.com/emer/gosl/v2/slbool"
+
+//gosl: start boolpack
+
+// Flags has flags packed into one field
+type Flags struct {
+	On    slbool.Bool
+	State slbool.Bool4
+	Pair  slbool.Bool2
+	pad   int32
+}
+
+// Update sets the state flags from the given values
+func (f *Flags) Update(v float32) {
+	slbool.Set(&f.State, 0, v > 0)
+	slbool.Set(&f.State, 2, slbool.Get(f.Pair, 1))
+	if slbool.Get(f.State, 3) {
+		f.Pair = slbool.Pack2(true, false)
+	}
+}
+
+// NewFlags returns flags with all of the state on
+func NewFlags() Flags {
+	f := Flags{}
+	f.State = slbool.Pack4(true, true, true, true)
+	return f
+}
+
+//gosl: end boolpack
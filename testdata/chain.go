@@ -0,0 +1,55 @@
+package test
+
+//gosl: start chain
+
+// MinMax is a range of values
+type MinMax struct {
+	Min, Max float32
+
+	pad, pad1 float32
+}
+
+// Clip returns x clipped to the range
+func (mm MinMax) Clip(x float32) float32 {
+	return min(max(x, mm.Min), mm.Max)
+}
+
+// Scaled returns the range scaled by given factor
+func (mm MinMax) Scaled(f float32) MinMax {
+	var r MinMax
+	r.Min = f * mm.Min
+	r.Max = f * mm.Max
+	return r
+}
+
+// Params has the range parameters
+type Params struct {
+	Rng  MinMax
+	Gain float32
+
+	pad, pad1, pad2 float32
+}
+
+// Self returns the range
+func (mm MinMax) Self() MinMax {
+	return mm
+}
+
+// Range returns the range
+func (ps *Params) Range() MinMax {
+	return ps.Rng
+}
+
+// Norm returns x clipped to the scaled range
+func (ps *Params) Norm(x float32) float32 {
+	y := ps.Range().Clip(x)
+	if ps.Range().Scaled(ps.Gain).Clip(x) > 0 {
+		y += ps.Range().Scaled(2).Scaled(ps.Gain).Clip(y)
+	}
+	if ps.Gain > 0 && ps.Range().Clip(x) > 0 {
+		y = 0
+	}
+	return ps.Rng.Scaled(2).Self().Clip(y)
+}
+
+//gosl: end chain
@@ -0,0 +1,21 @@
+package test
+
+//gosl: start complex
+
+// Rotate rotates and scales a by b, and divides by the twiddle factor
+func Rotate(a, b complex64, th float32) complex64 {
+	w := complex(th, 0)
+	c := a*b + w/b - 2*I
+	c *= b
+	if c == a {
+		c = -c
+	}
+	var d complex64 = 3
+	d /= a
+	return complex(real(c), imag(d))
+}
+
+// I is the imaginary unit
+const I = 1i
+
+//gosl: end complex
@@ -0,0 +1,13 @@
+package test
+
+import "math/cmplx"
+
+//gosl: start complexerr
+
+// Spin has the uses of complex numbers that cannot be translated
+func Spin(a complex128, b complex64) float32 {
+	d := cmplx.Abs(a)
+	return float32(real(a)) + real(b) + float32(d)
+}
+
+//gosl: end complexerr
@@ -0,0 +1,54 @@
+package test
+
+import "github.com/emer/gosl/v2/sltype"
+
+//gosl: start copy
+
+// Vals has some values
+type Vals struct {
+	A float32
+	B int32
+
+	pad, pad1 float32
+}
+
+// Outer contains Vals
+type Outer struct {
+	V Vals
+	X float32
+
+	pad, pad1, pad2 float32
+}
+
+// CopyVals exercises copy and whole-struct assignment
+func CopyVals(o *Outer, v *Vals, arr *[4]float32) {
+	var tmp [4]float32
+	copy(tmp[:], arr[:])
+	copy(arr[1:3], tmp[:2])
+	copy(arr[1:4], arr[0:3])
+	copy(arr[0:3], arr[1:4])
+	*v = o.V
+	o.V = *v
+}
+
+// Pos has a vector, which is an HLSL vector type
+type Pos struct {
+	P sltype.Float2
+	Z float32
+
+	pad float32
+}
+
+// AssignPos assigns vectors and a struct with a vector, as a whole
+func AssignPos(p *Pos, q Pos, v *sltype.Float2) {
+	*v = q.P
+	*p = q
+}
+
+// CopyN requires a constant number of elements to copy
+func CopyN(arr *[4]float32, n int32) {
+	var tmp [4]float32
+	copy(tmp[:n], arr[:])
+}
+
+//gosl: end copy
@@ -0,0 +1,30 @@
+package test
+
+import "fmt"
+
+//gosl: start cpublock
+
+// Params are the parameters
+type Params struct {
+	Gain, Max float32
+
+	pad, pad1 float32
+}
+
+// Scale returns v scaled by the gain, limited to Max, with a
+// warning on the CPU if it exceeds Max
+func (ps *Params) Scale(v float32) float32 {
+	sv := ps.Gain * v
+	if sv > ps.Max {
+		//gosl: cpuonly
+		msg := fmt.Sprintf("Scale: %g exceeds Max: %g", sv, ps.Max)
+		if len(msg) > 0 {
+			fmt.Println(msg)
+		}
+		//gosl: end cpuonly
+		sv = ps.Max
+	}
+	return sv
+}
+
+//gosl: end cpublock
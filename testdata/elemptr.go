@@ -0,0 +1,55 @@
+package test
+
+//gosl: start elemptr
+
+// Layer has the layer-level state
+type Layer struct {
+	Gi, Act float32
+
+	pad, pad1 float32
+}
+
+// CycleNeuron updates the layer state for a neuron
+func (ly *Layer) CycleNeuron(ge float32) {
+	ly.Gi += ge
+}
+
+// Pool has layers
+type Pool struct {
+	Lays [4]Layer
+}
+
+// Reset resets the first layer through a pointer to it
+func (pl *Pool) Reset() {
+	ly := &pl.Lays[0]
+	ly.Gi = 0
+	ly.CycleNeuron(1)
+}
+
+// Neuron has the neuron state
+type Neuron struct {
+	LayIndex uint32
+	Ge       float32
+
+	pad, pad1 float32
+}
+
+// Step updates the layer of the neuron, through pointers that must not
+// be copies, or the writes would be lost
+func Step(nrn *Neuron, pl *Pool) {
+	ly := &pl.Lays[nrn.LayIndex]
+	ly.CycleNeuron(nrn.Ge)
+	// the pointer continues to refer to the same element
+	nrn.Ge = ly.Gi
+	ly.Act = nrn.Ge
+	gi := &ly.Gi
+	*gi += 1
+	n := nrn
+	n.Ge *= 2
+	for i := range 2 {
+		li := &pl.Lays[i]
+		li.CycleNeuron(n.Ge)
+	}
+}
+
+//gosl: end elemptr
@@ -0,0 +1,64 @@
+package test
+
+import "github.com/emer/gosl/v2/sltype"
+
+//gosl: start entry
+
+// Neuron has the state of a neuron
+type Neuron struct {
+	Vm, Act  float32
+	LayIndex uint32
+	pad      float32
+}
+
+// Layer has the parameters of a layer of neurons
+type Layer struct {
+	Gain, Decay float32
+
+	pad, pad1 float32
+}
+
+// Synapse has the state of a synapse
+type Synapse struct {
+	Wt, DWt float32
+
+	pad, pad1 float32
+}
+
+// CycleNeuron updates the activation of the neuron
+//
+// gosl: kernel CycleNeuron ly=Layers[nrn.LayIndex]
+func (ly *Layer) CycleNeuron(ni uint32, nrn *Neuron) {
+	nrn.Act = ly.Gain * nrn.Vm
+}
+
+// DecayNeuron decays the membrane potential of the neuron
+//
+// gosl: kernel Decay threads=128 ly=Layers[0]
+func DecayNeuron(nrn *Neuron, ly Layer) {
+	nrn.Vm -= ly.Decay * nrn.Vm
+}
+
+// UpdateWt updates the weight of the synapse at the given 2D index
+//
+// gosl: kernel UpdateWts threads=16
+func UpdateWt(idx sltype.Uint3, sy *Synapse) {
+	sy.Wt += sy.DWt
+	sy.DWt = 0
+}
+
+// Normalize normalizes the activation of the neuron at the given index
+// by the gain of its layer, indexing the buffers passed as slices
+//
+// gosl: readonly lays
+// gosl: kernel NormalizeActs
+func Normalize(i uint32, nrns []Neuron, lays []Layer) {
+	nrn := &nrns[i]
+	nrn.Act /= lays[nrn.LayIndex].Gain
+}
+
+//gosl: buffer 0 0 Layers []Layer readonly
+//gosl: buffer 0 1 Neurons []Neuron
+//gosl: buffer 0 2 Synapses []Synapse
+
+//gosl: end entry
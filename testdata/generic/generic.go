@@ -0,0 +1,49 @@
+package test
+
+//gosl: start generic
+
+// Number is the constraint of the generic math functions
+type Number interface {
+	~float32 | ~int32
+}
+
+// Min returns the smaller of a and b
+func Min[T Number](a, b T) T {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of a and b
+func Max[T Number](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Clamp returns v clamped to the range from lo to hi
+func Clamp[T Number](v, lo, hi T) T {
+	if v > hi {
+		return hi
+	}
+	return T(Max(float32(v), float32(lo)))
+}
+
+// Neuron has the neuron variables
+type Neuron struct {
+	Act  float32
+	Spks int32
+
+	pad, pad1 float32
+}
+
+// Update clamps the neuron variables
+func (nrn *Neuron) Update(hi float32) {
+	nrn.Act = Clamp(nrn.Act, 0, hi)
+	nrn.Spks = Clamp[int32](nrn.Spks, 0, 10)
+	nrn.Act = Min(nrn.Act, Min[float32](hi, 1))
+}
+
+//gosl: end generic
@@ -0,0 +1,31 @@
+package test
+
+import "cogentcore.org/core/math32"
+
+//gosl: start globals
+
+// TauFact is the time constant factor
+var TauFact = math32.Pow(2, -0.5)
+
+var (
+	// Gain is computed from TauFact
+	Gain = 2 * TauFact
+
+	// Scale is set by SetScale
+	Scale = math32.Exp(1)
+)
+
+// Offset is computed by a function of the package
+var Offset = Bias(3)
+
+// Bias returns the given value scaled by TauFact
+func Bias(x float32) float32 {
+	return x * TauFact
+}
+
+// SetScale sets the Scale
+func SetScale(s float32) {
+	Scale = s
+}
+
+//gosl: end globals
@@ -0,0 +1,16 @@
+package test
+
+//gosl: start errors
+
+// First returns a
+func First[T any](a, b T) T {
+	return a
+}
+
+// Pick calls First with an array type, which is not a template argument
+func Pick(a, b [2]float32) float32 {
+	c := First(a, b)
+	return c[0]
+}
+
+//gosl: end errors
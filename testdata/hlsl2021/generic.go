@@ -0,0 +1,71 @@
+package test
+
+import (
+	"github.com/emer/gosl/v2/slrand"
+	"github.com/emer/gosl/v2/sltype"
+)
+
+//gosl: start generic
+
+// Number is the constraint of the generic math functions
+type Number interface {
+	~float32 | ~int32
+}
+
+// Max returns the larger of a and b
+func Max[T Number](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Clamp returns v clamped to the range from lo to hi
+func Clamp[T Number](v, lo, hi T) T {
+	if v > hi {
+		return hi
+	}
+	return Max(v, lo)
+}
+
+// Complex is a complex number
+type Complex struct {
+	Re, Im float32
+
+	pad, pad1 float32
+}
+
+// Add returns the sum of a and b
+func (a Complex) Add(b Complex) Complex {
+	return Complex{Re: a.Re + b.Re, Im: a.Im + b.Im}
+}
+
+// Mul returns the product of a and b
+func (a Complex) Mul(b Complex) Complex {
+	return Complex{Re: a.Re*b.Re - a.Im*b.Im, Im: a.Re*b.Im + a.Im*b.Re}
+}
+
+// Neuron has the neuron variables
+type Neuron struct {
+	Act  float32
+	Spks int32
+
+	pad, pad1 float32
+
+	Z Complex
+	W Complex
+}
+
+// Update clamps the neuron variables
+func (nrn *Neuron) Update(hi float32) {
+	nrn.Act = Clamp(nrn.Act, 0, hi)
+	nrn.Spks = Clamp[int32](nrn.Spks, 0, 10)
+	nrn.Z = nrn.Z.Mul(nrn.W).Add(nrn.W)
+}
+
+// Noise adds uniform noise to the activation, with a Philox4x32 counter
+func (nrn *Neuron) Noise(ctr *sltype.Uint4, idx uint32) {
+	nrn.Act += slrand.Float(ctr, idx)
+}
+
+//gosl: end generic
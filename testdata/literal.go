@@ -0,0 +1,54 @@
+package test
+
+import "github.com/emer/gosl/v2/sltype"
+
+//gosl: start literal
+
+// F32 is a float range
+type F32 struct {
+	Min, Max float32
+
+	pad, pad1 float32
+}
+
+// Mid returns the midpoint of the range
+func (f *F32) Mid() float32 {
+	return 0.5 * (f.Min + f.Max)
+}
+
+// Rng has a nested range
+type Rng struct {
+	A   F32
+	Pos sltype.Float2
+	N   int32
+	Wts [3]float32
+
+	pad, pad1 float32
+}
+
+// MakeF32 returns a range from a literal
+func MakeF32(mn, mx float32) F32 {
+	return F32{Min: mn, Max: mx}
+}
+
+// UseF32 returns the max of the range
+func UseF32(f F32) float32 {
+	return f.Max
+}
+
+// Literals exercises struct and vector composite literals
+func Literals(x float32, rs *Rng) float32 {
+	t := F32{Min: 0, Max: 1}
+	u := F32{1, 2, 0, 0}
+	var e F32 = F32{}
+	var w = Rng{A: u, Wts: [3]float32{1, 2: x}}
+	*rs = Rng{A: F32{Max: x}, N: 2}
+	v := sltype.Float2{X: 1, Y: x}
+	w.Pos = v
+	sum := t.Max + u.Min + e.Max + w.A.Min
+	sum += MakeF32(1, 2).Mid()
+	sum += UseF32(F32{Min: x, Max: t.Mid()})
+	return sum
+}
+
+//gosl: end literal
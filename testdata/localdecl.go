@@ -0,0 +1,30 @@
+package test
+
+//gosl: start localdecl
+
+// LocalDecls has local declarations of all forms
+func LocalDecls(x float32) float32 {
+	const n = 4
+	const (
+		gain       = 0.5
+		lim  int32 = 10
+	)
+	var a, b float32
+	var c, d = x, 2 * x
+	var k, m = x, lim
+	var (
+		e       float32
+		f, g    int32 = 1, 2
+		h             = x * gain
+		arr     [n]float32
+		u, v, w uint32
+	)
+	a = x + e + h
+	b = float32(f + g + m)
+	a += k
+	arr[0] = c + d
+	u = v + w
+	return a + b + arr[0] + float32(u)
+}
+
+//gosl: end localdecl
@@ -0,0 +1,42 @@
+package test
+
+//gosl: start loops
+
+// Loops has for loops that are not plain C for loops
+func Loops(n int32) float32 {
+	s := float32(0)
+	// the post statement is run before each continue
+	for i, j := int32(0), n; i < j; i, j = i+1, j-1 {
+		if i == 2 {
+			continue
+		}
+		for k := int32(0); k < i; k++ {
+			if k == 1 {
+				continue
+			}
+			s += 1
+		}
+		s += float32(j)
+	}
+	// the values are assigned at once
+	for a, b := float32(0), float32(1); a < 100; a, b = b, a+b {
+		s += a
+	}
+	k := int32(0)
+	for k < n {
+		k++
+		if k == 2 {
+			continue
+		}
+		s += 1
+	}
+	for {
+		if s > 10 {
+			break
+		}
+		s += 1
+	}
+	return s
+}
+
+//gosl: end loops
@@ -0,0 +1,40 @@
+package test
+
+//gosl: start make
+
+// NVals is the number of values
+const NVals = 4
+
+// Vals has some values
+type Vals struct {
+	A float32
+	B int32
+
+	pad, pad1 float32
+}
+
+// MakeVals exercises local arrays from make and slice literals
+func MakeVals(v *Vals, n int32) float32 {
+	tmp := make([]float32, NVals)
+	vs := make([]Vals, 2)
+	ws := []float32{v.A, 2, 3}
+	vs[0] = *v
+	tmp[1] = ws[2] + vs[0].A
+	return tmp[1]
+}
+
+// MakeEmpty makes slices without elements, which are not local arrays
+func MakeEmpty(n int32) float32 {
+	es := []float32{}
+	ms := make([]float32, 0)
+	ns := make([]float32, n)
+	return es[0] + ms[0] + ns[0]
+}
+
+// MakeKeyed has a keyed slice literal, which is not a local array
+func MakeKeyed() float32 {
+	ks := []float32{1: 2, 0: 1}
+	return ks[1]
+}
+
+//gosl: end make
@@ -0,0 +1,43 @@
+package test
+
+import (
+	"math"
+
+	"cogentcore.org/core/math32"
+)
+
+//gosl: start mathconst
+
+// MaxFloat32 is redefined here, as in older shader code
+const MaxFloat32 = 3.402823466e+38
+
+// Limits has limit values
+type Limits struct {
+	Min float32
+	Max float32
+	N   int32
+	U   uint32
+}
+
+// NewLimits returns limits with their initial values
+func NewLimits() Limits {
+	var lm Limits
+	lm.Min = math.MaxFloat32
+	lm.Max = -math32.MaxFloat32
+	lm.N = math.MinInt32
+	lm.U = math.MaxUint32
+	return lm
+}
+
+// Clip clips the value to the limits, with infinite values for none
+func (lm *Limits) Clip(v float32) float32 {
+	if lm.Min == MaxFloat32 {
+		return math32.Infinity
+	}
+	if v < math.SmallestNonzeroFloat32 {
+		return math32.Pi * v
+	}
+	return v
+}
+
+//gosl: end mathconst
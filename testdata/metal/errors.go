@@ -0,0 +1,23 @@
+package test
+
+//gosl: start errors
+
+// NThreads is the number of threads per workgroup
+const NThreads = 64
+
+//gosl: groupshared
+var Sums [NThreads]float32
+
+// Clear has a pointer to an array parameter, which is not a reference in MSL
+func Clear(vals *[4]float32) {
+	for i := range 4 {
+		vals[i] = Sums[i]
+	}
+}
+
+// Sum has a slice parameter, which is only a buffer parameter in HLSL
+func Sum(vals []float32) float32 {
+	return vals[0] + vals[1]
+}
+
+//gosl: end errors
@@ -0,0 +1,25 @@
+package test
+
+import "github.com/emer/gosl/v2/slint"
+
+//gosl: start minmax
+
+// Bounds has integer and float bounds
+type Bounds struct {
+	Lo, Hi int32
+	Gain   float32
+
+	pad float32
+}
+
+// Limit exercises the min and max builtins and the slint helpers
+func Limit(bs *Bounds, i int32, x float32) float32 {
+	n := max(i, bs.Lo)
+	m := min(n, bs.Hi, 10)
+	c := slint.Clamp(i, bs.Lo, bs.Hi)
+	k := slint.MinInt(c, m) + slint.MaxInt(c, 1)
+	y := max(x*bs.Gain, 0.5, float32(k))
+	return min(y) + min(x, 1)
+}
+
+//gosl: end minmax
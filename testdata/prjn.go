@@ -0,0 +1,50 @@
+package test
+
+import "github.com/emer/gosl/v2/slfixed"
+
+//gosl: start prjn
+
+// Neuron has the calcium of a neuron, for learning
+type Neuron struct {
+	CaSpkP, CaSpkD float32
+
+	pad, pad1 float32
+}
+
+// Synapse has the state of a synapse, as in the axon example
+type Synapse struct {
+	SendIndex, RecvIndex, PrjnIndex uint32
+
+	Wt, DWt float32
+
+	pad, pad1, pad2 float32
+}
+
+// Prjn has the parameters of a projection, as in the axon example
+type Prjn struct {
+	SendLay, RecvLay uint32
+
+	GScale, LRate float32
+}
+
+// SpikeG returns the conductance sent by a spike, in fixed point
+func (pj *Prjn) SpikeG(sy *Synapse) int32 {
+	return slfixed.FromFloat(pj.GScale * sy.Wt)
+}
+
+// DWt computes the weight change from the sending and receiving neurons
+func (pj *Prjn) DWt(sy *Synapse, sn, rn *Neuron) {
+	sy.DWt += pj.LRate * (sn.CaSpkP*rn.CaSpkP - sn.CaSpkD*rn.CaSpkD)
+}
+
+// WtFromDWt updates the weight from the weight change, soft bounded
+func (pj *Prjn) WtFromDWt(sy *Synapse) {
+	if sy.DWt > 0 {
+		sy.Wt += sy.DWt * (1 - sy.Wt)
+	} else {
+		sy.Wt += sy.DWt * sy.Wt
+	}
+	sy.DWt = 0
+}
+
+//gosl: end prjn
@@ -0,0 +1,28 @@
+package test
+
+//gosl: start rangeint
+
+// NVals is the number of values
+const NVals = 4
+
+// Sum returns the sum of the first n squares, using range over int loops
+func Sum(n int32) float32 {
+	var vals [NVals]float32
+	for i := range NVals {
+		vals[i] = float32(i * i)
+	}
+	sum := float32(0)
+	for i := range n {
+		sum += vals[i%NVals]
+	}
+	var j uint32
+	for j = range uint32(n) {
+		sum += float32(j)
+	}
+	for range 2 {
+		sum *= 2
+	}
+	return sum
+}
+
+//gosl: end rangeint
@@ -0,0 +1,29 @@
+package test
+
+//gosl: start reserved
+
+// matrix has a reserved type name
+type matrix struct {
+	Wt, LWt float32
+
+	pad, pad1 float32
+}
+
+// Synapse has fields named with HLSL reserved words
+type Synapse struct {
+	sample, line float32
+
+	enum int32
+
+	globallycoherent int32
+}
+
+// Learn uses local variables with reserved and non-ASCII names
+func (sy *Synapse) Learn(lr float32, mx *matrix) float32 {
+	ΔWt := lr * sy.sample
+	line := sy.line + ΔWt
+	mx.Wt += line
+	return mx.Wt
+}
+
+//gosl: end reserved
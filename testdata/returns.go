@@ -0,0 +1,122 @@
+package test
+
+import "github.com/emer/gosl/v2/slbool"
+
+//gosl: start returns
+
+// Modes
+const (
+	Off int32 = iota
+	Low
+	High
+)
+
+// Gate has early returns
+type Gate struct {
+	Thr, Gain float32
+	Mode      int32
+	On        slbool.Bool
+}
+
+// Step is a void method with early returns
+func (g *Gate) Step(x float32) {
+	if g.On.IsFalse() {
+		return
+	}
+	for i := range 4 {
+		if x > float32(i) {
+			g.Thr += x
+			return
+		}
+	}
+	switch g.Mode {
+	case Off:
+		g.Gain = 0
+		return
+	case Low:
+		if x < 0 {
+			return
+		} else {
+			g.Gain = x
+			return
+		}
+	default:
+		g.Gain = x
+	}
+	g.Gain *= 2
+}
+
+// Clip has a return in each branch
+func (g *Gate) Clip(x float32) float32 {
+	if x < 0 {
+		return 0
+	} else if x > g.Thr {
+		return g.Thr
+	} else {
+		return x
+	}
+}
+
+// Scaled has a named result
+func (g *Gate) Scaled(x float32) (y float32) {
+	if g.On.IsFalse() {
+		return
+	}
+	y = g.Gain * x
+	return
+}
+
+// Level has returns in switch cases
+func Level(mode int32) float32 {
+	switch mode {
+	case Off:
+		return 0
+	case Low:
+		return 0.5
+	}
+	return 1
+}
+
+// Find has returns in nested loops and blocks
+func Find(x float32) int32 {
+	for i := int32(0); i < 10; i++ {
+		for j := range 4 {
+			if x == float32(i*int32(j)) {
+				return i
+			}
+		}
+		{
+			if x < 0 {
+				return -1
+			}
+		}
+	}
+	return -2
+}
+
+// Count has a named result and a loop with continue and break
+func Count(x float32) (n int32) {
+	for i := range 8 {
+		switch i {
+		case 0:
+			continue
+		case 7:
+			break
+		}
+		if float32(i) > x {
+			return
+		}
+		n++
+	}
+	return
+}
+
+// MinMax has multiple results, which are not supported
+func MinMax(a, b float32) (float32, float32) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
+
+//gosl: end returns
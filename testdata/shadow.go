@@ -0,0 +1,46 @@
+package test
+
+//gosl: start shadow
+
+// Layer has a gain
+type Layer struct {
+	Gain, Off float32
+
+	pad, pad1 float32
+}
+
+// Scale shadows the parameter in a nested block
+func (ly *Layer) Scale(x float32) float32 {
+	y := x * ly.Gain
+	if x > 0 {
+		x := x + ly.Off
+		y := y * x
+		x += y
+	}
+	return x + y
+}
+
+// Shadow has shadowed variables in loops and switch cases
+func Shadow(x float32, n int32) float32 {
+	y := x * 2
+	for i := range n {
+		x := float32(i)
+		y += x
+		for i := range 2 {
+			y += float32(i)
+		}
+	}
+	switch n {
+	case 1:
+		y := float32(n)
+		x += y
+	}
+	const y_1 = 2
+	{
+		y := y * y_1
+		x += y
+	}
+	return x + y
+}
+
+//gosl: end shadow
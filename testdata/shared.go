@@ -0,0 +1,27 @@
+package test
+
+//gosl: start shared
+
+// NThreads is the number of threads per workgroup
+const NThreads = 64
+
+// Gain is an untyped float constant
+const Gain = 0.5
+
+//gosl: groupshared
+var Sums [NThreads]float32
+
+//gosl: groupshared
+var Grid [4][NThreads]int32
+
+// Reduce is one step of a tree reduction in shared memory
+func Reduce(ti, stride uint32) {
+	var tmp [2]float32
+	tmp[0] = Sums[ti]
+	if ti < stride {
+		Sums[ti] = Gain * (tmp[0] + Sums[ti+stride])
+		Grid[0][ti]++
+	}
+}
+
+//gosl: end shared
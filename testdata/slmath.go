@@ -0,0 +1,17 @@
+package test
+
+import (
+	"cogentcore.org/core/math32"
+	"github.com/emer/gosl/v2/slmath"
+)
+
+//gosl: start slmath
+
+// Rates computes a saturating rate, which matches the GPU on the CPU
+// when built with the slmathgpu tag, and a standard one.
+func Rates(x, tau float32) float32 {
+	r := slmath.Logistic(x) * slmath.Exp(-1/tau)
+	return r + slmath.Pow(x, 2) + math32.Exp(x)
+}
+
+//gosl: end slmath
@@ -0,0 +1,33 @@
+package test
+
+import "github.com/emer/gosl/v2/slwave"
+
+//gosl: start wave
+
+// Pool has the summary values of a pool of neurons
+type Pool struct {
+	ActSum float32
+	NAct   uint32
+	MaxIdx int32
+
+	pad float32
+}
+
+// WaveSum adds the act of each lane of the subgroup to the pool
+func WaveSum(pl *Pool, act float32, idx int32) float32 {
+	sum := slwave.ActiveSum(act)
+	n := slwave.ActiveSum(uint32(1))
+	off := slwave.PrefixSum(act)
+	if slwave.IsFirstLane() {
+		pl.ActSum += sum
+		pl.NAct += n
+		pl.MaxIdx = slwave.ActiveMax(idx)
+	}
+	first := slwave.ReadLaneFirst(act)
+	if slwave.ActiveAnyTrue(act > first) && slwave.LaneIndex() < slwave.LaneCount() {
+		return off + slwave.ReadLaneAt(act, 0)
+	}
+	return off
+}
+
+//gosl: end wave
@@ -0,0 +1,82 @@
+package test
+
+//gosl: start switch
+
+// Modes are the integration modes
+type Modes int32
+
+const (
+	ModeOff Modes = iota
+	ModeExp
+	ModeLin
+	ModeSig
+)
+
+// Flags are unsigned flags
+type Flags uint32
+
+const (
+	FlagNone Flags = iota
+	FlagClamp
+)
+
+// Steps has the switch statements
+type Steps struct {
+	Mode  Modes
+	Flag  Flags
+	Gain  float32
+	Thr   float32
+	Count int32
+
+	pad, pad1, pad2 float32
+}
+
+// Step exercises case lists, fallthrough, and enum case values
+func (st *Steps) Step(x float32) float32 {
+	y := x
+	switch st.Mode {
+	case ModeOff:
+		return 0
+	case ModeExp, ModeLin:
+		y *= st.Gain
+		fallthrough
+	case ModeSig:
+		y += st.Thr
+	default:
+		y = -1
+	}
+	switch st.Flag {
+	case FlagClamp:
+		y = min(y, 1)
+	}
+	switch n := st.Count * 2; n {
+	case 0, 2:
+		y += 1
+	case 4:
+		y += float32(n)
+	}
+	return y
+}
+
+// Sign exercises switch statements without a value
+func Sign(x, thr float32) float32 {
+	r := float32(0)
+	switch {
+	case x > thr, x < -thr:
+		r = x
+	default:
+		r = thr
+	case x == 0:
+		r = 0
+		break
+	}
+	switch y := x * 2; {
+	case y < 0:
+		return -1
+	case y > 0:
+		return 1
+	}
+	return r
+}
+
+//gosl: end switch
@@ -0,0 +1,37 @@
+package test
+
+//gosl: start switcherr
+
+// SwitchErrs has the switch statements that cannot be translated
+type SwitchErrs struct {
+	Mode int32
+	Thr  int32
+
+	pad, pad1 float32
+}
+
+// Step has a case value that is not a constant, and a break and a
+// fallthrough in a switch without a value
+func (se *SwitchErrs) Step(x float32) float32 {
+	switch se.Mode {
+	case se.Thr:
+		x = 0
+	}
+	for i := 0; i < 4; i++ {
+		switch {
+		case x > 1:
+			if x > 2 {
+				break
+			}
+			x = 1
+		case x < 0:
+			x = 0
+			fallthrough
+		default:
+			x += 1
+		}
+	}
+	return x
+}
+
+//gosl: end switcherr
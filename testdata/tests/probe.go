@@ -0,0 +1,12 @@
+package test
+
+//gosl: start probe
+
+// Neuron has the state of a neuron
+type Neuron struct {
+	Vm, Act float32
+
+	pad, pad1 float32
+}
+
+//gosl: end probe
@@ -0,0 +1,18 @@
+package test
+
+//gosl: start probetest
+
+// Probe has the state of a test probe, only used in tests
+type Probe struct {
+	Sum, N float32
+
+	pad, pad1 float32
+}
+
+// Record records the given value in the probe
+func (pr *Probe) Record(v float32) {
+	pr.Sum += v
+	pr.N += 1
+}
+
+//gosl: end probetest
@@ -0,0 +1,182 @@
+package test
+
+import (
+	"math"
+
+	"cogentcore.org/core/math32"
+	"github.com/emer/gosl/v2/slbool"
+	"github.com/emer/gosl/v2/sltype"
+)
+
+//gosl: nohlsl basic
+
+// MyTrickyFun this is the CPU version of the tricky function
+func MyTrickyFun(x float32) float32 {
+	return 10
+}
+
+//gosl: end basic
+
+//gosl: hlsl basic
+
+// // MyTrickyFun this is the HLSL version of the tricky function
+// float MyTrickyFun(float x) {
+// 	return 16;
+// }
+
+//gosl: end basic
+
+//gosl: wgsl basic
+
+// // MyTrickyFun this is the WGSL version of the tricky function
+// fn MyTrickyFun(x: f32) -> f32 {
+// 	return 16;
+// }
+
+//gosl: end basic
+
+//gosl: start basic
+
+// FastExp is a quartic spline approximation to the Exp function
+func FastExp(x float32) float32 {
+	if x <= -88.76731 {
+		return 0
+	}
+	i := int32(12102203*x) + 127*(1<<23)
+	m := i >> 7 & 0xFFFF // copy mantissa
+	i += (((((((((((3537 * m) >> 16) + 13668) * m) >> 18) + 15817) * m) >> 14) - 80470) * m) >> 11)
+	return math.Float32frombits(uint32(i))
+}
+
+// NeuronFlags are bit-flags encoding relevant binary state for neurons
+type NeuronFlags int32
+
+// The neuron flags
+const (
+	// NeuronOff flag indicates that this neuron has been turned off
+	NeuronOff NeuronFlags = 1
+
+	// NeuronHasExt means the neuron has external input
+	NeuronHasExt NeuronFlags = 1 << 2
+)
+
+// Modes are evaluation modes (Training, Testing, etc)
+type Modes int32
+
+// The evaluation modes
+const (
+	NoEvalMode Modes = iota
+	AllModes
+	Train
+	Test
+)
+
+// MaxIter is the maximum number of iterations
+const MaxIter = 10
+
+// DataStruct has the test data
+type DataStruct struct {
+
+	// raw value
+	Raw float32
+
+	// integrated value
+	Integ float32
+
+	// position
+	Pos sltype.Float2
+
+	// flags
+	Flags NeuronFlags
+
+	pad, pad1, pad2 float32
+}
+
+// ParamStruct has the test params
+type ParamStruct struct {
+
+	// rate constant in msec
+	Tau float32
+
+	// 1/Tau
+	Dt     float32
+	Option slbool.Bool
+
+	pad float32
+}
+
+// DtForTau returns the rate constant for the given time constant
+func DtForTau(tau float32) (dt float32) {
+	if tau <= 0 {
+		return
+	}
+	tau = math32.Max(tau, 1)
+	dt = 1 / tau
+	return
+}
+
+// NewParams returns params for the given time constant
+func NewParams(tau float32) ParamStruct {
+	return ParamStruct{Tau: tau, Dt: DtForTau(tau)}
+}
+
+// Decay returns the decayed value
+func (ps *ParamStruct) Decay(v float32) float32 {
+	return v - ps.Dt*v
+}
+
+func (ps *ParamStruct) IntegFromRaw(ds *DataStruct, modArg *float32) {
+	newVal := ps.Dt*(ds.Raw-ds.Integ) + *modArg
+	if newVal < -10 || ps.Option.IsTrue() {
+		newVal = -10
+	}
+	ds.Integ += newVal
+	ds.Integ = ps.Decay(ds.Integ)
+	ds.Pos = sltype.Float2{X: ds.Integ, Y: 1}
+	*modArg = math32.Exp(-ds.Integ)
+}
+
+// AnotherMeth does more computation
+func (ps *ParamStruct) AnotherMeth(ds *DataStruct, shift int32) {
+	var hist [4]float32
+	for i := 0; i < MaxIter; i++ {
+		ds.Integ *= 0.99
+		hist[i%4] = ds.Integ
+	}
+	ds.Flags &^= NeuronHasExt
+	ds.Flags |= NeuronOff << shift
+	lo, hi := hist[0], hist[3]
+	if lo > hi && (ds.Flags&NeuronOff != 0 || lo < 0) {
+		ds.Raw = lo
+	}
+
+	mode := Test
+	switch mode {
+	case Test:
+		fallthrough
+	case Train:
+		ab := float32(.5)
+		ds.Raw *= ab
+	default:
+		ds.Raw = 0
+	}
+	p := NewParams(ps.Tau)
+	p.IntegFromRaw(ds, &hist[1])
+}
+
+//gosl: end basic
+
+//gosl: wgsl basic
+/*
+@group(0) @binding(0) var<storage, read> Params: array<ParamStruct>;
+@group(0) @binding(1) var<storage, read_write> Data: array<DataStruct>;
+@compute @workgroup_size(64)
+fn main(@builtin(global_invocation_id) idx: vec3<u32>) {
+	var params = Params[0];
+	var data = Data[idx.x];
+	var raw = data.Raw;
+	ParamStruct_IntegFromRaw(&params, &data, &raw);
+	Data[idx.x] = data;
+}
+*/
+//gosl: end basic
@@ -0,0 +1,22 @@
+package test
+
+//gosl: start errors
+
+// Sum has a slice parameter, which is only a buffer parameter in HLSL
+func Sum(vals []float32) float32 {
+	return vals[0] + vals[1]
+}
+
+// Step has a fallthrough after other statements in a case
+func Step(mode int32, x float32) float32 {
+	switch mode {
+	case 0:
+		x = 0
+		fallthrough
+	case 1:
+		x += 1
+	}
+	return x
+}
+
+//gosl: end errors
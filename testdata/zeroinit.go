@@ -0,0 +1,34 @@
+package test
+
+//gosl: start zeroinit
+
+// Acc is an accumulator
+type Acc struct {
+	Sum, N float32
+
+	pad, pad1 float32
+}
+
+// NewAcc returns an accumulator with given sum
+func NewAcc(s float32) Acc {
+	a := Acc{}
+	a.Sum = s
+	return a
+}
+
+// ZeroInit has local struct and array variables declared without a value
+func ZeroInit(x float32) float32 {
+	var a Acc
+	a.Sum += x
+	var b Acc
+	b = NewAcc(x)
+	var c Acc
+	c = NewAcc(c.Sum)
+	var vals [3]float32
+	vals[0] = x
+	var s float32
+	s = a.Sum + b.Sum + c.Sum + vals[0]
+	return s
+}
+
+//gosl: end zeroinit
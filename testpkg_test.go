@@ -0,0 +1,72 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// testPackage returns the package of the given Go source, in a file with
+// the given name, as loaded for gosl: with its syntax, including comments,
+// and its types, type info and sizes, for the tests of the functions that
+// take a package.  Imports are type checked from source, e.g., of sltype.
+// Type errors fail the test.
+func testPackage(t *testing.T, fn, src string) *packages.Package {
+	t.Helper()
+	pkg := testPackageErrors(t, fn, src)
+	for _, err := range pkg.Errors {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+// testPackageErrors returns the package of the given Go source as in
+// testPackage, with its type errors in its Errors, for the tests of the
+// functions that report them.
+func testPackageErrors(t *testing.T, fn, src string) *packages.Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fn, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := types.SizesFor("gc", "amd64")
+	pkg := &packages.Package{Name: f.Name.Name, Fset: fset, Syntax: []*ast.File{f}, TypesSizes: sizes}
+	pkg.TypesInfo = &types.Info{Types: map[ast.Expr]types.TypeAndValue{}, Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}, Selections: map[*ast.SelectorExpr]*types.Selection{}}
+	conf := &types.Config{Sizes: sizes, Importer: importer.ForCompiler(fset, "source", nil), Error: func(err error) {
+		te := err.(types.Error)
+		pkg.Errors = append(pkg.Errors, packages.Error{Pos: te.Fset.Position(te.Pos).String(), Msg: te.Msg, Kind: packages.TypeError})
+	}}
+	pkg.Types, _ = conf.Check(f.Name.Name, fset, pkg.Syntax, pkg.TypesInfo)
+	return pkg
+}
+
+// checkGeneratedGo checks that the given generated Go files, by name,
+// compile with the files of the given package from testPackage.
+func checkGeneratedGo(t *testing.T, pkg *packages.Package, gen map[string][]byte) {
+	t.Helper()
+	files := pkg.Syntax
+	for fn, src := range gen {
+		gf, err := parser.ParseFile(pkg.Fset, fn, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, gf)
+	}
+	conf := &types.Config{Importer: importer.ForCompiler(pkg.Fset, "source", nil)}
+	if _, err := conf.Check(pkg.Name, pkg.Fset, files, nil); err != nil {
+		for fn, src := range gen {
+			t.Logf("%s:\n%s", fn, src)
+		}
+		t.Errorf("generated Go code does not compile: %v", err)
+	}
+}
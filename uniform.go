@@ -0,0 +1,333 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/emer/gosl/v2/alignsl"
+	"golang.org/x/tools/go/packages"
+)
+
+// UniformStruct is a struct type declared for use in a Uniform buffer
+// with a directive in the doc comment of the type:
+//
+//	//gosl: uniform
+//	type Params struct {
+//
+// Uniform buffers have stricter layout rules than Storage buffers (as
+// in std140): each element of an array is padded to 16 bytes (so a
+// [4]float32 takes 64 bytes), structs and arrays start at 16 byte
+// offsets, and vectors cannot straddle a 16 byte boundary.  The HLSL
+// struct is unchanged, and the compiler applies these rules to it in a
+// Uniform buffer, so gosl generates a padded Go mirror type with the
+// same layout, e.g., ParamsUniform, with Set and Get methods to convert
+// from and to the Go type, which is copied to the buffer instead.
+type UniformStruct struct {
+
+	// name of the struct type, e.g., Params
+	Type string
+
+	// struct type
+	Struct *types.Struct
+
+	// mirror types of this and the nested struct types,
+	// in order of generation
+	Mirrors []*uniformMirror
+}
+
+// uniformMirror is a generated mirror type of a struct type
+type uniformMirror struct {
+
+	// name of the struct type
+	Type string
+
+	// name of the mirror type
+	Name string
+
+	// Go source of the type and its methods
+	Src bytes.Buffer
+}
+
+// uniformLayout is the layout of a type in a Uniform buffer
+type uniformLayout struct {
+
+	// alignment in bytes
+	Align int
+
+	// size in bytes
+	Size int
+
+	// whether this is a 2-4 component vector, e.g., sltype.Float3,
+	// which is aligned to 4 bytes, but cannot straddle a 16 byte boundary
+	Vector bool
+}
+
+// isUniformDirective returns true if the given doc comment has
+// the //gosl: uniform directive, accepting the // gosl: form that
+// gofmt produces for doc comments.
+func isUniformDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(strings.TrimPrefix(c.Text, "//")) == "gosl: uniform" {
+			return true
+		}
+	}
+	return false
+}
+
+// FindUniformStructs returns the struct types declared for use in
+// Uniform buffers in the given package, sorted by name.
+func FindUniformStructs(pkg *packages.Package) ([]*UniformStruct, error) {
+	var uss []*UniformStruct
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, s := range gd.Specs {
+				ts, ok := s.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if !isUniformDirective(doc) {
+					continue
+				}
+				st, ok := pkg.Types.Scope().Lookup(ts.Name.Name).Type().Underlying().(*types.Struct)
+				if !ok {
+					return nil, fmt.Errorf("gosl: %s: only struct types can be declared for use in Uniform buffers", ts.Name.Name)
+				}
+				uss = append(uss, &UniformStruct{Type: ts.Name.Name, Struct: st})
+			}
+		}
+	}
+	sort.Slice(uss, func(i, j int) bool { return uss[i].Type < uss[j].Type })
+	return uss, nil
+}
+
+// roundUp16 returns n rounded up to a multiple of 16
+func roundUp16(n int) int {
+	return (n + 15) &^ 15
+}
+
+// UniformLayout returns the layout of the given type in a Uniform buffer.
+func UniformLayout(typ types.Type) (uniformLayout, error) {
+	switch ut := typ.Underlying().(type) {
+	case *types.Basic:
+		if hlslBasicType(ut) == "" {
+			return uniformLayout{}, fmt.Errorf("basic type must be a 32 bit type, not: %s", ut)
+		}
+		return uniformLayout{Align: 4, Size: 4}, nil
+	case *types.Array:
+		el, err := UniformLayout(ut.Elem())
+		if err != nil {
+			return el, err
+		}
+		return uniformLayout{Align: 16, Size: int(ut.Len()) * roundUp16(el.Size)}, nil
+	case *types.Struct:
+		if n := alignsl.VectorSize(ut); n > 0 {
+			return uniformLayout{Align: 4, Size: 4 * n, Vector: true}, nil
+		}
+		_, size, err := uniformOffsets(ut)
+		return uniformLayout{Align: 16, Size: size}, err
+	}
+	return uniformLayout{}, fmt.Errorf("unsupported type: %s", typ)
+}
+
+// uniformOffsets returns the offsets of the fields of the given struct
+// in a Uniform buffer, and its size.
+func uniformOffsets(st *types.Struct) ([]int, int, error) {
+	offs := make([]int, st.NumFields())
+	off := 0
+	for i := range st.NumFields() {
+		f := st.Field(i)
+		fl, err := UniformLayout(f.Type())
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		off = (off + fl.Align - 1) / fl.Align * fl.Align
+		if fl.Vector && off/16 != (off+fl.Size-1)/16 {
+			off = roundUp16(off)
+		}
+		offs[i] = off
+		off += fl.Size
+	}
+	return offs, roundUp16(off), nil
+}
+
+// Name returns the base name of the generated file, e.g., paramsuniform
+func (us *UniformStruct) Name() string {
+	return strings.ToLower(us.Type) + "uniform"
+}
+
+// Go returns the Go source with the mirror types, in given package,
+// for the type in the given types package.
+func (us *UniformStruct) Go(pkg *types.Package, pkgName string) ([]byte, error) {
+	imps := map[string]string{}
+	qual := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		imps[p.Path()] = p.Name()
+		return p.Name()
+	}
+	us.Mirrors = nil
+	if _, err := us.mirror(us.Type, us.Struct, qual); err != nil {
+		return nil, fmt.Errorf("gosl: %s is not valid in a Uniform buffer: %w", us.Type, err)
+	}
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	if len(imps) > 0 {
+		paths := make([]string, 0, len(imps))
+		for p := range imps {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		b.WriteString("import (\n")
+		for _, p := range paths {
+			fmt.Fprintf(&b, "\t%q\n", p)
+		}
+		b.WriteString(")\n\n")
+	}
+	for _, m := range us.Mirrors {
+		b.Write(m.Src.Bytes())
+	}
+	return format.Source(b.Bytes())
+}
+
+// mirror generates the mirror type of the given struct type if not
+// already generated, returning its name.
+func (us *UniformStruct) mirror(name string, st *types.Struct, qual types.Qualifier) (string, error) {
+	mnm := name + "Uniform"
+	for _, m := range us.Mirrors {
+		if m.Type == name {
+			return mnm, nil
+		}
+	}
+	offs, size, err := uniformOffsets(st)
+	if err != nil {
+		return "", err
+	}
+	m := &uniformMirror{Type: name, Name: mnm}
+	us.Mirrors = append(us.Mirrors, m)
+	var elems, flds, set, get bytes.Buffer
+	off, npad := 0, 0
+	pad := func(n int) {
+		if n > 0 {
+			fmt.Fprintf(&flds, "\tuniformPad%d [%d]uint32\n", npad, n/4)
+			npad++
+		}
+	}
+	for i := range st.NumFields() {
+		f := st.Field(i)
+		pad(offs[i] - off)
+		ft := types.TypeString(f.Type(), qual)
+		fl, _ := UniformLayout(f.Type())
+		switch ut := f.Type().Underlying().(type) {
+		case *types.Struct:
+			if alignsl.VectorSize(ut) > 0 {
+				fmt.Fprintf(&set, "\tu.%s = v.%s\n", f.Name(), f.Name())
+				fmt.Fprintf(&get, "\tv.%s = u.%s\n", f.Name(), f.Name())
+				break
+			}
+			nt, ok := f.Type().(*types.Named)
+			if !ok {
+				return "", fmt.Errorf("%s: struct fields must have a named type", f.Name())
+			}
+			if ft, err = us.mirror(nt.Obj().Name(), ut, qual); err != nil {
+				return "", fmt.Errorf("%s: %w", f.Name(), err)
+			}
+			fmt.Fprintf(&set, "\tu.%s.Set(&v.%s)\n", f.Name(), f.Name())
+			fmt.Fprintf(&get, "\tu.%s.Get(&v.%s)\n", f.Name(), f.Name())
+		case *types.Array:
+			el, _ := UniformLayout(ut.Elem())
+			et := types.TypeString(ut.Elem(), qual)
+			est, isStruct := ut.Elem().Underlying().(*types.Struct)
+			isStruct = isStruct && alignsl.VectorSize(est) == 0
+			if isStruct {
+				nt, ok := ut.Elem().(*types.Named)
+				if !ok {
+					return "", fmt.Errorf("%s: struct elements must have a named type", f.Name())
+				}
+				if et, err = us.mirror(nt.Obj().Name(), est, qual); err != nil {
+					return "", fmt.Errorf("%s: %w", f.Name(), err)
+				}
+			}
+			ev := ""
+			if roundUp16(el.Size) != el.Size {
+				// element type padded to the 16 byte array stride
+				wt := mnm + f.Name() + "Elem"
+				fmt.Fprintf(&elems, "// %s is an element of %s.%s,\n// padded to the 16 byte array stride of a Uniform buffer\n", wt, mnm, f.Name())
+				fmt.Fprintf(&elems, "type %s struct {\n\tV %s\n\n\tpad [%d]uint32\n}\n\n", wt, et, (roundUp16(el.Size)-el.Size)/4)
+				et, ev = wt, ".V"
+			}
+			ft = fmt.Sprintf("[%d]%s", ut.Len(), et)
+			if isStruct {
+				fmt.Fprintf(&set, "\tfor i := range v.%s {\n\t\tu.%s[i]%s.Set(&v.%s[i])\n\t}\n", f.Name(), f.Name(), ev, f.Name())
+				fmt.Fprintf(&get, "\tfor i := range v.%s {\n\t\tu.%s[i]%s.Get(&v.%s[i])\n\t}\n", f.Name(), f.Name(), ev, f.Name())
+			} else if ev != "" {
+				fmt.Fprintf(&set, "\tfor i := range v.%s {\n\t\tu.%s[i].V = v.%s[i]\n\t}\n", f.Name(), f.Name(), f.Name())
+				fmt.Fprintf(&get, "\tfor i := range v.%s {\n\t\tv.%s[i] = u.%s[i].V\n\t}\n", f.Name(), f.Name(), f.Name())
+			} else {
+				fmt.Fprintf(&set, "\tu.%s = v.%s\n", f.Name(), f.Name())
+				fmt.Fprintf(&get, "\tv.%s = u.%s\n", f.Name(), f.Name())
+			}
+		default:
+			fmt.Fprintf(&set, "\tu.%s = v.%s\n", f.Name(), f.Name())
+			fmt.Fprintf(&get, "\tv.%s = u.%s\n", f.Name(), f.Name())
+		}
+		fmt.Fprintf(&flds, "\t%s %s\n", f.Name(), ft)
+		off = offs[i] + fl.Size
+	}
+	pad(size - off)
+	src := &m.Src
+	fmt.Fprintf(src, "// %s is %s with the layout of a Uniform buffer\ntype %s struct {\n", mnm, name, mnm)
+	src.Write(flds.Bytes())
+	src.WriteString("}\n\n")
+	fmt.Fprintf(src, "// Set sets the fields from the given %s\nfunc (u *%s) Set(v *%s) {\n", name, mnm, name)
+	src.Write(set.Bytes())
+	src.WriteString("}\n\n")
+	fmt.Fprintf(src, "// Get sets the fields of the given %s\nfunc (u *%s) Get(v *%s) {\n", name, mnm, name)
+	src.Write(get.Bytes())
+	src.WriteString("}\n\n")
+	src.Write(elems.Bytes())
+	return mnm, nil
+}
+
+// GenUniformStructs generates the mirror types for all of the struct
+// types in the given package declared for use in Uniform buffers:
+// <type>uniform.go in the current directory.
+func GenUniformStructs(pkg *packages.Package) error {
+	uss, err := FindUniformStructs(pkg)
+	if err != nil {
+		return err
+	}
+	for _, us := range uss {
+		gofn := us.Name() + ".go"
+		pnm, _ := DocPackageName(gofn)
+		src, err := us.Go(pkg.Types, pnm)
+		if err != nil {
+			return err
+		}
+		if err := WriteGenerated(gofn, us.Name(), src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUniformStructs(t *testing.T) {
+	src := "package main\n\ntype Vec3 struct {\n\tX, Y, Z float32\n}\n\ntype Layer struct {\n\tGain, Bias float32\n\tPos Vec3\n}\n\n// gosl: uniform\ntype Params struct {\n\tN uint32\n\tTaus [3]float32\n\tDt float32\n\tLayers [2]Layer\n\tOff float32\n}\n\ntype Data struct {\n\tX float32\n}\n"
+	pkg := testPackage(t, "params.go", src)
+	uss, err := FindUniformStructs(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uss) != 1 || uss[0].Type != "Params" {
+		t.Fatalf("wrong uniform structs: %+v", uss)
+	}
+	offs, size, err := uniformOffsets(uss[0].Struct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Taus at 16 with 16 byte stride, Layers at 80 with 32 byte stride
+	if want := []int{0, 16, 64, 80, 144}; !equalInts(offs, want) || size != 160 {
+		t.Errorf("offsets: %v size: %d, want: %v 160", offs, size, want)
+	}
+	gsrc, err := uss[0].Go(pkg.Types, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Taus        [3]ParamsUniformTausElem", "Layers      [2]LayerUniform", "u.Layers[i].Set(&v.Layers[i])", "v.Taus[i] = u.Taus[i].V", "type LayerUniform struct {\n\tGain        float32\n\tBias        float32\n\tuniformPad0 [2]uint32\n\tPos         Vec3\n\tuniformPad1 [1]uint32"} {
+		if !strings.Contains(string(gsrc), want) {
+			t.Errorf("missing %q in:\n%s", want, gsrc)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
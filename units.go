@@ -0,0 +1,189 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// UnitTypes are the defined numeric types that are declared as units
+// of measure with a directive in the doc comment of the type, e.g.,
+// for normalized vs. biological membrane potential:
+//
+//	//gosl: unit
+//	type VmNorm float32
+//
+//	//gosl: unit
+//	type VmBio float32
+//
+// In HLSL, these are typedefs of the basic type, so the GPU compiler
+// does not catch mixing values of different units, and gosl checks the
+// Go code instead, at generation time, by CheckUnits.
+func UnitTypes(pkg *packages.Package) (map[*types.TypeName]bool, error) {
+	uts := map[*types.TypeName]bool{}
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, s := range gd.Specs {
+				ts, ok := s.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if !isUnitDirective(doc) {
+					continue
+				}
+				tn, ok := pkg.Types.Scope().Lookup(ts.Name.Name).(*types.TypeName)
+				if !ok {
+					continue
+				}
+				if bt, ok := tn.Type().Underlying().(*types.Basic); !ok || bt.Info()&types.IsNumeric == 0 || tn.IsAlias() {
+					return nil, fmt.Errorf("gosl: %s: only defined numeric types can be declared as units, e.g., type VmNorm float32", ts.Name.Name)
+				}
+				uts[tn] = true
+			}
+		}
+	}
+	return uts, nil
+}
+
+// isUnitDirective returns true if the given doc comment has
+// the //gosl: unit directive, accepting the // gosl: form that
+// gofmt produces for doc comments.
+func isUnitDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(strings.TrimPrefix(c.Text, "//")) == "gosl: unit" {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckUnits returns an error for each mixing of the UnitTypes in the
+// Go code of the given package:
+//   - type errors involving a unit type, e.g., adding a VmBio to a VmNorm
+//     or assigning one to the other, which are otherwise ignored at
+//     generation time, as the regions are checked without the rest of
+//     the package.
+//   - conversions from one unit type to another, e.g., VmBio(vm), also
+//     via a basic type, e.g., VmBio(float32(vm)), which Go allows, except
+//     in functions that take the one and return the other, which are
+//     where the conversion between the units is defined, e.g.,
+//     func VmToBio(vm VmNorm) VmBio.
+func CheckUnits(pkg *packages.Package) error {
+	uts, err := UnitTypes(pkg)
+	if err != nil || len(uts) == 0 {
+		return err
+	}
+	var names []string
+	for tn := range uts {
+		names = append(names, regexp.QuoteMeta(tn.Name()))
+	}
+	sort.Strings(names)
+	unitRe := regexp.MustCompile(`\b(` + strings.Join(names, "|") + `)\b`)
+	var errs []error
+	for _, e := range pkg.Errors {
+		if e.Kind == packages.TypeError && unitRe.MatchString(e.Msg) {
+			errs = append(errs, fmt.Errorf("%s: gosl unit error: %s", filepath.Base(e.Pos), e.Msg))
+		}
+	}
+
+	unitOf := func(ex ast.Expr) *types.TypeName {
+		if nt, ok := pkg.TypesInfo.TypeOf(ex).(*types.Named); ok && uts[nt.Obj()] {
+			return nt.Obj()
+		}
+		return nil
+	}
+	// conversion returns the target type and argument of a conversion
+	conversion := func(ex ast.Expr) (types.Type, ast.Expr) {
+		ce, ok := ast.Unparen(ex).(*ast.CallExpr)
+		if !ok || len(ce.Args) != 1 {
+			return nil, nil
+		}
+		if tv, ok := pkg.TypesInfo.Types[ce.Fun]; ok && tv.IsType() {
+			return tv.Type, ce.Args[0]
+		}
+		return nil, nil
+	}
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Body == nil {
+				continue
+			}
+			// unit types taken and returned by the function
+			takes := map[*types.TypeName]bool{}
+			returns := map[*types.TypeName]bool{}
+			if fn, ok := pkg.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+				sig := fn.Type().(*types.Signature)
+				addUnits := func(m map[*types.TypeName]bool, tp types.Type) {
+					if pt, ok := tp.(*types.Pointer); ok {
+						tp = pt.Elem()
+					}
+					if nt, ok := tp.(*types.Named); ok && uts[nt.Obj()] {
+						m[nt.Obj()] = true
+					}
+				}
+				if sig.Recv() != nil {
+					addUnits(takes, sig.Recv().Type())
+				}
+				for i := range sig.Params().Len() {
+					addUnits(takes, sig.Params().At(i).Type())
+				}
+				for i := range sig.Results().Len() {
+					addUnits(returns, sig.Results().At(i).Type())
+				}
+			}
+			ast.Inspect(fd.Body, func(n ast.Node) bool {
+				ex, ok := n.(ast.Expr)
+				if !ok {
+					return true
+				}
+				to, arg := conversion(ex)
+				if to == nil {
+					return true
+				}
+				nt, ok := to.(*types.Named)
+				if !ok || !uts[nt.Obj()] {
+					return true
+				}
+				for arg != nil {
+					if from := unitOf(arg); from != nil {
+						if from != nt.Obj() && !(takes[from] && returns[nt.Obj()]) {
+							pos := pkg.Fset.Position(ex.Pos())
+							errs = append(errs, fmt.Errorf("%s:%d:%d: gosl unit error: conversion from %s to %s outside of a function taking %s and returning %s: %s", filepath.Base(pos.Filename), pos.Line, pos.Column, from.Name(), nt.Obj().Name(), from.Name(), nt.Obj().Name(), types.ExprString(ex)))
+						}
+						break
+					}
+					bto, barg := conversion(arg)
+					if _, isBasic := bto.(*types.Basic); !isBasic {
+						break
+					}
+					arg = barg
+				}
+				return true
+			})
+		}
+	}
+	return errors.Join(errs...)
+}
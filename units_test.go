@@ -0,0 +1,50 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckUnits(t *testing.T) {
+	src := `package main
+
+// gosl: unit
+type VmNorm float32
+
+// gosl: unit
+type VmBio float32
+
+type Flags int32
+
+func VmToBio(vm VmNorm) VmBio {
+	return VmBio(100*vm - 100)
+}
+
+type Neuron struct {
+	Vm  VmNorm
+	Thr VmBio
+	Fl  Flags
+}
+
+func (nrn *Neuron) Spiked() bool {
+	return VmToBio(nrn.Vm) > nrn.Thr && nrn.Fl == Flags(int32(nrn.Thr))
+}
+
+func (nrn *Neuron) Bad() bool {
+	bio := VmBio(float32(nrn.Vm))
+	return nrn.Vm > nrn.Thr || bio > 0
+}
+`
+	err := CheckUnits(testPackageErrors(t, "vm.go", src))
+	if err == nil {
+		t.Fatal("expected unit errors")
+	}
+	errs := strings.Split(err.Error(), "\n")
+	if len(errs) != 2 || !strings.Contains(errs[0], "vm.go:27:18: gosl unit error: invalid operation: nrn.Vm > nrn.Thr (mismatched types VmNorm and VmBio)") || !strings.Contains(errs[1], "vm.go:26:9: gosl unit error: conversion from VmNorm to VmBio") {
+		t.Errorf("wrong errors:\n%s", err)
+	}
+}
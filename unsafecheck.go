@@ -0,0 +1,160 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// CheckUnsafe checks that the given source file does not use the unsafe
+// package within the given //gosl: start regions, as line ranges
+// starting at 1, except in the given CPUOnlyBlocks.  Pointer arithmetic
+// with unsafe, e.g., in a VarByIndex method, only works on the CPU, and
+// must be outside of the regions.
+func CheckUnsafe(fn string, src []byte, regions [][2]int, cpuBlocks []CPUOnlyBlock) error {
+	if !bytes.Contains(src, []byte(`"unsafe"`)) || len(regions) == 0 {
+		return nil
+	}
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, fn, src, parser.ImportsOnly)
+	if err != nil {
+		return nil // reported elsewhere
+	}
+	name := ""
+	for _, is := range af.Imports {
+		if is.Path.Value == `"unsafe"` {
+			name = "unsafe"
+			if is.Name != nil {
+				name = is.Name.Name
+			}
+		}
+	}
+	if name == "" || name == "_" {
+		return nil
+	}
+	af, err = parser.ParseFile(fset, fn, src, 0)
+	if err != nil {
+		return nil
+	}
+	cpus := make([][2]int, len(cpuBlocks))
+	for i, bl := range cpuBlocks {
+		cpus[i] = [2]int{bl.Start, bl.End}
+	}
+	var errs []error
+	ast.Inspect(af, func(n ast.Node) bool {
+		var what string
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			if id, ok := x.X.(*ast.Ident); ok && id.Name == name && id.Obj == nil {
+				what = "unsafe." + x.Sel.Name
+			}
+		case *ast.Ident:
+			if name == "." && x.Obj == nil && (x.Name == "Pointer" || x.Name == "Sizeof" || x.Name == "Offsetof" || x.Name == "Alignof" || x.Name == "Add" || x.Name == "Slice") {
+				what = "unsafe." + x.Name
+			}
+		}
+		if what == "" {
+			return true
+		}
+		if pos := fset.Position(n.Pos()); withinLines(pos.Line, regions) && !withinLines(pos.Line, cpus) {
+			errs = append(errs, fmt.Errorf("%s: %s is not supported on the GPU: move the code out of the //gosl: start region, or into a //gosl: cpuonly block", pos, what))
+		}
+		return false
+	})
+	return errors.Join(errs...)
+}
+
+// CheckVarStarts checks the <Type>VarStart constants in the given Go
+// files, e.g., NeuronVarStart, for the struct types in the regions of
+// the given package, which are used to access the fields of the type
+// by index with unsafe pointer arithmetic on the CPU, e.g., in a
+// VarByIndex method, outside of the regions.  The fields before the
+// VarStart index must be 32 bit, and all of the fields from it on
+// must be float32, so that each field is 4 bytes after the previous.
+func CheckVarStarts(pkg *packages.Package, files []string) error {
+	var errs []error
+	for _, fn := range files {
+		if !strings.HasSuffix(fn, ".go") {
+			continue
+		}
+		src, err := os.ReadFile(fn)
+		if err != nil || !bytes.Contains(src, []byte("VarStart")) {
+			continue
+		}
+		fset := token.NewFileSet()
+		af, err := parser.ParseFile(fset, fn, src, 0)
+		if err != nil {
+			continue
+		}
+		for _, d := range af.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.CONST {
+				continue
+			}
+			for _, s := range gd.Specs {
+				vs := s.(*ast.ValueSpec)
+				for i, id := range vs.Names {
+					if !strings.HasSuffix(id.Name, "VarStart") || i >= len(vs.Values) {
+						continue
+					}
+					tn := strings.TrimSuffix(id.Name, "VarStart")
+					obj, ok := pkg.Types.Scope().Lookup(tn).(*types.TypeName)
+					if !ok {
+						continue
+					}
+					st, ok := obj.Type().Underlying().(*types.Struct)
+					if !ok {
+						continue
+					}
+					pos := fset.Position(id.Pos())
+					start := -1
+					if bl, ok := vs.Values[i].(*ast.BasicLit); ok && bl.Kind == token.INT {
+						start, _ = strconv.Atoi(bl.Value)
+					}
+					if start < 0 {
+						errs = append(errs, fmt.Errorf("%s: %s must be an integer literal, to be checked against the fields of %s", pos, id.Name, tn))
+						continue
+					}
+					if err := checkVarStart(pkg, st, start); err != nil {
+						errs = append(errs, fmt.Errorf("%s: %s = %d: %w", pos, id.Name, start, err))
+					}
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkVarStart checks the fields of the given struct for given VarStart
+func checkVarStart(pkg *packages.Package, st *types.Struct, start int) error {
+	if start < 0 || start > st.NumFields() {
+		return fmt.Errorf("out of range of the %d fields", st.NumFields())
+	}
+	flds := make([]*types.Var, st.NumFields())
+	for i := range flds {
+		flds[i] = st.Field(i)
+	}
+	offs := pkg.TypesSizes.Offsetsof(flds)
+	for i, f := range flds {
+		if bt, ok := f.Type().Underlying().(*types.Basic); i >= start && (!ok || bt.Kind() != types.Float32) {
+			return fmt.Errorf("field %s is a %s: all fields from the VarStart index on must be float32", f.Name(), f.Type())
+		}
+		if offs[i] != int64(4*i) || pkg.TypesSizes.Sizeof(f.Type()) != 4 {
+			return fmt.Errorf("field %s is a %s: all fields before the VarStart index must be 32 bit", f.Name(), f.Type())
+		}
+	}
+	return nil
+}
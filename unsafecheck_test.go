@@ -0,0 +1,63 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const unsafeTestSrc = `package main
+
+import "unsafe"
+
+//gosl: start neuron
+
+type Neuron struct {
+	Flags   int32
+	LayIndex uint32
+	Act     float32
+	Ge      float32
+}
+
+func (nrn *Neuron) Ptr() uintptr {
+	//gosl: cpuonly
+	_ = unsafe.Sizeof(*nrn)
+	//gosl: end cpuonly
+	return uintptr(unsafe.Pointer(nrn))
+}
+
+//gosl: end neuron
+
+const NeuronVarStart = 2
+
+func (nrn *Neuron) VarByIndex(idx int) float32 {
+	return *(*float32)(unsafe.Pointer(uintptr(unsafe.Pointer(nrn)) + uintptr(NeuronVarStart*4+4*idx)))
+}
+`
+
+func TestCheckUnsafe(t *testing.T) {
+	err := CheckUnsafe("neuron.go", []byte(unsafeTestSrc), [][2]int{{5, 21}}, []CPUOnlyBlock{{Start: 15, End: 17}})
+	if err == nil || strings.Count(err.Error(), "\n") != 0 || !strings.Contains(err.Error(), "neuron.go:18:17: unsafe.Pointer is not supported on the GPU") {
+		t.Errorf("wrong error, want only the one in the region: %v", err)
+	}
+}
+
+func TestCheckVarStarts(t *testing.T) {
+	pkg := testPackage(t, "neuron.go", unsafeTestSrc)
+	fn := filepath.Join(t.TempDir(), "neuron.go")
+	for start, want := range map[int]string{2: "", 1: "field LayIndex is a uint32: all fields from the VarStart index on must be float32", 5: "out of range"} {
+		src := strings.Replace(unsafeTestSrc, "NeuronVarStart = 2", "NeuronVarStart = "+string(rune('0'+start)), 1)
+		if err := os.WriteFile(fn, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := CheckVarStarts(pkg, []string{fn})
+		if (err == nil) != (want == "") || (err != nil && !strings.Contains(err.Error(), want)) {
+			t.Errorf("NeuronVarStart = %d: got error %v, want %q", start, err, want)
+		}
+	}
+}
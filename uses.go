@@ -0,0 +1,90 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RegionUses are the other regions used by each region, in order,
+// as declared with directives within the region:
+//
+//	//gosl: uses chans kinase
+//
+// Each used region is included at the start of the output file of the
+// region, e.g., #include "chans.hlsl", so that a region can use the
+// types and functions of other regions without all being in the same
+// region, and the include guards of the output files ensure that each
+// region is only included once.
+var RegionUses = map[string][]string{}
+
+// AddRegionUses adds the regions in given uses directive arguments
+// to the list for given region, if not already present.
+func AddRegionUses(slFn, args string) {
+	for _, rg := range strings.Fields(args) {
+		if rg != slFn && !slices.Contains(RegionUses[slFn], rg) {
+			RegionUses[slFn] = append(RegionUses[slFn], rg)
+		}
+	}
+}
+
+// CheckRegionUses returns an error if any of the regions used by the
+// given regions do not exist, or if any regions use each other in a cycle.
+func CheckRegionUses(regions map[string][]byte) error {
+	nms := make([]string, 0, len(RegionUses))
+	for fn := range RegionUses {
+		nms = append(nms, fn)
+	}
+	sort.Strings(nms)
+	var errs []string
+	for _, fn := range nms {
+		for _, rg := range RegionUses[fn] {
+			if _, has := regions[rg]; !has {
+				errs = append(errs, fmt.Sprintf("gosl: region %s uses region %s, which is not defined in the files being processed", fn, rg))
+			}
+		}
+	}
+	state := map[string]int{} // 1 = visiting, 2 = done
+	var visit func(fn string, path []string)
+	visit = func(fn string, path []string) {
+		switch state[fn] {
+		case 1:
+			i := slices.Index(path, fn)
+			errs = append(errs, fmt.Sprintf("gosl: regions use each other in a cycle: %s", strings.Join(append(path[i:], fn), " -> ")))
+			return
+		case 2:
+			return
+		}
+		state[fn] = 1
+		for _, rg := range RegionUses[fn] {
+			visit(rg, append(path, fn))
+		}
+		state[fn] = 2
+	}
+	for _, fn := range nms {
+		visit(fn, nil)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// UsesIncludes returns the #include lines for the regions used by the
+// given region, for the start of its output file.
+func UsesIncludes(fn string) []byte {
+	var b bytes.Buffer
+	for _, rg := range RegionUses[fn] {
+		fmt.Fprintf(&b, "#include \"%s%s\"\n", rg, ShaderExt())
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegionUses(t *testing.T) {
+	defer clear(RegionUses)
+	AddRegionUses("axon", " chans  kinase")
+	AddRegionUses("axon", "chans axon")
+	AddRegionUses("kinase", "chans")
+	if got := strings.Join(RegionUses["axon"], ","); got != "chans,kinase" {
+		t.Errorf("uses: %s", got)
+	}
+	regions := map[string][]byte{"axon": nil, "chans": nil, "kinase": nil}
+	if err := CheckRegionUses(regions); err != nil {
+		t.Error(err)
+	}
+	if got := string(UsesIncludes("axon")); got != "#include \"chans.hlsl\"\n#include \"kinase.hlsl\"\n\n" {
+		t.Errorf("includes: %q", got)
+	}
+	AddRegionUses("chans", "axon learn")
+	err := CheckRegionUses(regions)
+	if err == nil || !strings.Contains(err.Error(), "region learn") || !strings.Contains(err.Error(), "axon -> chans -> axon") {
+		t.Errorf("expected missing region and cycle errors, got: %v", err)
+	}
+}
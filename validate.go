@@ -0,0 +1,361 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/emer/gosl/v2/alignsl"
+	"golang.org/x/tools/go/packages"
+)
+
+// BoundsStruct is a struct type with fields that have min and / or max
+// struct tags, e.g., `min:"1" max:"10"`, directly or in nested struct
+// types, for which -validate generates a Go Validate method, and an HLSL
+// Clamp function that is called on the buffer elements of that type in
+// a prologue of each kernel, for debug builds.
+type BoundsStruct struct {
+
+	// name of the struct type, e.g., Params
+	Type string
+
+	// fields with bounds, or of nested struct types with bounds
+	Fields []*FieldBounds
+}
+
+// FieldBounds are the bounds of a field of a BoundsStruct
+type FieldBounds struct {
+
+	// field name
+	Name string
+
+	// min bound, as a literal, if HasMin
+	Min string
+
+	// max bound, as a literal, if HasMax
+	Max string
+
+	HasMin, HasMax bool
+
+	// whether the field is a float32, for the HLSL literals
+	Float bool
+
+	// name of the struct type of the field, if it is a nested BoundsStruct
+	Struct string
+}
+
+// boundsValue parses the value of the given bounds tag key for a field
+// of the given basic type, returning the literal and value.
+func boundsValue(tag reflect.StructTag, key string, bt *types.Basic) (string, float64, bool, error) {
+	s, has := tag.Lookup(key)
+	if !has {
+		return "", 0, false, nil
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return "", 0, true, fmt.Errorf("%s tag is not a number: %q", key, s)
+	}
+	switch bt.Kind() {
+	case types.Float32:
+		return strconv.FormatFloat(v, 'g', -1, 32), v, true, nil
+	case types.Int32:
+		if v != float64(int32(v)) {
+			return "", 0, true, fmt.Errorf("%s tag is not an int32: %q", key, s)
+		}
+	case types.Uint32:
+		if v != float64(uint32(v)) {
+			return "", 0, true, fmt.Errorf("%s tag is not a uint32: %q", key, s)
+		}
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64), v, true, nil
+}
+
+// FindBoundsStructs returns the struct types in the given package with
+// min / max tags on any of their fields, or those of nested struct types,
+// in dependency order (nested types first, and otherwise by name).
+// It returns an error for invalid bounds, and numeric default tag values
+// (e.g., `default:"2,5"`) that are outside of the bounds.
+func FindBoundsStructs(pkg *packages.Package) ([]*BoundsStruct, error) {
+	all := map[string]*BoundsStruct{}
+	var order []*BoundsStruct
+	var errs []error
+	var find func(nm string, st *types.Struct) *BoundsStruct
+	find = func(nm string, st *types.Struct) *BoundsStruct {
+		if bs, has := all[nm]; has {
+			return bs
+		}
+		all[nm] = nil // in progress
+		bs := &BoundsStruct{Type: nm}
+		for _, f := range alignsl.GPUFields(st) {
+			var tag reflect.StructTag
+			for i := range st.NumFields() {
+				if st.Field(i) == f {
+					tag = reflect.StructTag(st.Tag(i))
+				}
+			}
+			switch ut := f.Type().Underlying().(type) {
+			case *types.Struct:
+				nt, ok := f.Type().(*types.Named)
+				if !ok || alignsl.VectorSize(ut) > 0 {
+					continue
+				}
+				if fbs := find(nt.Obj().Name(), ut); fbs != nil {
+					bs.Fields = append(bs.Fields, &FieldBounds{Name: f.Name(), Struct: fbs.Type})
+				}
+			case *types.Basic:
+				fb := &FieldBounds{Name: f.Name(), Float: ut.Kind() == types.Float32}
+				var mn, mx float64
+				var err error
+				if fb.Min, mn, fb.HasMin, err = boundsValue(tag, "min", ut); err == nil {
+					fb.Max, mx, fb.HasMax, err = boundsValue(tag, "max", ut)
+				}
+				if err == nil && (fb.HasMin || fb.HasMax) && hlslBasicType(ut) == "" {
+					err = fmt.Errorf("bounds tags require a 32 bit basic type, not: %s", ut)
+				}
+				if err == nil && fb.HasMin && fb.HasMax && mn > mx {
+					err = fmt.Errorf("min: %s > max: %s", fb.Min, fb.Max)
+				}
+				if err == nil && ut.Kind() == types.Uint32 && fb.HasMin && mn == 0 {
+					fb.HasMin = false // always true
+				}
+				if def, has := tag.Lookup("default"); has && err == nil && (fb.HasMin || fb.HasMax) {
+					for _, ds := range strings.Split(def, ",") {
+						dv, derr := strconv.ParseFloat(strings.TrimSpace(ds), 64)
+						if derr == nil && ((fb.HasMin && dv < mn) || (fb.HasMax && dv > mx)) {
+							err = fmt.Errorf("default: %s is outside of the bounds", strings.TrimSpace(ds))
+						}
+					}
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("gosl: %s.%s: %w", nm, f.Name(), err))
+					continue
+				}
+				if fb.HasMin || fb.HasMax {
+					bs.Fields = append(bs.Fields, fb)
+				}
+			}
+		}
+		if len(bs.Fields) == 0 {
+			return nil
+		}
+		all[nm] = bs
+		order = append(order, bs)
+		return bs
+	}
+	scope := pkg.Types.Scope()
+	for _, nm := range scope.Names() {
+		tn, ok := scope.Lookup(nm).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if st, ok := tn.Type().Underlying().(*types.Struct); ok {
+			find(nm, st)
+		}
+	}
+	return order, errors.Join(errs...)
+}
+
+// HLSL returns the HLSL Clamp function for the struct type, which clamps
+// the fields to their bounds, e.g., ClampParams(Params[0]);
+func (bs *BoundsStruct) HLSL() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Clamp%s clamps the fields of %s to the bounds of their min / max tags (-validate)\n", bs.Type, bs.Type)
+	fmt.Fprintf(&b, "void Clamp%s(inout %s v) {\n", bs.Type, bs.Type)
+	for _, f := range bs.Fields {
+		mn, mx := f.Min, f.Max
+		if f.Float {
+			mn, mx = hlslFloat(mn), hlslFloat(mx)
+		}
+		switch {
+		case f.Struct != "":
+			fmt.Fprintf(&b, "\tClamp%s(v.%s);\n", f.Struct, f.Name)
+		case f.HasMin && f.HasMax:
+			fmt.Fprintf(&b, "\tv.%s = clamp(v.%s, %s, %s);\n", f.Name, f.Name, mn, mx)
+		case f.HasMin:
+			fmt.Fprintf(&b, "\tv.%s = max(v.%s, %s);\n", f.Name, f.Name, mn)
+		default:
+			fmt.Fprintf(&b, "\tv.%s = min(v.%s, %s);\n", f.Name, f.Name, mx)
+		}
+	}
+	b.WriteString("}\n\n")
+	return b.String()
+}
+
+// hlslFloat returns the given float literal with a decimal point,
+// e.g., 1.0, so that it is a float in HLSL
+func hlslFloat(lit string) string {
+	if lit == "" || strings.ContainsAny(lit, ".eEnN") {
+		return lit
+	}
+	return lit + ".0"
+}
+
+// Go returns the Go Validate method for the struct type, where nested
+// types only have their Validate method called if in the locals.
+func (bs *BoundsStruct) Go(locals map[string]bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Validate returns an error if any fields of %s are outside of\n// the bounds of their min / max tags\n", bs.Type)
+	fmt.Fprintf(&b, "func (v *%s) Validate() error {\n\tvar errs []error\n", bs.Type)
+	for _, f := range bs.Fields {
+		if f.Struct != "" {
+			if locals[f.Struct] {
+				fmt.Fprintf(&b, "\tif err := v.%s.Validate(); err != nil {\n\t\terrs = append(errs, fmt.Errorf(\"%s.%s: %%w\", err))\n\t}\n", f.Name, bs.Type, f.Name)
+			}
+			continue
+		}
+		if f.HasMin {
+			fmt.Fprintf(&b, "\tif v.%s < %s {\n\t\terrs = append(errs, fmt.Errorf(\"%s.%s: %%v < min: %s\", v.%s))\n\t}\n", f.Name, f.Min, bs.Type, f.Name, f.Min, f.Name)
+		}
+		if f.HasMax {
+			fmt.Fprintf(&b, "\tif v.%s > %s {\n\t\terrs = append(errs, fmt.Errorf(\"%s.%s: %%v > max: %s\", v.%s))\n\t}\n", f.Name, f.Max, bs.Type, f.Name, f.Max, f.Name)
+		}
+	}
+	b.WriteString("\treturn errors.Join(errs...)\n}\n\n")
+	return b.String()
+}
+
+// LocalTypes returns the names of the types declared in the non-test
+// Go files in the directory of the given file, other than the file.
+func LocalTypes(path string) map[string]bool {
+	dir, fn := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	nms := map[string]bool{}
+	fls, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, gf := range fls {
+		if filepath.Base(gf) == fn || IsTestFile(gf) {
+			continue
+		}
+		af, err := parser.ParseFile(token.NewFileSet(), gf, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, d := range af.Decls {
+			if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, s := range gd.Specs {
+					nms[s.(*ast.TypeSpec).Name.Name] = true
+				}
+			}
+		}
+	}
+	return nms
+}
+
+// GenValidate generates the Validate methods for the BoundsStructs
+// declared in the package of the given Go file, written to the file.
+func GenValidate(path string, bss []*BoundsStruct) error {
+	pnm, _ := DocPackageName(path)
+	locals := LocalTypes(path)
+	var body strings.Builder
+	for _, bs := range bss {
+		if locals[bs.Type] {
+			body.WriteString(bs.Go(locals))
+		}
+	}
+	if body.Len() == 0 {
+		return fmt.Errorf("gosl: -validate: no struct types with min / max field tags are declared in the package of: %s", path)
+	}
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pnm)
+	if strings.Contains(body.String(), "fmt.") {
+		b.WriteString("import (\n\t\"errors\"\n\t\"fmt\"\n)\n\n")
+	} else {
+		b.WriteString("import \"errors\"\n\n")
+	}
+	b.WriteString(body.String())
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	return WriteGenerated(path, "validate", src)
+}
+
+// ValidateKernel returns the source of the given kernel with the Clamp
+// functions of the given BoundsStructs, and a prologue at the start of
+// the entry point function (after any -boundscheck prologue) that clamps
+// each element of a writable buffer of one of these types that the kernel
+// indexes with a constant, or the x thread index if bounds checked:
+//
+//	ClampParams(Params[0]);
+//
+// The elements indexed by a constant are clamped by every thread, to the
+// same values, which is only for debug builds.  It returns false if the
+// kernel does not have any such elements.
+func ValidateKernel(k *Kernel, src []byte, bss []*BoundsStruct) ([]byte, bool) {
+	bsm := map[string]*BoundsStruct{}
+	for _, bs := range bss {
+		bsm[bs.Type] = bs
+	}
+	code := StripHLSLComments(src)
+	var pro strings.Builder
+	for _, b := range k.Buffers {
+		if bsm[b.Type] == nil {
+			continue
+		}
+		if !strings.HasPrefix(b.Kind, "RW") {
+			fmt.Printf("gosl: -validate: kernel %s: %s is read-only, so its %s values are not clamped\n", k.Name, b.Name, b.Type)
+			continue
+		}
+		done := map[string]bool{}
+		for _, u := range BufferUses(code, b.Name) {
+			if done[u.Index] {
+				continue
+			}
+			if _, err := strconv.Atoi(u.Index); err != nil && !(k.Bounds != "" && u.Index == k.Index+".x") {
+				continue
+			}
+			done[u.Index] = true
+			fmt.Fprintf(&pro, "\tClamp%s(%s[%s]);\n", b.Type, b.Name, u.Index)
+		}
+	}
+	if pro.Len() == 0 {
+		return src, false
+	}
+	loc := regexp.MustCompile(`\bvoid\s+` + regexp.QuoteMeta(k.Entry) + `\s*\([^)]*SV_DispatchThreadID[^)]*\)\s*\{`).FindIndex(src)
+	if loc == nil {
+		return src, false
+	}
+	st := loc[0] // before any attributes, e.g., [numthreads(64, 1, 1)]
+	for st > 0 {
+		ls := bytes.LastIndexByte(src[:st-1], '\n') + 1
+		if !bytes.HasPrefix(bytes.TrimSpace(src[ls:st]), []byte("[")) {
+			break
+		}
+		st = ls
+	}
+	at := loc[1]
+	if k.Bounds != "" {
+		if i := bytes.Index(src[at:], []byte("return;\n\t}\n")); i >= 0 {
+			at += i + len("return;\n\t}\n")
+		}
+	}
+	var fns strings.Builder
+	for _, bs := range bss {
+		fns.WriteString(bs.HLSL())
+	}
+	out := make([]byte, 0, len(src)+fns.Len()+pro.Len())
+	out = append(out, src[:st]...)
+	out = append(out, fns.String()...)
+	out = append(out, src[st:at]...)
+	if k.Bounds == "" {
+		out = append(out, '\n')
+	}
+	out = append(out, pro.String()...)
+	return append(out, src[at:]...), true
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkBounds(t *testing.T, src string) ([]*BoundsStruct, error) {
+	t.Helper()
+	return FindBoundsStructs(testPackage(t, "params.go", src))
+}
+
+func TestValidate(t *testing.T) {
+	src := "package main\n\ntype Params struct {\n\tGain float32 `min:\"0.5\" max:\"10\" default:\"2,5\"`\n\tOff float32\n\tSub Sub\n}\n\ntype Sub struct {\n\tN uint32 `min:\"0\" max:\"8\"`\n}\n\ntype Data struct {\n\tX, Y float32\n}\n"
+	bss, err := checkBounds(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bss) != 2 || bss[0].Type != "Sub" || bss[1].Type != "Params" {
+		t.Fatalf("wrong bounds structs: %+v", bss)
+	}
+	gsrc := bss[1].Go(map[string]bool{"Params": true, "Sub": true})
+	for _, want := range []string{"if v.Gain < 0.5 {", "Params.Gain: %v > max: 10", "v.Sub.Validate()"} {
+		if !strings.Contains(gsrc, want) {
+			t.Errorf("missing %q in:\n%s", want, gsrc)
+		}
+	}
+	if hsrc := bss[0].HLSL(); !strings.Contains(hsrc, "v.N = min(v.N, 8);") {
+		t.Errorf("uint min of 0 not dropped in:\n%s", hsrc)
+	}
+
+	k := &Kernel{Name: "p", Entry: "main", Index: "idx", Buffers: []*Buffer{{Name: "Params", Kind: "RWStructuredBuffer", Type: "Params"}}}
+	ksrc := "[numthreads(64, 1, 1)]\nvoid main(uint3 idx : SV_DispatchThreadID) {\n\tParams[idx.x].Off = Params[0].Gain;\n}\n"
+	out, ok := ValidateKernel(k, []byte(ksrc), bss)
+	if !ok {
+		t.Fatal("kernel not validated")
+	}
+	if want := "}\n\n[numthreads(64, 1, 1)]\nvoid main(uint3 idx : SV_DispatchThreadID) {\n\tClampParams(Params[0]);\n\n\tParams[idx.x]"; !strings.Contains(string(out), want) {
+		t.Errorf("missing %q in:\n%s", want, out)
+	}
+
+	if _, err := checkBounds(t, "package main\n\ntype P struct {\n\tG float32 `min:\"1\" default:\"0.5\"`\n}\n"); err == nil {
+		t.Error("expected error for default outside of the bounds")
+	}
+}
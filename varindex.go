@@ -0,0 +1,168 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/gosl/v2/alignsl"
+	"golang.org/x/tools/go/packages"
+)
+
+// VarIndex is a struct type for which a VarByIndex function is generated,
+// returning the value of a field selected by an index, as a float, from
+// the -varindex arg, so that monitoring kernels can record any variable,
+// e.g., NeuronVarByIndex(Neurons[i], NeuronVarGe).
+type VarIndex struct {
+
+	// name of the struct type, e.g., Neuron
+	Type string
+
+	// names of the indexed fields: the exported 32 bit basic fields
+	Fields []string
+
+	// HLSL type of each field: float, int or uint
+	Kinds []string
+}
+
+// NewVarIndex returns the VarIndex for given struct type in the package
+func NewVarIndex(pkg *packages.Package, typ string) (*VarIndex, error) {
+	obj := pkg.Types.Scope().Lookup(typ)
+	if obj == nil {
+		return nil, fmt.Errorf("gosl: -varindex type not found in gosl regions: %s", typ)
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("gosl: -varindex type is not a struct: %s", typ)
+	}
+	vi := &VarIndex{Type: typ}
+	for _, f := range alignsl.GPUFields(st) {
+		ht := hlslBasicType(f.Type())
+		if !f.Exported() || ht == "" {
+			continue
+		}
+		vi.Fields = append(vi.Fields, f.Name())
+		vi.Kinds = append(vi.Kinds, ht)
+	}
+	if len(vi.Fields) == 0 {
+		return nil, fmt.Errorf("gosl: -varindex type has no exported 32 bit basic fields: %s", typ)
+	}
+	return vi, nil
+}
+
+// Name returns the base name of the generated files, e.g., neuronvars
+func (vi *VarIndex) Name() string {
+	return strings.ToLower(vi.Type) + "vars"
+}
+
+// Const returns the name of the index constant for given field,
+// e.g., NeuronVarGe
+func (vi *VarIndex) Const(field string) string {
+	return vi.Type + "Var" + field
+}
+
+// HLSL returns the header with the index constants and VarByIndex function
+func (vi *VarIndex) HLSL() []byte {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	guard := "__" + strings.ToUpper(vi.Name()) + "_HLSL__"
+	fmt.Fprintf(&b, "#ifndef %s\n#define %s\n\n", guard, guard)
+	fmt.Fprintf(&b, "// indexes of the %s variables for %sVarByIndex\n", vi.Type, vi.Type)
+	for i, f := range vi.Fields {
+		fmt.Fprintf(&b, "static const int %s = %d;\n", vi.Const(f), i)
+	}
+	fmt.Fprintf(&b, "static const int %sVarN = %d;\n\n", vi.Type, len(vi.Fields))
+	fmt.Fprintf(&b, "// %sVarByIndex returns the value of the %s variable with given\n", vi.Type, vi.Type)
+	fmt.Fprintf(&b, "// index, one of the %sVar constants, as a float, or 0 if out of range.\n", vi.Type)
+	fmt.Fprintf(&b, "float %sVarByIndex(in %s n, int idx) {\n\tswitch (idx) {\n", vi.Type, vi.Type)
+	for i, f := range vi.Fields {
+		if vi.Kinds[i] == "float" {
+			fmt.Fprintf(&b, "\tcase %d: return n.%s;\n", i, f)
+		} else {
+			fmt.Fprintf(&b, "\tcase %d: return float(n.%s);\n", i, f)
+		}
+	}
+	b.WriteString("\tdefault: return 0;\n\t}\n}\n\n")
+	fmt.Fprintf(&b, "#endif // %s\n", guard)
+	return b.Bytes()
+}
+
+// Go returns the Go source with the matching index constants and a CPU
+// version of the VarByIndex function, in given package.
+func (vi *VarIndex) Go(pkgName string) ([]byte, error) {
+	var b bytes.Buffer
+	vt := vi.Type + "Var"
+	b.WriteString("// Code generated by \"gosl\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	fmt.Fprintf(&b, "// %s is the index of a %s variable, for %sVarByIndex,\n", vt, vi.Type, vi.Type)
+	fmt.Fprintf(&b, "// matching the constants in %s.hlsl for monitoring kernels.\n", vi.Name())
+	fmt.Fprintf(&b, "type %s int32\n\n", vt)
+	b.WriteString("const (\n")
+	for i, f := range vi.Fields {
+		if i == 0 {
+			fmt.Fprintf(&b, "\t%s %s = iota\n", vi.Const(f), vt)
+		} else {
+			fmt.Fprintf(&b, "\t%s\n", vi.Const(f))
+		}
+	}
+	fmt.Fprintf(&b, "\n\t// %sN is the number of %s variables\n\t%sN\n)\n\n", vt, vi.Type, vt)
+	fmt.Fprintf(&b, "// %sNames are the names of the %s variables, by index\n", vt, vi.Type)
+	fmt.Fprintf(&b, "var %sNames = []string{", vt)
+	for i, f := range vi.Fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q", f)
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "// %sByName returns the index of the %s variable with given name,\n// or -1 if not found.\n", vt, vi.Type)
+	fmt.Fprintf(&b, "func %sByName(name string) %s {\n\tfor i, nm := range %sNames {\n\t\tif nm == name {\n\t\t\treturn %s(i)\n\t\t}\n\t}\n\treturn -1\n}\n\n", vt, vt, vt, vt)
+	fmt.Fprintf(&b, "// %sVarByIndex returns the value of the %s variable with given\n", vi.Type, vi.Type)
+	fmt.Fprintf(&b, "// index as a float32, or 0 if out of range, as on the GPU.\n")
+	fmt.Fprintf(&b, "func %sVarByIndex(n *%s, idx %s) float32 {\n\tswitch idx {\n", vi.Type, vi.Type, vt)
+	for i, f := range vi.Fields {
+		if vi.Kinds[i] == "float" {
+			fmt.Fprintf(&b, "\tcase %s:\n\t\treturn n.%s\n", vi.Const(f), f)
+		} else {
+			fmt.Fprintf(&b, "\tcase %s:\n\t\treturn float32(n.%s)\n", vi.Const(f), f)
+		}
+	}
+	b.WriteString("\t}\n\treturn 0\n}\n")
+	return format.Source(b.Bytes())
+}
+
+// GenVarIndexes generates the VarByIndex header in the output directory,
+// and the Go file with the matching constants in the current directory,
+// for each type in the comma-separated -varindex arg.
+func GenVarIndexes(pkg *packages.Package, spec string) error {
+	for _, typ := range strings.Split(spec, ",") {
+		if typ = strings.TrimSpace(typ); typ == "" {
+			continue
+		}
+		vi, err := NewVarIndex(pkg, typ)
+		if err != nil {
+			return err
+		}
+		nm := vi.Name()
+		if err := WriteGenerated(filepath.Join(GenDir(), nm+".hlsl"), nm, FormatShader("hlsl", vi.HLSL())); err != nil {
+			return err
+		}
+		gofn := nm + ".go"
+		pnm, _ := DocPackageName(gofn)
+		src, err := vi.Go(pnm)
+		if err != nil {
+			return err
+		}
+		if err := WriteGenerated(gofn, nm, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVarIndex(t *testing.T) {
+	src := "package main\n\ntype Neuron struct {\n\tGe, Act float32\n\tLayIndex uint32\n\tName string `gosl:\"-\"`\n\tPos [2]float32\n\tpad float32\n}\n"
+	pkg := testPackage(t, "neuron.go", src)
+	vi, err := NewVarIndex(pkg, "Neuron")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(vi.Fields, ",") != "Ge,Act,LayIndex" || vi.Name() != "neuronvars" {
+		t.Fatalf("wrong fields or name: %+v", vi)
+	}
+	hsrc := string(vi.HLSL())
+	for _, want := range []string{"static const int NeuronVarLayIndex = 2;", "float NeuronVarByIndex(in Neuron n, int idx) {", "case 2: return float(n.LayIndex);"} {
+		if !strings.Contains(hsrc, want) {
+			t.Errorf("missing %q in:\n%s", want, hsrc)
+		}
+	}
+	gsrc, err := vi.Go("axon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"NeuronVarGe NeuronVar = iota", "case NeuronVarLayIndex:\n\t\treturn float32(n.LayIndex)"} {
+		if !strings.Contains(string(gsrc), want) {
+			t.Errorf("missing %q in:\n%s", want, gsrc)
+		}
+	}
+	if _, err := NewVarIndex(pkg, "Synapse"); err == nil {
+		t.Error("expected error for missing type")
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"regexp"
+	"strings"
+)
+
+// vecTokenRe matches the tokens of a statement for Vectorize:
+// access chains (e.g., nrn.Ge), numbers, and operators
+var vecTokenRe = regexp.MustCompile(`[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*|\d+\.?\d*(?:[eE][-+]?\d+)?f?|[-+*/]=|[-+*/()=]|\S`)
+
+// vecHeaderRe matches a function header line
+var vecHeaderRe = regexp.MustCompile(`^\t*[\w<>]+\s+(\w+)\s*\(.*\)\s*\{\s*$`)
+
+// vecStruct matches the start of a struct at the top level
+var vecStructRe = regexp.MustCompile(`^struct\s+(\w+)\s*\{`)
+
+// vecStmt is a candidate statement for Vectorize: chain op= expr;
+type vecStmt struct {
+
+	// line index
+	Line int
+
+	// leading tabs
+	Indent string
+
+	// tokens, starting with the assigned chain and the operator
+	Tokens []string
+}
+
+// parseVecStmt returns the candidate statement on the given line, or
+// nil if it is not one: a single update of a field, which is
+// assigned with an arithmetic operator or uses its own value, e.g.,
+// nrn.Ge -= decay * nrn.Ge;
+// with only access chains, numbers, arithmetic operators and parens,
+// and no function calls.
+func parseVecStmt(line int, ln []byte) *vecStmt {
+	s := string(ln)
+	code := strings.TrimLeft(s, "\t")
+	if !strings.HasSuffix(code, ";") || strings.Contains(code, "//") {
+		return nil
+	}
+	toks := vecTokenRe.FindAllString(strings.TrimSuffix(code, ";"), -1)
+	if len(toks) < 3 || !strings.Contains(toks[0], ".") || !isVecChain(toks[0]) {
+		return nil
+	}
+	switch toks[1] {
+	case "=", "+=", "-=", "*=", "/=":
+	default:
+		return nil
+	}
+	update := toks[1] != "="
+	for i, t := range toks[2:] {
+		switch {
+		case isVecChain(t):
+			if i+3 < len(toks) && toks[i+3] == "(" {
+				return nil // function call
+			}
+			update = update || t == toks[0]
+		case t[0] >= '0' && t[0] <= '9':
+		case len(t) == 1 && strings.Contains("+-*/()", t):
+		default:
+			return nil
+		}
+	}
+	if !update {
+		return nil // setting is not worth vectorizing
+	}
+	return &vecStmt{Line: line, Indent: s[:len(s)-len(code)], Tokens: toks}
+}
+
+// isVecChain returns whether the given token is an access chain
+func isVecChain(t string) bool {
+	c := t[0]
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// vecPrefix returns the part of a chain before the last field, e.g., nrn
+// for nrn.Ge, or "" if it has no field.
+func vecPrefix(t string) string {
+	if i := strings.LastIndex(t, "."); i > 0 {
+		return t[:i]
+	}
+	return ""
+}
+
+// vecGroup returns whether the given statements can be computed
+// together as one vector operation, and the positions of the tokens
+// that vary between them.  They must have the same tokens, except for
+// chains to fields of the same variable, starting with the assigned
+// fields, which must be different, and no statement can use a field
+// assigned by a previous one.
+func vecGroup(stmts []*vecStmt) ([]int, bool) {
+	s0 := stmts[0]
+	for _, s := range stmts[1:] {
+		if len(s.Tokens) != len(s0.Tokens) || s.Indent != s0.Indent {
+			return nil, false
+		}
+	}
+	var vary []int
+	for p, t := range s0.Tokens {
+		same := true
+		for _, s := range stmts[1:] {
+			same = same && s.Tokens[p] == t
+		}
+		if same {
+			continue
+		}
+		for _, s := range stmts {
+			st := s.Tokens[p]
+			if !isVecChain(st) || vecPrefix(st) == "" || vecPrefix(st) != vecPrefix(t) {
+				return nil, false
+			}
+		}
+		vary = append(vary, p)
+	}
+	if len(vary) == 0 || vary[0] != 0 {
+		return nil, false
+	}
+	for i, s := range stmts {
+		for _, s2 := range stmts[i+1:] {
+			for _, t := range s2.Tokens {
+				if t == s.Tokens[0] {
+					return nil, false
+				}
+			}
+		}
+	}
+	return vary, true
+}
+
+// Vectorize returns the given HLSL source with runs of 2-4 adjacent
+// statements that apply the same arithmetic operation to different
+// float fields of the same variable, e.g., exponential decay updates:
+//
+//	nrn.GeSyn -= decay * (nrn.GeSyn - nrn.GeBase);
+//	nrn.Gi -= decay * (nrn.Gi - nrn.GiBase);
+//
+// computed as one operation on float2-4 vectors, in a block:
+//
+//	{
+//		float2 gosl_v0 = float2(nrn.GeSyn, nrn.Gi);
+//		float2 gosl_v1 = float2(nrn.GeBase, nrn.GiBase);
+//		gosl_v0 -= decay * (gosl_v0 - gosl_v1);
+//		nrn.GeSyn = gosl_v0.x;
+//		nrn.Gi = gosl_v0.y;
+//	}
+//
+// along with the number of runs.  The types of all the values must be
+// resolved, using the types in the given package for the fields, and
+// the other values must be scalars.  Statements that use a field
+// assigned by a previous one in the run are not included, as the
+// vector operation uses the previous values of all the fields.
+func Vectorize(pkg *types.Package, src []byte) ([]byte, int) {
+	lb := NewLineBuffer(src)
+	nrun := 0
+	for i := 0; i < lb.Len(); i++ {
+		var run []*vecStmt
+		var vary []int
+		for j := i; j < lb.Len() && len(run) < 4; j++ {
+			s := parseVecStmt(j, lb.Lines[j])
+			if s == nil {
+				break
+			}
+			vr, ok := vecGroup(append(run, s))
+			if !ok && len(run) > 0 {
+				break
+			}
+			run = append(run, s)
+			vary = vr
+		}
+		if len(run) < 2 || !vecTypesOK(pkg, lb.Lines, run, vary) {
+			continue
+		}
+		vlns := vecRun(run, vary)
+		lb.Delete(i, i+len(run))
+		lb.Insert(i, vlns...)
+		i += len(vlns) - 1
+		nrun++
+	}
+	return lb.Bytes(), nrun
+}
+
+// vecTypesOK returns whether the varying chains of the given run are
+// all float fields, and the other chains are scalars.
+func vecTypesOK(pkg *types.Package, lines [][]byte, run []*vecStmt, vary []int) bool {
+	isVary := map[int]bool{}
+	for _, p := range vary {
+		isVary[p] = true
+	}
+	for _, s := range run {
+		for p, t := range s.Tokens {
+			if !isVecChain(t) || (!isVary[p] && s != run[0]) {
+				continue
+			}
+			typ := vecChainType(pkg, lines, s.Line, t)
+			if typ == "" || (isVary[p] && typ != "float") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// vecRun returns the lines of the vector version of the given run.
+func vecRun(run []*vecStmt, vary []int) [][]byte {
+	n := len(run)
+	ind := run[0].Indent
+	vtype := fmt.Sprintf("float%d", n)
+	var lns [][]byte
+	lns = append(lns, []byte(ind+"{"))
+	// vector names of the varying positions, with the same
+	// name for positions with the same chains
+	names := map[int]string{}
+	cols := map[string]string{}
+	for _, p := range vary {
+		vals := make([]string, n)
+		for i, s := range run {
+			vals[i] = s.Tokens[p]
+		}
+		col := strings.Join(vals, ", ")
+		if nm, ok := cols[col]; ok {
+			names[p] = nm
+			continue
+		}
+		nm := fmt.Sprintf("gosl_v%d", len(cols))
+		cols[col] = nm
+		names[p] = nm
+		lns = append(lns, []byte(fmt.Sprintf("%s\t%s %s = %s(%s);", ind, vtype, nm, vtype, col)))
+	}
+	toks := make([]string, len(run[0].Tokens))
+	for p, t := range run[0].Tokens {
+		if nm, ok := names[p]; ok {
+			t = nm
+		}
+		toks[p] = t
+	}
+	lns = append(lns, []byte(ind+"\t"+vecJoin(toks)+";"))
+	for i, s := range run {
+		lns = append(lns, []byte(fmt.Sprintf("%s\t%s = %s.%c;", ind, s.Tokens[0], names[0], "xyzw"[i])))
+	}
+	return append(lns, []byte(ind+"}"))
+}
+
+// vecJoin joins the given tokens with spaces around binary operators
+func vecJoin(toks []string) string {
+	var b strings.Builder
+	for i, t := range toks {
+		if i > 0 && len(t) <= 2 && strings.ContainsAny(t[:1], "+-*/=") {
+			if pt := toks[i-1]; pt == ")" || isVecChain(pt) || (pt[0] >= '0' && pt[0] <= '9') {
+				b.WriteString(" " + t + " ")
+				continue
+			}
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+// vecChainType returns the HLSL scalar type of the given chain used on
+// the given line: float, int, uint or bool, or "" if it is not a scalar
+// or cannot be resolved.  The type of the variable at the root of the
+// chain is from the nearest declaration in an enclosing scope of the
+// function, or the struct of the method for this.
+func vecChainType(pkg *types.Package, lines [][]byte, line int, chain string) string {
+	flds := strings.Split(chain, ".")
+	root := flds[0]
+	tname := ""
+	if root == "this" {
+		tname = vecThisType(lines, line)
+	} else {
+		tname = vecDeclType(pkg, lines, line, root)
+	}
+	if tname == "" {
+		return ""
+	}
+	switch tname {
+	case "float", "int", "uint", "bool":
+		if len(flds) == 1 {
+			return tname
+		}
+		return ""
+	}
+	obj, ok := pkg.Scope().Lookup(tname).(*types.TypeName)
+	if !ok {
+		return ""
+	}
+	typ := obj.Type()
+	for _, fn := range flds[1:] {
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			return ""
+		}
+		var ft types.Type
+		for i := range st.NumFields() {
+			if st.Field(i).Name() == fn {
+				ft = st.Field(i).Type()
+			}
+		}
+		if ft == nil {
+			return ""
+		}
+		typ = ft
+	}
+	bt, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return ""
+	}
+	switch bt.Kind() {
+	case types.Float32:
+		return "float"
+	case types.Int32:
+		return "int"
+	case types.Uint32:
+		return "uint"
+	case types.Bool:
+		return "bool"
+	}
+	return ""
+}
+
+// vecThisType returns the name of the struct of the method that
+// contains the given line, or "" if none.
+func vecThisType(lines [][]byte, line int) string {
+	for j := line - 1; j >= 0; j-- {
+		ln := lines[j]
+		if len(ln) == 0 || ln[0] == '\t' || ln[0] == ' ' {
+			continue
+		}
+		if m := vecStructRe.FindSubmatch(ln); m != nil {
+			return string(m[1])
+		}
+		if ln[0] != '/' {
+			return ""
+		}
+	}
+	return ""
+}
+
+// vecDeclType returns the type name of the given variable used on the
+// given line, from the nearest declaration in an enclosing scope of the
+// function, including its parameters, or "" if not found.
+func vecDeclType(pkg *types.Package, lines [][]byte, line int, name string) string {
+	declRe := regexp.MustCompile(`(?:^|[\s(,])(\w+)\s+` + regexp.QuoteMeta(name) + `\s*(?:[,;)=]|$)`)
+	minInd := len(lines[line]) - len(bytes.TrimLeft(lines[line], "\t"))
+	for j := line - 1; j >= 0; j-- {
+		ln := lines[j]
+		code := bytes.TrimLeft(ln, "\t")
+		if len(code) == 0 {
+			continue
+		}
+		ind := len(ln) - len(code)
+		if ind > minInd {
+			continue
+		}
+		minInd = ind
+		for _, m := range declRe.FindAllSubmatch(code, -1) {
+			tn := string(m[1])
+			switch tn {
+			case "float", "int", "uint", "bool":
+				return tn
+			}
+			if _, ok := pkg.Scope().Lookup(tn).(*types.TypeName); ok {
+				return tn
+			}
+		}
+		if ind == 0 || vecHeaderRe.Match(ln) && !isControlLine(code) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// isControlLine returns whether the given line starts a control statement
+func isControlLine(code []byte) bool {
+	for _, kw := range []string{"if", "for", "while", "switch", "else", "do"} {
+		if bytes.HasPrefix(code, []byte(kw)) && (len(code) == len(kw) || !isVecChain(string(code[len(kw):len(kw)+1])) && (code[len(kw)] < '0' || code[len(kw)] > '9')) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const vecTestTypes = `package main
+
+type Neuron struct {
+	Ge, Gi, GeBase, GiBase, GeM, GiM, CaM, CaP, CaD float32
+	Flags int32
+}
+
+type InitParams struct {
+	Act float32
+}
+
+type DtParams struct {
+	Dt, MDt, PDt float32
+	Init InitParams
+}
+`
+
+const vecTestSrc = `struct DtParams {
+	float Dt;
+	void Update(inout Neuron nrn, float decay) {
+		nrn.Ge -= decay * (nrn.Ge - nrn.GeBase);
+		nrn.Gi -= decay * (nrn.Gi - nrn.GiBase);
+		nrn.GeM += this.Dt * (nrn.Ge - nrn.GeM);
+		nrn.GiM += this.Dt * (nrn.Gi - nrn.GiM);
+		nrn.CaP += decay * (nrn.CaM - nrn.CaP);
+		nrn.CaD += decay * (nrn.CaP - nrn.CaD);
+		nrn.CaM = -2 * nrn.CaM + this.Init.Act;
+		nrn.CaD = -2 * nrn.CaD + this.Init.Act;
+	}
+	void Other(inout Neuron nrn, float decay) {
+		nrn.Ge -= Exp(decay) * nrn.Ge;
+		nrn.Gi -= Exp(decay) * nrn.Gi;
+		nrn.Flags -= 2 * nrn.Flags;
+		nrn.Flags -= 2 * nrn.Flags;
+		nrn.Ge -= other * nrn.Ge;
+		nrn.Gi -= other * nrn.Gi;
+		nrn.Ge = -1;
+		nrn.Gi = -1;
+	}
+};
+`
+
+func TestVectorize(t *testing.T) {
+	out, n := Vectorize(testPackage(t, "types.go", vecTestTypes).Types, []byte(vecTestSrc))
+	// Ge, Gi; GeM, GiM; CaM, CaD -- CaD uses CaP, and Other has none
+	if n != 3 {
+		t.Errorf("vectorized %d runs, want 3 in:\n%s", n, out)
+	}
+	for _, want := range []string{
+		"\t\t{\n\t\t\tfloat2 gosl_v0 = float2(nrn.Ge, nrn.Gi);\n\t\t\tfloat2 gosl_v1 = float2(nrn.GeBase, nrn.GiBase);\n\t\t\tgosl_v0 -= decay * (gosl_v0 - gosl_v1);\n\t\t\tnrn.Ge = gosl_v0.x;\n\t\t\tnrn.Gi = gosl_v0.y;\n\t\t}\n",
+		"gosl_v0 += this.Dt * (gosl_v1 - gosl_v0);",
+		"\t\tnrn.CaP += decay * (nrn.CaM - nrn.CaP);\n\t\tnrn.CaD += decay * (nrn.CaP - nrn.CaD);\n",
+		"gosl_v0 = -2 * gosl_v0 + this.Init.Act;",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if other := string(out[strings.Index(string(out), "void Other"):]); strings.Contains(other, "gosl_v") {
+		t.Errorf("calls, int fields, unknown variables or sets were vectorized:\n%s", other)
+	}
+}
+
+// TestVectorizeValues checks that the vectorized runs compute the same
+// values as the original statements, for random values.
+func TestVectorizeValues(t *testing.T) {
+	out, _ := Vectorize(testPackage(t, "types.go", vecTestTypes).Types, []byte(vecTestSrc))
+	lines := strings.Split(string(out), "\n")
+	orig := strings.Split(vecTestSrc, "\n")
+	other := 0
+	for i, ln := range lines {
+		if strings.Contains(ln, "void Other") {
+			other = i
+		}
+	}
+	vars := []string{"decay", "this.Dt", "this.Init.Act"}
+	for _, f := range []string{"Ge", "Gi", "GeBase", "GiBase", "GeM", "GiM", "CaM", "CaP", "CaD"} {
+		vars = append(vars, "nrn."+f)
+	}
+	for range 10 {
+		env := map[string]float64{}
+		for _, v := range vars {
+			env[v] = rand.Float64()*2 - 1
+		}
+		want := vecTestRun(t, orig[3:11], env)
+		got := vecTestRun(t, lines[3:other], env)
+		for _, v := range vars {
+			if math.Abs(want[v]-got[v]) > 1e-12 {
+				t.Errorf("%s: vectorized %g != original %g", v, got[v], want[v])
+			}
+		}
+	}
+}
+
+var vecTestDeclRe = regexp.MustCompile(`^float\d (\w+) = float\d\((.*)\);$`)
+
+// vecTestRun runs the given statements, including the vector runs
+// generated by Vectorize, on a copy of the given values.
+func vecTestRun(t *testing.T, lines []string, vals map[string]float64) map[string]float64 {
+	env := map[string]float64{}
+	for k, v := range vals {
+		env[k] = v
+	}
+	vecs := map[string][]float64{}
+	for _, ln := range lines {
+		ln = strings.TrimSpace(ln)
+		if ln == "{" || ln == "}" || strings.HasPrefix(ln, "void ") || ln == "" {
+			continue
+		}
+		if m := vecTestDeclRe.FindStringSubmatch(ln); m != nil {
+			vecs[m[1]] = nil
+			for _, c := range strings.Split(m[2], ", ") {
+				vecs[m[1]] = append(vecs[m[1]], env[c])
+			}
+			continue
+		}
+		toks := vecTokenRe.FindAllString(strings.TrimSuffix(ln, ";"), -1)
+		lhs, op := toks[0], toks[1]
+		if len(toks) == 3 && strings.HasPrefix(toks[2], "gosl_v") {
+			nm, sw, _ := strings.Cut(toks[2], ".")
+			env[lhs] = vecs[nm][strings.Index("xyzw", sw)]
+			continue
+		}
+		lanes := 1
+		if v, ok := vecs[lhs]; ok {
+			lanes = len(v)
+		}
+		for l := range lanes {
+			get := func(c string) float64 {
+				if v, ok := vecs[c]; ok {
+					return v[l]
+				}
+				if x, err := strconv.ParseFloat(c, 64); err == nil {
+					return x
+				}
+				if _, ok := env[c]; !ok {
+					t.Fatalf("undefined: %s", c)
+				}
+				return env[c]
+			}
+			cur := get(lhs)
+			pos := 2
+			x := vecTestExpr(toks, &pos, get)
+			switch op {
+			case "=":
+				cur = x
+			case "+=":
+				cur += x
+			case "-=":
+				cur -= x
+			case "*=":
+				cur *= x
+			case "/=":
+				cur /= x
+			}
+			if v, ok := vecs[lhs]; ok {
+				v[l] = cur
+			} else {
+				env[lhs] = cur
+			}
+		}
+	}
+	return env
+}
+
+// vecTestExpr evaluates the expression in the given tokens from pos
+func vecTestExpr(toks []string, pos *int, get func(string) float64) float64 {
+	var term func() float64
+	factor := func() float64 {
+		t := toks[*pos]
+		*pos++
+		switch t {
+		case "(":
+			v := vecTestExpr(toks, pos, get)
+			*pos++ // )
+			return v
+		case "-":
+			return -term()
+		}
+		return get(t)
+	}
+	term = func() float64 {
+		v := factor()
+		for *pos < len(toks) && (toks[*pos] == "*" || toks[*pos] == "/") {
+			op := toks[*pos]
+			*pos++
+			if op == "*" {
+				v *= factor()
+			} else {
+				v /= factor()
+			}
+		}
+		return v
+	}
+	v := term()
+	for *pos < len(toks) && (toks[*pos] == "+" || toks[*pos] == "-") {
+		op := toks[*pos]
+		*pos++
+		if op == "+" {
+			v += term()
+		} else {
+			v -= term()
+		}
+	}
+	return v
+}
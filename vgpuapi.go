@@ -0,0 +1,86 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VgpuAPI is a version of the vgpu API targeted by the generated Go code
+// that calls vgpu, so that downstream users on older vgpu releases can
+// still regenerate their code.  The generated code is written for the
+// current API (cogentcore.org/core/vgpu), and converted with Convert.
+type VgpuAPI struct {
+
+	// name of the version, as given in the -vgpu flag
+	Name string
+
+	// import path of the vgpu package
+	Import string
+
+	// names of methods and fields in this version, by their name in
+	// the current API, for those that differ
+	Names map[string]string
+}
+
+// VgpuCurrent is the name of the current vgpu API version
+const VgpuCurrent = "core"
+
+// VgpuAPIs are the supported vgpu API versions, by name
+var VgpuAPIs = map[string]*VgpuAPI{
+	VgpuCurrent: {Name: VgpuCurrent, Import: "cogentcore.org/core/vgpu"},
+	"goki": {Name: "goki", Import: "github.com/goki/vgpu/vgpu", Names: map[string]string{
+		"Values":                "Vals",
+		"ValueByIndexTry":       "ValByIdxTry",
+		"ValueByNameTry":        "ValByNameTry",
+		"BindDynamicValueIndex": "BindDynValIdx",
+		"BindDynamicValueName":  "BindDynValName",
+		"SyncValueIndexFromGPU": "SyncValIdxFmGPU",
+		"ConfigValues":          "ConfigVals",
+	}},
+}
+
+// VgpuTarget is the vgpu API version set by the -vgpu flag
+var VgpuTarget = VgpuAPIs[VgpuCurrent]
+
+// VgpuArgs sets the VgpuTarget from the -vgpu flag.
+func VgpuArgs() error {
+	api, ok := VgpuAPIs[*vgpuVersion]
+	if !ok {
+		nms := make([]string, 0, len(VgpuAPIs))
+		for nm := range VgpuAPIs {
+			nms = append(nms, nm)
+		}
+		sort.Strings(nms)
+		return fmt.Errorf("gosl: -vgpu %q is not a supported vgpu API version: %s", *vgpuVersion, strings.Join(nms, ", "))
+	}
+	VgpuTarget = api
+	return nil
+}
+
+// Convert converts the given Go source written for the current vgpu API
+// to this version: the import path, and the selected names (e.g., .Values)
+// that differ.
+func (api *VgpuAPI) Convert(src []byte) []byte {
+	if api.Name == VgpuCurrent {
+		return src
+	}
+	cur := VgpuAPIs[VgpuCurrent]
+	src = []byte(strings.ReplaceAll(string(src), `"`+cur.Import+`"`, `"`+api.Import+`"`))
+	nms := make([]string, 0, len(api.Names))
+	for nm := range api.Names {
+		nms = append(nms, regexp.QuoteMeta(nm))
+	}
+	if len(nms) == 0 {
+		return src
+	}
+	re := regexp.MustCompile(`\.(` + strings.Join(nms, "|") + `)\b`)
+	return re.ReplaceAllFunc(src, func(m []byte) []byte {
+		return []byte("." + api.Names[string(m[1:])])
+	})
+}
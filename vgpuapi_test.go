@@ -0,0 +1,30 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestVgpuConvert(t *testing.T) {
+	src := `import "cogentcore.org/core/vgpu"
+
+	pvl, _ := parsv.Values.ValueByIndexTry(0)
+	vars.BindDynamicValueIndex(0, "Params", 0)
+	sy.Mem.SyncValueIndexFromGPU(1, "Data", 0)
+	nValues := 1
+`
+	want := `import "github.com/goki/vgpu/vgpu"
+
+	pvl, _ := parsv.Vals.ValByIdxTry(0)
+	vars.BindDynValIdx(0, "Params", 0)
+	sy.Mem.SyncValIdxFmGPU(1, "Data", 0)
+	nValues := 1
+`
+	if got := string(VgpuAPIs["goki"].Convert([]byte(src))); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got := string(VgpuAPIs[VgpuCurrent].Convert([]byte(src))); got != src {
+		t.Errorf("current API changed source:\n%s", got)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/emer/gosl/v2/slreload"
+)
+
+// WatchInterval is the interval at which the source files are
+// checked for changes in -watch mode.
+var WatchInterval = 500 * time.Millisecond
+
+// Watch regenerates the shaders whenever any of the source files in
+// the given paths change, until the process is interrupted.
+// The slreload.SignalFile is written in the output directory after each
+// regeneration, so that an slreload.Watcher in a running process
+// only reloads the shaders once they are all complete.
+func Watch(args []string) {
+	WriteSignal()
+	fls := FilesFromPaths(args)
+	mods := ModTimes(fls)
+	fmt.Printf("\ngosl: watching %d source files for changes\n", len(fls))
+	for {
+		time.Sleep(WatchInterval)
+		nmods := ModTimes(fls)
+		if maps.Equal(mods, nmods) {
+			continue
+		}
+		fmt.Printf("\ngosl: source files changed, regenerating: %s\n", time.Now().Format(time.TimeOnly))
+		ResetState()
+		if err := Generate(args); err != nil {
+			fmt.Println(err)
+		}
+		WriteSignal()
+		fls = FilesFromPaths(args) // could have new files
+		mods = ModTimes(fls)
+	}
+}
+
+// ModTimes returns the modification times of the given files,
+// with a zero time for missing files.
+func ModTimes(fls []string) map[string]time.Time {
+	mods := make(map[string]time.Time, len(fls))
+	for _, fn := range fls {
+		var mt time.Time
+		if st, err := os.Stat(fn); err == nil {
+			mt = st.ModTime()
+		}
+		mods[fn] = mt
+	}
+	return mods
+}
+
+// WriteSignal writes the slreload.SignalFile in the output directory,
+// with the time of writing.
+func WriteSignal() {
+	fn := filepath.Join(*outDir, slreload.SignalFile)
+	if err := os.WriteFile(fn, []byte(time.Now().Format(time.RFC3339Nano)+"\n"), 0644); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// ResetState resets the state accumulated while generating,
+// for regenerating in -watch mode, and generating the next package
+// in workspace mode.
+func ResetState() {
+	clear(Kernels)
+	clear(RegionSources)
+	clear(RegionUses)
+	clear(ExcludedNames)
+	clear(ExcludedFuncs)
+	clear(LoadedPackageNames)
+	clear(SPVStatus)
+	InterfaceStructs = nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/emer/gosl/v2/alignsl"
+)
+
+func TestWGSL(t *testing.T) {
+	tg := *target
+	*target = TargetWGSL
+	defer func() { *target = tg }()
+	runTest(t, "testdata/wgsl/basic.go", "testdata/wgsl/basic.golden")
+	runTest(t, "testdata/wgsl/errors.go", "testdata/wgsl/errors.golden")
+}
+
+func TestWGSLSizes(t *testing.T) {
+	src := `package p
+
+type Vec2 struct{ X, Y float32 }
+type Vec3 struct{ X, Y, Z float32 }
+
+type Ok struct {
+	A   float32
+	B   float32
+	Pos Vec2
+	Col Vec3
+	D   float32
+}
+
+type Bad struct {
+	A   float32
+	Pos Vec2
+	Ns  [2]Vec3
+}
+`
+	pkg := testPackage(t, "p.go", src).Types
+	ws := alignsl.WGSLSizes{alignsl.GPUSizes{types.SizesFor("gc", "amd64")}}
+	st := func(nm string) *types.Struct {
+		return pkg.Scope().Lookup(nm).Type().Underlying().(*types.Struct)
+	}
+	ok := st("Ok")
+	var flds []*types.Var
+	for i := range ok.NumFields() {
+		flds = append(flds, ok.Field(i))
+	}
+	offs := ws.Offsetsof(flds)
+	want := []int64{0, 4, 8, 16, 28}
+	for i := range want {
+		if offs[i] != want[i] {
+			t.Errorf("offset of %s: got %d, want %d", flds[i].Name(), offs[i], want[i])
+		}
+	}
+	if sz := ws.Sizeof(ok); sz != 32 {
+		t.Errorf("size of Ok: got %d, want 32", sz)
+	}
+
+	cx := alignsl.NewContext(types.SizesFor("gc", "amd64"))
+	cx.WGSL = true
+	if alignsl.CheckStruct(cx, ok, "Ok") {
+		t.Errorf("unexpected errors for Ok: %v", cx.Errs)
+	}
+	if !alignsl.CheckStruct(cx, st("Bad"), "Bad") {
+		t.Error("no errors for Bad, with a misaligned vector")
+	}
+}
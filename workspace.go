@@ -0,0 +1,256 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// WorkspacePackage is a package to generate in workspace mode,
+// e.g., gosl ./..., which is run in the directory of the package,
+// as go generate does.
+type WorkspacePackage struct {
+
+	// absolute directory of the package
+	Dir string
+
+	// gosl flags and path args for the package, from its
+	// //go:generate gosl line if it has one, else its .go and .hlsl files
+	Args []string
+}
+
+// IsWorkspace returns true if the given path args are a single
+// package pattern ending in /..., e.g., ./... for all of the packages
+// within the current directory, which are generated in workspace mode.
+func IsWorkspace(args []string) bool {
+	return len(args) == 1 && (args[0] == "..." || strings.HasSuffix(args[0], "/..."))
+}
+
+var (
+	goslDirectiveRe = regexp.MustCompile(`(?m)^\s*//\s?gosl: `)
+	goGenerateRe    = regexp.MustCompile(`(?m)^//go:generate\s+(.*)$`)
+)
+
+// WorkspacePackages returns the packages matching the given pattern
+// that have gosl directives, loading all of them at once.  Packages
+// with a //go:generate gosl line are generated with the flags and path
+// args of that line, and packages that are within the path args or
+// output directory of another one (e.g., a chans package of channel
+// types that is included in the shaders of the model package) are
+// skipped.  Other packages with gosl directives are generated from all
+// of their .go and .hlsl files.
+func WorkspacePackages(pattern string) ([]*WorkspacePackage, error) {
+	pkgs, err := packages.Load(LoadConfig(packages.NeedName|packages.NeedFiles), pattern)
+	if err != nil {
+		return nil, err
+	}
+	var wps []*WorkspacePackage
+	covered := map[string]bool{}
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 {
+			continue
+		}
+		dir := filepath.Dir(pkg.GoFiles[0])
+		has := false
+		var args []string
+		for _, fn := range pkg.GoFiles {
+			src, err := os.ReadFile(fn)
+			if err != nil {
+				continue
+			}
+			if goslDirectiveRe.Match(src) {
+				has = true
+			}
+			for _, m := range goGenerateRe.FindAllSubmatch(src, -1) {
+				if ga, ok := GoslGenerateArgs(string(m[1])); ok && args == nil {
+					args = ga
+				}
+			}
+		}
+		if args == nil {
+			if !has {
+				continue
+			}
+			for _, fn := range pkg.GoFiles {
+				args = append(args, filepath.Base(fn))
+			}
+			hls, _ := filepath.Glob(filepath.Join(dir, "*.hlsl"))
+			for _, fn := range hls {
+				args = append(args, filepath.Base(fn))
+			}
+		}
+		wp := &WorkspacePackage{Dir: dir, Args: args}
+		wps = append(wps, wp)
+		for _, d := range wp.Dirs() {
+			if d != dir {
+				covered[d] = true
+			}
+		}
+	}
+	sort.Slice(wps, func(i, j int) bool { return wps[i].Dir < wps[j].Dir })
+	var gen []*WorkspacePackage
+	for _, wp := range wps {
+		if !covered[wp.Dir] {
+			gen = append(gen, wp)
+		}
+	}
+	return gen, nil
+}
+
+// GoslGenerateArgs returns the args for gosl from the given //go:generate
+// command line, if it runs gosl, e.g., gosl -exclude=Update act.go or
+// ../../gosl act.go, or go run github.com/emer/gosl/v2@latest act.go.
+// Double-quoted args are unquoted, as go generate does.
+func GoslGenerateArgs(line string) ([]string, bool) {
+	var words []string
+	for rest := strings.TrimSpace(line); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == '"' {
+			if q, err := strconv.QuotedPrefix(rest); err == nil {
+				w, _ := strconv.Unquote(q)
+				words = append(words, w)
+				rest = rest[len(q):]
+				continue
+			}
+		}
+		w, r, _ := strings.Cut(rest, " ")
+		words = append(words, w)
+		rest = r
+	}
+	isGosl := func(cmd string) bool {
+		cmd, _, _ = strings.Cut(cmd, "@")
+		base := strings.TrimSuffix(filepath.Base(filepath.ToSlash(cmd)), ".exe")
+		return base == "gosl" || strings.HasSuffix(cmd, "/gosl/v2")
+	}
+	switch {
+	case len(words) > 0 && isGosl(words[0]):
+		return words[1:], true
+	case len(words) > 2 && words[0] == "go" && words[1] == "run" && isGosl(words[2]):
+		return words[3:], true
+	}
+	return nil, false
+}
+
+// Dirs returns the absolute directories that the package covers:
+// its own directory, those of its path args that are local
+// directories or files, and its -out directory.
+func (wp *WorkspacePackage) Dirs() []string {
+	dirs := []string{wp.Dir}
+	out := "shaders"
+	for i := 0; i < len(wp.Args); i++ {
+		a := wp.Args[i]
+		if strings.HasPrefix(a, "-") {
+			nm, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+			if f := flag.Lookup(nm); f != nil && !hasVal && !isBoolFlag(f) && i+1 < len(wp.Args) {
+				i++
+				val = wp.Args[i]
+			}
+			if nm == "out" {
+				out = val
+			}
+			continue
+		}
+		p := filepath.Join(wp.Dir, a)
+		if st, err := os.Stat(p); err == nil {
+			if !st.IsDir() {
+				p = filepath.Dir(p)
+			}
+			dirs = append(dirs, p)
+		}
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(wp.Dir, out)
+	}
+	return append(dirs, out)
+}
+
+// isBoolFlag returns true if the given flag does not take a value
+func isBoolFlag(f *flag.Flag) bool {
+	bf, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
+
+// GenerateWorkspace generates all of the WorkspacePackages for the given
+// pattern in one run, in the directory of each package in turn, with the
+// flags given on the command line, overridden by those of its
+// //go:generate gosl line.  The file lists of the packages loaded from
+// the path args, e.g., cogentcore.org/core/math32/fastexp.go, are shared
+// among the packages.  Each package is generated even if a previous one
+// failed, and the errors of all of them are returned.
+func GenerateWorkspace(pattern string) error {
+	wps, err := WorkspacePackages(pattern)
+	if err != nil {
+		return err
+	}
+	if len(wps) == 0 {
+		return fmt.Errorf("gosl: no packages with gosl directives found in: %s", pattern)
+	}
+	// these are relative to where gosl is invoked
+	for _, fp := range []*string{cacheDir, configFile} {
+		if *fp != "" {
+			*fp, _ = filepath.Abs(*fp)
+		}
+	}
+	defaults := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) { defaults[f.Name] = f.Value.String() })
+	defaults["chdir"] = "" // already applied: a -chdir of a package is relative to it
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	defer os.Chdir(wd)
+	loadCache = map[string][]*packages.Package{}
+	defer func() { loadCache = nil }()
+
+	var errs []error
+	for _, wp := range wps {
+		rel, _ := filepath.Rel(wd, wp.Dir)
+		fmt.Printf("\ngosl: generating package: %s %s\n", rel, strings.Join(wp.Args, " "))
+		if err := generatePackage(wp, defaults); err != nil {
+			errs = append(errs, fmt.Errorf("gosl: %s: %w", rel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// generatePackage generates the given WorkspacePackage, with the given
+// default flag values.
+func generatePackage(wp *WorkspacePackage, defaults map[string]string) error {
+	for nm, val := range defaults {
+		flag.Set(nm, val)
+	}
+	fs := flag.NewFlagSet("gosl", flag.ContinueOnError)
+	flag.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+	if err := fs.Parse(wp.Args); err != nil {
+		return err
+	}
+	args := fs.Args()
+	if len(args) == 0 {
+		return errors.New("no path args in //go:generate gosl line")
+	}
+	if *watch {
+		return errors.New("-watch cannot be used in workspace mode")
+	}
+	if err := os.Chdir(wp.Dir); err != nil {
+		return err
+	}
+	if err := Chdir(); err != nil {
+		return err
+	}
+	ResetState()
+	if err := ProcessArgs(); err != nil {
+		return err
+	}
+	return Generate(args)
+}
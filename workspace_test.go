@@ -0,0 +1,30 @@
+// Copyright (c) 2024, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGoslGenerateArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		args []string
+		ok   bool
+	}{
+		{"gosl -exclude=Update act.go", []string{"-exclude=Update", "act.go"}, true},
+		{"../../gosl  rand.go rand.hlsl", []string{"rand.go", "rand.hlsl"}, true},
+		{`go run github.com/emer/gosl/v2@latest -doc "gpu doc.go" .`, []string{"-doc", "gpu doc.go", "."}, true},
+		{"stringer -type=Flags", nil, false},
+		{"go run ./cmd/goslint", nil, false},
+	}
+	for _, tt := range tests {
+		args, ok := GoslGenerateArgs(tt.line)
+		if ok != tt.ok || !slices.Equal(args, tt.args) {
+			t.Errorf("%s: got %q, %v, want %q, %v", tt.line, args, ok, tt.args, tt.ok)
+		}
+	}
+}